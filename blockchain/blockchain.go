@@ -25,10 +25,13 @@ type Processor interface {
 	GetEthHttpClient() *ethclient.Client
 	GetEthWSClient() *ethclient.Client
 	CurrentBlock() (*big.Int, error)
+	ConfirmedBlock() (*big.Int, error)
+	BlockHash(number *big.Int) (common.Hash, error)
 	CompareWithLatestBlockNumber(blockNumberPassed *big.Int, allowedBlockChainDifference uint64) error
 	HasIdentity() bool
 	Close()
 	MultiPartyEscrowChannel(channelID *big.Int) (channel *MultiPartyEscrowChannel, ok bool, err error)
+	MultiPartyEscrowChannelAt(channelID *big.Int, block *big.Int) (channel *MultiPartyEscrowChannel, ok bool, err error)
 }
 
 var (
@@ -82,6 +85,10 @@ func NewProcessor(metadata *ServiceMetadata) (Processor, error) {
 	// TODO: Read this from github
 
 	p.escrowContractAddress = metadata.GetMpeAddress()
+	if address := config.GetMpeAddress(); address != "" && common.HexToAddress(address) != p.escrowContractAddress {
+		return &p, fmt.Errorf("mpe_address %v of the service metadata doesn't match mpe_address %v of the network",
+			p.escrowContractAddress.Hex(), address)
+	}
 
 	if mpe, err := NewMultiPartyEscrow(p.escrowContractAddress, p.ethHttpClient); err != nil {
 		return &p, errors.Wrap(err, "error instantiating MultiPartyEscrow contract")
@@ -150,6 +157,33 @@ func (processor *processor) CurrentBlock() (currentBlock *big.Int, err error) {
 	return new(big.Int).SetUint64(latestBlock), nil
 }
 
+// ConfirmedBlock returns the latest block which has confirmation_depth blocks on top of it,
+// nil means the latest block is used for the reads (confirmation_depth is 0)
+func (processor *processor) ConfirmedBlock() (confirmedBlock *big.Int, err error) {
+	depth := config.GetConfirmationDepth()
+	if depth == 0 {
+		return nil, nil
+	}
+	latestBlock, err := processor.CurrentBlock()
+	if err != nil {
+		return nil, err
+	}
+	confirmedBlock = latestBlock.Sub(latestBlock, new(big.Int).SetUint64(depth))
+	if confirmedBlock.Sign() < 0 {
+		confirmedBlock.SetInt64(0)
+	}
+	return confirmedBlock, nil
+}
+
+// BlockHash returns the hash of the block with the given number, it's used to detect the chain reorganizations
+func (processor *processor) BlockHash(number *big.Int) (hash common.Hash, err error) {
+	header, err := processor.ethHttpClient.HeaderByNumber(context.Background(), number)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error getting header of block %v: %v", number, err)
+	}
+	return header.Hash(), nil
+}
+
 func (processor *processor) CompareWithLatestBlockNumber(blockNumberPassed *big.Int, allowedBlockChainDifference uint64) (err error) {
 	latestBlockNumber, err := processor.CurrentBlock()
 	if err != nil {
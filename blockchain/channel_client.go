@@ -0,0 +1,148 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+)
+
+// ChannelBackend is the chain connection used by the channel client, both the
+// ethclient.Client and the client of the simulated backend implement it
+type ChannelBackend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// ChannelClient sends the transactions of the payment channel sender to the
+// MultiPartyEscrow: it deposits the tokens, opens the channels and funds them
+type ChannelClient struct {
+	backend                 ChannelBackend
+	multiPartyEscrow        *MultiPartyEscrow
+	multiPartyEscrowAddress common.Address
+	wallet                  *bind.TransactOpts
+}
+
+// NewChannelClient returns the client sending the transactions signed by the private key
+func NewChannelClient(ctx context.Context, backend ChannelBackend, mpeAddress common.Address, privateKey *ecdsa.PrivateKey) (*ChannelClient, error) {
+	chainID, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get chain id: %v", err)
+	}
+	wallet, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	if err != nil {
+		return nil, err
+	}
+	mpe, err := NewMultiPartyEscrow(mpeAddress, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelClient{backend: backend, multiPartyEscrow: mpe, multiPartyEscrowAddress: mpeAddress, wallet: wallet}, nil
+}
+
+// Address returns the address of the channel sender
+func (client *ChannelClient) Address() common.Address {
+	return client.wallet.From
+}
+
+// EscrowBalance returns the tokens of the sender deposited to the MultiPartyEscrow
+func (client *ChannelClient) EscrowBalance(ctx context.Context) (*big.Int, error) {
+	return client.multiPartyEscrow.Balances(&bind.CallOpts{Context: ctx}, client.Address())
+}
+
+// OpenChannel opens the channel to the recipient of the group and returns its
+// id, the missing part of the amount is deposited to the MultiPartyEscrow first
+func (client *ChannelClient) OpenChannel(ctx context.Context, recipient common.Address, groupID [32]byte,
+	amount *big.Int, expiration *big.Int) (channelID *big.Int, err error) {
+	if err = client.deposit(ctx, amount); err != nil {
+		return nil, err
+	}
+	tx, err := client.multiPartyEscrow.OpenChannel(client.transactOpts(ctx), client.Address(), recipient, groupID, amount, expiration)
+	receipt, err := client.waitMined(ctx, "open channel", tx, err)
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range receipt.Logs {
+		if event, err := client.multiPartyEscrow.ParseChannelOpen(*log); err == nil {
+			return event.ChannelId, nil
+		}
+	}
+	return nil, fmt.Errorf("can't open channel: transaction %v has no ChannelOpen event", tx.Hash().Hex())
+}
+
+// AddFunds adds the amount to the channel and extends it to the expiration,
+// the nil or zero amount and the nil expiration are skipped
+func (client *ChannelClient) AddFunds(ctx context.Context, channelID *big.Int, amount *big.Int, expiration *big.Int) (err error) {
+	var tx *types.Transaction
+	switch {
+	case amount != nil && amount.Sign() > 0:
+		if err = client.deposit(ctx, amount); err != nil {
+			return err
+		}
+		if expiration != nil {
+			tx, err = client.multiPartyEscrow.ChannelExtendAndAddFunds(client.transactOpts(ctx), channelID, expiration, amount)
+		} else {
+			tx, err = client.multiPartyEscrow.ChannelAddFunds(client.transactOpts(ctx), channelID, amount)
+		}
+	case expiration != nil:
+		tx, err = client.multiPartyEscrow.ChannelExtend(client.transactOpts(ctx), channelID, expiration)
+	default:
+		return fmt.Errorf("neither amount nor expiration of the channel is set")
+	}
+	_, err = client.waitMined(ctx, "add funds to channel", tx, err)
+	return err
+}
+
+// deposit approves and deposits the tokens which the escrow balance of the
+// sender lacks to cover the amount
+func (client *ChannelClient) deposit(ctx context.Context, amount *big.Int) error {
+	balance, err := client.EscrowBalance(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get escrow balance: %v", err)
+	}
+	if balance.Cmp(amount) >= 0 {
+		return nil
+	}
+	missing := new(big.Int).Sub(amount, balance)
+	tokenAddress, err := client.multiPartyEscrow.Token(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("can't get token address: %v", err)
+	}
+	token, err := NewFetchToken(tokenAddress, client.backend)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("depositing tokens to MultiPartyEscrow", zap.Any("amount", missing), zap.Any("balance", balance))
+	tx, err := token.Approve(client.transactOpts(ctx), client.multiPartyEscrowAddress, missing)
+	if _, err = client.waitMined(ctx, "approve tokens", tx, err); err != nil {
+		return err
+	}
+	tx, err = client.multiPartyEscrow.Deposit(client.transactOpts(ctx), missing)
+	_, err = client.waitMined(ctx, "deposit tokens", tx, err)
+	return err
+}
+
+func (client *ChannelClient) transactOpts(ctx context.Context) *bind.TransactOpts {
+	return &bind.TransactOpts{From: client.wallet.From, Signer: client.wallet.Signer, Context: ctx}
+}
+
+// waitMined waits the transaction is included into the block and checks it succeeded
+func (client *ChannelClient) waitMined(ctx context.Context, operation string, tx *types.Transaction, err error) (*types.Receipt, error) {
+	if err != nil {
+		return nil, fmt.Errorf("can't %v: %v", operation, err)
+	}
+	receipt, err := bind.WaitMined(ctx, client.backend, tx)
+	if err != nil {
+		return nil, fmt.Errorf("can't %v: %v", operation, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("can't %v: transaction %v is reverted", operation, tx.Hash().Hex())
+	}
+	return receipt, nil
+}
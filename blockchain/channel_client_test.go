@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelClient(t *testing.T) {
+	chain, err := NewDevChain(DevChainSettings{
+		Host:      "127.0.0.1",
+		HTTPPort:  freePort(t),
+		WSPort:    freePort(t),
+		BlockTime: 100 * time.Millisecond,
+		Accounts:  2,
+		Tokens:    big.NewInt(1000),
+		Deposit:   big.NewInt(400),
+	})
+	require.Nil(t, err)
+	chain.Start()
+	defer chain.Close()
+
+	backend, err := ethclient.Dial(chain.HTTPEndpoint())
+	require.Nil(t, err)
+	defer backend.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sender, recipient := chain.Accounts[1], chain.Accounts[0]
+	client, err := NewChannelClient(ctx, backend, chain.MultiPartyEscrowAddress, sender.PrivateKey)
+	require.Nil(t, err)
+	assert.Equal(t, sender.Address(), client.Address())
+
+	// the escrow balance lacks 200 tokens, they're deposited before the channel is opened
+	groupID := [32]byte{1}
+	channelID, err := client.OpenChannel(ctx, recipient.Address(), groupID, big.NewInt(600), big.NewInt(1000))
+	require.Nil(t, err)
+	balance, err := client.EscrowBalance(ctx)
+	require.Nil(t, err)
+	assert.Zero(t, balance.Sign())
+
+	channel, err := chain.MultiPartyEscrow.Channels(nil, channelID)
+	require.Nil(t, err)
+	assert.Equal(t, sender.Address(), channel.Sender)
+	assert.Equal(t, recipient.Address(), channel.Recipient)
+	assert.Equal(t, groupID, channel.GroupId)
+	assert.Equal(t, big.NewInt(600), channel.Value)
+	assert.Equal(t, big.NewInt(1000), channel.Expiration)
+
+	require.Nil(t, client.AddFunds(ctx, channelID, big.NewInt(100), big.NewInt(2000)))
+	channel, err = chain.MultiPartyEscrow.Channels(nil, channelID)
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(700), channel.Value)
+	assert.Equal(t, big.NewInt(2000), channel.Expiration)
+
+	require.Nil(t, client.AddFunds(ctx, channelID, nil, big.NewInt(3000)))
+	channel, err = chain.MultiPartyEscrow.Channels(nil, channelID)
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(700), channel.Value)
+	assert.Equal(t, big.NewInt(3000), channel.Expiration)
+
+	assert.NotNil(t, client.AddFunds(ctx, channelID, nil, nil))
+}
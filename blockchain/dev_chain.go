@@ -0,0 +1,254 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	contracts "github.com/singnet/snet-ecosystem-contracts"
+	"go.uber.org/zap"
+
+	"github.com/singnet/snet-daemon/v6/utils"
+)
+
+// DevChainID is the chain id of the simulated backend
+const DevChainID = 1337
+
+// devEtherBalance is the ether balance of the dev accounts, 1000 ether
+var devEtherBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+
+// DevChainSettings configures the simulated chain of the development mode
+type DevChainSettings struct {
+	Host      string
+	HTTPPort  int
+	WSPort    int
+	BlockTime time.Duration
+	// Accounts is the number of the funded accounts, the first one is the service provider
+	Accounts int
+	// Tokens is the token balance of every account, in cogs
+	Tokens *big.Int
+	// Deposit is the part of the token balance deposited to the MultiPartyEscrow
+	Deposit *big.Int
+}
+
+// DevAccount is a funded account of the simulated chain
+type DevAccount struct {
+	PrivateKey *ecdsa.PrivateKey
+	Wallet     *bind.TransactOpts
+}
+
+func (account *DevAccount) Address() common.Address {
+	return account.Wallet.From
+}
+
+// PrivateKeyHex returns the private key without the 0x prefix, as it is written in the daemon config
+func (account *DevAccount) PrivateKeyHex() string {
+	return common.Bytes2Hex(crypto.FromECDSA(account.PrivateKey))
+}
+
+// DevChain is a simulated chain with the deployed Registry, MultiPartyEscrow and
+// token contracts, the chain is served by the JSON RPC endpoints like a real node
+type DevChain struct {
+	Backend                 *simulated.Backend
+	Deployer                *DevAccount
+	Accounts                []*DevAccount
+	TokenAddress            common.Address
+	Token                   *FetchToken
+	MultiPartyEscrowAddress common.Address
+	MultiPartyEscrow        *MultiPartyEscrow
+	RegistryAddress         common.Address
+	Registry                *Registry
+
+	settings DevChainSettings
+	mutex    sync.Mutex
+	stop     chan struct{}
+	wait     *sync.WaitGroup
+}
+
+// NewDevChain starts the simulated chain, deploys the contracts and funds the accounts
+func NewDevChain(settings DevChainSettings) (chain *DevChain, err error) {
+	chain = &DevChain{
+		settings: settings,
+		stop:     make(chan struct{}),
+		wait:     new(sync.WaitGroup),
+	}
+	if chain.Deployer, err = newDevAccount(); err != nil {
+		return nil, err
+	}
+	alloc := types.GenesisAlloc{chain.Deployer.Address(): {Balance: devEtherBalance}}
+	for range settings.Accounts {
+		account, err := newDevAccount()
+		if err != nil {
+			return nil, err
+		}
+		chain.Accounts = append(chain.Accounts, account)
+		alloc[account.Address()] = types.Account{Balance: devEtherBalance}
+	}
+
+	chain.Backend = simulated.NewBackend(alloc, func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+		nodeConf.HTTPHost = settings.Host
+		nodeConf.HTTPPort = settings.HTTPPort
+		nodeConf.HTTPModules = []string{"eth", "net", "web3"}
+		nodeConf.HTTPCors = []string{"*"}
+		nodeConf.WSHost = settings.Host
+		nodeConf.WSPort = settings.WSPort
+		nodeConf.WSModules = []string{"eth", "net", "web3"}
+		nodeConf.WSOrigins = []string{"*"}
+	})
+	if err = chain.deployContracts(); err != nil {
+		chain.Backend.Close()
+		return nil, err
+	}
+	if err = chain.fundAccounts(); err != nil {
+		chain.Backend.Close()
+		return nil, err
+	}
+	return chain, nil
+}
+
+func newDevAccount() (*DevAccount, error) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(DevChainID))
+	if err != nil {
+		return nil, err
+	}
+	return &DevAccount{PrivateKey: privateKey, Wallet: wallet}, nil
+}
+
+// HTTPEndpoint returns the JSON RPC endpoint of the chain
+func (chain *DevChain) HTTPEndpoint() string {
+	return fmt.Sprintf("http://%v:%v", chain.settings.Host, chain.settings.HTTPPort)
+}
+
+// WSEndpoint returns the websocket JSON RPC endpoint of the chain
+func (chain *DevChain) WSEndpoint() string {
+	return fmt.Sprintf("ws://%v:%v", chain.settings.Host, chain.settings.WSPort)
+}
+
+// deployContracts deploys the token, MultiPartyEscrow and Registry contracts. The bindings of
+// FetchToken have no constructor code, so the SingularityNetToken is deployed as the token,
+// it's used through the FetchToken bindings as both are ERC20 tokens with the mint method
+func (chain *DevChain) deployContracts() (err error) {
+	tokenABI, err := abi.JSON(bytes.NewReader(contracts.GetABIClean(contracts.SingularityNetToken)))
+	if err != nil {
+		return err
+	}
+	// the bytecode file ends with the line break which isn't removed by GetBytecodeClean
+	tokenBytecode := common.FromHex(string(bytes.TrimSpace(contracts.GetBytecodeClean(contracts.SingularityNetToken))))
+	var tx *types.Transaction
+	chain.TokenAddress, tx, _, err = bind.DeployContract(EstimateGas(chain.Deployer.Wallet), tokenABI, tokenBytecode,
+		chain.Backend.Client(), "SingularityNET Token", "AGIX")
+	if err = chain.mine("deploy token", tx, err); err != nil {
+		return err
+	}
+	if chain.Token, err = NewFetchToken(chain.TokenAddress, chain.Backend.Client()); err != nil {
+		return err
+	}
+	chain.MultiPartyEscrowAddress, tx, chain.MultiPartyEscrow, err = DeployMultiPartyEscrow(EstimateGas(chain.Deployer.Wallet), chain.Backend.Client(), chain.TokenAddress)
+	if err = chain.mine("deploy MultiPartyEscrow", tx, err); err != nil {
+		return err
+	}
+	chain.RegistryAddress, tx, chain.Registry, err = DeployRegistry(EstimateGas(chain.Deployer.Wallet), chain.Backend.Client())
+	return chain.mine("deploy Registry", tx, err)
+}
+
+// fundAccounts mints the tokens to the accounts and deposits them to the MultiPartyEscrow
+func (chain *DevChain) fundAccounts() error {
+	for _, account := range chain.Accounts {
+		tx, err := chain.Token.Mint(EstimateGas(chain.Deployer.Wallet), account.Address(), chain.settings.Tokens)
+		if err = chain.mine("mint tokens", tx, err); err != nil {
+			return err
+		}
+		if chain.settings.Deposit == nil || chain.settings.Deposit.Sign() <= 0 {
+			continue
+		}
+		tx, err = chain.Token.Approve(EstimateGas(account.Wallet), chain.MultiPartyEscrowAddress, chain.settings.Deposit)
+		if err = chain.mine("approve tokens", tx, err); err != nil {
+			return err
+		}
+		tx, err = chain.MultiPartyEscrow.Deposit(EstimateGas(account.Wallet), chain.settings.Deposit)
+		if err = chain.mine("deposit tokens", tx, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterOrganization creates the organization with the metadata URI, the owner is the first account
+func (chain *DevChain) RegisterOrganization(orgID string, metadataURI string) error {
+	tx, err := chain.Registry.CreateOrganization(EstimateGas(chain.Accounts[0].Wallet),
+		utils.StringToBytes32(orgID), []byte(metadataURI), []common.Address{chain.Accounts[0].Address()})
+	return chain.mine("create organization", tx, err)
+}
+
+// RegisterService registers the service of the organization with the metadata URI
+func (chain *DevChain) RegisterService(orgID string, serviceID string, metadataURI string) error {
+	tx, err := chain.Registry.CreateServiceRegistration(EstimateGas(chain.Accounts[0].Wallet),
+		utils.StringToBytes32(orgID), utils.StringToBytes32(serviceID), []byte(metadataURI))
+	return chain.mine("create service registration", tx, err)
+}
+
+// mine commits the block with the transaction and checks the transaction succeeded
+func (chain *DevChain) mine(operation string, tx *types.Transaction, err error) error {
+	if err != nil {
+		return fmt.Errorf("can't %v: %v", operation, err)
+	}
+	chain.mutex.Lock()
+	chain.Backend.Commit()
+	chain.mutex.Unlock()
+	receipt, err := chain.Backend.Client().TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return fmt.Errorf("can't %v: %v", operation, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("can't %v: transaction %v is reverted", operation, tx.Hash().Hex())
+	}
+	return nil
+}
+
+// Start produces the blocks every block time, the transactions sent to the
+// JSON RPC endpoints are included into the next block
+func (chain *DevChain) Start() {
+	chain.wait.Add(1)
+	go func() {
+		defer chain.wait.Done()
+		ticker := time.NewTicker(chain.settings.BlockTime)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-chain.stop:
+				return
+			case <-ticker.C:
+				chain.mutex.Lock()
+				chain.Backend.Commit()
+				chain.mutex.Unlock()
+			}
+		}
+	}()
+	zap.L().Info("dev chain is started", zap.String("endpoint", chain.HTTPEndpoint()),
+		zap.Duration("blockTime", chain.settings.BlockTime))
+}
+
+// Close stops the block production and the chain
+func (chain *DevChain) Close() {
+	close(chain.stop)
+	chain.wait.Wait()
+	if err := chain.Backend.Close(); err != nil {
+		zap.L().Warn("can't close dev chain", zap.Error(err))
+	}
+}
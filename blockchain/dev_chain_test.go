@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/singnet/snet-daemon/v6/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestDevChain(t *testing.T) {
+	chain, err := NewDevChain(DevChainSettings{
+		Host:      "127.0.0.1",
+		HTTPPort:  freePort(t),
+		WSPort:    freePort(t),
+		BlockTime: 100 * time.Millisecond,
+		Accounts:  2,
+		Tokens:    big.NewInt(1000),
+		Deposit:   big.NewInt(400),
+	})
+	require.Nil(t, err)
+	chain.Start()
+	defer chain.Close()
+
+	require.Nil(t, chain.RegisterOrganization("test_org", "ipfs://org"))
+	require.Nil(t, chain.RegisterService("test_org", "test_service", "ipfs://service"))
+
+	client, err := ethclient.Dial(chain.HTTPEndpoint())
+	require.Nil(t, err)
+	defer client.Close()
+	chainID, err := client.ChainID(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(DevChainID), chainID)
+
+	registry, err := NewRegistryCaller(chain.RegistryAddress, client)
+	require.Nil(t, err)
+	service, err := registry.GetServiceRegistrationById(nil, utils.StringToBytes32("test_org"), utils.StringToBytes32("test_service"))
+	require.Nil(t, err)
+	assert.True(t, service.Found)
+	assert.Equal(t, "ipfs://service", string(service.MetadataURI))
+
+	mpe, err := NewMultiPartyEscrowCaller(chain.MultiPartyEscrowAddress, client)
+	require.Nil(t, err)
+	token, err := NewFetchTokenCaller(chain.TokenAddress, client)
+	require.Nil(t, err)
+	for _, account := range chain.Accounts {
+		balance, err := mpe.Balances(nil, account.Address())
+		require.Nil(t, err)
+		assert.Equal(t, big.NewInt(400), balance)
+		balance, err = token.BalanceOf(nil, account.Address())
+		require.Nil(t, err)
+		assert.Equal(t, big.NewInt(600), balance)
+	}
+
+	// the blocks are produced without the transactions
+	block, err := client.BlockNumber(context.Background())
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		latest, err := client.BlockNumber(context.Background())
+		return err == nil && latest > block
+	}, 5*time.Second, 50*time.Millisecond)
+}
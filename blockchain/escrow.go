@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 
 	"math/big"
@@ -20,10 +21,20 @@ type MultiPartyEscrowChannel struct {
 
 var zeroAddress = common.Address{}
 
+// MultiPartyEscrowChannel reads the channel at the confirmed block, see ConfirmedBlock
 func (processor *processor) MultiPartyEscrowChannel(channelID *big.Int) (channel *MultiPartyEscrowChannel, ok bool, err error) {
+	confirmedBlock, err := processor.ConfirmedBlock()
+	if err != nil {
+		return nil, false, err
+	}
+	return processor.MultiPartyEscrowChannelAt(channelID, confirmedBlock)
+}
+
+// MultiPartyEscrowChannelAt reads the channel at the given block, nil block means the latest one
+func (processor *processor) MultiPartyEscrowChannelAt(channelID *big.Int, block *big.Int) (channel *MultiPartyEscrowChannel, ok bool, err error) {
 	channelIdField := zap.Any("channelID", channelID)
 
-	ch, err := processor.multiPartyEscrow.Channels(nil, channelID)
+	ch, err := processor.multiPartyEscrow.Channels(&bind.CallOpts{BlockNumber: block}, channelID)
 	if err != nil {
 		zap.L().Warn("Error while looking up for channel id in blockchain", zap.Error(err), channelIdField)
 		return nil, false, err
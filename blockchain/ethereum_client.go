@@ -3,6 +3,8 @@ package blockchain
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/singnet/snet-daemon/v6/utils"
@@ -90,6 +92,39 @@ func CreateWSEthereumClient() (*EthereumClient, error) {
 	return ethereumWsClient, nil
 }
 
+// localNetwork is the network of the development chains, their chain ids
+// (ganache 1337, anvil 31337) differ from its network_id
+const localNetwork = "local"
+
+// CheckChainID compares the network_id of the selected network with the chain id
+// returned by the ethereum_json_rpc_http_endpoint, the mismatch of the local
+// network is only logged
+func CheckChainID(ctx context.Context) error {
+	ethHttpClient, err := CreateHTTPEthereumClient()
+	if err != nil {
+		return err
+	}
+	defer ethHttpClient.Close()
+	chainID, err := ethHttpClient.EthClient.ChainID(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting chain id of the blockchain endpoint")
+	}
+	return checkChainID(chainID, config.GetString(config.BlockChainNetworkSelected), config.GetNetworkId())
+}
+
+func checkChainID(chainID *big.Int, network, networkID string) error {
+	if chainID.String() == networkID {
+		return nil
+	}
+	err := fmt.Errorf("chain id of the blockchain endpoint %v doesn't match network_id %v of the network %v",
+		chainID, networkID, network)
+	if network == localNetwork {
+		zap.L().Warn("chain id of the local network isn't checked", zap.Error(err))
+		return nil
+	}
+	return err
+}
+
 func (ethereumClient *EthereumClient) Close() {
 	if ethereumClient != nil {
 		ethereumClient.EthClient.Close()
@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"encoding/base64"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,3 +49,12 @@ func TestGetAuthOption(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckChainID(t *testing.T) {
+	assert.Nil(t, checkChainID(big.NewInt(1), "main", "1"))
+	assert.ErrorContains(t, checkChainID(big.NewInt(11155111), "main", "1"),
+		"chain id of the blockchain endpoint 11155111 doesn't match network_id 1 of the network main")
+	// ganache and anvil of the local network
+	assert.Nil(t, checkChainID(big.NewInt(1337), "local", "42"))
+	assert.Nil(t, checkChainID(big.NewInt(31337), "local", "42"))
+}
@@ -53,6 +53,14 @@ func (m *MockProcessor) CurrentBlock() (*big.Int, error) {
 	return big.NewInt(MockedCurrentBlock), nil
 }
 
+func (m *MockProcessor) ConfirmedBlock() (*big.Int, error) {
+	return nil, nil
+}
+
+func (m *MockProcessor) BlockHash(number *big.Int) (common.Hash, error) {
+	return common.Hash{}, nil
+}
+
 func (m *MockProcessor) CompareWithLatestBlockNumber(blockNumberPassed *big.Int, allowedBlockChainDifference uint64) error {
 	latestBlockNumber, err := m.CurrentBlock()
 	if err != nil {
@@ -97,3 +105,7 @@ func (m *MockProcessor) MultiPartyEscrowChannel(channelID *big.Int) (channel *Mu
 
 	return channel, true, nil
 }
+
+func (m *MockProcessor) MultiPartyEscrowChannelAt(channelID *big.Int, block *big.Int) (channel *MultiPartyEscrowChannel, ok bool, err error) {
+	return m.MultiPartyEscrowChannel(channelID)
+}
@@ -185,14 +185,41 @@ func GetOrganizationMetaDataFromIPFS(hash string) (*OrganizationMetaData, error)
 
 func getMetaDataURI() []byte {
 	// Blockchain call to get the hash of the metadata for the given Organization
-	reg := getRegistryCaller()
-	orgId := utils.StringToBytes32(config.GetString(config.OrganizationId))
+	uri, err := getOrganizationMetaDataURI(getRegistryCaller())
+	if err != nil {
+		zap.L().Panic("Error Retrieving contract details for the Given Organization, recheck blockchain provider endpoint", zap.String("OrganizationId", config.GetString(config.OrganizationId)), zap.Error(err))
+	}
+	return uri
+}
 
+func getOrganizationMetaDataURI(reg *RegistryCaller) ([]byte, error) {
+	orgId := utils.StringToBytes32(config.GetString(config.OrganizationId))
 	organizationRegistered, err := reg.GetOrganizationById(nil, orgId)
-	if err != nil || !organizationRegistered.Found {
-		zap.L().Panic("Error Retrieving contract details for the Given Organization, recheck blockchain provider endpoint", zap.String("OrganizationId", config.GetString(config.OrganizationId)), zap.Error(err))
+	if err != nil {
+		return nil, err
+	}
+	if !organizationRegistered.Found {
+		return nil, fmt.Errorf("organization %v isn't found in the Registry", config.GetString(config.OrganizationId))
+	}
+	return organizationRegistered.OrgMetadataURI[:], nil
+}
+
+// LoadOrganizationMetaData reads the metadata of the organization registered in
+// the Registry, unlike GetOrganizationMetaData it returns the errors instead of
+// panicking. The empty metadata is returned when the blockchain is disabled.
+func LoadOrganizationMetaData() (*OrganizationMetaData, error) {
+	if !config.GetBool(config.BlockchainEnabledKey) {
+		return &OrganizationMetaData{daemonGroup: &Group{}}, nil
+	}
+	reg, err := newRegistryCaller()
+	if err != nil {
+		return nil, err
+	}
+	ipfsHash, err := getOrganizationMetaDataURI(reg)
+	if err != nil {
+		return nil, err
 	}
-	return organizationRegistered.OrgMetadataURI[:]
+	return GetOrganizationMetaDataFromIPFS(string(ipfsHash))
 }
 
 // GetGroupIdString Get the Group ID the Daemon needs to associate itself to, requests belonging to a different group if will be rejected
@@ -15,6 +15,7 @@ import (
 	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
 
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
 	pproto "github.com/emicklei/proto"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -22,6 +23,8 @@ import (
 	"github.com/singnet/snet-daemon/v6/config"
 	"github.com/singnet/snet-daemon/v6/ipfsutils"
 	"go.uber.org/zap"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 /*
@@ -288,17 +291,25 @@ func ReadServiceMetaDataFromLocalFile(filename string) (*ServiceMetadata, error)
 }
 
 func getRegistryCaller() (reg *RegistryCaller) {
+	reg, err := newRegistryCaller()
+	if err != nil {
+		zap.L().Panic(err.Error())
+	}
+	return reg
+}
+
+func newRegistryCaller() (reg *RegistryCaller, err error) {
 	ethHttpClient, err := CreateHTTPEthereumClient()
 	if err != nil {
-		zap.L().Panic("Unable to get Blockchain client ", zap.Error(err))
+		return nil, fmt.Errorf("unable to get Blockchain client: %v", err)
 	}
 	defer ethHttpClient.Close()
 	registryContractAddress := getRegistryAddressKey()
 	reg, err = NewRegistryCaller(registryContractAddress, ethHttpClient.EthClient)
 	if err != nil {
-		zap.L().Panic("Error instantiating Registry contract for the given Contract Address", zap.Error(err), zap.Any("registryContractAddress", registryContractAddress))
+		return nil, fmt.Errorf("error instantiating Registry contract for the given Contract Address %v: %v", registryContractAddress.Hex(), err)
 	}
-	return reg
+	return reg, nil
 }
 
 func GetRegistryFilterer(ethWsClient *ethclient.Client) *RegistryFilterer {
@@ -311,8 +322,10 @@ func GetRegistryFilterer(ethWsClient *ethclient.Client) *RegistryFilterer {
 }
 
 func getServiceMetaDataURIFromRegistry() ([]byte, error) {
-	reg := getRegistryCaller()
+	return getServiceMetaDataURI(getRegistryCaller())
+}
 
+func getServiceMetaDataURI(reg *RegistryCaller) ([]byte, error) {
 	orgId := utils.StringToBytes32(config.GetString(config.OrganizationId))
 	serviceId := utils.StringToBytes32(config.GetString(config.ServiceId))
 
@@ -324,6 +337,24 @@ func getServiceMetaDataURIFromRegistry() ([]byte, error) {
 	return serviceRegistration.MetadataURI[:], nil
 }
 
+// LoadServiceMetaData reads the metadata of the service registered in the
+// Registry, unlike ServiceMetaData it returns the errors instead of exiting.
+// The grpc service metadata is returned when the blockchain is disabled.
+func LoadServiceMetaData() (*ServiceMetadata, error) {
+	if !config.GetBool(config.BlockchainEnabledKey) {
+		return &ServiceMetadata{Encoding: "proto", ServiceType: "grpc"}, nil
+	}
+	reg, err := newRegistryCaller()
+	if err != nil {
+		return nil, err
+	}
+	ipfsHash, err := getServiceMetaDataURI(reg)
+	if err != nil {
+		return nil, err
+	}
+	return GetServiceMetaDataFromIPFS(string(ipfsHash))
+}
+
 func GetServiceMetaDataFromIPFS(hash string) (*ServiceMetadata, error) {
 	jsondata, err := ipfsutils.ReadFile(hash)
 	if err != nil {
@@ -405,6 +436,10 @@ func (metaData *ServiceMetadata) setDefaultPricing() (err error) {
 }
 
 func (metaData *ServiceMetadata) setMultiPartyEscrowAddress() {
+	if metaData.MpeAddress == "" {
+		// the MultiPartyEscrow of the custom network is used by the metadata without the address
+		metaData.MpeAddress = config.GetMpeAddress()
+	}
 	metaData.MpeAddress = utils.ToChecksumAddressStr(metaData.MpeAddress)
 	metaData.multiPartyEscrowAddress = common.HexToAddress(metaData.MpeAddress)
 }
@@ -484,10 +519,28 @@ func (metaData *ServiceMetadata) IsModelTraining(methodFullName string) (useMode
 	return slices.Contains(metaData.TrainingMethods, methodFullName)
 }
 
-// getProtoDescriptors converts text of proto files to bufbuild linker
+// GetProtoDescriptors returns the compiled proto files of the service, they're
+// compiled on the first call when the daemon config doesn't need them
+func (metaData *ServiceMetadata) GetProtoDescriptors() (linker.Files, error) {
+	if metaData.ProtoDescriptors == nil {
+		descriptors, err := getProtoDescriptors(metaData.ProtoFiles)
+		if err != nil {
+			return nil, err
+		}
+		metaData.ProtoDescriptors = descriptors
+	}
+	return metaData.ProtoDescriptors, nil
+}
+
+// getProtoDescriptors converts text of proto files to bufbuild linker, the
+// google/api protos (e.g. google/api/annotations.proto) and buf/validate/validate.proto
+// can be imported without adding them to the service api source
 func getProtoDescriptors(protoFiles map[string]string) (linker.Files, error) {
 	accessor := protocompile.SourceAccessorFromMap(protoFiles)
-	r := protocompile.WithStandardImports(&protocompile.SourceResolver{Accessor: accessor})
+	r := protocompile.WithStandardImports(protocompile.CompositeResolver{
+		&protocompile.SourceResolver{Accessor: accessor},
+		protocompile.ResolverFunc(resolveRegisteredProto),
+	})
 	compiler := protocompile.Compiler{
 		Resolver:       r,
 		SourceInfoMode: protocompile.SourceInfoStandard,
@@ -500,6 +553,34 @@ func getProtoDescriptors(protoFiles map[string]string) (linker.Files, error) {
 	return fds, nil
 }
 
+// resolveRegisteredProto returns the descriptors of google/api protos registered
+// by the genproto package and of the buf/validate protos of protovalidate
+func resolveRegisteredProto(path string) (protocompile.SearchResult, error) {
+	if !strings.HasPrefix(path, "google/api/") && !strings.HasPrefix(path, "buf/validate/") {
+		return protocompile.SearchResult{}, protoregistry.NotFound
+	}
+	file, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	if err != nil {
+		return protocompile.SearchResult{}, err
+	}
+	return protocompile.SearchResult{Desc: file}, nil
+}
+
+func requestValidationEnabled() bool {
+	settings, err := config.GetRequestValidation()
+	return err == nil && settings.Enabled
+}
+
+// retriesEnabled returns true when the unary calls can be repeated by the retry
+// policies, the hedging or the retries of the idempotent methods
+func retriesEnabled() bool {
+	if policies, err := config.GetServiceRetryPolicies(); err == nil && len(policies) > 0 {
+		return true
+	}
+	settings, err := config.GetServiceLoadBalancing()
+	return err == nil && settings.Retries > 0
+}
+
 func (metaData *ServiceMetadata) setServiceProto() (err error) {
 	metaData.DynamicPriceMethodMapping = make(map[string]string, 0)
 	metaData.TrainingMethods = make([]string, 0)
@@ -529,11 +610,20 @@ func (metaData *ServiceMetadata) setServiceProto() (err error) {
 		if config.GetBool(config.ModelTrainingEnabled) {
 			return errors.New("Training is not supported for HTTP services")
 		}
+	}
 
+	// the descriptors are used to convert the JSON of the HTTP service and of the
+	// REST ingress, to validate the requests, by the gRPC reflection and to find
+	// the unary methods which can be retried
+	if metaData.ServiceType == "http" || config.GetBool(config.RESTIngressEnabled) ||
+		config.GetBool(config.GrpcReflectionEnabled) || requestValidationEnabled() || retriesEnabled() {
 		metaData.ProtoDescriptors, err = getProtoDescriptors(metaData.ProtoFiles)
-		if err != nil {
+		if err != nil && metaData.ServiceType == "http" {
 			return err
 		}
+		if err != nil {
+			zap.L().Warn("can't compile proto files of the service, REST ingress, validation, reflection and retries don't know its methods", zap.Error(err))
+		}
 	}
 
 	for _, file := range metaData.ProtoFiles {
@@ -1,23 +1,31 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	contracts "github.com/singnet/snet-ecosystem-contracts"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
 type NetworkSelected struct {
 	NetworkName                 string
-	EthereumJSONRPCHTTPEndpoint string
-	EthereumJSONRPCWSEndpoint   string
-	NetworkId                   string
-	RegistryAddressKey          string
-	TokenAddress                string // now only for free calls
+	EthereumJSONRPCHTTPEndpoint string        `mapstructure:"ethereum_json_rpc_http_endpoint"`
+	EthereumJSONRPCWSEndpoint   string        `mapstructure:"ethereum_json_rpc_ws_endpoint"`
+	NetworkId                   string        `mapstructure:"network_id"` // chain id of the network
+	RegistryAddressKey          string        `mapstructure:"registry_address_key"`
+	MpeAddress                  string        `mapstructure:"mpe_address"`
+	TokenAddress                string        `mapstructure:"token_address"` // now only for free calls
+	ConfirmationDepth           uint64        `mapstructure:"confirmation_depth"`
+	BlockTime                   time.Duration `mapstructure:"block_time"`
 }
 
 const (
@@ -26,31 +34,90 @@ const (
 	EthereumJsonRpcWSEndpointKey   = "ethereum_json_rpc_ws_endpoint"
 	NetworkId                      = "network_id"
 	RegistryAddressKey             = "registry_address_key"
+
+	// ReferenceBlockTime is the block time of the Ethereum mainnet, the block
+	// counts of the organization metadata and of the daemon are given in its blocks
+	ReferenceBlockTime = 12 * time.Second
+
+	// FullNodeStateBlocks is the number of the latest blocks whose state is kept
+	// by the full nodes, the older blocks are read by the archive nodes only
+	FullNodeStateBlocks = 128
 )
 
 var networkSelected = &NetworkSelected{}
 var networkIdNameMapping string
 
+// readBlockchainNetworks reads the networks of the blockchain network config
+// and merges the networks defined by blockchain_networks of the daemon config into them
+func readBlockchainNetworks(data []byte) (*viper.Viper, error) {
+	networks := viper.New()
+	networks.SetConfigType("json")
+	if err := networks.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := networks.MergeConfigMap(vip.GetStringMap(BlockchainNetworksKey)); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
 func determineNetworkSelected(data []byte) (err error) {
-	dynamicBinding := map[string]any{}
-	networkName := GetString(BlockChainNetworkSelected)
-	if err = json.Unmarshal(data, &dynamicBinding); err != nil {
+	networks, err := readBlockchainNetworks(data)
+	if err != nil {
 		return err
 	}
 	//Get the Network Name selected in config ( snetd.config.json) , Based on this retrieve the Registry address ,
 	//Ethereum End point and Network ID mapped to
-	networkSelected.NetworkName = networkName
-	networkSelected.RegistryAddressKey = getDetailsFromJsonOrConfig(dynamicBinding[networkName].(map[string]any)[RegistryAddressKey], RegistryAddressKey)
-	networkSelected.EthereumJSONRPCHTTPEndpoint = getDetailsFromJsonOrConfig(dynamicBinding[networkName].(map[string]any)[EthereumJsonRpcHTTPEndpointKey], EthereumJsonRpcHTTPEndpointKey)
-	networkSelected.EthereumJSONRPCWSEndpoint = getDetailsFromJsonOrConfig(dynamicBinding[networkName].(map[string]any)[EthereumJsonRpcWSEndpointKey], EthereumJsonRpcWSEndpointKey)
-	networkSelected.NetworkId = fmt.Sprintf("%v", dynamicBinding[networkName].(map[string]any)[NetworkId])
-
-	fetchTokenData := map[string]map[string]any{}
-	if err = json.Unmarshal(contracts.GetNetworksClean(contracts.FetchToken), &fetchTokenData); err != nil {
-		return err
+	networkName := GetString(BlockChainNetworkSelected)
+	if !networks.IsSet(networkName) {
+		return fmt.Errorf("the network %q is not defined, add its definition to %v", networkName, BlockchainNetworksKey)
+	}
+	selected := &NetworkSelected{BlockTime: ReferenceBlockTime}
+	if err = networks.UnmarshalKey(networkName, selected); err != nil {
+		return fmt.Errorf("invalid definition of the network %q: %v", networkName, err)
+	}
+	selected.NetworkName = networkName
+	selected.RegistryAddressKey = getDetailsFromJsonOrConfig(selected.RegistryAddressKey, RegistryAddressKey)
+	selected.EthereumJSONRPCHTTPEndpoint = getDetailsFromJsonOrConfig(selected.EthereumJSONRPCHTTPEndpoint, EthereumJsonRpcHTTPEndpointKey)
+	selected.EthereumJSONRPCWSEndpoint = getDetailsFromJsonOrConfig(selected.EthereumJSONRPCWSEndpoint, EthereumJsonRpcWSEndpointKey)
+	if err = selected.validate(); err != nil {
+		return fmt.Errorf("invalid definition of the network %q: %v", networkName, err)
 	}
-	networkSelected.TokenAddress = fetchTokenData[networkSelected.NetworkId]["address"].(string)
 
+	if selected.TokenAddress == "" {
+		fetchTokenData := map[string]map[string]any{}
+		if err = json.Unmarshal(contracts.GetNetworksClean(contracts.FetchToken), &fetchTokenData); err != nil {
+			return err
+		}
+		if address, ok := fetchTokenData[selected.NetworkId]["address"].(string); ok {
+			selected.TokenAddress = address
+		}
+	}
+	*networkSelected = *selected
+
+	return nil
+}
+
+func (network *NetworkSelected) validate() error {
+	if chainID, ok := new(big.Int).SetString(network.NetworkId, 10); !ok || chainID.Sign() <= 0 {
+		return fmt.Errorf("%v %q is not a valid chain id", NetworkId, network.NetworkId)
+	}
+	for key, address := range map[string]string{
+		RegistryAddressKey: network.RegistryAddressKey,
+		"mpe_address":      network.MpeAddress,
+		"token_address":    network.TokenAddress,
+	} {
+		if address != "" && !common.IsHexAddress(address) {
+			return fmt.Errorf("%v %q is not a valid hex address", key, address)
+		}
+	}
+	if network.BlockTime <= 0 {
+		return fmt.Errorf("block_time must be positive")
+	}
+	if network.ConfirmationDepth > FullNodeStateBlocks {
+		zap.L().Warn(fmt.Sprintf("confirmation_depth %v of the network %q is larger than %v blocks of the state kept by the full nodes,"+
+			" the channels can be read by the archive node only", network.ConfirmationDepth, network.NetworkName, FullNodeStateBlocks))
+	}
 	return nil
 }
 
@@ -75,6 +142,40 @@ func GetTokenAddress() string {
 	return networkSelected.TokenAddress
 }
 
+// GetMpeAddress returns the MultiPartyEscrow address of the network, empty when
+// the address of the service metadata is used
+func GetMpeAddress() string {
+	return networkSelected.MpeAddress
+}
+
+// GetConfirmationDepth returns the number of the blocks after which the block of the network is final
+func GetConfirmationDepth() uint64 {
+	return networkSelected.ConfirmationDepth
+}
+
+// GetBlockTime returns the average time between the blocks of the network
+func GetBlockTime() time.Duration {
+	if networkSelected.BlockTime <= 0 {
+		return ReferenceBlockTime
+	}
+	return networkSelected.BlockTime
+}
+
+// BlocksIn returns the number of the network blocks produced during the duration, at least one
+func BlocksIn(duration time.Duration) uint64 {
+	return max(uint64(duration/GetBlockTime()), 1)
+}
+
+// ToNetworkBlocks converts the number of the blocks of ReferenceBlockTime to the
+// number of the network blocks produced during the same time
+func ToNetworkBlocks(blocks *big.Int) *big.Int {
+	if blocks == nil || GetBlockTime() == ReferenceBlockTime {
+		return blocks
+	}
+	converted := new(big.Int).Mul(blocks, big.NewInt(int64(ReferenceBlockTime)))
+	return converted.Div(converted, big.NewInt(int64(GetBlockTime())))
+}
+
 // GetBlockChainHTTPEndPoint - Get the blockchain endpoint associated with the Network selected
 func GetBlockChainHTTPEndPoint() string {
 	return networkSelected.EthereumJSONRPCHTTPEndpoint
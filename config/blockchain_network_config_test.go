@@ -2,10 +2,13 @@ package config
 
 import (
 	"encoding/json"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetNetworkId(t *testing.T) {
@@ -160,3 +163,63 @@ func Test_setBlockChainNetworkDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestCustomBlockchainNetwork(t *testing.T) {
+	defer func() {
+		Vip().Set(BlockchainNetworksKey, map[string]any{})
+		Vip().Set(BlockChainNetworkSelected, "sepolia")
+		assert.Nil(t, determineNetworkSelected([]byte(defaultBlockChainNetworkConfig)))
+	}()
+	Vip().Set(BlockchainNetworksKey, map[string]any{
+		"base": map[string]any{
+			"network_id":                      8453,
+			"ethereum_json_rpc_http_endpoint": "https://mainnet.base.org",
+			"registry_address_key":            "0x4e74fefa82e83e0964f0d9f53c68e03f7298a8b2",
+			"mpe_address":                     "0x5e592F9b1d303183d963635f895f0f0C48284f4e",
+			"token_address":                   "0x06A1D29e9FfA2415434A7A571235744F8DA2a514",
+			"confirmation_depth":              10,
+			"block_time":                      "2s",
+		},
+		// the built-in network is overridden field by field
+		"sepolia": map[string]any{
+			"confirmation_depth": 3,
+		},
+	})
+
+	Vip().Set(BlockChainNetworkSelected, "base")
+	require.Nil(t, determineNetworkSelected([]byte(defaultBlockChainNetworkConfig)))
+	assert.Equal(t, "8453", GetNetworkId())
+	assert.Equal(t, "https://mainnet.base.org", GetBlockChainHTTPEndPoint())
+	assert.Equal(t, "0x4e74fefa82e83e0964f0d9f53c68e03f7298a8b2", GetRegistryAddress())
+	assert.Equal(t, "0x5e592F9b1d303183d963635f895f0f0C48284f4e", GetMpeAddress())
+	assert.Equal(t, "0x06A1D29e9FfA2415434A7A571235744F8DA2a514", GetTokenAddress())
+	assert.Equal(t, uint64(10), GetConfirmationDepth())
+	assert.Equal(t, 2*time.Second, GetBlockTime())
+	assert.Equal(t, uint64(30), BlocksIn(time.Minute))
+	assert.Equal(t, big.NewInt(241920), ToNetworkBlocks(big.NewInt(40320)))
+
+	Vip().Set(BlockChainNetworkSelected, "sepolia")
+	require.Nil(t, determineNetworkSelected([]byte(defaultBlockChainNetworkConfig)))
+	assert.Equal(t, "11155111", GetNetworkId())
+	assert.Contains(t, GetBlockChainHTTPEndPoint(), "sepolia")
+	assert.Equal(t, uint64(3), GetConfirmationDepth())
+	assert.Equal(t, ReferenceBlockTime, GetBlockTime())
+	assert.Equal(t, big.NewInt(40320), ToNetworkBlocks(big.NewInt(40320)))
+
+	Vip().Set(BlockChainNetworkSelected, "unknown")
+	assert.ErrorContains(t, determineNetworkSelected([]byte(defaultBlockChainNetworkConfig)), "is not defined")
+
+	Vip().Set(BlockchainNetworksKey, map[string]any{
+		"broken": map[string]any{"network_id": "base", "block_time": "2s"},
+	})
+	Vip().Set(BlockChainNetworkSelected, "broken")
+	assert.ErrorContains(t, determineNetworkSelected([]byte(defaultBlockChainNetworkConfig)), "not a valid chain id")
+
+	// the depth beyond the state of the full nodes is only warned about
+	Vip().Set(BlockchainNetworksKey, map[string]any{
+		"archive": map[string]any{"network_id": "1", "confirmation_depth": 1000},
+	})
+	Vip().Set(BlockChainNetworkSelected, "archive")
+	require.Nil(t, determineNetworkSelected([]byte(defaultBlockChainNetworkConfig)))
+	assert.Equal(t, uint64(1000), GetConfirmationDepth())
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/url"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -26,8 +28,10 @@ const (
 	AuthenticationAddresses   = "authentication_addresses"
 	AutoSSLDomainKey          = "auto_ssl_domain"
 	AutoSSLCacheDirKey        = "auto_ssl_cache_dir"
+	AutoSSLCacheStorageKey    = "auto_ssl_cache_storage"
 	BlockchainEnabledKey      = "blockchain_enabled"
 	BlockChainNetworkSelected = "blockchain_network_selected"
+	BlockchainNetworksKey     = "blockchain_networks"
 	BurstSize                 = "burst_size"
 	ConfigPathKey             = "config_path"
 	DaemonGroupName           = "daemon_group_name"
@@ -45,20 +49,37 @@ const (
 	// ModelMaintenanceEndPoint This is for grpc server end point for Model Maintenance like Create, update, delete, status check
 	ModelMaintenanceEndPoint       = "model_maintenance_endpoint"
 	ModelTrainingEnabled           = "model_training_enabled"
+	ModelTrainingJobsKey           = "model_training_jobs"
+	ModelTrainingBillingKey        = "model_training_billing"
+	ModelTrainingDatasetsKey       = "model_training_datasets"
 	OrganizationId                 = "organization_id"
 	ServiceId                      = "service_id"
 	PassthroughEnabledKey          = "passthrough_enabled"
 	ServiceEndpointKey             = "service_endpoint"
+	ServiceLoadBalancingKey        = "service_load_balancing"
+	ServiceCircuitBreakerKey       = "service_circuit_breaker"
+	ServiceConcurrencyLimitKey     = "service_concurrency_limit"
+	ServiceRetryPoliciesKey        = "service_retry_policies"
+	ProcessPoolKey                 = "process_pool"
+	RequestValidationKey           = "request_validation"
+	ResponseCacheKey               = "response_cache"
 	ServiceCredentialsKey          = "service_credentials"
+	SecretsKey                     = "secrets"
+	HTTPRPCMappingKey              = "http_rpc_mapping"
 	RateLimitPerMinute             = "rate_limit_per_minute"
 	SSLCertPathKey                 = "ssl_cert"
 	SSLKeyPathKey                  = "ssl_key"
+	SSLClientAuthKey               = "ssl_client_auth"
+	SSLCertificatesKey             = "ssl_certificates"
+	SSLCertReloadKey               = "ssl_cert_reload"
 	PaymentChannelCertPath         = "payment_channel_cert_path"
 	PaymentChannelCaPath           = "payment_channel_ca_path"
 	PaymentChannelKeyPath          = "payment_channel_key_path"
 	PaymentChannelStorageTypeKey   = "payment_channel_storage_type"
 	PaymentChannelStorageClientKey = "payment_channel_storage_client"
 	PaymentChannelStorageServerKey = "payment_channel_storage_server"
+	PaymentStorageMaintenanceKey   = "payment_channel_storage_maintenance"
+	PaymentChannelConfirmationKey  = "payment_channel_confirmation"
 	BlockchainProviderApiKey       = "blockchain_provider_api_key"
 	FreeCallsPerAddress            = "free_calls_per_address"
 	TrustedFreeCallSigners         = "trusted_free_call_signers"
@@ -74,11 +95,19 @@ const (
 	TokenExpiryInMinutes        = "token_expiry_in_minutes"
 	TokenSecretKey              = "token_secret_key"
 	Experimental                = "experimental"
+	// Usage reporting
+	UsageReportingEnabled = "usage_reporting_enabled"
+	UsageReportingBucket  = "usage_reporting_bucket"
+	// REST ingress
+	RESTIngressEnabled = "rest_ingress_enabled"
+	// gRPC reflection
+	GrpcReflectionEnabled = "grpc_reflection_enabled"
 	//This defaultConfigJson will eventually be replaced by DefaultDaemonConfigurationSchema
 	defaultConfigJson string = `
 {
 	"blockchain_enabled": true,
 	"blockchain_network_selected": "sepolia",
+	"blockchain_networks": {},
 	"daemon_endpoint": "127.0.0.1:8080",
 	"daemon_group_name":"default_group",
 	"payment_channel_storage_type": "etcd",
@@ -93,12 +122,31 @@ const (
 	"metering_enabled": false,
 	"ssl_cert": "",
 	"ssl_key": "",
+	"ssl_certificates": [],
+	"ssl_cert_reload": {
+		"watch": true,
+		"poll_interval": "30s",
+		"expiry_warning": "720h"
+	},
+	"secrets": {
+		"reload_interval": "0s",
+		"exec_timeout": "10s"
+	},
+	"ssl_client_auth": {
+		"enabled": false,
+		"mode": "require",
+		"ca": "",
+		"crl": "",
+		"crl_reload_interval": "1m",
+		"trusted_identities": []
+	},
 	"max_message_size_in_mb" : 4,
 	"daemon_type": "grpc",
     "enable_dynamic_pricing":false,
 	"allowed_user_flag" :false,
 	"auto_ssl_domain": "",
 	"auto_ssl_cache_dir": ".certs",
+	"auto_ssl_cache_storage": "dir",
 	"private_key_for_free_calls": "",
 	"min_balance_for_free_call" : "10",
 	"trusted_free_call_signers": ["0x3Bb9b2499c283cec176e7C707Ecb495B7a961ebf", "0x7DF35C98f41F3Af0df1dc4c7F7D4C19a71Dd059F"],
@@ -137,14 +185,89 @@ const (
 		"data_dir": "storage-data-dir-1.etcd",
 		"log_level": "info",
 		"log_outputs": ["./etcd-server.log"],
+		"cluster_state": "new",
+		"restore_from": "",
 		"enabled": false
 	},
+	"payment_channel_storage_maintenance": {
+		"snapshot_interval": "0s",
+		"snapshot_dir": "etcd-snapshots",
+		"snapshot_retention": 7,
+		"compaction_interval": "0s",
+		"compaction_retention": 10000,
+		"defrag_interval": "0s"
+	},
+	"payment_channel_confirmation": {
+		"optimistic": false,
+		"unconfirmed_limit": "0",
+		"reorg_check_interval": "1m"
+	},
 	"alerts_email": "", 
 	"service_heartbeat_type": "",
 	"heartbeat_endpoint": "",
     "token_expiry_in_minutes": 1440,
     "token_secret_key": "test-secret-key-at-least-32-bytes-long",
-    "model_training_enabled": false
+    "model_training_enabled": false,
+	"model_training_jobs": {
+		"workers": 4,
+		"poll_interval": "3s",
+		"max_poll_interval": "5m",
+		"status_timeout": "20s",
+		"job_timeout": "168h",
+		"history_size": 100
+	},
+	"model_training_billing": {
+		"errored_charge_ratio": 1
+	},
+	"model_training_datasets": {
+		"storage": "",
+		"dir": "data/datasets",
+		"upload_ttl": "24h"
+	},
+	"usage_reporting_enabled": false,
+	"usage_reporting_bucket": "1h",
+	"rest_ingress_enabled": false,
+	"grpc_reflection_enabled": false,
+	"service_load_balancing": {
+		"policy": "round_robin",
+		"health_check_interval": "10s",
+		"consecutive_failures": 5,
+		"ejection_time": "30s",
+		"retries": 1,
+		"idempotent_methods": []
+	},
+	"service_circuit_breaker": {
+		"failure_threshold": 5,
+		"open_time": "30s",
+		"half_open_calls": 1
+	},
+	"service_retry_policies": [],
+	"service_concurrency_limit": {
+		"max_concurrent_calls": 0,
+		"max_queued_calls": 0,
+		"queue_timeout": "1s"
+	},
+	"process_pool": {
+		"workers": 0,
+		"call_timeout": "0s",
+		"max_memory_mb": 0,
+		"restart_delay": "1s"
+	},
+	"request_validation": {
+		"enabled": false,
+		"max_request_bytes": 0,
+		"validate_responses": false
+	},
+	"response_cache": {
+		"enabled": false,
+		"backend": "memory",
+		"max_entries": 10000,
+		"max_bytes": 104857600,
+		"max_entry_bytes": 1048576,
+		"default_ttl": "5m",
+		"cleanup_interval": "1m",
+		"methods": []
+	}
 }`
 	MinimumConfigJson string = `{
 	"blockchain_network_selected": "sepolia",
@@ -185,27 +308,36 @@ func init() {
 	vip.AddConfigPath(".")
 }
 
+// deprecatedParams maps the old deprecated aliases to the current keys
+var deprecatedParams = map[string]string{
+	"daemon_end_point":           DaemonEndpoint,
+	"ipfs_end_point":             IpfsEndpoint,
+	"passthrough_endpoint":       ServiceEndpointKey,
+	"metering_end_point":         MeteringEndpoint,
+	"heartbeat_svc_end_point":    HeartbeatServiceEndpoint,
+	"notification_svc_end_point": NotificationServiceEndpoint,
+	"pvt_key_for_metering":       PvtKeyForMetering,
+	"pvt_key_for_free_calls":     PvtKeyForFreeCalls,
+}
+
 // support old deprecated alias
 func migrateDeprecatedParams(v *viper.Viper) {
-
-	deprecated := map[string]string{
-		"daemon_end_point":           DaemonEndpoint,
-		"ipfs_end_point":             IpfsEndpoint,
-		"passthrough_endpoint":       ServiceEndpointKey,
-		"metering_end_point":         MeteringEndpoint,
-		"heartbeat_svc_end_point":    HeartbeatServiceEndpoint,
-		"notification_svc_end_point": NotificationServiceEndpoint,
-		"pvt_key_for_metering":       PvtKeyForMetering,
-		"pvt_key_for_free_calls":     PvtKeyForFreeCalls,
+	for oldKey, newKey := range DeprecatedParamsUsed(v) {
+		val := v.Get(oldKey)
+		v.Set(newKey, val)
+		zap.L().Warn(fmt.Sprintf("Config parameter '%s' is deprecated, use '%s' instead", oldKey, newKey))
 	}
+}
 
-	for oldKey, newKey := range deprecated {
+// DeprecatedParamsUsed returns the deprecated aliases set in the config mapped to their current keys
+func DeprecatedParamsUsed(v *viper.Viper) map[string]string {
+	used := map[string]string{}
+	for oldKey, newKey := range deprecatedParams {
 		if v.IsSet(oldKey) {
-			val := v.Get(oldKey)
-			v.Set(newKey, val)
-			zap.L().Warn(fmt.Sprintf("Config parameter '%s' is deprecated, use '%s' instead", oldKey, newKey))
+			used[oldKey] = newKey
 		}
 	}
+	return used
 }
 
 // SetVip allows setting a new Viper instance.
@@ -237,6 +369,76 @@ func Validate() error {
 
 	migrateDeprecatedParams(Vip())
 
+	for _, validator := range validators() {
+		if err := validator.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Check if the Daemon is on the latest version or not
+	if message, err := CheckVersionOfDaemon(); err != nil {
+		// In case of any error on version check, just log it
+		zap.L().Warn(err.Error())
+	} else {
+		// Print current version of daemon
+		zap.L().Info(message)
+	}
+
+	mustDuration(ServiceTimeout, time.Second*100)
+	return nil
+}
+
+// ValidationResult is the result of the named validator of the config
+type ValidationResult struct {
+	Name string
+	Err  error
+}
+
+// ValidateAll runs all validators of Validate instead of stopping on the first
+// failed one and returns their results in the order of Validate
+func ValidateAll() []ValidationResult {
+	migrateDeprecatedParams(Vip())
+
+	all := validators()
+	results := make([]ValidationResult, 0, len(all))
+	for _, validator := range all {
+		results = append(results, ValidationResult{Name: validator.name, Err: validator.validate()})
+	}
+	return results
+}
+
+type validator struct {
+	name     string
+	validate func() error
+}
+
+func validators() []validator {
+	return []validator{
+		{"daemon type", validateDaemonType},
+		{"blockchain network", func() error { return setBlockChainNetworkDetails(BlockChainNetworkFileName) }},
+		{"ssl", validateSSL},
+		{"service endpoint", validateServiceEndpoints},
+		{"service load balancing", validateServiceLoadBalancing},
+		{"service circuit breaker", validateServiceCircuitBreaker},
+		{"service retry policies", validateServiceRetryPolicies},
+		{"process pool", validateProcessPool},
+		{"request validation", validateRequestValidation},
+		{"response cache", validateResponseCache},
+		{"model training jobs", validateModelTrainingJobs},
+		{"model training billing", validateModelTrainingBilling},
+		{"model training datasets", validateModelTrainingDatasets},
+		{"payment channel confirmation", validatePaymentChannelConfirmation},
+		{"max message size", validateMaxMessageSize},
+		{"allowed users", allowedUserConfigurationChecks},
+		{"secrets", validateSecrets},
+		{"free call signer key", validateFreeCallSignerKey},
+		{"token secret key", validateTokenSecretKey},
+		{"usage reporting", validateUsageReportingChecks},
+		{"metering", validateMeteringChecks},
+	}
+}
+
+func validateDaemonType() error {
 	switch dType := vip.GetString(DaemonTypeKey); dType {
 	case "grpc":
 	case "http":
@@ -244,53 +446,63 @@ func Validate() error {
 	default:
 		return fmt.Errorf("unrecognized DAEMON_TYPE '%+v'", dType)
 	}
-	if err := setBlockChainNetworkDetails(BlockChainNetworkFileName); err != nil {
-		return err
-	}
+	return nil
+}
+
+func validateSSL() error {
 	certPath, keyPath := vip.GetString(SSLCertPathKey), vip.GetString(SSLKeyPathKey)
 	if (certPath != "" && keyPath == "") || (certPath == "" && keyPath != "") {
 		return errors.New("SSL requires both key and certificate when enabled")
 	}
-
-	// Validate metrics URL and set state
-	serviceEndpoint := vip.GetString(ServiceEndpointKey)
-	daemonEndpoint := vip.GetString(DaemonEndpoint)
-	err := ValidateEndpoints(daemonEndpoint, serviceEndpoint)
-	if err != nil {
+	if err := validateSSLCertificates(); err != nil {
 		return err
 	}
+	return validateSSLClientAuth()
+}
 
-	// Check if the Daemon is on the latest version or not
-	if message, err := CheckVersionOfDaemon(); err != nil {
-		// In case of any error on version check, just log it
-		zap.L().Warn(err.Error())
-	} else {
-		// Print current version of daemon
-		zap.L().Info(message)
+func validateServiceEndpoints() error {
+	serviceEndpoints := GetServiceEndpoints()
+	if len(serviceEndpoints) == 0 {
+		return errors.New("service_endpoint is the endpoint of your AI service in the daemon config and needs to be a valid url")
 	}
+	daemonEndpoint := vip.GetString(DaemonEndpoint)
+	for _, serviceEndpoint := range serviceEndpoints {
+		if err := ValidateEndpoints(daemonEndpoint, serviceEndpoint.Endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Check the maximum message size (The maximum that the server can receive - 2GB).
+// Check the maximum message size (The maximum that the server can receive - 2GB).
+func validateMaxMessageSize() error {
 	maxMessageSize := vip.GetInt(MaxMessageSizeInMB)
 	if maxMessageSize <= 0 || maxMessageSize > 2048 {
 		return errors.New(" max_message_size_in_mb cannot be more than 2GB (i.e 2048 MB) and has to be a positive number")
 	}
-	if err = allowedUserConfigurationChecks(); err != nil {
+	return nil
+}
+
+func validateFreeCallSignerKey() error {
+	privateKey, err := GetSecret(PvtKeyForFreeCalls)
+	if err != nil {
 		return err
 	}
-
-	if GetString(PvtKeyForFreeCalls) != "" {
-		if utils.ParsePrivateKey(GetString(PvtKeyForFreeCalls)) == nil {
-			return errors.New("invalid " + PvtKeyForFreeCalls)
-		}
+	if privateKey != "" && utils.ParsePrivateKey(privateKey) == nil {
+		return errors.New("invalid " + PvtKeyForFreeCalls)
 	}
+	return nil
+}
 
-	if vip.GetBool(BlockchainEnabledKey) && len(GetString(TokenSecretKey)) < 32 {
+func validateTokenSecretKey() error {
+	secretKey, err := GetSecret(TokenSecretKey)
+	if err != nil {
+		return err
+	}
+	if vip.GetBool(BlockchainEnabledKey) && len(secretKey) < 32 {
 		return fmt.Errorf("%s must be set to a value of at least 32 bytes when %s is true", TokenSecretKey, BlockchainEnabledKey)
 	}
-
-	mustDuration(ServiceTimeout, time.Second*100)
-
-	return validateMeteringChecks()
+	return nil
 }
 
 func GetTrustedFreeCallSignersAddresses() []common.Address {
@@ -337,6 +549,17 @@ func validateMeteringChecks() (err error) {
 	return nil
 }
 
+func validateUsageReportingChecks() error {
+	if !GetBool(UsageReportingEnabled) {
+		return nil
+	}
+	bucket, err := time.ParseDuration(GetString(UsageReportingBucket))
+	if err != nil || bucket <= 0 {
+		return fmt.Errorf("%s must be a positive duration like '1h' or '15m'", UsageReportingBucket)
+	}
+	return nil
+}
+
 func LoadConfig(configFile string) error {
 	vip.SetConfigFile(configFile)
 	return vip.ReadInConfig()
@@ -439,8 +662,10 @@ var DisplayKeys = map[string]bool{
 	strings.ToUpper(AuthenticationAddresses):        true,
 	strings.ToUpper(AutoSSLDomainKey):               true,
 	strings.ToUpper(AutoSSLCacheDirKey):             true,
+	strings.ToUpper(AutoSSLCacheStorageKey):         true,
 	strings.ToUpper(BlockchainEnabledKey):           true,
 	strings.ToUpper(BlockChainNetworkSelected):      true,
+	strings.ToUpper(BlockchainNetworksKey):          true,
 	strings.ToUpper(BurstSize):                      true,
 	strings.ToUpper(ConfigPathKey):                  true,
 	strings.ToUpper(DaemonGroupName):                true,
@@ -456,12 +681,33 @@ var DisplayKeys = map[string]bool{
 	strings.ToUpper(ServiceId):                      true,
 	strings.ToUpper(PassthroughEnabledKey):          true,
 	strings.ToUpper(ServiceEndpointKey):             true,
+	strings.ToUpper(ServiceLoadBalancingKey):        true,
+	strings.ToUpper(ServiceCircuitBreakerKey):       true,
+	strings.ToUpper(HTTPRPCMappingKey):              true,
+	strings.ToUpper(ServiceRetryPoliciesKey):        true,
+	strings.ToUpper(ServiceConcurrencyLimitKey):     true,
+	strings.ToUpper(ProcessPoolKey):                 true,
+	strings.ToUpper(RequestValidationKey):           true,
+	strings.ToUpper(ResponseCacheKey):               true,
+	strings.ToUpper(ModelTrainingJobsKey):           true,
+	strings.ToUpper(ModelTrainingBillingKey):        true,
+	strings.ToUpper(ModelTrainingDatasetsKey):       true,
 	strings.ToUpper(RateLimitPerMinute):             true,
 	strings.ToUpper(SSLCertPathKey):                 true,
 	strings.ToUpper(SSLKeyPathKey):                  true,
+	strings.ToUpper(SSLClientAuthKey):               true,
+	strings.ToUpper(SSLCertificatesKey):             true,
+	strings.ToUpper(PaymentStorageMaintenanceKey):   true,
+	strings.ToUpper(PaymentChannelConfirmationKey):  true,
+	strings.ToUpper(SSLCertReloadKey):               true,
 	strings.ToUpper(PaymentChannelCertPath):         true,
 	strings.ToUpper(PaymentChannelCaPath):           true,
 	strings.ToUpper(PaymentChannelKeyPath):          true,
+	strings.ToUpper(PvtKeyForFreeCalls):             true,
+	strings.ToUpper(PvtKeyForMetering):              true,
+	strings.ToUpper(TokenSecretKey):                 true,
+	strings.ToUpper(ServiceCredentialsKey):          true,
+	strings.ToUpper(SecretsKey):                     true,
 	strings.ToUpper(PaymentChannelStorageTypeKey):   true,
 	strings.ToUpper(PaymentChannelStorageClientKey): true,
 	strings.ToUpper(PaymentChannelStorageServerKey): true,
@@ -471,6 +717,10 @@ var DisplayKeys = map[string]bool{
 	strings.ToUpper(MeteringEndpoint):               true,
 	strings.ToUpper(NotificationServiceEndpoint):    true,
 	strings.ToUpper(ServiceHeartbeatType):           true,
+	strings.ToUpper(UsageReportingEnabled):          true,
+	strings.ToUpper(RESTIngressEnabled):             true,
+	strings.ToUpper(GrpcReflectionEnabled):          true,
+	strings.ToUpper(UsageReportingBucket):           true,
 }
 
 func LogConfig() {
@@ -479,10 +729,11 @@ func LogConfig() {
 	sort.Strings(keys)
 	for _, key := range keys {
 		if DisplayKeys[strings.ToUpper(key)] {
-			if v, ok := vip.Get(key).(string); ok && v == "" {
+			value := RedactedValue(key)
+			if v, ok := value.(string); ok && v == "" {
 				continue
 			}
-			zap.L().Info(key, zap.Any("value", vip.Get(key)))
+			zap.L().Info(key, zap.Any("value", value))
 		}
 	}
 }
@@ -609,6 +860,776 @@ type ExperimentalSettings struct {
 	} `json:"traffic_split" mapstructure:"traffic_split"`
 }
 
+// ServiceEndpoint is a single upstream of the service. The service_endpoint
+// accepts a single URL, a list of URLs (or a comma separated string) or a list of
+// objects with the weight and the heartbeat endpoint of each upstream.
+type ServiceEndpoint struct {
+	Endpoint          string `json:"endpoint" mapstructure:"endpoint"`
+	Weight            int    `json:"weight" mapstructure:"weight"`
+	HeartbeatEndpoint string `json:"heartbeat_endpoint" mapstructure:"heartbeat_endpoint"`
+}
+
+// GetServiceEndpoints returns all the upstreams configured in service_endpoint
+func GetServiceEndpoints() []ServiceEndpoint {
+	return parseServiceEndpoints(vip.Get(ServiceEndpointKey))
+}
+
+// GetServiceEndpoint returns the first upstream configured in service_endpoint,
+// it is used where a single service endpoint is expected
+func GetServiceEndpoint() string {
+	endpoints := GetServiceEndpoints()
+	if len(endpoints) == 0 {
+		return ""
+	}
+	return endpoints[0].Endpoint
+}
+
+func parseServiceEndpoints(raw any) []ServiceEndpoint {
+	endpoints := make([]ServiceEndpoint, 0)
+	switch value := raw.(type) {
+	case string:
+		for _, endpoint := range strings.Split(value, ",") {
+			endpoints = appendServiceEndpoint(endpoints, ServiceEndpoint{Endpoint: endpoint})
+		}
+	case []string:
+		for _, endpoint := range value {
+			endpoints = appendServiceEndpoint(endpoints, ServiceEndpoint{Endpoint: endpoint})
+		}
+	case []any:
+		for _, item := range value {
+			switch endpoint := item.(type) {
+			case string:
+				endpoints = appendServiceEndpoint(endpoints, ServiceEndpoint{Endpoint: endpoint})
+			case map[string]any:
+				endpoints = appendServiceEndpoint(endpoints, ServiceEndpoint{
+					Endpoint:          cast.ToString(endpoint["endpoint"]),
+					Weight:            cast.ToInt(endpoint["weight"]),
+					HeartbeatEndpoint: cast.ToString(endpoint["heartbeat_endpoint"]),
+				})
+			}
+		}
+	}
+	return endpoints
+}
+
+func appendServiceEndpoint(endpoints []ServiceEndpoint, endpoint ServiceEndpoint) []ServiceEndpoint {
+	endpoint.Endpoint = strings.TrimSpace(endpoint.Endpoint)
+	if endpoint.Endpoint == "" {
+		return endpoints
+	}
+	if endpoint.Weight <= 0 {
+		endpoint.Weight = 1
+	}
+	return append(endpoints, endpoint)
+}
+
+const (
+	RoundRobinPolicy   = "round_robin"
+	LeastRequestPolicy = "least_request"
+	WeightedPolicy     = "weighted"
+)
+
+// ServiceLoadBalancingSettings configures how the calls are balanced between
+// the upstreams of service_endpoint
+// Policy              - round_robin, least_request or weighted
+// HealthCheckInterval - interval of the active health checks of each upstream, 0 disables them
+// ConsecutiveFailures - number of consecutive failures after which the upstream is ejected, 0 disables ejection
+// EjectionTime        - time the ejected upstream doesn't receive calls
+// Retries             - number of retries of idempotent unary calls on another upstream
+// IdempotentMethods   - full names of the methods which are safe to retry, in addition to
+//
+//	the methods marked with idempotency_level in the proto files
+type ServiceLoadBalancingSettings struct {
+	Policy              string        `json:"policy" mapstructure:"policy"`
+	HealthCheckInterval time.Duration `json:"health_check_interval" mapstructure:"health_check_interval"`
+	ConsecutiveFailures int           `json:"consecutive_failures" mapstructure:"consecutive_failures"`
+	EjectionTime        time.Duration `json:"ejection_time" mapstructure:"ejection_time"`
+	Retries             int           `json:"retries" mapstructure:"retries"`
+	IdempotentMethods   []string      `json:"idempotent_methods" mapstructure:"idempotent_methods"`
+}
+
+// GetServiceLoadBalancing returns the service_load_balancing settings merged with the defaults
+func GetServiceLoadBalancing() (settings *ServiceLoadBalancingSettings, err error) {
+	settings = &ServiceLoadBalancingSettings{Policy: RoundRobinPolicy}
+	subVip := SubWithDefault(vip, ServiceLoadBalancingKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ServiceLoadBalancingKey, err)
+	}
+	if settings.Policy == "" {
+		settings.Policy = RoundRobinPolicy
+	}
+	return settings, nil
+}
+
+func validateServiceLoadBalancing() error {
+	settings, err := GetServiceLoadBalancing()
+	if err != nil {
+		return err
+	}
+	switch settings.Policy {
+	case RoundRobinPolicy, LeastRequestPolicy, WeightedPolicy:
+	default:
+		return fmt.Errorf("unrecognized %s.policy '%s', supported policies are %s, %s and %s",
+			ServiceLoadBalancingKey, settings.Policy, RoundRobinPolicy, LeastRequestPolicy, WeightedPolicy)
+	}
+	if settings.HealthCheckInterval < 0 || settings.EjectionTime < 0 || settings.ConsecutiveFailures < 0 || settings.Retries < 0 {
+		return fmt.Errorf("%s values can't be negative", ServiceLoadBalancingKey)
+	}
+	return nil
+}
+
+// ServiceCircuitBreakerSettings configures the circuit breaker kept per upstream and per method
+// FailureThreshold - number of consecutive failures which opens the circuit, 0 disables the circuit breaker
+// OpenTime         - time the open circuit rejects the calls before letting trial calls through
+// HalfOpenCalls    - number of trial calls allowed at once when the circuit is half-open
+type ServiceCircuitBreakerSettings struct {
+	FailureThreshold int           `json:"failure_threshold" mapstructure:"failure_threshold"`
+	OpenTime         time.Duration `json:"open_time" mapstructure:"open_time"`
+	HalfOpenCalls    int           `json:"half_open_calls" mapstructure:"half_open_calls"`
+}
+
+// ServiceConcurrencyLimitSettings limits the calls in flight to the upstreams
+// MaxConcurrentCalls - calls in flight per upstream applied to the pool as a whole, 0 means no limit
+// MaxQueuedCalls     - maximum number of calls waiting for a free slot, the others are rejected
+// QueueTimeout       - maximum time the call waits in the queue
+type ServiceConcurrencyLimitSettings struct {
+	MaxConcurrentCalls int           `json:"max_concurrent_calls" mapstructure:"max_concurrent_calls"`
+	MaxQueuedCalls     int           `json:"max_queued_calls" mapstructure:"max_queued_calls"`
+	QueueTimeout       time.Duration `json:"queue_timeout" mapstructure:"queue_timeout"`
+}
+
+// GetServiceCircuitBreaker returns the service_circuit_breaker settings merged with the defaults
+func GetServiceCircuitBreaker() (settings *ServiceCircuitBreakerSettings, err error) {
+	settings = &ServiceCircuitBreakerSettings{}
+	subVip := SubWithDefault(vip, ServiceCircuitBreakerKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ServiceCircuitBreakerKey, err)
+	}
+	return settings, nil
+}
+
+// GetServiceConcurrencyLimit returns the service_concurrency_limit settings merged with the defaults
+func GetServiceConcurrencyLimit() (settings *ServiceConcurrencyLimitSettings, err error) {
+	settings = &ServiceConcurrencyLimitSettings{}
+	subVip := SubWithDefault(vip, ServiceConcurrencyLimitKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ServiceConcurrencyLimitKey, err)
+	}
+	return settings, nil
+}
+
+func validateServiceCircuitBreaker() error {
+	breaker, err := GetServiceCircuitBreaker()
+	if err != nil {
+		return err
+	}
+	if breaker.FailureThreshold < 0 || breaker.OpenTime < 0 || breaker.HalfOpenCalls < 0 {
+		return fmt.Errorf("%s values can't be negative", ServiceCircuitBreakerKey)
+	}
+	if breaker.FailureThreshold > 0 && breaker.HalfOpenCalls == 0 {
+		return fmt.Errorf("%s.half_open_calls must be at least 1", ServiceCircuitBreakerKey)
+	}
+	limit, err := GetServiceConcurrencyLimit()
+	if err != nil {
+		return err
+	}
+	if limit.MaxConcurrentCalls < 0 || limit.MaxQueuedCalls < 0 || limit.QueueTimeout < 0 {
+		return fmt.Errorf("%s values can't be negative", ServiceConcurrencyLimitKey)
+	}
+	return nil
+}
+
+// ServiceRetryPolicy configures the retries of the unary calls of the methods
+// Methods              - full names of the methods, the methods must be safe to call several times
+// MaxAttempts          - maximum number of the calls of the service, including the first one
+// RetryableStatusCodes - status codes of the failed attempts which are retried, e.g. UNAVAILABLE
+// InitialBackoff       - delay before the first retry, the delays are randomized between 0 and the backoff
+// MaxBackoff           - maximum delay between the retries
+// BackoffMultiplier    - the backoff is multiplied by it after every retry
+// HedgingDelay         - when not 0, the next attempt is sent to another upstream if the previous one
+//
+//	doesn't respond within the delay, the first response wins and the other attempts are cancelled
+type ServiceRetryPolicy struct {
+	Methods              []string      `json:"methods" mapstructure:"methods"`
+	MaxAttempts          int           `json:"max_attempts" mapstructure:"max_attempts"`
+	RetryableStatusCodes []string      `json:"retryable_status_codes" mapstructure:"retryable_status_codes"`
+	InitialBackoff       time.Duration `json:"initial_backoff" mapstructure:"initial_backoff"`
+	MaxBackoff           time.Duration `json:"max_backoff" mapstructure:"max_backoff"`
+	BackoffMultiplier    float64       `json:"backoff_multiplier" mapstructure:"backoff_multiplier"`
+	HedgingDelay         time.Duration `json:"hedging_delay" mapstructure:"hedging_delay"`
+}
+
+// GetServiceRetryPolicies returns the service_retry_policies, the missing fields of
+// the policy are set to the defaults: UNAVAILABLE is retried with the backoff from
+// 100ms to 1s multiplied by 2
+func GetServiceRetryPolicies() (policies []ServiceRetryPolicy, err error) {
+	if err = vip.UnmarshalKey(ServiceRetryPoliciesKey, &policies); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ServiceRetryPoliciesKey, err)
+	}
+	for i := range policies {
+		policy := &policies[i]
+		if len(policy.RetryableStatusCodes) == 0 {
+			policy.RetryableStatusCodes = []string{"UNAVAILABLE"}
+		}
+		if policy.InitialBackoff == 0 {
+			policy.InitialBackoff = 100 * time.Millisecond
+		}
+		if policy.MaxBackoff == 0 {
+			policy.MaxBackoff = time.Second
+		}
+		if policy.BackoffMultiplier == 0 {
+			policy.BackoffMultiplier = 2
+		}
+	}
+	return policies, nil
+}
+
+func validateServiceRetryPolicies() error {
+	policies, err := GetServiceRetryPolicies()
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		if len(policy.Methods) == 0 {
+			return fmt.Errorf("%s: the policy has no methods", ServiceRetryPoliciesKey)
+		}
+		for _, method := range policy.Methods {
+			if !strings.HasPrefix(method, "/") || strings.Count(method, "/") != 2 {
+				return fmt.Errorf("%s method '%s' must be the full method name /package.Service/Method", ServiceRetryPoliciesKey, method)
+			}
+		}
+		if policy.MaxAttempts < 1 || policy.MaxAttempts > 10 {
+			return fmt.Errorf("%s max_attempts of %v must be between 1 and 10", ServiceRetryPoliciesKey, policy.Methods)
+		}
+		for _, code := range policy.RetryableStatusCodes {
+			if _, err := StatusCode(code); err != nil {
+				return fmt.Errorf("%s: %v", ServiceRetryPoliciesKey, err)
+			}
+		}
+		if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 || policy.HedgingDelay < 0 {
+			return fmt.Errorf("%s values can't be negative", ServiceRetryPoliciesKey)
+		}
+		if policy.BackoffMultiplier < 1 {
+			return fmt.Errorf("%s backoff_multiplier must be at least 1", ServiceRetryPoliciesKey)
+		}
+	}
+	return nil
+}
+
+// StatusCode parses the gRPC status code name, e.g. UNAVAILABLE
+func StatusCode(name string) (code codes.Code, err error) {
+	if err = code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err != nil {
+		return code, fmt.Errorf("unknown status code '%s'", name)
+	}
+	return code, nil
+}
+
+// ProcessPoolSettings configures the long-lived worker processes of executable_path
+// Workers      - number of worker processes, 0 starts the executable for every call
+// CallTimeout  - maximum duration of the call, service_timeout is used when 0
+// MaxMemoryMB  - resident memory above which the worker is killed and restarted, 0 means no limit
+// RestartDelay - delay before the exited worker is started again
+type ProcessPoolSettings struct {
+	Workers      int           `json:"workers" mapstructure:"workers"`
+	CallTimeout  time.Duration `json:"call_timeout" mapstructure:"call_timeout"`
+	MaxMemoryMB  int           `json:"max_memory_mb" mapstructure:"max_memory_mb"`
+	RestartDelay time.Duration `json:"restart_delay" mapstructure:"restart_delay"`
+}
+
+// GetProcessPool returns the process_pool settings merged with the defaults
+func GetProcessPool() (settings *ProcessPoolSettings, err error) {
+	settings = &ProcessPoolSettings{}
+	subVip := SubWithDefault(vip, ProcessPoolKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ProcessPoolKey, err)
+	}
+	return settings, nil
+}
+
+func validateProcessPool() error {
+	settings, err := GetProcessPool()
+	if err != nil {
+		return err
+	}
+	if settings.Workers < 0 || settings.CallTimeout < 0 || settings.MaxMemoryMB < 0 || settings.RestartDelay < 0 {
+		return fmt.Errorf("%s values can't be negative", ProcessPoolKey)
+	}
+	if settings.Workers > 0 && vip.GetString(ExecutablePathKey) == "" {
+		return fmt.Errorf("%s requires %s", ProcessPoolKey, ExecutablePathKey)
+	}
+	return nil
+}
+
+// RequestValidationSettings configures the validation of the requests against the proto files of the service
+// Enabled           - decode and validate the requests before the payment is checked
+// MaxRequestBytes   - maximum size of a request message, 0 means no limit besides max_message_recv_size
+// ValidateResponses - check that the responses of the service can be decoded with the output type
+type RequestValidationSettings struct {
+	Enabled           bool `json:"enabled" mapstructure:"enabled"`
+	MaxRequestBytes   int  `json:"max_request_bytes" mapstructure:"max_request_bytes"`
+	ValidateResponses bool `json:"validate_responses" mapstructure:"validate_responses"`
+}
+
+// GetRequestValidation returns the request_validation settings merged with the defaults
+func GetRequestValidation() (settings *RequestValidationSettings, err error) {
+	settings = &RequestValidationSettings{}
+	subVip := SubWithDefault(vip, RequestValidationKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RequestValidationKey, err)
+	}
+	return settings, nil
+}
+
+func validateRequestValidation() error {
+	settings, err := GetRequestValidation()
+	if err != nil {
+		return err
+	}
+	if settings.MaxRequestBytes < 0 {
+		return fmt.Errorf("%s.max_request_bytes can't be negative", RequestValidationKey)
+	}
+	return nil
+}
+
+const (
+	MemoryCacheBackend  = "memory"
+	StorageCacheBackend = "storage"
+)
+
+// ResponseCacheSettings configures the cache of the responses of the unary methods
+// Enabled       - serve the repeated calls of Methods from the cache
+// Backend       - memory (LRU of the daemon) or storage (shared by the daemons of the group via etcd)
+// MaxEntries      - maximum number of the responses in the cache
+// MaxBytes        - maximum size of the responses in the cache
+// MaxEntryBytes   - the larger responses aren't cached
+// DefaultTTL      - time the response is cached when the method has no TTL
+// CleanupInterval - how often the storage cache removes the expired responses and applies the bounds
+// Methods         - the cached methods
+type ResponseCacheSettings struct {
+	Enabled         bool                  `json:"enabled" mapstructure:"enabled"`
+	Backend         string                `json:"backend" mapstructure:"backend"`
+	MaxEntries      int                   `json:"max_entries" mapstructure:"max_entries"`
+	MaxBytes        int                   `json:"max_bytes" mapstructure:"max_bytes"`
+	MaxEntryBytes   int                   `json:"max_entry_bytes" mapstructure:"max_entry_bytes"`
+	DefaultTTL      time.Duration         `json:"default_ttl" mapstructure:"default_ttl"`
+	CleanupInterval time.Duration         `json:"cleanup_interval" mapstructure:"cleanup_interval"`
+	Methods         []ResponseCacheMethod `json:"methods" mapstructure:"methods"`
+}
+
+// ResponseCacheMethod is the cached method
+// Method         - full method name, e.g. /example_service.Calculator/add
+// TTL            - time the response is cached, default_ttl is used when 0
+// HitPriceInCogs - price of the call served from the cache, the price of the method when not set
+type ResponseCacheMethod struct {
+	Method         string        `json:"method" mapstructure:"method"`
+	TTL            time.Duration `json:"ttl" mapstructure:"ttl"`
+	HitPriceInCogs *uint64       `json:"hit_price_in_cogs" mapstructure:"hit_price_in_cogs"`
+}
+
+// GetResponseCache returns the response_cache settings merged with the defaults
+func GetResponseCache() (settings *ResponseCacheSettings, err error) {
+	settings = &ResponseCacheSettings{Backend: MemoryCacheBackend, CleanupInterval: time.Minute}
+	subVip := SubWithDefault(vip, ResponseCacheKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ResponseCacheKey, err)
+	}
+	return settings, nil
+}
+
+// ResponseCacheMethod returns the cache settings of the method, nil when the method isn't cached
+func (settings *ResponseCacheSettings) ResponseCacheMethod(fullMethod string) *ResponseCacheMethod {
+	for i := range settings.Methods {
+		if settings.Methods[i].Method == fullMethod {
+			return &settings.Methods[i]
+		}
+	}
+	return nil
+}
+
+func validateResponseCache() error {
+	settings, err := GetResponseCache()
+	if err != nil {
+		return err
+	}
+	switch settings.Backend {
+	case MemoryCacheBackend, StorageCacheBackend:
+	default:
+		return fmt.Errorf("unrecognized %s.backend '%s', supported backends are %s and %s",
+			ResponseCacheKey, settings.Backend, MemoryCacheBackend, StorageCacheBackend)
+	}
+	if settings.MaxEntries < 0 || settings.MaxBytes < 0 || settings.MaxEntryBytes < 0 || settings.DefaultTTL < 0 {
+		return fmt.Errorf("%s values can't be negative", ResponseCacheKey)
+	}
+	if settings.CleanupInterval <= 0 {
+		return fmt.Errorf("%s cleanup_interval must be positive", ResponseCacheKey)
+	}
+	for _, method := range settings.Methods {
+		if !strings.HasPrefix(method.Method, "/") || strings.Count(method.Method, "/") != 2 {
+			return fmt.Errorf("%s method '%s' must be the full method name /package.Service/Method", ResponseCacheKey, method.Method)
+		}
+		if method.TTL < 0 {
+			return fmt.Errorf("%s ttl of %s can't be negative", ResponseCacheKey, method.Method)
+		}
+		if method.TTL == 0 && settings.DefaultTTL == 0 {
+			return fmt.Errorf("%s ttl of %s is required when default_ttl is 0", ResponseCacheKey, method.Method)
+		}
+	}
+	return nil
+}
+
+// ModelTrainingJobsSettings configures the tracking of the validation and training jobs
+// Workers         - maximum number of the status requests to the service provider at once
+// PollInterval    - interval between the status requests of the job, it's doubled every time
+//
+//	the status doesn't change, up to MaxPollInterval
+//
+// StatusTimeout   - timeout of the status request
+// JobTimeout      - the job is dropped when its status doesn't change for this time
+// HistorySize     - number of the status events kept per model
+type ModelTrainingJobsSettings struct {
+	Workers         int           `json:"workers" mapstructure:"workers"`
+	PollInterval    time.Duration `json:"poll_interval" mapstructure:"poll_interval"`
+	MaxPollInterval time.Duration `json:"max_poll_interval" mapstructure:"max_poll_interval"`
+	StatusTimeout   time.Duration `json:"status_timeout" mapstructure:"status_timeout"`
+	JobTimeout      time.Duration `json:"job_timeout" mapstructure:"job_timeout"`
+	HistorySize     int           `json:"history_size" mapstructure:"history_size"`
+}
+
+// GetModelTrainingJobs returns the model_training_jobs settings
+func GetModelTrainingJobs() (settings *ModelTrainingJobsSettings, err error) {
+	settings = &ModelTrainingJobsSettings{Workers: 4, PollInterval: 3 * time.Second, MaxPollInterval: 5 * time.Minute,
+		StatusTimeout: 20 * time.Second, JobTimeout: 168 * time.Hour, HistorySize: 100}
+	subVip := SubWithDefault(vip, ModelTrainingJobsKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ModelTrainingJobsKey, err)
+	}
+	return settings, nil
+}
+
+func validateModelTrainingJobs() error {
+	if !vip.GetBool(ModelTrainingEnabled) {
+		return nil
+	}
+	settings, err := GetModelTrainingJobs()
+	if err != nil {
+		return err
+	}
+	if settings.Workers < 1 || settings.HistorySize < 1 {
+		return fmt.Errorf("%s workers and history_size must be at least 1", ModelTrainingJobsKey)
+	}
+	if settings.PollInterval <= 0 || settings.StatusTimeout <= 0 || settings.JobTimeout <= 0 {
+		return fmt.Errorf("%s poll_interval, status_timeout and job_timeout must be positive", ModelTrainingJobsKey)
+	}
+	if settings.MaxPollInterval < settings.PollInterval {
+		return fmt.Errorf("%s max_poll_interval can't be less than poll_interval", ModelTrainingJobsKey)
+	}
+	return nil
+}
+
+// ModelTrainingBillingSettings configures the charge of the validation and training jobs,
+// the payment is committed to the channel as usual and is settled when the job is finished
+// ErroredChargeRatio - part of the payment captured when the job ends with the ERRORED
+//
+//	status, the rest is refunded to the payment channel
+type ModelTrainingBillingSettings struct {
+	ErroredChargeRatio float64 `json:"errored_charge_ratio" mapstructure:"errored_charge_ratio"`
+}
+
+// GetModelTrainingBilling returns the model_training_billing settings
+func GetModelTrainingBilling() (settings *ModelTrainingBillingSettings, err error) {
+	settings = &ModelTrainingBillingSettings{ErroredChargeRatio: 1}
+	subVip := SubWithDefault(vip, ModelTrainingBillingKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ModelTrainingBillingKey, err)
+	}
+	return settings, nil
+}
+
+func validateModelTrainingBilling() error {
+	settings, err := GetModelTrainingBilling()
+	if err != nil {
+		return err
+	}
+	if settings.ErroredChargeRatio < 0 || settings.ErroredChargeRatio > 1 {
+		return fmt.Errorf("%s errored_charge_ratio must be from 0 to 1", ModelTrainingBillingKey)
+	}
+	return nil
+}
+
+// PaymentChannelConfirmationSettings configures the reads of the payment channels,
+// the channels are read confirmation_depth blocks below the latest block of the network
+// Optimistic         - the deposits to the channel which aren't confirmed yet are accepted
+// UnconfirmedLimit   - the largest unconfirmed deposit accepted in the optimistic mode, in cogs
+// ReorgCheckInterval - the interval of the checks of the chain reorganization, the channels of
+//
+//	the storage are reconciled with the blockchain after the reorganization, 0 disables the checks
+type PaymentChannelConfirmationSettings struct {
+	Optimistic         bool          `json:"optimistic" mapstructure:"optimistic"`
+	UnconfirmedLimit   *big.Int      `json:"unconfirmed_limit" mapstructure:"-"`
+	ReorgCheckInterval time.Duration `json:"reorg_check_interval" mapstructure:"reorg_check_interval"`
+}
+
+// GetPaymentChannelConfirmation returns the payment_channel_confirmation settings
+func GetPaymentChannelConfirmation() (settings *PaymentChannelConfirmationSettings, err error) {
+	settings = &PaymentChannelConfirmationSettings{UnconfirmedLimit: big.NewInt(0)}
+	subVip := SubWithDefault(vip, PaymentChannelConfirmationKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", PaymentChannelConfirmationKey, err)
+	}
+	if limit := subVip.GetString("unconfirmed_limit"); limit != "" {
+		if _, ok := settings.UnconfirmedLimit.SetString(limit, 10); !ok {
+			return nil, fmt.Errorf("invalid %s: unconfirmed_limit %q is not a number", PaymentChannelConfirmationKey, limit)
+		}
+	}
+	return settings, nil
+}
+
+func validatePaymentChannelConfirmation() error {
+	settings, err := GetPaymentChannelConfirmation()
+	if err != nil {
+		return err
+	}
+	if settings.UnconfirmedLimit.Sign() < 0 {
+		return fmt.Errorf("%s unconfirmed_limit can't be negative", PaymentChannelConfirmationKey)
+	}
+	if settings.ReorgCheckInterval < 0 {
+		return fmt.Errorf("%s reorg_check_interval can't be negative", PaymentChannelConfirmationKey)
+	}
+	return nil
+}
+
+const (
+	DatasetStorageLocal = "local"
+	DatasetStorageIpfs  = "ipfs"
+)
+
+// ModelTrainingDatasetsSettings configures the staging of the datasets uploaded by
+// upload_and_validate, the datasets are streamed to the service provider when Storage is empty
+// Storage   - "local" keeps the datasets in Dir, "ipfs" adds them to the ipfs_end_point node
+// Dir       - directory of the datasets and the incomplete uploads
+// UploadTTL - the incomplete upload is removed when it isn't resumed for this time
+type ModelTrainingDatasetsSettings struct {
+	Storage   string        `json:"storage" mapstructure:"storage"`
+	Dir       string        `json:"dir" mapstructure:"dir"`
+	UploadTTL time.Duration `json:"upload_ttl" mapstructure:"upload_ttl"`
+}
+
+// GetModelTrainingDatasets returns the model_training_datasets settings
+func GetModelTrainingDatasets() (settings *ModelTrainingDatasetsSettings, err error) {
+	settings = &ModelTrainingDatasetsSettings{}
+	subVip := SubWithDefault(vip, ModelTrainingDatasetsKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ModelTrainingDatasetsKey, err)
+	}
+	return settings, nil
+}
+
+func validateModelTrainingDatasets() error {
+	settings, err := GetModelTrainingDatasets()
+	if err != nil {
+		return err
+	}
+	switch settings.Storage {
+	case "":
+		return nil
+	case DatasetStorageLocal, DatasetStorageIpfs:
+	default:
+		return fmt.Errorf("%s storage must be %q, %q or empty", ModelTrainingDatasetsKey, DatasetStorageLocal, DatasetStorageIpfs)
+	}
+	if settings.Dir == "" {
+		return fmt.Errorf("%s dir is required", ModelTrainingDatasetsKey)
+	}
+	if settings.UploadTTL <= 0 {
+		return fmt.Errorf("%s upload_ttl must be positive", ModelTrainingDatasetsKey)
+	}
+	return nil
+}
+
+const (
+	AutoSSLCacheDir     = "dir"
+	AutoSSLCacheStorage = "storage"
+)
+
+// SSLCertificate is the key pair served by the daemon listener
+type SSLCertificate struct {
+	Cert string `json:"cert" mapstructure:"cert"`
+	Key  string `json:"key" mapstructure:"key"`
+}
+
+// GetSSLCertificates returns the key pairs of the daemon listener, the pair of
+// ssl_cert and ssl_key goes first and is served to the clients without SNI or
+// with an unknown server name, the certificate of ssl_certificates is selected
+// by the server name of the client.
+func GetSSLCertificates() (certificates []SSLCertificate, err error) {
+	if cert := vip.GetString(SSLCertPathKey); cert != "" {
+		certificates = append(certificates, SSLCertificate{Cert: cert, Key: vip.GetString(SSLKeyPathKey)})
+	}
+	var extra []SSLCertificate
+	if err = vip.UnmarshalKey(SSLCertificatesKey, &extra); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SSLCertificatesKey, err)
+	}
+	return append(certificates, extra...), nil
+}
+
+// GetAutoSSLDomains returns the comma separated domains of auto_ssl_domain
+func GetAutoSSLDomains() (domains []string) {
+	for domain := range strings.SplitSeq(vip.GetString(AutoSSLDomainKey), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// SSLCertReloadSettings configures the reloading of the certificates of the daemon listener
+// Watch         - the certificate files are watched for changes, they are polled when the watch isn't available
+// PollInterval  - how often the certificate files are checked when they aren't watched
+// ExpiryWarning - the alert is sent when the certificate expires within this time
+type SSLCertReloadSettings struct {
+	Watch         bool          `json:"watch" mapstructure:"watch"`
+	PollInterval  time.Duration `json:"poll_interval" mapstructure:"poll_interval"`
+	ExpiryWarning time.Duration `json:"expiry_warning" mapstructure:"expiry_warning"`
+}
+
+// GetSSLCertReload returns the ssl_cert_reload settings
+func GetSSLCertReload() (settings *SSLCertReloadSettings, err error) {
+	settings = &SSLCertReloadSettings{Watch: true, PollInterval: 30 * time.Second, ExpiryWarning: 720 * time.Hour}
+	subVip := SubWithDefault(vip, SSLCertReloadKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SSLCertReloadKey, err)
+	}
+	return settings, nil
+}
+
+func validateSSLCertificates() error {
+	certificates, err := GetSSLCertificates()
+	if err != nil {
+		return err
+	}
+	for _, certificate := range certificates {
+		if certificate.Cert == "" || certificate.Key == "" {
+			return errors.New("SSL requires both key and certificate when enabled")
+		}
+	}
+	if len(certificates) > 0 && vip.GetString(SSLCertPathKey) == "" {
+		return fmt.Errorf("%s requires %s and %s for the clients without SNI", SSLCertificatesKey, SSLCertPathKey, SSLKeyPathKey)
+	}
+	if vip.GetString(SSLCertPathKey) == "" && vip.GetString(AutoSSLDomainKey) == "" {
+		return nil
+	}
+	settings, err := GetSSLCertReload()
+	if err != nil {
+		return err
+	}
+	if settings.PollInterval <= 0 {
+		return fmt.Errorf("%s poll_interval must be positive", SSLCertReloadKey)
+	}
+	if settings.ExpiryWarning < 0 {
+		return fmt.Errorf("%s expiry_warning can't be negative", SSLCertReloadKey)
+	}
+	switch vip.GetString(AutoSSLCacheStorageKey) {
+	case "", AutoSSLCacheDir, AutoSSLCacheStorage:
+	default:
+		return fmt.Errorf("%s must be %q or %q", AutoSSLCacheStorageKey, AutoSSLCacheDir, AutoSSLCacheStorage)
+	}
+	return nil
+}
+
+const (
+	SSLClientAuthRequire       = "require"
+	SSLClientAuthVerifyIfGiven = "verify_if_given"
+)
+
+// SSLClientAuthSettings configures the mTLS authentication of the clients on the daemon listener
+// Mode              - "require" rejects the connections without a client certificate,
+// "verify_if_given" verifies the certificate only when the client sends it
+// CA                - path to the bundle of the CA certificates the client certificates are issued by
+// CRL               - optional path to the certificate revocation lists (PEM or DER) of the CAs
+// CRLReloadInterval - how often the CRL file is reloaded
+// TrustedIdentities - the certificate identities (SAN or CN) admitted without a payment
+type SSLClientAuthSettings struct {
+	Enabled           bool          `json:"enabled" mapstructure:"enabled"`
+	Mode              string        `json:"mode" mapstructure:"mode"`
+	CA                string        `json:"ca" mapstructure:"ca"`
+	CRL               string        `json:"crl" mapstructure:"crl"`
+	CRLReloadInterval time.Duration `json:"crl_reload_interval" mapstructure:"crl_reload_interval"`
+	TrustedIdentities []string      `json:"trusted_identities" mapstructure:"trusted_identities"`
+}
+
+// GetSSLClientAuth returns the ssl_client_auth settings
+func GetSSLClientAuth() (settings *SSLClientAuthSettings, err error) {
+	settings = &SSLClientAuthSettings{}
+	subVip := SubWithDefault(vip, SSLClientAuthKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SSLClientAuthKey, err)
+	}
+	return settings, nil
+}
+
+func validateSSLClientAuth() error {
+	settings, err := GetSSLClientAuth()
+	if err != nil {
+		return err
+	}
+	if !settings.Enabled {
+		return nil
+	}
+	if vip.GetString(SSLCertPathKey) == "" && vip.GetString(AutoSSLDomainKey) == "" {
+		return fmt.Errorf("%s requires %s or %s", SSLClientAuthKey, SSLCertPathKey, AutoSSLDomainKey)
+	}
+	switch settings.Mode {
+	case SSLClientAuthRequire, SSLClientAuthVerifyIfGiven:
+	default:
+		return fmt.Errorf("%s mode must be %q or %q", SSLClientAuthKey, SSLClientAuthRequire, SSLClientAuthVerifyIfGiven)
+	}
+	if settings.CA == "" {
+		return fmt.Errorf("%s ca is required", SSLClientAuthKey)
+	}
+	if settings.CRL != "" && settings.CRLReloadInterval <= 0 {
+		return fmt.Errorf("%s crl_reload_interval must be positive", SSLClientAuthKey)
+	}
+	if slices.Contains(settings.TrustedIdentities, "") {
+		return fmt.Errorf("%s trusted_identities can't contain an empty identity", SSLClientAuthKey)
+	}
+	return nil
+}
+
 func mustDuration(key string, def time.Duration) time.Duration {
 	raw := vip.Get(key)
 
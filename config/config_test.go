@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/spf13/viper"
@@ -193,3 +194,353 @@ func Test_validateMeteringChecks(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseServiceEndpoints(t *testing.T) {
+	endpoints := parseServiceEndpoints("http://127.0.0.1:5001, http://127.0.0.1:5002")
+	assert.Equal(t, []ServiceEndpoint{{Endpoint: "http://127.0.0.1:5001", Weight: 1}, {Endpoint: "http://127.0.0.1:5002", Weight: 1}}, endpoints)
+
+	endpoints = parseServiceEndpoints([]any{
+		"http://127.0.0.1:5001",
+		map[string]any{"endpoint": "http://127.0.0.1:5002", "weight": 3, "heartbeat_endpoint": "http://127.0.0.1:5002/health"},
+	})
+	assert.Equal(t, []ServiceEndpoint{
+		{Endpoint: "http://127.0.0.1:5001", Weight: 1},
+		{Endpoint: "http://127.0.0.1:5002", Weight: 3, HeartbeatEndpoint: "http://127.0.0.1:5002/health"},
+	}, endpoints)
+
+	assert.Empty(t, parseServiceEndpoints(""))
+	assert.Empty(t, parseServiceEndpoints(nil))
+}
+
+func Test_GetServiceLoadBalancing(t *testing.T) {
+	defer vip.Set(ServiceLoadBalancingKey, vip.Get(ServiceLoadBalancingKey))
+
+	vip.Set(ServiceLoadBalancingKey, map[string]any{"policy": "least_request", "ejection_time": "1m"})
+	settings, err := GetServiceLoadBalancing()
+	assert.Nil(t, err)
+	assert.Equal(t, LeastRequestPolicy, settings.Policy)
+	assert.Equal(t, time.Minute, settings.EjectionTime)
+	assert.Equal(t, 10*time.Second, settings.HealthCheckInterval)
+	assert.Equal(t, 5, settings.ConsecutiveFailures)
+	assert.Nil(t, validateServiceLoadBalancing())
+
+	vip.Set(ServiceLoadBalancingKey, map[string]any{"policy": "random"})
+	assert.NotNil(t, validateServiceLoadBalancing())
+}
+
+func Test_validateServiceCircuitBreaker(t *testing.T) {
+	defer vip.Set(ServiceCircuitBreakerKey, vip.Get(ServiceCircuitBreakerKey))
+	defer vip.Set(ServiceConcurrencyLimitKey, vip.Get(ServiceConcurrencyLimitKey))
+
+	vip.Set(ServiceCircuitBreakerKey, map[string]any{"open_time": "1m"})
+	breaker, err := GetServiceCircuitBreaker()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, breaker.FailureThreshold)
+	assert.Equal(t, time.Minute, breaker.OpenTime)
+	assert.Equal(t, 1, breaker.HalfOpenCalls)
+	assert.Nil(t, validateServiceCircuitBreaker())
+
+	vip.Set(ServiceCircuitBreakerKey, map[string]any{"half_open_calls": 0})
+	assert.NotNil(t, validateServiceCircuitBreaker())
+
+	vip.Set(ServiceCircuitBreakerKey, map[string]any{"failure_threshold": 0, "half_open_calls": 0})
+	vip.Set(ServiceConcurrencyLimitKey, map[string]any{"max_concurrent_calls": 10})
+	limit, err := GetServiceConcurrencyLimit()
+	assert.Nil(t, err)
+	assert.Equal(t, 10, limit.MaxConcurrentCalls)
+	assert.Equal(t, time.Second, limit.QueueTimeout)
+	assert.Nil(t, validateServiceCircuitBreaker())
+
+	vip.Set(ServiceConcurrencyLimitKey, map[string]any{"max_queued_calls": -1})
+	assert.NotNil(t, validateServiceCircuitBreaker())
+}
+
+func Test_validateProcessPool(t *testing.T) {
+	defer vip.Set(ProcessPoolKey, vip.Get(ProcessPoolKey))
+	defer vip.Set(ExecutablePathKey, vip.Get(ExecutablePathKey))
+
+	vip.Set(ProcessPoolKey, map[string]any{"workers": 4})
+	vip.Set(ExecutablePathKey, "")
+	assert.NotNil(t, validateProcessPool())
+
+	vip.Set(ExecutablePathKey, "/usr/bin/model")
+	settings, err := GetProcessPool()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, settings.Workers)
+	assert.Equal(t, time.Second, settings.RestartDelay)
+	assert.Nil(t, validateProcessPool())
+
+	vip.Set(ProcessPoolKey, map[string]any{"max_memory_mb": -1})
+	assert.NotNil(t, validateProcessPool())
+}
+
+func Test_validateRequestValidation(t *testing.T) {
+	defer vip.Set(RequestValidationKey, vip.Get(RequestValidationKey))
+
+	settings, err := GetRequestValidation()
+	assert.Nil(t, err)
+	assert.False(t, settings.Enabled)
+
+	vip.Set(RequestValidationKey, map[string]any{"enabled": true, "max_request_bytes": 1024})
+	settings, err = GetRequestValidation()
+	assert.Nil(t, err)
+	assert.True(t, settings.Enabled)
+	assert.Equal(t, 1024, settings.MaxRequestBytes)
+	assert.False(t, settings.ValidateResponses)
+	assert.Nil(t, validateRequestValidation())
+
+	vip.Set(RequestValidationKey, map[string]any{"max_request_bytes": -1})
+	assert.NotNil(t, validateRequestValidation())
+}
+
+func Test_validateResponseCache(t *testing.T) {
+	defer vip.Set(ResponseCacheKey, vip.Get(ResponseCacheKey))
+
+	vip.Set(ResponseCacheKey, map[string]any{
+		"enabled": true,
+		"methods": []any{
+			map[string]any{"method": "/example_service.Calculator/add", "ttl": "1h", "hit_price_in_cogs": 1},
+			map[string]any{"method": "/example_service.Calculator/mul"},
+		},
+	})
+	settings, err := GetResponseCache()
+	assert.Nil(t, err)
+	assert.Equal(t, MemoryCacheBackend, settings.Backend)
+	assert.Equal(t, 5*time.Minute, settings.DefaultTTL)
+	assert.Equal(t, time.Minute, settings.CleanupInterval)
+	add := settings.ResponseCacheMethod("/example_service.Calculator/add")
+	assert.Equal(t, time.Hour, add.TTL)
+	assert.Equal(t, uint64(1), *add.HitPriceInCogs)
+	assert.Nil(t, settings.ResponseCacheMethod("/example_service.Calculator/mul").HitPriceInCogs)
+	assert.Nil(t, settings.ResponseCacheMethod("/example_service.Calculator/div"))
+	assert.Nil(t, validateResponseCache())
+
+	vip.Set(ResponseCacheKey, map[string]any{"backend": "redis"})
+	assert.NotNil(t, validateResponseCache())
+	vip.Set(ResponseCacheKey, map[string]any{"methods": []any{map[string]any{"method": "add"}}})
+	assert.NotNil(t, validateResponseCache())
+	vip.Set(ResponseCacheKey, map[string]any{"default_ttl": "0s", "methods": []any{map[string]any{"method": "/example_service.Calculator/add"}}})
+	assert.NotNil(t, validateResponseCache())
+	vip.Set(ResponseCacheKey, map[string]any{"cleanup_interval": "0s"})
+	assert.ErrorContains(t, validateResponseCache(), "cleanup_interval must be positive")
+}
+
+func Test_validateServiceRetryPolicies(t *testing.T) {
+	defer vip.Set(ServiceRetryPoliciesKey, vip.Get(ServiceRetryPoliciesKey))
+
+	vip.Set(ServiceRetryPoliciesKey, []any{
+		map[string]any{"methods": []any{"/example_service.Calculator/add"}, "max_attempts": 3, "hedging_delay": "50ms"},
+		map[string]any{"methods": []any{"/example_service.Calculator/mul"}, "max_attempts": 2,
+			"retryable_status_codes": []any{"unavailable", "RESOURCE_EXHAUSTED"}, "initial_backoff": "10ms"},
+	})
+	policies, err := GetServiceRetryPolicies()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 2)
+	assert.Equal(t, []string{"UNAVAILABLE"}, policies[0].RetryableStatusCodes)
+	assert.Equal(t, 50*time.Millisecond, policies[0].HedgingDelay)
+	assert.Equal(t, 100*time.Millisecond, policies[0].InitialBackoff)
+	assert.Equal(t, time.Second, policies[0].MaxBackoff)
+	assert.Equal(t, float64(2), policies[0].BackoffMultiplier)
+	assert.Equal(t, 10*time.Millisecond, policies[1].InitialBackoff)
+	assert.Nil(t, validateServiceRetryPolicies())
+
+	vip.Set(ServiceRetryPoliciesKey, []any{map[string]any{"methods": []any{"add"}, "max_attempts": 2}})
+	assert.NotNil(t, validateServiceRetryPolicies())
+	vip.Set(ServiceRetryPoliciesKey, []any{map[string]any{"methods": []any{"/example_service.Calculator/add"}}})
+	assert.NotNil(t, validateServiceRetryPolicies())
+	vip.Set(ServiceRetryPoliciesKey, []any{map[string]any{"methods": []any{"/example_service.Calculator/add"}, "max_attempts": 2,
+		"retryable_status_codes": []any{"BROKEN"}}})
+	assert.NotNil(t, validateServiceRetryPolicies())
+	vip.Set(ServiceRetryPoliciesKey, []any{map[string]any{"methods": []any{"/example_service.Calculator/add"}, "max_attempts": 2,
+		"backoff_multiplier": 0.5}})
+	assert.NotNil(t, validateServiceRetryPolicies())
+}
+
+func Test_validateModelTrainingJobs(t *testing.T) {
+	defer vip.Set(ModelTrainingJobsKey, vip.Get(ModelTrainingJobsKey))
+	defer vip.Set(ModelTrainingEnabled, vip.Get(ModelTrainingEnabled))
+	vip.Set(ModelTrainingEnabled, true)
+
+	settings, err := GetModelTrainingJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, settings.Workers)
+	assert.Equal(t, 3*time.Second, settings.PollInterval)
+	assert.Equal(t, 5*time.Minute, settings.MaxPollInterval)
+	assert.Equal(t, 100, settings.HistorySize)
+	assert.Nil(t, validateModelTrainingJobs())
+
+	vip.Set(ModelTrainingJobsKey, map[string]any{"workers": 0})
+	assert.NotNil(t, validateModelTrainingJobs())
+	vip.Set(ModelTrainingJobsKey, map[string]any{"poll_interval": "0s"})
+	assert.NotNil(t, validateModelTrainingJobs())
+	vip.Set(ModelTrainingJobsKey, map[string]any{"poll_interval": "10m"})
+	assert.NotNil(t, validateModelTrainingJobs())
+
+	// the settings aren't used when the training is disabled
+	vip.Set(ModelTrainingEnabled, false)
+	assert.Nil(t, validateModelTrainingJobs())
+
+	// the config without the defaults gets the default settings
+	defer SetVip(vip)
+	SetVip(viper.New())
+	vip.Set(ModelTrainingEnabled, true)
+	settings, err = GetModelTrainingJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, settings.Workers)
+	assert.Nil(t, validateModelTrainingJobs())
+}
+
+func Test_validateModelTrainingBilling(t *testing.T) {
+	defer vip.Set(ModelTrainingBillingKey, vip.Get(ModelTrainingBillingKey))
+
+	settings, err := GetModelTrainingBilling()
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, settings.ErroredChargeRatio)
+	assert.Nil(t, validateModelTrainingBilling())
+
+	vip.Set(ModelTrainingBillingKey, map[string]any{"errored_charge_ratio": 0.25})
+	assert.Nil(t, validateModelTrainingBilling())
+	vip.Set(ModelTrainingBillingKey, map[string]any{"errored_charge_ratio": 1.5})
+	assert.NotNil(t, validateModelTrainingBilling())
+	vip.Set(ModelTrainingBillingKey, map[string]any{"errored_charge_ratio": -0.1})
+	assert.NotNil(t, validateModelTrainingBilling())
+}
+
+func Test_validateModelTrainingDatasets(t *testing.T) {
+	defer vip.Set(ModelTrainingDatasetsKey, vip.Get(ModelTrainingDatasetsKey))
+
+	settings, err := GetModelTrainingDatasets()
+	assert.Nil(t, err)
+	assert.Equal(t, "", settings.Storage)
+	assert.Equal(t, 24*time.Hour, settings.UploadTTL)
+	assert.Nil(t, validateModelTrainingDatasets())
+
+	vip.Set(ModelTrainingDatasetsKey, map[string]any{"storage": "ipfs", "dir": "/tmp/datasets", "upload_ttl": "1h"})
+	assert.Nil(t, validateModelTrainingDatasets())
+	vip.Set(ModelTrainingDatasetsKey, map[string]any{"storage": "s3", "dir": "/tmp/datasets", "upload_ttl": "1h"})
+	assert.NotNil(t, validateModelTrainingDatasets())
+	vip.Set(ModelTrainingDatasetsKey, map[string]any{"storage": "local", "dir": "", "upload_ttl": "1h"})
+	assert.NotNil(t, validateModelTrainingDatasets())
+	vip.Set(ModelTrainingDatasetsKey, map[string]any{"storage": "local", "dir": "/tmp/datasets", "upload_ttl": "0s"})
+	assert.NotNil(t, validateModelTrainingDatasets())
+}
+
+func Test_validateSSLClientAuth(t *testing.T) {
+	defer vip.Set(SSLClientAuthKey, vip.Get(SSLClientAuthKey))
+	defer vip.Set(SSLCertPathKey, vip.Get(SSLCertPathKey))
+
+	settings, err := GetSSLClientAuth()
+	assert.Nil(t, err)
+	assert.False(t, settings.Enabled)
+	assert.Equal(t, SSLClientAuthRequire, settings.Mode)
+	assert.Equal(t, time.Minute, settings.CRLReloadInterval)
+	assert.Nil(t, validateSSLClientAuth())
+
+	vip.Set(SSLCertPathKey, "")
+	vip.Set(SSLClientAuthKey, map[string]any{"enabled": true, "mode": "require", "ca": "ca.pem"})
+	assert.NotNil(t, validateSSLClientAuth())
+
+	vip.Set(SSLCertPathKey, "cert.pem")
+	assert.Nil(t, validateSSLClientAuth())
+	vip.Set(SSLClientAuthKey, map[string]any{"enabled": true, "mode": "request", "ca": "ca.pem"})
+	assert.NotNil(t, validateSSLClientAuth())
+	vip.Set(SSLClientAuthKey, map[string]any{"enabled": true, "mode": "verify_if_given", "ca": ""})
+	assert.NotNil(t, validateSSLClientAuth())
+	vip.Set(SSLClientAuthKey, map[string]any{"enabled": true, "mode": "require", "ca": "ca.pem",
+		"crl": "crl.pem", "crl_reload_interval": "0s"})
+	assert.NotNil(t, validateSSLClientAuth())
+	vip.Set(SSLClientAuthKey, map[string]any{"enabled": true, "mode": "require", "ca": "ca.pem",
+		"trusted_identities": []string{"partner.example.com", ""}})
+	assert.NotNil(t, validateSSLClientAuth())
+}
+
+func Test_validateSSLCertificates(t *testing.T) {
+	defer vip.Set(SSLCertPathKey, vip.Get(SSLCertPathKey))
+	defer vip.Set(SSLKeyPathKey, vip.Get(SSLKeyPathKey))
+	defer vip.Set(SSLCertificatesKey, vip.Get(SSLCertificatesKey))
+	defer vip.Set(SSLCertReloadKey, vip.Get(SSLCertReloadKey))
+	defer vip.Set(AutoSSLCacheStorageKey, vip.Get(AutoSSLCacheStorageKey))
+
+	settings, err := GetSSLCertReload()
+	assert.Nil(t, err)
+	assert.True(t, settings.Watch)
+	assert.Equal(t, 30*time.Second, settings.PollInterval)
+	assert.Equal(t, 720*time.Hour, settings.ExpiryWarning)
+	assert.Nil(t, validateSSLCertificates())
+
+	vip.Set(SSLCertPathKey, "")
+	vip.Set(SSLKeyPathKey, "")
+	vip.Set(SSLCertificatesKey, []map[string]any{{"cert": "b.pem", "key": "b.key"}})
+	assert.NotNil(t, validateSSLCertificates())
+
+	vip.Set(SSLCertPathKey, "a.pem")
+	vip.Set(SSLKeyPathKey, "a.key")
+	assert.Nil(t, validateSSLCertificates())
+	certificates, err := GetSSLCertificates()
+	assert.Nil(t, err)
+	assert.Equal(t, []SSLCertificate{{Cert: "a.pem", Key: "a.key"}, {Cert: "b.pem", Key: "b.key"}}, certificates)
+
+	vip.Set(SSLCertificatesKey, []map[string]any{{"cert": "b.pem"}})
+	assert.NotNil(t, validateSSLCertificates())
+	vip.Set(SSLCertificatesKey, []map[string]any{})
+
+	vip.Set(SSLCertReloadKey, map[string]any{"watch": false, "poll_interval": "0s", "expiry_warning": "1h"})
+	assert.NotNil(t, validateSSLCertificates())
+	vip.Set(SSLCertReloadKey, map[string]any{"watch": false, "poll_interval": "1s", "expiry_warning": "1h"})
+	assert.Nil(t, validateSSLCertificates())
+
+	vip.Set(AutoSSLCacheStorageKey, "s3")
+	assert.NotNil(t, validateSSLCertificates())
+
+	// the reload settings aren't used without SSL
+	vip.Set(SSLCertPathKey, "")
+	vip.Set(SSLKeyPathKey, "")
+	vip.Set(SSLCertReloadKey, map[string]any{"poll_interval": "0s"})
+	assert.Nil(t, validateSSLCertificates())
+
+	// the config without the defaults gets the default settings
+	defer SetVip(vip)
+	SetVip(viper.New())
+	settings, err = GetSSLCertReload()
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, settings.PollInterval)
+	assert.Nil(t, validateSSLCertificates())
+}
+
+func TestGetAutoSSLDomains(t *testing.T) {
+	defer vip.Set(AutoSSLDomainKey, vip.Get(AutoSSLDomainKey))
+
+	assert.Nil(t, GetAutoSSLDomains())
+	vip.Set(AutoSSLDomainKey, "a.example.com, b.example.com,")
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, GetAutoSSLDomains())
+}
+
+func TestDeprecatedParamsUsed(t *testing.T) {
+	v := viper.New()
+	assert.Empty(t, DeprecatedParamsUsed(v))
+
+	v.Set("daemon_end_point", "127.0.0.1:8080")
+	v.Set("pvt_key_for_free_calls", "key")
+	assert.Equal(t, map[string]string{"daemon_end_point": DaemonEndpoint, "pvt_key_for_free_calls": PvtKeyForFreeCalls},
+		DeprecatedParamsUsed(v))
+
+	migrateDeprecatedParams(v)
+	assert.Equal(t, "127.0.0.1:8080", v.GetString(DaemonEndpoint))
+	assert.Equal(t, "key", v.GetString(PvtKeyForFreeCalls))
+}
+
+func TestValidateAll(t *testing.T) {
+	defer vip.Set(DaemonTypeKey, vip.Get(DaemonTypeKey))
+	defer vip.Set(MaxMessageSizeInMB, vip.Get(MaxMessageSizeInMB))
+
+	vip.Set(DaemonTypeKey, "unknown")
+	vip.Set(MaxMessageSizeInMB, 4096)
+	failed := map[string]bool{}
+	for _, result := range ValidateAll() {
+		failed[result.Name] = result.Err != nil
+	}
+	assert.Len(t, failed, len(validators()))
+	assert.True(t, failed["daemon type"])
+	assert.True(t, failed["max message size"])
+	assert.NotNil(t, Validate())
+}
@@ -0,0 +1,382 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// RedactedSecret replaces the plain secrets in the logs and in the configuration service
+const RedactedSecret = "[REDACTED]"
+
+// SecretProvider resolves the secret references of one scheme, the reference
+// passed to the provider is the config value without the "<scheme>://" prefix
+type SecretProvider interface {
+	Resolve(ctx context.Context, reference string) ([]byte, error)
+}
+
+// SecretProviderFunc is the SecretProvider implemented by the function
+type SecretProviderFunc func(ctx context.Context, reference string) ([]byte, error)
+
+func (resolve SecretProviderFunc) Resolve(ctx context.Context, reference string) ([]byte, error) {
+	return resolve(ctx, reference)
+}
+
+// SecretsSettings configures the resolution of the secret references
+// ReloadInterval - the secrets are resolved again after this time, "0s" keeps them until the daemon gets SIGHUP
+// ExecTimeout    - timeout of the commands of the exec:// references
+type SecretsSettings struct {
+	ReloadInterval time.Duration `json:"reload_interval" mapstructure:"reload_interval"`
+	ExecTimeout    time.Duration `json:"exec_timeout" mapstructure:"exec_timeout"`
+}
+
+// GetSecretsSettings returns the secrets settings
+func GetSecretsSettings() (settings *SecretsSettings, err error) {
+	settings = &SecretsSettings{ExecTimeout: 10 * time.Second}
+	subVip := SubWithDefault(vip, SecretsKey)
+	if subVip == nil {
+		return settings, nil
+	}
+	if err = subVip.Unmarshal(settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SecretsKey, err)
+	}
+	return settings, nil
+}
+
+func validateSecrets() error {
+	settings, err := GetSecretsSettings()
+	if err != nil {
+		return err
+	}
+	if settings.ReloadInterval < 0 {
+		return fmt.Errorf("%s reload_interval can't be negative", SecretsKey)
+	}
+	if settings.ExecTimeout <= 0 {
+		return fmt.Errorf("%s exec_timeout must be positive", SecretsKey)
+	}
+	for _, key := range []string{PvtKeyForFreeCalls, PvtKeyForMetering, TokenSecretKey,
+		PaymentChannelCertPath, PaymentChannelKeyPath, PaymentChannelCaPath} {
+		if _, err = GetSecret(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretKeys are the keys whose plain values are redacted, the secret
+// references and the paths of the etcd TLS files are shown as they are
+var secretKeys = map[string]bool{
+	PvtKeyForFreeCalls:    true,
+	PvtKeyForMetering:     true,
+	TokenSecretKey:        true,
+	ServiceCredentialsKey: true,
+}
+
+type resolvedSecret struct {
+	value      []byte
+	resolvedAt time.Time
+	// stale is set by ReloadSecrets, the secret is resolved again on the next use
+	stale bool
+}
+
+var (
+	secretsMutex    sync.RWMutex
+	secretProviders = map[string]SecretProvider{}
+	resolvedSecrets = map[string]resolvedSecret{}
+	// secretsGroup resolves each reference once for its concurrent uses
+	secretsGroup singleflight.Group
+)
+
+func init() {
+	RegisterSecretProvider("file", SecretProviderFunc(resolveFileSecret))
+	RegisterSecretProvider("env", SecretProviderFunc(resolveEnvSecret))
+	RegisterSecretProvider("exec", SecretProviderFunc(resolveExecSecret))
+	RegisterSecretProvider("keystore", SecretProviderFunc(resolveKeystoreSecret))
+}
+
+// RegisterSecretProvider registers the provider of the scheme, e.g. of the
+// secret manager of the cloud, the provider of the same scheme is replaced
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	secretProviders[scheme] = provider
+}
+
+func secretProvider(value string) (provider SecretProvider, reference string, ok bool) {
+	scheme, reference, found := strings.Cut(value, "://")
+	if !found {
+		return nil, "", false
+	}
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	provider, ok = secretProviders[scheme]
+	return provider, reference, ok
+}
+
+// IsSecretReference checks the value is the reference of the registered secret provider
+func IsSecretReference(value string) bool {
+	_, _, ok := secretProvider(value)
+	return ok
+}
+
+// ResolveSecret returns the secret of the reference, the value which isn't
+// a reference is returned as it is. The resolved secrets are cached until
+// ReloadSecrets or until reload_interval of the secrets settings passes, the
+// cached secret is kept when it can't be resolved again.
+func ResolveSecret(value string) (string, error) {
+	secret, err := resolveSecret(value)
+	return string(secret), err
+}
+
+func resolveSecret(value string) ([]byte, error) {
+	provider, reference, ok := secretProvider(value)
+	if !ok {
+		return []byte(value), nil
+	}
+	settings, err := GetSecretsSettings()
+	if err != nil {
+		return nil, err
+	}
+	secretsMutex.RLock()
+	resolved, cached := resolvedSecrets[value]
+	secretsMutex.RUnlock()
+	if cached && !resolved.stale && (settings.ReloadInterval == 0 || time.Since(resolved.resolvedAt) < settings.ReloadInterval) {
+		return resolved.value, nil
+	}
+
+	secret, err, _ := secretsGroup.Do(value, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), settings.ExecTimeout)
+		defer cancel()
+		secret, err := provider.Resolve(ctx, reference)
+		secretsMutex.Lock()
+		defer secretsMutex.Unlock()
+		if err != nil {
+			if cached {
+				// the failed refresh is retried after reload_interval or SIGHUP
+				resolvedSecrets[value] = resolvedSecret{value: resolved.value, resolvedAt: time.Now()}
+			}
+			return nil, err
+		}
+		resolvedSecrets[value] = resolvedSecret{value: secret, resolvedAt: time.Now()}
+		return secret, nil
+	})
+	if err != nil {
+		if cached {
+			zap.L().Warn("can't resolve secret again, the cached secret is used", zap.String("secret", value), zap.Error(err))
+			return resolved.value, nil
+		}
+		return nil, fmt.Errorf("can't resolve secret %v: %v", value, err)
+	}
+	return secret.([]byte), nil
+}
+
+// ReloadSecrets makes the resolved secrets be resolved again on the next use,
+// the cached secret is kept while its reference can't be resolved
+func ReloadSecrets() {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for value, resolved := range resolvedSecrets {
+		resolved.stale = true
+		resolvedSecrets[value] = resolved
+	}
+}
+
+// GetSecret returns the value of the key, its secret reference is resolved
+func GetSecret(key string) (string, error) {
+	secret, err := ResolveSecret(GetString(key))
+	if err != nil {
+		return "", fmt.Errorf("invalid %v: %v", key, err)
+	}
+	return secret, nil
+}
+
+// GetSecretFile returns the content of the file of the key: the resolved
+// secret reference or the content of the file at the plain path
+func GetSecretFile(key string) ([]byte, error) {
+	value := GetString(key)
+	if !IsSecretReference(value) {
+		return os.ReadFile(value)
+	}
+	content, err := resolveSecret(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v: %v", key, err)
+	}
+	return content, nil
+}
+
+// LoadX509KeyPair loads the certificate and the key of the keys, each of them
+// is the path or the secret reference of the PEM
+func LoadX509KeyPair(certKey, keyKey string) (tls.Certificate, error) {
+	certPEM, err := GetSecretFile(certKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := GetSecretFile(keyKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// RedactedValue returns the value of the key for the logs and the
+// configuration service, the plain values of the secret keys are redacted
+func RedactedValue(key string) any {
+	value := vip.Get(key)
+	if !secretKeys[strings.ToLower(key)] {
+		return value
+	}
+	if s, ok := value.(string); ok && (s == "" || IsSecretReference(s)) {
+		return s
+	}
+	if credentials, ok := value.([]any); ok {
+		redacted := make([]any, 0, len(credentials))
+		for _, credential := range credentials {
+			redacted = append(redacted, redactCredential(credential))
+		}
+		return redacted
+	}
+	return RedactedSecret
+}
+
+// RedactedString returns RedactedValue of the key as the string, the lists
+// of the secret keys are returned as JSON
+func RedactedString(key string) string {
+	if !secretKeys[strings.ToLower(key)] {
+		return GetString(key)
+	}
+	value := RedactedValue(key)
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if bytes, err := json.Marshal(value); err == nil {
+		return string(bytes)
+	}
+	return RedactedSecret
+}
+
+// redactCredential redacts the value of the service credential unless it's the secret reference
+func redactCredential(credential any) any {
+	fields, ok := credential.(map[string]any)
+	if !ok {
+		return RedactedSecret
+	}
+	redacted := make(map[string]any, len(fields))
+	for field, value := range fields {
+		redacted[field] = value
+	}
+	if s, ok := redacted["value"].(string); !ok || !IsSecretReference(s) {
+		redacted["value"] = RedactedSecret
+	}
+	return redacted
+}
+
+// resolveFileSecret reads the file of file:///path/to/secret, the trailing newline is trimmed
+func resolveFileSecret(ctx context.Context, reference string) ([]byte, error) {
+	content, err := os.ReadFile(reference)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(content, "\r\n"), nil
+}
+
+// resolveEnvSecret reads the environment variable of env://NAME
+func resolveEnvSecret(ctx context.Context, reference string) ([]byte, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %v isn't set", reference)
+	}
+	return []byte(value), nil
+}
+
+// resolveExecSecret runs the command of exec://command arg... without the
+// shell and returns its output, the trailing newline is trimmed
+func resolveExecSecret(ctx context.Context, reference string) ([]byte, error) {
+	args := strings.Fields(reference)
+	if len(args) == 0 {
+		return nil, errors.New("command isn't set")
+	}
+	stderr := &bytes.Buffer{}
+	command := exec.CommandContext(ctx, args[0], args[1:]...)
+	command.Stderr = stderr
+	output, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command %v failed: %v %v", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return bytes.TrimRight(output, "\r\n"), nil
+}
+
+// resolveKeystoreSecret decrypts the keystore of
+// keystore:///path/to/keystore.json?passphrase=<secret reference>. The geth
+// keystore of the signing key is resolved to the hex private key, the
+// encrypted data keystore created by EncryptSecret is resolved to the data.
+func resolveKeystoreSecret(ctx context.Context, reference string) ([]byte, error) {
+	path, rawQuery, _ := strings.Cut(reference, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	passphraseReference := query.Get("passphrase")
+	if !IsSecretReference(passphraseReference) {
+		return nil, errors.New("passphrase of the keystore must be the secret reference, e.g. passphrase=env://KEYSTORE_PASSPHRASE")
+	}
+	passphrase, err := ResolveSecret(passphraseReference)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decryptKeystore(content, passphrase)
+}
+
+func decryptKeystore(content []byte, passphrase string) ([]byte, error) {
+	var keyJSON struct {
+		Crypto *keystore.CryptoJSON `json:"crypto"`
+	}
+	if err := json.Unmarshal(content, &keyJSON); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	if keyJSON.Crypto != nil {
+		key, err := keystore.DecryptKey(content, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(hex.EncodeToString(crypto.FromECDSA(key.PrivateKey))), nil
+	}
+	var cryptoJSON keystore.CryptoJSON
+	if err := json.Unmarshal(content, &cryptoJSON); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	return keystore.DecryptDataV3(cryptoJSON, passphrase)
+}
+
+// EncryptSecret returns the keystore of the secret which is resolved by the
+// keystore:// reference, the hex private key is encrypted to the geth keystore
+// readable by the Ethereum tools, other secrets to the encrypted data
+func EncryptSecret(secret []byte, passphrase string) ([]byte, error) {
+	if privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(string(secret), "0x")); err == nil {
+		key := &keystore.Key{PrivateKey: privateKey, Address: crypto.PubkeyToAddress(privateKey.PublicKey)}
+		return keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+	}
+	cryptoJSON, err := keystore.EncryptDataV3(secret, []byte(passphrase), keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(cryptoJSON, "", "  ")
+}
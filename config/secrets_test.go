@@ -0,0 +1,216 @@
+package config
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecretPrivateKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+func TestResolveSecret(t *testing.T) {
+	ReloadSecrets()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret")
+	require.Nil(t, os.WriteFile(file, []byte("file-secret\n"), 0600))
+	t.Setenv("SNETD_TEST_SECRET", "env-secret")
+
+	for value, expected := range map[string]string{
+		"plain-secret":              "plain-secret",
+		"file://" + file:            "file-secret",
+		"env://SNETD_TEST_SECRET":   "env-secret",
+		"exec://echo exec-secret":   "exec-secret",
+		"https://example.com/value": "https://example.com/value",
+	} {
+		secret, err := ResolveSecret(value)
+		require.Nil(t, err, value)
+		assert.Equal(t, expected, secret, value)
+	}
+
+	_, err := ResolveSecret("env://SNETD_TEST_UNSET_SECRET")
+	assert.ErrorContains(t, err, "environment variable SNETD_TEST_UNSET_SECRET isn't set")
+	_, err = ResolveSecret("file://" + filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+	_, err = ResolveSecret("exec://false")
+	assert.ErrorContains(t, err, "command false failed")
+}
+
+func TestReloadSecrets(t *testing.T) {
+	ReloadSecrets()
+	file := filepath.Join(t.TempDir(), "secret")
+	require.Nil(t, os.WriteFile(file, []byte("old"), 0600))
+	vip.Set(TokenSecretKey, "file://"+file)
+	defer vip.Set(TokenSecretKey, "")
+
+	secret, err := GetSecret(TokenSecretKey)
+	require.Nil(t, err)
+	assert.Equal(t, "old", secret)
+
+	require.Nil(t, os.WriteFile(file, []byte("new"), 0600))
+	secret, _ = GetSecret(TokenSecretKey)
+	assert.Equal(t, "old", secret)
+
+	ReloadSecrets()
+	secret, _ = GetSecret(TokenSecretKey)
+	assert.Equal(t, "new", secret)
+
+	vip.Set(SecretsKey+".reload_interval", "1ns")
+	defer vip.Set(SecretsKey+".reload_interval", "0s")
+	require.Nil(t, os.WriteFile(file, []byte("rotated"), 0600))
+	secret, _ = GetSecret(TokenSecretKey)
+	assert.Equal(t, "rotated", secret)
+}
+
+func TestRegisterSecretProvider(t *testing.T) {
+	ReloadSecrets()
+	assert.False(t, IsSecretReference("vault://secret/daemon"))
+	RegisterSecretProvider("vault", SecretProviderFunc(func(ctx context.Context, reference string) ([]byte, error) {
+		return []byte("vault:" + reference), nil
+	}))
+	assert.True(t, IsSecretReference("vault://secret/daemon"))
+
+	secret, err := ResolveSecret("vault://secret/daemon")
+	require.Nil(t, err)
+	assert.Equal(t, "vault:secret/daemon", secret)
+}
+
+func TestGetSecretsSettings(t *testing.T) {
+	settings, err := GetSecretsSettings()
+	require.Nil(t, err)
+	assert.Equal(t, 10*time.Second, settings.ExecTimeout)
+
+	// the config without the defaults gets the default settings
+	defer SetVip(vip)
+	SetVip(viper.New())
+	settings, err = GetSecretsSettings()
+	require.Nil(t, err)
+	assert.Equal(t, 10*time.Second, settings.ExecTimeout)
+	assert.Nil(t, validateSecrets())
+}
+
+func TestResolveSecretOnce(t *testing.T) {
+	ReloadSecrets()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	RegisterSecretProvider("slow", SecretProviderFunc(func(ctx context.Context, reference string) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("slow-secret"), nil
+	}))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			secret, err := ResolveSecret("slow://once")
+			assert.Nil(t, err)
+			assert.Equal(t, "slow-secret", secret)
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestResolveSecretFailedRefresh(t *testing.T) {
+	ReloadSecrets()
+	var failed atomic.Bool
+	RegisterSecretProvider("flaky", SecretProviderFunc(func(ctx context.Context, reference string) ([]byte, error) {
+		if failed.Load() {
+			return nil, errors.New("provider is unavailable")
+		}
+		return []byte("flaky-secret"), nil
+	}))
+
+	secret, err := ResolveSecret("flaky://secret")
+	require.Nil(t, err)
+	assert.Equal(t, "flaky-secret", secret)
+
+	// the cached secret is served while the refresh fails
+	failed.Store(true)
+	ReloadSecrets()
+	secret, err = ResolveSecret("flaky://secret")
+	require.Nil(t, err)
+	assert.Equal(t, "flaky-secret", secret)
+
+	_, err = ResolveSecret("flaky://other")
+	assert.ErrorContains(t, err, "provider is unavailable")
+}
+
+func TestKeystoreSecret(t *testing.T) {
+	ReloadSecrets()
+	dir := t.TempDir()
+	t.Setenv("SNETD_TEST_PASSPHRASE", "passphrase")
+
+	privateKey, err := crypto.HexToECDSA(testSecretPrivateKey)
+	require.Nil(t, err)
+	key := &keystore.Key{PrivateKey: privateKey, Address: crypto.PubkeyToAddress(privateKey.PublicKey)}
+	keyJSON, err := keystore.EncryptKey(key, "passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	require.Nil(t, err)
+	keyFile := filepath.Join(dir, "key.json")
+	require.Nil(t, os.WriteFile(keyFile, keyJSON, 0600))
+
+	secret, err := ResolveSecret("keystore://" + keyFile + "?passphrase=env://SNETD_TEST_PASSPHRASE")
+	require.Nil(t, err)
+	assert.Equal(t, testSecretPrivateKey, secret)
+
+	cryptoJSON, err := keystore.EncryptDataV3([]byte("token-secret"), []byte("passphrase"), keystore.LightScryptN, keystore.LightScryptP)
+	require.Nil(t, err)
+	dataJSON, err := json.Marshal(cryptoJSON)
+	require.Nil(t, err)
+	dataFile := filepath.Join(dir, "data.json")
+	require.Nil(t, os.WriteFile(dataFile, dataJSON, 0600))
+
+	secret, err = ResolveSecret("keystore://" + dataFile + "?passphrase=env://SNETD_TEST_PASSPHRASE")
+	require.Nil(t, err)
+	assert.Equal(t, "token-secret", secret)
+
+	_, err = ResolveSecret("keystore://" + keyFile + "?passphrase=passphrase")
+	assert.ErrorContains(t, err, "passphrase of the keystore must be the secret reference")
+	t.Setenv("SNETD_TEST_WRONG_PASSPHRASE", "wrong")
+	_, err = ResolveSecret("keystore://" + keyFile + "?passphrase=env://SNETD_TEST_WRONG_PASSPHRASE")
+	assert.ErrorContains(t, err, "could not decrypt key with given password")
+}
+
+func TestEncryptSecret(t *testing.T) {
+	keyJSON, err := EncryptSecret([]byte("0x"+testSecretPrivateKey), "passphrase")
+	require.Nil(t, err)
+	key, err := keystore.DecryptKey(keyJSON, "passphrase")
+	require.Nil(t, err)
+	assert.Equal(t, testSecretPrivateKey, hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)))
+}
+
+func TestRedactedValue(t *testing.T) {
+	defer func() {
+		vip.Set(PvtKeyForFreeCalls, "")
+		vip.Set(ServiceCredentialsKey, nil)
+	}()
+
+	vip.Set(PvtKeyForFreeCalls, testSecretPrivateKey)
+	assert.Equal(t, RedactedSecret, RedactedValue(PvtKeyForFreeCalls))
+	vip.Set(PvtKeyForFreeCalls, "file:///run/secrets/free_call_key")
+	assert.Equal(t, "file:///run/secrets/free_call_key", RedactedValue(PvtKeyForFreeCalls))
+	vip.Set(PvtKeyForFreeCalls, "")
+	assert.Equal(t, "", RedactedValue(PvtKeyForFreeCalls))
+	assert.Equal(t, vip.Get(DaemonEndpoint), RedactedValue(DaemonEndpoint))
+
+	vip.Set(ServiceCredentialsKey, []any{
+		map[string]any{"key": "api_key", "value": "plain", "location": "header"},
+		map[string]any{"key": "token", "value": "env://SERVICE_TOKEN", "location": "query"},
+	})
+	assert.Equal(t, `[{"key":"api_key","location":"header","value":"[REDACTED]"},`+
+		`{"key":"token","location":"query","value":"env://SERVICE_TOKEN"}]`, RedactedString(ServiceCredentialsKey))
+}
@@ -225,7 +225,7 @@ func getCurrentConfig() map[string]string {
 	sort.Strings(keys)
 	for _, key := range keys {
 		if config.DisplayKeys[strings.ToUpper(key)] {
-			currentConfigMap[key] = config.GetString(key)
+			currentConfigMap[key] = config.RedactedString(key)
 		}
 
 	}
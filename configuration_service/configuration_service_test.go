@@ -101,7 +101,11 @@ func Test_getCurrentConfig(t *testing.T) {
 	assert.NotEmpty(t, currentConfig[config.DaemonEndpoint])
 	config.Vip().Set(config.PvtKeyForMetering, "HIDDEN")
 	currentConfig = getCurrentConfig()
-	assert.Empty(t, currentConfig[config.PvtKeyForMetering])
+	assert.Equal(t, config.RedactedSecret, currentConfig[config.PvtKeyForMetering])
+	config.Vip().Set(config.PvtKeyForMetering, "env://METERING_KEY")
+	currentConfig = getCurrentConfig()
+	assert.Equal(t, "env://METERING_KEY", currentConfig[config.PvtKeyForMetering])
+	config.Vip().Set(config.PvtKeyForMetering, "")
 }
 
 func TestConfigurationService_buildSchemaDetails(t *testing.T) {
@@ -2,4 +2,14 @@ package ctxkeys
 
 type ContextKey string
 
-const MethodKey ContextKey = "method"
+const (
+	MethodKey ContextKey = "method"
+	// PaymentTypeKey holds the type of the payment handler which accepted the call
+	PaymentTypeKey ContextKey = "payment-type"
+	// SenderKey holds the sender address verified by the payment handler
+	SenderKey ContextKey = "sender"
+	// ChargedAmountKey holds the amount of cogs charged for the call
+	ChargedAmountKey ContextKey = "charged-amount"
+	// ResponseCacheKey holds the response cache lookup of the call
+	ResponseCacheKey ContextKey = "response-cache"
+)
@@ -0,0 +1,271 @@
+//go:generate go run ../resources/generate-error-reference/main.go
+
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domains of the daemon errors, the domain and the reason of the error
+// identify it in the google.rpc.ErrorInfo detail
+const (
+	DomainDaemon      = "daemon"
+	DomainPayment     = "payment"
+	DomainFreeCall    = "free_call"
+	DomainTraining    = "training"
+	DomainLicense     = "license"
+	DomainPassthrough = "passthrough"
+)
+
+// IncorrectNonceCode is the custom gRPC code returned when the payment has the
+// incorrect nonce, the client gets the latest nonce from PaymentChannelStateService
+const IncorrectNonceCode codes.Code = 1000
+
+// Definition is the entry of the error catalogue. The reason and the domain
+// are stable, the clients handle the errors by them instead of the messages.
+type Definition struct {
+	Reason      string
+	Domain      string
+	Code        codes.Code
+	Description string
+	// DocCode is the number of the error on the dev portal, 0 if the error has no page
+	DocCode int
+}
+
+var catalogue []*Definition
+
+func define(domain, reason string, code codes.Code, docCode int, description string) *Definition {
+	definition := &Definition{Reason: reason, Domain: domain, Code: code, Description: description, DocCode: docCode}
+	catalogue = append(catalogue, definition)
+	return definition
+}
+
+// Daemon errors
+var (
+	ErrInvalidConfig             = define(DomainDaemon, "INVALID_CONFIG", codes.FailedPrecondition, InvalidConfig, "the daemon configuration is invalid")
+	ErrInvalidMetadata           = define(DomainDaemon, "INVALID_METADATA", codes.FailedPrecondition, InvalidMetadata, "the service or organization metadata can't be read")
+	ErrInvalidServiceCredentials = define(DomainDaemon, "INVALID_SERVICE_CREDENTIALS", codes.FailedPrecondition, InvalidServiceCredentials, "the credentials of the service are invalid")
+	ErrProviderLimitsExceeded    = define(DomainDaemon, "BLOCKCHAIN_PROVIDER_LIMITS_EXCEEDED", codes.Unavailable, BlockchainProviderLimitsExceed, "the blockchain provider rejects the requests of the daemon")
+	ErrMissingMetadata           = define(DomainDaemon, "MISSING_METADATA", codes.InvalidArgument, 0, "the request has no gRPC metadata")
+	ErrConcurrencyLimit          = define(DomainDaemon, "CONCURRENCY_LIMIT_REACHED", codes.ResourceExhausted, 0, "too many calls to the service are in flight")
+	ErrRateLimited               = define(DomainDaemon, "RATE_LIMITED", codes.ResourceExhausted, 0, "the daemon rate limit is reached")
+	ErrRequestsStopped           = define(DomainDaemon, "REQUESTS_STOPPED", codes.Unavailable, 0, "the daemon is told to stop processing the requests by the configuration service")
+)
+
+// Payment errors
+var (
+	ErrUnknownPaymentType   = define(DomainPayment, "UNKNOWN_PAYMENT_TYPE", codes.InvalidArgument, 0, "the snet-payment-type header has an unsupported value")
+	ErrMissingPaymentHeader = define(DomainPayment, "MISSING_PAYMENT_HEADER", codes.InvalidArgument, 0, "the payment header is missing or repeated")
+	ErrInvalidPaymentHeader = define(DomainPayment, "INVALID_PAYMENT_HEADER", codes.InvalidArgument, 0, "the payment header has an invalid format")
+	ErrPaymentInternal      = define(DomainPayment, "PAYMENT_INTERNAL", codes.Internal, 0, "the payment can't be processed because of the daemon failure")
+	ErrChannelNotFound      = define(DomainPayment, "CHANNEL_NOT_FOUND", codes.Unauthenticated, 0, "the payment channel is not found")
+	ErrChannelBusy          = define(DomainPayment, "CHANNEL_BUSY", codes.FailedPrecondition, 0, "another payment on the channel is in progress")
+	ErrIncorrectNonce       = define(DomainPayment, "INCORRECT_NONCE", IncorrectNonceCode, 0, "the nonce of the payment isn't the latest nonce of the channel")
+	ErrInvalidSignature     = define(DomainPayment, "INVALID_SIGNATURE", codes.Unauthenticated, 0, "the signature of the payment is invalid")
+	ErrInvalidSigner        = define(DomainPayment, "INVALID_SIGNER", codes.Unauthenticated, 0, "the payment isn't signed by the channel sender or signer")
+	ErrChannelExpiring      = define(DomainPayment, "CHANNEL_EXPIRING", codes.Unauthenticated, 0, "the payment channel expires within the expiration threshold")
+	ErrInsufficientFunds    = define(DomainPayment, "INSUFFICIENT_FUNDS", codes.Unauthenticated, 0, "the payment amount exceeds the funds of the channel")
+	ErrIncomeMismatch       = define(DomainPayment, "INCOME_MISMATCH", codes.Unauthenticated, 0, "the payment amount doesn't match the price of the call")
+	ErrBlockUnavailable     = define(DomainPayment, "BLOCK_NUMBER_UNAVAILABLE", codes.Internal, 0, "the current block number can't be read")
+	ErrMeteringFailed       = define(DomainPayment, "METERING_FAILED", codes.Internal, 0, "the channel state can't be published to the metering endpoint")
+	ErrNotAllowedUser       = define(DomainPayment, "NOT_ALLOWED_USER", codes.PermissionDenied, 0, "the caller isn't allowed to call the service during its curation")
+	ErrInvalidAddress       = define(DomainPayment, "INVALID_ADDRESS", codes.InvalidArgument, 0, "the address of the caller is not a valid hex address")
+	ErrClientCertRequired   = define(DomainPayment, "CLIENT_CERTIFICATE_REQUIRED", codes.Unauthenticated, 0, "the call requires the client certificate")
+	ErrClientCertUntrusted  = define(DomainPayment, "CLIENT_CERTIFICATE_UNTRUSTED", codes.PermissionDenied, 0, "the client certificate identity is not trusted")
+)
+
+// Free call errors
+var (
+	ErrFreeCallInternal      = define(DomainFreeCall, "FREE_CALL_INTERNAL", codes.Internal, 0, "the free call can't be processed because of the daemon failure")
+	ErrFreeCallUserNotFound  = define(DomainFreeCall, "FREE_CALL_USER_NOT_FOUND", codes.Unauthenticated, 0, "the free call user is not found")
+	ErrFreeCallUserBusy      = define(DomainFreeCall, "FREE_CALL_USER_BUSY", codes.FailedPrecondition, 0, "another free call of the user is in progress")
+	ErrFreeCallsExhausted    = define(DomainFreeCall, "FREE_CALLS_EXHAUSTED", codes.ResourceExhausted, 0, "the user made all the free calls allowed")
+	ErrInvalidFreeCallToken  = define(DomainFreeCall, "INVALID_FREE_CALL_TOKEN", codes.InvalidArgument, 0, "the free call token is invalid or expired")
+	ErrFreeCallBalanceTooLow = define(DomainFreeCall, "BALANCE_TOO_LOW", codes.PermissionDenied, 0, "the balance of the user is below the minimum required for the free calls")
+)
+
+// Training errors
+var (
+	ErrTrainingInvalidRequest = define(DomainTraining, "INVALID_REQUEST", codes.InvalidArgument, 0, "the training request is invalid")
+	ErrTrainingUnauthorized   = define(DomainTraining, "UNAUTHORIZED", codes.Unauthenticated, 0, "the training request has no valid authorization")
+	ErrTrainingAccessDenied   = define(DomainTraining, "ACCESS_DENIED", codes.PermissionDenied, 0, "the caller has no access to the model")
+	ErrModelNotFound          = define(DomainTraining, "MODEL_NOT_FOUND", codes.NotFound, 0, "the model doesn't exist")
+	ErrModelVersionNotFound   = define(DomainTraining, "MODEL_VERSION_NOT_FOUND", codes.NotFound, 0, "the model version doesn't exist")
+	ErrInvalidModelVersion    = define(DomainTraining, "INVALID_MODEL_VERSION", codes.FailedPrecondition, 0, "the model version can't be used")
+	ErrModelUpdateFailed      = define(DomainTraining, "MODEL_UPDATE_FAILED", codes.FailedPrecondition, 0, "the model state can't be changed")
+	ErrDatasetUploadNotFound  = define(DomainTraining, "DATASET_UPLOAD_NOT_FOUND", codes.NotFound, 0, "the dataset upload doesn't exist or is expired")
+	ErrDatasetUploadFailed    = define(DomainTraining, "DATASET_UPLOAD_FAILED", codes.FailedPrecondition, 0, "the dataset upload is incomplete or doesn't meet the requirements")
+	ErrTrainingService        = define(DomainTraining, "SERVICE_ERROR", codes.Unavailable, 0, "the training call to the service failed")
+	ErrTrainingStorage        = define(DomainTraining, "STORAGE_ERROR", codes.Internal, 0, "the training data can't be read or written")
+	ErrTraining               = define(DomainTraining, "TRAINING_ERROR", codes.Unknown, 0, "the training request failed")
+)
+
+// License errors
+var (
+	ErrLicenseUsageExceeded = define(DomainLicense, "USAGE_EXCEEDED", codes.ResourceExhausted, 0, "the usage exceeds the planned usage of the license")
+	ErrLicenseUsageType     = define(DomainLicense, "UNKNOWN_USAGE_TYPE", codes.Internal, 0, "the license usage type is unknown")
+)
+
+// Passthrough errors
+var (
+	ErrServiceUnavailable   = define(DomainPassthrough, "SERVICE_UNAVAILABLE", codes.Unavailable, ServiceUnavailable, "the daemon can't connect to the service")
+	ErrServiceCallFailed    = define(DomainPassthrough, "SERVICE_CALL_FAILED", codes.Internal, ServiceUnavailable, "the call to the service failed")
+	ErrInvalidProto         = define(DomainPassthrough, "INVALID_PROTO", codes.Internal, InvalidProto, "the message can't be converted between proto and json")
+	ErrHTTPRequestBuild     = define(DomainPassthrough, "HTTP_REQUEST_BUILD_ERROR", codes.Internal, HTTPRequestBuildError, "the HTTP request to the service can't be created")
+	ErrReceiveMsg           = define(DomainPassthrough, "RECEIVE_MSG_ERROR", codes.Internal, ReceiveMsgError, "the request message can't be received from the client")
+	ErrInvalidRequest       = define(DomainPassthrough, "INVALID_REQUEST", codes.InvalidArgument, 0, "the request can't be converted to the service request")
+	ErrUnknownMethod        = define(DomainPassthrough, "UNKNOWN_METHOD", codes.Internal, 0, "the method of the call can't be determined")
+	ErrInvalidEndpoint      = define(DomainPassthrough, "INVALID_SERVICE_ENDPOINT", codes.Internal, InvalidConfig, "the service_endpoint can't be parsed")
+	ErrServiceResponse      = define(DomainPassthrough, "SERVICE_RESPONSE_ERROR", codes.Internal, 0, "the response of the service can't be read")
+	ErrSendResponse         = define(DomainPassthrough, "SEND_RESPONSE_ERROR", codes.Internal, 0, "the response can't be sent to the client")
+	ErrProcessFailed        = define(DomainPassthrough, "PROCESS_FAILED", codes.Internal, 0, "the service process failed")
+	ErrNoIdleWorker         = define(DomainPassthrough, "NO_IDLE_WORKER", codes.ResourceExhausted, 0, "no worker process of the pool is idle")
+	ErrWorkerMemoryExceeded = define(DomainPassthrough, "WORKER_MEMORY_EXCEEDED", codes.ResourceExhausted, 0, "the worker process exceeded its memory limit")
+	ErrWorkerExited         = define(DomainPassthrough, "WORKER_EXITED", codes.Unavailable, 0, "the worker process exited during the call")
+	ErrWorkerFailed         = define(DomainPassthrough, "WORKER_ERROR", codes.Internal, 0, "the worker process failed")
+)
+
+// Catalogue returns the definitions of the daemon errors sorted by the domain and the reason
+func Catalogue() []*Definition {
+	definitions := slices.Clone(catalogue)
+	slices.SortFunc(definitions, func(a, b *Definition) int {
+		if a.Domain != b.Domain {
+			return strings.Compare(a.Domain, b.Domain)
+		}
+		return strings.Compare(a.Reason, b.Reason)
+	})
+	return definitions
+}
+
+// Lookup returns the definition of the error with the domain and the reason
+func Lookup(domain, reason string) (*Definition, bool) {
+	for _, definition := range catalogue {
+		if definition.Domain == domain && definition.Reason == reason {
+			return definition, true
+		}
+	}
+	return nil, false
+}
+
+// DocURL returns the dev portal page of the error, empty if the error has no page
+func (definition *Definition) DocURL() string {
+	if definition.DocCode == 0 {
+		return ""
+	}
+	return devPortalURL + strconv.Itoa(definition.DocCode)
+}
+
+// New returns the error of the definition with the message
+func (definition *Definition) New(format string, args ...any) *Error {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	return &Error{Definition: definition, Message: message}
+}
+
+// Is returns true when the error or the gRPC status of the error is of the definition
+func (definition *Definition) Is(err error) bool {
+	var daemonErr *Error
+	if errors.As(err, &daemonErr) {
+		return daemonErr.Definition == definition
+	}
+	info := ErrorInfo(err)
+	return info != nil && info.Domain == definition.Domain && info.Reason == definition.Reason
+}
+
+// Error is the daemon error of the catalogue, it is returned to the client as
+// the gRPC status with the google.rpc.ErrorInfo detail
+type Error struct {
+	Definition *Definition
+	Message    string
+	Metadata   map[string]string
+}
+
+// With adds the metadata of the error, the metadata is returned in the ErrorInfo detail
+func (err *Error) With(key string, value any) *Error {
+	if err.Metadata == nil {
+		err.Metadata = make(map[string]string)
+	}
+	err.Metadata[key] = fmt.Sprint(value)
+	return err
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// GRPCStatus returns the gRPC status of the error, the error is converted by
+// the gRPC server when it is returned by the handler
+func (err *Error) GRPCStatus() *status.Status {
+	st := status.New(err.Definition.Code, err.Message)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   err.Definition.Reason,
+		Domain:   err.Definition.Domain,
+		Metadata: err.Metadata,
+	}}
+	if url := err.Definition.DocURL(); url != "" {
+		details = append(details, &errdetails.Help{Links: []*errdetails.Help_Link{{
+			Description: "About error & possible fixes",
+			Url:         url,
+		}}})
+	}
+	if detailed, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		return detailed
+	}
+	return st
+}
+
+// ErrorInfo returns the ErrorInfo detail of the gRPC status of the error, nil
+// if the error has no detail
+func ErrorInfo(err error) *errdetails.ErrorInfo {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// Reference renders the markdown reference of the catalogue
+func Reference() string {
+	var builder strings.Builder
+	builder.WriteString("# Daemon error reference\n\n")
+	builder.WriteString("<!-- Generated from the error catalogue by `go generate ./errs`, DO NOT EDIT. -->\n\n")
+	builder.WriteString("The daemon returns the errors as the gRPC status with the `google.rpc.ErrorInfo` detail, the\n")
+	builder.WriteString("domain and the reason of the detail identify the error and are stable between the releases.\n")
+	builder.WriteString("The metadata of the detail has the values of the error, e.g. the latest nonce of the channel.\n")
+	builder.WriteString("The errors documented on the dev portal have the `google.rpc.Help` detail with the link to the page.\n")
+	domain := ""
+	for _, definition := range Catalogue() {
+		if definition.Domain != domain {
+			domain = definition.Domain
+			builder.WriteString("\n## " + domain + "\n\n")
+			builder.WriteString("| Reason | gRPC code | Description |\n")
+			builder.WriteString("|--------|-----------|-------------|\n")
+		}
+		code := definition.Code.String()
+		if definition.Code == IncorrectNonceCode {
+			code = "IncorrectNonce (1000)"
+		}
+		description := definition.Description
+		if url := definition.DocURL(); url != "" {
+			description += " ([details](" + url + "))"
+		}
+		fmt.Fprintf(&builder, "| %s | %s | %s |\n", definition.Reason, code, description)
+	}
+	return builder.String()
+}
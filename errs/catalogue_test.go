@@ -0,0 +1,59 @@
+package errs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCatalogue(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, definition := range Catalogue() {
+		key := definition.Domain + "/" + definition.Reason
+		assert.False(t, seen[key], "duplicated reason %v", key)
+		seen[key] = true
+		assert.NotEmpty(t, definition.Description, key)
+		found, ok := Lookup(definition.Domain, definition.Reason)
+		assert.True(t, ok)
+		assert.Same(t, definition, found)
+	}
+	_, ok := Lookup(DomainPayment, "UNKNOWN")
+	assert.False(t, ok)
+}
+
+func TestReferenceIsGenerated(t *testing.T) {
+	reference, err := os.ReadFile("README.md")
+	require.Nil(t, err)
+	assert.Equal(t, Reference(), string(reference), "run go generate ./errs to update the reference")
+}
+
+func TestError_GRPCStatus(t *testing.T) {
+	err := ErrIncorrectNonce.New("incorrect payment channel nonce, latest: %v, sent: %v", 3, 2).With("latest_nonce", 3)
+
+	st := status.Convert(err)
+	assert.Equal(t, IncorrectNonceCode, st.Code())
+	assert.Equal(t, "incorrect payment channel nonce, latest: 3, sent: 2", st.Message())
+	info := ErrorInfo(err)
+	require.NotNil(t, info)
+	assert.Equal(t, "INCORRECT_NONCE", info.Reason)
+	assert.Equal(t, DomainPayment, info.Domain)
+	assert.Equal(t, map[string]string{"latest_nonce": "3"}, info.Metadata)
+
+	assert.True(t, ErrIncorrectNonce.Is(err))
+	assert.True(t, ErrIncorrectNonce.Is(st.Err()))
+	assert.True(t, ErrIncorrectNonce.Is(fmt.Errorf("wrapped: %w", err)))
+	assert.False(t, ErrChannelBusy.Is(err))
+	assert.False(t, ErrChannelBusy.Is(status.Error(codes.Internal, "no details")))
+	assert.Nil(t, ErrorInfo(fmt.Errorf("plain")))
+
+	// the documented error links the dev portal page
+	st = ErrServiceUnavailable.New("can't connect to service").GRPCStatus()
+	require.Len(t, st.Details(), 2)
+	assert.Equal(t, devPortalURL+"1", st.Details()[1].(*errdetails.Help).Links[0].Url)
+}
@@ -2,9 +2,9 @@ package escrow
 
 import (
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/handler"
 	"github.com/singnet/snet-daemon/v6/utils"
-	"google.golang.org/grpc/codes"
 )
 
 type allowedUserPaymentHandler struct {
@@ -57,8 +57,8 @@ func (h *allowedUserPaymentHandler) getPaymentFromContext(context *handler.GrpcS
 		return
 	}
 	if !common.IsHexAddress(address) {
-		err = handler.NewGrpcErrorf(codes.InvalidArgument, "Address is not a valid Hex address \"%v\": %v",
-			handler.PaymentMultiPartyEscrowAddressHeader, address)
+		err = handler.NewGrpcErrorFrom(errs.ErrInvalidAddress.New("Address is not a valid Hex address \"%v\": %v",
+			handler.PaymentMultiPartyEscrowAddressHeader, address).With("header", handler.PaymentMultiPartyEscrowAddressHeader))
 		return
 	}
 
@@ -0,0 +1,59 @@
+package escrow
+
+import (
+	"slices"
+
+	"github.com/singnet/snet-daemon/v6/errs"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"go.uber.org/zap"
+)
+
+const (
+	// CertIdentityPaymentType admits the calls of the clients authenticated by
+	// the trusted mTLS certificate, no payment metadata is required.
+	CertIdentityPaymentType = "cert-identity"
+)
+
+// CertIdentityPayment is the call of the client with the trusted certificate
+type CertIdentityPayment struct {
+	// Identity is the trusted SAN or CN of the client certificate
+	Identity string
+}
+
+type certIdentityPaymentHandler struct {
+	trustedIdentities []string
+}
+
+// CertIdentityPaymentHandler admits the calls from the clients whose verified
+// certificate has one of the trusted identities as SAN or CN.
+func CertIdentityPaymentHandler(trustedIdentities []string) handler.StreamPaymentHandler {
+	return &certIdentityPaymentHandler{
+		trustedIdentities: trustedIdentities,
+	}
+}
+
+func (h *certIdentityPaymentHandler) Type() (typ string) {
+	return CertIdentityPaymentType
+}
+
+func (h *certIdentityPaymentHandler) Payment(context *handler.GrpcStreamContext) (payment handler.Payment, err *handler.GrpcError) {
+	if context.ClientIdentity == nil {
+		return nil, handler.NewGrpcErrorFrom(errs.ErrClientCertRequired.New("client certificate is required"))
+	}
+	for _, name := range context.ClientIdentity.Names() {
+		if slices.Contains(h.trustedIdentities, name) {
+			return &CertIdentityPayment{Identity: name}, nil
+		}
+	}
+	zap.L().Debug("client certificate isn't trusted", zap.Strings("identities", context.ClientIdentity.Names()))
+	return nil, handler.NewGrpcErrorFrom(errs.ErrClientCertUntrusted.New("client certificate %q is not trusted",
+		context.ClientIdentity.CommonName))
+}
+
+func (h *certIdentityPaymentHandler) Complete(payment handler.Payment) (err *handler.GrpcError) {
+	return nil
+}
+
+func (h *certIdentityPaymentHandler) CompleteAfterError(payment handler.Payment, result error) (err *handler.GrpcError) {
+	return nil
+}
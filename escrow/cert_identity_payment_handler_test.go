@@ -0,0 +1,36 @@
+package escrow
+
+import (
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_certIdentityPaymentHandler_Payment(t *testing.T) {
+	testHandler := CertIdentityPaymentHandler([]string{"partner.example.com", "billing-client"})
+	assert.Equal(t, CertIdentityPaymentType, testHandler.Type())
+
+	context := &handler.GrpcStreamContext{MD: metadata.New(map[string]string{})}
+	_, err := testHandler.Payment(context)
+	assert.Equal(t, codes.Unauthenticated, err.Status.Code())
+
+	context.ClientIdentity = &handler.ClientCertIdentity{CommonName: "unknown", SANs: []string{"other.example.com"}}
+	_, err = testHandler.Payment(context)
+	assert.Equal(t, codes.PermissionDenied, err.Status.Code())
+
+	context.ClientIdentity = &handler.ClientCertIdentity{CommonName: "unknown", SANs: []string{"partner.example.com"}}
+	payment, err := testHandler.Payment(context)
+	assert.Nil(t, err)
+	assert.Equal(t, &CertIdentityPayment{Identity: "partner.example.com"}, payment)
+
+	context.ClientIdentity = &handler.ClientCertIdentity{CommonName: "billing-client"}
+	payment, err = testHandler.Payment(context)
+	assert.Nil(t, err)
+	assert.Equal(t, &CertIdentityPayment{Identity: "billing-client"}, payment)
+
+	assert.Nil(t, testHandler.Complete(payment))
+	assert.Nil(t, testHandler.CompleteAfterError(payment, nil))
+}
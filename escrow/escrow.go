@@ -2,9 +2,11 @@ package escrow
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 
+	"github.com/singnet/snet-daemon/v6/errs"
 	"go.uber.org/zap"
 )
 
@@ -146,6 +148,41 @@ func (h *lockingPaymentChannelService) StartClaim(key *PaymentChannelKey, update
 	}, nil
 }
 
+// Refund adds the amount to the credit of the channel, the credit pays the
+// next calls of the channel sender
+func (h *lockingPaymentChannelService) Refund(key *PaymentChannelKey, amount *big.Int) (err error) {
+	lock, ok, err := h.locker.Lock(key.String())
+	if err != nil {
+		return fmt.Errorf("cannot get mutex for channel: %v because of %v", key, err)
+	}
+	if !ok {
+		return fmt.Errorf("another transaction on channel: %v is in progress", key)
+	}
+	defer func() {
+		if e := lock.Unlock(); e != nil {
+			zap.L().Error("Refund is finished, but channel cannot be unlocked. All other transactions on this channel will be blocked until unlock. Please unlock channel manually.",
+				zap.Any("key", key), zap.Error(e))
+		}
+	}()
+
+	channel, ok, err := h.storage.Get(key)
+	if err != nil {
+		return fmt.Errorf("channel storage error: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("channel is not found by key: %v", key)
+	}
+
+	nextChannel := *channel
+	nextChannel.Credit = new(big.Int).Add(channel.CreditAmount(), amount)
+	if err = h.storage.Put(key, &nextChannel); err != nil {
+		return fmt.Errorf("channel storage error: %v", err)
+	}
+	zap.L().Info("Amount is refunded to the channel credit", zap.Any("key", key), zap.Any("amount", amount),
+		zap.Any("credit", nextChannel.Credit))
+	return nil
+}
+
 func (h *lockingPaymentChannelService) ListClaims() (claims []Claim, err error) {
 	payments, err := h.paymentStorage.GetAll()
 	if err != nil {
@@ -176,16 +213,48 @@ func getPaymentFromChannel(channel *PaymentChannelData) *Payment {
 }
 
 type paymentTransaction struct {
-	payment Payment
-	channel *PaymentChannelData
-	service *lockingPaymentChannelService
-	lock    Lock
+	payment    Payment
+	channel    *PaymentChannelData
+	service    *lockingPaymentChannelService
+	lock       Lock
+	usedCredit *big.Int
+	overpaid   *big.Int
 }
 
 func (payment *paymentTransaction) GetSender() common.Address {
 	return payment.channel.Sender
 }
 
+// ChargedAmount returns the difference between the amount authorized by the
+// payment and the amount authorized before this call
+func (payment *paymentTransaction) ChargedAmount() *big.Int {
+	return new(big.Int).Sub(payment.payment.Amount, payment.channel.AuthorizedAmount)
+}
+
+// useCredit sets the part of the channel credit which pays the call
+func (payment *paymentTransaction) useCredit(amount *big.Int) {
+	payment.usedCredit = amount
+}
+
+// addCredit sets the part of the signed amount over the price of the call
+// which is added to the channel credit
+func (payment *paymentTransaction) addCredit(amount *big.Int) {
+	payment.overpaid = amount
+}
+
+// PaidAmount returns the price of the call paid by the signed amount and the
+// channel credit
+func (payment *paymentTransaction) PaidAmount() *big.Int {
+	paid := payment.ChargedAmount()
+	if payment.usedCredit != nil {
+		paid.Add(paid, payment.usedCredit)
+	}
+	if payment.overpaid != nil {
+		paid.Sub(paid, payment.overpaid)
+	}
+	return paid
+}
+
 func (payment *paymentTransaction) String() string {
 	return fmt.Sprintf("{payment: %v, channel: %v}", payment.payment, payment.channel)
 }
@@ -200,10 +269,10 @@ func (h *lockingPaymentChannelService) StartPaymentTransaction(payment *Payment)
 	lock, ok, err := h.locker.Lock(channelKey.String())
 	if err != nil {
 		zap.L().Error("StartPaymentTransaction, unable to get lock!", zap.Error(err), zap.Any("channelKey", channelKey))
-		return nil, NewPaymentError(Internal, "cannot get mutex for channel: %v", channelKey)
+		return nil, NewPaymentError(errs.ErrPaymentInternal, "cannot get mutex for channel: %v", channelKey)
 	}
 	if !ok {
-		return nil, NewPaymentError(FailedPrecondition, "another transaction on channel: %v is in progress", channelKey)
+		return nil, NewPaymentError(errs.ErrChannelBusy, "another transaction on channel: %v is in progress", channelKey)
 	}
 	defer func(lock Lock) {
 		if err != nil {
@@ -219,11 +288,11 @@ func (h *lockingPaymentChannelService) StartPaymentTransaction(payment *Payment)
 	channel, ok, err := h.PaymentChannel(channelKey)
 	if err != nil {
 		zap.L().Error("StartPaymentTransaction, unable to get channel!", zap.Error(err), zap.Any("channelKey", channelKey))
-		return nil, NewPaymentError(Internal, "payment channel error: %s", err.Error())
+		return nil, NewPaymentError(errs.ErrPaymentInternal, "payment channel error: %s", err.Error())
 	}
 	if !ok {
 		zap.L().Warn("Payment channel not found")
-		return nil, NewPaymentError(Unauthenticated, "payment channel \"%v\" not found", channelKey)
+		return nil, NewPaymentError(errs.ErrChannelNotFound, "payment channel \"%v\" not found", channelKey)
 	}
 
 	err = h.validator.Validate(payment, channel)
@@ -250,6 +319,17 @@ func (payment *paymentTransaction) Commit() error {
 		}
 	}(payment)
 
+	credit := payment.channel.Credit
+	if payment.usedCredit != nil && payment.usedCredit.Sign() > 0 {
+		credit = new(big.Int).Sub(payment.channel.CreditAmount(), payment.usedCredit)
+	}
+	if payment.overpaid != nil && payment.overpaid.Sign() > 0 {
+		if credit == nil {
+			credit = big.NewInt(0)
+		}
+		credit = new(big.Int).Add(credit, payment.overpaid)
+	}
+
 	err := payment.service.storage.Put(
 		&PaymentChannelKey{ID: payment.payment.ChannelID},
 		&PaymentChannelData{
@@ -264,11 +344,12 @@ func (payment *paymentTransaction) Commit() error {
 			AuthorizedAmount: payment.payment.Amount,
 			Signature:        payment.payment.Signature,
 			GroupID:          payment.channel.GroupID,
+			Credit:           credit,
 		},
 	)
 	if err != nil {
 		zap.L().Error("Unable to store new payment channel state", zap.Error(err))
-		return NewPaymentError(Internal, "unable to store new payment channel state")
+		return NewPaymentError(errs.ErrPaymentInternal, "unable to store new payment channel state")
 	}
 
 	zap.L().Debug("Payment completed", zap.Uint64("channel.ChannelID", payment.channel.ChannelID.Uint64()), zap.Uint64("payment.ChannelID", payment.payment.ChannelID.Uint64()))
@@ -9,6 +9,7 @@ import (
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/storage"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -220,7 +221,7 @@ func (suite *PaymentChannelServiceSuite) TestPaymentParallelTransaction() {
 	channel, ok, errD := suite.storage.Get(suite.channelKey())
 
 	assert.Nil(suite.T(), errA, "Unexpected error: %v", errA)
-	assert.Equal(suite.T(), NewPaymentError(FailedPrecondition, "another transaction on channel: {ID: 42} is in progress"), errB)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrChannelBusy, "another transaction on channel: {ID: 42} is in progress"), errB)
 	assert.Nil(suite.T(), transactionB)
 	assert.Nil(suite.T(), errC, "Unexpected error: %v", errC)
 	assert.Nil(suite.T(), errD, "Unexpected error: %v", errD)
@@ -2,8 +2,10 @@ package escrow
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
@@ -59,6 +61,11 @@ func (transaction *freeCallTransaction) GetSender() common.Address {
 	return common.HexToAddress(transaction.payment.Address)
 }
 
+// ChargedAmount is always zero for free calls
+func (transaction *freeCallTransaction) ChargedAmount() *big.Int {
+	return big.NewInt(0)
+}
+
 func (transaction *freeCallTransaction) String() string {
 	return fmt.Sprintf("{FreeCallPayment: %v, FreeCallUser: %v}", transaction.payment.String(), transaction.freeCallUser.String())
 }
@@ -81,17 +88,17 @@ func (h *lockingFreeCallUserService) StartFreeCallUserTransaction(payment *FreeC
 
 	userKey, err := h.GetFreeCallUserKey(payment)
 	if err != nil {
-		return nil, NewPaymentError(Internal, "payment freeCallUserKey error: %s", err.Error())
+		return nil, NewPaymentError(errs.ErrFreeCallInternal, "payment freeCallUserKey error: %s", err.Error())
 	}
 
 	freeCallUserData, ok, err := h.FreeCallUser(userKey)
 	if err != nil {
-		return nil, NewPaymentError(Internal, "payment freeCallUserData error: %s", err.Error())
+		return nil, NewPaymentError(errs.ErrFreeCallInternal, "payment freeCallUserData error: %s", err.Error())
 	}
 
 	if !ok {
 		zap.L().Warn("Payment freeCallUserData not found")
-		return nil, NewPaymentError(Unauthenticated, "payment freeCallUserData \"%v\" not found", userKey)
+		return nil, NewPaymentError(errs.ErrFreeCallUserNotFound, "payment freeCallUserData \"%v\" not found", userKey)
 	}
 
 	freeCallUserData.ServiceId = userKey.ServiceId
@@ -101,10 +108,10 @@ func (h *lockingFreeCallUserService) StartFreeCallUserTransaction(payment *FreeC
 
 	lock, ok, err := h.locker.Lock(userKey.String())
 	if err != nil {
-		return nil, NewPaymentError(Internal, "cannot get mutex for user: %v", userKey)
+		return nil, NewPaymentError(errs.ErrFreeCallInternal, "cannot get mutex for user: %v", userKey)
 	}
 	if !ok {
-		return nil, NewPaymentError(FailedPrecondition, "another transaction on this user: %v is in progress", userKey)
+		return nil, NewPaymentError(errs.ErrFreeCallUserBusy, "another transaction on this user: %v is in progress", userKey)
 	}
 	defer func(lock Lock) {
 		if err != nil {
@@ -127,10 +134,10 @@ func (h *lockingFreeCallUserService) StartFreeCallUserTransaction(payment *FreeC
 	if allowed != -1 {
 		made := freeCallUserData.FreeCallsMade
 		if made >= allowed {
-			return nil, fmt.Errorf(
+			return nil, NewPaymentError(errs.ErrFreeCallsExhausted,
 				"free call limit has been exceeded, calls made = %d, total free calls eligible = %d",
 				made, allowed,
-			)
+			).With("free_calls_allowed", allowed)
 		}
 	}
 
@@ -163,7 +170,7 @@ func (transaction *freeCallTransaction) Commit() error {
 	)
 	if err != nil {
 		zap.L().Error("Unable to store new transaction free call user state")
-		return NewPaymentError(Internal, "unable to store new transaction free call user state")
+		return NewPaymentError(errs.ErrFreeCallInternal, "unable to store new transaction free call user state")
 	}
 
 	zap.L().Debug("Free Call Payment completed")
@@ -3,9 +3,9 @@ package escrow
 import (
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/handler"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -84,7 +84,7 @@ func (h *freeCallPaymentHandler) getPaymentFromContext(context *handler.GrpcStre
 	parsedToken, blockExpiration, err2 := ParseFreeCallToken(authToken)
 	if err2 != nil {
 		zap.L().Debug(err2.Error())
-		return nil, handler.NewGrpcErrorf(codes.InvalidArgument, "invalid token: %v", err2)
+		return nil, handler.NewGrpcErrorFrom(errs.ErrInvalidFreeCallToken.New("invalid token: %v", err2))
 	}
 
 	return &FreeCallPayment{
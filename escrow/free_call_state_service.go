@@ -11,9 +11,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
-	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
 )
 
 type FreeCallStateService struct {
@@ -34,7 +33,8 @@ func (service *FreeCallStateService) CheckBalanceForFreeCall(ctx context.Context
 	balance, err := service.tokenInstance.BalanceOf(&bind.CallOpts{Context: ctx}, address)
 	if err != nil {
 		zap.L().Error("error can't get balance", zap.Error(err))
-		return handler.NewGrpcErrorf(codes.PermissionDenied, "you must have at least %s FET (ASI) in your balance to use free calls", service.minBalanceForFreeCall.String())
+		return errs.ErrFreeCallBalanceTooLow.New("you must have at least %s FET (ASI) in your balance to use free calls", service.minBalanceForFreeCall.String()).
+			With("min_balance", service.minBalanceForFreeCall)
 	}
 
 	// 10 * 10^18
@@ -43,7 +43,8 @@ func (service *FreeCallStateService) CheckBalanceForFreeCall(ctx context.Context
 	threshold := new(big.Int).Mul(service.minBalanceForFreeCall, factor)
 
 	if balance.Cmp(threshold) < 0 {
-		return handler.NewGrpcErrorf(codes.PermissionDenied, "you must have at least %s FET (ASI) in your balance to use free calls", service.minBalanceForFreeCall.String())
+		return errs.ErrFreeCallBalanceTooLow.New("you must have at least %s FET (ASI) in your balance to use free calls", service.minBalanceForFreeCall.String()).
+			With("min_balance", service.minBalanceForFreeCall)
 	}
 	return nil
 }
@@ -70,7 +71,7 @@ func (service *FreeCallStateService) GetFreeCallToken(ctx context.Context, reque
 			return *signer == addr
 		}) {
 		if request.GetUserId() != "" {
-			return nil, fmt.Errorf("your address is not trusted by this service provider, the use of user_id is not allowed")
+			return nil, errs.ErrInvalidSigner.New("your address is not trusted by this service provider, the use of user_id is not allowed")
 		}
 		err := service.CheckBalanceForFreeCall(ctx, common.HexToAddress(request.Address))
 		if err != nil {
@@ -2,10 +2,10 @@ package escrow
 
 import (
 	"errors"
-	"fmt"
 	"math/big"
 	"strings"
 
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/pricing"
 	"github.com/singnet/snet-daemon/v6/training"
 	"go.uber.org/zap"
@@ -23,6 +23,13 @@ type IncomeStreamData struct {
 	// GrpcContext contains gRPC stream context information. For instance,
 	// metadata could be used to pass invoice id to check pricing.
 	GrpcContext *handler.GrpcStreamContext
+	// Credit is a credit of the payment channel which can pay the call.
+	Credit *big.Int
+	// UsedCredit is set by the validator to the part of Credit which pays the call.
+	UsedCredit *big.Int
+	// Overpaid is set by the validator to the part of Income over the price of
+	// the call served from the cache, it's added to the channel credit.
+	Overpaid *big.Int
 }
 
 // IncomeStreamValidator uses pricing information to check that call was paid
@@ -68,14 +75,49 @@ func (validator *incomeStreamValidator) Validate(data *IncomeStreamData) (err er
 		if err != nil {
 			return err
 		}
+		// the client which doesn't know the call is served from the cache pays
+		// the price of the method, the difference to the hit price is credited
+		if data.Income.Cmp(price) > 0 && data.GrpcContext.InStream != nil &&
+			handler.IsResponseCacheHit(data.GrpcContext.InStream.Context()) {
+			fullPrice, err := validator.priceStrategy.GetFullPrice(data.GrpcContext)
+			if err != nil {
+				return err
+			}
+			if data.Income.Cmp(fullPrice) == 0 {
+				data.Overpaid = new(big.Int).Sub(fullPrice, price)
+				return nil
+			}
+		}
 	}
 
-	if data.Income.Cmp(price) != 0 {
-		err = NewPaymentError(Unauthenticated, "income %d does not equal to price %d", data.Income, price)
-		return
+	data.UsedCredit, err = checkIncome(data.Income, data.Credit, price)
+	return
+}
+
+// checkIncome verifies that the income pays the price exactly, alone or
+// together with the channel credit which pays the call first. The clients
+// which don't use the credit sign the full price and the credit is kept for
+// the next calls. It returns the used credit.
+func checkIncome(income, credit, price *big.Int) (usedCredit *big.Int, err error) {
+	if income.Cmp(price) == 0 {
+		return big.NewInt(0), nil
+	}
+	usedCredit = big.NewInt(0)
+	if credit != nil && credit.Sign() > 0 {
+		usedCredit.Set(credit)
+		if usedCredit.Cmp(price) > 0 {
+			usedCredit.Set(price)
+		}
 	}
 
-	return
+	if usedCredit.Sign() == 0 {
+		return nil, NewPaymentError(errs.ErrIncomeMismatch, "income %d does not equal to price %d", income, price).With("price", price)
+	}
+	if expected := new(big.Int).Sub(price, usedCredit); income.Cmp(expected) != 0 {
+		return nil, NewPaymentError(errs.ErrIncomeMismatch, "income %d does not equal to price %d or price minus channel credit %d",
+			income, price, usedCredit).With("price", price)
+	}
+	return usedCredit, nil
 }
 
 type trainUnaryValidator struct {
@@ -96,6 +138,10 @@ type IncomeUnaryData struct {
 	// GrpcContext contains gRPC stream context information. For instance
 	// metadata could be used to pass invoice id to check pricing.
 	GrpcContext *handler.GrpcUnaryContext
+	// Credit is a credit of the payment channel which can pay the call.
+	Credit *big.Int
+	// UsedCredit is set by the validator to the part of Credit which pays the call.
+	UsedCredit *big.Int
 }
 
 // NewTrainValidator returns a new income validator instance
@@ -130,10 +176,8 @@ func (validator *trainUnaryValidator) Validate(data *IncomeUnaryData) (err error
 
 	zap.L().Debug("[Validate]", zap.Uint64("price", price.Uint64()))
 
-	if data.Income.Cmp(price) != 0 {
-		zap.L().Error(fmt.Sprintf("[Validate] income %d does not equal to price %d", data.Income, price))
-		err = NewPaymentError(Unauthenticated, "income %d does not equal to price %d", data.Income, price)
-		return
+	if data.UsedCredit, err = checkIncome(data.Income, data.Credit, price); err != nil {
+		zap.L().Error("[Validate] income check failed", zap.Error(err))
 	}
 
 	return
@@ -1,16 +1,24 @@
 package escrow
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/handler"
 	"github.com/singnet/snet-daemon/v6/pricing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type incomeValidatorMockType struct {
@@ -46,7 +54,7 @@ func TestIncomeValidate(t *testing.T) {
 
 	income.Sub(price, one)
 	err = incomeValidator.Validate(&IncomeStreamData{Income: income, GrpcContext: &handler.GrpcStreamContext{Info: &grpc.StreamServerInfo{FullMethod: "test"}}})
-	assert.Equal(t, NewPaymentError(Unauthenticated, "income %s does not equal to price %s", income, price), err)
+	assert.Equal(t, NewPaymentError(errs.ErrIncomeMismatch, "income %s does not equal to price %s", income, price).With("price", price), err)
 
 	income.Set(price)
 	err = incomeValidator.Validate(&IncomeStreamData{Income: income, GrpcContext: &handler.GrpcStreamContext{Info: &grpc.StreamServerInfo{FullMethod: "test"}}})
@@ -54,7 +62,7 @@ func TestIncomeValidate(t *testing.T) {
 
 	income.Add(price, one)
 	err = incomeValidator.Validate(&IncomeStreamData{Income: income, GrpcContext: &handler.GrpcStreamContext{Info: &grpc.StreamServerInfo{FullMethod: "test"}}})
-	assert.Equal(t, NewPaymentError(Unauthenticated, "income %s does not equal to price %s", income, price), err)
+	assert.Equal(t, NewPaymentError(errs.ErrIncomeMismatch, "income %s does not equal to price %s", income, price).With("price", price), err)
 }
 
 type MockPriceErrorType struct {
@@ -75,3 +83,110 @@ func TestIncomeValidateForPriceError(t *testing.T) {
 	err = incomeValidator.Validate(&IncomeStreamData{Income: big.NewInt(0), GrpcContext: &handler.GrpcStreamContext{Info: &grpc.StreamServerInfo{FullMethod: "test"}}})
 	assert.Equal(t, err.Error(), "Error in Determining Price")
 }
+
+func TestCheckIncomeWithCredit(t *testing.T) {
+	price := big.NewInt(10)
+
+	usedCredit, err := checkIncome(big.NewInt(10), nil, price)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), usedCredit)
+
+	usedCredit, err = checkIncome(big.NewInt(6), big.NewInt(4), price)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(4), usedCredit)
+
+	usedCredit, err = checkIncome(big.NewInt(0), big.NewInt(25), price)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(10), usedCredit)
+
+	// the full price leaves the credit unused for the clients which don't use it
+	usedCredit, err = checkIncome(big.NewInt(10), big.NewInt(4), price)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), usedCredit)
+
+	_, err = checkIncome(big.NewInt(8), big.NewInt(4), price)
+	assert.Equal(t, NewPaymentError(errs.ErrIncomeMismatch, "income 8 does not equal to price 10 or price minus channel credit 4").With("price", 10), err)
+	_, err = checkIncome(big.NewInt(8), nil, price)
+	assert.Equal(t, NewPaymentError(errs.ErrIncomeMismatch, "income 8 does not equal to price 10").With("price", 10), err)
+}
+
+// cacheTestStream is the unary call sending the request
+type cacheTestStream struct {
+	grpc.ServerStream
+	request []byte
+}
+
+func (s *cacheTestStream) Context() context.Context     { return context.Background() }
+func (s *cacheTestStream) SetHeader(metadata.MD) error  { return nil }
+func (s *cacheTestStream) SendHeader(metadata.MD) error { return nil }
+func (s *cacheTestStream) SetTrailer(metadata.MD)       {}
+func (s *cacheTestStream) SendMsg(any) error            { return nil }
+func (s *cacheTestStream) RecvMsg(m any) error {
+	if s.request == nil {
+		return io.EOF
+	}
+	m.(*codec.GrpcFrame).Data, s.request = s.request, nil
+	return nil
+}
+
+func TestIncomeValidateCacheHit(t *testing.T) {
+	defer config.Vip().Set(config.ResponseCacheKey, config.Vip().Get(config.ResponseCacheKey))
+	config.Vip().Set(config.ResponseCacheKey, map[string]any{
+		"enabled": true,
+		"methods": []any{map[string]any{"method": "/example_service.Calculator/add", "hit_price_in_cogs": 1}},
+	})
+	settings, err := config.GetResponseCache()
+	require.Nil(t, err)
+	// the metadata without the service api, the default price is 2
+	metadata, err := blockchain.InitServiceMetaDataFromJson([]byte(strings.Replace(testJsonDataFixedPrice,
+		`"model_ipfs_hash": "Qmdiq8Hu6dYiwp712GtnbBxagyfYyvUY1HYqkH7iN76UCc",`, "", 1)))
+	require.Nil(t, err)
+	pricingStrt, err := pricing.InitPricingStrategy(metadata)
+	require.Nil(t, err)
+	incomeValidator := NewIncomeStreamValidator(pricingStrt, nil)
+
+	cache := handler.NewResponseCache(settings, metadata, nil)
+	lookup := handler.GrpcResponseCacheLookupInterceptor(cache)
+	serve := handler.GrpcResponseCacheInterceptor(cache)
+
+	// validate returns the income data of the call validated as the payment handler does
+	validate := func(income int64) (*IncomeStreamData, error) {
+		data := &IncomeStreamData{Income: big.NewInt(income)}
+		var validateErr error
+		info := &grpc.StreamServerInfo{FullMethod: "/example_service.Calculator/add"}
+		err := lookup(nil, &cacheTestStream{request: []byte{1}}, info, func(srv any, ss grpc.ServerStream) error {
+			data.GrpcContext = &handler.GrpcStreamContext{Info: info, InStream: ss}
+			if validateErr = incomeValidator.Validate(data); validateErr != nil {
+				return nil
+			}
+			return serve(srv, ss, info, func(srv any, ss grpc.ServerStream) error {
+				return ss.SendMsg(&codec.GrpcFrame{Data: []byte{2}})
+			})
+		})
+		require.Nil(t, err)
+		return data, validateErr
+	}
+
+	// the call of the service is paid by the price of the method only
+	_, err = validate(1)
+	assert.Equal(t, NewPaymentError(errs.ErrIncomeMismatch, "income 1 does not equal to price 2").With("price", 2), err)
+	data, err := validate(2)
+	assert.Nil(t, err)
+	assert.Nil(t, data.Overpaid)
+
+	// the client paying the price of the method for the cached response gets
+	// the difference to the hit price as the channel credit
+	data, err = validate(2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1), data.Overpaid)
+	data, err = validate(1)
+	assert.Nil(t, err)
+	assert.Nil(t, data.Overpaid)
+	_, err = validate(3)
+	assert.Equal(t, NewPaymentError(errs.ErrIncomeMismatch, "income 3 does not equal to price 1").With("price", 1), err)
+
+	transaction := &paymentTransaction{payment: Payment{Amount: big.NewInt(12)},
+		channel: &PaymentChannelData{AuthorizedAmount: big.NewInt(10)}}
+	addChannelCredit(transaction, big.NewInt(1))
+	assert.Equal(t, big.NewInt(1), transaction.PaidAmount())
+}
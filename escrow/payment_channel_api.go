@@ -5,7 +5,9 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
+	"google.golang.org/grpc/codes"
 )
 
 // Payment contains MultiPartyEscrow payment details
@@ -99,11 +101,22 @@ type PaymentChannelData struct {
 	// Signature is a signature of last message containing Authorized amount.
 	// It is required to claim tokens from channel.
 	Signature []byte
+	// Credit is an amount refunded to Sender, it pays the next RPC calls
+	// instead of the signed amount.
+	Credit *big.Int
+}
+
+// CreditAmount returns the credit of the channel, zero if there is no credit.
+func (data *PaymentChannelData) CreditAmount() *big.Int {
+	if data.Credit == nil {
+		return big.NewInt(0)
+	}
+	return data.Credit
 }
 
 func (data *PaymentChannelData) String() string {
-	return fmt.Sprintf("{ChannelID: %v, Nonce: %v, State: %v, Sender: %v, Recipient: %v, GroupId: %v, FullAmount: %v, Expiration: %v, Signer: %v, AuthorizedAmount: %v, Signature: %v",
-		data.ChannelID, data.Nonce, data.State, utils.AddressToHex(&data.Sender), utils.AddressToHex(&data.Recipient), utils.BytesToBase64(data.GroupID[:]), data.FullAmount, data.Expiration, utils.AddressToHex(&data.Signer), data.AuthorizedAmount, utils.BytesToBase64(data.Signature))
+	return fmt.Sprintf("{ChannelID: %v, Nonce: %v, State: %v, Sender: %v, Recipient: %v, GroupId: %v, FullAmount: %v, Expiration: %v, Signer: %v, AuthorizedAmount: %v, Signature: %v, Credit: %v",
+		data.ChannelID, data.Nonce, data.State, utils.AddressToHex(&data.Sender), utils.AddressToHex(&data.Recipient), utils.BytesToBase64(data.GroupID[:]), data.FullAmount, data.Expiration, utils.AddressToHex(&data.Signer), data.AuthorizedAmount, utils.BytesToBase64(data.Signature), data.Credit)
 }
 
 // PaymentChannelService interface is API for payment channel functionality.
@@ -127,10 +140,16 @@ type PaymentChannelService interface {
 
 	//Get Channel from BlockChain
 	PaymentChannelFromBlockChain(key *PaymentChannelKey) (channel *PaymentChannelData, ok bool, err error)
+
+	// Refund adds the amount to the credit of the channel
+	Refund(key *PaymentChannelKey, amount *big.Int) (err error)
 }
 
 // PaymentErrorCode contains all types of errors which we need to handle on the
 // client side.
+//
+// Deprecated: the client handles the Reason of the PaymentError, the code is
+// derived from the gRPC code of the reason.
 type PaymentErrorCode int
 
 const (
@@ -147,21 +166,47 @@ const (
 	IncorrectNonce PaymentErrorCode = 4
 )
 
+func paymentErrorCode(code codes.Code) PaymentErrorCode {
+	switch code {
+	case codes.Unauthenticated:
+		return Unauthenticated
+	case codes.FailedPrecondition:
+		return FailedPrecondition
+	case errs.IncorrectNonceCode:
+		return IncorrectNonce
+	}
+	return Internal
+}
+
 // PaymentError contains error code and message and implements Error interface.
 type PaymentError struct {
 	// Code is error code
 	Code PaymentErrorCode
+	// Reason is the definition of the error in the error catalogue
+	Reason *errs.Definition
 	// Message is message
 	Message string
+	// Metadata is returned to the client in the ErrorInfo detail
+	Metadata map[string]string
 }
 
-// NewPaymentError constructs new PaymentError instance with given error code
+// NewPaymentError constructs new PaymentError instance with given reason
 // and message.
-func NewPaymentError(code PaymentErrorCode, format string, msg ...any) *PaymentError {
-	if len(msg) == 0 {
-		return &PaymentError{Code: code, Message: format}
+func NewPaymentError(reason *errs.Definition, format string, msg ...any) *PaymentError {
+	err := &PaymentError{Code: paymentErrorCode(reason.Code), Reason: reason, Message: format}
+	if len(msg) > 0 {
+		err.Message = fmt.Sprintf(format, msg...)
+	}
+	return err
+}
+
+// With adds the metadata of the error
+func (err *PaymentError) With(key string, value any) *PaymentError {
+	if err.Metadata == nil {
+		err.Metadata = make(map[string]string)
 	}
-	return &PaymentError{Code: code, Message: fmt.Sprintf(format, msg...)}
+	err.Metadata[key] = fmt.Sprint(value)
+	return err
 }
 
 func (err *PaymentError) Error() string {
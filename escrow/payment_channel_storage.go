@@ -8,6 +8,7 @@ import (
 	"reflect"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/config"
 	"github.com/singnet/snet-daemon/v6/storage"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -92,28 +93,54 @@ func (storage *PaymentChannelStorage) CompareAndSwap(key *PaymentChannelKey, pre
 type BlockchainChannelReader struct {
 	readChannelFromBlockchain func(channelID *big.Int) (channel *blockchain.MultiPartyEscrowChannel, ok bool, err error)
 	recipientPaymentAddress   func() common.Address
+	// readLatestChannel reads the channel at the latest block, it's set only in the optimistic mode
+	readLatestChannel func(channelID *big.Int) (channel *blockchain.MultiPartyEscrowChannel, ok bool, err error)
+	unconfirmedLimit  *big.Int
 }
 
 // NewBlockchainChannelReader returns a new instance of blockchain channel reader
 func NewBlockchainChannelReader(processor blockchain.Processor, cfg *viper.Viper,
 	orgMetadata *blockchain.OrganizationMetaData) *BlockchainChannelReader {
-	return &BlockchainChannelReader{
+	reader := &BlockchainChannelReader{
 		readChannelFromBlockchain: processor.MultiPartyEscrowChannel,
 		recipientPaymentAddress: func() common.Address {
 			address := orgMetadata.GetPaymentAddress()
 			return address
 		},
 	}
+
+	settings, err := config.GetPaymentChannelConfirmation()
+	if err != nil {
+		zap.L().Warn("optimistic channel reads are disabled", zap.Error(err))
+		return reader
+	}
+	if settings.Optimistic && config.GetConfirmationDepth() > 0 {
+		reader.readLatestChannel = func(channelID *big.Int) (*blockchain.MultiPartyEscrowChannel, bool, error) {
+			return processor.MultiPartyEscrowChannelAt(channelID, nil)
+		}
+		reader.unconfirmedLimit = settings.UnconfirmedLimit
+	}
+	return reader
 }
 
 // GetChannelStateFromBlockchain returns channel state from Ethereum
 // blockchain. ok is false if the channel is not found.
 func (reader *BlockchainChannelReader) GetChannelStateFromBlockchain(key *PaymentChannelKey) (channel *PaymentChannelData, ok bool, err error) {
 	ch, ok, err := reader.readChannelFromBlockchain(key.ID)
-	if err != nil || !ok {
+	if err != nil {
 		zap.L().Warn("Unsuccessful GetChannelStateFromBlockchain", zap.Error(err), zap.Bool("ok", ok))
 		return
 	}
+	if reader.readLatestChannel != nil {
+		ch, ok, err = reader.addUnconfirmedDeposit(key.ID, ch, ok)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if !ok {
+		zap.L().Warn("Unsuccessful GetChannelStateFromBlockchain", zap.Bool("ok", ok))
+		return
+	}
 
 	recipientPaymentAddress := reader.recipientPaymentAddress()
 
@@ -138,6 +165,45 @@ func (reader *BlockchainChannelReader) GetChannelStateFromBlockchain(key *Paymen
 	}, true, nil
 }
 
+// addUnconfirmedDeposit adds the part of the channel value which isn't confirmed
+// yet to the confirmed channel state, the unconfirmed part is limited by unconfirmed_limit.
+// The confirmed state is returned as is when the latest channel has another nonce
+// because the claims and the channel extensions aren't accepted before the confirmation.
+func (reader *BlockchainChannelReader) addUnconfirmedDeposit(channelID *big.Int,
+	confirmed *blockchain.MultiPartyEscrowChannel, confirmedOk bool) (channel *blockchain.MultiPartyEscrowChannel, ok bool, err error) {
+	latest, latestOk, err := reader.readLatestChannel(channelID)
+	if err != nil || !latestOk {
+		return confirmed, confirmedOk, err
+	}
+
+	if !confirmedOk {
+		unconfirmed := *latest
+		unconfirmed.Value = minBigInt(latest.Value, reader.unconfirmedLimit)
+		zap.L().Debug("Unconfirmed channel is accepted", zap.Any("channelID", channelID), zap.Any("value", unconfirmed.Value))
+		return &unconfirmed, true, nil
+	}
+
+	if confirmed.Nonce.Cmp(latest.Nonce) != 0 {
+		return confirmed, true, nil
+	}
+
+	deposit := new(big.Int).Sub(latest.Value, confirmed.Value)
+	if deposit.Sign() <= 0 {
+		return confirmed, true, nil
+	}
+	merged := *confirmed
+	merged.Value = new(big.Int).Add(confirmed.Value, minBigInt(deposit, reader.unconfirmedLimit))
+	zap.L().Debug("Unconfirmed deposit is accepted", zap.Any("channelID", channelID), zap.Any("value", merged.Value))
+	return &merged, true, nil
+}
+
+func minBigInt(a, b *big.Int) *big.Int {
+	if a.Cmp(b) < 0 {
+		return new(big.Int).Set(a)
+	}
+	return new(big.Int).Set(b)
+}
+
 // MergeStorageAndBlockchainChannelState merges two instances of payment
 // channel: one read from storage, one from blockchain.
 func MergeStorageAndBlockchainChannelState(storage, blockchain *PaymentChannelData) (merged *PaymentChannelData) {
@@ -146,6 +212,8 @@ func MergeStorageAndBlockchainChannelState(storage, blockchain *PaymentChannelDa
 		return storage
 	}
 	if cmp < 0 {
+		// the credit isn't a part of the blockchain state
+		blockchain.Credit = storage.Credit
 		return blockchain
 	}
 
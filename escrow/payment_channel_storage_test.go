@@ -173,6 +173,68 @@ func (suite *BlockchainChannelReaderSuite) TestGetChannelStateIncorrectRecipient
 	assert.Nil(suite.T(), channel)
 }
 
+func (suite *BlockchainChannelReaderSuite) optimisticReader(confirmed, latest *blockchain.MultiPartyEscrowChannel) BlockchainChannelReader {
+	reader := suite.reader
+	reader.readChannelFromBlockchain = func(channelID *big.Int) (*blockchain.MultiPartyEscrowChannel, bool, error) {
+		return confirmed, confirmed != nil, nil
+	}
+	reader.readLatestChannel = func(channelID *big.Int) (*blockchain.MultiPartyEscrowChannel, bool, error) {
+		return latest, latest != nil, nil
+	}
+	reader.unconfirmedLimit = big.NewInt(100)
+	return reader
+}
+
+func (suite *BlockchainChannelReaderSuite) TestGetChannelStateUnconfirmedDeposit() {
+	latest := suite.mpeChannel()
+	latest.Value = big.NewInt(12375)
+	latest.Expiration = big.NewInt(200)
+	reader := suite.optimisticReader(suite.mpeChannel(), latest)
+
+	channel, ok, err := reader.GetChannelStateFromBlockchain(suite.channelKey())
+
+	assert.Nil(suite.T(), err, "Unexpected error: %v", err)
+	assert.True(suite.T(), ok)
+	expected := suite.channel()
+	expected.FullAmount = big.NewInt(12375)
+	assert.Equal(suite.T(), expected, channel)
+}
+
+func (suite *BlockchainChannelReaderSuite) TestGetChannelStateUnconfirmedDepositLimit() {
+	latest := suite.mpeChannel()
+	latest.Value = big.NewInt(20000)
+	reader := suite.optimisticReader(suite.mpeChannel(), latest)
+
+	channel, ok, err := reader.GetChannelStateFromBlockchain(suite.channelKey())
+
+	assert.Nil(suite.T(), err, "Unexpected error: %v", err)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), big.NewInt(12445), channel.FullAmount)
+}
+
+func (suite *BlockchainChannelReaderSuite) TestGetChannelStateUnconfirmedChannel() {
+	reader := suite.optimisticReader(nil, suite.mpeChannel())
+
+	channel, ok, err := reader.GetChannelStateFromBlockchain(suite.channelKey())
+
+	assert.Nil(suite.T(), err, "Unexpected error: %v", err)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), big.NewInt(100), channel.FullAmount)
+}
+
+func (suite *BlockchainChannelReaderSuite) TestGetChannelStateUnconfirmedClaim() {
+	latest := suite.mpeChannel()
+	latest.Nonce = big.NewInt(4)
+	latest.Value = big.NewInt(20000)
+	reader := suite.optimisticReader(suite.mpeChannel(), latest)
+
+	channel, ok, err := reader.GetChannelStateFromBlockchain(suite.channelKey())
+
+	assert.Nil(suite.T(), err, "Unexpected error: %v", err)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), suite.channel(), channel)
+}
+
 func (suite *PaymentChannelStorageSuite) TestNewPaymentChannelStorage() {
 	mpeStorage := storage.NewPrefixedAtomicStorage(storage.NewPrefixedAtomicStorage(suite.memoryStorage, "path1"), "path2")
 	err := mpeStorage.Put("key1", "value1")
@@ -0,0 +1,170 @@
+package escrow
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/utils"
+)
+
+// PaymentClient is the client side of the payments: it reads the channel
+// state from the daemon and signs the payment metadata of the escrow, prepaid
+// and free call payment types on behalf of the channel sender
+type PaymentClient struct {
+	privateKey      *ecdsa.PrivateKey
+	mpeAddress      func() common.Address
+	currentBlock    func() (*big.Int, error)
+	groupID         string
+	stateService    PaymentChannelStateServiceClient
+	tokenService    TokenServiceClient
+	freeCallService FreeCallStateServiceClient
+}
+
+// NewPaymentClient returns the client calling the state services of the daemon via conn
+func NewPaymentClient(conn grpc.ClientConnInterface, privateKey *ecdsa.PrivateKey, processor blockchain.Processor,
+	orgMetadata *blockchain.OrganizationMetaData) *PaymentClient {
+	return &PaymentClient{
+		privateKey:      privateKey,
+		mpeAddress:      processor.EscrowContractAddress,
+		currentBlock:    processor.CurrentBlock,
+		groupID:         orgMetadata.GetGroupIdString(),
+		stateService:    NewPaymentChannelStateServiceClient(conn),
+		tokenService:    NewTokenServiceClient(conn),
+		freeCallService: NewFreeCallStateServiceClient(conn),
+	}
+}
+
+// Address returns the address of the channel sender or of the free call user
+func (client *PaymentClient) Address() common.Address {
+	return utils.GetAddressFromPrivateKeyECDSA(client.privateKey)
+}
+
+// ChannelState returns the latest state of the channel known by the daemon
+func (client *PaymentClient) ChannelState(ctx context.Context, channelID *big.Int) (*ChannelStateReply, error) {
+	currentBlock, err := client.currentBlock()
+	if err != nil {
+		return nil, err
+	}
+	message := ChannelStateMessage(client.mpeAddress(), channelID, currentBlock.Uint64())
+	return client.stateService.GetChannelState(ctx, &ChannelStateRequest{
+		ChannelId:    bigIntToBytes(channelID),
+		Signature:    utils.GetSignature(message, client.privateKey),
+		CurrentBlock: currentBlock.Uint64(),
+	})
+}
+
+// EscrowPayment returns the metadata of the call paying the price, the amount
+// signed by the payment is the latest signed amount of the channel plus the
+// price, the credit of the channel pays the price first
+func (client *PaymentClient) EscrowPayment(ctx context.Context, channelID *big.Int, price *big.Int) (metadata.MD, error) {
+	nonce, amount, err := client.nextAmount(ctx, channelID, price, true)
+	if err != nil {
+		return nil, err
+	}
+	signature := utils.GetSignature(PaymentMessage(client.mpeAddress(), channelID, nonce, amount), client.privateKey)
+	return metadata.Pairs(
+		handler.PaymentTypeHeader, EscrowPaymentType,
+		handler.PaymentChannelIDHeader, channelID.String(),
+		handler.PaymentChannelNonceHeader, nonce.String(),
+		handler.PaymentChannelAmountHeader, amount.String(),
+		handler.PaymentChannelSignatureHeader, string(signature),
+	), nil
+}
+
+// PrepaidPayment returns the metadata of the prepaid call, the token of the
+// call is requested with the signature of the latest signed amount of the
+// channel plus the amount. The zero amount renews the token of the channel.
+func (client *PaymentClient) PrepaidPayment(ctx context.Context, channelID *big.Int, amount *big.Int) (metadata.MD, error) {
+	nonce, signedAmount, err := client.nextAmount(ctx, channelID, amount, false)
+	if err != nil {
+		return nil, err
+	}
+	currentBlock, err := client.currentBlock()
+	if err != nil {
+		return nil, err
+	}
+	claimSignature := utils.GetSignature(PaymentMessage(client.mpeAddress(), channelID, nonce, signedAmount), client.privateKey)
+	reply, err := client.tokenService.GetToken(ctx, &TokenRequest{
+		ChannelId:      channelID.Uint64(),
+		CurrentNonce:   nonce.Uint64(),
+		SignedAmount:   signedAmount.Uint64(),
+		Signature:      utils.GetSignature(TokenRequestMessage(claimSignature, currentBlock.Uint64()), client.privateKey),
+		CurrentBlock:   currentBlock.Uint64(),
+		ClaimSignature: claimSignature,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't get prepaid token: %v", err)
+	}
+	return metadata.Pairs(
+		handler.PaymentTypeHeader, PrePaidPaymentType,
+		handler.PaymentChannelIDHeader, channelID.String(),
+		handler.PrePaidAuthTokenHeader, reply.GetToken(),
+	), nil
+}
+
+// FreeCallPayment returns the metadata of the free call, the free call token
+// of the user is requested from the daemon before the call. The user id is
+// allowed only for the trusted free call signers of the service.
+func (client *PaymentClient) FreeCallPayment(ctx context.Context, userID string) (metadata.MD, error) {
+	currentBlock, err := client.currentBlock()
+	if err != nil {
+		return nil, err
+	}
+	address := client.Address().Hex()
+	organizationID, serviceID := config.GetString(config.OrganizationId), config.GetString(config.ServiceId)
+	request := &GetFreeCallTokenRequest{
+		Address: address,
+		Signature: utils.GetSignature(FreeCallMessage(address, userID, organizationID, serviceID, client.groupID,
+			currentBlock, nil), client.privateKey),
+		CurrentBlock: currentBlock.Uint64(),
+	}
+	if userID != "" {
+		request.UserId = &userID
+	}
+	token, err := client.freeCallService.GetFreeCallToken(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("can't get free call token: %v", err)
+	}
+	signature := utils.GetSignature(FreeCallMessage(address, userID, organizationID, serviceID, client.groupID,
+		currentBlock, token.GetToken()), client.privateKey)
+	md := metadata.Pairs(
+		handler.PaymentTypeHeader, FreeCallPaymentType,
+		handler.FreeCallUserAddressHeader, address,
+		handler.CurrentBlockNumberHeader, currentBlock.String(),
+		handler.FreeCallAuthTokenHeader, string(token.GetToken()),
+		handler.PaymentChannelSignatureHeader, string(signature),
+	)
+	if userID != "" {
+		md.Set(handler.FreeCallUserIdHeader, userID)
+	}
+	return md, nil
+}
+
+// nextAmount returns the nonce of the channel and its latest signed amount plus
+// the price, the price is reduced by the credit of the channel like the daemon
+// does when useCredit is set
+func (client *PaymentClient) nextAmount(ctx context.Context, channelID *big.Int, price *big.Int, useCredit bool) (nonce *big.Int, amount *big.Int, err error) {
+	state, err := client.ChannelState(ctx, channelID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't get channel state: %v", err)
+	}
+	nonce = bytesToBigInt(state.GetCurrentNonce())
+	income := new(big.Int).Set(price)
+	if credit := bytesToBigInt(state.GetCreditAmount()); useCredit && credit.Sign() > 0 {
+		if credit.Cmp(income) > 0 {
+			credit = income
+		}
+		income.Sub(income, credit)
+	}
+	amount = new(big.Int).Add(bytesToBigInt(state.GetCurrentSignedAmount()), income)
+	return nonce, amount, nil
+}
@@ -0,0 +1,183 @@
+package escrow
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/utils"
+)
+
+// stateServiceClientStub calls the state service directly instead of gRPC
+type stateServiceClientStub struct {
+	server PaymentChannelStateServiceServer
+}
+
+func (stub *stateServiceClientStub) GetChannelState(ctx context.Context, in *ChannelStateRequest, opts ...grpc.CallOption) (*ChannelStateReply, error) {
+	return stub.server.GetChannelState(ctx, in)
+}
+
+type tokenServiceClientStub struct {
+	request *TokenRequest
+}
+
+func (stub *tokenServiceClientStub) GetToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenReply, error) {
+	stub.request = in
+	return &TokenReply{ChannelId: in.ChannelId, Token: "prepaid-token"}, nil
+}
+
+type freeCallServiceClientStub struct {
+	request *GetFreeCallTokenRequest
+}
+
+func (stub *freeCallServiceClientStub) GetFreeCallsAvailable(ctx context.Context, in *FreeCallStateRequest, opts ...grpc.CallOption) (*FreeCallStateReply, error) {
+	return &FreeCallStateReply{}, nil
+}
+
+func (stub *freeCallServiceClientStub) GetFreeCallToken(ctx context.Context, in *GetFreeCallTokenRequest, opts ...grpc.CallOption) (*FreeCallToken, error) {
+	stub.request = in
+	return &FreeCallToken{Token: []byte("free-call-token_100")}, nil
+}
+
+func newTestPaymentClient() *PaymentClient {
+	return &PaymentClient{
+		privateKey:   stateServiceTest.signerPrivateKey,
+		mpeAddress:   func() common.Address { return stateServiceTest.mpeAddress },
+		currentBlock: func() (*big.Int, error) { return stateServiceTest.ethereumBlock, nil },
+		groupID:      "test-group",
+		stateService: &stateServiceClientStub{server: &stateServiceTest.service},
+	}
+}
+
+func mdBigInt(t *testing.T, md metadata.MD, key string) *big.Int {
+	value, err := handler.GetBigInt(md, key)
+	require.Nil(t, err)
+	return value
+}
+
+func TestPaymentClientEscrowPayment(t *testing.T) {
+	stateServiceTest.channelServiceMock.Put(stateServiceTest.defaultChannelKey, stateServiceTest.defaultChannelData)
+	defer stateServiceTest.channelServiceMock.Clear()
+	client := newTestPaymentClient()
+
+	md, err := client.EscrowPayment(context.Background(), stateServiceTest.defaultChannelId, big.NewInt(10))
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{EscrowPaymentType}, md.Get(handler.PaymentTypeHeader))
+	payment := &Payment{
+		MpeContractAddress: stateServiceTest.mpeAddress,
+		ChannelID:          mdBigInt(t, md, handler.PaymentChannelIDHeader),
+		ChannelNonce:       mdBigInt(t, md, handler.PaymentChannelNonceHeader),
+		Amount:             mdBigInt(t, md, handler.PaymentChannelAmountHeader),
+	}
+	assert.Equal(t, stateServiceTest.defaultChannelId, payment.ChannelID)
+	assert.Equal(t, big.NewInt(3), payment.ChannelNonce)
+	assert.Equal(t, big.NewInt(12355), payment.Amount)
+	signature, grpcErr := handler.GetBytes(md, handler.PaymentChannelSignatureHeader)
+	require.Nil(t, grpcErr)
+	payment.Signature = signature
+	signer, err := getSignerAddressFromPayment(payment)
+	require.Nil(t, err)
+	assert.Equal(t, stateServiceTest.signerAddress, *signer)
+}
+
+func TestPaymentClientEscrowPaymentWithCredit(t *testing.T) {
+	defer stateServiceTest.channelServiceMock.Clear()
+	client := newTestPaymentClient()
+	price := big.NewInt(10)
+
+	for credit, expected := range map[int64]int64{4: 12351, 10: 12345, 25: 12345} {
+		channel := *stateServiceTest.defaultChannelData
+		channel.Credit = big.NewInt(credit)
+		stateServiceTest.channelServiceMock.Put(stateServiceTest.defaultChannelKey, &channel)
+
+		md, err := client.EscrowPayment(context.Background(), stateServiceTest.defaultChannelId, price)
+		require.Nil(t, err)
+
+		amount := mdBigInt(t, md, handler.PaymentChannelAmountHeader)
+		assert.Equal(t, big.NewInt(expected), amount, "credit %v", credit)
+		income := new(big.Int).Sub(amount, channel.AuthorizedAmount)
+		_, err = checkIncome(income, channel.Credit, price)
+		assert.Nil(t, err, "credit %v", credit)
+	}
+}
+
+func TestPaymentClientChannelStateError(t *testing.T) {
+	client := newTestPaymentClient()
+
+	_, err := client.EscrowPayment(context.Background(), big.NewInt(1000), big.NewInt(10))
+
+	assert.ErrorContains(t, err, "channel is not found")
+}
+
+func TestPaymentClientPrepaidPayment(t *testing.T) {
+	stateServiceTest.channelServiceMock.Put(stateServiceTest.defaultChannelKey, stateServiceTest.defaultChannelData)
+	defer stateServiceTest.channelServiceMock.Clear()
+	tokenService := &tokenServiceClientStub{}
+	client := newTestPaymentClient()
+	client.tokenService = tokenService
+
+	md, err := client.PrepaidPayment(context.Background(), stateServiceTest.defaultChannelId, big.NewInt(100))
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{PrePaidPaymentType}, md.Get(handler.PaymentTypeHeader))
+	assert.Equal(t, []string{"prepaid-token"}, md.Get(handler.PrePaidAuthTokenHeader))
+	assert.Equal(t, stateServiceTest.defaultChannelId, mdBigInt(t, md, handler.PaymentChannelIDHeader))
+
+	request := tokenService.request
+	assert.Equal(t, uint64(3), request.CurrentNonce)
+	assert.Equal(t, uint64(12445), request.SignedAmount)
+	assert.Equal(t, stateServiceTest.ethereumBlock.Uint64(), request.CurrentBlock)
+	signer, err := getSignerAddressFromPayment(&Payment{
+		MpeContractAddress: stateServiceTest.mpeAddress,
+		ChannelID:          stateServiceTest.defaultChannelId,
+		ChannelNonce:       big.NewInt(3),
+		Amount:             big.NewInt(12445),
+		Signature:          request.ClaimSignature,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, stateServiceTest.signerAddress, *signer)
+	signer, err = utils.GetSignerAddressFromMessage(TokenRequestMessage(request.ClaimSignature, request.CurrentBlock), request.Signature)
+	require.Nil(t, err)
+	assert.Equal(t, stateServiceTest.signerAddress, *signer)
+}
+
+func TestPaymentClientFreeCallPayment(t *testing.T) {
+	freeCallService := &freeCallServiceClientStub{}
+	client := newTestPaymentClient()
+	client.freeCallService = freeCallService
+
+	md, err := client.FreeCallPayment(context.Background(), "user@example.com")
+	require.Nil(t, err)
+
+	request := freeCallService.request
+	assert.Equal(t, stateServiceTest.signerAddress.Hex(), request.GetAddress())
+	assert.Equal(t, "user@example.com", request.GetUserId())
+	signer, err := getAddressFromSignatureForNewFreeCallToken(request, "test-group")
+	require.Nil(t, err)
+	assert.Equal(t, stateServiceTest.signerAddress, *signer)
+
+	assert.Equal(t, []string{FreeCallPaymentType}, md.Get(handler.PaymentTypeHeader))
+	assert.Equal(t, []string{"user@example.com"}, md.Get(handler.FreeCallUserIdHeader))
+	signature, grpcErr := handler.GetBytes(md, handler.PaymentChannelSignatureHeader)
+	require.Nil(t, grpcErr)
+	token, grpcErr := handler.GetBytes(md, handler.FreeCallAuthTokenHeader)
+	require.Nil(t, grpcErr)
+	signer, err = getAddressFromSigForFreeCall(&FreeCallPayment{
+		Address:            md.Get(handler.FreeCallUserAddressHeader)[0],
+		UserID:             "user@example.com",
+		GroupId:            "test-group",
+		CurrentBlockNumber: mdBigInt(t, md, handler.CurrentBlockNumberHeader),
+		AuthToken:          token,
+		Signature:          signature,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, stateServiceTest.signerAddress, *signer)
+}
@@ -4,9 +4,9 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
@@ -58,16 +58,41 @@ func (h *paymentChannelPaymentHandler) Payment(context *handler.GrpcStreamContex
 
 	income := big.NewInt(0)
 	income.Sub(internalPayment.Amount, transaction.Channel().AuthorizedAmount)
-	e = h.incomeValidator.Validate(&IncomeStreamData{Income: income, GrpcContext: context})
+	incomeData := &IncomeStreamData{Income: income, GrpcContext: context, Credit: transaction.Channel().CreditAmount()}
+	e = h.incomeValidator.Validate(incomeData)
 	if e != nil {
 		// Make sure the transaction is rolled back, else this will cause a lock on the channel
 		transaction.Rollback()
 		return nil, paymentErrorToGrpcError(e)
 	}
+	useChannelCredit(transaction, incomeData.UsedCredit)
+	addChannelCredit(transaction, incomeData.Overpaid)
 
 	return transaction, nil
 }
 
+// creditPayment is the payment transaction which can be paid by the channel credit
+type creditPayment interface {
+	useCredit(amount *big.Int)
+	addCredit(amount *big.Int)
+}
+
+// useChannelCredit sets the part of the channel credit which pays the call, the
+// credit is deducted when the transaction is committed
+func useChannelCredit(transaction PaymentTransaction, usedCredit *big.Int) {
+	if payment, ok := transaction.(creditPayment); ok && usedCredit != nil {
+		payment.useCredit(usedCredit)
+	}
+}
+
+// addChannelCredit sets the overpaid part of the income, it's added to the
+// channel credit when the transaction is committed
+func addChannelCredit(transaction PaymentTransaction, overpaid *big.Int) {
+	if payment, ok := transaction.(creditPayment); ok && overpaid != nil {
+		payment.addCredit(overpaid)
+	}
+}
+
 func (h *paymentChannelPaymentHandler) getPaymentFromContext(context *handler.GrpcStreamContext) (payment *Payment, err *handler.GrpcError) {
 	channelID, err := handler.GetBigInt(context.MD, handler.PaymentChannelIDHeader)
 	if err != nil {
@@ -131,12 +156,12 @@ func PublishChannelStats(payment handler.Payment, currentBlock func() (*big.Int,
 
 	block, err := currentBlock()
 	if err != nil {
-		return handler.NewGrpcErrorf(codes.Internal, "Unable to get latest block")
+		return handler.NewGrpcErrorFrom(errs.ErrBlockUnavailable.New("Unable to get latest block"))
 	}
 	status := metrics.Publish(channelStats, meteringURL, commonStats, block)
 	if !status {
 		zap.L().Warn("Payment handler unable to post latest off-chain Channel state on contract API Endpoint for metering", zap.String("meteringURL", meteringURL))
-		return handler.NewGrpcErrorf(codes.Internal, "Unable to publish status error")
+		return handler.NewGrpcErrorFrom(errs.ErrMeteringFailed.New("Unable to publish status error"))
 	}
 	return nil
 }
@@ -150,23 +175,14 @@ func paymentErrorToGrpcError(err error) *handler.GrpcError {
 		return nil
 	}
 
-	if _, ok := err.(*PaymentError); !ok {
-		return handler.NewGrpcErrorf(codes.Internal, "internal error: %v", err)
-	}
-
-	var grpcCode codes.Code
-	switch err.(*PaymentError).Code {
-	case Internal:
-		grpcCode = codes.Internal
-	case Unauthenticated:
-		grpcCode = codes.Unauthenticated
-	case FailedPrecondition:
-		grpcCode = codes.FailedPrecondition
-	case IncorrectNonce:
-		grpcCode = handler.IncorrectNonce
-	default:
-		grpcCode = codes.Internal
+	paymentErr, ok := err.(*PaymentError)
+	if !ok {
+		return handler.NewGrpcErrorFrom(errs.ErrPaymentInternal.New("internal error: %v", err))
 	}
 
-	return handler.NewGrpcError(grpcCode, err.(*PaymentError).Message)
+	return handler.NewGrpcErrorFrom(&errs.Error{
+		Definition: paymentErr.Reason,
+		Message:    paymentErr.Message,
+		Metadata:   paymentErr.Metadata,
+	})
 }
@@ -2,11 +2,11 @@ package escrow
 
 import (
 	"math/big"
-	"reflect"
 	"strconv"
 	"testing"
 
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -16,8 +16,20 @@ import (
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 )
 
+// assertGrpcError compares the code, the message and the ErrorInfo details of the errors
+func assertGrpcError(t *testing.T, expected, actual *handler.GrpcError) bool {
+	t.Helper()
+	if expected == nil || actual == nil {
+		return assert.Equal(t, expected, actual)
+	}
+	return assert.Equal(t, expected.Status.Code(), actual.Status.Code()) &&
+		assert.Equal(t, expected.Status.Message(), actual.Status.Message()) &&
+		assert.True(t, proto.Equal(expected.Status.Proto(), actual.Status.Proto()), "details of %v", actual)
+}
+
 type PaymentHandlerTestSuite struct {
 	suite.Suite
 
@@ -85,7 +97,7 @@ func (suite *PaymentHandlerTestSuite) TestGetPaymentNoChannelId() {
 
 	payment, err := suite.paymentHandler.Payment(context)
 
-	assert.Equal(suite.T(), handler.NewGrpcError(codes.InvalidArgument, "missing \"snet-payment-channel-id\""), err)
+	assert.Equal(suite.T(), handler.NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"snet-payment-channel-id\"").With("header", "snet-payment-channel-id")), err)
 	assert.Nil(suite.T(), payment)
 }
 
@@ -96,7 +108,7 @@ func (suite *PaymentHandlerTestSuite) TestGetPaymentNoChannelNonce() {
 
 	payment, err := suite.paymentHandler.Payment(context)
 
-	assert.Equal(suite.T(), handler.NewGrpcError(codes.InvalidArgument, "missing \"snet-payment-channel-nonce\""), err)
+	assert.Equal(suite.T(), handler.NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"snet-payment-channel-nonce\"").With("header", "snet-payment-channel-nonce")), err)
 	assert.Nil(suite.T(), payment)
 }
 
@@ -107,7 +119,7 @@ func (suite *PaymentHandlerTestSuite) TestGetPaymentNoChannelAmount() {
 
 	payment, err := suite.paymentHandler.Payment(context)
 
-	assert.Equal(suite.T(), handler.NewGrpcError(codes.InvalidArgument, "missing \"snet-payment-channel-amount\""), err)
+	assert.Equal(suite.T(), handler.NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"snet-payment-channel-amount\"").With("header", "snet-payment-channel-amount")), err)
 	assert.Nil(suite.T(), payment)
 }
 
@@ -118,7 +130,7 @@ func (suite *PaymentHandlerTestSuite) TestGetPaymentNoSignature() {
 
 	payment, err := suite.paymentHandler.Payment(context)
 
-	assert.Equal(suite.T(), handler.NewGrpcError(codes.InvalidArgument, "missing \"snet-payment-channel-signature-bin\""), err)
+	assert.Equal(suite.T(), handler.NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"snet-payment-channel-signature-bin\"").With("header", "snet-payment-channel-signature-bin")), err)
 	assert.Nil(suite.T(), payment)
 }
 
@@ -126,24 +138,26 @@ func (suite *PaymentHandlerTestSuite) TestStartTransactionError() {
 	context := suite.grpcContext(func(md *metadata.MD) {})
 	paymentHandler := suite.paymentHandler
 	paymentHandler.service = &paymentChannelServiceMock{
-		err: NewPaymentError(FailedPrecondition, "another transaction in progress"),
+		err: NewPaymentError(errs.ErrChannelBusy, "another transaction in progress"),
 	}
 
 	payment, err := paymentHandler.Payment(context)
 
-	assert.Equal(suite.T(), handler.NewGrpcError(codes.FailedPrecondition, "another transaction in progress"), err)
+	assertGrpcError(suite.T(), handler.NewGrpcErrorFrom(errs.ErrChannelBusy.New("another transaction in progress")), err)
+	assert.Equal(suite.T(), codes.FailedPrecondition, err.Status.Code())
 	assert.Nil(suite.T(), payment)
 }
 
 func (suite *PaymentHandlerTestSuite) TestValidatePaymentIncorrectIncome() {
 	context := suite.grpcContext(func(md *metadata.MD) {})
-	incomeErr := NewPaymentError(Unauthenticated, "incorrect payment income: \"45\", expected \"46\"")
+	incomeErr := NewPaymentError(errs.ErrIncomeMismatch, "incorrect payment income: \"45\", expected \"46\"")
 	paymentHandler := suite.paymentHandler
 	paymentHandler.incomeValidator = &incomeValidatorMockType{err: incomeErr}
 
 	payment, err := paymentHandler.Payment(context)
 
-	assert.Equal(suite.T(), handler.NewGrpcError(codes.Unauthenticated, "incorrect payment income: \"45\", expected \"46\""), err)
+	assertGrpcError(suite.T(), handler.NewGrpcErrorFrom(errs.ErrIncomeMismatch.New("incorrect payment income: \"45\", expected \"46\"")), err)
+	assert.Equal(suite.T(), codes.Unauthenticated, err.Status.Code())
 	assert.Nil(suite.T(), payment)
 }
 
@@ -168,16 +182,14 @@ func Test_paymentChannelPaymentHandler_PublishChannelStats(t *testing.T) {
 			config.Vip().Set(config.MeteringEndpoint, "https://bkq2d3zjl4.execute-api.eu-west-1.amazonaws.com/main")
 		}},
 
-		{name: "", wantErr: handler.NewGrpcErrorf(codes.Internal, "Unable to publish status error"), setupFunc: func() {
+		{name: "", wantErr: handler.NewGrpcErrorFrom(errs.ErrMeteringFailed.New("Unable to publish status error")), setupFunc: func() {
 			config.Vip().Set(config.MeteringEndpoint, "badurl")
 		}},
 	}
 	for _, tt := range tests {
 		tt.setupFunc()
 		t.Run(tt.name, func(t *testing.T) {
-			if gotErr := PublishChannelStats(payment, mocked.CurrentBlock); !reflect.DeepEqual(gotErr, tt.wantErr) {
-				t.Errorf("paymentChannelPaymentHandler.PublishChannelStats() = %v, want %v", gotErr, tt.wantErr)
-			}
+			assertGrpcError(t, tt.wantErr, PublishChannelStats(payment, mocked.CurrentBlock))
 		})
 	}
 }
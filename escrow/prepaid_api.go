@@ -34,6 +34,9 @@ func (transaction prePaidTransactionImpl) ChannelId() *big.Int {
 func (transaction prePaidTransactionImpl) Price() *big.Int {
 	return transaction.price
 }
+func (transaction prePaidTransactionImpl) ChargedAmount() *big.Int {
+	return transaction.price
+}
 func (transaction prePaidTransactionImpl) Commit() error {
 	return nil
 }
@@ -0,0 +1,148 @@
+package escrow
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+)
+
+// ReorgWatcher detects the reorganizations of the chain and reconciles the
+// channels of the storage with the blockchain state after them. The watcher
+// remembers the hash of the latest block and checks it on the next tick, the
+// hash is changed when the block was replaced by the reorganization.
+type ReorgWatcher struct {
+	currentBlock func() (*big.Int, error)
+	blockHash    func(number *big.Int) (common.Hash, error)
+	storage      *PaymentChannelStorage
+	reader       *BlockchainChannelReader
+	interval     time.Duration
+
+	lastBlock *big.Int
+	lastHash  common.Hash
+
+	stop chan struct{}
+	wait *sync.WaitGroup
+}
+
+// NewReorgWatcher returns a new instance of the reorganization watcher
+func NewReorgWatcher(processor blockchain.Processor, storage *PaymentChannelStorage,
+	reader *BlockchainChannelReader, interval time.Duration) *ReorgWatcher {
+	return &ReorgWatcher{
+		currentBlock: processor.CurrentBlock,
+		blockHash:    processor.BlockHash,
+		storage:      storage,
+		reader:       reader,
+		interval:     interval,
+		stop:         make(chan struct{}),
+		wait:         new(sync.WaitGroup),
+	}
+}
+
+// Start runs the checks every interval
+func (watcher *ReorgWatcher) Start() {
+	zap.L().Info("chain reorganization watcher is started", zap.Duration("interval", watcher.interval))
+	watcher.wait.Add(1)
+	go func() {
+		defer watcher.wait.Done()
+		ticker := time.NewTicker(watcher.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watcher.stop:
+				return
+			case <-ticker.C:
+				watcher.check()
+			}
+		}
+	}()
+}
+
+// Close stops the checks and waits the running one
+func (watcher *ReorgWatcher) Close() {
+	close(watcher.stop)
+	watcher.wait.Wait()
+}
+
+// check reconciles the storage when the remembered block was replaced and
+// remembers the latest block
+func (watcher *ReorgWatcher) check() (reorganized bool) {
+	if watcher.lastBlock != nil {
+		hash, err := watcher.blockHash(watcher.lastBlock)
+		if err != nil {
+			zap.L().Warn("can't check chain reorganization", zap.Error(err))
+			return false
+		}
+		if hash != watcher.lastHash {
+			zap.L().Warn("chain reorganization is detected, reconciling payment channels",
+				zap.Any("block", watcher.lastBlock),
+				zap.String("oldHash", watcher.lastHash.Hex()),
+				zap.String("newHash", hash.Hex()))
+			watcher.reconcile()
+			reorganized = true
+		}
+	}
+
+	latestBlock, err := watcher.currentBlock()
+	if err != nil {
+		zap.L().Warn("can't check chain reorganization", zap.Error(err))
+		return
+	}
+	hash, err := watcher.blockHash(latestBlock)
+	if err != nil {
+		zap.L().Warn("can't check chain reorganization", zap.Error(err))
+		return
+	}
+	watcher.lastBlock, watcher.lastHash = latestBlock, hash
+	return
+}
+
+// reconcile replaces the channel value and expiration of the storage by the
+// blockchain ones. The channels with another nonce are skipped as the
+// blockchain state is merged with the storage on the next read of the channel.
+func (watcher *ReorgWatcher) reconcile() {
+	states, err := watcher.storage.GetAll()
+	if err != nil {
+		zap.L().Error("can't read payment channels from storage", zap.Error(err))
+		return
+	}
+
+	for _, state := range states {
+		key := &PaymentChannelKey{ID: state.ChannelID}
+		channel, ok, err := watcher.reader.GetChannelStateFromBlockchain(key)
+		if err != nil {
+			zap.L().Error("can't read payment channel from blockchain", zap.Stringer("key", key), zap.Error(err))
+			continue
+		}
+		if !ok {
+			zap.L().Warn("payment channel isn't found in blockchain after chain reorganization", zap.Stringer("key", key))
+			continue
+		}
+		if state.Nonce.Cmp(channel.Nonce) != 0 ||
+			(state.FullAmount.Cmp(channel.FullAmount) == 0 && state.Expiration.Cmp(channel.Expiration) == 0) {
+			continue
+		}
+
+		reconciled := *state
+		reconciled.FullAmount = channel.FullAmount
+		reconciled.Expiration = channel.Expiration
+		ok, err = watcher.storage.CompareAndSwap(key, state, &reconciled)
+		if err != nil || !ok {
+			// the channel was updated concurrently, the next read merges it with the blockchain
+			zap.L().Warn("can't reconcile payment channel", zap.Stringer("key", key), zap.Error(err))
+			continue
+		}
+		zap.L().Info("payment channel is reconciled after chain reorganization", zap.Stringer("key", key),
+			zap.Any("fullAmount", reconciled.FullAmount), zap.Any("expiration", reconciled.Expiration))
+		if reconciled.AuthorizedAmount.Cmp(reconciled.FullAmount) > 0 {
+			zap.L().Error("authorized amount of payment channel exceeds its value after chain reorganization",
+				zap.Stringer("key", key),
+				zap.Any("authorizedAmount", reconciled.AuthorizedAmount),
+				zap.Any("fullAmount", reconciled.FullAmount))
+		}
+	}
+}
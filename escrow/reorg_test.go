@@ -0,0 +1,66 @@
+package escrow
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorgWatcher(t *testing.T) {
+	recipient := crypto.PubkeyToAddress(GenerateTestPrivateKey().PublicKey)
+	mpeChannel := &blockchain.MultiPartyEscrowChannel{
+		Recipient:  recipient,
+		Value:      big.NewInt(100),
+		Nonce:      big.NewInt(3),
+		Expiration: big.NewInt(1000),
+	}
+	channelStorage := NewPaymentChannelStorage(storage.NewMemStorage())
+	key := &PaymentChannelKey{ID: big.NewInt(42)}
+	stored := &PaymentChannelData{
+		ChannelID:        key.ID,
+		Nonce:            big.NewInt(3),
+		Recipient:        recipient,
+		FullAmount:       big.NewInt(150),
+		Expiration:       big.NewInt(1000),
+		AuthorizedAmount: big.NewInt(120),
+	}
+	require.Nil(t, channelStorage.Put(key, stored))
+
+	hashes := map[int64]common.Hash{10: {1}}
+	latestBlock := int64(10)
+	watcher := &ReorgWatcher{
+		currentBlock: func() (*big.Int, error) { return big.NewInt(latestBlock), nil },
+		blockHash: func(number *big.Int) (common.Hash, error) {
+			return hashes[number.Int64()], nil
+		},
+		storage: channelStorage,
+		reader: &BlockchainChannelReader{
+			readChannelFromBlockchain: func(channelID *big.Int) (*blockchain.MultiPartyEscrowChannel, bool, error) {
+				return mpeChannel, true, nil
+			},
+			recipientPaymentAddress: func() common.Address { return recipient },
+		},
+	}
+
+	assert.False(t, watcher.check())
+	latestBlock = 11
+	hashes[11] = common.Hash{2}
+	assert.False(t, watcher.check())
+	channel, _, _ := channelStorage.Get(key)
+	assert.Equal(t, big.NewInt(150), channel.FullAmount)
+
+	// the block 11 is replaced, the deposit is reverted
+	hashes[11] = common.Hash{3}
+	assert.True(t, watcher.check())
+	channel, _, _ = channelStorage.Get(key)
+	assert.Equal(t, big.NewInt(100), channel.FullAmount)
+	assert.Equal(t, big.NewInt(1000), channel.Expiration)
+	assert.Equal(t, big.NewInt(120), channel.AuthorizedAmount)
+	assert.Equal(t, watcher.lastHash, common.Hash{3})
+}
@@ -73,6 +73,17 @@ func NewPaymentChannelStateService(channelService PaymentChannelService, payment
 	}
 }
 
+// ChannelStateMessage returns the message of the channel state request which is
+// signed by the channel signer, sender or recipient
+func ChannelStateMessage(mpeAddress common.Address, channelID *big.Int, currentBlock uint64) []byte {
+	return bytes.Join([][]byte{
+		[]byte("__get_channel_state"),
+		mpeAddress.Bytes(),
+		bigIntToBytes(channelID),
+		math.U256Bytes(new(big.Int).SetUint64(currentBlock)),
+	}, nil)
+}
+
 // GetChannelState returns the latest state of the channel which id is passed
 // in request. To authenticate sender request should also contain the correct
 // signature of the channel id.
@@ -93,12 +104,7 @@ func (service *PaymentChannelStateService) GetChannelState(context context.Conte
 
 	channelID := bytesToBigInt(request.GetChannelId())
 	// signature verification
-	message := bytes.Join([][]byte{
-		[]byte("__get_channel_state"),
-		service.mpeAddress().Bytes(),
-		bigIntToBytes(channelID),
-		math.U256Bytes(big.NewInt(int64(request.CurrentBlock))),
-	}, nil)
+	message := ChannelStateMessage(service.mpeAddress(), channelID, request.CurrentBlock)
 	signature := request.GetSignature()
 
 	sender, err := utils.GetSignerAddressFromMessage(message, signature)
@@ -146,12 +152,14 @@ func (service *PaymentChannelStateService) GetChannelState(context context.Conte
 			CurrentSignature:     channel.Signature,
 			OldNonceSignedAmount: bigIntToBytes(payment.Amount),
 			OldNonceSignature:    payment.Signature,
+			CreditAmount:         creditAmountBytes(channel),
 		}, nil
 	}
 
 	if channel.Signature == nil {
 		return &ChannelStateReply{
 			CurrentNonce: bigIntToBytes(channel.Nonce),
+			CreditAmount: creditAmountBytes(channel),
 		}, nil
 	}
 
@@ -159,5 +167,14 @@ func (service *PaymentChannelStateService) GetChannelState(context context.Conte
 		CurrentNonce:        bigIntToBytes(channel.Nonce),
 		CurrentSignedAmount: bigIntToBytes(channel.AuthorizedAmount),
 		CurrentSignature:    channel.Signature,
+		CreditAmount:        creditAmountBytes(channel),
 	}, nil
 }
+
+// creditAmountBytes returns the credit of the channel, nil when the channel has no credit
+func creditAmountBytes(channel *PaymentChannelData) []byte {
+	if channel.CreditAmount().Sign() == 0 {
+		return nil
+	}
+	return bigIntToBytes(channel.CreditAmount())
+}
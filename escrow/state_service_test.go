@@ -148,6 +148,29 @@ func TestGetChannelState(t *testing.T) {
 
 }
 
+func TestGetChannelStateWithCredit(t *testing.T) {
+	channelData := *stateServiceTest.defaultChannelData
+	channelData.Credit = big.NewInt(42)
+	stateServiceTest.channelServiceMock.Put(
+		stateServiceTest.defaultChannelKey,
+		&channelData,
+	)
+	defer stateServiceTest.channelServiceMock.Clear()
+
+	reply, err := stateServiceTest.service.GetChannelState(
+		nil,
+		stateServiceTest.defaultRequest,
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &ChannelStateReply{
+		CurrentNonce:        bigIntToBytes(big.NewInt(3)),
+		CurrentSignedAmount: bigIntToBytes(big.NewInt(12345)),
+		CurrentSignature:    stateServiceTest.defaultChannelData.Signature,
+		CreditAmount:        bigIntToBytes(big.NewInt(42)),
+	}, reply)
+}
+
 func TestGetChannelStateWhenNonceDiffers(t *testing.T) {
 	previousSignature, _ := hex.DecodeString("0708090A0B")
 	previousChannelData := &PaymentChannelData{
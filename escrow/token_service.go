@@ -117,10 +117,7 @@ func (service *TokenService) getPayment(channelId *big.Int, latestAuthorizedAmou
 }
 
 func (service *TokenService) verifySignature(request *TokenRequest, channel *PaymentChannelData) (send *common.Address, err error) {
-	message := bytes.Join([][]byte{
-		request.GetClaimSignature(),
-		math.U256Bytes(big.NewInt(int64(request.CurrentBlock))),
-	}, nil)
+	message := TokenRequestMessage(request.GetClaimSignature(), request.CurrentBlock)
 	signature := request.GetSignature()
 
 	sender, err := utils.GetSignerAddressFromMessage(message, signature)
@@ -138,6 +135,15 @@ func (service *TokenService) verifySignature(request *TokenRequest, channel *Pay
 	return sender, nil
 }
 
+// TokenRequestMessage returns the message of the token request, it's the claim
+// signature of the signed amount followed by the current block
+func TokenRequestMessage(claimSignature []byte, currentBlock uint64) []byte {
+	return bytes.Join([][]byte{
+		claimSignature,
+		math.U256Bytes(new(big.Int).SetUint64(currentBlock)),
+	}, nil)
+}
+
 func (service *TokenService) GetToken(ctx context.Context, request *TokenRequest) (reply *TokenReply, err error) {
 
 	// Check for update state
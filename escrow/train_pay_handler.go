@@ -1,7 +1,9 @@
 package escrow
 
 import (
+	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"go.uber.org/zap"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/training"
 )
 
 const (
@@ -20,6 +23,7 @@ type trainUnaryPaymentHandler struct {
 	mpeContractAddress func() common.Address
 	incomeValidator    IncomeUnaryValidator
 	currentBlock       func() (*big.Int, error)
+	billing            *training.Billing
 }
 
 type trainStreamPaymentHandler struct {
@@ -27,6 +31,66 @@ type trainStreamPaymentHandler struct {
 	mpeContractAddress func() common.Address
 	currentBlock       func() (*big.Int, error)
 	incomeValidator    IncomeStreamValidator
+	billing            *training.Billing
+}
+
+// trainPayment is the payment of the validation or training of the model, it's
+// committed as usual and recorded by the billing until the job is finished
+type trainPayment struct {
+	*paymentTransaction
+	modelID string
+	method  string
+}
+
+func newTrainPayment(transaction PaymentTransaction, md metadata.MD, fullMethod string) handler.Payment {
+	payment, ok := transaction.(*paymentTransaction)
+	modelID := md.Get(handler.TrainingModelId)
+	if !ok || len(modelID) == 0 {
+		return transaction
+	}
+	return &trainPayment{
+		paymentTransaction: payment,
+		modelID:            modelID[0],
+		method:             fullMethod[strings.LastIndex(fullMethod, "/")+1:],
+	}
+}
+
+// completeTrainPayment commits the payment and records it in the billing until the job is finished
+func completeTrainPayment(payment handler.Payment, billing *training.Billing, currentBlock func() (*big.Int, error)) (err *handler.GrpcError) {
+	transaction, ok := payment.(*trainPayment)
+	if !ok {
+		return completePayment(payment, currentBlock)
+	}
+	if err = completePayment(transaction.paymentTransaction, currentBlock); err != nil || billing == nil {
+		return err
+	}
+	channel := transaction.Channel()
+	e := billing.Hold(transaction.modelID, &training.ModelCharge{
+		ChargeId:  fmt.Sprintf("%v/%v", PaymentID(channel.ChannelID, channel.Nonce), transaction.payment.Amount),
+		Method:    transaction.method,
+		ChannelId: channel.ChannelID,
+		Sender:    channel.Sender.Hex(),
+		Amount:    transaction.PaidAmount(),
+	})
+	if e != nil {
+		// the payment is committed already, it's captured as without the billing
+		zap.L().Error("[completeTrainPayment] payment isn't held", zap.String("modelID", transaction.modelID), zap.Error(e))
+	}
+	return nil
+}
+
+func completePayment(payment handler.Payment, currentBlock func() (*big.Int, error)) (err *handler.GrpcError) {
+	if err = paymentErrorToGrpcError(payment.(*paymentTransaction).Commit()); err == nil {
+		go PublishChannelStats(payment, currentBlock)
+	}
+	return err
+}
+
+func rollbackPayment(payment handler.Payment) (err *handler.GrpcError) {
+	if transaction, ok := payment.(*trainPayment); ok {
+		payment = transaction.paymentTransaction
+	}
+	return paymentErrorToGrpcError(payment.(*paymentTransaction).Rollback())
 }
 
 func (t trainStreamPaymentHandler) Type() (typ string) {
@@ -46,25 +110,24 @@ func (t trainStreamPaymentHandler) Payment(context *handler.GrpcStreamContext) (
 
 	income := big.NewInt(0)
 	income.Sub(internalPayment.Amount, transaction.Channel().AuthorizedAmount)
-	e = t.incomeValidator.Validate(&IncomeStreamData{Income: income, GrpcContext: context})
+	incomeData := &IncomeStreamData{Income: income, GrpcContext: context, Credit: transaction.Channel().CreditAmount()}
+	e = t.incomeValidator.Validate(incomeData)
 	if e != nil {
 		// Make sure the transaction is rolled back, else this will cause a lock on the channel
 		transaction.Rollback()
 		return nil, paymentErrorToGrpcError(e)
 	}
+	useChannelCredit(transaction, incomeData.UsedCredit)
 
-	return transaction, nil
+	return newTrainPayment(transaction, context.MD, context.Info.FullMethod), nil
 }
 
 func (t trainStreamPaymentHandler) Complete(payment handler.Payment) (err *handler.GrpcError) {
-	if err = paymentErrorToGrpcError(payment.(*paymentTransaction).Commit()); err == nil {
-		go PublishChannelStats(payment, t.currentBlock)
-	}
-	return err
+	return completeTrainPayment(payment, t.billing, t.currentBlock)
 }
 
 func (t trainStreamPaymentHandler) CompleteAfterError(payment handler.Payment, result error) (err *handler.GrpcError) {
-	return paymentErrorToGrpcError(payment.(*paymentTransaction).Rollback())
+	return rollbackPayment(payment)
 }
 
 func (t trainStreamPaymentHandler) getPaymentFromContext(md metadata.MD) (payment *Payment, err *handler.GrpcError) {
@@ -101,12 +164,14 @@ func (t trainStreamPaymentHandler) getPaymentFromContext(md metadata.MD) (paymen
 func NewTrainUnaryPaymentHandler(
 	service PaymentChannelService,
 	processor blockchain.Processor,
-	incomeValidator IncomeUnaryValidator) handler.UnaryPaymentHandler {
+	incomeValidator IncomeUnaryValidator,
+	billing *training.Billing) handler.UnaryPaymentHandler {
 	return &trainUnaryPaymentHandler{
 		service:            service,
 		mpeContractAddress: processor.EscrowContractAddress,
 		currentBlock:       processor.CurrentBlock,
 		incomeValidator:    incomeValidator,
+		billing:            billing,
 	}
 }
 
@@ -114,12 +179,14 @@ func NewTrainUnaryPaymentHandler(
 func NewTrainStreamPaymentHandler(
 	service PaymentChannelService,
 	processor blockchain.Processor,
-	incomeValidator IncomeStreamValidator) handler.StreamPaymentHandler {
+	incomeValidator IncomeStreamValidator,
+	billing *training.Billing) handler.StreamPaymentHandler {
 	return &trainStreamPaymentHandler{
 		service:            service,
 		mpeContractAddress: processor.EscrowContractAddress,
 		currentBlock:       processor.CurrentBlock,
 		incomeValidator:    incomeValidator,
+		billing:            billing,
 	}
 }
 
@@ -141,14 +208,16 @@ func (h *trainUnaryPaymentHandler) Payment(context *handler.GrpcUnaryContext) (p
 	income := big.NewInt(0)
 	zap.L().Debug("[trainUnaryPaymentHandler.Payment]", zap.Any("Amount", internalPayment.Amount), zap.Any("AuthorizedAmount", transaction.Channel().AuthorizedAmount))
 	income.Sub(internalPayment.Amount, transaction.Channel().AuthorizedAmount)
-	e = h.incomeValidator.Validate(&IncomeUnaryData{Income: income, GrpcContext: context})
+	incomeData := &IncomeUnaryData{Income: income, GrpcContext: context, Credit: transaction.Channel().CreditAmount()}
+	e = h.incomeValidator.Validate(incomeData)
 	if e != nil {
 		// Make sure the transaction is rolled back, else this will cause a lock on the channel
 		transaction.Rollback()
 		return nil, paymentErrorToGrpcError(e)
 	}
+	useChannelCredit(transaction, incomeData.UsedCredit)
 
-	return transaction, nil
+	return newTrainPayment(transaction, context.MD, context.Info.FullMethod), nil
 }
 
 func (h *trainUnaryPaymentHandler) getPaymentFromContext(md metadata.MD) (payment *Payment, err *handler.GrpcError) {
@@ -182,12 +251,9 @@ func (h *trainUnaryPaymentHandler) getPaymentFromContext(md metadata.MD) (paymen
 }
 
 func (h *trainUnaryPaymentHandler) Complete(payment handler.Payment) (err *handler.GrpcError) {
-	if err = paymentErrorToGrpcError(payment.(*paymentTransaction).Commit()); err == nil {
-		go PublishChannelStats(payment, h.currentBlock)
-	}
-	return err
+	return completeTrainPayment(payment, h.billing, h.currentBlock)
 }
 
 func (h *trainUnaryPaymentHandler) CompleteAfterError(payment handler.Payment, result error) (err *handler.GrpcError) {
-	return paymentErrorToGrpcError(payment.(*paymentTransaction).Rollback())
+	return rollbackPayment(payment)
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
 
 	"go.uber.org/zap"
@@ -23,7 +24,11 @@ const (
 	//Agreed constant value
 	AllowedUserPrefixSignature = "__authorized_user"
 
-	FreeCallTokenLifetime = 172800 // in blocks
+	FreeCallTokenLifetime = 172800 // in blocks of the Ethereum mainnet
+
+	// FreeCallTokenMaxDuration is the longest lifetime of the free call token,
+	// FreeCallTokenLifetime blocks of the Ethereum mainnet
+	FreeCallTokenMaxDuration = FreeCallTokenLifetime * config.ReferenceBlockTime
 )
 
 type FreeCallPaymentValidator struct {
@@ -51,8 +56,9 @@ func (validator *FreeCallPaymentValidator) NewFreeCallToken(userAddress string,
 		return nil, nil
 	}
 
-	blockExpiration := big.NewInt(FreeCallTokenLifetime)
-	if tokenLifetimeBlocks != nil && *tokenLifetimeBlocks <= FreeCallTokenLifetime {
+	maxLifetimeBlocks := config.BlocksIn(FreeCallTokenMaxDuration)
+	blockExpiration := new(big.Int).SetUint64(maxLifetimeBlocks)
+	if tokenLifetimeBlocks != nil && *tokenLifetimeBlocks <= maxLifetimeBlocks {
 		blockExpiration.SetUint64(*tokenLifetimeBlocks)
 	}
 
@@ -82,15 +88,8 @@ func ParseFreeCallToken(token []byte) (sig []byte, block *big.Int, err error) {
 
 func getAddressFromSignatureForNewFreeCallToken(request *GetFreeCallTokenRequest, groupID string) (signer *common.Address, err error) {
 
-	message := bytes.Join([][]byte{
-		[]byte(FreeCallPrefixSignature),
-		[]byte(request.GetAddress()),
-		[]byte(request.GetUserId()),
-		[]byte(config.GetString(config.OrganizationId)),
-		[]byte(config.GetString(config.ServiceId)),
-		[]byte(groupID),
-		bigIntToBytes(big.NewInt(int64(request.GetCurrentBlock()))),
-	}, nil)
+	message := FreeCallMessage(request.GetAddress(), request.GetUserId(), config.GetString(config.OrganizationId),
+		config.GetString(config.ServiceId), groupID, big.NewInt(int64(request.GetCurrentBlock())), nil)
 
 	signer, err = utils.GetSignerAddressFromMessage(message, request.Signature)
 	if err != nil {
@@ -122,18 +121,18 @@ func (validator *FreeCallPaymentValidator) CompareSignerAddrs(addr common.Addres
 	if addr == validator.freeCallSignerAddress {
 		return nil
 	}
-	return NewPaymentError(Unauthenticated, "payment signer %v is not valid", addr.Hex())
+	return NewPaymentError(errs.ErrInvalidSigner, "payment signer %v is not valid", addr.Hex())
 }
 
 func (validator *FreeCallPaymentValidator) Validate(payment *FreeCallPayment) (err error) {
 	tokenSignerAddress, err := validator.getSignerOfAuthTokenForFreeCall(payment)
 	if err != nil {
-		return NewPaymentError(Unauthenticated, "sign is not valid: %v", err)
+		return NewPaymentError(errs.ErrInvalidFreeCallToken, "sign is not valid: %v", err)
 	}
 
 	// check that free call token signed by daemon
 	if *tokenSignerAddress != validator.freeCallSignerAddress {
-		return NewPaymentError(Unauthenticated, "token sign is not valid")
+		return NewPaymentError(errs.ErrInvalidFreeCallToken, "token sign is not valid")
 	}
 
 	if err := validator.CheckIfBlockExpired(payment.AuthTokenExpiryBlockNumber); err != nil {
@@ -159,7 +158,8 @@ func NewChannelPaymentValidator(processor blockchain.Processor, metadata *blockc
 	return &ChannelPaymentValidator{
 		currentBlock: processor.CurrentBlock,
 		paymentExpirationThreshold: func() *big.Int {
-			return metadata.GetPaymentExpirationThreshold()
+			// the threshold of the metadata is in the blocks of the Ethereum mainnet
+			return config.ToNetworkBlocks(metadata.GetPaymentExpirationThreshold())
 		},
 	}
 }
@@ -172,33 +172,36 @@ func (validator *ChannelPaymentValidator) Validate(payment *Payment, channel *Pa
 
 	if payment.ChannelNonce.Cmp(channel.Nonce) != 0 {
 		zap.L().Warn("Incorrect nonce is sent by client", paymentFieldLog, channelFieldLog)
-		return NewPaymentError(IncorrectNonce, "incorrect payment channel nonce, latest: %v, sent: %v", channel.Nonce, payment.ChannelNonce)
+		return NewPaymentError(errs.ErrIncorrectNonce, "incorrect payment channel nonce, latest: %v, sent: %v", channel.Nonce, payment.ChannelNonce).
+			With("latest_nonce", channel.Nonce)
 	}
 
 	signerAddress, err := getSignerAddressFromPayment(payment)
 	if err != nil {
-		return NewPaymentError(Unauthenticated, "payment signature is not valid")
+		return NewPaymentError(errs.ErrInvalidSignature, "payment signature is not valid")
 	}
 
 	signerAddressFieldLog := zap.String("signerAddress", utils.AddressToHex(signerAddress))
 	if *signerAddress != channel.Signer && *signerAddress != channel.Sender {
 		zap.L().Warn("Channel signer is not equal to payment signer/sender", signerAddressFieldLog)
-		return NewPaymentError(Unauthenticated, "payment is not signed by channel signer/sender")
+		return NewPaymentError(errs.ErrInvalidSigner, "payment is not signed by channel signer/sender")
 	}
 	currentBlock, e := validator.currentBlock()
 	if e != nil {
-		return NewPaymentError(Internal, "cannot determine current block")
+		return NewPaymentError(errs.ErrBlockUnavailable, "cannot determine current block")
 	}
 	expirationThreshold := validator.paymentExpirationThreshold()
 	currentBlockWithThreshold := new(big.Int).Add(currentBlock, expirationThreshold)
 	if currentBlockWithThreshold.Cmp(channel.Expiration) >= 0 {
 		zap.L().Warn("Channel expiration time is after expiration threshold", zap.Any("currentBlock", currentBlock), zap.Any("expirationThreshold", expirationThreshold))
-		return NewPaymentError(Unauthenticated, "payment channel is near to be expired, expiration time: %v, current block: %v, expiration threshold: %v", channel.Expiration, currentBlock, expirationThreshold)
+		return NewPaymentError(errs.ErrChannelExpiring, "payment channel is near to be expired, expiration time: %v, current block: %v, expiration threshold: %v", channel.Expiration, currentBlock, expirationThreshold).
+			With("expiration", channel.Expiration)
 	}
 
 	if channel.FullAmount.Cmp(payment.Amount) < 0 {
 		zap.L().Warn("Not enough tokens on payment channel")
-		return NewPaymentError(Unauthenticated, "not enough tokens on payment channel, channel amount: %v, payment amount: %v", channel.FullAmount, payment.Amount)
+		return NewPaymentError(errs.ErrInsufficientFunds, "not enough tokens on payment channel, channel amount: %v, payment amount: %v", channel.FullAmount, payment.Amount).
+			With("channel_amount", channel.FullAmount)
 	}
 
 	return
@@ -212,7 +215,7 @@ func (validator *FreeCallPaymentValidator) compareWithLatestBlockNumber(blockNum
 	}
 	differenceInBlockNumber := blockNumberPassed.Sub(blockNumberPassed, latestBlockNumber)
 	if differenceInBlockNumber.Abs(differenceInBlockNumber).Uint64() > AllowedBlockDifference {
-		return fmt.Errorf("authentication failed as the signature passed has expired")
+		return NewPaymentError(errs.ErrInvalidFreeCallToken, "authentication failed as the signature passed has expired")
 	}
 	return nil
 }
@@ -224,7 +227,7 @@ func (validator *FreeCallPaymentValidator) CheckIfBlockExpired(expiredBlock *big
 	}
 
 	if expiredBlock.Cmp(currentBlockNumber) < 0 {
-		return fmt.Errorf("authentication failed as the Free Call Token passed has expired")
+		return NewPaymentError(errs.ErrInvalidFreeCallToken, "authentication failed as the Free Call Token passed has expired")
 	}
 	return nil
 }
@@ -263,16 +266,8 @@ func (validator *FreeCallPaymentValidator) getSignerOfAuthTokenForFreeCall(payme
 
 func getAddressFromSigForFreeCall(payment *FreeCallPayment) (signer *common.Address, err error) {
 
-	message := bytes.Join([][]byte{
-		[]byte(FreeCallPrefixSignature),
-		[]byte(payment.Address),
-		[]byte(payment.UserID),
-		[]byte(config.GetString(config.OrganizationId)),
-		[]byte(config.GetString(config.ServiceId)),
-		[]byte(payment.GroupId),
-		bigIntToBytes(payment.CurrentBlockNumber),
-		payment.AuthToken,
-	}, nil)
+	message := FreeCallMessage(payment.Address, payment.UserID, config.GetString(config.OrganizationId),
+		config.GetString(config.ServiceId), payment.GroupId, payment.CurrentBlockNumber, payment.AuthToken)
 
 	signer, err = utils.GetSignerAddressFromMessage(message, payment.Signature)
 	if err != nil {
@@ -286,6 +281,33 @@ func getAddressFromSigForFreeCall(payment *FreeCallPayment) (signer *common.Addr
 	return signer, err
 }
 
+// PaymentMessage returns the message of the payment which is signed by the
+// channel sender or signer, the same message is signed to claim the channel
+func PaymentMessage(mpeAddress common.Address, channelID, nonce, amount *big.Int) []byte {
+	return bytes.Join([][]byte{
+		[]byte(PrefixInSignature),
+		mpeAddress.Bytes(),
+		bigIntToBytes(channelID),
+		bigIntToBytes(nonce),
+		bigIntToBytes(amount),
+	}, nil)
+}
+
+// FreeCallMessage returns the message of the free call which is signed by the
+// user, the token is nil in the message of the free call token request
+func FreeCallMessage(address, userID, organizationID, serviceID, groupID string, currentBlock *big.Int, token []byte) []byte {
+	return bytes.Join([][]byte{
+		[]byte(FreeCallPrefixSignature),
+		[]byte(address),
+		[]byte(userID),
+		[]byte(organizationID),
+		[]byte(serviceID),
+		[]byte(groupID),
+		bigIntToBytes(currentBlock),
+		token,
+	}, nil)
+}
+
 func bigIntToBytes(value *big.Int) []byte {
 	return common.BigToHash(value).Bytes()
 }
@@ -299,20 +321,14 @@ func checkCurationValidations(signer *common.Address) error {
 	//hit by unknown users during a curation process
 	if config.GetBool(config.AllowedUserFlag) {
 		if !config.IsAllowedUser(signer) {
-			return fmt.Errorf("you are not Authorized to call this service during curation process")
+			return NewPaymentError(errs.ErrNotAllowedUser, "you are not Authorized to call this service during curation process")
 		}
 	}
 	return nil
 }
 
 func getSignerAddressFromPayment(payment *Payment) (signer *common.Address, err error) {
-	message := bytes.Join([][]byte{
-		[]byte(PrefixInSignature),
-		payment.MpeContractAddress.Bytes(),
-		bigIntToBytes(payment.ChannelID),
-		bigIntToBytes(payment.ChannelNonce),
-		bigIntToBytes(payment.Amount),
-	}, nil)
+	message := PaymentMessage(payment.MpeContractAddress, payment.ChannelID, payment.ChannelNonce, payment.Amount)
 
 	signer, err = utils.GetSignerAddressFromMessage(message, payment.Signature)
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -180,7 +181,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentChannelNonce() {
 
 	err := suite.validator.Validate(payment, channel)
 
-	assert.Equal(suite.T(), NewPaymentError(IncorrectNonce, "incorrect payment channel nonce, latest: 3, sent: 2"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrIncorrectNonce, "incorrect payment channel nonce, latest: 3, sent: 2").With("latest_nonce", 3), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentIncorrectSignatureLength() {
@@ -189,7 +190,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentIncorrectSignatureLength()
 
 	err := suite.validator.Validate(payment, suite.channel())
 
-	assert.Equal(suite.T(), NewPaymentError(Unauthenticated, "payment signature is not valid"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrInvalidSignature, "payment signature is not valid"), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentIncorrectSignatureChecksum() {
@@ -198,7 +199,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentIncorrectSignatureChecksum(
 
 	err := suite.validator.Validate(payment, suite.channel())
 
-	assert.Equal(suite.T(), NewPaymentError(Unauthenticated, "payment signature is not valid"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrInvalidSignature, "payment signature is not valid"), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentIncorrectSigner() {
@@ -207,7 +208,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentIncorrectSigner() {
 
 	err := suite.validator.Validate(payment, suite.channel())
 
-	assert.Equal(suite.T(), NewPaymentError(Unauthenticated, "payment is not signed by channel signer/sender"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrInvalidSigner, "payment is not signed by channel signer/sender"), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentChannelCannotGetCurrentBlock() {
@@ -217,7 +218,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentChannelCannotGetCurrentBloc
 
 	err := validator.Validate(suite.payment(), suite.channel())
 
-	assert.Equal(suite.T(), NewPaymentError(Internal, "cannot determine current block"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrBlockUnavailable, "cannot determine current block"), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentExpiredChannel() {
@@ -230,7 +231,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentExpiredChannel() {
 
 	err := validator.Validate(suite.payment(), channel)
 
-	assert.Equal(suite.T(), NewPaymentError(Unauthenticated, "payment channel is near to be expired, expiration time: 99, current block: 99, expiration threshold: 0"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrChannelExpiring, "payment channel is near to be expired, expiration time: 99, current block: 99, expiration threshold: 0").With("expiration", 99), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentChannelExpirationThreshold() {
@@ -243,7 +244,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentChannelExpirationThreshold(
 
 	err := validator.Validate(suite.payment(), channel)
 
-	assert.Equal(suite.T(), NewPaymentError(Unauthenticated, "payment channel is near to be expired, expiration time: 99, current block: 98, expiration threshold: 1"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrChannelExpiring, "payment channel is near to be expired, expiration time: 99, current block: 98, expiration threshold: 1").With("expiration", 99), err)
 }
 
 func (suite *ValidationTestSuite) TestValidatePaymentAmountIsTooBig() {
@@ -255,7 +256,7 @@ func (suite *ValidationTestSuite) TestValidatePaymentAmountIsTooBig() {
 
 	err := suite.validator.Validate(payment, suite.channel())
 
-	assert.Equal(suite.T(), NewPaymentError(Unauthenticated, "not enough tokens on payment channel, channel amount: 12345, payment amount: 12346"), err)
+	assert.Equal(suite.T(), NewPaymentError(errs.ErrInsufficientFunds, "not enough tokens on payment channel, channel amount: 12345, payment amount: 12346").With("channel_amount", 12345), err)
 }
 
 func (suite *ValidationTestSuite) TestGetPublicKeyFromPayment() {
@@ -5,8 +5,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
@@ -152,9 +152,8 @@ func getTLSConfig() (*tls.Config, error) {
 
 	certPath := config.GetString(config.PaymentChannelCertPath)
 	keyPath := config.GetString(config.PaymentChannelKeyPath)
-	caPath := config.GetString(config.PaymentChannelCaPath)
 
-	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	cert, err := config.LoadX509KeyPair(config.PaymentChannelCertPath, config.PaymentChannelKeyPath)
 	if err != nil {
 		zap.L().Error("[etcd] unable to load SSL X509 keypair",
 			zap.String("certPath", certPath),
@@ -170,16 +169,28 @@ func getTLSConfig() (*tls.Config, error) {
 		}
 	}
 
-	caCert, err := os.ReadFile(caPath)
+	caCert, err := config.GetSecretFile(config.PaymentChannelCaPath)
 	if err != nil {
 		return nil, err
 	}
 	zap.L().Debug("[etcd] enabling SSL support via X509 keypair")
 	caCertPool := x509.NewCertPool()
 	caCertPool.AppendCertsFromPEM(caCert)
+	// the client keypair is loaded at every handshake, so it follows the
+	// reloaded secrets, the CA is loaded once by the client
+	loaded := &atomic.Pointer[tls.Certificate]{}
+	loaded.Store(&cert)
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		RootCAs:      caCertPool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := config.LoadX509KeyPair(config.PaymentChannelCertPath, config.PaymentChannelKeyPath)
+			if err != nil {
+				zap.L().Warn("[etcd] unable to reload SSL X509 keypair, the loaded one is used", zap.Error(err))
+				return loaded.Load(), nil
+			}
+			loaded.Store(&cert)
+			return &cert, nil
+		},
+		RootCAs: caCertPool,
 	}
 	return tlsConfig, nil
 }
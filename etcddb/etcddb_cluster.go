@@ -0,0 +1,113 @@
+package etcddb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.uber.org/zap"
+)
+
+// EtcdMember is a member of the etcd cluster, the member added to the cluster
+// has no name until it is started
+type EtcdMember struct {
+	ID         uint64
+	Name       string
+	PeerURLs   []string
+	ClientURLs []string
+	IsLearner  bool
+}
+
+func (member *EtcdMember) String() string {
+	return fmt.Sprintf("%x: name=%v peerURLs=%v clientURLs=%v isLearner=%v", member.ID, member.Name,
+		strings.Join(member.PeerURLs, ","), strings.Join(member.ClientURLs, ","), member.IsLearner)
+}
+
+func toEtcdMembers(members []*etcdserverpb.Member) []*EtcdMember {
+	result := make([]*EtcdMember, len(members))
+	for i, member := range members {
+		result[i] = &EtcdMember{
+			ID:         member.ID,
+			Name:       member.Name,
+			PeerURLs:   member.PeerURLs,
+			ClientURLs: member.ClientURLs,
+			IsLearner:  member.IsLearner,
+		}
+	}
+	return result
+}
+
+// InitialCluster returns the cluster configuration of the new member, it is
+// set as the cluster field of the payment_channel_storage_server config of the
+// new member together with the "existing" cluster_state, the members are
+// sorted by name
+func InitialCluster(members []*EtcdMember, name string, peerURLs []string) string {
+	var cluster []string
+	for _, member := range members {
+		memberName, memberURLs := member.Name, member.PeerURLs
+		if memberName == "" {
+			// the added member isn't started yet
+			memberName, memberURLs = name, peerURLs
+		}
+		for _, url := range memberURLs {
+			cluster = append(cluster, memberName+"="+url)
+		}
+	}
+	sort.Strings(cluster)
+	return strings.Join(cluster, ",")
+}
+
+// MemberList returns the members of the cluster
+func (client *EtcdClient) MemberList() ([]*EtcdMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	response, err := client.etcd.MemberList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toEtcdMembers(response.Members), nil
+}
+
+// MemberAdd adds the member with the peer urls to the cluster, the learner
+// member doesn't vote until it is promoted. The added member and the members of
+// the cluster are returned.
+func (client *EtcdClient) MemberAdd(peerURLs []string, learner bool) (added *EtcdMember, members []*EtcdMember, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	add := client.etcd.MemberAdd
+	if learner {
+		add = client.etcd.MemberAddAsLearner
+	}
+	response, err := add(ctx, peerURLs)
+	if err != nil {
+		return nil, nil, err
+	}
+	zap.L().Info("[etcd] member is added", zap.Uint64("id", response.Member.ID),
+		zap.Strings("peerURLs", peerURLs), zap.Bool("learner", learner))
+	return toEtcdMembers([]*etcdserverpb.Member{response.Member})[0], toEtcdMembers(response.Members), nil
+}
+
+// MemberRemove removes the member from the cluster
+func (client *EtcdClient) MemberRemove(id uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	if _, err := client.etcd.MemberRemove(ctx, id); err != nil {
+		return err
+	}
+	zap.L().Info("[etcd] member is removed", zap.Uint64("id", id))
+	return nil
+}
+
+// MemberPromote makes the learner member a voting member of the cluster, the
+// learner is promoted when its log is in sync with the leader
+func (client *EtcdClient) MemberPromote(id uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	if _, err := client.etcd.MemberPromote(ctx, id); err != nil {
+		return err
+	}
+	zap.L().Info("[etcd] member is promoted", zap.Uint64("id", id))
+	return nil
+}
@@ -1,6 +1,7 @@
 package etcddb
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -84,6 +85,12 @@ func normalizeDefaultConf(conf string) string {
 //	cross-cluster-interaction, which might corrupt the clusters.
 //
 // StartupTimeout - time to wait the etcd server successfully started
+// ClusterState - "new" bootstraps the cluster, "existing" joins the member
+//
+//	added to the running cluster by the member add command
+//
+// RestoreFrom - snapshot loaded into the new cluster when its data dir is empty,
+// the incomplete restore is repeated at the next start
 // Enabled - enable running embedded etcd server
 // For more details see etcd Clustering Guide link:
 // https://github.com/etcd-io/etcd/blob/master/Documentation/op-guide/clustering.md
@@ -100,6 +107,8 @@ type EtcdServerConf struct {
 	DataDir        string   `json:"data_dir" mapstructure:"DATA_DIR"`
 	LogLevel       string   `json:"log_level" mapstructure:"LOG_LEVEL"`
 	LogOutputs     []string `json:"log_outputs" mapstructure:"LOG_OUTPUTS"`
+	ClusterState   string   `json:"cluster_state" mapstructure:"CLUSTER_STATE"`
+	RestoreFrom    string   `json:"restore_from" mapstructure:"RESTORE_FROM"`
 }
 
 // GetEtcdServerConf gets EtcdServerConf from viper
@@ -128,5 +137,49 @@ func GetEtcdServerConf(vip *viper.Viper) (conf *EtcdServerConf, err error) {
 		return
 	}
 
+	if conf.ClusterState != "new" && conf.ClusterState != "existing" {
+		return nil, fmt.Errorf("%s cluster_state must be new or existing, got %q", key, conf.ClusterState)
+	}
+
+	return
+}
+
+// EtcdMaintenanceConf schedules the maintenance of the etcd storage, the zero
+// interval disables the task
+// SnapshotInterval    - how often the snapshot is saved to SnapshotDir
+// SnapshotRetention   - number of the latest snapshots kept in SnapshotDir
+// CompactionInterval  - how often the history of the keys is compacted
+// CompactionRetention - number of the latest revisions kept by the compaction
+// DefragInterval      - how often the members are defragmented to release the compacted space
+type EtcdMaintenanceConf struct {
+	SnapshotInterval    time.Duration `json:"snapshot_interval" mapstructure:"snapshot_interval"`
+	SnapshotDir         string        `json:"snapshot_dir" mapstructure:"snapshot_dir"`
+	SnapshotRetention   int           `json:"snapshot_retention" mapstructure:"snapshot_retention"`
+	CompactionInterval  time.Duration `json:"compaction_interval" mapstructure:"compaction_interval"`
+	CompactionRetention int64         `json:"compaction_retention" mapstructure:"compaction_retention"`
+	DefragInterval      time.Duration `json:"defrag_interval" mapstructure:"defrag_interval"`
+}
+
+// Enabled returns true when any maintenance task is scheduled
+func (conf *EtcdMaintenanceConf) Enabled() bool {
+	return conf.SnapshotInterval > 0 || conf.CompactionInterval > 0 || conf.DefragInterval > 0
+}
+
+// GetEtcdMaintenanceConf gets EtcdMaintenanceConf from viper
+func GetEtcdMaintenanceConf(vip *viper.Viper) (conf *EtcdMaintenanceConf, err error) {
+	conf = &EtcdMaintenanceConf{}
+	subVip := config.SubWithDefault(vip, config.PaymentStorageMaintenanceKey)
+	if subVip == nil {
+		return
+	}
+	if err = subVip.Unmarshal(conf); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", config.PaymentStorageMaintenanceKey, err)
+	}
+	if conf.SnapshotInterval > 0 && (conf.SnapshotDir == "" || conf.SnapshotRetention <= 0) {
+		return nil, fmt.Errorf("%s requires snapshot_dir and positive snapshot_retention", config.PaymentStorageMaintenanceKey)
+	}
+	if conf.CompactionInterval > 0 && conf.CompactionRetention <= 0 {
+		return nil, fmt.Errorf("%s compaction_retention must be positive", config.PaymentStorageMaintenanceKey)
+	}
 	return
 }
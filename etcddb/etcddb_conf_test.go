@@ -169,3 +169,46 @@ func removeWorkDir(t *testing.T, workDir string) {
 	err = os.RemoveAll(dir + "/" + workDir)
 	assert.Nil(t, err)
 }
+
+func TestGetEtcdMaintenanceConf(t *testing.T) {
+	conf, err := GetEtcdMaintenanceConf(readConfig(t, `{}`))
+	assert.Nil(t, err)
+	assert.False(t, conf.Enabled())
+	assert.Equal(t, "etcd-snapshots", conf.SnapshotDir)
+	assert.Equal(t, 7, conf.SnapshotRetention)
+	assert.Equal(t, int64(10000), conf.CompactionRetention)
+
+	conf, err = GetEtcdMaintenanceConf(readConfig(t, `
+	{
+		"payment_channel_storage_maintenance": {
+			"snapshot_interval": "1h",
+			"snapshot_dir": "/var/lib/snetd/snapshots",
+			"compaction_interval": "10m",
+			"defrag_interval": "24h"
+		}
+	}`))
+	assert.Nil(t, err)
+	assert.True(t, conf.Enabled())
+	assert.Equal(t, time.Hour, conf.SnapshotInterval)
+	assert.Equal(t, "/var/lib/snetd/snapshots", conf.SnapshotDir)
+	assert.Equal(t, 10*time.Minute, conf.CompactionInterval)
+	assert.Equal(t, 24*time.Hour, conf.DefragInterval)
+
+	_, err = GetEtcdMaintenanceConf(readConfig(t, `
+	{
+		"payment_channel_storage_maintenance": {"snapshot_interval": "1h", "snapshot_retention": 0}
+	}`))
+	assert.ErrorContains(t, err, "snapshot_retention")
+
+	_, err = GetEtcdMaintenanceConf(readConfig(t, `
+	{
+		"payment_channel_storage_maintenance": {"compaction_interval": "1h", "compaction_retention": 0}
+	}`))
+	assert.ErrorContains(t, err, "compaction_retention")
+}
+
+func TestInvalidEtcdServerClusterState(t *testing.T) {
+	vip := readConfig(t, `{"payment_channel_storage_server": {"cluster_state": "joining"}}`)
+	_, err := GetEtcdServerConf(vip)
+	assert.ErrorContains(t, err, "cluster_state")
+}
@@ -0,0 +1,107 @@
+package etcddb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+const (
+	maintenanceLockPrefix = "/maintenance/lock/"
+	// defragTimeout is the time to wait the member is defragmented, it is
+	// longer than the request timeout as the whole database is rewritten
+	defragTimeout = 5 * time.Minute
+)
+
+// EtcdMaintenance runs the scheduled maintenance tasks of the etcd storage,
+// every daemon of the organization schedules the tasks but the task is run by
+// the daemon which holds its lock
+type EtcdMaintenance struct {
+	client *EtcdClient
+	conf   *EtcdMaintenanceConf
+	stop   chan struct{}
+	wait   *sync.WaitGroup
+}
+
+func NewEtcdMaintenance(client *EtcdClient, conf *EtcdMaintenanceConf) *EtcdMaintenance {
+	return &EtcdMaintenance{
+		client: client,
+		conf:   conf,
+		stop:   make(chan struct{}),
+		wait:   new(sync.WaitGroup),
+	}
+}
+
+// Start schedules the enabled tasks
+func (maintenance *EtcdMaintenance) Start() {
+	conf := maintenance.conf
+	maintenance.schedule("snapshot", conf.SnapshotInterval, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.SnapshotInterval)
+		defer cancel()
+		if _, err := maintenance.client.SaveSnapshot(ctx, conf.SnapshotDir); err != nil {
+			return err
+		}
+		return PruneSnapshots(conf.SnapshotDir, conf.SnapshotRetention)
+	})
+	maintenance.schedule("compaction", conf.CompactionInterval, func() error {
+		_, err := maintenance.client.Compact(conf.CompactionRetention)
+		return err
+	})
+	maintenance.schedule("defrag", conf.DefragInterval, maintenance.client.Defragment)
+}
+
+// Close stops the tasks and waits the running ones
+func (maintenance *EtcdMaintenance) Close() {
+	close(maintenance.stop)
+	maintenance.wait.Wait()
+}
+
+func (maintenance *EtcdMaintenance) schedule(name string, interval time.Duration, task func() error) {
+	if interval <= 0 {
+		return
+	}
+	zap.L().Info("[etcd] maintenance task is scheduled", zap.String("task", name), zap.Duration("interval", interval))
+	maintenance.wait.Add(1)
+	go func() {
+		defer maintenance.wait.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-maintenance.stop:
+				return
+			case <-ticker.C:
+				if err := maintenance.run(name, task); err != nil {
+					zap.L().Error("[etcd] maintenance task failed", zap.String("task", name), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// run runs the task when no other daemon runs it
+func (maintenance *EtcdMaintenance) run(name string, task func() error) error {
+	client := maintenance.client
+	mutex := concurrency.NewMutex(client.session, maintenanceLockPrefix+name)
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	if err := mutex.TryLock(ctx); err != nil {
+		if errors.Is(err, concurrency.ErrLocked) {
+			zap.L().Debug("[etcd] maintenance task is run by another daemon", zap.String("task", name))
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+		defer cancel()
+		if err := mutex.Unlock(ctx); err != nil {
+			zap.L().Warn("[etcd] can't unlock maintenance task", zap.String("task", name), zap.Error(err))
+		}
+	}()
+	return task()
+}
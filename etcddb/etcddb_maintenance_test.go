@@ -0,0 +1,192 @@
+package etcddb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (suite *EtcdTestSuite) TestEtcdMembers() {
+	t := suite.T()
+	client := suite.client
+
+	members, err := client.MemberList()
+	require.Nil(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "storage-1", members[0].Name)
+	assert.Equal(t, []string{"http://127.0.0.1:2379"}, members[0].ClientURLs)
+
+	// the learner doesn't vote, so the single member cluster keeps its quorum
+	added, members, err := client.MemberAdd([]string{"http://127.0.0.1:2390"}, true)
+	require.Nil(t, err)
+	assert.True(t, added.IsLearner)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "storage-1=http://127.0.0.1:2380,storage-2=http://127.0.0.1:2390",
+		InitialCluster(members, "storage-2", []string{"http://127.0.0.1:2390"}))
+
+	// the learner isn't started, so its log isn't in sync
+	assert.NotNil(t, client.MemberPromote(added.ID))
+
+	assert.Nil(t, client.MemberRemove(added.ID))
+	members, err = client.MemberList()
+	require.Nil(t, err)
+	assert.Len(t, members, 1)
+}
+
+func (suite *EtcdTestSuite) TestEtcdSnapshot() {
+	t := suite.T()
+	client := suite.client
+	dir := t.TempDir()
+
+	require.Nil(t, client.Put("snapshot-key", "old-value"))
+	require.Nil(t, client.Put("snapshot-key", "value"))
+	require.Nil(t, client.Put("deleted-key", "value"))
+	require.Nil(t, client.Delete("deleted-key"))
+	_, err := client.etcd.Put(context.Background(), "lease-key", "value", clientv3.WithLease(client.session.Lease()))
+	require.Nil(t, err)
+
+	var saved []string
+	for range 3 {
+		path, err := client.SaveSnapshot(context.Background(), dir)
+		require.Nil(t, err)
+		saved = append(saved, path)
+	}
+	require.Nil(t, PruneSnapshots(dir, 2))
+	snapshots, err := ListSnapshots(dir)
+	require.Nil(t, err)
+	assert.Equal(t, saved[1:], snapshots)
+
+	values, err := readSnapshot(saved[2])
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"snapshot-key": []byte("value")}, values)
+
+	require.Nil(t, client.Delete("snapshot-key"))
+	restored, err := client.RestoreSnapshot(saved[2])
+	require.Nil(t, err)
+	assert.Equal(t, 1, restored)
+	assertGet(suite, "snapshot-key", "value")
+
+	// the corrupted snapshot is rejected
+	data, err := os.ReadFile(saved[2])
+	require.Nil(t, err)
+	data[len(data)/2] ^= 0xff
+	corrupted := filepath.Join(dir, "corrupted.db")
+	require.Nil(t, os.WriteFile(corrupted, data, 0600))
+	_, err = client.RestoreSnapshot(corrupted)
+	assert.ErrorContains(t, err, "checksum")
+}
+
+func (suite *EtcdTestSuite) TestEtcdRestoreAtStartup() {
+	t := suite.T()
+	require.Nil(t, suite.client.Put("restored-key", "value"))
+	path, err := suite.client.SaveSnapshot(context.Background(), t.TempDir())
+	require.Nil(t, err)
+	suite.client.Close()
+	suite.server.Close()
+	removeWorkDir(t, suite.server.GetConf().DataDir)
+
+	vip := readConfig(t, `
+	{
+		"payment_channel_storage_server": {
+			"id": "storage-1",
+			"host" : "127.0.0.1",
+			"client_port": 2379,
+			"peer_port": 2380,
+			"token": "unique-token",
+			"cluster": "storage-1=http://127.0.0.1:2380",
+			"data_dir": "storage-data-dir-restored.etcd",
+			"restore_from": "`+path+`",
+			"enabled": true
+		}
+	}`)
+	suite.server, err = GetEtcdServerFromVip(vip)
+	require.Nil(t, err)
+	require.Nil(t, suite.server.Start())
+	suite.client, err = NewEtcdClientFromVip(vip, suite.metaData)
+	require.Nil(t, err)
+	assertGet(suite, "restored-key", "value")
+	marker := filepath.Join(suite.server.GetConf().DataDir, restoreMarker)
+	assert.NoFileExists(t, marker)
+
+	// the data isn't overwritten at the next start
+	require.Nil(t, suite.client.Delete("restored-key"))
+	suite.client.Close()
+	suite.server.Close()
+	require.Nil(t, suite.server.Start())
+	suite.client, err = NewEtcdClientFromVip(vip, suite.metaData)
+	require.Nil(t, err)
+	_, ok, err := suite.client.Get("restored-key")
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	// the incomplete restore is repeated
+	suite.client.Close()
+	suite.server.Close()
+	require.Nil(t, os.WriteFile(marker, []byte(path), 0600))
+	require.Nil(t, suite.server.Start())
+	suite.client, err = NewEtcdClientFromVip(vip, suite.metaData)
+	require.Nil(t, err)
+	assertGet(suite, "restored-key", "value")
+	assert.NoFileExists(t, marker)
+}
+
+func (suite *EtcdTestSuite) TestEtcdCompactAndDefragment() {
+	t := suite.T()
+	client := suite.client
+
+	revision, err := client.Compact(1000)
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), revision)
+
+	for range 5 {
+		require.Nil(t, client.Put("compacted-key", "value"))
+	}
+	revision, err = client.Compact(2)
+	require.Nil(t, err)
+	assert.True(t, revision > 0)
+
+	_, err = client.etcd.Get(context.Background(), "compacted-key", clientv3.WithRev(revision-1))
+	assert.NotNil(t, err)
+	assertGet(suite, "compacted-key", "value")
+
+	assert.Nil(t, client.Defragment())
+	assertGet(suite, "compacted-key", "value")
+}
+
+func (suite *EtcdTestSuite) TestEtcdMaintenance() {
+	t := suite.T()
+	dir := t.TempDir()
+	maintenance := NewEtcdMaintenance(suite.client, &EtcdMaintenanceConf{
+		SnapshotInterval:  100 * time.Millisecond,
+		SnapshotDir:       dir,
+		SnapshotRetention: 2,
+	})
+	maintenance.Start()
+
+	assert.Eventually(t, func() bool {
+		snapshots, err := ListSnapshots(dir)
+		return err == nil && len(snapshots) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	maintenance.Close()
+
+	// the task isn't run while another daemon holds its lock
+	other, err := NewEtcdClient(suite.metaData)
+	require.Nil(t, err)
+	defer other.Close()
+	mutex, err := other.NewMutex(maintenanceLockPrefix + "snapshot")
+	require.Nil(t, err)
+	require.Nil(t, mutex.Lock(context.Background()))
+	defer mutex.Unlock(context.Background())
+
+	ran := false
+	assert.Nil(t, NewEtcdMaintenance(suite.client, &EtcdMaintenanceConf{}).run("snapshot", func() error {
+		ran = true
+		return nil
+	}))
+	assert.False(t, ran)
+}
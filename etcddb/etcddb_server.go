@@ -1,14 +1,18 @@
 package etcddb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/singnet/snet-daemon/v6/config"
 	"github.com/spf13/viper"
 	"go.etcd.io/etcd/server/v3/embed"
+	"go.etcd.io/etcd/server/v3/etcdserver/api/v3client"
 	"go.uber.org/zap"
 )
 
@@ -62,18 +66,72 @@ func GetEtcdServerFromVip(vip *viper.Viper) (server *EtcdServer, err error) {
 	return
 }
 
-// Start starts etcd server
+// restoreMarker is the file in the data dir which exists while the snapshot is
+// restored, the restore is repeated at the next start until it's complete
+const restoreMarker = "restore.pending"
+
+// Start starts etcd server, the keys of the RestoreFrom snapshot are loaded
+// into the new cluster when the server has no data yet
 func (server *EtcdServer) Start() (err error) {
 
+	restore := server.needsRestore()
+	marker := filepath.Join(server.conf.DataDir, restoreMarker)
+	if restore {
+		if err = os.MkdirAll(server.conf.DataDir, 0700); err != nil {
+			return
+		}
+		if err = os.WriteFile(marker, []byte(server.conf.RestoreFrom), 0600); err != nil {
+			return
+		}
+	}
 	etcd, err := startEtcdServer(server.conf)
 	if err != nil {
 		return
 	}
 
 	server.etcd = etcd
+	if restore {
+		if err = server.restore(); err == nil {
+			err = os.Remove(marker)
+		}
+		if err != nil {
+			etcd.Close()
+			server.etcd = nil
+		}
+	}
 	return
 }
 
+// needsRestore returns true when the snapshot is set and the new cluster is
+// bootstrapped without data or its restore isn't complete, the existing data
+// is never overwritten
+func (server *EtcdServer) needsRestore() bool {
+	conf := server.conf
+	if conf.RestoreFrom == "" || conf.ClusterState == embed.ClusterStateFlagExisting {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(conf.DataDir, restoreMarker)); err == nil {
+		zap.L().Warn("[etcd] previous restore of the snapshot isn't complete, it's restored again", zap.String("snapshot", conf.RestoreFrom))
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(conf.DataDir, "member")); err == nil {
+		zap.L().Info("[etcd] data dir isn't empty, the snapshot isn't restored", zap.String("snapshot", conf.RestoreFrom))
+		return false
+	}
+	return true
+}
+
+func (server *EtcdServer) restore() error {
+	client := v3client.New(server.etcd.Server)
+	defer client.Close()
+	restored, err := RestoreSnapshot(context.Background(), client, server.conf.RestoreFrom)
+	if err != nil {
+		return fmt.Errorf("failed restoring etcd snapshot %v: %w", server.conf.RestoreFrom, err)
+	}
+	zap.L().Info("[etcd] snapshot is restored", zap.String("snapshot", server.conf.RestoreFrom), zap.Int("keys", restored))
+	return nil
+}
+
 // Close closes etcd server
 func (server *EtcdServer) Close() {
 	server.etcd.Close()
@@ -141,6 +199,9 @@ func getEtcdConf(conf *EtcdServerConf) *embed.Config {
 
 	//  --initial-cluster-state
 	etcdConf.ClusterState = embed.ClusterStateFlagNew
+	if conf.ClusterState == embed.ClusterStateFlagExisting {
+		etcdConf.ClusterState = embed.ClusterStateFlagExisting
+	}
 
 	// --log-level
 	etcdConf.LogLevel = conf.LogLevel
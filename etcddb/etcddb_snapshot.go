@@ -0,0 +1,271 @@
+package etcddb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/storage/mvcc"
+	"go.etcd.io/etcd/server/v3/storage/schema"
+	"go.uber.org/zap"
+)
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".db"
+	// snapshotTimeFormat sorts the snapshot files by the time they were saved
+	snapshotTimeFormat = "20060102T150405.000000000Z"
+	// restoreBatchSize is the number of the keys put by one transaction of the
+	// restore, it's the default max-txn-ops of etcd
+	restoreBatchSize = 128
+)
+
+// SaveSnapshot saves the snapshot of the etcd storage into the directory, the
+// path of the snapshot file is returned. The snapshot is written into the
+// temporary file which is renamed when the checksum of the snapshot is valid.
+func (client *EtcdClient) SaveSnapshot(ctx context.Context, dir string) (path string, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	reader, err := client.etcd.Snapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	path = filepath.Join(dir, snapshotPrefix+time.Now().UTC().Format(snapshotTimeFormat)+snapshotSuffix)
+	part := path + ".part"
+	file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, reader)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		_, err = verifySnapshot(part)
+	}
+	if err == nil {
+		err = os.Rename(part, path)
+	}
+	if err != nil {
+		_ = os.Remove(part)
+		return "", fmt.Errorf("failed saving etcd snapshot: %w", err)
+	}
+	zap.L().Info("[etcd] snapshot is saved", zap.String("path", path))
+	return path, nil
+}
+
+// ListSnapshots returns the snapshot files of the directory from the oldest to the latest
+func ListSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix) {
+			snapshots = append(snapshots, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// PruneSnapshots removes all but the retain latest snapshots of the directory
+func PruneSnapshots(dir string, retain int) error {
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return err
+	}
+	for len(snapshots) > retain {
+		if err = os.Remove(snapshots[0]); err != nil {
+			return err
+		}
+		zap.L().Debug("[etcd] snapshot is removed", zap.String("path", snapshots[0]))
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
+
+// verifySnapshot checks the sha256 checksum appended by etcd to the snapshot
+// and returns the size of the database without the checksum. The copy of the
+// database file has no checksum and is returned as is.
+func verifySnapshot(path string) (size int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size = info.Size()
+	if size%512 != sha256.Size {
+		return size, nil
+	}
+	size -= sha256.Size
+	hash := sha256.New()
+	if _, err = io.CopyN(hash, file, size); err != nil {
+		return 0, err
+	}
+	expected := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(file, expected); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		return 0, errors.New("snapshot checksum mismatch")
+	}
+	return size, nil
+}
+
+// readSnapshot returns the latest values of the keys of the snapshot, the
+// keys attached to the leases are skipped as their leases don't exist in the
+// restored cluster
+func readSnapshot(path string) (map[string][]byte, error) {
+	size, err := verifySnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	// the database is copied without the checksum, bolt can't open the snapshot as is
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp("", "etcd-snapshot-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.CopyN(tmp, src, size)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(tmp.Name(), 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	values := make(map[string][]byte)
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(schema.Key.Name())
+		if bucket == nil {
+			return errors.New("snapshot has no key bucket")
+		}
+		// the keys of the bucket are the revisions, so the latest change of the key is applied last
+		return bucket.ForEach(func(rev, data []byte) error {
+			kv := &mvccpb.KeyValue{}
+			if err := kv.Unmarshal(data); err != nil {
+				return err
+			}
+			if mvcc.IsTombstone(rev) || kv.Lease != 0 {
+				delete(values, string(kv.Key))
+				return nil
+			}
+			values[string(kv.Key)] = kv.Value
+			return nil
+		})
+	})
+	return values, err
+}
+
+// RestoreSnapshot puts the keys of the snapshot into the storage by the
+// transactions of restoreBatchSize keys, the number of the restored keys is
+// returned. The history of the keys and the leases aren't restored.
+func RestoreSnapshot(ctx context.Context, kv clientv3.KV, path string) (restored int, err error) {
+	values, err := readSnapshot(path)
+	if err != nil {
+		return 0, err
+	}
+	ops := make([]clientv3.Op, 0, restoreBatchSize)
+	put := func() error {
+		if _, err := kv.Txn(ctx).Then(ops...).Commit(); err != nil {
+			return err
+		}
+		restored += len(ops)
+		ops = ops[:0]
+		return nil
+	}
+	for key, value := range values {
+		ops = append(ops, clientv3.OpPut(key, string(value)))
+		if len(ops) == restoreBatchSize {
+			if err = put(); err != nil {
+				return restored, err
+			}
+		}
+	}
+	if len(ops) > 0 {
+		err = put()
+	}
+	return restored, err
+}
+
+// RestoreSnapshot puts the keys of the snapshot into the etcd storage
+func (client *EtcdClient) RestoreSnapshot(path string) (restored int, err error) {
+	return RestoreSnapshot(context.Background(), client.etcd, path)
+}
+
+// Compact compacts the history of the keys keeping the retention latest
+// revisions, the compacted revision is returned
+func (client *EtcdClient) Compact(retention int64) (revision int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	response, err := client.etcd.Get(ctx, "/", clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	revision = response.Header.Revision - retention
+	if revision <= 0 {
+		return 0, nil
+	}
+	if _, err = client.etcd.Compact(ctx, revision, clientv3.WithCompactPhysical()); err != nil {
+		return 0, err
+	}
+	zap.L().Info("[etcd] storage is compacted", zap.Int64("revision", revision))
+	return revision, nil
+}
+
+// Defragment defragments the members of the cluster one by one to release the
+// space of the compacted revisions, the member doesn't serve the requests
+// while it is defragmented
+func (client *EtcdClient) Defragment() error {
+	members, err := client.MemberList()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		for _, endpoint := range member.ClientURLs {
+			ctx, cancel := context.WithTimeout(context.Background(), defragTimeout)
+			_, err = client.etcd.Defragment(ctx, endpoint)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed defragmenting %v: %w", endpoint, err)
+			}
+			zap.L().Info("[etcd] member is defragmented", zap.String("endpoint", endpoint))
+		}
+	}
+	return nil
+}
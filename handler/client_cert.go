@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"crypto/x509"
+	"slices"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientCertIdentity is the identity of the client certificate verified by
+// the daemon listener when the mTLS client authentication is enabled.
+type ClientCertIdentity struct {
+	CommonName string
+	// SANs contains the DNS names, email addresses, URIs and IP addresses of
+	// the certificate
+	SANs []string
+}
+
+// Names returns the SANs and the common name of the certificate
+func (identity *ClientCertIdentity) Names() []string {
+	names := slices.Clone(identity.SANs)
+	if identity.CommonName != "" {
+		names = append(names, identity.CommonName)
+	}
+	return names
+}
+
+// NewClientCertIdentity returns the identity of the client certificate
+func NewClientCertIdentity(cert *x509.Certificate) *ClientCertIdentity {
+	identity := &ClientCertIdentity{CommonName: cert.Subject.CommonName}
+	identity.SANs = append(identity.SANs, cert.DNSNames...)
+	identity.SANs = append(identity.SANs, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identity.SANs = append(identity.SANs, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		identity.SANs = append(identity.SANs, ip.String())
+	}
+	return identity
+}
+
+// ClientCertIdentityFromContext returns the identity of the verified client
+// certificate of the call or nil when the client didn't send a certificate.
+func ClientCertIdentityFromContext(ctx context.Context) *ClientCertIdentity {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return NewClientCertIdentity(tlsInfo.State.VerifiedChains[0][0])
+}
@@ -2,15 +2,19 @@ package handler
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
 	"os/exec"
-	"path"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/rpc/v2/json2"
@@ -25,33 +29,42 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 var grpcDesc = &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
 
 type grpcHandler struct {
-	grpcConn            *grpc.ClientConn
-	grpcModelConn       *grpc.ClientConn
-	options             grpc.DialOption
-	enc                 string
-	passthroughEndpoint string
+	grpcModelConn *grpc.ClientConn
+	options       grpc.DialOption
+	enc           string
+	upstreams     *UpstreamPool
+	loadBalancing *config.ServiceLoadBalancingSettings
+	retryPolicies map[string]*retryPolicy
 	//modelTrainingEndpoint string
 	executable         string
+	processes          *ProcessPool
 	serviceMetaData    *blockchain.ServiceMetadata
 	serviceCredentials serviceCredentials
+	httpRules          httpRules
+	annotatedRules     sync.Map
 	httpClient         *http.Client
 	timeout            time.Duration
 }
 
-func (g *grpcHandler) GrpcConn(isModelTraining bool) *grpc.ClientConn {
-	if isModelTraining {
+func (g *grpcHandler) GrpcConn(isModelTraining bool, upstream *Upstream) *grpc.ClientConn {
+	if isModelTraining && g.grpcModelConn != nil {
 		return g.grpcModelConn
 	}
 
-	return g.grpcConn
+	return upstream.conn
 }
 
-func NewGrpcHandler(serviceMetadata *blockchain.ServiceMetadata) grpc.StreamHandler {
+// NewGrpcHandler returns the handler proxying the calls to the upstreams of the
+// pool, the calls of the process service are routed to the workers of processes
+// or start executable_path when processes is nil
+func NewGrpcHandler(serviceMetadata *blockchain.ServiceMetadata, upstreams *UpstreamPool, processes *ProcessPool) grpc.StreamHandler {
 	passthroughEnabled := config.GetBool(config.PassthroughEnabledKey)
 
 	if !passthroughEnabled {
@@ -61,28 +74,51 @@ func NewGrpcHandler(serviceMetadata *blockchain.ServiceMetadata) grpc.StreamHand
 	timeout := config.GetDuration(config.ServiceTimeout)
 
 	h := &grpcHandler{
-		timeout:             timeout,
-		serviceMetaData:     serviceMetadata,
-		enc:                 serviceMetadata.GetWireEncoding(),
-		passthroughEndpoint: config.GetString(config.ServiceEndpointKey),
+		timeout:         timeout,
+		serviceMetaData: serviceMetadata,
+		enc:             serviceMetadata.GetWireEncoding(),
+		loadBalancing:   upstreams.Settings(),
+		upstreams:       upstreams,
 		//modelTrainingEndpoint: config.GetString(config.ModelTrainingEndpoint),
 		executable: config.GetString(config.ExecutablePathKey),
+		processes:  processes,
 		options: grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(config.GetInt(config.MaxMessageSizeInMB)*1024*1024),
 			grpc.MaxCallSendMsgSize(config.GetInt(config.MaxMessageSizeInMB)*1024*1024)),
 	}
 
+	retryPolicies, err := config.GetServiceRetryPolicies()
+	if err == nil {
+		h.retryPolicies, err = newRetryPolicies(retryPolicies)
+	}
+	if err != nil {
+		zap.L().Fatal("invalid config", zap.Error(fmt.Errorf("%v%v", err, errs.ErrDescURL(errs.InvalidConfig))))
+	}
+
+	// the retries and the hedging need the descriptors to find the unary methods,
+	// they are compiled here when the metadata was loaded without them
+	retries := len(h.retryPolicies) > 0 || (h.loadBalancing != nil && h.loadBalancing.Retries > 0)
+	if retries && serviceMetadata.ProtoDescriptors == nil {
+		if _, err = serviceMetadata.GetProtoDescriptors(); err != nil {
+			zap.L().Warn("can't compile proto files of the service, the calls aren't retried", zap.Error(err))
+		}
+	}
+
 	// Add small slack so http.Client.Timeout does not fire before context deadline
 	h.httpClient = &http.Client{Timeout: timeout + time.Second}
 
 	switch serviceMetadata.GetServiceType() {
 	case "grpc":
-		h.grpcConn = h.getConnection(h.passthroughEndpoint)
+		for _, upstream := range h.upstreams.Upstreams() {
+			upstream.conn = h.getConnection(upstream.Endpoint)
+		}
 		//if config.GetBool(config.ModelTrainingEnabled) {
 		//	h.grpcModelConn = h.getConnection(h.modelTrainingEndpoint)
 		//}
+		h.upstreams.StartHealthChecks()
 		return h.grpcToGRPC
 	case "jsonrpc":
+		h.upstreams.StartHealthChecks()
 		return h.grpcToJSONRPC
 	case "http":
 		h.serviceCredentials = serviceCredentials{}
@@ -94,8 +130,19 @@ func NewGrpcHandler(serviceMetadata *blockchain.ServiceMetadata) grpc.StreamHand
 		if err != nil {
 			zap.L().Fatal("invalid config", zap.Error(fmt.Errorf("%v%v", err, errs.ErrDescURL(errs.InvalidServiceCredentials))))
 		}
+		err = config.Vip().UnmarshalKey(config.HTTPRPCMappingKey, &h.httpRules)
+		if err == nil {
+			err = h.httpRules.validate()
+		}
+		if err != nil {
+			zap.L().Fatal("invalid config", zap.Error(fmt.Errorf("%v%v", err, errs.ErrDescURL(errs.InvalidConfig))))
+		}
+		h.upstreams.StartHealthChecks()
 		return h.grpcToHTTP
 	case "process":
+		if h.processes != nil {
+			return h.grpcToProcessPool
+		}
 		return h.grpcToProcess
 	}
 	return nil
@@ -134,13 +181,13 @@ Modifications Copyright 2018 SingularityNET Foundation. All Rights Reserved. See
 func (g *grpcHandler) grpcToGRPC(srv any, inStream grpc.ServerStream) error {
 	method, ok := grpc.MethodFromServerStream(inStream)
 	if !ok {
-		return status.Errorf(codes.Internal, "could not determine method from server stream")
+		return errs.ErrUnknownMethod.New("could not determine method from server stream")
 	}
 
 	inCtx := inStream.Context()
 	md, ok := metadata.FromIncomingContext(inCtx)
 	if !ok {
-		return status.Errorf(codes.Internal, "could not get metadata from incoming context")
+		return errs.ErrMissingMetadata.New("could not get metadata from incoming context")
 	}
 
 	outCtx, outCancel := withDefaultTimeout(inCtx, g.timeout)
@@ -148,9 +195,21 @@ func (g *grpcHandler) grpcToGRPC(srv any, inStream grpc.ServerStream) error {
 	outCtx = metadata.NewOutgoingContext(outCtx, md.Copy())
 
 	isModelTraining := g.serviceMetaData.IsModelTraining(method)
-	outStream, err := g.GrpcConn(isModelTraining).NewStream(outCtx, grpcDesc, method, grpc.CallContentSubtype(g.enc))
+	if !isModelTraining && (g.isIdempotent(method) || g.retryPolicy(method) != nil) && g.isUnary(method) {
+		return g.grpcToGRPCUnary(outCtx, method, inStream)
+	}
+
+	upstream, err := g.upstreams.Pick(method)
+	if err != nil {
+		return errs.ErrServiceUnavailable.New("can't connect to service %v", err)
+	}
+	upstreamFailed := false
+	defer func() { g.upstreams.Done(upstream, method, upstreamFailed) }()
+
+	outStream, err := g.GrpcConn(isModelTraining, upstream).NewStream(outCtx, grpcDesc, method, grpc.CallContentSubtype(g.enc))
 	if err != nil {
-		return status.Errorf(codes.Internal, "can't connect to service %v%v", err, errs.ErrDescURL(errs.ServiceUnavailable))
+		upstreamFailed = true
+		return errs.ErrServiceCallFailed.New("can't connect to service %v", err)
 	}
 
 	s2cErrChan := forwardServerToClient(inStream, outStream)
@@ -172,7 +231,7 @@ func (g *grpcHandler) grpcToGRPC(srv any, inStream grpc.ServerStream) error {
 				// to cancel the clientStream to the backend, let all of its goroutines be freed up by the CancelFunc and
 				// exit with an error to the stack
 				outCancel()
-				return status.Errorf(codes.Internal, "failed proxying s2c: %v%s", s2cErr, errs.ErrDescURL(errs.ServiceUnavailable))
+				return errs.ErrServiceCallFailed.New("failed proxying s2c: %v", s2cErr)
 			}
 		case c2sErr := <-c2sErrChan:
 			// This happens when the clientStream has nothing else to offer (io.EOF), returned a gRPC error. In those two
@@ -181,12 +240,83 @@ func (g *grpcHandler) grpcToGRPC(srv any, inStream grpc.ServerStream) error {
 			inStream.SetTrailer(outStream.Trailer())
 			// c2sErr will contain RPC error from client code. If not io.EOF return the RPC error as server stream error.
 			if c2sErr != io.EOF {
+				upstreamFailed = isUpstreamFailure(c2sErr)
 				return c2sErr
 			}
 			return nil
 		}
 	}
-	return status.Errorf(codes.Internal, "gRPC proxying should never reach this stage.")
+	return errs.ErrServiceCallFailed.New("gRPC proxying should never reach this stage.")
+}
+
+// unaryResponse is the response of the unary call with its header and trailer
+type unaryResponse struct {
+	frame   *codec.GrpcFrame
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+// grpcToGRPCUnary proxies the unary call which can be repeated, the call is
+// retried by the retry policy of the method or on another upstream if the
+// upstream is unavailable.
+func (g *grpcHandler) grpcToGRPCUnary(outCtx context.Context, method string, inStream grpc.ServerStream) error {
+	f := &codec.GrpcFrame{}
+	if err := inStream.RecvMsg(f); err != nil {
+		return errs.ErrReceiveMsg.New("error receiving grpc msg: %v", err)
+	}
+
+	resp, attempts, err := callUpstream(g, outCtx, method, func(ctx context.Context, upstream *Upstream) (*unaryResponse, bool, error) {
+		resp := &unaryResponse{frame: &codec.GrpcFrame{}, header: metadata.MD{}, trailer: metadata.MD{}}
+		err := upstream.conn.Invoke(ctx, method, f, resp.frame, grpc.Header(&resp.header), grpc.Trailer(&resp.trailer),
+			grpc.CallContentSubtype(g.enc))
+		return resp, isUpstreamFailure(err), err
+	})
+	if resp != nil {
+		inStream.SetTrailer(resp.trailer)
+	}
+	inStream.SetTrailer(retryTrailer(attempts))
+	if err != nil {
+		return err
+	}
+	if err = inStream.SendHeader(resp.header); err != nil {
+		return err
+	}
+	return inStream.SendMsg(resp.frame)
+}
+
+// isUpstreamFailure returns true for the errors of the gRPC service which is
+// unavailable or doesn't respond in time
+func isUpstreamFailure(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
+
+// isIdempotent returns true for the methods listed in idempotent_methods and for
+// the unary methods marked with idempotency_level in the proto files.
+func (g *grpcHandler) isIdempotent(fullMethod string) bool {
+	if slices.Contains(g.loadBalancing.IdempotentMethods, fullMethod) {
+		return true
+	}
+	method := g.findMethod(fullMethod)
+	if method == nil || method.IsStreamingClient() || method.IsStreamingServer() {
+		return false
+	}
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	return ok && options.GetIdempotencyLevel() != descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN
+}
+
+// isUnary returns true only if the proto files declare the method as unary, the
+// unknown methods are never retried or hedged
+func (g *grpcHandler) isUnary(fullMethod string) bool {
+	method := g.findMethod(fullMethod)
+	return method != nil && !method.IsStreamingClient() && !method.IsStreamingServer()
+}
+
+func (g *grpcHandler) findMethod(fullMethod string) protoreflect.MethodDescriptor {
+	if g.serviceMetaData == nil {
+		return nil
+	}
+	return findFullMethodInProto(g.serviceMetaData.ProtoDescriptors, fullMethod)
 }
 
 /*
@@ -263,11 +393,19 @@ type serviceCredential struct {
 
 type serviceCredentials []serviceCredential
 
+// value returns the value of the credential, the secret reference is resolved
+func (cred serviceCredential) value() (any, error) {
+	if v, ok := cred.Value.(string); ok {
+		return config.ResolveSecret(v)
+	}
+	return cred.Value, nil
+}
+
 func (g *grpcHandler) grpcToHTTP(srv any, inStream grpc.ServerStream) error {
 
 	methodFull, ok := grpc.MethodFromServerStream(inStream)
 	if !ok {
-		return status.Errorf(codes.Internal, "could not determine method from server stream")
+		return errs.ErrUnknownMethod.New("could not determine method from server stream")
 	}
 
 	// we are expecting "/service/method", but we are normalizing it just in case
@@ -276,191 +414,457 @@ func (g *grpcHandler) grpcToHTTP(srv any, inStream grpc.ServerStream) error {
 	// we guarantee the availability of service/method
 	svc, method, ok := strings.Cut(methodFull, "/")
 	if !ok || svc == "" || method == "" {
-		return status.Errorf(codes.Internal, "unexpected grpc method format: %q", methodFull)
+		return errs.ErrUnknownMethod.New("unexpected grpc method format: %q", methodFull)
 	}
 
 	if strings.Contains(method, "/") {
-		return status.Errorf(codes.Internal, "unexpected grpc method format (extra segments): %q", methodFull)
+		return errs.ErrUnknownMethod.New("unexpected grpc method format (extra segments): %q", methodFull)
 	}
 
 	zap.L().Info("Calling method", zap.String("method", method))
 
+	descriptor := g.findMethod("/" + methodFull)
+	rule := g.httpRuleFor("/"+methodFull, descriptor)
+	if descriptor != nil && (descriptor.IsStreamingClient() || descriptor.IsStreamingServer()) {
+		return g.grpcToHTTPStream(inStream, "/"+methodFull, method, descriptor, rule)
+	}
+
 	f := &codec.GrpcFrame{}
 	if err := inStream.RecvMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error receiving grpc msg: %v%v", err, errs.ErrDescURL(errs.ReceiveMsgError))
+		return errs.ErrReceiveMsg.New("error receiving grpc msg: %v", err)
 	}
 
 	// convert proto msg to json
 	jsonBody, err := protoToJson(g.serviceMetaData.ProtoDescriptors, f.Data, method)
 	if err != nil {
-		return status.Errorf(codes.Internal, "protoToJson error: %+v", errs.ErrDescURL(errs.InvalidProto))
+		return errs.ErrInvalidProto.New("protoToJson error: %+v", err)
 	}
 
 	zap.L().Debug("Proto to json result", zap.String("json", string(jsonBody)))
 
-	base, err := url.Parse(g.passthroughEndpoint)
+	req, err := g.newHTTPRequest(rule, descriptor, jsonBody)
 	if err != nil {
-		zap.L().Error("can't parse passthroughEndpoint", zap.Error(err))
-		return status.Errorf(codes.Internal, "can't parse service_endpoint %v%v", err, errs.ErrDescURL(errs.InvalidConfig))
+		return errs.ErrInvalidRequest.New("can't build http request: %v", err)
+	}
+
+	inCtx := inStream.Context()
+	outCtx, cancel := withDefaultTimeout(inCtx, g.timeout)
+	defer cancel()
+
+	httpResp, attempts, err := callUpstream(g, outCtx, "/"+methodFull, func(ctx context.Context, upstream *Upstream) (httpResponse, bool, error) {
+		body, header, failed, err := g.callHTTPService(ctx, upstream, rule, req)
+		return httpResponse{body: body, header: header}, failed, err
+	})
+	resp := httpResp.body
+	if httpResp.header != nil {
+		inStream.SetTrailer(headersToTrailer(httpResp.header))
+	}
+	inStream.SetTrailer(retryTrailer(attempts))
+	if err != nil {
+		return err
 	}
 
-	base.Path = path.Join(base.Path, method) // method from proto should be the same as http handler path
+	zap.L().Debug("Response from HTTP service", zap.String("response", string(resp)))
 
-	params := url.Values{}
-	headers := http.Header{}
+	protoMessage, errMarshal := jsonToProto(g.serviceMetaData.ProtoDescriptors, rule.responseJSON(resp), method)
+	if errMarshal != nil {
+		return errs.ErrInvalidProto.New("jsonToProto error: %+v", errMarshal)
+	}
 
-	var bodyMap = map[string]any{}
-	errJson := json.Unmarshal(jsonBody, &bodyMap)
+	if err = inStream.SendMsg(protoMessage); err != nil {
+		return errs.ErrSendResponse.New("error sending response from HTTP service: %+v", err)
+	}
+
+	return nil
+}
+
+// httpResponse is the response body of the HTTP service with its headers
+type httpResponse struct {
+	body   []byte
+	header http.Header
+}
+
+// httpRequest is the request to the HTTP service built by the rule of the method
+type httpRequest struct {
+	method  string
+	path    string
+	query   url.Values
+	headers http.Header
+	body    []byte
+	stream  io.Reader
+}
+
+// newHTTPRequest builds the request from the request message converted to JSON
+// and adds the service_credentials
+func (g *grpcHandler) newHTTPRequest(rule *httpRule, descriptor protoreflect.MethodDescriptor, jsonBody []byte) (*httpRequest, error) {
+	var input protoreflect.MessageDescriptor
+	if descriptor != nil {
+		input = descriptor.Input()
+	}
+	requestPath, params, bodyMap, err := rule.buildRequest(jsonBody, input)
+	if err != nil {
+		return nil, err
+	}
+	req := &httpRequest{method: rule.Method, path: requestPath, query: params, headers: http.Header{}}
 
 	for _, cred := range g.serviceCredentials {
+		value, err := cred.value()
+		if err != nil {
+			return nil, err
+		}
 		switch cred.Location {
 		case query:
-			v, ok := cred.Value.(string)
+			v, ok := value.(string)
 			if ok {
-				params.Add(cred.Key, v)
+				req.query.Add(cred.Key, v)
 			}
 		case body:
-			if errJson == nil {
-				bodyMap[cred.Key] = cred.Value
+			if bodyMap != nil {
+				bodyMap[cred.Key] = value
 			}
 		case header:
-			v, ok := cred.Value.(string)
+			v, ok := value.(string)
 			if ok {
-				headers.Set(cred.Key, v)
+				req.headers.Set(cred.Key, v)
 			}
 		}
 	}
 
-	if errJson == nil {
-		newJson, err := json.Marshal(bodyMap)
-		if err == nil {
-			jsonBody = newJson
-		} else {
-			zap.L().Debug("Can't marshal json", zap.Error(err))
+	if bodyMap != nil {
+		if req.body, err = json.Marshal(bodyMap); err != nil {
+			return nil, err
 		}
 	}
+	return req, nil
+}
 
-	base.RawQuery = params.Encode()
-	zap.L().Debug("Calling http service",
-		zap.String("url", base.String()),
-		zap.String("body", string(jsonBody)),
-		zap.String("method", "POST"))
+// grpcToHTTPStream proxies the streaming method: the request messages of the
+// client stream are sent in the request body and the messages streamed by the
+// HTTP service are sent to the client one by one, in the format of the rule.
+func (g *grpcHandler) grpcToHTTPStream(inStream grpc.ServerStream, fullMethod string, method string,
+	descriptor protoreflect.MethodDescriptor, rule *httpRule) error {
+	var req *httpRequest
+	var err error
+	if descriptor.IsStreamingClient() {
+		// the path and the query can't use the fields of the streamed messages
+		if req, err = g.newHTTPRequest(rule, nil, nil); err != nil {
+			return errs.ErrInvalidRequest.New("can't build http request: %v", err)
+		}
+		// the body service_credentials are added to every streamed message
+		creds, err := g.bodyCredentials()
+		if err != nil {
+			return errs.ErrInvalidRequest.New("can't build http request: %v", err)
+		}
+		format := cmp.Or(rule.Stream, ndjsonStream)
+		req.body = nil
+		req.headers.Set("content-type", streamContentTypes[format])
+		reader, writer := io.Pipe()
+		defer reader.Close()
+		go g.writeRequestStream(inStream, writer, format, method, creds)
+		req.stream = reader
+	} else {
+		f := &codec.GrpcFrame{}
+		if err = inStream.RecvMsg(f); err != nil {
+			return errs.ErrReceiveMsg.New("error receiving grpc msg: %v", err)
+		}
+		jsonBody, err := protoToJson(g.serviceMetaData.ProtoDescriptors, f.Data, method)
+		if err != nil {
+			return errs.ErrInvalidProto.New("protoToJson error: %+v", err)
+		}
+		if req, err = g.newHTTPRequest(rule, descriptor, jsonBody); err != nil {
+			return errs.ErrInvalidRequest.New("can't build http request: %v", err)
+		}
+	}
+	if descriptor.IsStreamingServer() && rule.Stream != "" {
+		req.headers.Set("accept", streamContentTypes[rule.Stream])
+	}
 
-	inCtx := inStream.Context()
-	outCtx, cancel := withDefaultTimeout(inCtx, g.timeout)
+	outCtx, cancel := withDefaultTimeout(inStream.Context(), g.timeout)
 	defer cancel()
 
-	httpReq, err := http.NewRequestWithContext(outCtx, http.MethodPost, base.String(), bytes.NewBuffer(jsonBody))
+	upstream, err := g.upstreams.Pick(fullMethod)
 	if err != nil {
-		return status.Errorf(codes.Internal, "error creating http request: %+v%v", err, errs.ErrDescURL(errs.HTTPRequestBuildError))
+		return errs.ErrServiceUnavailable.New("can't connect to service %v", err)
 	}
-	httpReq.Header = headers
-	httpReq.Header.Set("content-type", "application/json")
+	upstreamFailed := false
+	defer func() { g.upstreams.Done(upstream, fullMethod, upstreamFailed) }()
 
-	httpResp, err := g.httpClient.Do(httpReq)
+	httpResp, failed, err := g.sendHTTPRequest(outCtx, upstream, rule, req)
+	if httpResp != nil {
+		inStream.SetTrailer(headersToTrailer(httpResp.Header))
+	}
 	if err != nil {
-		return status.Errorf(codes.Internal, "error executing HTTP service: %+v%v", err, errs.ErrDescURL(errs.ServiceUnavailable))
+		upstreamFailed = failed
+		return err
 	}
 	defer httpResp.Body.Close()
 
-	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
-		b, _ := io.ReadAll(httpResp.Body)
-		return status.Errorf(codes.Unavailable, "upstream http status %d: %s%v",
-			httpResp.StatusCode, string(b), errs.ErrDescURL(errs.ServiceUnavailable))
+	send := func(message []byte) error {
+		protoMessage, err := jsonToProto(g.serviceMetaData.ProtoDescriptors, rule.responseJSON(message), method)
+		if err != nil {
+			return errs.ErrInvalidProto.New("jsonToProto error: %+v", err)
+		}
+		if err = inStream.SendMsg(protoMessage); err != nil {
+			return errs.ErrSendResponse.New("error sending response from HTTP service: %+v", err)
+		}
+		return nil
+	}
+
+	if !descriptor.IsStreamingServer() {
+		resp, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			return errs.ErrServiceResponse.New("error reading response from HTTP service: %+v", err)
+		}
+		return send(resp)
 	}
 
-	resp, err := io.ReadAll(httpResp.Body)
+	format := streamFormat(rule.Stream, httpResp.Header.Get("content-type"))
+	err = readHTTPStream(httpResp.Body, format, config.GetInt(config.MaxMessageSizeInMB)*1024*1024, send)
+	if _, ok := status.FromError(err); err != nil && !ok {
+		if outCtx.Err() != nil {
+			return status.FromContextError(outCtx.Err()).Err()
+		}
+		return errs.ErrServiceResponse.New("error reading %v stream from HTTP service: %+v", format, err)
+	}
+	return err
+}
+
+// bodyCredentials returns the values of the service_credentials located in the body
+func (g *grpcHandler) bodyCredentials() (map[string]any, error) {
+	creds := map[string]any{}
+	for _, cred := range g.serviceCredentials {
+		if cred.Location != body {
+			continue
+		}
+		value, err := cred.value()
+		if err != nil {
+			return nil, err
+		}
+		creds[cred.Key] = value
+	}
+	return creds, nil
+}
+
+// withCredentials adds the credentials to the JSON object of the message
+func withCredentials(jsonMessage []byte, creds map[string]any) ([]byte, error) {
+	if len(creds) == 0 {
+		return jsonMessage, nil
+	}
+	message := map[string]any{}
+	if err := json.Unmarshal(jsonMessage, &message); err != nil {
+		return nil, err
+	}
+	maps.Copy(message, creds)
+	return json.Marshal(message)
+}
+
+// writeRequestStream writes the messages of the client stream with the body
+// credentials to the request body until the client closes the stream
+func (g *grpcHandler) writeRequestStream(inStream grpc.ServerStream, writer *io.PipeWriter, format string, method string,
+	creds map[string]any) {
+	for {
+		f := &codec.GrpcFrame{}
+		if err := inStream.RecvMsg(f); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			writer.CloseWithError(err)
+			return
+		}
+		jsonMessage, err := protoToJson(g.serviceMetaData.ProtoDescriptors, f.Data, method)
+		if err == nil {
+			jsonMessage, err = withCredentials(jsonMessage, creds)
+		}
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if err = writeHTTPStream(writer, format, jsonMessage); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// callHTTPService calls the method of the HTTP service on the upstream and reads
+// the response, failed is true when the upstream can't be reached or responds
+// with a gateway error. The header of the response is returned for the errors too.
+func (g *grpcHandler) callHTTPService(ctx context.Context, upstream *Upstream, rule *httpRule, req *httpRequest) (resp []byte, header http.Header, failed bool, err error) {
+	httpResp, failed, err := g.sendHTTPRequest(ctx, upstream, rule, req)
+	if httpResp != nil {
+		header = httpResp.Header
+	}
 	if err != nil {
-		return status.Errorf(codes.Internal, "error reading response from HTTP service: %+v%v", err, errs.ErrDescURL(errs.ServiceUnavailable))
+		return nil, header, failed, err
 	}
+	defer httpResp.Body.Close()
 
-	zap.L().Debug("Response from HTTP service", zap.String("response", string(resp)))
+	resp, err = io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, header, false, errs.ErrServiceResponse.New("error reading response from HTTP service: %+v", err)
+	}
+	return resp, header, false, nil
+}
 
-	protoMessage, errMarshal := jsonToProto(g.serviceMetaData.ProtoDescriptors, resp, method)
-	if errMarshal != nil {
-		return status.Errorf(codes.Internal, "jsonToProto error: %+v%v", errMarshal, errs.ErrDescURL(errs.InvalidProto))
+// sendHTTPRequest sends the request to the upstream, the non-2xx responses are
+// converted to the errors with the code of the status, the codes configured in
+// the rule take precedence. The response is returned with the closed body for
+// the non-2xx statuses.
+func (g *grpcHandler) sendHTTPRequest(ctx context.Context, upstream *Upstream, rule *httpRule, req *httpRequest) (httpResp *http.Response, failed bool, err error) {
+	base, err := url.Parse(upstream.Endpoint)
+	if err != nil {
+		zap.L().Error("can't parse passthroughEndpoint", zap.Error(err))
+		return nil, false, errs.ErrInvalidEndpoint.New("can't parse service_endpoint %v", err)
 	}
 
-	if err = inStream.SendMsg(protoMessage); err != nil {
-		return status.Errorf(codes.Internal, "error sending response from HTTP service: %+v", err)
+	base = base.JoinPath(req.path)
+	base.RawQuery = req.query.Encode()
+	zap.L().Debug("Calling http service",
+		zap.String("url", base.String()),
+		zap.String("body", string(req.body)),
+		zap.String("method", req.method))
+
+	var reqBody io.Reader = req.stream
+	if req.stream == nil && req.body != nil {
+		reqBody = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, base.String(), reqBody)
+	if err != nil {
+		return nil, false, errs.ErrHTTPRequestBuild.New("error creating http request: %+v", err)
+	}
+	httpReq.Header = req.headers.Clone()
+	if req.body != nil && httpReq.Header.Get("content-type") == "" {
+		httpReq.Header.Set("content-type", "application/json")
 	}
 
-	return nil
+	httpResp, err = g.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, true, errs.ErrServiceCallFailed.New("error executing HTTP service: %+v", err)
+	}
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		defer httpResp.Body.Close()
+		b, _ := io.ReadAll(httpResp.Body)
+		code, ok := rule.statusCode(httpResp.StatusCode)
+		if !ok {
+			code = httpStatusToCode(httpResp.StatusCode)
+		}
+		return httpResp, isGatewayError(httpResp.StatusCode), httpStatusError(code, httpResp.StatusCode, b)
+	}
+	return httpResp, false, nil
+}
+
+// isGatewayError returns true for the statuses returned when the upstream is unavailable
+func isGatewayError(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable ||
+		statusCode == http.StatusGatewayTimeout
 }
 
 func (g *grpcHandler) grpcToJSONRPC(srv any, inStream grpc.ServerStream) error {
 	method, ok := grpc.MethodFromServerStream(inStream)
 	if !ok {
-		return status.Errorf(codes.Internal, "could not determine method from server stream")
+		return errs.ErrUnknownMethod.New("could not determine method from server stream")
 	}
 
+	fullMethod := method
 	methodSegs := strings.Split(method, "/")
 	method = methodSegs[len(methodSegs)-1]
 
 	f := &codec.GrpcFrame{}
 	if err := inStream.RecvMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error receiving request; error: %+v", err)
+		return errs.ErrReceiveMsg.New("error receiving request; error: %+v", err)
 	}
 
 	params := new(any)
 
 	if err := json.Unmarshal(f.Data, params); err != nil {
-		return status.Errorf(codes.Internal, "error unmarshaling request; error: %+v", err)
+		return errs.ErrInvalidRequest.New("error unmarshaling request; error: %+v", err)
 	}
 
 	jsonRPCReq, err := json2.EncodeClientRequest(method, params)
 
 	if err != nil {
-		return status.Errorf(codes.Internal, "error encoding request; error: %+v", err)
+		return errs.ErrInvalidRequest.New("error encoding request; error: %+v", err)
 	}
 
 	inCtx := inStream.Context()
 	outCtx, cancel := withDefaultTimeout(inCtx, g.timeout)
 	defer cancel()
 
-	httpReq, err := http.NewRequestWithContext(outCtx, http.MethodPost, g.passthroughEndpoint, bytes.NewBuffer(jsonRPCReq))
-	if err != nil {
-		return status.Errorf(codes.Internal, "error creating http request; error: %+v", err)
+	jsonRPCResp, attempts, err := callUpstream(g, outCtx, fullMethod, func(ctx context.Context, upstream *Upstream) (httpResponse, bool, error) {
+		body, header, failed, err := g.callJSONRPCService(ctx, upstream, jsonRPCReq)
+		return httpResponse{body: body, header: header}, failed, err
+	})
+	respBody := jsonRPCResp.body
+	if jsonRPCResp.header != nil {
+		inStream.SetTrailer(headersToTrailer(jsonRPCResp.header))
 	}
-
-	httpReq.Header.Set("content-type", "application/json")
-	httpResp, err := g.httpClient.Do(httpReq)
+	inStream.SetTrailer(retryTrailer(attempts))
 	if err != nil {
-		return status.Errorf(codes.Internal, "error executing http call; error: %+v", err)
-	}
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
-		b, _ := io.ReadAll(httpResp.Body)
-		return status.Errorf(codes.Unavailable, "upstream http status %d: %s%v",
-			httpResp.StatusCode, string(b), errs.ErrDescURL(errs.ServiceUnavailable))
+		return err
 	}
 
 	result := new(any)
-	if err = json2.DecodeClientResponse(httpResp.Body, result); err != nil {
-		return status.Errorf(codes.Internal, "json-rpc error; error: %+v", err)
+	if err = json2.DecodeClientResponse(bytes.NewReader(respBody), result); err != nil {
+		if jsonErr, ok := err.(*json2.Error); ok {
+			return jsonRPCStatusError(jsonErr)
+		}
+		return errs.ErrServiceResponse.New("json-rpc error; error: %+v", err)
 	}
 
 	respBytes, err := json.Marshal(result)
 
 	if err != nil {
-		return status.Errorf(codes.Internal, "error marshaling response; error: %+v", err)
+		return errs.ErrServiceResponse.New("error marshaling response; error: %+v", err)
 	}
 
 	f = &codec.GrpcFrame{Data: respBytes}
 
 	if err = inStream.SendMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error sending response; error: %+v", err)
+		return errs.ErrSendResponse.New("error sending response; error: %+v", err)
 	}
 
 	return nil
 }
 
+// callJSONRPCService sends the JSON-RPC request to the upstream, failed is true
+// when the upstream can't be reached or responds with a gateway error. The
+// JSON-RPC error object sent with the non-2xx status is converted to the error.
+func (g *grpcHandler) callJSONRPCService(ctx context.Context, upstream *Upstream, jsonRPCReq []byte) (resp []byte, header http.Header, failed bool, err error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, upstream.Endpoint, bytes.NewBuffer(jsonRPCReq))
+	if err != nil {
+		return nil, nil, false, errs.ErrHTTPRequestBuild.New("error creating http request; error: %+v", err)
+	}
+
+	httpReq.Header.Set("content-type", "application/json")
+	httpResp, err := g.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, true, errs.ErrServiceCallFailed.New("error executing http call; error: %+v", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		var jsonErr *json2.Error
+		if errors.As(json2.DecodeClientResponse(bytes.NewReader(b), new(any)), &jsonErr) {
+			return nil, httpResp.Header, isGatewayError(httpResp.StatusCode), jsonRPCStatusError(jsonErr)
+		}
+		return nil, httpResp.Header, isGatewayError(httpResp.StatusCode),
+			httpStatusError(httpStatusToCode(httpResp.StatusCode), httpResp.StatusCode, b)
+	}
+
+	resp, err = io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, httpResp.Header, false, errs.ErrServiceResponse.New("error reading response; error: %+v", err)
+	}
+	return resp, httpResp.Header, false, nil
+}
+
 func (g *grpcHandler) grpcToProcess(srv any, inStream grpc.ServerStream) error {
 	method, ok := grpc.MethodFromServerStream(inStream)
 
 	if !ok {
-		return status.Errorf(codes.Internal, "could not determine method from server stream")
+		return errs.ErrUnknownMethod.New("could not determine method from server stream")
 	}
 
 	methodSegs := strings.Split(method, "/")
@@ -468,7 +872,7 @@ func (g *grpcHandler) grpcToProcess(srv any, inStream grpc.ServerStream) error {
 
 	f := &codec.GrpcFrame{}
 	if err := inStream.RecvMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error receiving request; error: %+v", err)
+		return errs.ErrReceiveMsg.New("error receiving request; error: %+v", err)
 	}
 
 	inCtx := inStream.Context()
@@ -476,39 +880,65 @@ func (g *grpcHandler) grpcToProcess(srv any, inStream grpc.ServerStream) error {
 	defer cancel()
 
 	cmd := exec.CommandContext(outCtx, g.executable, method)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return status.Errorf(codes.Internal, "error creating stdin pipe; error: %+v", err)
-	}
+	cmd.Stdin = bytes.NewReader(f.Data)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
 
-	if _, err := stdin.Write(f.Data); err != nil {
-		stdin.Close()
-		return status.Errorf(codes.Internal, "error writing to stdin; error: %+v", err)
+	// stderr is logged and never mixed into the response
+	out, err := cmd.Output()
+	if stderr.Len() > 0 {
+		zap.L().Info("process stderr", zap.String("method", method), zap.String("stderr", stderr.String()))
 	}
-	_ = stdin.Close()
-
-	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return status.Errorf(codes.Internal, "process failed: %v; output=%s", err, string(out))
+		return errs.ErrProcessFailed.New("process failed: %v; stderr=%s", err, stderr.String())
 	}
 
 	f = &codec.GrpcFrame{Data: out}
 
 	if err = inStream.SendMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error sending response; error: %+v", err)
+		return errs.ErrSendResponse.New("error sending response; error: %+v", err)
 	}
 
 	return nil
 }
 
+// grpcToProcessPool routes the call to an idle worker of the process pool, the
+// messages of the request and response streams are passed as they are
+func (g *grpcHandler) grpcToProcessPool(srv any, inStream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(inStream)
+	if !ok {
+		return errs.ErrUnknownMethod.New("could not determine method from server stream")
+	}
+
+	// the worker is held for call_timeout at most, the earlier deadline of the client is kept
+	var outCtx context.Context
+	var cancel context.CancelFunc
+	if callTimeout := g.processes.CallTimeout(g.timeout); callTimeout > 0 {
+		outCtx, cancel = context.WithTimeout(inStream.Context(), callTimeout)
+	} else {
+		outCtx, cancel = context.WithCancel(inStream.Context())
+	}
+	defer cancel()
+
+	return g.processes.Call(outCtx, method, func() ([]byte, error) {
+		f := &codec.GrpcFrame{}
+		if err := inStream.RecvMsg(f); err != nil {
+			return nil, err
+		}
+		return f.Data, nil
+	}, func(message []byte) error {
+		return inStream.SendMsg(&codec.GrpcFrame{Data: message})
+	})
+}
+
 func grpcLoopback(srv any, inStream grpc.ServerStream) error {
 	f := &codec.GrpcFrame{}
 	if err := inStream.RecvMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error receiving request; error: %+v", err)
+		return errs.ErrReceiveMsg.New("error receiving request; error: %+v", err)
 	}
 
 	if err := inStream.SendMsg(f); err != nil {
-		return status.Errorf(codes.Internal, "error sending response; error: %+v", err)
+		return errs.ErrSendResponse.New("error sending response; error: %+v", err)
 	}
 
 	return nil
@@ -530,6 +960,9 @@ func (srvCreds serviceCredentials) validate() error {
 			if v.Key == "" {
 				return fmt.Errorf("invalid service_credentials: key can't be empty")
 			}
+			if _, err := v.value(); err != nil {
+				return fmt.Errorf("invalid service_credentials: %v", err)
+			}
 		}
 	}
 	return nil
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/singnet/snet-daemon/v6/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
@@ -129,9 +130,31 @@ func TestHttpCredentials(t *testing.T) {
 			Value:    "123abc",
 			Location: "header",
 		}},
+		{serviceCredential{
+			Key:      "X-Api-Key",
+			Value:    "env://SNETD_TEST_UNSET_API_KEY",
+			Location: "header",
+		}},
 	}
 
 	for _, v := range invalidCreds {
 		assert.NotNil(t, v.validate())
 	}
 }
+
+func TestHttpCredentialsSecretReference(t *testing.T) {
+	t.Setenv("SNETD_TEST_API_KEY", "secret-api-key")
+	config.ReloadSecrets()
+	g := &grpcHandler{serviceCredentials: serviceCredentials{
+		{Key: "X-Api-Key", Value: "env://SNETD_TEST_API_KEY", Location: header},
+		{Key: "api-key", Value: "env://SNETD_TEST_API_KEY", Location: query},
+	}}
+	assert.Nil(t, g.serviceCredentials.validate())
+
+	rule := &httpRule{Method: "GET", Path: "/predict"}
+	assert.Nil(t, rule.init())
+	req, err := g.newHTTPRequest(rule, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret-api-key", req.headers.Get("X-Api-Key"))
+	assert.Equal(t, "secret-api-key", req.query.Get("api-key"))
+}
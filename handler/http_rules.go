@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	ndjsonStream  = "ndjson"
+	sseStream     = "sse"
+	chunkedStream = "chunked"
+)
+
+// httpRule describes how the gRPC method is called on the HTTP service. Rules are
+// set in http_rpc_mapping, otherwise they are taken from the google.api.http
+// annotation of the method.
+// RPC          - full method name, e.g. /example_service.Calculator/add
+// Method       - HTTP method, POST by default
+// Path         - path template appended to service_endpoint, e.g. /v1/items/{id}, the method name by default
+// Body         - request field sent as the body, "*" for the whole request, "" to send the fields as the query
+// ResponseBody - response field filled with the body, the whole response by default
+// Stream       - format of the streamed messages: ndjson, sse or chunked, by default the format is
+//
+//	detected from the response content type
+//
+// StatusCodes  - gRPC codes returned for the HTTP statuses of the service, e.g. {"404": "NOT_FOUND"}
+type httpRule struct {
+	RPC          string            `json:"rpc" mapstructure:"rpc"`
+	Method       string            `json:"method" mapstructure:"method"`
+	Path         string            `json:"path" mapstructure:"path"`
+	Body         *string           `json:"body" mapstructure:"body"`
+	ResponseBody string            `json:"response_body" mapstructure:"response_body"`
+	Stream       string            `json:"stream" mapstructure:"stream"`
+	StatusCodes  map[string]string `json:"status_codes" mapstructure:"status_codes"`
+
+	statusCodes map[int]codes.Code
+}
+
+type httpRules []*httpRule
+
+var httpRuleMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+func (rules httpRules) validate() error {
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.RPC, "/") || strings.Count(rule.RPC, "/") != 2 {
+			return fmt.Errorf("invalid http_rpc_mapping rpc %q, expected full method name like /package.Service/Method", rule.RPC)
+		}
+		if err := rule.init(); err != nil {
+			return fmt.Errorf("invalid http_rpc_mapping for %v: %v", rule.RPC, err)
+		}
+	}
+	return nil
+}
+
+// find returns the rule configured for the method or nil
+func (rules httpRules) find(fullMethod string) *httpRule {
+	for _, rule := range rules {
+		if rule.RPC == fullMethod {
+			return rule
+		}
+	}
+	return nil
+}
+
+// init sets the defaults of the rule and parses the status codes
+func (rule *httpRule) init() error {
+	if rule.Method == "" {
+		rule.Method = http.MethodPost
+	}
+	rule.Method = strings.ToUpper(rule.Method)
+	if !slices.Contains(httpRuleMethods, rule.Method) {
+		return fmt.Errorf("unsupported method %q", rule.Method)
+	}
+	if rule.Path == "" {
+		rule.Path = "/" + rule.RPC[strings.LastIndex(rule.RPC, "/")+1:]
+	}
+	if rule.Body == nil {
+		body := "*"
+		if rule.Method == http.MethodGet || rule.Method == http.MethodDelete {
+			body = ""
+		}
+		rule.Body = &body
+	}
+	switch rule.Stream {
+	case "", ndjsonStream, sseStream, chunkedStream:
+	default:
+		return fmt.Errorf("unsupported stream %q, supported formats are %v, %v and %v", rule.Stream,
+			ndjsonStream, sseStream, chunkedStream)
+	}
+	rule.statusCodes = make(map[int]codes.Code, len(rule.StatusCodes))
+	for httpStatus, grpcCode := range rule.StatusCodes {
+		statusCode, err := strconv.Atoi(httpStatus)
+		if err != nil || statusCode < 100 || statusCode > 599 {
+			return fmt.Errorf("invalid http status %q", httpStatus)
+		}
+		var code codes.Code
+		if err = code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(grpcCode)))); err != nil {
+			return fmt.Errorf("invalid grpc code %q for http status %v", grpcCode, statusCode)
+		}
+		rule.statusCodes[statusCode] = code
+	}
+	return nil
+}
+
+// annotatedRule is the cached rule of the method descriptor, the rule is built
+// again when the service metadata is changed and the method has a new descriptor
+type annotatedRule struct {
+	method protoreflect.MethodDescriptor
+	rule   *httpRule
+}
+
+// httpRuleFor returns the rule of the method: the rule from http_rpc_mapping,
+// the google.api.http annotation or POST to the method name.
+func (g *grpcHandler) httpRuleFor(fullMethod string, method protoreflect.MethodDescriptor) *httpRule {
+	if rule := g.httpRules.find(fullMethod); rule != nil {
+		return rule
+	}
+	if cached, ok := g.annotatedRules.Load(fullMethod); ok && cached.(annotatedRule).method == method {
+		return cached.(annotatedRule).rule
+	}
+	rule := &httpRule{RPC: fullMethod}
+	if annotated := httpRuleFromAnnotation(method); annotated != nil {
+		rule = annotated
+		rule.RPC = fullMethod
+	}
+	// the rule without stream and status codes is always valid
+	_ = rule.init()
+	g.annotatedRules.Store(fullMethod, annotatedRule{method: method, rule: rule})
+	return rule
+}
+
+// httpRuleFromAnnotation converts the google.api.http option of the method, only
+// the main binding is used
+func httpRuleFromAnnotation(method protoreflect.MethodDescriptor) *httpRule {
+	if method == nil {
+		return nil
+	}
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok || options == nil {
+		return nil
+	}
+	// the compiled options keep the extension as unknown field or dynamic message,
+	// decode them again to get the generated type
+	raw, err := proto.Marshal(options)
+	if err != nil {
+		return nil
+	}
+	options = &descriptorpb.MethodOptions{}
+	if err = (proto.UnmarshalOptions{Resolver: protoregistry.GlobalTypes}).Unmarshal(raw, options); err != nil {
+		return nil
+	}
+	annotation, ok := proto.GetExtension(options, annotations.E_Http).(*annotations.HttpRule)
+	if !ok || annotation == nil || annotation.GetPattern() == nil {
+		return nil
+	}
+
+	body := annotation.GetBody()
+	rule := &httpRule{Body: &body, ResponseBody: annotation.GetResponseBody()}
+	switch pattern := annotation.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		rule.Method, rule.Path = http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Post:
+		rule.Method, rule.Path = http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Put:
+		rule.Method, rule.Path = http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Patch:
+		rule.Method, rule.Path = http.MethodPatch, pattern.Patch
+	case *annotations.HttpRule_Delete:
+		rule.Method, rule.Path = http.MethodDelete, pattern.Delete
+	default:
+		return nil
+	}
+	return rule
+}
+
+var pathVariable = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?}`)
+
+// buildRequest splits the request converted to JSON into the path, query and
+// body of the HTTP request according to the rule, input is the descriptor of
+// the request used for the path fields omitted with the default values
+func (rule *httpRule) buildRequest(requestJSON []byte, input protoreflect.MessageDescriptor) (requestPath string, query url.Values, requestBody map[string]any, err error) {
+	fields := map[string]any{}
+	if len(requestJSON) > 0 {
+		if err = json.Unmarshal(requestJSON, &fields); err != nil {
+			return "", nil, nil, fmt.Errorf("request is not a JSON object: %v", err)
+		}
+	}
+
+	requestPath = pathVariable.ReplaceAllStringFunc(rule.Path, func(variable string) string {
+		match := pathVariable.FindStringSubmatch(variable)
+		value, ok := popField(fields, match[1])
+		if !ok {
+			if value, ok = defaultFieldValue(input, match[1]); !ok {
+				err = fmt.Errorf("request has no field %q of the path %v", match[1], rule.Path)
+				return ""
+			}
+		}
+		// multi segment variables like {name=items/**} keep the slashes
+		if strings.Contains(match[2], "**") {
+			return (&url.URL{Path: fmt.Sprint(value)}).EscapedPath()
+		}
+		return url.PathEscape(fmt.Sprint(value))
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	query = url.Values{}
+	switch *rule.Body {
+	case "*":
+		return requestPath, query, fields, nil
+	case "":
+	default:
+		value, _ := popField(fields, *rule.Body)
+		requestBody, _ = value.(map[string]any)
+		if requestBody == nil && value != nil {
+			return "", nil, nil, fmt.Errorf("request field %q is not a message", *rule.Body)
+		}
+		if requestBody == nil {
+			requestBody = map[string]any{}
+		}
+	}
+	addQueryFields(query, "", fields)
+	return requestPath, query, requestBody, nil
+}
+
+// popField removes the field with the dotted path from the fields and returns its value
+func popField(fields map[string]any, fieldPath string) (value any, ok bool) {
+	names := strings.Split(fieldPath, ".")
+	for _, name := range names[:len(names)-1] {
+		if fields, ok = fields[name].(map[string]any); !ok {
+			return nil, false
+		}
+	}
+	value, ok = fields[names[len(names)-1]]
+	delete(fields, names[len(names)-1])
+	return value, ok
+}
+
+// defaultFieldValue returns the default value of the scalar field with the dotted
+// path, protojson omits the fields with the default values
+func defaultFieldValue(message protoreflect.MessageDescriptor, fieldPath string) (value any, ok bool) {
+	if message == nil {
+		return nil, false
+	}
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		field := message.Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.IsList() || field.IsMap() {
+			return nil, false
+		}
+		if i == len(names)-1 {
+			if field.Message() != nil {
+				return nil, false
+			}
+			if field.Enum() != nil {
+				return string(field.DefaultEnumValue().Name()), true
+			}
+			return field.Default().Interface(), true
+		}
+		if message = field.Message(); message == nil {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// addQueryFields adds the fields to the query, nested messages use the dotted names
+func addQueryFields(query url.Values, prefix string, fields map[string]any) {
+	for name, value := range fields {
+		switch value := value.(type) {
+		case map[string]any:
+			addQueryFields(query, prefix+name+".", value)
+		case []any:
+			for _, item := range value {
+				query.Add(prefix+name, fmt.Sprint(item))
+			}
+		case nil:
+		default:
+			query.Add(prefix+name, fmt.Sprint(value))
+		}
+	}
+}
+
+// responseJSON puts the response body into response_body field when it is set
+func (rule *httpRule) responseJSON(body []byte) []byte {
+	if rule.ResponseBody == "" {
+		return body
+	}
+	response, err := json.Marshal(map[string]json.RawMessage{rule.ResponseBody: body})
+	if err != nil {
+		return body
+	}
+	return response
+}
+
+// statusCode returns the gRPC code configured for the HTTP status of the service
+func (rule *httpRule) statusCode(httpStatus int) (code codes.Code, ok bool) {
+	code, ok = rule.statusCodes[httpStatus]
+	return
+}
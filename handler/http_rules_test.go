@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const httpRulesTestProto = `
+syntax = "proto3";
+package items;
+import "google/api/annotations.proto";
+service Items {
+	rpc Get(GetRequest) returns (Item) {
+		option (google.api.http) = { get: "/v1/shelves/{shelf}/items/{id}" };
+	}
+	rpc Update(UpdateRequest) returns (Item) {
+		option (google.api.http) = { patch: "/v1/items/{item.id}" body: "item" };
+	}
+	rpc Count(CountRequest) returns (stream Item);
+	rpc Sum(stream Item) returns (Item);
+}
+message GetRequest { string shelf = 1; int64 id = 2; string view = 3; }
+message UpdateRequest { Item item = 1; bool validate = 2; }
+message CountRequest { int32 to = 1; }
+message Item { int64 id = 1; string name = 2; }
+`
+
+// httpTestStream is the server stream of the proxied call
+type httpTestStream struct {
+	serverStreamMock
+	requests [][]byte
+	sent     []string
+}
+
+func (m *httpTestStream) RecvMsg(msg any) error {
+	if len(m.requests) == 0 {
+		return io.EOF
+	}
+	msg.(*codec.GrpcFrame).Data, m.requests = m.requests[0], m.requests[1:]
+	return nil
+}
+
+func (m *httpTestStream) SendMsg(msg any) error {
+	b, err := protojson.Marshal(msg.(proto.Message))
+	m.sent = append(m.sent, strings.ReplaceAll(string(b), " ", ""))
+	return err
+}
+
+type methodTransportStream struct {
+	grpc.ServerTransportStream
+	method string
+}
+
+func (s *methodTransportStream) Method() string { return s.method }
+
+func newHTTPTestHandler(t *testing.T, serviceURL string) *grpcHandler {
+	settings := &config.ServiceLoadBalancingSettings{}
+	return &grpcHandler{
+		serviceMetaData: &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"items.proto": httpRulesTestProto})},
+		upstreams:       NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: serviceURL}}, settings, nil, nil, "", ""),
+		loadBalancing:   settings,
+		httpClient:      &http.Client{},
+		timeout:         5 * time.Second,
+	}
+}
+
+func (g *grpcHandler) testCall(t *testing.T, fullMethod string, requests ...string) (*httpTestStream, error) {
+	method := g.findMethod(fullMethod)
+	require.NotNil(t, method)
+	stream := &httpTestStream{}
+	for _, request := range requests {
+		msg := dynamicpb.NewMessage(method.Input())
+		require.Nil(t, protojson.Unmarshal([]byte(request), msg))
+		data, err := proto.Marshal(msg)
+		require.Nil(t, err)
+		stream.requests = append(stream.requests, data)
+	}
+	stream.context = grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		&methodTransportStream{method: fullMethod})
+	return stream, g.grpcToHTTP(nil, stream)
+}
+
+func TestHttpRule_buildRequest(t *testing.T) {
+	g := newHTTPTestHandler(t, "")
+
+	rule := g.httpRuleFor("/items.Items/Get", g.findMethod("/items.Items/Get"))
+	assert.Equal(t, http.MethodGet, rule.Method)
+	requestPath, query, body, err := rule.buildRequest([]byte(`{"shelf":"a b","view":"full"}`), g.findMethod("/items.Items/Get").Input())
+	assert.Nil(t, err)
+	assert.Equal(t, "/v1/shelves/a%20b/items/0", requestPath)
+	assert.Equal(t, "view=full", query.Encode())
+	assert.Nil(t, body)
+
+	rule = g.httpRuleFor("/items.Items/Update", g.findMethod("/items.Items/Update"))
+	assert.Equal(t, http.MethodPatch, rule.Method)
+	requestPath, query, body, err = rule.buildRequest([]byte(`{"item":{"id":"7","name":"book"},"validate":true}`), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/v1/items/7", requestPath)
+	assert.Equal(t, "validate=true", query.Encode())
+	assert.Equal(t, map[string]any{"name": "book"}, body)
+
+	// methods without rule are posted to the method name
+	rule = g.httpRuleFor("/items.Items/Count", g.findMethod("/items.Items/Count"))
+	assert.Equal(t, http.MethodPost, rule.Method)
+	assert.Equal(t, "/Count", rule.Path)
+
+	// the configured rule overrides the annotation
+	g.httpRules = httpRules{{RPC: "/items.Items/Get", Method: "post", Path: "/get", StatusCodes: map[string]string{"404": "not_found"}}}
+	assert.Nil(t, g.httpRules.validate())
+	rule = g.httpRuleFor("/items.Items/Get", g.findMethod("/items.Items/Get"))
+	assert.Equal(t, http.MethodPost, rule.Method)
+	assert.Equal(t, "*", *rule.Body)
+	code, ok := rule.statusCode(http.StatusNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, codes.NotFound, code)
+
+	// the annotated rule is built again for the changed service metadata
+	g.httpRules = nil
+	assert.Equal(t, "/v1/shelves/{shelf}/items/{id}", g.httpRuleFor("/items.Items/Get", g.findMethod("/items.Items/Get")).Path)
+	g.serviceMetaData = &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{
+		"items.proto": strings.Replace(httpRulesTestProto, "/v1/shelves/{shelf}/items/{id}", "/v2/items/{id}", 1)})}
+	assert.Equal(t, "/v2/items/{id}", g.httpRuleFor("/items.Items/Get", g.findMethod("/items.Items/Get")).Path)
+
+	assert.NotNil(t, httpRules{{RPC: "Get"}}.validate())
+	assert.NotNil(t, httpRules{{RPC: "/items.Items/Get", Stream: "websocket"}}.validate())
+	assert.NotNil(t, httpRules{{RPC: "/items.Items/Get", StatusCodes: map[string]string{"404": "missing"}}}.validate())
+}
+
+func TestReadHTTPStream(t *testing.T) {
+	read := func(format string, body string) (messages []string, err error) {
+		err = readHTTPStream(strings.NewReader(body), format, 1024, func(message []byte) error {
+			messages = append(messages, string(message))
+			return nil
+		})
+		return
+	}
+
+	messages, err := read(ndjsonStream, "{\"id\":1}\n\n{\"id\":2}\n")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2}`}, messages)
+
+	messages, err = read(sseStream, ": comment\nevent: item\ndata: {\"id\":1}\n\ndata: {\"id\":\ndata: 2}\n\ndata: [DONE]\n\ndata: {}\n\n")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":1}`, "{\"id\":\n2}"}, messages)
+
+	messages, err = read(chunkedStream, ` [{"id":1}, {"id":2}]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2}`}, messages)
+
+	messages, err = read(chunkedStream, `{"id":1}{"id":2}`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2}`}, messages)
+
+	_, err = read(ndjsonStream, strings.Repeat("a", 2048))
+	assert.NotNil(t, err)
+
+	assert.Equal(t, sseStream, streamFormat("", "text/event-stream; charset=utf-8"))
+	assert.Equal(t, ndjsonStream, streamFormat("", "application/x-ndjson"))
+	assert.Equal(t, chunkedStream, streamFormat("", "application/json"))
+	assert.Equal(t, ndjsonStream, streamFormat(ndjsonStream, "application/json"))
+}
+
+func TestGrpcToHTTP_Streaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/shelves/main/items/3":
+			assert.Equal(t, http.MethodGet, r.Method)
+			_, _ = w.Write([]byte(`{"id":3,"name":"book"}`))
+		case "/Count":
+			var request map[string]int
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+			w.Header().Set("content-type", "text/event-stream")
+			for i := 1; i <= request["to"]; i++ {
+				_, _ = w.Write([]byte("data: {\"id\":" + string(rune('0'+i)) + "}\n\n"))
+				w.(http.Flusher).Flush()
+			}
+		case "/Sum":
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("content-type"))
+			var sum, count int
+			scanner := bufio.NewScanner(r.Body)
+			var keys string
+			for scanner.Scan() {
+				var item struct {
+					ID     int    `json:"id,string"`
+					APIKey string `json:"api_key"`
+				}
+				assert.Nil(t, json.Unmarshal(scanner.Bytes(), &item))
+				sum += item.ID
+				count++
+				keys += item.APIKey
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": sum, "name": strings.Repeat("x", count) + keys})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	g := newHTTPTestHandler(t, server.URL)
+
+	stream, err := g.testCall(t, "/items.Items/Get", `{"shelf":"main","id":"3"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":"3","name":"book"}`}, stream.sent)
+
+	stream, err = g.testCall(t, "/items.Items/Count", `{"to":3}`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":"1"}`, `{"id":"2"}`, `{"id":"3"}`}, stream.sent)
+
+	stream, err = g.testCall(t, "/items.Items/Sum", `{"id":"1"}`, `{"id":"2"}`, `{"id":"4"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":"7","name":"xxx"}`}, stream.sent)
+
+	// the body service_credentials are added to every message of the client stream
+	g.serviceCredentials = serviceCredentials{{Key: "api_key", Value: "k", Location: body}, {Key: "x-key", Value: "h", Location: header}}
+	stream, err = g.testCall(t, "/items.Items/Sum", `{"id":"1"}`, `{"id":"2"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id":"3","name":"xxkk"}`}, stream.sent)
+	g.serviceCredentials = nil
+
+	g.httpRules = httpRules{{RPC: "/items.Items/Update", StatusCodes: map[string]string{"404": "NOT_FOUND"}}}
+	assert.Nil(t, g.httpRules.validate())
+	_, err = g.testCall(t, "/items.Items/Update", `{"item":{"id":"1"}}`)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
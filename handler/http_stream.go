@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+)
+
+// streamContentTypes are the content types of the stream formats
+var streamContentTypes = map[string]string{
+	ndjsonStream:  "application/x-ndjson",
+	sseStream:     "text/event-stream",
+	chunkedStream: "application/json",
+}
+
+// streamFormat returns the format of the stream sent by the HTTP service, the
+// format of the rule is used when set
+func streamFormat(ruleFormat string, contentType string) string {
+	if ruleFormat != "" {
+		return ruleFormat
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/event-stream":
+		return sseStream
+	case "application/x-ndjson", "application/jsonl", "application/jsonlines", "application/stream+json":
+		return ndjsonStream
+	}
+	return chunkedStream
+}
+
+// writeHTTPStream writes the JSON message to the request stream in the format
+func writeHTTPStream(w io.Writer, format string, message []byte) (err error) {
+	switch format {
+	case sseStream:
+		_, err = w.Write([]byte("data: " + strings.ReplaceAll(string(message), "\n", "\ndata: ") + "\n\n"))
+	default:
+		// ndjson and the chunked stream of JSON values are separated by new lines
+		_, err = w.Write(append(bytes.TrimSpace(message), '\n'))
+	}
+	return
+}
+
+// readHTTPStream reads the JSON messages streamed by the HTTP service in the
+// format and calls send for each message. maxSize limits the size of the
+// ndjson line and sse event.
+func readHTTPStream(body io.Reader, format string, maxSize int, send func(message []byte) error) error {
+	switch format {
+	case ndjsonStream:
+		return readNDJSON(body, maxSize, send)
+	case sseStream:
+		return readSSE(body, maxSize, send)
+	}
+	return readJSONValues(body, send)
+}
+
+func readNDJSON(body io.Reader, maxSize int, send func(message []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxSize)), maxSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := send(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readSSE sends the data of every server-sent event, the "[DONE]" data ends the stream
+func readSSE(body io.Reader, maxSize int, send func(message []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxSize)), maxSize)
+	var data []string
+	dispatch := func() (done bool, err error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		message := strings.Join(data, "\n")
+		data = data[:0]
+		if message == "[DONE]" {
+			return true, nil
+		}
+		return false, send([]byte(message))
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if done, err := dispatch(); done || err != nil {
+				return err
+			}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		if field == "data" {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	_, err := dispatch()
+	return err
+}
+
+// readJSONValues reads the stream of concatenated JSON values, the elements of
+// the top level JSON array are sent one by one
+func readJSONValues(body io.Reader, send func(message []byte) error) error {
+	reader := bufio.NewReader(body)
+	first, err := peekNonSpace(reader)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		if _, err = decoder.Token(); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var message json.RawMessage
+			if err = decoder.Decode(&message); err != nil {
+				return err
+			}
+			if err = send(message); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	}
+
+	for {
+		var message json.RawMessage
+		if err = decoder.Decode(&message); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = send(message); err != nil {
+			return err
+		}
+	}
+}
+
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = reader.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
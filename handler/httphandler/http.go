@@ -21,7 +21,7 @@ type httpHandler struct {
 func NewHTTPHandler(blockProc blockchain.Processor) http.Handler {
 	return &httpHandler{
 		passthroughEnabled:  config.GetBool(config.PassthroughEnabledKey),
-		passthroughEndpoint: config.GetString(config.ServiceEndpointKey),
+		passthroughEndpoint: config.GetServiceEndpoint(),
 		rateLimiter:         *ratelimit.NewRateLimiter(),
 	}
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
 
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
@@ -130,7 +131,7 @@ func (suite *InterceptorsSuite) TestGetBytesFromHexStringNoValue() {
 
 	_, err := GetBytesFromHex(md, "test-key")
 
-	assert.Equal(suite.T(), NewGrpcErrorf(codes.InvalidArgument, "missing \"test-key\""), err)
+	assert.Equal(suite.T(), NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"test-key\"").With("header", "test-key")), err)
 }
 
 func (suite *InterceptorsSuite) TestGetBytesFromHexStringTooManyValues() {
@@ -138,7 +139,7 @@ func (suite *InterceptorsSuite) TestGetBytesFromHexStringTooManyValues() {
 
 	_, err := GetBytesFromHex(md, "test-key")
 
-	assert.Equal(suite.T(), NewGrpcErrorf(codes.InvalidArgument, "too many values for key \"test-key\": [0x123 FED]"), err)
+	assert.Equal(suite.T(), NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("too many values for key \"test-key\": [0x123 FED]").With("header", "test-key")), err)
 }
 
 func (suite *InterceptorsSuite) TestGetBigInt() {
@@ -155,7 +156,7 @@ func (suite *InterceptorsSuite) TestGetBigIntIncorrectValue() {
 
 	_, err := GetBigInt(md, "big-int-key")
 
-	assert.Equal(suite.T(), NewGrpcErrorf(codes.InvalidArgument, "incorrect format \"big-int-key\": \"12345abc\""), err)
+	assert.Equal(suite.T(), NewGrpcErrorFrom(errs.ErrInvalidPaymentHeader.New("incorrect format \"big-int-key\": \"12345abc\"").With("header", "big-int-key")), err)
 }
 
 func (suite *InterceptorsSuite) TestGetBigIntNoValue() {
@@ -163,7 +164,7 @@ func (suite *InterceptorsSuite) TestGetBigIntNoValue() {
 
 	_, err := GetBigInt(md, "big-int-key")
 
-	assert.Equal(suite.T(), NewGrpcErrorf(codes.InvalidArgument, "missing \"big-int-key\""), err)
+	assert.Equal(suite.T(), NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"big-int-key\"").With("header", "big-int-key")), err)
 }
 
 func (suite *InterceptorsSuite) TestGetBigIntTooManyValues() {
@@ -171,7 +172,7 @@ func (suite *InterceptorsSuite) TestGetBigIntTooManyValues() {
 
 	_, err := GetBigInt(md, "big-int-key")
 
-	assert.Equal(suite.T(), NewGrpcErrorf(codes.InvalidArgument, "too many values for key \"big-int-key\": [12345 54321]"), err)
+	assert.Equal(suite.T(), NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("too many values for key \"big-int-key\": [12345 54321]").With("header", "big-int-key")), err)
 }
 
 func (suite *InterceptorsSuite) TestGetBytes() {
@@ -188,7 +189,7 @@ func (suite *InterceptorsSuite) TestGetBytesIncorrectBinaryKey() {
 
 	_, err := GetBytes(md, "binary-key")
 
-	assert.Equal(suite.T(), NewGrpcErrorf(codes.InvalidArgument, "incorrect binary key name \"binary-key\""), err)
+	assert.Equal(suite.T(), NewGrpcErrorFrom(errs.ErrInvalidPaymentHeader.New("incorrect binary key name \"binary-key\"").With("header", "binary-key")), err)
 }
 
 func (suite *InterceptorsSuite) TestCompleteOnHandlerError() {
@@ -143,7 +143,11 @@ func setupEnvironment() *integrationTestEnvironment {
 		ServiceType: "grpc",
 		Encoding:    "proto",
 	}
-	grpcToGrpc := handler.NewGrpcHandler(serviceMetadata)
+	upstreams, err := handler.NewServiceUpstreamPool()
+	if err != nil {
+		zap.L().Fatal("Failed to create upstream pool", zap.Error(err))
+	}
+	grpcToGrpc := handler.NewGrpcHandler(serviceMetadata, upstreams, nil)
 	grpcServerA, listenerA := startServerA(":5001", grpcToGrpc)
 	grpcServerB, listenerB := startServerB(":5002")
 
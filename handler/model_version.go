@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ModelVersionResolver returns the version of the model used by the inference
+// call, pinned is the version requested by the call. The empty version means
+// the model has no versions.
+type ModelVersionResolver func(modelID string, pinned string) (version string, err error)
+
+// GrpcModelVersionInterceptor resolves the version of the training model used by
+// the inference call before the payment validation. The call uses the current
+// version of the model unless it pins another version with the
+// snet-train-model-version metadata; the resolved version is passed to the
+// service in the same metadata.
+func GrpcModelVersionInterceptor(resolve ModelVersionResolver) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, ok := metadata.FromIncomingContext(ss.Context())
+		if !ok || len(md.Get(TrainingModelId)) == 0 || strings.HasPrefix(info.FullMethod, "/training.") {
+			return handler(srv, ss)
+		}
+		modelID := md.Get(TrainingModelId)[0]
+		var pinned string
+		if values := md.Get(TrainingModelVersion); len(values) > 0 {
+			pinned = values[0]
+		}
+
+		version, err := resolve(modelID, pinned)
+		if err != nil {
+			zap.L().Debug("can't resolve the model version", zap.String("modelID", modelID),
+				zap.String("version", pinned), zap.Error(err))
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if version == pinned {
+			return handler(srv, ss)
+		}
+		md = md.Copy()
+		md.Set(TrainingModelVersion, version)
+		return handler(srv, &modelVersionServerStream{ServerStream: ss, ctx: metadata.NewIncomingContext(ss.Context(), md)})
+	}
+}
+
+// modelVersionServerStream returns the context with the resolved model version
+type modelVersionServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *modelVersionServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcModelVersionInterceptor(t *testing.T) {
+	resolve := func(modelID string, pinned string) (string, error) {
+		switch {
+		case modelID != "model":
+			return "", nil
+		case pinned == "":
+			return "3", nil
+		case pinned == "2" || pinned == "3":
+			return pinned, nil
+		}
+		return "", errors.New("version isn't ready to use")
+	}
+	interceptor := GrpcModelVersionInterceptor(resolve)
+	info := &grpc.StreamServerInfo{FullMethod: "/service.Service/Predict"}
+
+	call := func(md metadata.MD) (version []string, err error) {
+		stream := &serverStreamMock{context: metadata.NewIncomingContext(context.Background(), md)}
+		err = interceptor(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+			received, _ := metadata.FromIncomingContext(ss.Context())
+			version = received.Get(TrainingModelVersion)
+			return nil
+		})
+		return
+	}
+
+	version, err := call(metadata.Pairs(TrainingModelId, "model"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3"}, version)
+
+	version, err = call(metadata.Pairs(TrainingModelId, "model", TrainingModelVersion, "2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, version)
+
+	_, err = call(metadata.Pairs(TrainingModelId, "model", TrainingModelVersion, "1"))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	version, err = call(metadata.Pairs(TrainingModelId, "legacy"))
+	assert.Nil(t, err)
+	assert.Empty(t, version)
+
+	version, err = call(metadata.Pairs())
+	assert.Nil(t, err)
+	assert.Empty(t, version)
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// OpenAPIPath is the path of the OpenAPI document of the REST ingress
+const OpenAPIPath = "/v1/openapi.json"
+
+// restHeaderParameters are the payment headers documented for every operation
+var restHeaderParameters = []struct{ name, description string }{
+	{PaymentTypeHeader, "payment type: escrow, free-call, prepaid-call or train-call"},
+	{PaymentChannelIDHeader, "payment channel id"},
+	{PaymentChannelNonceHeader, "payment channel nonce"},
+	{PaymentChannelAmountHeader, "authorized amount in cogs"},
+	{PaymentChannelSignatureHeader, "base64 encoded signature of the payment"},
+	{CurrentBlockNumberHeader, "block number used in the signature"},
+	{PrePaidAuthTokenHeader, "base64 encoded prepaid token"},
+	{FreeCallAuthTokenHeader, "base64 encoded free call token"},
+	{FreeCallUserAddressHeader, "address of the free call user"},
+}
+
+// OpenAPIDocument builds the OpenAPI 3 document of the REST ingress from the proto
+// descriptors of the service, the price of every method is in x-snet-pricing
+func OpenAPIDocument(serviceMetadata *blockchain.ServiceMetadata) ([]byte, error) {
+	parameters := map[string]any{}
+	var parameterRefs []any
+	for _, header := range restHeaderParameters {
+		parameters[header.name] = map[string]any{
+			"name":        header.name,
+			"in":          "header",
+			"required":    false,
+			"description": header.description,
+			"schema":      map[string]any{"type": "string"},
+		}
+		parameterRefs = append(parameterRefs, map[string]any{"$ref": "#/components/parameters/" + header.name})
+	}
+
+	schemas := map[string]any{
+		"Status": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code":    map[string]any{"type": "integer", "format": "int32"},
+				"message": map[string]any{"type": "string"},
+				"details": map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+			},
+		},
+	}
+	paths := map[string]any{}
+	for _, protoFile := range serviceMetadata.ProtoDescriptors {
+		services := protoFile.Services()
+		for i := range services.Len() {
+			service := services.Get(i)
+			for j := range service.Methods().Len() {
+				method := service.Methods().Get(j)
+				if method.IsStreamingClient() || method.IsStreamingServer() {
+					continue
+				}
+				addMessageSchema(schemas, method.Input())
+				addMessageSchema(schemas, method.Output())
+				fullMethod := "/" + string(service.FullName()) + "/" + string(method.Name())
+				operation := map[string]any{
+					"operationId": string(service.Name()) + "_" + string(method.Name()),
+					"tags":        []string{string(service.FullName())},
+					"parameters":  parameterRefs,
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  jsonContent(schemaRef(method.Input())),
+					},
+					"responses": map[string]any{
+						"200":     map[string]any{"description": "OK", "content": jsonContent(schemaRef(method.Output()))},
+						"default": map[string]any{"description": "error", "content": jsonContent(map[string]any{"$ref": "#/components/schemas/Status"})},
+					},
+					"x-snet-pricing": methodPricing(serviceMetadata, fullMethod),
+				}
+				if comments := strings.TrimSpace(protoFile.SourceLocations().ByDescriptor(method).LeadingComments); comments != "" {
+					operation["description"] = comments
+				}
+				paths[RESTPathPrefix+string(service.FullName())+"/"+string(method.Name())] = map[string]any{"post": operation}
+			}
+		}
+	}
+
+	title := serviceMetadata.GetDisplayName()
+	if title == "" {
+		title = config.GetString(config.ServiceId)
+	}
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":             title,
+			"version":           "1",
+			"x-snet-free-calls": serviceMetadata.GetFreeCallsAllowed(),
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"parameters": parameters,
+			"schemas":    schemas,
+		},
+	}, "", "  ")
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func schemaRef(message protoreflect.MessageDescriptor) map[string]any {
+	if schema := wellKnownSchema(message); schema != nil {
+		return schema
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + string(message.FullName())}
+}
+
+// methodPricing returns the price of the method according to the default pricing of the group
+func methodPricing(serviceMetadata *blockchain.ServiceMetadata, fullMethod string) map[string]any {
+	if pricingMethod, ok := serviceMetadata.GetDynamicPricingMethodAssociated(fullMethod); ok {
+		return map[string]any{"price_model": "dynamic_pricing", "pricing_method": pricingMethod}
+	}
+	pricing := serviceMetadata.GetDefaultPricing()
+	result := map[string]any{"price_model": pricing.PriceModel}
+	if pricing.PriceInCogs != nil {
+		result["price_in_cogs"] = pricing.PriceInCogs.String()
+	}
+	prefix := pricing.PackageName
+	if prefix != "" {
+		prefix += "."
+	}
+	for _, details := range pricing.PricingDetails {
+		for _, method := range details.MethodPricing {
+			if "/"+prefix+details.ServiceName+"/"+method.MethodName == fullMethod && method.PriceInCogs != nil {
+				result["price_in_cogs"] = method.PriceInCogs.String()
+			}
+		}
+	}
+	return result
+}
+
+// addMessageSchema adds the schema of the message and of the messages of its fields.
+// The properties use the proto field names like the responses of the REST ingress.
+func addMessageSchema(schemas map[string]any, message protoreflect.MessageDescriptor) {
+	name := string(message.FullName())
+	if _, ok := schemas[name]; ok || wellKnownSchema(message) != nil {
+		return
+	}
+	properties := map[string]any{}
+	schema := map[string]any{"type": "object", "properties": properties}
+	schemas[name] = schema
+	fields := message.Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		properties[string(field.Name())] = fieldSchema(schemas, field)
+	}
+}
+
+func fieldSchema(schemas map[string]any, field protoreflect.FieldDescriptor) map[string]any {
+	if field.IsMap() {
+		return map[string]any{"type": "object", "additionalProperties": singularSchema(schemas, field.MapValue())}
+	}
+	if field.IsList() {
+		return map[string]any{"type": "array", "items": singularSchema(schemas, field)}
+	}
+	return singularSchema(schemas, field)
+}
+
+// singularSchema returns the schema of a single value of the field in the protojson format
+func singularSchema(schemas map[string]any, field protoreflect.FieldDescriptor) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson writes the 64-bit integers as strings
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := range values.Len() {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		addMessageSchema(schemas, field.Message())
+		return schemaRef(field.Message())
+	}
+	return map[string]any{"type": "string"}
+}
+
+// wellKnownSchema returns the schema of the well-known types with the special JSON form
+func wellKnownSchema(message protoreflect.MessageDescriptor) map[string]any {
+	switch message.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return map[string]any{"type": "string"}
+	case "google.protobuf.Struct", "google.protobuf.Any", "google.protobuf.Empty":
+		return map[string]any{"type": "object"}
+	case "google.protobuf.ListValue":
+		return map[string]any{"type": "array", "items": map[string]any{}}
+	case "google.protobuf.Value":
+		return map[string]any{}
+	case "google.protobuf.StringValue", "google.protobuf.BytesValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return map[string]any{"type": "string"}
+	case "google.protobuf.BoolValue":
+		return map[string]any{"type": "boolean"}
+	case "google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		return map[string]any{"type": "integer"}
+	case "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return map[string]any{"type": "number"}
+	}
+	return nil
+}
@@ -0,0 +1,440 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Frame types of the process pool protocol. Every frame written to the stdin and
+// read from the stdout of the worker is the type byte, the big-endian uint32
+// length of the payload and the payload. The call is the "C" frame with the
+// full method name, the "M" frames with the request messages and the "E" frame.
+// The worker answers with the "M" frames of the response messages and the "E"
+// frame, or the "X" frame with the error {"code": 3, "message": "..."}.
+const (
+	processFrameCall    byte = 'C'
+	processFrameMessage byte = 'M'
+	processFrameEnd     byte = 'E'
+	processFrameError   byte = 'X'
+)
+
+const processFrameHeaderSize = 5
+
+// ErrPoolClosed is returned by the calls made after the process pool is closed
+var ErrPoolClosed = errors.New("process pool is closed")
+
+func writeProcessFrame(w io.Writer, frameType byte, payload []byte) error {
+	frame := make([]byte, processFrameHeaderSize+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[processFrameHeaderSize:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readProcessFrame reads the frame, maxSize limits the size of the payload
+func readProcessFrame(r io.Reader, maxSize int) (frameType byte, payload []byte, err error) {
+	header := make([]byte, processFrameHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if maxSize > 0 && int64(size) > int64(maxSize) {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the limit of %d bytes", size, maxSize)
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// processError is the payload of the error frame
+type processError struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+func processErrorStatus(payload []byte) error {
+	processErr := &processError{}
+	if err := json.Unmarshal(payload, processErr); err != nil {
+		return status.Error(codes.Unknown, string(payload))
+	}
+	if processErr.Code == codes.OK {
+		processErr.Code = codes.Unknown
+	}
+	return status.Error(processErr.Code, processErr.Message)
+}
+
+// processWorker is the running worker process, the worker serves one call at a time
+type processWorker struct {
+	id     int
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *bufio.Reader
+	exited chan struct{}
+
+	// overMemory is set when the worker is killed for exceeding max_memory_mb
+	overMemory atomic.Bool
+}
+
+func (w *processWorker) kill() {
+	if w.cmd.Process != nil {
+		_ = w.cmd.Process.Kill()
+	}
+}
+
+func (w *processWorker) alive() bool {
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// ProcessPool keeps the long-lived worker processes of the executable and routes
+// every call to an idle worker. The exited workers are started again after
+// RestartDelay, the worker is killed when the call times out, when the call is
+// abandoned in the middle or when it exceeds MaxMemoryMB. The stderr of the
+// workers is logged.
+type ProcessPool struct {
+	executable     string
+	settings       *config.ProcessPoolSettings
+	maxMessageSize int
+
+	idle   chan *processWorker
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewProcessPool starts settings.Workers processes of the executable
+func NewProcessPool(executable string, settings *config.ProcessPoolSettings, maxMessageSize int) (*ProcessPool, error) {
+	if executable == "" {
+		return nil, fmt.Errorf("%s is not set", config.ExecutablePathKey)
+	}
+	if settings.Workers <= 0 {
+		return nil, fmt.Errorf("%s.workers must be at least 1", config.ProcessPoolKey)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &ProcessPool{
+		executable:     executable,
+		settings:       settings,
+		maxMessageSize: maxMessageSize,
+		// the workers which exited while idle stay in the channel until acquire skips them
+		idle:   make(chan *processWorker, 2*settings.Workers),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for id := range settings.Workers {
+		pool.wg.Add(1)
+		go pool.supervise(id)
+	}
+	return pool, nil
+}
+
+// NewServiceProcessPool creates the pool of executable_path from the config, nil
+// is returned when process_pool.workers is 0 and the executable is started for every call
+func NewServiceProcessPool() (*ProcessPool, error) {
+	settings, err := config.GetProcessPool()
+	if err != nil {
+		return nil, err
+	}
+	if settings.Workers == 0 {
+		return nil, nil
+	}
+	return NewProcessPool(config.GetString(config.ExecutablePathKey), settings,
+		config.GetInt(config.MaxMessageSizeInMB)*1024*1024)
+}
+
+// CallTimeout returns the timeout of the call, serviceTimeout when call_timeout isn't set
+func (pool *ProcessPool) CallTimeout(serviceTimeout time.Duration) time.Duration {
+	if pool.settings.CallTimeout > 0 {
+		return pool.settings.CallTimeout
+	}
+	return serviceTimeout
+}
+
+// Close kills the workers and waits for them to exit
+func (pool *ProcessPool) Close() {
+	pool.cancel()
+	pool.wg.Wait()
+}
+
+// supervise starts the worker and starts it again each time it exits until the pool is closed
+func (pool *ProcessPool) supervise(id int) {
+	defer pool.wg.Done()
+	for {
+		worker, err := pool.start(id)
+		if err != nil {
+			zap.L().Error("can't start worker process", zap.Int("worker", id), zap.String("executable", pool.executable), zap.Error(err))
+		} else {
+			select {
+			case pool.idle <- worker:
+			case <-pool.ctx.Done():
+				worker.kill()
+				<-worker.exited
+				return
+			}
+			select {
+			case <-worker.exited:
+				zap.L().Warn("worker process exited", zap.Int("worker", id), zap.String("state", worker.cmd.ProcessState.String()))
+			case <-pool.ctx.Done():
+				worker.kill()
+				<-worker.exited
+				return
+			}
+		}
+		select {
+		case <-time.After(pool.settings.RestartDelay):
+		case <-pool.ctx.Done():
+			return
+		}
+	}
+}
+
+func (pool *ProcessPool) start(id int) (*processWorker, error) {
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		closeAll(stdinReader, stdinWriter)
+		return nil, err
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		closeAll(stdinReader, stdinWriter, stdoutReader, stdoutWriter)
+		return nil, err
+	}
+
+	cmd := exec.Command(pool.executable)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdinReader, stdoutWriter, stderrWriter
+	err = cmd.Start()
+	// the child has its own copies of the pipe ends
+	closeAll(stdinReader, stdoutWriter, stderrWriter)
+	if err != nil {
+		closeAll(stdinWriter, stdoutReader, stderrReader)
+		return nil, err
+	}
+
+	worker := &processWorker{
+		id:     id,
+		cmd:    cmd,
+		stdin:  stdinWriter,
+		stdout: bufio.NewReader(stdoutReader),
+		exited: make(chan struct{}),
+	}
+	go logProcessStderr(id, stderrReader)
+	go func() {
+		_ = cmd.Wait()
+		closeAll(stdinWriter, stdoutReader)
+		close(worker.exited)
+	}()
+	if pool.settings.MaxMemoryMB > 0 {
+		go pool.watchMemory(worker)
+	}
+	zap.L().Info("worker process started", zap.Int("worker", id), zap.Int("pid", cmd.Process.Pid))
+	return worker, nil
+}
+
+func closeAll(files ...*os.File) {
+	for _, file := range files {
+		_ = file.Close()
+	}
+}
+
+func logProcessStderr(id int, stderr *os.File) {
+	defer stderr.Close()
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		zap.L().Info("worker process stderr", zap.Int("worker", id), zap.String("line", scanner.Text()))
+	}
+}
+
+// watchMemory kills the worker when its resident memory exceeds max_memory_mb
+func (pool *ProcessPool) watchMemory(worker *processWorker) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	limit := int64(pool.settings.MaxMemoryMB) * 1024 * 1024
+	for {
+		select {
+		case <-worker.exited:
+			return
+		case <-ticker.C:
+			rss, err := processRSS(worker.cmd.Process.Pid)
+			if err != nil {
+				zap.L().Warn("can't read memory of worker process, max_memory_mb isn't enforced", zap.Int("worker", worker.id), zap.Error(err))
+				return
+			}
+			if rss > limit {
+				zap.L().Warn("worker process exceeded max_memory_mb and is killed", zap.Int("worker", worker.id),
+					zap.Int64("rss", rss), zap.Int("max_memory_mb", pool.settings.MaxMemoryMB))
+				worker.overMemory.Store(true)
+				worker.kill()
+				return
+			}
+		}
+	}
+}
+
+// processRSS returns the resident memory of the process from /proc
+func processRSS(pid int) (int64, error) {
+	procStatus, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, err
+	}
+	for line := range strings.SplitSeq(string(procStatus), "\n") {
+		if value, ok := strings.CutPrefix(line, "VmRSS:"); ok {
+			kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			return kb * 1024, err
+		}
+	}
+	return 0, errors.New("no VmRSS in process status")
+}
+
+// acquire waits for an idle worker, the workers which exited while idle are skipped
+func (pool *ProcessPool) acquire(ctx context.Context) (*processWorker, error) {
+	for {
+		select {
+		case worker := <-pool.idle:
+			if worker.alive() {
+				return worker, nil
+			}
+		case <-pool.ctx.Done():
+			return nil, ErrPoolClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Call sends the request messages returned by recv to the worker until recv
+// returns io.EOF and calls send with every response message
+func (pool *ProcessPool) Call(ctx context.Context, fullMethod string, recv func() ([]byte, error), send func([]byte) error) error {
+	worker, err := pool.acquire(ctx)
+	if errors.Is(err, ErrPoolClosed) {
+		return errs.ErrServiceUnavailable.New("%v", err)
+	}
+	if err != nil {
+		return errs.ErrNoIdleWorker.New("no idle worker process: %v", err)
+	}
+
+	reusable, err := pool.call(ctx, worker, fullMethod, recv, send)
+	if reusable {
+		select {
+		case pool.idle <- worker:
+			return err
+		default:
+		}
+	}
+	worker.kill()
+	return err
+}
+
+// call runs the call on the worker, reusable is false when the worker is left in the
+// middle of the call and must be restarted
+func (pool *ProcessPool) call(ctx context.Context, worker *processWorker, fullMethod string,
+	recv func() ([]byte, error), send func([]byte) error) (reusable bool, err error) {
+	if err = writeProcessFrame(worker.stdin, processFrameCall, []byte(fullMethod)); err != nil {
+		return false, pool.workerError(ctx, worker, err)
+	}
+
+	// the request stream is forwarded while the response is read
+	forwarded := make(chan struct{})
+	forwardErr := make(chan error, 1)
+	go func() {
+		for {
+			message, err := recv()
+			if errors.Is(err, io.EOF) {
+				if err = writeProcessFrame(worker.stdin, processFrameEnd, nil); err == nil {
+					close(forwarded)
+					return
+				}
+			} else if err == nil {
+				err = writeProcessFrame(worker.stdin, processFrameMessage, message)
+			}
+			if err != nil {
+				forwardErr <- err
+				worker.kill()
+				return
+			}
+		}
+	}()
+
+	// the worker is killed when the call times out or is cancelled, the worker whose
+	// kill has already started isn't returned to the idle pool
+	stopKill := context.AfterFunc(ctx, worker.kill)
+	defer func() {
+		if !stopKill() {
+			reusable = false
+		}
+	}()
+
+	for {
+		frameType, payload, err := readProcessFrame(worker.stdout, pool.maxMessageSize)
+		if err != nil {
+			select {
+			case err = <-forwardErr:
+				return false, errs.ErrReceiveMsg.New("error receiving request; error: %+v", err)
+			default:
+			}
+			return false, pool.workerError(ctx, worker, err)
+		}
+		switch frameType {
+		case processFrameMessage:
+			if err = send(payload); err != nil {
+				return false, errs.ErrSendResponse.New("error sending response; error: %+v", err)
+			}
+		case processFrameEnd, processFrameError:
+			// the forwarder may still be closing the request stream of the finished call,
+			// the worker is reused only after the whole request stream is written
+			select {
+			case <-forwarded:
+				reusable = true
+			case <-forwardErr:
+			case <-ctx.Done():
+			}
+			if frameType == processFrameError {
+				return reusable, processErrorStatus(payload)
+			}
+			return reusable, nil
+		default:
+			return false, errs.ErrWorkerFailed.New("unexpected frame %q from worker process", frameType)
+		}
+	}
+}
+
+// workerError converts the error of the worker pipes to the status
+func (pool *ProcessPool) workerError(ctx context.Context, worker *processWorker, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if worker.overMemory.Load() {
+		return errs.ErrWorkerMemoryExceeded.New("worker process exceeded %s.max_memory_mb", config.ProcessPoolKey)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, os.ErrClosed) {
+		return errs.ErrWorkerExited.New("worker process exited during the call")
+	}
+	return errs.ErrWorkerFailed.New("worker process error: %v", err)
+}
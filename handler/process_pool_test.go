@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const processWorkerEnv = "SNET_TEST_PROCESS_WORKER"
+
+// TestProcessPoolWorker is the worker process started by the pool in the tests
+func TestProcessPoolWorker(t *testing.T) {
+	if os.Getenv(processWorkerEnv) != "1" {
+		t.Skip("run by the process pool tests only")
+	}
+	os.Stderr.WriteString("worker started\n")
+	for {
+		frameType, method, err := readProcessFrame(os.Stdin, 0)
+		if err != nil || frameType != processFrameCall {
+			os.Exit(0)
+		}
+		var messages [][]byte
+		for {
+			frameType, message, err := readProcessFrame(os.Stdin, 0)
+			if err != nil {
+				os.Exit(1)
+			}
+			if frameType == processFrameEnd {
+				break
+			}
+			messages = append(messages, message)
+		}
+		switch string(method) {
+		case "/calc.Calc/echo":
+			for _, message := range messages {
+				_ = writeProcessFrame(os.Stdout, processFrameMessage, message)
+			}
+		case "/calc.Calc/sum":
+			_ = writeProcessFrame(os.Stdout, processFrameMessage, bytes.Join(messages, nil))
+		case "/calc.Calc/pid":
+			_ = writeProcessFrame(os.Stdout, processFrameMessage, []byte(strconv.Itoa(os.Getpid())))
+		case "/calc.Calc/fail":
+			_ = writeProcessFrame(os.Stdout, processFrameError, []byte(`{"code":3,"message":"bad number"}`))
+			continue
+		case "/calc.Calc/crash":
+			os.Exit(2)
+		case "/calc.Calc/sleep":
+			time.Sleep(time.Minute)
+		}
+		_ = writeProcessFrame(os.Stdout, processFrameEnd, nil)
+	}
+}
+
+func newTestProcessPool(t *testing.T) *ProcessPool {
+	executable := filepath.Join(t.TempDir(), "worker.sh")
+	script := "#!/bin/sh\n" + processWorkerEnv + "=1 exec " + os.Args[0] + " -test.run '^TestProcessPoolWorker$'\n"
+	require.Nil(t, os.WriteFile(executable, []byte(script), 0o755))
+
+	pool, err := NewProcessPool(executable, &config.ProcessPoolSettings{Workers: 1, RestartDelay: 10 * time.Millisecond}, 1024)
+	require.Nil(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func callProcessPool(pool *ProcessPool, ctx context.Context, method string, requests ...string) (responses []string, err error) {
+	err = pool.Call(ctx, method, func() ([]byte, error) {
+		if len(requests) == 0 {
+			return nil, io.EOF
+		}
+		request := requests[0]
+		requests = requests[1:]
+		return []byte(request), nil
+	}, func(message []byte) error {
+		responses = append(responses, string(message))
+		return nil
+	})
+	return
+}
+
+func TestProcessFrame(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, writeProcessFrame(buffer, processFrameMessage, []byte("hello")))
+	assert.Equal(t, []byte{'M', 0, 0, 0, 5}, buffer.Bytes()[:processFrameHeaderSize])
+
+	frameType, payload, err := readProcessFrame(bytes.NewReader(buffer.Bytes()), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, processFrameMessage, frameType)
+	assert.Equal(t, "hello", string(payload))
+
+	_, _, err = readProcessFrame(bytes.NewReader(buffer.Bytes()), 4)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(processErrorStatus([]byte(`{"code":3,"message":"bad"}`))))
+	assert.Equal(t, codes.Unknown, status.Code(processErrorStatus([]byte("failed"))))
+}
+
+func TestProcessPool_Call(t *testing.T) {
+	pool := newTestProcessPool(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	responses, err := callProcessPool(pool, ctx, "/calc.Calc/echo", "a", "b")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, responses)
+
+	responses, err = callProcessPool(pool, ctx, "/calc.Calc/sum", "1", "2", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"123"}, responses)
+
+	_, err = callProcessPool(pool, ctx, "/calc.Calc/fail")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "bad number", status.Convert(err).Message())
+
+	// the worker is reused between the calls
+	pid, err := callProcessPool(pool, ctx, "/calc.Calc/pid")
+	assert.Nil(t, err)
+	for range 20 {
+		samePid, err := callProcessPool(pool, ctx, "/calc.Calc/pid", "request")
+		assert.Nil(t, err)
+		assert.Equal(t, pid, samePid)
+	}
+
+	// the crashed worker is restarted
+	_, err = callProcessPool(pool, ctx, "/calc.Calc/crash")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	newPid, err := callProcessPool(pool, ctx, "/calc.Calc/pid")
+	assert.Nil(t, err)
+	assert.NotEqual(t, pid, newPid)
+}
+
+func TestProcessPool_Timeout(t *testing.T) {
+	pool := newTestProcessPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := callProcessPool(pool, ctx, "/calc.Calc/sleep")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	// the call waits for the killed worker to be restarted
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	responses, err := callProcessPool(pool, ctx, "/calc.Calc/echo", "ok")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ok"}, responses)
+
+	// the message exceeding the limit is rejected
+	_, err = callProcessPool(pool, ctx, "/calc.Calc/echo", string(make([]byte, 2048)))
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestProcessPool_CancelledAfterResponse(t *testing.T) {
+	pool := newTestProcessPool(t)
+
+	// the worker killed by the cancellation at the end of the call isn't returned
+	// to the idle pool and the next call waits for the restarted worker
+	for range 5 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := pool.Call(ctx, "/calc.Calc/pid", func() ([]byte, error) {
+			return nil, io.EOF
+		}, func([]byte) error {
+			cancel()
+			return nil
+		})
+		if err != nil {
+			assert.Equal(t, codes.Canceled, status.Code(err))
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = callProcessPool(pool, ctx, "/calc.Calc/echo", "ok")
+		cancel()
+		assert.Nil(t, err)
+	}
+}
+
+func TestGrpcHandler_grpcToProcessPoolCallTimeout(t *testing.T) {
+	pool := newTestProcessPool(t)
+	pool.settings.CallTimeout = 200 * time.Millisecond
+	g := &grpcHandler{processes: pool, timeout: time.Minute}
+
+	// call_timeout applies even if the deadline of the client is later
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stream := &httpTestStream{serverStreamMock: serverStreamMock{
+		context: grpc.NewContextWithServerTransportStream(ctx, &methodTransportStream{method: "/calc.Calc/sleep"}),
+	}}
+	start := time.Now()
+	err := g.grpcToProcessPool(nil, stream)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/bufbuild/protocompile/linker"
 	"go.uber.org/zap"
@@ -78,3 +79,18 @@ func findMethodInProto(protoFiles linker.Files, methodName string) (method proto
 	}
 	return nil
 }
+
+// findFullMethodInProto returns the method of the full name /package.Service/Method,
+// nil when the proto files have no such method
+func findFullMethodInProto(protoFiles linker.Files, fullMethod string) protoreflect.MethodDescriptor {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil
+	}
+	for _, protoFile := range protoFiles {
+		if service, ok := protoFile.FindDescriptorByName(protoreflect.FullName(serviceName)).(protoreflect.ServiceDescriptor); ok {
+			return service.Methods().ByName(protoreflect.Name(methodName))
+		}
+	}
+	return nil
+}
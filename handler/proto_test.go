@@ -10,12 +10,20 @@ import (
 	"github.com/bufbuild/protocompile/linker"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 // compileProtoFiles
 func getDescriptors(t *testing.T, protoFiles map[string]string) linker.Files {
 	accessor := protocompile.SourceAccessorFromMap(protoFiles)
-	r := protocompile.WithStandardImports(&protocompile.SourceResolver{Accessor: accessor})
+	r := protocompile.WithStandardImports(protocompile.CompositeResolver{
+		&protocompile.SourceResolver{Accessor: accessor},
+		protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+			// google/api/annotations.proto is registered by the genproto package
+			file, err := protoregistry.GlobalFiles.FindFileByPath(path)
+			return protocompile.SearchResult{Desc: file}, err
+		}),
+	})
 	compiler := protocompile.Compiler{
 		Resolver:       r,
 		SourceInfoMode: protocompile.SourceInfoStandard,
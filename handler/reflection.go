@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ReflectionMethods are the streaming methods of the reflection, they are free
+// and must skip the payment interceptors
+var ReflectionMethods = []string{
+	grpc_reflection_v1.ServerReflection_ServerReflectionInfo_FullMethodName,
+	grpc_reflection_v1alpha.ServerReflection_ServerReflectionInfo_FullMethodName,
+}
+
+// RegisterReflection registers the gRPC server reflection (v1 and v1alpha) which
+// lists the services of the daemon and of the proxied service. The descriptors
+// of the service are taken from serviceMetadata on every request, so the
+// reflection follows the reloaded metadata.
+func RegisterReflection(server *grpc.Server, serviceMetadata func() *blockchain.ServiceMetadata) {
+	options := reflection.ServerOptions{
+		Services:           &reflectionServices{server: server, serviceMetadata: serviceMetadata},
+		DescriptorResolver: &reflectionResolver{serviceMetadata: serviceMetadata},
+	}
+	grpc_reflection_v1.RegisterServerReflectionServer(server, reflection.NewServerV1(options))
+	grpc_reflection_v1alpha.RegisterServerReflectionServer(server, reflection.NewServer(options))
+}
+
+// reflectionServices lists the services registered on the server and the services of the metadata
+type reflectionServices struct {
+	server          *grpc.Server
+	serviceMetadata func() *blockchain.ServiceMetadata
+}
+
+func (s *reflectionServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	services := s.server.GetServiceInfo()
+	for _, protoFile := range s.serviceMetadata().ProtoDescriptors {
+		for i := range protoFile.Services().Len() {
+			service := protoFile.Services().Get(i)
+			info := grpc.ServiceInfo{Metadata: protoFile.Path()}
+			for j := range service.Methods().Len() {
+				method := service.Methods().Get(j)
+				info.Methods = append(info.Methods, grpc.MethodInfo{
+					Name:           string(method.Name()),
+					IsClientStream: method.IsStreamingClient(),
+					IsServerStream: method.IsStreamingServer(),
+				})
+			}
+			services[string(service.FullName())] = info
+		}
+	}
+	return services
+}
+
+// reflectionResolver finds the descriptors in the proto files of the service
+// first and then in the files of the daemon
+type reflectionResolver struct {
+	serviceMetadata func() *blockchain.ServiceMetadata
+}
+
+func (r *reflectionResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, protoFile := range r.serviceMetadata().ProtoDescriptors {
+		if protoFile.Path() == path {
+			return protoFile, nil
+		}
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *reflectionResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, protoFile := range r.serviceMetadata().ProtoDescriptors {
+		if descriptor := protoFile.FindDescriptorByName(name); descriptor != nil {
+			return descriptor, nil
+		}
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRegisterReflection(t *testing.T) {
+	serviceMetadata := &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"items.proto": httpRulesTestProto})}
+	current := serviceMetadata
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	RegisterReflection(server, func() *blockchain.ServiceMetadata { return current })
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///reflection-test",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.Nil(t, err)
+	request := func(req *grpc_reflection_v1.ServerReflectionRequest) *grpc_reflection_v1.ServerReflectionResponse {
+		require.Nil(t, stream.Send(req))
+		resp, err := stream.Recv()
+		require.Nil(t, err)
+		return resp
+	}
+	listServices := func() (names []string) {
+		resp := request(&grpc_reflection_v1.ServerReflectionRequest{
+			MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{}})
+		for _, service := range resp.GetListServicesResponse().GetService() {
+			names = append(names, service.Name)
+		}
+		return
+	}
+
+	// the services of the daemon and of the metadata are listed
+	services := listServices()
+	assert.Contains(t, services, "grpc.health.v1.Health")
+	assert.Contains(t, services, "items.Items")
+	assert.Contains(t, services, "grpc.reflection.v1.ServerReflection")
+
+	resp := request(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "items.Items"}})
+	files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+	require.NotEmpty(t, files)
+	var names []string
+	for _, file := range files {
+		fileProto := &descriptorpb.FileDescriptorProto{}
+		require.Nil(t, proto.Unmarshal(file, fileProto))
+		names = append(names, fileProto.GetName())
+	}
+	// the imports of the service files are resolved from the files of the daemon
+	assert.Contains(t, names, "items.proto")
+	assert.Contains(t, names, "google/api/annotations.proto")
+
+	resp = request(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_FileByFilename{FileByFilename: "items.proto"}})
+	assert.NotEmpty(t, resp.GetFileDescriptorResponse().GetFileDescriptorProto())
+
+	// the reflection follows the replaced metadata
+	current = &blockchain.ServiceMetadata{}
+	assert.NotContains(t, listServices(), "items.Items")
+	resp = request(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "items.Items"}})
+	assert.NotNil(t, resp.GetErrorResponse())
+}
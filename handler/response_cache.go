@@ -0,0 +1,484 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/ctxkeys"
+	"github.com/singnet/snet-daemon/v6/metrics"
+	"github.com/singnet/snet-daemon/v6/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ResponseCacheHeader is set in the trailer of the calls served from the response cache
+const ResponseCacheHeader = "snet-response-cache"
+
+// cachedResponse is the response of the unary call with its header and trailer
+type cachedResponse struct {
+	Key     string      `json:"key"`
+	Data    []byte      `json:"data"`
+	Header  metadata.MD `json:"header,omitempty"`
+	Trailer metadata.MD `json:"trailer,omitempty"`
+	Expires time.Time   `json:"expires"`
+}
+
+func (response *cachedResponse) size() int {
+	size := len(response.Key) + len(response.Data)
+	for _, md := range []metadata.MD{response.Header, response.Trailer} {
+		for key, values := range md {
+			for _, value := range values {
+				size += len(key) + len(value)
+			}
+		}
+	}
+	return size
+}
+
+// responseCacheBackend keeps the cached responses, the expired responses are never returned
+type responseCacheBackend interface {
+	get(key string, now time.Time) (*cachedResponse, error)
+	put(response *cachedResponse) error
+	stats(stats *metrics.ResponseCacheStats)
+	close()
+}
+
+// ResponseCache serves the repeated calls of the configured unary methods without
+// calling the service. The key of the response is the hash of the method, the
+// training model id and the request bytes.
+type ResponseCache struct {
+	settings        *config.ResponseCacheSettings
+	serviceMetaData *blockchain.ServiceMetadata
+	backend         responseCacheBackend
+	now             func() time.Time
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	stores atomic.Uint64
+}
+
+// NewResponseCache returns the cache of the responses, atomicStorage is used by the storage backend only
+func NewResponseCache(settings *config.ResponseCacheSettings, serviceMetadata *blockchain.ServiceMetadata, atomicStorage storage.AtomicStorage) *ResponseCache {
+	cache := &ResponseCache{
+		settings:        settings,
+		serviceMetaData: serviceMetadata,
+		now:             time.Now,
+	}
+	if settings.Backend == config.StorageCacheBackend {
+		cache.backend = newStorageResponseCache(atomicStorage, settings.MaxEntries, settings.MaxBytes, settings.CleanupInterval)
+	} else {
+		cache.backend = newMemoryResponseCache(settings.MaxEntries, settings.MaxBytes)
+	}
+	return cache
+}
+
+// NewServiceResponseCache returns the cache configured by response_cache, nil when the cache is disabled
+func NewServiceResponseCache(serviceMetadata *blockchain.ServiceMetadata, atomicStorage func() storage.AtomicStorage) (*ResponseCache, error) {
+	settings, err := config.GetResponseCache()
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled || len(settings.Methods) == 0 {
+		return nil, nil
+	}
+	var shared storage.AtomicStorage
+	if settings.Backend == config.StorageCacheBackend {
+		shared = storage.NewPrefixedAtomicStorage(atomicStorage(), "/response-cache")
+	}
+	return NewResponseCache(settings, serviceMetadata, shared), nil
+}
+
+// Close stops the cleanup of the expired responses
+func (cache *ResponseCache) Close() {
+	cache.backend.close()
+}
+
+// Stats returns the counters of the cache for the heartbeat
+func (cache *ResponseCache) Stats() *metrics.ResponseCacheStats {
+	stats := &metrics.ResponseCacheStats{
+		Backend: cache.settings.Backend,
+		Hits:    cache.hits.Load(),
+		Misses:  cache.misses.Load(),
+		Stores:  cache.stores.Load(),
+	}
+	cache.backend.stats(stats)
+	return stats
+}
+
+// cacheable returns the settings of the method, nil when the method isn't cached
+// or the proto files declare it as streaming
+func (cache *ResponseCache) cacheable(fullMethod string) *config.ResponseCacheMethod {
+	method := cache.settings.ResponseCacheMethod(fullMethod)
+	if method == nil {
+		return nil
+	}
+	if descriptor := findFullMethodInProto(cache.serviceMetaData.ProtoDescriptors, fullMethod); descriptor != nil &&
+		(descriptor.IsStreamingClient() || descriptor.IsStreamingServer()) {
+		return nil
+	}
+	return method
+}
+
+// responseCacheKey returns the hash of the method, the model id and the request
+func responseCacheKey(fullMethod, modelID string, request []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(fullMethod))
+	hash.Write([]byte{0})
+	hash.Write([]byte(modelID))
+	hash.Write([]byte{0})
+	hash.Write(request)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseCacheLookup is the result of the lookup stored in the context of the
+// call, response is nil on the cache miss
+type responseCacheLookup struct {
+	key      string
+	ttl      time.Duration
+	response *cachedResponse
+}
+
+// IsResponseCacheHit returns true if the call is served from the response cache
+func IsResponseCacheHit(ctx context.Context) bool {
+	lookup, ok := ctx.Value(ctxkeys.ResponseCacheKey).(*responseCacheLookup)
+	return ok && lookup.response != nil
+}
+
+// GrpcResponseCacheLookupInterceptor looks the request of the cached methods up in
+// the cache. It must precede the payment validation, so the pricing knows the call
+// is served from the cache (see IsResponseCacheHit).
+func GrpcResponseCacheLookupInterceptor(cache *ResponseCache) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method := cache.cacheable(info.FullMethod)
+		if method == nil {
+			return handler(srv, ss)
+		}
+		first := &codec.GrpcFrame{}
+		if err := ss.RecvMsg(first); err != nil {
+			return err
+		}
+
+		var modelID string
+		if md, ok := metadata.FromIncomingContext(ss.Context()); ok && len(md.Get(TrainingModelId)) > 0 {
+			modelID = md.Get(TrainingModelId)[0]
+			// the versions of the model respond differently
+			if version := md.Get(TrainingModelVersion); len(version) > 0 && version[0] != "" {
+				modelID += "@" + version[0]
+			}
+		}
+		lookup := &responseCacheLookup{key: responseCacheKey(info.FullMethod, modelID, first.Data), ttl: method.TTL}
+		if lookup.ttl == 0 {
+			lookup.ttl = cache.settings.DefaultTTL
+		}
+		response, err := cache.backend.get(lookup.key, cache.now())
+		if err != nil {
+			zap.L().Warn("can't get the cached response", zap.String("method", info.FullMethod), zap.Error(err))
+		}
+		if response != nil {
+			cache.hits.Add(1)
+			lookup.response = response
+		} else {
+			cache.misses.Add(1)
+		}
+		return handler(srv, &cacheServerStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), ctxkeys.ResponseCacheKey, lookup),
+			first:        first,
+			firstPending: true,
+		})
+	}
+}
+
+// GrpcResponseCacheInterceptor serves the calls found by GrpcResponseCacheLookupInterceptor
+// from the cache and caches the successful responses of the other calls. It must follow
+// the payment validation, so the calls served from the cache are paid.
+func GrpcResponseCacheInterceptor(cache *ResponseCache) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		lookup, ok := ss.Context().Value(ctxkeys.ResponseCacheKey).(*responseCacheLookup)
+		if !ok {
+			return handler(srv, ss)
+		}
+		if response := lookup.response; response != nil {
+			trailer := response.Trailer.Copy()
+			trailer.Set(ResponseCacheHeader, "hit")
+			ss.SetTrailer(trailer)
+			if err := ss.SendHeader(response.Header.Copy()); err != nil {
+				return err
+			}
+			return ss.SendMsg(&codec.GrpcFrame{Data: response.Data})
+		}
+
+		recorder := &responseRecorderStream{ServerStream: ss, header: metadata.MD{}, trailer: metadata.MD{}}
+		if err := handler(srv, recorder); err != nil {
+			return err
+		}
+		if len(recorder.responses) != 1 {
+			return nil
+		}
+		response := &cachedResponse{
+			Key:     lookup.key,
+			Data:    recorder.responses[0],
+			Header:  recorder.header,
+			Trailer: recorder.trailer,
+			Expires: cache.now().Add(lookup.ttl),
+		}
+		if cache.settings.MaxEntryBytes > 0 && response.size() > cache.settings.MaxEntryBytes {
+			return nil
+		}
+		if err := cache.backend.put(response); err != nil {
+			zap.L().Warn("can't cache the response", zap.String("method", info.FullMethod), zap.Error(err))
+			return nil
+		}
+		cache.stores.Add(1)
+		return nil
+	}
+}
+
+// cacheServerStream replays the request read by the lookup and returns the context with the lookup
+type cacheServerStream struct {
+	grpc.ServerStream
+	ctx          context.Context
+	first        *codec.GrpcFrame
+	firstPending bool
+}
+
+func (s *cacheServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *cacheServerStream) RecvMsg(m any) error {
+	if s.firstPending {
+		s.firstPending = false
+		frame, ok := m.(*codec.GrpcFrame)
+		if !ok {
+			return fmt.Errorf("cacheServerStream: unexpected message type %T, want *codec.GrpcFrame", m)
+		}
+		*frame = *s.first
+		return nil
+	}
+	return s.ServerStream.RecvMsg(m)
+}
+
+// responseRecorderStream records the responses, the header and the trailer sent by the service
+type responseRecorderStream struct {
+	grpc.ServerStream
+	header    metadata.MD
+	trailer   metadata.MD
+	responses [][]byte
+}
+
+func (s *responseRecorderStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return s.ServerStream.SetHeader(md)
+}
+
+func (s *responseRecorderStream) SendHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return s.ServerStream.SendHeader(md)
+}
+
+func (s *responseRecorderStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+	s.ServerStream.SetTrailer(md)
+}
+
+func (s *responseRecorderStream) SendMsg(m any) error {
+	if frame, ok := m.(*codec.GrpcFrame); ok {
+		s.responses = append(s.responses, frame.Data)
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// memoryResponseCache is the LRU cache bounded by the number of responses and their size
+type memoryResponseCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	evictions  uint64
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+func newMemoryResponseCache(maxEntries, maxBytes int) *memoryResponseCache {
+	return &memoryResponseCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (cache *memoryResponseCache) get(key string, now time.Time) (*cachedResponse, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	response := element.Value.(*cachedResponse)
+	if !now.Before(response.Expires) {
+		cache.remove(element)
+		return nil, nil
+	}
+	cache.order.MoveToFront(element)
+	return response, nil
+}
+
+func (cache *memoryResponseCache) put(response *cachedResponse) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[response.Key]; ok {
+		cache.remove(element)
+	}
+	cache.entries[response.Key] = cache.order.PushFront(response)
+	cache.bytes += response.size()
+	for (cache.maxEntries > 0 && cache.order.Len() > cache.maxEntries) || (cache.maxBytes > 0 && cache.bytes > cache.maxBytes) {
+		cache.remove(cache.order.Back())
+		cache.evictions++
+	}
+	return nil
+}
+
+func (cache *memoryResponseCache) remove(element *list.Element) {
+	response := cache.order.Remove(element).(*cachedResponse)
+	delete(cache.entries, response.Key)
+	cache.bytes -= response.size()
+}
+
+func (cache *memoryResponseCache) stats(stats *metrics.ResponseCacheStats) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	stats.Evictions = cache.evictions
+	stats.Entries = cache.order.Len()
+	stats.Bytes = cache.bytes
+}
+
+func (cache *memoryResponseCache) close() {
+}
+
+// defaultCleanupInterval is used by the storage cache when the cleanup interval isn't set
+const defaultCleanupInterval = time.Minute
+
+// storageResponseCache keeps the responses in the storage shared by the daemons of
+// the group. The expired responses are removed periodically, then the responses
+// expiring first are evicted while the cache exceeds maxEntries or maxBytes.
+type storageResponseCache struct {
+	storage    storage.AtomicStorage
+	maxEntries int
+	maxBytes   int
+	entries    atomic.Int64
+	bytes      atomic.Int64
+	evictions  atomic.Uint64
+	stop       chan struct{}
+	closeOnce  sync.Once
+}
+
+func newStorageResponseCache(atomicStorage storage.AtomicStorage, maxEntries, maxBytes int, cleanupInterval time.Duration) *storageResponseCache {
+	cache := &storageResponseCache{storage: atomicStorage, maxEntries: maxEntries, maxBytes: maxBytes, stop: make(chan struct{})}
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultCleanupInterval
+	}
+	go cache.cleanup(cleanupInterval)
+	return cache
+}
+
+func (cache *storageResponseCache) get(key string, now time.Time) (*cachedResponse, error) {
+	value, ok, err := cache.storage.Get(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	response := &cachedResponse{}
+	if err = json.Unmarshal([]byte(value), response); err != nil {
+		return nil, err
+	}
+	if !now.Before(response.Expires) {
+		return nil, nil
+	}
+	return response, nil
+}
+
+func (cache *storageResponseCache) put(response *cachedResponse) error {
+	value, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return cache.storage.Put(response.Key, string(value))
+}
+
+// removeExpired deletes the responses expired at now and evicts the responses
+// expiring first while the cache exceeds its bounds
+func (cache *storageResponseCache) removeExpired(now time.Time) {
+	values, err := cache.storage.GetByKeyPrefix("")
+	if err != nil {
+		zap.L().Warn("can't list the cached responses", zap.Error(err))
+		return
+	}
+	live := make([]*cachedResponse, 0, len(values))
+	bytes := 0
+	for _, value := range values {
+		response := &cachedResponse{}
+		if err = json.Unmarshal([]byte(value), response); err != nil {
+			continue
+		}
+		if now.Before(response.Expires) {
+			live = append(live, response)
+			bytes += response.size()
+			continue
+		}
+		cache.delete(response)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Expires.Before(live[j].Expires) })
+	for len(live) > 0 && ((cache.maxEntries > 0 && len(live) > cache.maxEntries) || (cache.maxBytes > 0 && bytes > cache.maxBytes)) {
+		if cache.delete(live[0]) {
+			bytes -= live[0].size()
+		}
+		live = live[1:]
+	}
+	cache.entries.Store(int64(len(live)))
+	cache.bytes.Store(int64(bytes))
+}
+
+func (cache *storageResponseCache) delete(response *cachedResponse) bool {
+	if err := cache.storage.Delete(response.Key); err != nil {
+		zap.L().Warn("can't delete the cached response", zap.Error(err))
+		return false
+	}
+	cache.evictions.Add(1)
+	return true
+}
+
+func (cache *storageResponseCache) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cache.stop:
+			return
+		case now := <-ticker.C:
+			cache.removeExpired(now)
+		}
+	}
+}
+
+func (cache *storageResponseCache) stats(stats *metrics.ResponseCacheStats) {
+	stats.Evictions = cache.evictions.Load()
+	stats.Entries = int(cache.entries.Load())
+	stats.Bytes = int(cache.bytes.Load())
+}
+
+func (cache *storageResponseCache) close() {
+	cache.closeOnce.Do(func() { close(cache.stop) })
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// cacheTestStream is the unary call of the client recording the response
+type cacheTestStream struct {
+	validationTestStream
+	trailer metadata.MD
+}
+
+func (m *cacheTestStream) SetTrailer(md metadata.MD) {
+	m.trailer = metadata.Join(m.trailer, md)
+}
+
+func newTestResponseCache(t *testing.T, backend string, atomicStorage storage.AtomicStorage) *ResponseCache {
+	hitPrice := uint64(1)
+	settings := &config.ResponseCacheSettings{
+		Enabled:       true,
+		Backend:       backend,
+		MaxEntries:    2,
+		MaxEntryBytes: 1024,
+		DefaultTTL:    time.Minute,
+		Methods: []config.ResponseCacheMethod{
+			{Method: "/items.Items/Get", TTL: time.Hour, HitPriceInCogs: &hitPrice},
+			{Method: "/items.Items/Count"},
+			{Method: "/items.Items/Update"},
+		},
+	}
+	serviceMetadata := &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"items.proto": httpRulesTestProto})}
+	cache := NewResponseCache(settings, serviceMetadata, atomicStorage)
+	t.Cleanup(cache.Close)
+	return cache
+}
+
+// callResponseCache calls the method through the cache interceptors, calls counts the calls of the service
+func callResponseCache(t *testing.T, cache *ResponseCache, method string, md metadata.MD, request string, calls *int) (*cacheTestStream, error) {
+	stream := &cacheTestStream{validationTestStream: validationTestStream{
+		serverStreamMock: serverStreamMock{context: metadata.NewIncomingContext(context.Background(), md)},
+		requests:         [][]byte{[]byte(request)},
+	}}
+	info := &grpc.StreamServerInfo{FullMethod: method}
+	service := func(srv any, ss grpc.ServerStream) error {
+		*calls++
+		frame := &codec.GrpcFrame{}
+		require.Nil(t, ss.RecvMsg(frame))
+		if string(frame.Data) == "fail" {
+			return status.Errorf(codes.Unavailable, "service is down")
+		}
+		ss.SetTrailer(metadata.Pairs("x-request", string(frame.Data)))
+		return ss.SendMsg(&codec.GrpcFrame{Data: append([]byte("response "), frame.Data...)})
+	}
+	err := GrpcResponseCacheLookupInterceptor(cache)(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+		return GrpcResponseCacheInterceptor(cache)(srv, ss, info, service)
+	})
+	return stream, err
+}
+
+func TestResponseCache_Memory(t *testing.T) {
+	cache := newTestResponseCache(t, config.MemoryCacheBackend, nil)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	calls := 0
+
+	stream, err := callResponseCache(t, cache, "/items.Items/Get", nil, "a", &calls)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("response a")}, stream.sent)
+	assert.Empty(t, stream.trailer.Get(ResponseCacheHeader))
+
+	// the same request is served from the cache with the trailer of the service
+	stream, err = callResponseCache(t, cache, "/items.Items/Get", nil, "a", &calls)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, [][]byte{[]byte("response a")}, stream.sent)
+	assert.Equal(t, []string{"hit"}, stream.trailer.Get(ResponseCacheHeader))
+	assert.Equal(t, []string{"a"}, stream.trailer.Get("x-request"))
+
+	// the model id is a part of the key
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", metadata.Pairs(TrainingModelId, "1"), "a", &calls)
+	assert.Equal(t, 2, calls)
+
+	// the errors aren't cached
+	_, err = callResponseCache(t, cache, "/items.Items/Get", nil, "fail", &calls)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", nil, "fail", &calls)
+	assert.Equal(t, 4, calls)
+
+	// the streaming and the not configured methods aren't cached
+	_, _ = callResponseCache(t, cache, "/items.Items/Count", nil, "a", &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Count", nil, "a", &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Sum", nil, "a", &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Sum", nil, "a", &calls)
+	assert.Equal(t, 8, calls)
+
+	// the least recently used response is evicted
+	_, _ = callResponseCache(t, cache, "/items.Items/Update", nil, "b", &calls)
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(3), stats.Stores)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", nil, "a", &calls)
+	assert.Equal(t, 10, calls)
+
+	// the method ttl overrides the default one
+	_, _ = callResponseCache(t, cache, "/items.Items/Update", nil, "b", &calls)
+	assert.Equal(t, 10, calls)
+	now = now.Add(2 * time.Minute)
+	_, _ = callResponseCache(t, cache, "/items.Items/Update", nil, "b", &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", nil, "a", &calls)
+	assert.Equal(t, 11, calls)
+
+	// the responses larger than max_entry_bytes aren't cached
+	large := string(make([]byte, 2048))
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", nil, large, &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", nil, large, &calls)
+	assert.Equal(t, 13, calls)
+}
+
+func TestResponseCache_Storage(t *testing.T) {
+	atomicStorage := storage.NewMemStorage()
+	cache := newTestResponseCache(t, config.StorageCacheBackend, atomicStorage)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	calls := 0
+
+	_, _ = callResponseCache(t, cache, "/items.Items/Get", nil, "a", &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Update", nil, "b", &calls)
+
+	// the response cached by another daemon of the group is served
+	other := newTestResponseCache(t, config.StorageCacheBackend, atomicStorage)
+	other.now = cache.now
+	stream, err := callResponseCache(t, other, "/items.Items/Get", nil, "a", &calls)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, [][]byte{[]byte("response a")}, stream.sent)
+	assert.Equal(t, []string{"hit"}, stream.trailer.Get(ResponseCacheHeader))
+
+	// the expired responses are removed
+	cache.backend.(*storageResponseCache).removeExpired(now.Add(2 * time.Minute))
+	values, err := atomicStorage.GetByKeyPrefix("")
+	assert.Nil(t, err)
+	assert.Len(t, values, 1)
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+
+	// the responses expiring first are evicted beyond max_entries
+	_, _ = callResponseCache(t, cache, "/items.Items/Update", nil, "c", &calls)
+	_, _ = callResponseCache(t, cache, "/items.Items/Update", nil, "d", &calls)
+	cache.backend.(*storageResponseCache).removeExpired(now)
+	values, err = atomicStorage.GetByKeyPrefix("")
+	assert.Nil(t, err)
+	assert.Len(t, values, 2)
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+	_, _ = callResponseCache(t, other, "/items.Items/Get", nil, "a", &calls)
+	assert.Equal(t, 4, calls)
+}
+
+func TestResponseCache_CacheableFullMethod(t *testing.T) {
+	cache := newTestResponseCache(t, config.MemoryCacheBackend, nil)
+	cache.serviceMetaData = &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{
+		"items.proto": httpRulesTestProto,
+		"other.proto": `syntax = "proto3";
+package other;
+message Request {}
+service Items {
+	rpc Count(Request) returns (Request);
+}`,
+	})}
+	cache.settings.Methods = append(cache.settings.Methods, config.ResponseCacheMethod{Method: "/other.Items/Count"})
+
+	// the streaming method of the same name in another service doesn't matter
+	assert.Nil(t, cache.cacheable("/items.Items/Count"))
+	assert.NotNil(t, cache.cacheable("/other.Items/Count"))
+	assert.NotNil(t, cache.cacheable("/items.Items/Get"))
+	assert.Nil(t, cache.cacheable("/items.Items/Sum"))
+}
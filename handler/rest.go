@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bufbuild/protocompile/linker"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// RESTPathPrefix is the prefix of the REST ingress paths: POST /v1/{service}/{method}
+const RESTPathPrefix = "/v1/"
+
+// restHandler transcodes the JSON requests to the gRPC methods of the service
+// and calls them through the gRPC server of the daemon, so the calls pass the
+// same payment interceptors as the gRPC calls. The snet-* HTTP headers are sent
+// as the gRPC metadata, the values of the -bin headers are base64 encoded.
+type restHandler struct {
+	serviceMetaData *blockchain.ServiceMetadata
+	conn            grpc.ClientConnInterface
+	maxMessageSize  int64
+}
+
+// NewRESTHandler returns the REST ingress calling the methods via conn
+func NewRESTHandler(serviceMetadata *blockchain.ServiceMetadata, conn grpc.ClientConnInterface, maxMessageSize int) http.Handler {
+	return &restHandler{
+		serviceMetaData: serviceMetadata,
+		conn:            conn,
+		maxMessageSize:  int64(maxMessageSize),
+	}
+}
+
+func (h *restHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		resp.Header().Set("Allow", http.MethodPost)
+		writeRESTError(resp, status.New(codes.Unimplemented, "only POST is supported"), http.StatusMethodNotAllowed)
+		return
+	}
+
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(req.URL.Path, RESTPathPrefix), "/")
+	method := FindServiceMethod(h.serviceMetaData.ProtoDescriptors, serviceName, methodName)
+	if !ok || method == nil {
+		writeRESTError(resp, status.Newf(codes.NotFound, "method %v/%v not found", serviceName, methodName), 0)
+		return
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		writeRESTError(resp, status.New(codes.Unimplemented, "streaming methods aren't supported by the REST ingress, use gRPC"), 0)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(resp, req.Body, h.maxMessageSize))
+	if err != nil {
+		writeRESTError(resp, status.Newf(codes.InvalidArgument, "error reading request; error: %v", err), 0)
+		return
+	}
+	request, err := h.requestFrame(method, body)
+	if err != nil {
+		writeRESTError(resp, status.New(codes.InvalidArgument, err.Error()), 0)
+		return
+	}
+	md, err := restMetadata(req.Header)
+	if err != nil {
+		writeRESTError(resp, status.New(codes.InvalidArgument, err.Error()), 0)
+		return
+	}
+
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	var header, trailer metadata.MD
+	response := &codec.GrpcFrame{}
+	err = h.conn.Invoke(metadata.NewOutgoingContext(req.Context(), md), fullMethod, &codec.GrpcFrame{Data: request}, response,
+		grpc.Header(&header), grpc.Trailer(&trailer))
+	setRESTHeaders(resp, metadata.Join(header, trailer))
+	if err != nil {
+		writeRESTError(resp, status.Convert(err), 0)
+		return
+	}
+
+	responseJSON, err := h.responseJSON(method, response.Data)
+	if err != nil {
+		writeRESTError(resp, status.Newf(codes.Internal, "error converting response; error: %v", err), 0)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	_, _ = resp.Write(responseJSON)
+}
+
+// requestFrame converts the JSON body to the request of the service wire encoding
+func (h *restHandler) requestFrame(method protoreflect.MethodDescriptor, body []byte) ([]byte, error) {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		body = []byte("{}")
+	}
+	if h.serviceMetaData.GetWireEncoding() == "json" {
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("request is not a valid JSON")
+		}
+		return body, nil
+	}
+	request := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal(body, request); err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
+	}
+	return proto.Marshal(request)
+}
+
+// responseJSON converts the response of the service wire encoding to JSON
+func (h *restHandler) responseJSON(method protoreflect.MethodDescriptor, data []byte) ([]byte, error) {
+	if h.serviceMetaData.GetWireEncoding() == "json" {
+		return data, nil
+	}
+	response := dynamicpb.NewMessage(method.Output())
+	if err := proto.Unmarshal(data, response); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(response)
+}
+
+// FindServiceMethod returns the method of the service with the full or short name
+func FindServiceMethod(protoFiles linker.Files, serviceName, methodName string) protoreflect.MethodDescriptor {
+	for _, protoFile := range protoFiles {
+		services := protoFile.Services()
+		for j := 0; j < services.Len(); j++ {
+			service := services.Get(j)
+			if string(service.FullName()) != serviceName && string(service.Name()) != serviceName {
+				continue
+			}
+			if method := service.Methods().ByName(protoreflect.Name(methodName)); method != nil {
+				return method
+			}
+		}
+	}
+	return nil
+}
+
+// restMetadata converts the snet-* headers of the REST request to the gRPC metadata
+func restMetadata(header http.Header) (metadata.MD, error) {
+	md := metadata.MD{}
+	for key, values := range header {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, "snet-") {
+			continue
+		}
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				decoded, err := decodeBinaryHeader(value)
+				if err != nil {
+					return nil, fmt.Errorf("header %v is not base64 encoded: %v", key, err)
+				}
+				value = string(decoded)
+			}
+			md.Append(key, value)
+		}
+	}
+	return md, nil
+}
+
+func decodeBinaryHeader(value string) ([]byte, error) {
+	if len(value)%4 == 0 {
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// setRESTHeaders sets the headers and trailers of the gRPC response as the HTTP headers
+func setRESTHeaders(resp http.ResponseWriter, md metadata.MD) {
+	for key, values := range md {
+		if skippedTrailerHeaders[key] || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			resp.Header().Add(key, value)
+		}
+	}
+}
+
+// writeRESTError writes the status as the JSON {"code": 3, "message": "...", "details": [...]},
+// the HTTP status is derived from the code when httpStatus is 0
+func writeRESTError(resp http.ResponseWriter, st *status.Status, httpStatus int) {
+	if httpStatus == 0 {
+		httpStatus = codeToHTTPStatus(st.Code())
+	}
+	body, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		// the details of the unknown types can't be converted
+		zap.L().Debug("can't convert status details", zap.Error(err))
+		body, _ = json.Marshal(map[string]any{"code": st.Code(), "message": st.Message()})
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(httpStatus)
+	_, _ = resp.Write(body)
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// newRESTTestHandler returns the REST ingress calling the gRPC server which
+// requires the escrow payment type and the signature, the service returns the
+// item with the id of the request
+func newRESTTestHandler(t *testing.T) http.Handler {
+	serviceMetadata := &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"items.proto": httpRulesTestProto})}
+	method := FindServiceMethod(serviceMetadata.ProtoDescriptors, "items.Items", "Get")
+	require.NotNil(t, method)
+
+	paymentInterceptor := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		if len(md.Get(PaymentTypeHeader)) == 0 || md.Get(PaymentTypeHeader)[0] != "escrow" {
+			return status.Errorf(codes.InvalidArgument, "unexpected payment type")
+		}
+		if len(md.Get(PaymentChannelSignatureHeader)) == 0 || md.Get(PaymentChannelSignatureHeader)[0] != "\x01\x02\x03" {
+			return status.Errorf(codes.Unauthenticated, "invalid signature")
+		}
+		return handler(srv, ss)
+	}
+	service := func(srv any, stream grpc.ServerStream) error {
+		f := &codec.GrpcFrame{}
+		if err := stream.RecvMsg(f); err != nil {
+			return err
+		}
+		request := dynamicpb.NewMessage(method.Input())
+		if err := proto.Unmarshal(f.Data, request); err != nil {
+			return err
+		}
+		id := request.Get(method.Input().Fields().ByName("id")).Int()
+		if id == 0 {
+			return status.Errorf(codes.NotFound, "item not found")
+		}
+		stream.SetTrailer(metadata.Pairs("x-price", "10"))
+		item := dynamicpb.NewMessage(method.Output())
+		item.Set(method.Output().Fields().ByName("id"), request.Get(method.Input().Fields().ByName("id")))
+		item.Set(method.Output().Fields().ByName("name"), request.Get(method.Input().Fields().ByName("shelf")))
+		data, err := proto.Marshal(item)
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(&codec.GrpcFrame{Data: data})
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(service), grpc.StreamInterceptor(paymentInterceptor))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///rest-test",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewRESTHandler(serviceMetadata, conn, 1024*1024)
+}
+
+func restCall(handler http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRESTHandler(t *testing.T) {
+	handler := newRESTTestHandler(t)
+	payment := map[string]string{
+		PaymentTypeHeader:             "escrow",
+		PaymentChannelSignatureHeader: base64.StdEncoding.EncodeToString([]byte{1, 2, 3}),
+	}
+
+	resp := restCall(handler, http.MethodPost, "/v1/items.Items/Get", `{"shelf":"books","id":"7"}`, payment)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"id":"7","name":"books"}`, resp.Body.String())
+	assert.Equal(t, "10", resp.Header().Get("x-price"))
+
+	// the short service name is accepted too
+	resp = restCall(handler, http.MethodPost, "/v1/Items/Get", `{"id":"1"}`, payment)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = restCall(handler, http.MethodPost, "/v1/items.Items/Get", `{"id":"0"}`, payment)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	var errorBody map[string]any
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &errorBody))
+	assert.Equal(t, float64(codes.NotFound), errorBody["code"])
+	assert.Equal(t, "item not found", errorBody["message"])
+
+	// the payment headers are checked by the interceptors of the gRPC server
+	resp = restCall(handler, http.MethodPost, "/v1/items.Items/Get", `{"id":"7"}`, map[string]string{PaymentTypeHeader: "escrow"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = restCall(handler, http.MethodPost, "/v1/items.Items/Get", `{"id":"7"}`,
+		map[string]string{PaymentTypeHeader: "escrow", PaymentChannelSignatureHeader: "not base64!"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = restCall(handler, http.MethodPost, "/v1/items.Items/Get", `{"unknown":1}`, payment)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = restCall(handler, http.MethodGet, "/v1/items.Items/Get", "", payment)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	resp = restCall(handler, http.MethodPost, "/v1/items.Items/Missing", "{}", payment)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	resp = restCall(handler, http.MethodPost, "/v1/items.Items/Count", "{}", payment)
+	assert.Equal(t, http.StatusNotImplemented, resp.Code)
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	serviceMetadata := &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"items.proto": httpRulesTestProto})}
+	document, err := OpenAPIDocument(serviceMetadata)
+	require.Nil(t, err)
+
+	var openAPI struct {
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.Nil(t, json.Unmarshal(document, &openAPI))
+
+	assert.Contains(t, openAPI.Paths, "/v1/items.Items/Get")
+	assert.Contains(t, openAPI.Paths, "/v1/items.Items/Update")
+	// streaming methods aren't served by the REST ingress
+	assert.NotContains(t, openAPI.Paths, "/v1/items.Items/Count")
+	assert.Contains(t, openAPI.Paths["/v1/items.Items/Get"]["post"], "x-snet-pricing")
+
+	item := openAPI.Components.Schemas["items.Item"]
+	assert.Equal(t, map[string]any{"type": "string", "format": "int64"}, item.Properties["id"])
+	assert.Equal(t, map[string]any{"type": "string"}, item.Properties["name"])
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/items.Item"},
+		openAPI.Components.Schemas["items.UpdateRequest"].Properties["item"])
+	assert.Equal(t, map[string]any{"type": "boolean"}, openAPI.Components.Schemas["items.UpdateRequest"].Properties["validate"])
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/errs"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RetryCountHeader is set in the trailer of the calls which were repeated, the
+// value is the number of the additional calls of the service
+const RetryCountHeader = "snet-retry-count"
+
+// errAttemptCancelled cancels the hedged attempts when another attempt responded
+var errAttemptCancelled = errors.New("another attempt of the call responded")
+
+// retryPolicy is the service_retry_policies entry of the method with the parsed status codes
+type retryPolicy struct {
+	maxAttempts    int
+	retryableCodes []codes.Code
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	hedgingDelay   time.Duration
+	// reuseUpstreams allows to call the upstreams again when all of them were tried
+	reuseUpstreams bool
+}
+
+// newRetryPolicies returns the policies of service_retry_policies by method
+func newRetryPolicies(policies []config.ServiceRetryPolicy) (map[string]*retryPolicy, error) {
+	byMethod := make(map[string]*retryPolicy)
+	for _, policy := range policies {
+		retryable := make([]codes.Code, 0, len(policy.RetryableStatusCodes))
+		for _, name := range policy.RetryableStatusCodes {
+			code, err := config.StatusCode(name)
+			if err != nil {
+				return nil, err
+			}
+			retryable = append(retryable, code)
+		}
+		for _, method := range policy.Methods {
+			byMethod[method] = &retryPolicy{
+				maxAttempts:    policy.MaxAttempts,
+				retryableCodes: retryable,
+				initialBackoff: policy.InitialBackoff,
+				maxBackoff:     policy.MaxBackoff,
+				multiplier:     policy.BackoffMultiplier,
+				hedgingDelay:   policy.HedgingDelay,
+				reuseUpstreams: true,
+			}
+		}
+	}
+	return byMethod, nil
+}
+
+// retryPolicy returns the configured policy of the unary method, nil if the
+// method has no policy or is streaming
+func (g *grpcHandler) retryPolicy(fullMethod string) *retryPolicy {
+	policy, ok := g.retryPolicies[fullMethod]
+	if !ok || !g.isUnary(fullMethod) {
+		return nil
+	}
+	return policy
+}
+
+// defaultRetryPolicy retries the calls of the idempotent methods on another
+// upstream if the upstream is unavailable, see service_load_balancing.retries
+func (g *grpcHandler) defaultRetryPolicy(fullMethod string) *retryPolicy {
+	policy := &retryPolicy{maxAttempts: 1}
+	if g.isIdempotent(fullMethod) {
+		policy.maxAttempts += g.loadBalancing.Retries
+	}
+	return policy
+}
+
+// retryable returns true if the failed attempt must be repeated, without the
+// configured status codes the attempts failed by the upstream are repeated
+func (policy *retryPolicy) retryable(failed bool, err error) bool {
+	if err == nil {
+		return false
+	}
+	if policy.retryableCodes == nil {
+		return failed
+	}
+	return slices.Contains(policy.retryableCodes, status.Code(err))
+}
+
+// backoff returns the randomized delay before the retry of the attempt with the
+// given number, 1 for the first retry
+func (policy *retryPolicy) backoff(retry int) time.Duration {
+	backoff := float64(policy.initialBackoff)
+	for range retry - 1 {
+		backoff *= policy.multiplier
+	}
+	backoff = min(backoff, float64(policy.maxBackoff))
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(backoff)) + 1)
+}
+
+// retryTrailer returns the trailer with the number of the repeated calls, nil
+// when the service was called once
+func retryTrailer(attempts int) metadata.MD {
+	if attempts <= 1 {
+		return nil
+	}
+	return metadata.Pairs(RetryCountHeader, strconv.Itoa(attempts-1))
+}
+
+// upstreamCall calls the service on the upstream, failed is true when the
+// upstream is unavailable or doesn't respond in time
+type upstreamCall[T any] func(ctx context.Context, upstream *Upstream) (result T, failed bool, err error)
+
+type attemptResult[T any] struct {
+	result    T
+	err       error
+	retryable bool
+	upstream  *Upstream
+}
+
+// callUpstream calls the service on the upstream picked by the pool and repeats
+// the failed call by the service_retry_policies policy of the method, or on
+// another upstream if the idempotent method has no policy. With the hedging
+// delay the next attempt is sent without waiting for the previous one, the first
+// response which isn't retryable wins and the other attempts are cancelled.
+// The result and the error are the ones of the last finished attempt, attempts
+// is the number of the calls of the service.
+func callUpstream[T any](g *grpcHandler, ctx context.Context, method string, call upstreamCall[T]) (result T, attempts int, err error) {
+	policy := g.retryPolicy(method)
+	if policy == nil {
+		policy = g.defaultRetryPolicy(method)
+	}
+
+	attemptCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errAttemptCancelled)
+	results := make(chan attemptResult[T], policy.maxAttempts)
+	tried := make([]*Upstream, 0, policy.maxAttempts)
+	start := func() error {
+		upstream, pickErr := g.upstreams.Pick(method, tried...)
+		if errors.Is(pickErr, ErrNoUpstream) && len(tried) > 0 && policy.reuseUpstreams {
+			upstream, pickErr = g.upstreams.Pick(method)
+		}
+		if pickErr != nil {
+			return pickErr
+		}
+		tried = append(tried, upstream)
+		attempts++
+		go func() {
+			result, failed, err := call(attemptCtx, upstream)
+			// the cancelled hedged attempt isn't the failure of the upstream
+			cancelled := errors.Is(context.Cause(attemptCtx), errAttemptCancelled)
+			g.upstreams.Done(upstream, method, failed && !cancelled)
+			results <- attemptResult[T]{result: result, err: err, retryable: policy.retryable(failed, err), upstream: upstream}
+		}()
+		return nil
+	}
+
+	if pickErr := start(); pickErr != nil {
+		if errors.Is(pickErr, ErrCircuitOpen) {
+			return result, 0, errs.ErrServiceUnavailable.New("can't call %v: %v", method, pickErr)
+		}
+		return result, 0, errs.ErrServiceUnavailable.New("can't connect to service %v", pickErr)
+	}
+
+	var hedge <-chan time.Time
+	var hedgeTimer *time.Timer
+	if policy.hedgingDelay > 0 {
+		hedgeTimer = time.NewTimer(policy.hedgingDelay)
+		defer hedgeTimer.Stop()
+		hedge = hedgeTimer.C
+	}
+
+	pending := 1
+	retries := 0
+	for pending > 0 {
+		select {
+		case <-hedge:
+			if attempts >= policy.maxAttempts || start() != nil {
+				hedge = nil
+				continue
+			}
+			pending++
+			g.upstreams.hedges.Add(1)
+			hedgeTimer.Reset(policy.hedgingDelay)
+		case attempt := <-results:
+			pending--
+			result, err = attempt.result, attempt.err
+			if !attempt.retryable {
+				return result, attempts, err
+			}
+			zap.L().Warn("service call failed", zap.String("endpoint", attempt.upstream.Endpoint),
+				zap.String("method", method), zap.Int("attempt", attempts), zap.Error(err))
+			if pending > 0 || attempts >= policy.maxAttempts || ctx.Err() != nil {
+				continue
+			}
+			retries++
+			if hedge == nil && !sleepContext(ctx, policy.backoff(retries)) {
+				return result, attempts, err
+			}
+			if start() != nil {
+				return result, attempts, err
+			}
+			pending++
+			g.upstreams.retries.Add(1)
+			if hedgeTimer != nil {
+				hedgeTimer.Reset(policy.hedgingDelay)
+			}
+		}
+	}
+	return result, attempts, err
+}
+
+// sleepContext waits for the delay, false if the context is done before
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
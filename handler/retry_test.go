@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestRetryHandler(t *testing.T, policy config.ServiceRetryPolicy, endpoints ...config.ServiceEndpoint) *grpcHandler {
+	pool := newTestUpstreamPool(config.RoundRobinPolicy, endpoints...)
+	policies, err := newRetryPolicies([]config.ServiceRetryPolicy{policy})
+	require.Nil(t, err)
+	return &grpcHandler{upstreams: pool, loadBalancing: pool.Settings(), retryPolicies: policies,
+		serviceMetaData: &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"items.proto": httpRulesTestProto})}}
+}
+
+func TestGrpcHandler_callUpstreamRetryPolicy(t *testing.T) {
+	g := newTestRetryHandler(t, config.ServiceRetryPolicy{
+		Methods:              []string{"/items.Items/Get"},
+		MaxAttempts:          3,
+		RetryableStatusCodes: []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"},
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           5 * time.Millisecond,
+		BackoffMultiplier:    2,
+	}, config.ServiceEndpoint{Endpoint: "a"})
+
+	// the single upstream is called again with the retryable codes
+	var responses []error
+	call := func(ctx context.Context, upstream *Upstream) (int, bool, error) {
+		err := responses[0]
+		responses = responses[1:]
+		return len(responses), isUpstreamFailure(err), err
+	}
+	responses = []error{status.Error(codes.ResourceExhausted, "busy"), status.Error(codes.Unavailable, "down"), nil}
+	result, attempts, err := callUpstream(g, context.Background(), "/items.Items/Get", call)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, uint64(2), g.upstreams.RetryStats().Retries)
+
+	// the call isn't repeated more than max_attempts times
+	unavailable := status.Error(codes.Unavailable, "down")
+	responses = []error{unavailable, unavailable, unavailable, nil}
+	_, attempts, err = callUpstream(g, context.Background(), "/items.Items/Get", call)
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 3, attempts)
+
+	// the errors which aren't retryable are returned at once
+	invalid := status.Error(codes.InvalidArgument, "invalid")
+	responses = []error{invalid, nil}
+	_, attempts, err = callUpstream(g, context.Background(), "/items.Items/Get", call)
+	assert.Equal(t, invalid, err)
+	assert.Equal(t, 1, attempts)
+
+	// the retries stop when the call is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	responses = []error{unavailable, nil}
+	_, attempts, err = callUpstream(g, ctx, "/items.Items/Get", call)
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 1, attempts)
+
+	assert.Nil(t, retryTrailer(1))
+	assert.Equal(t, []string{"2"}, retryTrailer(3).Get(RetryCountHeader))
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := &retryPolicy{initialBackoff: 10 * time.Millisecond, maxBackoff: 30 * time.Millisecond, multiplier: 2}
+	for range 100 {
+		assert.LessOrEqual(t, policy.backoff(1), 10*time.Millisecond)
+		assert.LessOrEqual(t, policy.backoff(2), 20*time.Millisecond)
+		assert.LessOrEqual(t, policy.backoff(5), 30*time.Millisecond)
+		assert.Greater(t, policy.backoff(1), time.Duration(0))
+	}
+	assert.Equal(t, time.Duration(0), (&retryPolicy{}).backoff(1))
+}
+
+func TestGrpcHandler_callUpstreamHedging(t *testing.T) {
+	g := newTestRetryHandler(t, config.ServiceRetryPolicy{
+		Methods:              []string{"/items.Items/Get"},
+		MaxAttempts:          2,
+		RetryableStatusCodes: []string{"UNAVAILABLE"},
+		HedgingDelay:         10 * time.Millisecond,
+		BackoffMultiplier:    1,
+	}, config.ServiceEndpoint{Endpoint: "a"}, config.ServiceEndpoint{Endpoint: "b"})
+
+	// the slow upstream is cancelled when the hedged attempt responds
+	var mutex sync.Mutex
+	cancelled := false
+	call := func(ctx context.Context, upstream *Upstream) (string, bool, error) {
+		if upstream.Endpoint == "a" {
+			<-ctx.Done()
+			mutex.Lock()
+			cancelled = true
+			mutex.Unlock()
+			return "", false, status.FromContextError(ctx.Err()).Err()
+		}
+		return upstream.Endpoint, false, nil
+	}
+	result, attempts, err := callUpstream(g, context.Background(), "/items.Items/Get", call)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", result)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, uint64(1), g.upstreams.RetryStats().Hedges)
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return cancelled
+	}, time.Second, time.Millisecond)
+
+	// the cancelled attempt isn't the failure of the upstream
+	assert.Eventually(t, func() bool {
+		states := g.upstreams.States()
+		return states[0].ActiveRequests == 0 && !states[0].Ejected
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 0, g.upstreams.Upstreams()[0].consecutiveFailures)
+
+	// the hedged attempt isn't sent when the first one responds in time
+	result, attempts, err = callUpstream(g, context.Background(), "/items.Items/Get", func(ctx context.Context, upstream *Upstream) (string, bool, error) {
+		return upstream.Endpoint, false, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.NotEmpty(t, result)
+}
+
+func TestGrpcHandler_retryPolicy(t *testing.T) {
+	g := newTestRetryHandler(t, config.ServiceRetryPolicy{
+		Methods:     []string{"/items.Items/Get", "/items.Items/Count"},
+		MaxAttempts: 2,
+	}, config.ServiceEndpoint{Endpoint: "a"})
+
+	assert.NotNil(t, g.retryPolicy("/items.Items/Get"))
+	assert.Nil(t, g.retryPolicy("/items.Items/Update"))
+	// the streaming calls can't be repeated
+	assert.Nil(t, g.retryPolicy("/items.Items/Count"))
+	// the methods unknown to the proto files are never repeated
+	g.retryPolicies["/other.Items/Get"] = g.retryPolicies["/items.Items/Get"]
+	assert.Nil(t, g.retryPolicy("/other.Items/Get"))
+	g.serviceMetaData = nil
+	assert.Nil(t, g.retryPolicy("/items.Items/Get"))
+}
+
+// flakyExampleService fails the first calls with UNAVAILABLE
+type flakyExampleService struct {
+	UnimplementedExampleServiceServer
+	failures atomic.Int32
+	calls    atomic.Int32
+}
+
+func (service *flakyExampleService) Ping(ctx context.Context, input *Input) (*Output, error) {
+	if service.calls.Add(1) <= service.failures.Load() {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+	return &Output{Message: input.Message}, nil
+}
+
+func TestNewGrpcHandler_RetryWithoutPresetDescriptors(t *testing.T) {
+	protoFile, err := os.ReadFile("grpc_test.proto")
+	require.Nil(t, err)
+
+	previousVip := config.Vip()
+	defer config.SetVip(previousVip)
+	vip := viper.New()
+	vip.Set(config.PassthroughEnabledKey, true)
+	vip.Set(config.ServiceTimeout, "10s")
+	vip.Set(config.MaxMessageSizeInMB, 4)
+	vip.Set(config.ServiceRetryPoliciesKey, []map[string]any{{
+		"methods":         []string{"/handler.ExampleService/Ping"},
+		"max_attempts":    3,
+		"initial_backoff": "1ms",
+		"max_backoff":     "1ms",
+	}})
+	config.SetVip(vip)
+
+	service := &flakyExampleService{}
+	service.failures.Store(2)
+	upstreamListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	upstream := grpc.NewServer()
+	RegisterExampleServiceServer(upstream, service)
+	go upstream.Serve(upstreamListener)
+	defer upstream.Stop()
+
+	// the grpc service metadata has the proto files only
+	serviceMetadata := &blockchain.ServiceMetadata{ServiceType: "grpc", ProtoFiles: map[string]string{"grpc_test.proto": string(protoFile)}}
+	pool := NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: upstreamListener.Addr().String()}},
+		&config.ServiceLoadBalancingSettings{Policy: config.RoundRobinPolicy}, nil, nil, "", "")
+	defer pool.Close()
+	daemonListener := bufconn.Listen(1024 * 1024)
+	daemon := grpc.NewServer(grpc.UnknownServiceHandler(NewGrpcHandler(serviceMetadata, pool, nil)))
+	go daemon.Serve(daemonListener)
+	defer daemon.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///retry-test",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return daemonListener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+
+	output, err := NewExampleServiceClient(conn).Ping(context.Background(), &Input{Message: "ping"})
+	require.Nil(t, err)
+	assert.Equal(t, "ping", output.Message)
+	assert.Equal(t, int32(3), service.calls.Load())
+	assert.NotNil(t, serviceMetadata.ProtoDescriptors)
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/singnet/snet-daemon/v6/errs"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// HTTPErrorDomain is the domain of the ErrorInfo detail of the errors returned by the HTTP service
+	HTTPErrorDomain = "http"
+	// JSONRPCErrorDomain is the domain of the ErrorInfo detail of the errors returned by the JSON-RPC service
+	JSONRPCErrorDomain = "jsonrpc"
+)
+
+// httpStatusToCode maps the HTTP status of the service response to the gRPC code
+func httpStatusToCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case 499: // client closed request
+		return codes.Canceled
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	switch {
+	case statusCode >= 400 && statusCode < 500:
+		return codes.FailedPrecondition
+	case statusCode >= 500:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// codeToHTTPStatus maps the gRPC code to the HTTP status of the REST ingress response
+func codeToHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// httpStatusError returns the error of the non-2xx response of the service, the
+// ErrorInfo detail has the HTTP status
+func httpStatusError(code codes.Code, statusCode int, body []byte) error {
+	message := "upstream http status " + strconv.Itoa(statusCode) + ": " + string(body)
+	if code == codes.Unavailable {
+		message += errs.ErrDescURL(errs.ServiceUnavailable)
+	}
+	return withDetails(status.New(code, message), &errdetails.ErrorInfo{
+		Reason:   strings.ToUpper(strings.ReplaceAll(http.StatusText(statusCode), " ", "_")),
+		Domain:   HTTPErrorDomain,
+		Metadata: map[string]string{"http_status": strconv.Itoa(statusCode)},
+	})
+}
+
+// jsonRPCCodeToCode maps the code of the JSON-RPC error object to the gRPC code,
+// the codes defined by the application are Unknown
+func jsonRPCCodeToCode(code json2.ErrorCode) codes.Code {
+	switch {
+	case code == json2.E_PARSE, code == json2.E_INVALID_REQ, code == json2.E_BAD_PARAMS:
+		return codes.InvalidArgument
+	case code == json2.E_NO_METHOD:
+		return codes.Unimplemented
+	case code == json2.E_INTERNAL:
+		return codes.Internal
+	case code <= json2.E_SERVER && code >= -32099: // reserved for implementation-defined server errors
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// jsonRPCStatusError returns the error of the JSON-RPC error object, the code of
+// the object is kept in the ErrorInfo detail and its data in the Value detail
+func jsonRPCStatusError(jsonErr *json2.Error) error {
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   "JSON_RPC_ERROR",
+		Domain:   JSONRPCErrorDomain,
+		Metadata: map[string]string{"code": strconv.Itoa(int(jsonErr.Code))},
+	}}
+	if jsonErr.Data != nil {
+		data, err := structpb.NewValue(jsonErr.Data)
+		if err == nil {
+			details = append(details, data)
+		} else {
+			zap.L().Debug("can't convert json-rpc error data", zap.Error(err))
+		}
+	}
+	return withDetails(status.New(jsonRPCCodeToCode(jsonErr.Code), jsonErr.Message), details...)
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// skippedTrailerHeaders are the headers of the transport which are not forwarded as trailers
+var skippedTrailerHeaders = map[string]bool{
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"content-encoding":  true,
+	"date":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// headersToTrailer converts the headers of the service response to the gRPC
+// trailer, the transport and reserved gRPC headers are skipped
+func headersToTrailer(header http.Header) metadata.MD {
+	trailer := metadata.MD{}
+	for key, values := range header {
+		key = strings.ToLower(key)
+		if skippedTrailerHeaders[key] || strings.HasPrefix(key, "grpc-") || strings.HasPrefix(key, ":") {
+			continue
+		}
+		trailer.Append(key, values...)
+	}
+	return trailer
+}
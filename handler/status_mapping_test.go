@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// trailerTestStream keeps the trailer set by the handler
+type trailerTestStream struct {
+	httpTestStream
+	trailer metadata.MD
+}
+
+func (m *trailerTestStream) SetTrailer(md metadata.MD) {
+	m.trailer = metadata.Join(m.trailer, md)
+}
+
+func TestHttpStatusToCode(t *testing.T) {
+	assert.Equal(t, codes.InvalidArgument, httpStatusToCode(http.StatusBadRequest))
+	assert.Equal(t, codes.Unauthenticated, httpStatusToCode(http.StatusUnauthorized))
+	assert.Equal(t, codes.NotFound, httpStatusToCode(http.StatusNotFound))
+	assert.Equal(t, codes.ResourceExhausted, httpStatusToCode(http.StatusTooManyRequests))
+	assert.Equal(t, codes.Unavailable, httpStatusToCode(http.StatusServiceUnavailable))
+	assert.Equal(t, codes.DeadlineExceeded, httpStatusToCode(http.StatusGatewayTimeout))
+	assert.Equal(t, codes.FailedPrecondition, httpStatusToCode(http.StatusTeapot))
+	assert.Equal(t, codes.Internal, httpStatusToCode(http.StatusInternalServerError))
+	assert.Equal(t, codes.Unknown, httpStatusToCode(http.StatusFound))
+}
+
+func TestJSONRPCStatusError(t *testing.T) {
+	st := status.Convert(jsonRPCStatusError(&json2.Error{Code: json2.E_BAD_PARAMS, Message: "bad a", Data: map[string]any{"field": "a"}}))
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "bad a", st.Message())
+	require.Len(t, st.Details(), 2)
+	info := st.Details()[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, JSONRPCErrorDomain, info.Domain)
+	assert.Equal(t, "-32602", info.Metadata["code"])
+	assert.Equal(t, "a", st.Details()[1].(*structpb.Value).GetStructValue().Fields["field"].GetStringValue())
+
+	assert.Equal(t, codes.Unknown, status.Code(jsonRPCStatusError(&json2.Error{Code: 42})))
+	assert.Equal(t, codes.Internal, status.Code(jsonRPCStatusError(&json2.Error{Code: -32050})))
+}
+
+func TestHeadersToTrailer(t *testing.T) {
+	trailer := headersToTrailer(http.Header{
+		"Content-Type":   {"application/json"},
+		"Grpc-Status":    {"0"},
+		"X-Request-Id":   {"42"},
+		"X-Ratelimit-Ok": {"1", "2"},
+	})
+	assert.Equal(t, metadata.MD{"x-request-id": {"42"}, "x-ratelimit-ok": {"1", "2"}}, trailer)
+}
+
+func TestGrpcToHTTP_ErrorMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-request-id", "42")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"shelf is required"}`))
+	}))
+	defer server.Close()
+	g := newHTTPTestHandler(t, server.URL)
+
+	method := g.findMethod("/items.Items/Get")
+	require.NotNil(t, method)
+	stream := &trailerTestStream{}
+	stream.context = grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		&methodTransportStream{method: "/items.Items/Get"})
+	stream.requests = [][]byte{nil}
+
+	st := status.Convert(g.grpcToHTTP(nil, stream))
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	info := st.Details()[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, HTTPErrorDomain, info.Domain)
+	assert.Equal(t, "BAD_REQUEST", info.Reason)
+	assert.Equal(t, "400", info.Metadata["http_status"])
+	assert.Equal(t, []string{"42"}, stream.trailer.Get("x-request-id"))
+}
+
+func TestGrpcToJSONRPC_ErrorMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-request-id", "7")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"error":   map[string]any{"code": -32601, "message": "method not found", "data": "div"},
+		})
+	}))
+	defer server.Close()
+	g := newHTTPTestHandler(t, server.URL)
+
+	stream := &trailerTestStream{}
+	stream.context = grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		&methodTransportStream{method: "/example.Calculator/div"})
+	stream.requests = [][]byte{[]byte(`{"a":1}`)}
+
+	st := status.Convert(g.grpcToJSONRPC(nil, stream))
+	assert.Equal(t, codes.Unimplemented, st.Code())
+	assert.Equal(t, "method not found", st.Message())
+	require.Len(t, st.Details(), 2)
+	assert.Equal(t, "-32601", st.Details()[0].(*errdetails.ErrorInfo).Metadata["code"])
+	assert.Equal(t, "div", st.Details()[1].(*structpb.Value).GetStringValue())
+	assert.Equal(t, []string{"7"}, stream.trailer.Get("x-request-id"))
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/configuration_service"
+	"github.com/singnet/snet-daemon/v6/ctxkeys"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/metrics"
 	"github.com/singnet/snet-daemon/v6/ratelimit"
 	"go.uber.org/zap"
@@ -75,6 +79,8 @@ const (
 	DynamicPriceDerived = "snet-derived-dynamic-price-cost"
 
 	TrainingModelId = "snet-train-model-id"
+	// TrainingModelVersion pins the version of the training model used by the inference call
+	TrainingModelVersion = "snet-train-model-version"
 )
 
 // GrpcStreamContext contains information about gRPC call which is used to
@@ -83,6 +89,9 @@ type GrpcStreamContext struct {
 	MD       metadata.MD
 	Info     *grpc.StreamServerInfo
 	InStream grpc.ServerStream
+	// ClientIdentity is the identity of the verified client certificate, it is
+	// nil when the client didn't send a certificate
+	ClientIdentity *ClientCertIdentity
 }
 
 func (context *GrpcStreamContext) String() string {
@@ -98,7 +107,7 @@ const (
 	// IncorrectNonce is returned to client when payment received contains
 	// incorrect nonce value. Client may use PaymentChannelStateService to get
 	// latest channel state and correct nonce value.
-	IncorrectNonce codes.Code = 1000
+	IncorrectNonce = errs.IncorrectNonceCode
 )
 
 // GrpcError is an error which will be returned by interceptor via gRPC
@@ -146,6 +155,14 @@ func NewGrpcErrorf(code codes.Code, format string, args ...any) *GrpcError {
 	}
 }
 
+// NewGrpcErrorFrom returns new error which contains gRPC status of the error
+// of the catalogue, the status has the ErrorInfo detail of the error
+func NewGrpcErrorFrom(err *errs.Error) *GrpcError {
+	return &GrpcError{
+		Status: err.GRPCStatus(),
+	}
+}
+
 // StreamPaymentHandler interface which is used by gRPC interceptor to get, validate
 // and complete payment. There are two payment handler implementations so far:
 // jobPaymentHandler and escrowPaymentHandler. jobPaymentHandler is deprecated.
@@ -188,6 +205,32 @@ func (interceptor *rateLimitInterceptor) startOrStopProcessingAnyRequests() {
 	}
 }
 
+// GrpcCircuitBreakerInterceptor rejects the calls of the methods whose circuit is
+// open on every upstream and limits the calls in flight to the upstreams. It must
+// precede the payment validation to not lock the payments of the rejected calls.
+// The methods of the daemon services (srv isn't nil) never reach the upstreams
+// and are passed through, only the unknown service handler proxies the calls.
+func GrpcCircuitBreakerInterceptor(upstreams *UpstreamPool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if srv != nil {
+			return handler(srv, ss)
+		}
+		if err := upstreams.Allow(info.FullMethod); err != nil {
+			return errs.ErrServiceUnavailable.New("can't call %v: %v", info.FullMethod, err)
+		}
+		release, err := upstreams.Acquire(ss.Context())
+		if err != nil {
+			if ss.Context().Err() != nil {
+				return status.FromContextError(err).Err()
+			}
+			zap.L().Info("concurrency limit reached, too many calls to the service", zap.String("method", info.FullMethod))
+			return errs.ErrConcurrencyLimit.New("%v", err)
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
 func GrpcMeteringInterceptor(currentBlock func() (*big.Int, error)) grpc.StreamServerInterceptor {
 	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		return interceptMetering(srv, ss, info, handler, currentBlock)
@@ -234,11 +277,11 @@ func interceptMetering(
 func (interceptor *rateLimitInterceptor) intercept(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 
 	if interceptor.processRequest == configuration_service.StopProcessingAnyRequest {
-		return status.New(codes.Unavailable, "No requests are currently being processed, please try again later").Err()
+		return errs.ErrRequestsStopped.New("No requests are currently being processed, please try again later")
 	}
 	if !interceptor.rateLimiter.Allow() {
 		zap.L().Info("rate limit reached, too many requests to handle", zap.Any("rateLimiter.Burst()", interceptor.rateLimiter.Burst()))
-		return status.New(codes.ResourceExhausted, "rate limiting , too many requests to handle").Err()
+		return errs.ErrRateLimited.New("rate limiting , too many requests to handle")
 	}
 	err := handler(srv, ss)
 	if err != nil {
@@ -323,6 +366,11 @@ func (interceptor *paymentValidationInterceptor) streamIntercept(srv any, ss grp
 		}
 	}
 
+	// make payment details available to the interceptors further in the chain (usage reporting)
+	if ws, ok := wrapperStream.(*WrapperServerStream); ok {
+		ws.Ctx = withPaymentDetails(ws.Ctx, paymentHandler.Type(), payment)
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
 			zap.L().Warn("Service handler called panic(panicValue)", zap.Any("panicValue", r))
@@ -354,6 +402,19 @@ func (interceptor *paymentValidationInterceptor) streamIntercept(srv any, ss grp
 	return nil
 }
 
+// withPaymentDetails stores the payment type, the verified sender and the charged
+// amount of the accepted payment into the context.
+func withPaymentDetails(ctx context.Context, paymentType string, payment Payment) context.Context {
+	ctx = context.WithValue(ctx, ctxkeys.PaymentTypeKey, paymentType)
+	if sp, ok := payment.(SenderProvider); ok {
+		ctx = context.WithValue(ctx, ctxkeys.SenderKey, sp.GetSender())
+	}
+	if ap, ok := payment.(ChargedAmountProvider); ok {
+		ctx = context.WithValue(ctx, ctxkeys.ChargedAmountKey, ap.ChargedAmount())
+	}
+	return ctx
+}
+
 func getGrpcContext(
 	serverStream grpc.ServerStream,
 	info *grpc.StreamServerInfo,
@@ -361,7 +422,7 @@ func getGrpcContext(
 	md, ok := metadata.FromIncomingContext(serverStream.Context())
 	if !ok {
 		zap.L().Error("Invalid metadata", zap.Any("info", info))
-		return nil, NewGrpcError(codes.InvalidArgument, "missing metadata")
+		return nil, NewGrpcErrorFrom(errs.ErrMissingMetadata.New("missing metadata"))
 	}
 
 	// 1) Copy metadata to allow safe mutation
@@ -376,6 +437,7 @@ func getGrpcContext(
 		Info:     info,
 		InStream: serverStream, // using original stream for now
 		// optionally add separate Ctx field if needed
+		ClientIdentity: ClientCertIdentityFromContext(serverStream.Context()),
 	}, nil
 }
 
@@ -392,7 +454,7 @@ func (interceptor *paymentValidationInterceptor) getPaymentHandler(context *Grpc
 	paymentHandler, ok := interceptor.paymentHandlers[paymentType]
 	if !ok {
 		zap.L().Error("Unexpected payment type", zap.String("paymentType", paymentType))
-		return nil, NewGrpcErrorf(codes.InvalidArgument, "unexpected \"%v\", value: \"%v\"", PaymentTypeHeader, paymentType)
+		return nil, NewGrpcErrorFrom(errs.ErrUnknownPaymentType.New("unexpected \"%v\", value: \"%v\"", PaymentTypeHeader, paymentType))
 	}
 
 	zap.L().Debug("Return payment handler by type", zap.Any("paymentType", paymentType))
@@ -409,7 +471,7 @@ func GetBigInt(md metadata.MD, key string) (value *big.Int, err *GrpcError) {
 	value = big.NewInt(0)
 	e := value.UnmarshalText([]byte(str))
 	if e != nil {
-		return nil, NewGrpcErrorf(codes.InvalidArgument, "incorrect format \"%v\": \"%v\"", key, str)
+		return nil, NewGrpcErrorFrom(errs.ErrInvalidPaymentHeader.New("incorrect format \"%v\": \"%v\"", key, str).With("header", key))
 	}
 
 	return
@@ -419,7 +481,7 @@ func GetBigInt(md metadata.MD, key string) (value *big.Int, err *GrpcError) {
 // suffix, internally this data is encoded as base64
 func GetBytes(md metadata.MD, key string) (result []byte, err *GrpcError) {
 	if !strings.HasSuffix(key, "-bin") {
-		return nil, NewGrpcErrorf(codes.InvalidArgument, "incorrect binary key name \"%v\"", key)
+		return nil, NewGrpcErrorFrom(errs.ErrInvalidPaymentHeader.New("incorrect binary key name \"%v\"", key).With("header", key))
 	}
 
 	str, err := GetSingleValue(md, key)
@@ -445,11 +507,11 @@ func GetSingleValue(md metadata.MD, key string) (value string, err *GrpcError) {
 	array := md.Get(key)
 
 	if len(array) == 0 {
-		return "", NewGrpcErrorf(codes.InvalidArgument, "missing \"%v\"", key)
+		return "", NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("missing \"%v\"", key).With("header", key))
 	}
 
 	if len(array) > 1 {
-		return "", NewGrpcErrorf(codes.InvalidArgument, "too many values for key \"%v\": %v", key, array)
+		return "", NewGrpcErrorFrom(errs.ErrMissingPaymentHeader.New("too many values for key \"%v\": %v", key, array).With("header", key))
 	}
 
 	return array[0], nil
@@ -461,6 +523,17 @@ func NoOpInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
 	return handler(srv, ss)
 }
 
+// SkipStreamMethods returns the interceptor calling the handler directly for the
+// methods, e.g. the free streaming methods of the daemon services
+func SkipStreamMethods(interceptor grpc.StreamServerInterceptor, fullMethods ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if slices.Contains(fullMethods, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		return interceptor(srv, ss, info, handler)
+	}
+}
+
 // set Additional details on the metrics persisted; this is to keep track of how many calls were made per channel
 func setAdditionalDetails(context *GrpcStreamContext, stats *metrics.CommonStats) {
 	md := context.MD
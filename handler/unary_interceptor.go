@@ -3,14 +3,15 @@ package handler
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/ctxkeys"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -25,6 +26,12 @@ type SenderProvider interface {
 	GetSender() common.Address
 }
 
+// ChargedAmountProvider allows retrieving the amount of cogs charged for the call
+// by the payment, independent of the specific type from pkg/escrow.
+type ChargedAmountProvider interface {
+	ChargedAmount() *big.Int
+}
+
 type UnaryPaymentHandler interface {
 	// Type is a content of the PaymentTypeHeader field that triggers usage of the
 	// payment handler.
@@ -56,7 +63,7 @@ func (interceptor *paymentValidationUnaryInterceptor) unaryIntercept(ctx context
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		zap.L().Error("Invalid metadata", zap.Any("info", info))
-		return nil, NewGrpcError(codes.InvalidArgument, "missing metadata").Err()
+		return nil, errs.ErrMissingMetadata.New("missing metadata")
 	}
 
 	// pass non-training requests and free requests
@@ -137,7 +144,7 @@ func (interceptor *paymentValidationUnaryInterceptor) getPaymentHandler(context
 	paymentHandler, ok := interceptor.paymentHandlers[paymentType]
 	if !ok {
 		zap.L().Error("Unexpected payment type", zap.String("paymentType", paymentType))
-		return nil, NewGrpcErrorf(codes.InvalidArgument, "unexpected \"%v\", value: \"%v\"", PaymentTypeHeader, paymentType)
+		return nil, NewGrpcErrorFrom(errs.ErrUnknownPaymentType.New("unexpected \"%v\", value: \"%v\"", PaymentTypeHeader, paymentType))
 	}
 
 	zap.L().Debug("Return payment handler by type", zap.Any("paymentType", paymentType))
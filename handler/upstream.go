@@ -0,0 +1,493 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+var (
+	// ErrNoUpstream is returned when there is no upstream left to pick
+	ErrNoUpstream = errors.New("no service upstream available")
+	// ErrCircuitOpen is returned when the circuit of the method is open on every upstream
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrTooManyCalls is returned when the call can't get an upstream slot in time
+	ErrTooManyCalls = errors.New("too many calls in flight to the service")
+)
+
+// Upstream is a single replica of the service behind the daemon
+type Upstream struct {
+	Endpoint          string
+	Weight            int
+	HeartbeatEndpoint string
+
+	conn *grpc.ClientConn
+
+	activeRequests      int
+	healthy             bool
+	consecutiveFailures int
+	ejectedUntil        time.Time
+	currentWeight       int
+	circuits            map[string]*circuitBreaker
+}
+
+// CircuitState is the state of the circuit breaker of the upstream method
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// circuitBreaker opens after FailureThreshold consecutive failures and rejects the
+// calls for OpenTime, then lets HalfOpenCalls trial calls through: the first
+// successful trial closes the circuit, a failed one opens it again.
+type circuitBreaker struct {
+	state         CircuitState
+	failures      int
+	openedAt      time.Time
+	halfOpenCalls int
+}
+
+// UpstreamPool balances calls between the upstreams of service_endpoint.
+// Upstreams which fail the active health check or return ConsecutiveFailures
+// failures in a row are not picked until they recover. If no upstream is
+// available, all of them are considered to avoid refusing calls because of a
+// faulty health check. Upstreams with the open circuit for the method are never
+// picked.
+type UpstreamPool struct {
+	upstreams         []*Upstream
+	settings          *config.ServiceLoadBalancingSettings
+	breaker           *config.ServiceCircuitBreakerSettings
+	limit             *config.ServiceConcurrencyLimitSettings
+	heartbeatType     string
+	heartbeatEndpoint string
+
+	mutex       sync.Mutex
+	next        int
+	now         func() time.Time
+	checkHealth func(heartbeatType string, serviceURL string) error
+	stop        chan struct{}
+	stopOnce    sync.Once
+
+	slots  chan struct{}
+	queued atomic.Int32
+
+	retries atomic.Uint64
+	hedges  atomic.Uint64
+}
+
+// NewUpstreamPool creates the pool of the endpoints, nil breaker and limit
+// disable the circuit breaker and the concurrency limit
+func NewUpstreamPool(endpoints []config.ServiceEndpoint, settings *config.ServiceLoadBalancingSettings,
+	breaker *config.ServiceCircuitBreakerSettings, limit *config.ServiceConcurrencyLimitSettings,
+	heartbeatType string, heartbeatEndpoint string) *UpstreamPool {
+	pool := &UpstreamPool{
+		settings:          settings,
+		breaker:           breaker,
+		limit:             limit,
+		heartbeatType:     heartbeatType,
+		heartbeatEndpoint: heartbeatEndpoint,
+		now:               time.Now,
+		checkHealth:       metrics.CheckServiceHealth,
+		stop:              make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		pool.upstreams = append(pool.upstreams, &Upstream{
+			Endpoint:          endpoint.Endpoint,
+			Weight:            max(endpoint.Weight, 1),
+			HeartbeatEndpoint: endpoint.HeartbeatEndpoint,
+			healthy:           true,
+			circuits:          map[string]*circuitBreaker{},
+		})
+	}
+	if limit != nil && limit.MaxConcurrentCalls > 0 {
+		pool.slots = make(chan struct{}, limit.MaxConcurrentCalls*len(pool.upstreams))
+	}
+	return pool
+}
+
+// NewServiceUpstreamPool creates the pool of the service_endpoint upstreams from the config
+func NewServiceUpstreamPool() (*UpstreamPool, error) {
+	settings, err := config.GetServiceLoadBalancing()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := config.GetServiceCircuitBreaker()
+	if err != nil {
+		return nil, err
+	}
+	limit, err := config.GetServiceConcurrencyLimit()
+	if err != nil {
+		return nil, err
+	}
+	return NewUpstreamPool(config.GetServiceEndpoints(), settings, breaker, limit,
+		config.GetString(config.ServiceHeartbeatType), config.GetString(config.HeartbeatServiceEndpoint)), nil
+}
+
+// Settings returns the load balancing settings of the pool
+func (pool *UpstreamPool) Settings() *config.ServiceLoadBalancingSettings {
+	return pool.settings
+}
+
+// Upstreams returns all the upstreams of the pool
+func (pool *UpstreamPool) Upstreams() []*Upstream {
+	return pool.upstreams
+}
+
+// Len returns the number of upstreams in the pool
+func (pool *UpstreamPool) Len() int {
+	return len(pool.upstreams)
+}
+
+// States returns the snapshot of the upstreams state
+func (pool *UpstreamPool) States() []metrics.UpstreamState {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	now := pool.now()
+	states := make([]metrics.UpstreamState, 0, len(pool.upstreams))
+	for _, upstream := range pool.upstreams {
+		state := metrics.UpstreamState{
+			Endpoint:       upstream.Endpoint,
+			Healthy:        upstream.healthy,
+			Ejected:        now.Before(upstream.ejectedUntil),
+			ActiveRequests: upstream.activeRequests,
+		}
+		for method, circuit := range upstream.circuits {
+			if state.Circuits == nil {
+				state.Circuits = map[string]string{}
+			}
+			state.Circuits[method] = pool.circuitState(circuit, now).String()
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// RetryStats returns the number of the calls repeated by the retry policies
+func (pool *UpstreamPool) RetryStats() *metrics.UpstreamRetryStats {
+	return &metrics.UpstreamRetryStats{Retries: pool.retries.Load(), Hedges: pool.hedges.Load()}
+}
+
+// Allow returns ErrCircuitOpen when the circuit of the method is open on every
+// upstream, so the call can be rejected before the payment is validated
+func (pool *UpstreamPool) Allow(method string) error {
+	if !pool.breakerEnabled() {
+		return nil
+	}
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	now := pool.now()
+	for _, upstream := range pool.upstreams {
+		if pool.circuitAllows(upstream.circuits[method], now) {
+			return nil
+		}
+	}
+	return ErrCircuitOpen
+}
+
+// Acquire waits for a free slot when service_concurrency_limit is set, the
+// pool has MaxConcurrentCalls slots per upstream shared by all the calls, the
+// call waits in the queue for QueueTimeout at most. The returned release
+// function must be called when the call is finished.
+func (pool *UpstreamPool) Acquire(ctx context.Context) (release func(), err error) {
+	if pool.slots == nil {
+		return func() {}, nil
+	}
+	release = func() { <-pool.slots }
+	select {
+	case pool.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if pool.queued.Add(1) > int32(pool.limit.MaxQueuedCalls) {
+		pool.queued.Add(-1)
+		return nil, ErrTooManyCalls
+	}
+	defer pool.queued.Add(-1)
+
+	timer := time.NewTimer(pool.limit.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case pool.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: queue timeout %v exceeded", ErrTooManyCalls, pool.limit.QueueTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Pick selects the upstream for the call of the method according to the policy,
+// the upstreams in exclude (already tried by the call) are never selected. The
+// caller must call Done when the call to the upstream is finished.
+func (pool *UpstreamPool) Pick(method string, exclude ...*Upstream) (*Upstream, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	now := pool.now()
+	candidates := make([]*Upstream, 0, len(pool.upstreams))
+	fallback := make([]*Upstream, 0, len(pool.upstreams))
+	circuitOpen := false
+	for _, upstream := range pool.upstreams {
+		if slices.Contains(exclude, upstream) {
+			continue
+		}
+		if pool.breakerEnabled() && !pool.circuitAllows(upstream.circuits[method], now) {
+			circuitOpen = true
+			continue
+		}
+		fallback = append(fallback, upstream)
+		if upstream.healthy && !now.Before(upstream.ejectedUntil) {
+			candidates = append(candidates, upstream)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = fallback
+	}
+	if len(candidates) == 0 {
+		if circuitOpen {
+			return nil, ErrCircuitOpen
+		}
+		return nil, ErrNoUpstream
+	}
+
+	var picked *Upstream
+	switch pool.settings.Policy {
+	case config.LeastRequestPolicy:
+		picked = pool.pickLeastRequest(candidates)
+	case config.WeightedPolicy:
+		picked = pickWeighted(candidates)
+	default:
+		picked = candidates[pool.next%len(candidates)]
+		pool.next++
+	}
+	picked.activeRequests++
+	if circuit := picked.circuits[method]; circuit != nil && circuit.state == CircuitHalfOpen {
+		circuit.halfOpenCalls++
+	}
+	return picked, nil
+}
+
+func (pool *UpstreamPool) breakerEnabled() bool {
+	return pool.breaker != nil && pool.breaker.FailureThreshold > 0
+}
+
+// circuitState returns the state of the circuit, the open circuit becomes
+// half-open when OpenTime is elapsed
+func (pool *UpstreamPool) circuitState(circuit *circuitBreaker, now time.Time) CircuitState {
+	if circuit.state == CircuitOpen && !now.Before(circuit.openedAt.Add(pool.breaker.OpenTime)) {
+		circuit.state = CircuitHalfOpen
+		circuit.halfOpenCalls = 0
+	}
+	return circuit.state
+}
+
+// circuitAllows returns true when the call can be sent through the circuit
+func (pool *UpstreamPool) circuitAllows(circuit *circuitBreaker, now time.Time) bool {
+	if circuit == nil {
+		return true
+	}
+	switch pool.circuitState(circuit, now) {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		return circuit.halfOpenCalls < pool.breaker.HalfOpenCalls
+	}
+	return true
+}
+
+// recordCircuit updates the circuit of the upstream method with the result of the call
+func (pool *UpstreamPool) recordCircuit(upstream *Upstream, method string, failed bool) {
+	circuit := upstream.circuits[method]
+	if circuit == nil {
+		if !failed {
+			return
+		}
+		circuit = &circuitBreaker{}
+		upstream.circuits[method] = circuit
+	}
+
+	switch circuit.state {
+	case CircuitHalfOpen:
+		circuit.halfOpenCalls = max(circuit.halfOpenCalls-1, 0)
+		if !failed {
+			circuit.state = CircuitClosed
+			circuit.failures = 0
+			zap.L().Info("circuit breaker closed", zap.String("endpoint", upstream.Endpoint), zap.String("method", method))
+			return
+		}
+	case CircuitOpen:
+		// the call was picked before the circuit opened
+		return
+	default:
+		if !failed {
+			circuit.failures = 0
+			return
+		}
+		circuit.failures++
+		if circuit.failures < pool.breaker.FailureThreshold {
+			return
+		}
+	}
+	circuit.state = CircuitOpen
+	circuit.failures = 0
+	circuit.openedAt = pool.now()
+	zap.L().Warn("circuit breaker opened", zap.String("endpoint", upstream.Endpoint), zap.String("method", method),
+		zap.Duration("openTime", pool.breaker.OpenTime))
+}
+
+// pickLeastRequest selects the upstream with the fewest active requests,
+// the ties are resolved in round-robin order
+func (pool *UpstreamPool) pickLeastRequest(candidates []*Upstream) *Upstream {
+	start := pool.next % len(candidates)
+	pool.next++
+	picked := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		candidate := candidates[(start+i)%len(candidates)]
+		if candidate.activeRequests < picked.activeRequests {
+			picked = candidate
+		}
+	}
+	return picked
+}
+
+// pickWeighted is the smooth weighted round-robin used by nginx
+func pickWeighted(candidates []*Upstream) *Upstream {
+	total := 0
+	var picked *Upstream
+	for _, candidate := range candidates {
+		candidate.currentWeight += candidate.Weight
+		total += candidate.Weight
+		if picked == nil || candidate.currentWeight > picked.currentWeight {
+			picked = candidate
+		}
+	}
+	picked.currentWeight -= total
+	return picked
+}
+
+// Done releases the upstream picked by Pick, failed is true when the upstream
+// was unavailable or didn't respond in time. Upstreams failing ConsecutiveFailures
+// times in a row are ejected for EjectionTime.
+func (pool *UpstreamPool) Done(upstream *Upstream, method string, failed bool) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	upstream.activeRequests--
+	if pool.breakerEnabled() {
+		pool.recordCircuit(upstream, method, failed)
+	}
+	if !failed {
+		upstream.consecutiveFailures = 0
+		return
+	}
+	upstream.consecutiveFailures++
+	threshold := pool.settings.ConsecutiveFailures
+	if threshold > 0 && upstream.consecutiveFailures >= threshold && len(pool.upstreams) > 1 {
+		upstream.consecutiveFailures = 0
+		upstream.ejectedUntil = pool.now().Add(pool.settings.EjectionTime)
+		zap.L().Warn("service upstream ejected", zap.String("endpoint", upstream.Endpoint),
+			zap.Duration("ejectionTime", pool.settings.EjectionTime))
+	}
+}
+
+// StartHealthChecks checks the health of every upstream each HealthCheckInterval
+// until Close is called. Health checks are not needed for a single upstream,
+// the service heartbeat reports its state already.
+func (pool *UpstreamPool) StartHealthChecks() {
+	if pool.settings.HealthCheckInterval <= 0 || len(pool.upstreams) < 2 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(pool.settings.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			pool.CheckHealth()
+			select {
+			case <-pool.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckHealth runs the health check of every upstream once
+func (pool *UpstreamPool) CheckHealth() {
+	var wg sync.WaitGroup
+	for _, upstream := range pool.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			heartbeatType, serviceURL := pool.healthCheckTarget(upstream)
+			err := pool.checkHealth(heartbeatType, serviceURL)
+			pool.mutex.Lock()
+			defer pool.mutex.Unlock()
+			if upstream.healthy != (err == nil) {
+				zap.L().Info("service upstream health changed", zap.String("endpoint", upstream.Endpoint),
+					zap.Bool("healthy", err == nil), zap.Error(err))
+			}
+			upstream.healthy = err == nil
+		}()
+	}
+	wg.Wait()
+}
+
+// healthCheckTarget returns the heartbeat type and url of the upstream. The
+// heartbeat_endpoint of the upstream is used when set, otherwise the path of the
+// daemon heartbeat_endpoint is called on the upstream host. Without a heartbeat
+// endpoint the upstream is checked with TCP.
+func (pool *UpstreamPool) healthCheckTarget(upstream *Upstream) (heartbeatType string, serviceURL string) {
+	if upstream.HeartbeatEndpoint != "" {
+		return pool.heartbeatType, upstream.HeartbeatEndpoint
+	}
+	endpoint := upstream.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	upstreamURL, err := url.Parse(endpoint)
+	if err != nil {
+		return "tcp", upstream.Endpoint
+	}
+	switch pool.heartbeatType {
+	case "grpc":
+		return pool.heartbeatType, upstreamURL.Host
+	case "http", "https":
+		heartbeatURL, err := url.Parse(pool.heartbeatEndpoint)
+		if err != nil || pool.heartbeatEndpoint == "" {
+			return "tcp", upstream.Endpoint
+		}
+		heartbeatURL.Host = upstreamURL.Host
+		return pool.heartbeatType, heartbeatURL.String()
+	}
+	return "tcp", upstream.Endpoint
+}
+
+// Close stops the health checks
+func (pool *UpstreamPool) Close() {
+	pool.stopOnce.Do(func() { close(pool.stop) })
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestUpstreamPool(policy string, endpoints ...config.ServiceEndpoint) *UpstreamPool {
+	settings := &config.ServiceLoadBalancingSettings{
+		Policy:              policy,
+		ConsecutiveFailures: 2,
+		EjectionTime:        time.Minute,
+		Retries:             1,
+	}
+	return NewUpstreamPool(endpoints, settings, nil, nil, "", "")
+}
+
+func pickEndpoints(t *testing.T, pool *UpstreamPool, count int) (picked []string) {
+	for range count {
+		upstream, err := pool.Pick("/svc/add")
+		assert.Nil(t, err)
+		picked = append(picked, upstream.Endpoint)
+		pool.Done(upstream, "/svc/add", false)
+	}
+	return picked
+}
+
+func TestUpstreamPool_RoundRobin(t *testing.T) {
+	pool := newTestUpstreamPool(config.RoundRobinPolicy, config.ServiceEndpoint{Endpoint: "a"}, config.ServiceEndpoint{Endpoint: "b"})
+	assert.Equal(t, []string{"a", "b", "a", "b"}, pickEndpoints(t, pool, 4))
+}
+
+func TestUpstreamPool_Weighted(t *testing.T) {
+	pool := newTestUpstreamPool(config.WeightedPolicy,
+		config.ServiceEndpoint{Endpoint: "a", Weight: 2}, config.ServiceEndpoint{Endpoint: "b", Weight: 1})
+	assert.Equal(t, []string{"a", "b", "a", "a", "b", "a"}, pickEndpoints(t, pool, 6))
+}
+
+func TestUpstreamPool_LeastRequest(t *testing.T) {
+	pool := newTestUpstreamPool(config.LeastRequestPolicy, config.ServiceEndpoint{Endpoint: "a"}, config.ServiceEndpoint{Endpoint: "b"})
+	busy, _ := pool.Pick("/svc/add")
+	for range 3 {
+		upstream, err := pool.Pick("/svc/add")
+		assert.Nil(t, err)
+		assert.NotEqual(t, busy, upstream)
+		pool.Done(upstream, "/svc/add", false)
+	}
+}
+
+func TestUpstreamPool_Ejection(t *testing.T) {
+	now := time.Now()
+	pool := newTestUpstreamPool(config.RoundRobinPolicy, config.ServiceEndpoint{Endpoint: "a"}, config.ServiceEndpoint{Endpoint: "b"})
+	pool.now = func() time.Time { return now }
+	a := pool.Upstreams()[0]
+
+	for range 2 {
+		a.activeRequests++
+		pool.Done(a, "/svc/add", true)
+	}
+	assert.True(t, pool.States()[0].Ejected)
+	assert.Equal(t, []string{"b", "b", "b"}, pickEndpoints(t, pool, 3))
+
+	now = now.Add(2 * time.Minute)
+	assert.False(t, pool.States()[0].Ejected)
+	assert.ElementsMatch(t, []string{"a", "b"}, pickEndpoints(t, pool, 2))
+}
+
+func TestUpstreamPool_HealthCheck(t *testing.T) {
+	pool := newTestUpstreamPool(config.RoundRobinPolicy, config.ServiceEndpoint{Endpoint: "http://a:80"}, config.ServiceEndpoint{Endpoint: "http://b:80"})
+	pool.checkHealth = func(heartbeatType string, serviceURL string) error {
+		if serviceURL == "http://a:80" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	pool.CheckHealth()
+	assert.Equal(t, []string{"http://b:80", "http://b:80"}, pickEndpoints(t, pool, 2))
+
+	// all upstreams are unhealthy, the calls are still balanced between them
+	pool.checkHealth = func(heartbeatType string, serviceURL string) error { return errors.New("connection refused") }
+	pool.CheckHealth()
+	assert.Equal(t, []string{"http://a:80", "http://b:80"}, pickEndpoints(t, pool, 2))
+}
+
+func TestUpstreamPool_HealthCheckTarget(t *testing.T) {
+	pool := NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: "http://a:8000/api"}, {Endpoint: "b:9000", HeartbeatEndpoint: "http://b:9001/health"}},
+		&config.ServiceLoadBalancingSettings{}, nil, nil, "http", "http://localhost:8000/heartbeat")
+	heartbeatType, serviceURL := pool.healthCheckTarget(pool.Upstreams()[0])
+	assert.Equal(t, "http", heartbeatType)
+	assert.Equal(t, "http://a:8000/heartbeat", serviceURL)
+	heartbeatType, serviceURL = pool.healthCheckTarget(pool.Upstreams()[1])
+	assert.Equal(t, "http", heartbeatType)
+	assert.Equal(t, "http://b:9001/health", serviceURL)
+
+	pool.heartbeatType = "grpc"
+	_, serviceURL = pool.healthCheckTarget(pool.Upstreams()[0])
+	assert.Equal(t, "a:8000", serviceURL)
+
+	pool.heartbeatType = ""
+	heartbeatType, serviceURL = pool.healthCheckTarget(pool.Upstreams()[0])
+	assert.Equal(t, "tcp", heartbeatType)
+	assert.Equal(t, "http://a:8000/api", serviceURL)
+}
+
+func TestGrpcHandler_callUpstreamRetries(t *testing.T) {
+	pool := newTestUpstreamPool(config.RoundRobinPolicy, config.ServiceEndpoint{Endpoint: "a"}, config.ServiceEndpoint{Endpoint: "b"})
+	g := &grpcHandler{upstreams: pool, loadBalancing: pool.Settings()}
+	g.loadBalancing.IdempotentMethods = []string{"/svc/get"}
+
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	var called []string
+	call := func(ctx context.Context, upstream *Upstream) (string, bool, error) {
+		called = append(called, upstream.Endpoint)
+		if upstream.Endpoint == "a" {
+			return "", true, unavailable
+		}
+		return upstream.Endpoint, false, nil
+	}
+
+	result, attempts, err := callUpstream(g, context.Background(), "/svc/get", call)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", result)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, []string{"a", "b"}, called)
+
+	// not idempotent calls are not retried
+	called = nil
+	_, attempts, err = callUpstream(g, context.Background(), "/svc/set", call)
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, []string{"a"}, called)
+}
+
+func TestUpstreamPool_CircuitBreaker(t *testing.T) {
+	now := time.Now()
+	pool := NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: "a"}, {Endpoint: "b"}}, &config.ServiceLoadBalancingSettings{},
+		&config.ServiceCircuitBreakerSettings{FailureThreshold: 2, OpenTime: time.Minute, HalfOpenCalls: 1}, nil, "", "")
+	pool.now = func() time.Time { return now }
+	a, b := pool.Upstreams()[0], pool.Upstreams()[1]
+
+	for _, upstream := range []*Upstream{a, a, b, b} {
+		upstream.activeRequests++
+		pool.Done(upstream, "/svc/add", true)
+	}
+	assert.Equal(t, "open", pool.States()[0].Circuits["/svc/add"])
+	assert.Equal(t, ErrCircuitOpen, pool.Allow("/svc/add"))
+	_, err := pool.Pick("/svc/add")
+	assert.Equal(t, ErrCircuitOpen, err)
+
+	// the circuit is kept per method
+	assert.Nil(t, pool.Allow("/svc/sub"))
+	upstream, err := pool.Pick("/svc/sub")
+	assert.Nil(t, err)
+	pool.Done(upstream, "/svc/sub", false)
+
+	// after open_time a single trial call is let through
+	now = now.Add(2 * time.Minute)
+	assert.Nil(t, pool.Allow("/svc/add"))
+	trialA, err := pool.Pick("/svc/add")
+	assert.Nil(t, err)
+	trialB, err := pool.Pick("/svc/add")
+	assert.Nil(t, err)
+	assert.NotEqual(t, trialA, trialB)
+	assert.Equal(t, ErrCircuitOpen, pool.Allow("/svc/add"))
+
+	pool.Done(trialA, "/svc/add", false)
+	pool.Done(trialB, "/svc/add", true)
+	circuits := map[string]string{}
+	for _, state := range pool.States() {
+		circuits[state.Endpoint] = state.Circuits["/svc/add"]
+	}
+	assert.Equal(t, map[string]string{trialA.Endpoint: "closed", trialB.Endpoint: "open"}, circuits)
+	assert.Nil(t, pool.Allow("/svc/add"))
+}
+
+func TestUpstreamPool_Acquire(t *testing.T) {
+	pool := NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: "a"}}, &config.ServiceLoadBalancingSettings{}, nil,
+		&config.ServiceConcurrencyLimitSettings{MaxConcurrentCalls: 1, MaxQueuedCalls: 1, QueueTimeout: time.Second}, "", "")
+
+	release, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+
+	queued := make(chan error)
+	go func() {
+		releaseQueued, err := pool.Acquire(context.Background())
+		if err == nil {
+			releaseQueued()
+		}
+		queued <- err
+	}()
+	assert.Eventually(t, func() bool { return pool.queued.Load() == 1 }, time.Second, time.Millisecond)
+
+	// the queue is full
+	_, err = pool.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyCalls)
+
+	release()
+	assert.Nil(t, <-queued)
+
+	// the queued call waits for queue_timeout at most
+	release, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	defer release()
+	pool.limit.QueueTimeout = time.Millisecond
+	_, err = pool.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyCalls)
+}
+
+func TestUpstreamPool_AcquireSharedSlots(t *testing.T) {
+	pool := NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: "a"}, {Endpoint: "b"}}, &config.ServiceLoadBalancingSettings{}, nil,
+		&config.ServiceConcurrencyLimitSettings{MaxConcurrentCalls: 1, QueueTimeout: time.Second}, "", "")
+	a, b := pool.upstreams[0], pool.upstreams[1]
+	a.healthy = false
+
+	// the slots of the unhealthy upstream are used by the healthy one
+	for range 2 {
+		release, err := pool.Acquire(context.Background())
+		assert.Nil(t, err)
+		defer release()
+		upstream, err := pool.Pick("/svc/add")
+		assert.Nil(t, err)
+		assert.Equal(t, b, upstream)
+	}
+	_, err := pool.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyCalls)
+}
+
+func TestGrpcCircuitBreakerInterceptor(t *testing.T) {
+	pool := NewUpstreamPool([]config.ServiceEndpoint{{Endpoint: "a"}}, &config.ServiceLoadBalancingSettings{}, nil,
+		&config.ServiceConcurrencyLimitSettings{MaxConcurrentCalls: 1, QueueTimeout: time.Millisecond}, "", "")
+	release, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	defer release()
+
+	interceptor := GrpcCircuitBreakerInterceptor(pool)
+	stream := &serverStreamMock{context: context.Background()}
+	called := false
+	handler := func(srv any, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+
+	// the proxied call waits for the slot
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/svc/add"}, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.False(t, called)
+
+	// the methods of the daemon services don't reach the upstreams
+	err = interceptor(struct{}{}, stream, &grpc.StreamServerInfo{FullMethod: "/training.Daemon/upload_and_validate"}, handler)
+	assert.Nil(t, err)
+	assert.True(t, called)
+}
@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"buf.build/go/protovalidate"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// RequestValidator decodes the messages of the service methods with the proto
+// descriptors of the service and checks the required fields (proto2 required and
+// google.api.field_behavior = REQUIRED), the size limit and the buf.validate rules.
+type RequestValidator struct {
+	serviceMetaData *blockchain.ServiceMetadata
+	settings        *config.RequestValidationSettings
+	validator       protovalidate.Validator
+	// requiredFields caches the required fields of every message descriptor
+	requiredFields sync.Map
+}
+
+// NewRequestValidator returns the validator of the methods in the proto descriptors of the service
+func NewRequestValidator(serviceMetadata *blockchain.ServiceMetadata, settings *config.RequestValidationSettings) (*RequestValidator, error) {
+	validator, err := protovalidate.New()
+	if err != nil {
+		return nil, err
+	}
+	return &RequestValidator{
+		serviceMetaData: serviceMetadata,
+		settings:        settings,
+		validator:       validator,
+	}, nil
+}
+
+// NewServiceRequestValidator returns the validator configured by request_validation,
+// nil when the validation is disabled
+func NewServiceRequestValidator(serviceMetadata *blockchain.ServiceMetadata) (*RequestValidator, error) {
+	settings, err := config.GetRequestValidation()
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled {
+		return nil, nil
+	}
+	return NewRequestValidator(serviceMetadata, settings)
+}
+
+// method returns the descriptor of the method /package.Service/Method, nil when
+// the method isn't in the proto files of the service
+func (v *RequestValidator) method(fullMethod string) protoreflect.MethodDescriptor {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil
+	}
+	method := FindServiceMethod(v.serviceMetaData.ProtoDescriptors, serviceName, methodName)
+	if method == nil || string(method.Parent().FullName()) != serviceName {
+		return nil
+	}
+	return method
+}
+
+// ValidateRequest returns the InvalidArgument status if the request doesn't
+// decode as the input of the method or breaks its rules
+func (v *RequestValidator) ValidateRequest(method protoreflect.MethodDescriptor, data []byte) error {
+	if v.settings.MaxRequestBytes > 0 && len(data) > v.settings.MaxRequestBytes {
+		return status.Errorf(codes.InvalidArgument, "request of %v bytes exceeds the limit of %v bytes", len(data), v.settings.MaxRequestBytes)
+	}
+	request, err := v.decode(method.Input(), data)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "request is not a valid %v: %v", method.Input().FullName(), err)
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	v.checkRequired(request, "", &violations)
+	err = v.validator.Validate(request)
+	var validationError *protovalidate.ValidationError
+	if errors.As(err, &validationError) {
+		for _, violation := range validationError.Violations {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       protovalidate.FieldPathString(violation.Proto.GetField()),
+				Description: violation.Proto.GetMessage(),
+			})
+		}
+	} else if err != nil {
+		// the rules which can't be compiled or evaluated are the errors of the service api
+		zap.L().Warn("can't evaluate the validation rules", zap.String("message", string(method.Input().FullName())), zap.Error(err))
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		descriptions = append(descriptions, violation.Field+": "+violation.Description)
+	}
+	st := status.Newf(codes.InvalidArgument, "invalid %v: %v", method.Input().FullName(), strings.Join(descriptions, "; "))
+	return withDetails(st, &errdetails.BadRequest{FieldViolations: violations})
+}
+
+// ValidateResponse returns the Internal status if the response of the service
+// doesn't decode as the output of the method
+func (v *RequestValidator) ValidateResponse(method protoreflect.MethodDescriptor, data []byte) error {
+	if _, err := v.decode(method.Output(), data); err != nil {
+		zap.L().Warn("service returned an invalid response", zap.String("message", string(method.Output().FullName())), zap.Error(err))
+		return status.Errorf(codes.Internal, "service returned an invalid %v: %v", method.Output().FullName(), err)
+	}
+	return nil
+}
+
+// decode decodes the message of the service wire encoding, the missing required
+// fields are reported by checkRequired
+func (v *RequestValidator) decode(descriptor protoreflect.MessageDescriptor, data []byte) (*dynamicpb.Message, error) {
+	message := dynamicpb.NewMessage(descriptor)
+	if v.serviceMetaData.GetWireEncoding() == "json" {
+		return message, protojson.UnmarshalOptions{AllowPartial: true}.Unmarshal(data, message)
+	}
+	return message, proto.UnmarshalOptions{AllowPartial: true}.Unmarshal(data, message)
+}
+
+// checkRequired adds the violations of the required fields missing in the message and in its sub messages
+func (v *RequestValidator) checkRequired(message protoreflect.Message, prefix string, violations *[]*errdetails.BadRequest_FieldViolation) {
+	for _, field := range v.required(message.Descriptor()) {
+		if !message.Has(field) {
+			*violations = append(*violations, &errdetails.BadRequest_FieldViolation{
+				Field:       prefix + string(field.Name()),
+				Description: "value is required",
+			})
+		}
+	}
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		path := prefix + string(field.Name())
+		switch {
+		case field.IsMap():
+			if field.MapValue().Message() != nil {
+				value.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+					v.checkRequired(value.Message(), fmt.Sprintf("%v[%v].", path, key.String()), violations)
+					return true
+				})
+			}
+		case field.IsList():
+			if field.Message() != nil {
+				for i := range value.List().Len() {
+					v.checkRequired(value.List().Get(i).Message(), fmt.Sprintf("%v[%v].", path, i), violations)
+				}
+			}
+		case field.Message() != nil:
+			v.checkRequired(value.Message(), path+".", violations)
+		}
+		return true
+	})
+}
+
+// required returns the required fields of the message
+func (v *RequestValidator) required(descriptor protoreflect.MessageDescriptor) []protoreflect.FieldDescriptor {
+	if fields, ok := v.requiredFields.Load(descriptor); ok {
+		return fields.([]protoreflect.FieldDescriptor)
+	}
+	var required []protoreflect.FieldDescriptor
+	fields := descriptor.Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		if field.Cardinality() == protoreflect.Required || isRequiredByFieldBehavior(field) {
+			required = append(required, field)
+		}
+	}
+	v.requiredFields.Store(descriptor, required)
+	return required
+}
+
+func isRequiredByFieldBehavior(field protoreflect.FieldDescriptor) bool {
+	options, ok := field.Options().(*descriptorpb.FieldOptions)
+	if !ok || options == nil {
+		return false
+	}
+	// the compiled options keep the extension as unknown field or dynamic message,
+	// decode them again to get the generated type
+	raw, err := proto.Marshal(options)
+	if err != nil {
+		return false
+	}
+	options = &descriptorpb.FieldOptions{}
+	if err = (proto.UnmarshalOptions{Resolver: protoregistry.GlobalTypes}).Unmarshal(raw, options); err != nil {
+		return false
+	}
+	behaviors, _ := proto.GetExtension(options, annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	for _, behavior := range behaviors {
+		if behavior == annotations.FieldBehavior_REQUIRED {
+			return true
+		}
+	}
+	return false
+}
+
+// GrpcRequestValidationInterceptor rejects the requests which aren't valid inputs
+// of the method with InvalidArgument. It must precede the payment validation to
+// not charge the invalid calls. The methods which aren't in the proto files of the
+// service are not validated.
+func GrpcRequestValidationInterceptor(validator *RequestValidator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method := validator.method(info.FullMethod)
+		if method == nil {
+			return handler(srv, ss)
+		}
+		first := &codec.GrpcFrame{}
+		err := ss.RecvMsg(first)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == nil {
+			if err := validator.ValidateRequest(method, first.Data); err != nil {
+				zap.L().Debug("invalid request", zap.String("method", info.FullMethod), zap.Error(err))
+				return err
+			}
+		}
+		return handler(srv, &validatingServerStream{
+			ServerStream: ss,
+			validator:    validator,
+			method:       method,
+			first:        first,
+			firstErr:     err,
+			firstPending: true,
+		})
+	}
+}
+
+// validatingServerStream replays the validated first request and validates the
+// following requests, and the responses when validate_responses is set
+type validatingServerStream struct {
+	grpc.ServerStream
+	validator    *RequestValidator
+	method       protoreflect.MethodDescriptor
+	first        *codec.GrpcFrame
+	firstErr     error
+	firstPending bool
+}
+
+func (s *validatingServerStream) RecvMsg(m any) error {
+	frame, ok := m.(*codec.GrpcFrame)
+	if !ok {
+		return fmt.Errorf("validatingServerStream: unexpected message type %T, want *codec.GrpcFrame", m)
+	}
+	if s.firstPending {
+		s.firstPending = false
+		if s.firstErr != nil {
+			return s.firstErr
+		}
+		*frame = *s.first
+		return nil
+	}
+	if err := s.ServerStream.RecvMsg(frame); err != nil {
+		return err
+	}
+	return s.validator.ValidateRequest(s.method, frame.Data)
+}
+
+func (s *validatingServerStream) SendMsg(m any) error {
+	if frame, ok := m.(*codec.GrpcFrame); ok && s.validator.settings.ValidateResponses {
+		if err := s.validator.ValidateResponse(s.method, frame.Data); err != nil {
+			return err
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
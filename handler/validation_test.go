@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const validationTestProto = `
+syntax = "proto3";
+package users;
+import "google/api/field_behavior.proto";
+import "buf/validate/validate.proto";
+service Users {
+	rpc Create(User) returns (User);
+	rpc Import(stream User) returns (User);
+}
+message User {
+	string name = 1 [(google.api.field_behavior) = REQUIRED];
+	optional string email = 2 [(buf.validate.field).string.email = true];
+	int32 age = 3 [(buf.validate.field).int32 = { gte: 0, lte: 150 }];
+	Address address = 4;
+}
+message Address { string city = 1 [(google.api.field_behavior) = REQUIRED]; }
+`
+
+type validationTestStream struct {
+	serverStreamMock
+	requests [][]byte
+	sent     [][]byte
+}
+
+func (m *validationTestStream) RecvMsg(msg any) error {
+	if len(m.requests) == 0 {
+		return io.EOF
+	}
+	msg.(*codec.GrpcFrame).Data, m.requests = m.requests[0], m.requests[1:]
+	return nil
+}
+
+func (m *validationTestStream) SendMsg(msg any) error {
+	m.sent = append(m.sent, msg.(*codec.GrpcFrame).Data)
+	return nil
+}
+
+func newTestRequestValidator(t *testing.T, settings *config.RequestValidationSettings) (*RequestValidator, protoreflect.MessageDescriptor) {
+	serviceMetadata := &blockchain.ServiceMetadata{ProtoDescriptors: getDescriptors(t, map[string]string{"users.proto": validationTestProto})}
+	validator, err := NewRequestValidator(serviceMetadata, settings)
+	require.Nil(t, err)
+	method := validator.method("/users.Users/Create")
+	require.NotNil(t, method)
+	return validator, method.Input()
+}
+
+func newTestUser(t *testing.T, descriptor protoreflect.MessageDescriptor, fields map[string]any) []byte {
+	user := dynamicpb.NewMessage(descriptor)
+	for name, value := range fields {
+		field := descriptor.Fields().ByName(protoreflect.Name(name))
+		if name == "address" {
+			address := dynamicpb.NewMessage(field.Message())
+			address.Set(field.Message().Fields().ByName("city"), protoreflect.ValueOf(value))
+			user.Set(field, protoreflect.ValueOfMessage(address))
+			continue
+		}
+		user.Set(field, protoreflect.ValueOf(value))
+	}
+	data, err := proto.Marshal(user)
+	require.Nil(t, err)
+	return data
+}
+
+func TestRequestValidator_ValidateRequest(t *testing.T) {
+	validator, user := newTestRequestValidator(t, &config.RequestValidationSettings{Enabled: true, MaxRequestBytes: 64})
+	method := validator.method("/users.Users/Create")
+	assert.Nil(t, validator.method("/users.Users/Missing"))
+	assert.Nil(t, validator.method("/Users/Create"))
+
+	valid := newTestUser(t, user, map[string]any{"name": "alice", "email": "alice@example.com", "age": int32(30), "address": "Paris"})
+	assert.Nil(t, validator.ValidateRequest(method, valid))
+
+	err := validator.ValidateRequest(method, []byte{0xff, 0xff})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = validator.ValidateRequest(method, make([]byte, 65))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = validator.ValidateRequest(method, newTestUser(t, user, map[string]any{"email": "not an email", "age": int32(200), "address": ""}))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		for _, violation := range detail.(*errdetails.BadRequest).GetFieldViolations() {
+			fields = append(fields, violation.GetField())
+		}
+	}
+	assert.ElementsMatch(t, []string{"name", "address.city", "email", "age"}, fields)
+}
+
+func TestGrpcRequestValidationInterceptor(t *testing.T) {
+	validator, user := newTestRequestValidator(t, &config.RequestValidationSettings{Enabled: true, ValidateResponses: true})
+	interceptor := GrpcRequestValidationInterceptor(validator)
+	valid := newTestUser(t, user, map[string]any{"name": "alice"})
+	invalid := newTestUser(t, user, map[string]any{"age": int32(-1)})
+
+	called := false
+	handler := func(srv any, stream grpc.ServerStream) error {
+		called = true
+		for {
+			frame := &codec.GrpcFrame{}
+			if err := stream.RecvMsg(frame); err != nil {
+				if err == io.EOF {
+					return stream.SendMsg(&codec.GrpcFrame{Data: valid})
+				}
+				return err
+			}
+		}
+	}
+
+	// the invalid first request is rejected before the next interceptors are called
+	stream := &validationTestStream{serverStreamMock: serverStreamMock{context: context.Background()}, requests: [][]byte{invalid}}
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/users.Users/Create"}, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.False(t, called)
+
+	stream = &validationTestStream{serverStreamMock: serverStreamMock{context: context.Background()}, requests: [][]byte{valid}}
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/users.Users/Create"}, handler)
+	assert.Nil(t, err)
+	assert.True(t, called)
+	assert.Equal(t, [][]byte{valid}, stream.sent)
+
+	// the following requests of the stream are validated too
+	stream = &validationTestStream{serverStreamMock: serverStreamMock{context: context.Background()}, requests: [][]byte{valid, invalid}}
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/users.Users/Import"}, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the empty client stream is passed to the handler
+	stream = &validationTestStream{serverStreamMock: serverStreamMock{context: context.Background()}}
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/users.Users/Import"}, handler)
+	assert.Nil(t, err)
+
+	// the responses which aren't the output type fail
+	badResponse := func(srv any, stream grpc.ServerStream) error {
+		return stream.SendMsg(&codec.GrpcFrame{Data: []byte{0xff, 0xff}})
+	}
+	stream = &validationTestStream{serverStreamMock: serverStreamMock{context: context.Background()}, requests: [][]byte{valid}}
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/users.Users/Create"}, badResponse)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Empty(t, stream.sent)
+
+	// the methods unknown to the proto files aren't validated
+	stream = &validationTestStream{serverStreamMock: serverStreamMock{context: context.Background()}, requests: [][]byte{{0xff}}}
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/other.Service/Call"}, func(srv any, stream grpc.ServerStream) error {
+		return stream.RecvMsg(&codec.GrpcFrame{})
+	})
+	assert.Nil(t, err)
+}
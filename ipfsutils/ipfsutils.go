@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/kubo/client/rpc"
 	"github.com/singnet/snet-daemon/v6/config"
@@ -44,8 +45,8 @@ func GetIpfsFile(hash string) (content []byte, err error) {
 	}(resp)
 
 	if resp.Error != nil {
-		zap.L().Error("error executing the cat command in ipfs", zap.String("hashFromMetaData", hash), zap.Error(err))
-		return nil, err
+		zap.L().Error("error executing the cat command in ipfs", zap.String("hashFromMetaData", hash), zap.Error(resp.Error))
+		return nil, resp.Error
 	}
 	fileContent, err := io.ReadAll(resp.Output)
 	if err != nil {
@@ -71,6 +72,26 @@ func GetIpfsFile(hash string) (content []byte, err error) {
 	return fileContent, nil
 }
 
+// AddFile adds the content to the IPFS node and returns its CID
+func AddFile(ctx context.Context, content io.Reader) (cID string, err error) {
+	ipfsClient := GetIPFSClient()
+	path, err := ipfsClient.Unixfs().Add(ctx, files.NewReaderFile(content))
+	if err != nil {
+		zap.L().Error("error executing the add command in ipfs", zap.Error(err))
+		return "", err
+	}
+	return path.RootCid().String(), nil
+}
+
+// Version returns the version of the IPFS node of ipfs_endpoint, it checks the node is reachable
+func Version(ctx context.Context) (version string, err error) {
+	var reply struct{ Version string }
+	if err = GetIPFSClient().Request("version").Exec(ctx, &reply); err != nil {
+		return "", err
+	}
+	return reply.Version, nil
+}
+
 func GetIPFSClient() *rpc.HttpApi {
 	httpClient := http.Client{
 		Timeout: time.Duration(config.GetInt(config.IpfsTimeout)) * time.Second,
@@ -0,0 +1,155 @@
+package ipfsutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"go.uber.org/zap"
+)
+
+// localIpfsVersion is reported by the version method, the RPC client checks it before adding the files
+const localIpfsVersion = "0.43.0"
+
+// LocalIPFS is an in-memory replacement of the IPFS node for the local development,
+// it serves the cat, add and version methods of the IPFS RPC API which are used by the daemon
+type LocalIPFS struct {
+	mutex sync.RWMutex
+	files map[string][]byte
+}
+
+func NewLocalIPFS() *LocalIPFS {
+	return &LocalIPFS{files: make(map[string][]byte)}
+}
+
+// LocalCID returns the CID of the content, the raw CIDv1 with sha2-256 hash
+func LocalCID(content []byte) (cid.Cid, error) {
+	return cid.V1Builder{Codec: cid.Raw, MhType: multihash.SHA2_256}.Sum(content)
+}
+
+// Add stores the content and returns its CID
+func (ipfs *LocalIPFS) Add(content []byte) (cID string, err error) {
+	c, err := LocalCID(content)
+	if err != nil {
+		return "", err
+	}
+	ipfs.mutex.Lock()
+	defer ipfs.mutex.Unlock()
+	ipfs.files[c.String()] = content
+	return c.String(), nil
+}
+
+// AddDir stores the regular files of the directory, it returns the CIDs by the file names
+func (ipfs *LocalIPFS) AddDir(dir string) (cIDs map[string]string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	cIDs = make(map[string]string)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if cIDs[entry.Name()], err = ipfs.Add(content); err != nil {
+			return nil, err
+		}
+	}
+	return cIDs, nil
+}
+
+// Get returns the content by its CID
+func (ipfs *LocalIPFS) Get(cID string) (content []byte, ok bool) {
+	ipfs.mutex.RLock()
+	defer ipfs.mutex.RUnlock()
+	content, ok = ipfs.files[cID]
+	return
+}
+
+func (ipfs *LocalIPFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v0/cat":
+		ipfs.cat(w, r)
+	case "/api/v0/add":
+		ipfs.add(w, r)
+	case "/api/v0/version":
+		writeLocalResponse(w, map[string]string{"Version": localIpfsVersion})
+	default:
+		writeLocalError(w, http.StatusNotFound, fmt.Errorf("unknown command %q", r.URL.Path))
+	}
+}
+
+func (ipfs *LocalIPFS) cat(w http.ResponseWriter, r *http.Request) {
+	arg := strings.TrimPrefix(r.URL.Query().Get("arg"), "/ipfs/")
+	c, err := cid.Parse(arg)
+	if err != nil {
+		writeLocalError(w, http.StatusBadRequest, err)
+		return
+	}
+	content, ok := ipfs.Get(c.String())
+	if !ok {
+		zap.L().Warn("file isn't found in local IPFS", zap.String("cid", arg))
+		writeLocalError(w, http.StatusInternalServerError, fmt.Errorf("file %v isn't found", arg))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write(content)
+}
+
+func (ipfs *LocalIPFS) add(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeLocalError(w, http.StatusBadRequest, err)
+		return
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			writeLocalError(w, http.StatusBadRequest, fmt.Errorf("no file to add"))
+			return
+		}
+		if err != nil {
+			writeLocalError(w, http.StatusBadRequest, err)
+			return
+		}
+		if part.Header.Get("Content-Type") == "application/x-directory" {
+			continue
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			writeLocalError(w, http.StatusBadRequest, err)
+			return
+		}
+		cID, err := ipfs.Add(content)
+		if err != nil {
+			writeLocalError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeLocalResponse(w, map[string]string{"Name": part.FileName(), "Hash": cID, "Size": fmt.Sprint(len(content))})
+		return
+	}
+}
+
+func writeLocalResponse(w http.ResponseWriter, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func writeLocalError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"Message": err.Error(), "Code": 0, "Type": "error"})
+}
@@ -0,0 +1,49 @@
+package ipfsutils
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalIPFS(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "service_metadata.json"), []byte(`{"version": 1}`), 0600))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "protos"), 0700))
+
+	ipfs := NewLocalIPFS()
+	cIDs, err := ipfs.AddDir(dir)
+	require.Nil(t, err)
+	require.Len(t, cIDs, 1)
+
+	server := httptest.NewServer(ipfs)
+	defer server.Close()
+	endpoint := config.GetString(config.IpfsEndpoint)
+	config.Vip().Set(config.IpfsEndpoint, server.URL)
+	defer config.Vip().Set(config.IpfsEndpoint, endpoint)
+
+	version, err := Version(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, localIpfsVersion, version)
+
+	content, err := ReadFile("ipfs://" + cIDs["service_metadata.json"])
+	assert.Nil(t, err)
+	assert.Equal(t, `{"version": 1}`, string(content))
+
+	cID, err := AddFile(context.Background(), bytes.NewReader([]byte("dataset")))
+	require.Nil(t, err)
+	content, err = GetIpfsFile(cID)
+	assert.Nil(t, err)
+	assert.Equal(t, "dataset", string(content))
+
+	missing, _ := LocalCID([]byte("missing"))
+	_, err = GetIpfsFile(missing.String())
+	assert.NotNil(t, err)
+}
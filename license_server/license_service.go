@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/storage"
 )
 
@@ -93,7 +94,7 @@ func (h *LockingLicenseService) UpdateLicenseUsage(channelId *big.Int, serviceId
 		conditionFunc = IncrementRefundUsage
 
 	default:
-		return fmt.Errorf("unknown update type %v", updateUsageType)
+		return errs.ErrLicenseUsageType.New("unknown update type %v", updateUsageType)
 	}
 
 	typedUpdateFunc := func(conditionValues []storage.TypedKeyValueData) (update []storage.TypedKeyValueData, ok bool, err error) {
@@ -150,7 +151,7 @@ func convertTypedDataToLicenseDataUsage(data []storage.TypedKeyValueData) (new *
 		} else if strings.Compare(key.UsageType, REFUND) == 0 {
 			usageData.Refund = data.Usage
 		} else {
-			return nil, fmt.Errorf("unknown usage type %v", key.UsageType)
+			return nil, errs.ErrLicenseUsageType.New("unknown usage type %v", key.UsageType)
 		}
 	}
 	return usageData, nil
@@ -186,7 +187,8 @@ var (
 		if incrementUsage.Cmp(big.NewInt(0)) > 0 {
 			updateLicenseUsageData(newState, usageKey, incrementUsage)
 			if newState.Used.GetUsage().Cmp(oldState.Planned.GetUsage().Add(oldState.Planned.GetUsage(), oldState.Refund.GetUsage())) > 0 {
-				return nil, fmt.Errorf("usage exceeded on channel Id %v", oldState.ChannelID)
+				return nil, errs.ErrLicenseUsageExceeded.New("usage exceeded on channel Id %v", oldState.ChannelID).
+					With("channel_id", oldState.ChannelID)
 			}
 		} else {
 			newState.UpdateUsageType = USED
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -114,6 +115,28 @@ func tcpPingService(serviceURL string) error {
 	return nil
 }
 
+// CheckServiceHealth calls the heartbeat of the given type (grpc, http, https or tcp)
+// and returns an error if the service is not serving. An empty or "none" type pings the
+// service with TCP.
+func CheckServiceHealth(heartbeatType string, serviceURL string) error {
+	switch heartbeatType {
+	case "grpc":
+		response, err := callGrpcServiceHeartbeat(serviceURL)
+		if err != nil {
+			return err
+		}
+		if response != grpc_health_v1.HealthCheckResponse_SERVING {
+			return fmt.Errorf("service is %v", response)
+		}
+		return nil
+	case "http", "https":
+		_, err := callHTTPServiceHeartbeat(serviceURL)
+		return err
+	default:
+		return tcpPingService(serviceURL)
+	}
+}
+
 // calls the corresponding the service to send the registration information
 func callRegisterService(daemonID string, serviceURL string) (status bool) {
 	//Send the Daemon ID and the Network ID to register the Daemon
@@ -7,7 +7,6 @@ package metrics
 
 import (
 	"context"
-	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
@@ -58,6 +57,59 @@ type DaemonHeartbeat struct {
 	CurrentBlock             func() (*big.Int, error)                   `json:"-"`
 	TrainingMetadata         func() (*training.TrainingMetadata, error) `json:"-"`
 	TrainingMetadataData     *training.TrainingMetadata                 `json:"trainingMetadata,omitempty"`
+	Upstreams                []UpstreamState                            `json:"upstreams,omitempty"`
+	ResponseCache            *ResponseCacheStats                        `json:"responseCache,omitempty"`
+	UpstreamRetries          *UpstreamRetryStats                        `json:"upstreamRetries,omitempty"`
+}
+
+// UpstreamState is the state of the service upstream reported in the heartbeat,
+// Circuits has the circuit breaker state per method
+type UpstreamState struct {
+	Endpoint       string            `json:"endpoint"`
+	Healthy        bool              `json:"healthy"`
+	Ejected        bool              `json:"ejected"`
+	ActiveRequests int               `json:"activeRequests"`
+	Circuits       map[string]string `json:"circuits,omitempty"`
+}
+
+var upstreamStates func() []UpstreamState
+
+// SetUpstreamStatesProvider sets the function returning the upstreams state for the heartbeat
+func SetUpstreamStatesProvider(provider func() []UpstreamState) {
+	upstreamStates = provider
+}
+
+// ResponseCacheStats is the state of the response cache reported in the heartbeat,
+// Entries and Bytes are reported by the memory cache only
+type ResponseCacheStats struct {
+	Backend   string `json:"backend"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Stores    uint64 `json:"stores"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries,omitempty"`
+	Bytes     int    `json:"bytes,omitempty"`
+}
+
+var responseCacheStats func() *ResponseCacheStats
+
+// SetResponseCacheStatsProvider sets the function returning the response cache stats for the heartbeat
+func SetResponseCacheStatsProvider(provider func() *ResponseCacheStats) {
+	responseCacheStats = provider
+}
+
+// UpstreamRetryStats counts the calls of the service repeated by the retry
+// policies, Hedges are the attempts sent before the previous one responded
+type UpstreamRetryStats struct {
+	Retries uint64 `json:"retries"`
+	Hedges  uint64 `json:"hedges"`
+}
+
+var upstreamRetryStats func() *UpstreamRetryStats
+
+// SetUpstreamRetryStatsProvider sets the function returning the retry stats for the heartbeat
+func SetUpstreamRetryStatsProvider(provider func() *UpstreamRetryStats) {
+	upstreamRetryStats = provider
 }
 
 func (service *DaemonHeartbeat) List(ctx context.Context, request *grpc_health_v1.HealthListRequest) (*grpc_health_v1.HealthListResponse, error) {
@@ -101,7 +153,7 @@ func ValidateHeartbeatConfig(heartbeatType, heartbeatEndpoint string) error {
 // getStorageCertificateDetails returns the storage certificate details
 func getStorageCertificateDetails() (cert StorageClientCert) {
 	cert = StorageClientCert{}
-	certificate, err := tls.LoadX509KeyPair(config.GetString(config.PaymentChannelCertPath), config.GetString(config.PaymentChannelKeyPath))
+	certificate, err := config.LoadX509KeyPair(config.PaymentChannelCertPath, config.PaymentChannelKeyPath)
 	if err != nil {
 		zap.L().Error("unable to load specific SSL X509 keypair for storage certificate", zap.Error(err))
 		return
@@ -147,6 +199,18 @@ func GetHeartbeat(serviceEndpoint string, serviceHeartbeatURL string, heartbeatT
 		heartbeat.TrainingMetadataData = md
 	}
 
+	if upstreamStates != nil {
+		heartbeat.Upstreams = upstreamStates()
+	}
+
+	if responseCacheStats != nil {
+		heartbeat.ResponseCache = responseCacheStats()
+	}
+
+	if upstreamRetryStats != nil {
+		heartbeat.UpstreamRetries = upstreamRetryStats()
+	}
+
 	var curResp = &HeartStatus{Status: "NOT_SERVING", ServiceID: serviceID}
 	switch heartbeatType {
 	case "grpc":
@@ -213,7 +277,7 @@ func HeartbeatHandler(rw http.ResponseWriter, trainingMetadata func() (*training
 	heartbeatType := config.GetString(config.ServiceHeartbeatType)
 	serviceURL := config.GetString(config.HeartbeatServiceEndpoint)
 	serviceID := config.GetString(config.ServiceId)
-	heartbeat, _ := GetHeartbeat(config.GetString(config.ServiceEndpointKey), serviceURL, heartbeatType, serviceID, trainingMetadata, dynamicPricing, currentBlock)
+	heartbeat, _ := GetHeartbeat(config.GetServiceEndpoint(), serviceURL, heartbeatType, serviceID, trainingMetadata, dynamicPricing, currentBlock)
 	err := json.NewEncoder(rw).Encode(heartbeat)
 	if err != nil {
 		zap.L().Info("Failed to write heartbeat message.", zap.Error(err))
@@ -223,7 +287,7 @@ func HeartbeatHandler(rw http.ResponseWriter, trainingMetadata func() (*training
 // Check implements `service Health`.
 func (service *DaemonHeartbeat) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
 
-	heartbeat, err := GetHeartbeat(config.GetString(config.ServiceEndpointKey), config.GetString(config.HeartbeatServiceEndpoint), config.GetString(config.ServiceHeartbeatType),
+	heartbeat, err := GetHeartbeat(config.GetServiceEndpoint(), config.GetString(config.HeartbeatServiceEndpoint), config.GetString(config.ServiceHeartbeatType),
 		config.GetString(config.ServiceId), service.TrainingMetadata, service.DynamicPricing, service.CurrentBlock)
 
 	if err != nil {
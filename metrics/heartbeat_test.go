@@ -183,6 +183,48 @@ func (suite *HeartBeatTestSuite) Test_GetHeartbeat() {
 		"Unexpected service heartbeat")
 }
 
+func (suite *HeartBeatTestSuite) Test_GetHeartbeatUpstreams() {
+	SetUpstreamStatesProvider(func() []UpstreamState {
+		return []UpstreamState{{Endpoint: "http://localhost:5001", Healthy: true, Circuits: map[string]string{"/svc/add": "open"}}}
+	})
+	defer SetUpstreamStatesProvider(nil)
+
+	serviceURL := suite.serviceURL + "/heartbeat"
+	dHeartbeat, _ := GetHeartbeat(serviceURL, serviceURL, "http", "SERVICE001", suite.trainingMD, nil, suite.currentBlock)
+	assert.Equal(suite.T(), 1, len(dHeartbeat.Upstreams))
+	assert.Equal(suite.T(), "open", dHeartbeat.Upstreams[0].Circuits["/svc/add"])
+
+	heartbeatJSON, err := json.Marshal(dHeartbeat)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(heartbeatJSON), `"upstreams":[{"endpoint":"http://localhost:5001","healthy":true,"ejected":false,"activeRequests":0,"circuits":{"/svc/add":"open"}}]`)
+}
+
+func (suite *HeartBeatTestSuite) Test_GetHeartbeatResponseCache() {
+	SetResponseCacheStatsProvider(func() *ResponseCacheStats {
+		return &ResponseCacheStats{Backend: "memory", Hits: 3, Misses: 1, Stores: 1, Entries: 1, Bytes: 10}
+	})
+	defer SetResponseCacheStatsProvider(nil)
+
+	serviceURL := suite.serviceURL + "/heartbeat"
+	dHeartbeat, _ := GetHeartbeat(serviceURL, serviceURL, "http", "SERVICE001", suite.trainingMD, nil, suite.currentBlock)
+	heartbeatJSON, err := json.Marshal(dHeartbeat)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(heartbeatJSON), `"responseCache":{"backend":"memory","hits":3,"misses":1,"stores":1,"evictions":0,"entries":1,"bytes":10}`)
+}
+
+func (suite *HeartBeatTestSuite) Test_GetHeartbeatUpstreamRetries() {
+	SetUpstreamRetryStatsProvider(func() *UpstreamRetryStats {
+		return &UpstreamRetryStats{Retries: 2, Hedges: 1}
+	})
+	defer SetUpstreamRetryStatsProvider(nil)
+
+	serviceURL := suite.serviceURL + "/heartbeat"
+	dHeartbeat, _ := GetHeartbeat(serviceURL, serviceURL, "http", "SERVICE001", suite.trainingMD, nil, suite.currentBlock)
+	heartbeatJSON, err := json.Marshal(dHeartbeat)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(heartbeatJSON), `"upstreamRetries":{"retries":2,"hedges":1}`)
+}
+
 func (suite *HeartBeatTestSuite) validateHeartbeat(dHeartbeat DaemonHeartbeat) {
 	assert.NotNil(suite.T(), dHeartbeat, "heartbeat must not be nil")
 
@@ -121,7 +121,11 @@ func SignMessageForMetering(req *http.Request, commonStats *CommonStats, current
 }
 
 func getPrivateKeyForMetering() (privateKey *ecdsa.PrivateKey, err error) {
-	if privateKeyString := config.GetString(config.PvtKeyForMetering); privateKeyString != "" {
+	privateKeyString, err := config.GetSecret(config.PvtKeyForMetering)
+	if err != nil {
+		return nil, err
+	}
+	if privateKeyString != "" {
 		privateKey, err = crypto.HexToECDSA(privateKeyString)
 		if err != nil {
 			return nil, err
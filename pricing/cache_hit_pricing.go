@@ -0,0 +1,52 @@
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/handler"
+)
+
+// CacheHitPrice is the price of the calls served from the response cache, set
+// per method by hit_price_in_cogs of response_cache
+type CacheHitPrice struct {
+	// Service/Method is the key and value is the price
+	methodToPriceMap map[string]*big.Int
+}
+
+func (priceType CacheHitPrice) GetPrice(GrpcContext *handler.GrpcStreamContext) (price *big.Int, err error) {
+	methodName := GrpcContext.Info.FullMethod
+	if price, ok := priceType.methodToPriceMap[methodName]; !ok {
+		return nil, fmt.Errorf("cache hit price is not defined for the Method %v", methodName)
+	} else {
+		return price, nil
+	}
+}
+
+func (priceType CacheHitPrice) GetPriceType() string {
+	return CACHE_HIT_PRICING
+}
+
+// hasPrice returns true if the hits of the method have their own price
+func (priceType CacheHitPrice) hasPrice(fullMethod string) bool {
+	_, ok := priceType.methodToPriceMap[fullMethod]
+	return ok
+}
+
+// newCacheHitPrice returns the prices of the cache hits, nil when no method has one
+func newCacheHitPrice(settings *config.ResponseCacheSettings) *CacheHitPrice {
+	if !settings.Enabled {
+		return nil
+	}
+	priceType := &CacheHitPrice{methodToPriceMap: make(map[string]*big.Int)}
+	for _, method := range settings.Methods {
+		if method.HitPriceInCogs != nil {
+			priceType.methodToPriceMap[method.Method] = new(big.Int).SetUint64(*method.HitPriceInCogs)
+		}
+	}
+	if len(priceType.methodToPriceMap) == 0 {
+		return nil
+	}
+	return priceType
+}
@@ -0,0 +1,93 @@
+package pricing
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// cacheTestStream is the unary call sending the request
+type cacheTestStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	request []byte
+}
+
+func (s *cacheTestStream) Context() context.Context     { return s.ctx }
+func (s *cacheTestStream) SetHeader(metadata.MD) error  { return nil }
+func (s *cacheTestStream) SendHeader(metadata.MD) error { return nil }
+func (s *cacheTestStream) SetTrailer(metadata.MD)       {}
+func (s *cacheTestStream) SendMsg(any) error            { return nil }
+func (s *cacheTestStream) RecvMsg(m any) error {
+	if s.request == nil {
+		return io.EOF
+	}
+	m.(*codec.GrpcFrame).Data, s.request = s.request, nil
+	return nil
+}
+
+func TestCacheHitPrice_GetPrice(t *testing.T) {
+	defer config.Vip().Set(config.ResponseCacheKey, config.Vip().Get(config.ResponseCacheKey))
+	config.Vip().Set(config.ResponseCacheKey, map[string]any{
+		"enabled": true,
+		"methods": []any{
+			map[string]any{"method": "/example_service.Calculator/add", "hit_price_in_cogs": 1},
+			map[string]any{"method": "/example_service.Calculator/mul"},
+		},
+	})
+
+	settings, err := config.GetResponseCache()
+	require.Nil(t, err)
+	cacheHit := newCacheHitPrice(settings)
+	require.NotNil(t, cacheHit)
+	assert.Equal(t, CACHE_HIT_PRICING, cacheHit.GetPriceType())
+
+	// the metadata without pricing has the empty default price model
+	metadata := &blockchain.ServiceMetadata{}
+	pricing := &PricingStrategy{serviceMetaData: metadata}
+	pricing.pricingTypes = map[string]PriceType{"": &FixedPrice{priceInCogs: big.NewInt(2)}}
+	pricing.AddPricingTypes(cacheHit)
+
+	cache := handler.NewResponseCache(settings, metadata, nil)
+	lookup := handler.GrpcResponseCacheLookupInterceptor(cache)
+	serve := handler.GrpcResponseCacheInterceptor(cache)
+
+	// call returns the price of the call as the payment validation computes it
+	call := func(method string) *big.Int {
+		var price *big.Int
+		stream := &cacheTestStream{ctx: context.Background(), request: []byte{1}}
+		info := &grpc.StreamServerInfo{FullMethod: method}
+		err := lookup(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+			var priceErr error
+			price, priceErr = pricing.GetPrice(&handler.GrpcStreamContext{Info: info, InStream: ss})
+			require.Nil(t, priceErr)
+			// the full price is the price of the method for the hits too
+			fullPrice, priceErr := pricing.GetFullPrice(&handler.GrpcStreamContext{Info: info, InStream: ss})
+			require.Nil(t, priceErr)
+			assert.Equal(t, big.NewInt(2), fullPrice)
+			return serve(srv, ss, info, func(srv any, ss grpc.ServerStream) error {
+				return ss.SendMsg(&codec.GrpcFrame{Data: []byte{2}})
+			})
+		})
+		require.Nil(t, err)
+		return price
+	}
+
+	// the default price is charged for the call of the service, the hit price for the cached response
+	assert.Equal(t, big.NewInt(2), call("/example_service.Calculator/add"))
+	assert.Equal(t, big.NewInt(1), call("/example_service.Calculator/add"))
+	// the hits of the method without hit price are charged the price of the method
+	assert.Equal(t, big.NewInt(2), call("/example_service.Calculator/mul"))
+	assert.Equal(t, big.NewInt(2), call("/example_service.Calculator/mul"))
+	assert.Equal(t, uint64(2), cache.Stats().Hits)
+}
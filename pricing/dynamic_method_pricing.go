@@ -42,7 +42,7 @@ func (priceType *DynamicMethodPrice) checkForDynamicPricing(
 		return nil, fmt.Errorf("Unable to get the method Name from the incoming request")
 	}
 	//[TODO]: get grpc options standardized rather than doing then everytime
-	passThroughURL, err := url.Parse(config.GetString(config.ServiceEndpointKey))
+	passThroughURL, err := url.Parse(config.GetServiceEndpoint())
 	if err != nil {
 		zap.L().Error(err.Error(), methodNameField)
 		return nil, err
@@ -10,6 +10,7 @@ const (
 	FIXED_METHOD_PRICING = "fixed_price_per_method"
 	FIXED_PRICING        = "fixed_price"
 	DYNAMIC_PRICING      = "dynamic_pricing"
+	CACHE_HIT_PRICING    = "cache_hit_price"
 )
 
 // Based on the request passed, a particular strategy will be picked up for processing
@@ -1,6 +1,7 @@
 package pricing
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"strings"
@@ -19,10 +20,16 @@ type PricingStrategy struct {
 }
 
 // Figure out which price type is to be used
-func (pricing PricingStrategy) determinePricingApplicable(fullMethod string) (priceType PriceType, err error) {
+func (pricing PricingStrategy) determinePricingApplicable(ctx context.Context, fullMethod string) (priceType PriceType, err error) {
 	// For future, there could be multiple pricingTypes to select from and this method will help decide which pricing to pick
 	// but for now, we just have one pricing Type (either Fixed Price or Fixed price per Method)
 
+	// The calls served from the response cache have their own price when it's set for the method
+	if cacheHit, ok := pricing.pricingTypes[CACHE_HIT_PRICING].(*CacheHitPrice); ok && cacheHit.hasPrice(fullMethod) &&
+		ctx != nil && handler.IsResponseCacheHit(ctx) {
+		return cacheHit, nil
+	}
+
 	if config.GetBool(config.EnableDynamicPricing) {
 		// Use Dynamic pricing ONLY when you find the mapped price method to be called.
 		if _, ok := pricing.serviceMetaData.GetDynamicPricingMethodAssociated(fullMethod); ok {
@@ -55,7 +62,21 @@ func (pricing *PricingStrategy) AddPricingTypes(priceType PriceType) {
 
 func (pricing PricingStrategy) GetPrice(GrpcContext *handler.GrpcStreamContext) (price *big.Int, err error) {
 	// Based on the input request, determine which price type is to be used
-	if priceType, err := pricing.determinePricingApplicable(GrpcContext.Info.FullMethod); err != nil {
+	var ctx context.Context
+	if GrpcContext.InStream != nil {
+		ctx = GrpcContext.InStream.Context()
+	}
+	if priceType, err := pricing.determinePricingApplicable(ctx, GrpcContext.Info.FullMethod); err != nil {
+		return nil, err
+	} else {
+		return priceType.GetPrice(GrpcContext)
+	}
+}
+
+// GetFullPrice returns the price of the call as if it weren't served from the
+// response cache, the client can't know if the call is a cache hit when it pays
+func (pricing PricingStrategy) GetFullPrice(GrpcContext *handler.GrpcStreamContext) (price *big.Int, err error) {
+	if priceType, err := pricing.determinePricingApplicable(nil, GrpcContext.Info.FullMethod); err != nil {
 		return nil, err
 	} else {
 		return priceType.GetPrice(GrpcContext)
@@ -75,6 +96,11 @@ func (pricing *PricingStrategy) initFromMetaData(metadata *blockchain.ServiceMet
 		priceType = methodPricing
 	}
 	pricing.AddPricingTypes(priceType)
+	if settings, cacheErr := config.GetResponseCache(); cacheErr == nil {
+		if cacheHit := newCacheHitPrice(settings); cacheHit != nil {
+			pricing.AddPricingTypes(cacheHit)
+		}
+	}
 	if config.GetBool(config.EnableDynamicPricing) {
 		pricing.AddPricingTypes(&DynamicMethodPrice{
 			serviceMetaData: metadata,
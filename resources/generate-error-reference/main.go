@@ -0,0 +1,15 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/singnet/snet-daemon/v6/errs"
+)
+
+// Generate the reference of the daemon errors from the error catalogue
+func main() {
+	if err := os.WriteFile("README.md", []byte(errs.Reference()), 0644); err != nil {
+		log.Fatalf("Failed to write error reference: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/singnet/snet-daemon/v6/storage"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// autocertStorageCache keeps the account key and the certificates of autocert
+// in the atomic storage, so the daemon replicas of the group share them
+// instead of requesting the certificate each.
+type autocertStorageCache struct {
+	storage storage.AtomicStorage
+}
+
+func newAutocertStorageCache(atomicStorage storage.AtomicStorage) autocert.Cache {
+	return &autocertStorageCache{storage: storage.NewPrefixedAtomicStorage(atomicStorage, "/autocert")}
+}
+
+func (cache *autocertStorageCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, ok, err := cache.storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return base64.StdEncoding.DecodeString(value)
+}
+
+func (cache *autocertStorageCache) Put(ctx context.Context, key string, data []byte) error {
+	return cache.storage.Put(key, base64.StdEncoding.EncodeToString(data))
+}
+
+func (cache *autocertStorageCache) Delete(ctx context.Context, key string) error {
+	return cache.storage.Delete(key)
+}
@@ -1,48 +1,261 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/singnet/snet-daemon/v6/config"
 	"go.uber.org/zap"
 )
 
+// certDebounce is the time to wait for the rest of the file changes after the
+// first one, the certificate and the key are usually written one after another
+const certDebounce = 500 * time.Millisecond
+
+// CertExpiryAlert is called once for the certificate which expires soon
+type CertExpiryAlert func(cert *x509.Certificate)
+
+// CertReloader serves the key pairs of the daemon listener and reloads them
+// when their files are changed. The changed pair is validated before it
+// replaces the served one, the served pair is kept if the new one is invalid.
 type CertReloader struct {
-	CertFile   string // path to the x509 certificate for https
-	KeyFile    string // path to the x509 private key matching
-	mutex      *sync.Mutex
-	cachedCert *tls.Certificate
+	pairs         []config.SSLCertificate
+	settings      *config.SSLCertReloadSettings
+	alert         CertExpiryAlert
+	now           func() time.Time
+	mutex         *sync.RWMutex
+	cachedCerts   []*tls.Certificate
+	modTimes      []time.Time
+	expiryAlerted map[string]struct{}
+}
+
+func NewCertReloader(pairs []config.SSLCertificate, settings *config.SSLCertReloadSettings, alert CertExpiryAlert) (*CertReloader, error) {
+	reloader := &CertReloader{
+		pairs:         pairs,
+		settings:      settings,
+		alert:         alert,
+		now:           time.Now,
+		mutex:         new(sync.RWMutex),
+		cachedCerts:   make([]*tls.Certificate, len(pairs)),
+		modTimes:      make([]time.Time, len(pairs)),
+		expiryAlerted: make(map[string]struct{}),
+	}
+	for i := range pairs {
+		if err := reloader.reloadCertificate(i); err != nil {
+			return nil, err
+		}
+	}
+	reloader.checkExpiry()
+	return reloader, nil
 }
 
-func (cr *CertReloader) reloadCertificate() error {
-	pair, err := tls.LoadX509KeyPair(cr.CertFile, cr.KeyFile)
+// loadCertificate loads the key pair
+func (cr *CertReloader) loadCertificate(pair config.SSLCertificate) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(pair.Cert, pair.Key)
 	if err != nil {
-		return fmt.Errorf("failed loading tls key pair: %w", err)
+		return nil, fmt.Errorf("failed loading tls key pair: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("failed parsing certificate %v: %w", pair.Cert, err)
+		}
+	}
+	return &cert, nil
+}
+
+// checkValidity checks that the certificate is valid now
+func (cr *CertReloader) checkValidity(cert *tls.Certificate) error {
+	if now := cr.now(); now.Before(cert.Leaf.NotBefore) || now.After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("certificate %v is valid from %v till %v", cert.Leaf.Subject,
+			cert.Leaf.NotBefore, cert.Leaf.NotAfter)
+	}
+	return nil
+}
+
+// modTime returns the time of the last change of the key pair files
+func modTime(pair config.SSLCertificate) (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{pair.Cert, pair.Key} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (cr *CertReloader) reloadCertificate(i int) error {
+	changed, err := modTime(cr.pairs[i])
+	if err != nil {
+		return fmt.Errorf("failed reading tls key pair: %w", err)
+	}
+	cert, err := cr.loadCertificate(cr.pairs[i])
+	if err != nil {
+		return err
+	}
+	// the certificate which isn't valid now replaces only the served one which isn't valid
+	// too, so the daemon starts with the expired certificate and the reload can fix it
+	if err = cr.checkValidity(cert); err != nil {
+		cr.mutex.RLock()
+		served := cr.cachedCerts[i]
+		cr.mutex.RUnlock()
+		if served != nil && cr.checkValidity(served) == nil {
+			return err
+		}
+		zap.L().Warn("ssl certificate isn't valid now, it's served until the valid one is written",
+			zap.String("cert", cr.pairs[i].Cert), zap.Error(err))
 	}
 	cr.mutex.Lock()
-	cr.cachedCert = &pair
+	cr.cachedCerts[i] = cert
+	cr.modTimes[i] = changed
 	cr.mutex.Unlock()
-	return err
+	return nil
 }
 
-func (cr *CertReloader) GetCertificate() *tls.Certificate {
+// reloadChanged reloads the key pairs whose files were changed
+func (cr *CertReloader) reloadChanged() {
+	for i, pair := range cr.pairs {
+		changed, err := modTime(pair)
+		cr.mutex.RLock()
+		loaded := cr.modTimes[i]
+		cr.mutex.RUnlock()
+		if err == nil && changed.Equal(loaded) {
+			continue
+		}
+		if err = cr.reloadCertificate(i); err != nil {
+			zap.L().Error("Error in reloading ssl certificates, the previous certificate is kept",
+				zap.String("cert", pair.Cert), zap.Error(err))
+			continue
+		}
+		zap.L().Info("ssl certificate is reloaded", zap.String("cert", pair.Cert))
+	}
+	cr.checkExpiry()
+}
+
+// checkExpiry alerts once about every certificate which expires within the expiry warning
+func (cr *CertReloader) checkExpiry() {
+	if cr.settings.ExpiryWarning <= 0 {
+		return
+	}
 	cr.mutex.Lock()
-	defer cr.mutex.Unlock()
-	return cr.cachedCert
+	var expiring []*x509.Certificate
+	for _, cert := range cr.cachedCerts {
+		if cert == nil || cr.now().Add(cr.settings.ExpiryWarning).Before(cert.Leaf.NotAfter) {
+			continue
+		}
+		key := string(cert.Leaf.RawIssuer) + "/" + cert.Leaf.SerialNumber.String()
+		if _, ok := cr.expiryAlerted[key]; ok {
+			continue
+		}
+		cr.expiryAlerted[key] = struct{}{}
+		expiring = append(expiring, cert.Leaf)
+	}
+	cr.mutex.Unlock()
+
+	for _, cert := range expiring {
+		zap.L().Warn("ssl certificate expires soon", zap.String("subject", cert.Subject.String()),
+			zap.Time("notAfter", cert.NotAfter))
+		if cr.alert != nil {
+			cr.alert(cert)
+		}
+	}
+}
+
+// GetCertificate returns the certificate matching the server name of the
+// client, the first certificate is returned when none matches.
+func (cr *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	if hello.ServerName != "" {
+		for _, cert := range cr.cachedCerts[1:] {
+			if hello.SupportsCertificate(cert) == nil {
+				return cert, nil
+			}
+		}
+	}
+	return cr.cachedCerts[0], nil
 }
 
-// TODO pass ctx here
-func (cr *CertReloader) Listen() {
-	ticker := time.NewTicker(3 * time.Second)
+// Listen reloads the changed key pairs until the context is done. The
+// directories of the files are watched, so the files replaced by rename or by
+// the symlink swap are reloaded too; the files are polled when the watch isn't
+// available.
+func (cr *CertReloader) Listen(ctx context.Context) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if cr.settings.Watch {
+		watcher, err := cr.watch()
+		if err != nil {
+			zap.L().Warn("can't watch ssl certificates, polling them", zap.Error(err))
+		} else {
+			events, errs = watcher.Events, watcher.Errors
+			go func() {
+				<-ctx.Done()
+				watcher.Close()
+			}()
+		}
+	}
+
 	go func() {
-		defer ticker.Stop()
-		for range ticker.C {
-			err := cr.reloadCertificate()
-			if err != nil {
-				zap.L().Error("Error in reloading ssl certificates", zap.Error(err))
+		poll := time.NewTicker(cr.settings.PollInterval)
+		defer poll.Stop()
+		debounce := time.NewTimer(certDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-poll.C:
+				if events == nil {
+					cr.reloadChanged()
+				} else {
+					cr.checkExpiry()
+				}
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				zap.L().Debug("ssl certificate directory changed", zap.String("event", event.String()))
+				debounce.Reset(certDebounce)
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				zap.L().Error("Error in watching ssl certificates", zap.Error(err))
+			case <-debounce.C:
+				cr.reloadChanged()
 			}
 		}
 	}()
 }
+
+func (cr *CertReloader) watch() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]struct{})
+	for _, pair := range cr.pairs {
+		dirs[filepath.Dir(pair.Cert)] = struct{}{}
+		dirs[filepath.Dir(pair.Key)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+	return watcher, nil
+}
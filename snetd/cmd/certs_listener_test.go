@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func writeKeyPair(t *testing.T, cert tls.Certificate, pair config.SSLCertificate) {
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(pair.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600))
+	require.Nil(t, os.WriteFile(pair.Cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+}
+
+func helloFor(serverName string) *tls.ClientHelloInfo {
+	return &tls.ClientHelloInfo{
+		ServerName:        serverName,
+		SupportedVersions: []uint16{tls.VersionTLS13},
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+	}
+}
+
+func servedName(t *testing.T, reloader *CertReloader, serverName string) string {
+	cert, err := reloader.GetCertificate(helloFor(serverName))
+	require.Nil(t, err)
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	pairs := []config.SSLCertificate{
+		{Cert: filepath.Join(dir, "a.pem"), Key: filepath.Join(dir, "a.key")},
+		{Cert: filepath.Join(dir, "b.pem"), Key: filepath.Join(dir, "b.key")},
+	}
+	writeKeyPair(t, ca.issue(t, 2, "a", "a.example.com"), pairs[0])
+	writeKeyPair(t, ca.issue(t, 3, "b", "b.example.com"), pairs[1])
+
+	var alerted []string
+	reloader, err := NewCertReloader(pairs, &config.SSLCertReloadSettings{PollInterval: time.Second, ExpiryWarning: 2 * time.Hour},
+		func(cert *x509.Certificate) { alerted = append(alerted, cert.Subject.CommonName) })
+	require.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, alerted)
+
+	assert.Equal(t, "a", servedName(t, reloader, ""))
+	assert.Equal(t, "a", servedName(t, reloader, "a.example.com"))
+	assert.Equal(t, "b", servedName(t, reloader, "b.example.com"))
+	assert.Equal(t, "a", servedName(t, reloader, "unknown.example.com"))
+
+	// the invalid pair isn't served
+	require.Nil(t, os.WriteFile(pairs[1].Cert, []byte("broken"), 0600))
+	reloader.reloadChanged()
+	assert.Equal(t, "b", servedName(t, reloader, "b.example.com"))
+
+	// the valid pair replaces the served one, the alert is sent once per certificate
+	writeKeyPair(t, ca.issue(t, 4, "b2", "b.example.com"), pairs[1])
+	reloader.reloadChanged()
+	assert.Equal(t, "b2", servedName(t, reloader, "b.example.com"))
+	reloader.checkExpiry()
+	assert.Equal(t, []string{"a", "b", "b2"}, alerted)
+
+	// the certificate which isn't valid now is loaded, its validity is checked separately
+	reloader.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	cert, err := reloader.loadCertificate(pairs[0])
+	require.Nil(t, err)
+	assert.ErrorContains(t, reloader.checkValidity(cert), "is valid from")
+}
+
+func TestCertReloader_Expired(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	pairs := []config.SSLCertificate{{Cert: filepath.Join(dir, "a.pem"), Key: filepath.Join(dir, "a.key")}}
+	writeKeyPair(t, ca.issueValidTill(t, 2, "a", time.Now().Add(-time.Minute)), pairs[0])
+
+	// the expired certificate is served at the start
+	reloader, err := NewCertReloader(pairs, &config.SSLCertReloadSettings{PollInterval: time.Second}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "a", servedName(t, reloader, ""))
+
+	// and is replaced by the valid one, which isn't replaced by the expired one
+	writeKeyPair(t, ca.issue(t, 3, "a2"), pairs[0])
+	require.Nil(t, reloader.reloadCertificate(0))
+	assert.Equal(t, "a2", servedName(t, reloader, ""))
+	writeKeyPair(t, ca.issueValidTill(t, 4, "a3", time.Now().Add(-time.Minute)), pairs[0])
+	assert.ErrorContains(t, reloader.reloadCertificate(0), "is valid from")
+	assert.Equal(t, "a2", servedName(t, reloader, ""))
+}
+
+func (ca *testCA) issueValidTill(t *testing.T, serial int64, commonName string, notAfter time.Time) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertReloader_Listen(t *testing.T) {
+	for _, watch := range []bool{true, false} {
+		dir := t.TempDir()
+		ca := newTestCA(t)
+		pairs := []config.SSLCertificate{{Cert: filepath.Join(dir, "a.pem"), Key: filepath.Join(dir, "a.key")}}
+		writeKeyPair(t, ca.issue(t, 2, "a"), pairs[0])
+
+		reloader, err := NewCertReloader(pairs, &config.SSLCertReloadSettings{Watch: watch, PollInterval: 100 * time.Millisecond}, nil)
+		require.Nil(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		reloader.Listen(ctx)
+
+		// the new pair is written into the temporary files and renamed like the certificate managers do
+		tmp := config.SSLCertificate{Cert: filepath.Join(dir, "a.pem.tmp"), Key: filepath.Join(dir, "a.key.tmp")}
+		writeKeyPair(t, ca.issue(t, 3, "a2"), tmp)
+		later := time.Now().Add(time.Second)
+		require.Nil(t, os.Chtimes(tmp.Cert, later, later))
+		require.Nil(t, os.Rename(tmp.Key, pairs[0].Key))
+		require.Nil(t, os.Rename(tmp.Cert, pairs[0].Cert))
+
+		assert.Eventually(t, func() bool { return servedName(t, reloader, "") == "a2" }, 5*time.Second, 50*time.Millisecond,
+			"watch: %v", watch)
+		cancel()
+	}
+}
+
+func Test_autocertStorageCache(t *testing.T) {
+	cache := newAutocertStorageCache(storage.NewMemStorage())
+	ctx := context.Background()
+
+	_, err := cache.Get(ctx, "example.com")
+	assert.Equal(t, autocert.ErrCacheMiss, err)
+
+	assert.Nil(t, cache.Put(ctx, "example.com", []byte{0, 1, 2, 255}))
+	data, err := cache.Get(ctx, "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0, 1, 2, 255}, data)
+
+	assert.Nil(t, cache.Delete(ctx, "example.com"))
+	_, err = cache.Get(ctx, "example.com")
+	assert.Equal(t, autocert.ErrCacheMiss, err)
+}
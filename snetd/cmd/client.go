@@ -0,0 +1,495 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/codec"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/escrow"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/pricing"
+)
+
+// ClientCmd groups commands acting as the client of the service
+var ClientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Open payment channels and make paid calls to the service",
+	Long: "Client commands act as the client of the organization and the service of the config: they open and fund" +
+		" the payment channels of the client key, read the channel state from the daemon and call the service methods" +
+		" with the escrow, prepaid or free call payments, which gives the full smoke test of the deployed daemon.",
+}
+
+var ClientOpenChannelCmd = &cobra.Command{
+	Use:   "open-channel",
+	Short: "Open the payment channel to the payment address of the daemon group",
+	Long: "Open the payment channel of the client key to the payment address of the daemon group, the tokens missing" +
+		" in the MultiPartyEscrow balance are deposited first. User can use" +
+		" 'snetd client open-channel --private-key {key} --amount 1000' to open the channel.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newClientOpenChannelCommand)
+	},
+}
+
+var ClientAddFundsCmd = &cobra.Command{
+	Use:   "add-funds <channel-id>",
+	Short: "Add funds to the payment channel and extend it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newClientAddFundsCommand)
+	},
+}
+
+var ClientChannelStateCmd = &cobra.Command{
+	Use:   "channel-state <channel-id>",
+	Short: "Print the state of the payment channel known by the daemon",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newClientChannelStateCommand)
+	},
+}
+
+var ClientCallCmd = &cobra.Command{
+	Use:   "call <service>/<method> [json]",
+	Short: "Call the method of the service with the JSON request",
+	Long: "Call the unary method of the service, the JSON request is converted by the proto files of the service" +
+		" metadata and the JSON response is printed. The request is read from --input when it isn't passed as the" +
+		" argument. User can use 'snetd client call example_service.Calculator/add '{\"a\": 1, \"b\": 2}'" +
+		" --private-key {key} --channel-id 5' to make the call paid from the channel.",
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newClientCallCommand)
+	},
+}
+
+const (
+	ClientPrivateKeyFlag  = "private-key"
+	ClientEndpointFlag    = "endpoint"
+	ClientTimeoutFlag     = "timeout"
+	ClientAmountFlag      = "amount"
+	ClientExpirationFlag  = "expiration"
+	ClientChannelFlag     = "channel-id"
+	ClientPaymentTypeFlag = "payment-type"
+	ClientPriceFlag       = "price"
+	ClientInputFlag       = "input"
+)
+
+// clientCommand runs the action of the service client and prints its result
+type clientCommand struct {
+	action  func(ctx context.Context, output io.Writer) error
+	conn    *grpc.ClientConn
+	timeout time.Duration
+	output  io.Writer
+}
+
+func (command *clientCommand) Run() error {
+	if command.conn != nil {
+		defer command.conn.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), command.timeout)
+	defer cancel()
+	return command.action(ctx, command.output)
+}
+
+func newClientCommand(cmd *cobra.Command, action func(ctx context.Context, output io.Writer) error) *clientCommand {
+	timeout, _ := cmd.Flags().GetDuration(ClientTimeoutFlag)
+	return &clientCommand{action: action, timeout: timeout, output: cmd.OutOrStdout()}
+}
+
+func newClientOpenChannelCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	channelClient, err := newChannelClient(cmd, components)
+	if err != nil {
+		return
+	}
+	amount, err := getCogsFlag(cmd, ClientAmountFlag)
+	if err != nil {
+		return
+	}
+	if amount.Sign() == 0 {
+		return nil, fmt.Errorf("--%v must be set", ClientAmountFlag)
+	}
+	processor, orgMetadata := components.Blockchain(), components.OrganizationMetaData()
+	expirationBlocks, _ := cmd.Flags().GetInt64(ClientExpirationFlag)
+	return newClientCommand(cmd, func(ctx context.Context, output io.Writer) error {
+		expiration, err := channelExpiration(processor, expirationBlocks)
+		if err != nil {
+			return err
+		}
+		channelID, err := channelClient.OpenChannel(ctx, orgMetadata.GetPaymentAddress(), orgMetadata.GetGroupId(), amount, expiration)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Channel %v is opened from %v to %v, value: %v cogs, expiration block: %v\n",
+			channelID, channelClient.Address().Hex(), orgMetadata.GetPaymentAddress().Hex(), amount, expiration)
+		return nil
+	}), nil
+}
+
+func newClientAddFundsCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	channelID, err := parseChannelID(args[0])
+	if err != nil {
+		return
+	}
+	channelClient, err := newChannelClient(cmd, components)
+	if err != nil {
+		return
+	}
+	amount, err := getCogsFlag(cmd, ClientAmountFlag)
+	if err != nil {
+		return
+	}
+	processor := components.Blockchain()
+	expirationBlocks, _ := cmd.Flags().GetInt64(ClientExpirationFlag)
+	return newClientCommand(cmd, func(ctx context.Context, output io.Writer) (err error) {
+		var expiration *big.Int
+		if expirationBlocks > 0 {
+			if expiration, err = channelExpiration(processor, expirationBlocks); err != nil {
+				return err
+			}
+		}
+		if err = channelClient.AddFunds(ctx, channelID, amount, expiration); err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Channel %v is funded with %v cogs", channelID, amount)
+		if expiration != nil {
+			fmt.Fprintf(output, " and extended to block %v", expiration)
+		}
+		fmt.Fprintln(output)
+		return nil
+	}), nil
+}
+
+func newClientChannelStateCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	channelID, err := parseChannelID(args[0])
+	if err != nil {
+		return
+	}
+	paymentClient, conn, err := newPaymentClient(cmd, components)
+	if err != nil {
+		return
+	}
+	processor := components.Blockchain()
+	clientCommand := newClientCommand(cmd, func(ctx context.Context, output io.Writer) error {
+		channel, ok, err := processor.MultiPartyEscrowChannel(channelID)
+		if err != nil {
+			return fmt.Errorf("can't read channel from blockchain: %v", err)
+		}
+		if !ok {
+			return fmt.Errorf("channel %v isn't found in blockchain", channelID)
+		}
+		state, err := paymentClient.ChannelState(ctx, channelID)
+		if err != nil {
+			return fmt.Errorf("can't get channel state: %v", err)
+		}
+		printChannelState(output, channelID, channel, state)
+		return nil
+	})
+	clientCommand.conn = conn
+	return clientCommand, nil
+}
+
+// printChannelState prints the channel of the blockchain and its state known
+// by the daemon, the unspent amount is calculated as described by the
+// PaymentChannelStateService
+func printChannelState(output io.Writer, channelID *big.Int, channel *blockchain.MultiPartyEscrowChannel, state *escrow.ChannelStateReply) {
+	signedAmount := new(big.Int).SetBytes(state.GetCurrentSignedAmount())
+	unspentAmount := new(big.Int).Sub(channel.Value, signedAmount)
+	fmt.Fprintf(output, "Channel %v\n", channelID)
+	fmt.Fprintf(output, "  sender:            %v\n", channel.Sender.Hex())
+	fmt.Fprintf(output, "  signer:            %v\n", channel.Signer.Hex())
+	fmt.Fprintf(output, "  recipient:         %v\n", channel.Recipient.Hex())
+	fmt.Fprintf(output, "  value:             %v cogs\n", channel.Value)
+	fmt.Fprintf(output, "  expiration block:  %v\n", channel.Expiration)
+	fmt.Fprintf(output, "  nonce:             %v\n", new(big.Int).SetBytes(state.GetCurrentNonce()))
+	fmt.Fprintf(output, "  signed amount:     %v cogs\n", signedAmount)
+	if state.GetOldNonceSignedAmount() != nil {
+		oldNonceSignedAmount := new(big.Int).SetBytes(state.GetOldNonceSignedAmount())
+		unspentAmount.Sub(unspentAmount, oldNonceSignedAmount)
+		fmt.Fprintf(output, "  old nonce signed:  %v cogs\n", oldNonceSignedAmount)
+	}
+	fmt.Fprintf(output, "  unspent amount:    %v cogs\n", unspentAmount)
+}
+
+func newClientCallCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	serviceMetadata := components.ServiceMetaData()
+	method, err := findClientMethod(serviceMetadata, args[0])
+	if err != nil {
+		return
+	}
+	request, err := readClientRequest(cmd, args)
+	if err != nil {
+		return
+	}
+	paymentClient, conn, err := newPaymentClient(cmd, components)
+	if err != nil {
+		return
+	}
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	payment, err := newClientPayment(cmd, paymentClient, serviceMetadata, fullMethod)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	clientCommand := newClientCommand(cmd, func(ctx context.Context, output io.Writer) error {
+		md, err := payment(ctx)
+		if err != nil {
+			return err
+		}
+		response, err := callMethod(metadata.NewOutgoingContext(ctx, md), conn, serviceMetadata.GetWireEncoding(), method, request)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(output, string(response))
+		return nil
+	})
+	clientCommand.conn = conn
+	return clientCommand, nil
+}
+
+// newClientPayment returns the function building the payment metadata of the
+// call, the price of the escrow payment is taken from the service metadata
+// unless it's set by the flag
+func newClientPayment(cmd *cobra.Command, paymentClient *escrow.PaymentClient, serviceMetadata *blockchain.ServiceMetadata,
+	fullMethod string) (payment func(ctx context.Context) (metadata.MD, error), err error) {
+	paymentType, _ := cmd.Flags().GetString(ClientPaymentTypeFlag)
+	if paymentType == escrow.FreeCallPaymentType {
+		userID, _ := cmd.Flags().GetString(UserIdFlag)
+		return func(ctx context.Context) (metadata.MD, error) {
+			return paymentClient.FreeCallPayment(ctx, userID)
+		}, nil
+	}
+	if paymentType != escrow.EscrowPaymentType && paymentType != escrow.PrePaidPaymentType {
+		return nil, fmt.Errorf("--%v %q is not one of '%v','%v','%v'", ClientPaymentTypeFlag, paymentType,
+			escrow.EscrowPaymentType, escrow.PrePaidPaymentType, escrow.FreeCallPaymentType)
+	}
+
+	channelFlag, _ := cmd.Flags().GetString(ClientChannelFlag)
+	if channelFlag == "" {
+		return nil, fmt.Errorf("--%v must be set for %v payment", ClientChannelFlag, paymentType)
+	}
+	channelID, err := parseChannelID(channelFlag)
+	if err != nil {
+		return nil, err
+	}
+	price, err := methodPrice(cmd, serviceMetadata, fullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if paymentType == escrow.PrePaidPaymentType {
+		// the amount signed upfront, the price of the single call by default
+		if cmd.Flags().Changed(ClientAmountFlag) {
+			if price, err = getCogsFlag(cmd, ClientAmountFlag); err != nil {
+				return nil, err
+			}
+		}
+		return func(ctx context.Context) (metadata.MD, error) {
+			return paymentClient.PrepaidPayment(ctx, channelID, price)
+		}, nil
+	}
+	return func(ctx context.Context) (metadata.MD, error) {
+		return paymentClient.EscrowPayment(ctx, channelID, price)
+	}, nil
+}
+
+func methodPrice(cmd *cobra.Command, serviceMetadata *blockchain.ServiceMetadata, fullMethod string) (*big.Int, error) {
+	if cmd.Flags().Changed(ClientPriceFlag) {
+		return getCogsFlag(cmd, ClientPriceFlag)
+	}
+	strategy, err := pricing.InitPricingStrategy(serviceMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("can't get price of the method, set --%v: %v", ClientPriceFlag, err)
+	}
+	price, err := strategy.GetPrice(&handler.GrpcStreamContext{Info: &grpc.StreamServerInfo{FullMethod: fullMethod}})
+	if err != nil {
+		return nil, fmt.Errorf("can't get price of the method, set --%v: %v", ClientPriceFlag, err)
+	}
+	return price, nil
+}
+
+// callMethod sends the JSON request converted to the wire encoding of the
+// service and returns the JSON response
+func callMethod(ctx context.Context, conn grpc.ClientConnInterface, wireEncoding string,
+	method protoreflect.MethodDescriptor, requestJSON []byte) ([]byte, error) {
+	request := requestJSON
+	if wireEncoding != "json" {
+		message := dynamicpb.NewMessage(method.Input())
+		if err := protojson.Unmarshal(requestJSON, message); err != nil {
+			return nil, fmt.Errorf("invalid request: %v", err)
+		}
+		var err error
+		if request, err = proto.Marshal(message); err != nil {
+			return nil, err
+		}
+	}
+
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	response := &codec.GrpcFrame{}
+	if err := conn.Invoke(ctx, fullMethod, &codec.GrpcFrame{Data: request}, response); err != nil {
+		st := status.Convert(err)
+		return nil, fmt.Errorf("call failed: %v: %v", st.Code(), st.Message())
+	}
+	if wireEncoding == "json" {
+		return response.Data, nil
+	}
+	message := dynamicpb.NewMessage(method.Output())
+	if err := proto.Unmarshal(response.Data, message); err != nil {
+		return nil, fmt.Errorf("can't decode response: %v", err)
+	}
+	return protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(message)
+}
+
+// findClientMethod returns the unary method by the <service>/<method> name,
+// the service name is full or short
+func findClientMethod(serviceMetadata *blockchain.ServiceMetadata, name string) (protoreflect.MethodDescriptor, error) {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("method %q isn't in the <service>/<method> format", name)
+	}
+	descriptors, err := serviceMetadata.GetProtoDescriptors()
+	if err != nil {
+		return nil, fmt.Errorf("can't compile proto files of the service: %v", err)
+	}
+	method := handler.FindServiceMethod(descriptors, serviceName, methodName)
+	if method == nil {
+		return nil, fmt.Errorf("method %v/%v isn't found in the proto files of the service", serviceName, methodName)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("streaming method %v/%v isn't supported", serviceName, methodName)
+	}
+	return method, nil
+}
+
+// readClientRequest returns the JSON request of the argument or of the input
+// file, "-" reads it from stdin
+func readClientRequest(cmd *cobra.Command, args []string) ([]byte, error) {
+	input, _ := cmd.Flags().GetString(ClientInputFlag)
+	var request []byte
+	switch {
+	case len(args) > 1:
+		request = []byte(args[1])
+	case input == "-":
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return nil, err
+		}
+		request = data
+	case input != "":
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return nil, err
+		}
+		request = data
+	}
+	if len(strings.TrimSpace(string(request))) == 0 {
+		request = []byte("{}")
+	}
+	return request, nil
+}
+
+func newChannelClient(cmd *cobra.Command, components *Components) (*blockchain.ChannelClient, error) {
+	privateKey, err := getClientPrivateKey(cmd)
+	if err != nil {
+		return nil, err
+	}
+	processor := components.Blockchain()
+	if !processor.Enabled() {
+		return nil, fmt.Errorf("blockchain must be enabled to send the channel transactions")
+	}
+	return blockchain.NewChannelClient(context.Background(), processor.GetEthHttpClient(), processor.EscrowContractAddress(), privateKey)
+}
+
+func newPaymentClient(cmd *cobra.Command, components *Components) (*escrow.PaymentClient, *grpc.ClientConn, error) {
+	privateKey, err := getClientPrivateKey(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint, _ := cmd.Flags().GetString(ClientEndpointFlag)
+	if endpoint == "" {
+		if endpoint, err = groupEndpoint(components.ServiceMetaData()); err != nil {
+			return nil, nil, err
+		}
+	}
+	conn, err := dialDaemon(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return escrow.NewPaymentClient(conn, privateKey, components.Blockchain(), components.OrganizationMetaData()), conn, nil
+}
+
+// groupEndpoint returns the first endpoint of the daemon group in the service metadata
+func groupEndpoint(serviceMetadata *blockchain.ServiceMetadata) (string, error) {
+	groupName := config.GetString(config.DaemonGroupName)
+	for _, group := range serviceMetadata.Groups {
+		if group.GroupName == groupName && len(group.Endpoints) > 0 {
+			return group.Endpoints[0], nil
+		}
+	}
+	return "", fmt.Errorf("group %v has no endpoints in the service metadata, set --%v", groupName, ClientEndpointFlag)
+}
+
+// dialDaemon connects to the daemon endpoint, TLS is used for the https endpoints
+func dialDaemon(endpoint string) (*grpc.ClientConn, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	daemonURL, err := url.Parse(endpoint)
+	if err != nil || daemonURL.Host == "" {
+		return nil, fmt.Errorf("invalid daemon endpoint %q", endpoint)
+	}
+	transportCredentials := insecure.NewCredentials()
+	if daemonURL.Scheme == "https" {
+		transportCredentials = credentials.NewClientTLSFromCert(nil, "")
+	}
+	return grpc.NewClient(daemonURL.Host, grpc.WithTransportCredentials(transportCredentials))
+}
+
+func getClientPrivateKey(cmd *cobra.Command) (*ecdsa.PrivateKey, error) {
+	value, _ := cmd.Flags().GetString(ClientPrivateKeyFlag)
+	if value == "" {
+		return nil, fmt.Errorf("--%v must be set", ClientPrivateKeyFlag)
+	}
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("--%v is not a valid private key: %v", ClientPrivateKeyFlag, err)
+	}
+	return privateKey, nil
+}
+
+// channelExpiration returns the block after the blocks of the Ethereum
+// mainnet scaled by the block time of the network
+func channelExpiration(processor blockchain.Processor, blocks int64) (*big.Int, error) {
+	currentBlock, err := processor.CurrentBlock()
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Add(currentBlock, config.ToNetworkBlocks(big.NewInt(blocks))), nil
+}
+
+func parseChannelID(value string) (*big.Int, error) {
+	channelID, ok := new(big.Int).SetString(value, 10)
+	if !ok || channelID.Sign() < 0 {
+		return nil, fmt.Errorf("incorrect channel id %q", value)
+	}
+	return channelID, nil
+}
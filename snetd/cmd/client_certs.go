@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/semyon-dev/cmux"
+	"github.com/singnet/snet-daemon/v6/config"
+	originalCmux "github.com/soheilhy/cmux"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+)
+
+// ClientCertVerifier verifies the client certificates of the mTLS listener
+// against the CA bundle and the revocation lists, the revocation lists are
+// reloaded periodically and when their next update time has passed.
+type ClientCertVerifier struct {
+	CAFile     string // path to the bundle of the CA certificates
+	CRLFile    string // path to the revocation lists of the CAs, optional
+	cas        []*x509.Certificate
+	pool       *x509.CertPool
+	mutex      *sync.RWMutex
+	revoked    map[string]struct{}
+	nextUpdate time.Time // the earliest next update of the loaded lists, zero if none is set
+	now        func() time.Time
+}
+
+func NewClientCertVerifier(settings *config.SSLClientAuthSettings) (*ClientCertVerifier, error) {
+	verifier := &ClientCertVerifier{
+		CAFile:  settings.CA,
+		CRLFile: settings.CRL,
+		pool:    x509.NewCertPool(),
+		mutex:   new(sync.RWMutex),
+		revoked: make(map[string]struct{}),
+		now:     time.Now,
+	}
+	bundle, err := os.ReadFile(settings.CA)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading client CA bundle: %w", err)
+	}
+	for block, rest := pem.Decode(bundle); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing client CA certificate: %w", err)
+		}
+		verifier.cas = append(verifier.cas, ca)
+		verifier.pool.AddCert(ca)
+	}
+	if len(verifier.cas) == 0 {
+		return nil, fmt.Errorf("no certificates in client CA bundle %v", settings.CA)
+	}
+	if err = verifier.reloadCRL(); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// revokedKey identifies the revoked certificate by its issuer and serial number
+func revokedKey(issuer []byte, serial fmt.Stringer) string {
+	return string(issuer) + "/" + serial.String()
+}
+
+func (verifier *ClientCertVerifier) reloadCRL() error {
+	if verifier.CRLFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(verifier.CRLFile)
+	if err != nil {
+		return fmt.Errorf("failed reading CRL: %w", err)
+	}
+	var ders [][]byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "X509 CRL" {
+			ders = append(ders, block.Bytes)
+		}
+	}
+	if len(ders) == 0 {
+		ders = append(ders, data)
+	}
+
+	revoked := make(map[string]struct{})
+	var nextUpdate time.Time
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return fmt.Errorf("failed parsing CRL: %w", err)
+		}
+		if err = verifier.checkCRLSignature(crl); err != nil {
+			return err
+		}
+		if !crl.NextUpdate.IsZero() {
+			if !verifier.now().Before(crl.NextUpdate) {
+				return fmt.Errorf("CRL of %v is expired since %v", crl.Issuer, crl.NextUpdate)
+			}
+			if nextUpdate.IsZero() || crl.NextUpdate.Before(nextUpdate) {
+				nextUpdate = crl.NextUpdate
+			}
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			revoked[revokedKey(crl.RawIssuer, entry.SerialNumber)] = struct{}{}
+		}
+	}
+	verifier.mutex.Lock()
+	verifier.revoked = revoked
+	verifier.nextUpdate = nextUpdate
+	verifier.mutex.Unlock()
+	return nil
+}
+
+// checkCRLSignature checks that the CRL is signed by one of the CAs
+func (verifier *ClientCertVerifier) checkCRLSignature(crl *x509.RevocationList) error {
+	for _, ca := range verifier.cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("CRL of %v is not signed by the client CAs", crl.Issuer)
+}
+
+// refreshExpiredCRL reloads the revocation lists after their next update time,
+// the client certificates are rejected until the fresh lists are loaded
+func (verifier *ClientCertVerifier) refreshExpiredCRL() error {
+	verifier.mutex.RLock()
+	nextUpdate := verifier.nextUpdate
+	verifier.mutex.RUnlock()
+	if nextUpdate.IsZero() || verifier.now().Before(nextUpdate) {
+		return nil
+	}
+	if err := verifier.reloadCRL(); err != nil {
+		zap.L().Error("Client CRL is expired and can't be refreshed", zap.Error(err))
+		return fmt.Errorf("client CRL is expired: %w", err)
+	}
+	return nil
+}
+
+// VerifyPeerCertificate rejects the client certificate chains with the revoked
+// certificates and all the chains while the revocation lists are expired
+func (verifier *ClientCertVerifier) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if err := verifier.refreshExpiredCRL(); err != nil {
+		return err
+	}
+	verifier.mutex.RLock()
+	defer verifier.mutex.RUnlock()
+	for _, chain := range verifiedChains {
+		for _, cert := range chain {
+			if _, ok := verifier.revoked[revokedKey(cert.RawIssuer, cert.SerialNumber)]; ok {
+				return fmt.Errorf("certificate %v is revoked", cert.Subject)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply configures the client authentication of the listener
+func (verifier *ClientCertVerifier) Apply(tlsConfig *tls.Config, mode string) {
+	tlsConfig.ClientCAs = verifier.pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if mode == config.SSLClientAuthVerifyIfGiven {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	tlsConfig.VerifyPeerCertificate = verifier.VerifyPeerCertificate
+}
+
+// Listen reloads the revocation lists with the interval
+func (verifier *ClientCertVerifier) Listen(interval time.Duration) {
+	if verifier.CRLFile == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := verifier.reloadCRL(); err != nil {
+				zap.L().Error("Error in reloading client CRL", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// muxTLSCredentials passes the state of the TLS connection accepted by the
+// daemon listener to the gRPC server, the handshake is already done by the
+// listener so the connection is returned as is.
+type muxTLSCredentials struct{}
+
+func (muxTLSCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	for inner := conn; inner != nil; {
+		switch c := inner.(type) {
+		case *tls.Conn:
+			return conn, credentials.TLSInfo{
+				State:          c.ConnectionState(),
+				CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+			}, nil
+		case *cmux.MuxConn:
+			inner = c.Conn
+		case *originalCmux.MuxConn:
+			inner = c.Conn
+		default:
+			inner = nil
+		}
+	}
+	return conn, nil, nil
+}
+
+func (muxTLSCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("client handshake is not supported")
+}
+
+func (muxTLSCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls"}
+}
+
+func (c muxTLSCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (muxTLSCredentials) OverrideServerName(string) error {
+	return nil
+}
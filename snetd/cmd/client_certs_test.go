@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/peer"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, commonName string, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) writeCRL(t *testing.T, path string, number int64, revoked ...int64) {
+	ca.writeCRLUntil(t, path, time.Now().Add(time.Hour), number, revoked...)
+}
+
+func (ca *testCA) writeCRLUntil(t *testing.T, path string, nextUpdate time.Time, number int64, revoked ...int64) {
+	var entries []x509.RevocationListEntry
+	for _, serial := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600))
+}
+
+// handshake connects the client with the certificate to the listener configured by the verifier
+func handshake(t *testing.T, verifier *ClientCertVerifier, mode string, server tls.Certificate, client *tls.Certificate) (*tls.Conn, error) {
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{server}}
+	verifier.Apply(serverConfig, mode)
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+	if client != nil {
+		clientConfig.Certificates = []tls.Certificate{*client}
+	}
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.Nil(t, err)
+	defer lis.Close()
+	go func() {
+		if clientConn, err := tls.Dial("tcp", lis.Addr().String(), clientConfig); err == nil {
+			_, _ = io.Copy(io.Discard, clientConn)
+			clientConn.Close()
+		}
+	}()
+	conn, err := lis.Accept()
+	require.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	serverConn := conn.(*tls.Conn)
+	return serverConn, serverConn.Handshake()
+}
+
+func TestClientCertVerifier(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath, crlPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "crl.pem")
+	require.Nil(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+	ca.writeCRL(t, crlPath, 1)
+
+	verifier, err := NewClientCertVerifier(&config.SSLClientAuthSettings{CA: caPath, CRL: crlPath})
+	require.Nil(t, err)
+
+	server := ca.issue(t, 2, "daemon", "localhost")
+	partner := ca.issue(t, 3, "partner", "partner.example.com")
+	other := newTestCA(t).issue(t, 4, "other")
+
+	conn, err := handshake(t, verifier, config.SSLClientAuthRequire, server, &partner)
+	require.Nil(t, err)
+	_, authInfo, err := muxTLSCredentials{}.ServerHandshake(conn)
+	require.Nil(t, err)
+	identity := handler.ClientCertIdentityFromContext(peer.NewContext(context.Background(), &peer.Peer{AuthInfo: authInfo}))
+	require.NotNil(t, identity)
+	assert.Equal(t, "partner", identity.CommonName)
+	assert.Equal(t, []string{"partner.example.com", "partner"}, identity.Names())
+
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, nil)
+	assert.NotNil(t, err)
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, &other)
+	assert.NotNil(t, err)
+
+	conn, err = handshake(t, verifier, config.SSLClientAuthVerifyIfGiven, server, nil)
+	require.Nil(t, err)
+	_, authInfo, err = muxTLSCredentials{}.ServerHandshake(conn)
+	require.Nil(t, err)
+	assert.Nil(t, handler.ClientCertIdentityFromContext(peer.NewContext(context.Background(), &peer.Peer{AuthInfo: authInfo})))
+
+	// the revoked certificate is rejected after the CRL is reloaded
+	ca.writeCRL(t, crlPath, 2, 3)
+	require.Nil(t, verifier.reloadCRL())
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, &partner)
+	assert.ErrorContains(t, err, "revoked")
+
+	// the CRL of the unknown CA is rejected and the last loaded CRL is kept
+	newTestCA(t).writeCRL(t, crlPath, 3)
+	assert.NotNil(t, verifier.reloadCRL())
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, &partner)
+	assert.NotNil(t, err)
+}
+
+func TestClientCertVerifier_ExpiredCRL(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath, crlPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "crl.pem")
+	require.Nil(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+
+	// the expired CRL isn't loaded
+	ca.writeCRLUntil(t, crlPath, time.Now().Add(-time.Minute), 1)
+	_, err := NewClientCertVerifier(&config.SSLClientAuthSettings{CA: caPath, CRL: crlPath})
+	assert.ErrorContains(t, err, "expired")
+
+	ca.writeCRL(t, crlPath, 2)
+	verifier, err := NewClientCertVerifier(&config.SSLClientAuthSettings{CA: caPath, CRL: crlPath})
+	require.Nil(t, err)
+	server := ca.issue(t, 2, "daemon", "localhost")
+	partner := ca.issue(t, 3, "partner")
+
+	// the certificates are rejected when the loaded CRL expires and the file has no fresh one
+	now := time.Now().Add(2 * time.Hour)
+	verifier.now = func() time.Time { return now }
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, &partner)
+	assert.ErrorContains(t, err, "expired")
+
+	// the fresh CRL is loaded at the next handshake
+	ca.writeCRLUntil(t, crlPath, now.Add(time.Hour), 3, 3)
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, &partner)
+	assert.ErrorContains(t, err, "revoked")
+	other := ca.issue(t, 4, "other")
+	_, err = handshake(t, verifier, config.SSLClientAuthRequire, server, &other)
+	assert.Nil(t, err)
+}
+
+func Test_muxTLSCredentials_ServerHandshake(t *testing.T) {
+	serverRaw, clientRaw := net.Pipe()
+	defer clientRaw.Close()
+	conn, authInfo, err := muxTLSCredentials{}.ServerHandshake(serverRaw)
+	assert.Nil(t, err)
+	assert.Nil(t, authInfo)
+	assert.Equal(t, serverRaw, conn)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClientCallCmd() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().StringP(ClientInputFlag, "i", "", "")
+	return cmd
+}
+
+func TestReadClientRequest(t *testing.T) {
+	cmd := newTestClientCallCmd()
+
+	request, err := readClientRequest(cmd, []string{"svc/method", `{"a":1}`})
+	require.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, string(request))
+
+	request, err = readClientRequest(cmd, []string{"svc/method"})
+	require.Nil(t, err)
+	assert.Equal(t, "{}", string(request))
+
+	file := filepath.Join(t.TempDir(), "request.json")
+	require.Nil(t, os.WriteFile(file, []byte(`{"b":2}`), 0600))
+	require.Nil(t, cmd.Flags().Set(ClientInputFlag, file))
+	request, err = readClientRequest(cmd, []string{"svc/method"})
+	require.Nil(t, err)
+	assert.Equal(t, `{"b":2}`, string(request))
+
+	require.Nil(t, cmd.Flags().Set(ClientInputFlag, "-"))
+	cmd.SetIn(strings.NewReader(`{"c":3}`))
+	request, err = readClientRequest(cmd, []string{"svc/method"})
+	require.Nil(t, err)
+	assert.Equal(t, `{"c":3}`, string(request))
+}
+
+func TestDialDaemon(t *testing.T) {
+	for _, endpoint := range []string{"127.0.0.1:5000", "http://127.0.0.1:5000", "https://daemon.example.com:443"} {
+		conn, err := dialDaemon(endpoint)
+		require.Nil(t, err, endpoint)
+		conn.Close()
+	}
+
+	_, err := dialDaemon("http://")
+	assert.ErrorContains(t, err, "invalid daemon endpoint")
+}
+
+func TestParseChannelID(t *testing.T) {
+	channelID, err := parseChannelID("42")
+	require.Nil(t, err)
+	assert.Equal(t, int64(42), channelID.Int64())
+
+	for _, value := range []string{"", "-1", "0x10", "one"} {
+		_, err = parseChannelID(value)
+		assert.NotNil(t, err, value)
+	}
+}
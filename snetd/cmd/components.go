@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"os"
 	"strings"
@@ -20,26 +21,32 @@ import (
 	"github.com/singnet/snet-daemon/v6/escrow"
 	"github.com/singnet/snet-daemon/v6/etcddb"
 	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/ipfsutils"
 	"github.com/singnet/snet-daemon/v6/metrics"
 	"github.com/singnet/snet-daemon/v6/pricing"
 	"github.com/singnet/snet-daemon/v6/storage"
 	"github.com/singnet/snet-daemon/v6/token"
 	"github.com/singnet/snet-daemon/v6/training"
+	"github.com/singnet/snet-daemon/v6/usage"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/acme/autocert"
 	"google.golang.org/grpc"
 )
 
 type Components struct {
 	allowedUserPaymentHandler  handler.StreamPaymentHandler
+	certIdentityPaymentHandler handler.StreamPaymentHandler
 	serviceMetadata            *blockchain.ServiceMetadata
 	blockchain                 blockchain.Processor
 	etcdClient                 *etcddb.EtcdClient
 	etcdServer                 *etcddb.EtcdServer
+	etcdMaintenance            *etcddb.EtcdMaintenance
+	reorgWatcher               *escrow.ReorgWatcher
 	atomicStorage              storage.AtomicStorage
 	paymentChannelService      escrow.PaymentChannelService
 	escrowPaymentHandler       handler.StreamPaymentHandler
@@ -72,6 +79,18 @@ type Components struct {
 	modelStorage               *training.ModelStorage
 	pendingModelStorage        *training.PendingModelStorage
 	publicModelStorage         *training.PublicModelStorage
+	modelJobStorage            *training.ModelJobStorage
+	modelChargeStorage         *training.ModelChargeStorage
+	modelBilling               *training.Billing
+	modelVersionStorage        *training.ModelVersionStorage
+	modelVersions              *training.ModelVersions
+	datasetStager              *training.DatasetStager
+	usageStorage               *usage.UsageStorage
+	usageRecorder              *usage.Recorder
+	usageReportService         *usage.UsageReportServiceImpl
+	upstreamPool               *handler.UpstreamPool
+	processPool                *handler.ProcessPool
+	responseCache              *handler.ResponseCache
 }
 
 func InitComponents(cmd *cobra.Command) (components *Components) {
@@ -111,6 +130,12 @@ func isFileExist(fileName string) bool {
 }
 
 func (components *Components) Close() {
+	if components.reorgWatcher != nil {
+		components.reorgWatcher.Close()
+	}
+	if components.etcdMaintenance != nil {
+		components.etcdMaintenance.Close()
+	}
 	if components.etcdClient != nil {
 		components.etcdClient.Close()
 	}
@@ -120,6 +145,15 @@ func (components *Components) Close() {
 	if components.blockchain != nil {
 		components.blockchain.Close()
 	}
+	if components.upstreamPool != nil {
+		components.upstreamPool.Close()
+	}
+	if components.processPool != nil {
+		components.processPool.Close()
+	}
+	if components.responseCache != nil {
+		components.responseCache.Close()
+	}
 }
 
 func (components *Components) Blockchain() blockchain.Processor {
@@ -193,6 +227,67 @@ func (components *Components) EtcdClient() *etcddb.EtcdClient {
 	return components.etcdClient
 }
 
+// EtcdMaintenance starts the scheduled maintenance of the etcd payment storage,
+// nil is returned when the storage isn't etcd or no task is scheduled
+func (components *Components) EtcdMaintenance() *etcddb.EtcdMaintenance {
+	if components.etcdMaintenance != nil {
+		return components.etcdMaintenance
+	}
+	if config.GetString(config.PaymentChannelStorageTypeKey) != "etcd" {
+		return nil
+	}
+
+	conf, err := etcddb.GetEtcdMaintenanceConf(config.Vip())
+	if err != nil {
+		zap.L().Panic("error during etcd maintenance config parsing", zap.Error(err))
+	}
+	if !conf.Enabled() {
+		return nil
+	}
+
+	components.etcdMaintenance = etcddb.NewEtcdMaintenance(components.EtcdClient(), conf)
+	components.etcdMaintenance.Start()
+	return components.etcdMaintenance
+}
+
+// ReorgWatcher starts the reconciliation of the payment channels after the
+// chain reorganizations, it returns nil when the blockchain is disabled or the
+// network has no confirmation_depth
+func (components *Components) ReorgWatcher() *escrow.ReorgWatcher {
+	if components.reorgWatcher != nil {
+		return components.reorgWatcher
+	}
+	if !config.GetBool(config.BlockchainEnabledKey) || config.GetConfirmationDepth() == 0 {
+		return nil
+	}
+
+	settings, err := config.GetPaymentChannelConfirmation()
+	if err != nil {
+		zap.L().Panic("error during payment channel confirmation config parsing", zap.Error(err))
+	}
+	if settings.ReorgCheckInterval <= 0 {
+		return nil
+	}
+
+	components.reorgWatcher = escrow.NewReorgWatcher(
+		components.Blockchain(),
+		escrow.NewPaymentChannelStorage(components.MPESpecificStorage()),
+		escrow.NewBlockchainChannelReader(components.Blockchain(), config.Vip(), components.OrganizationMetaData()),
+		settings.ReorgCheckInterval,
+	)
+	components.reorgWatcher.Start()
+	return components.reorgWatcher
+}
+
+// AutocertCache returns the cache of the automatic SSL certificates, the
+// "storage" cache is shared by the daemon replicas through the atomic storage
+func (components *Components) AutocertCache() autocert.Cache {
+	if config.GetString(config.AutoSSLCacheStorageKey) == config.AutoSSLCacheStorage {
+		return newAutocertStorageCache(components.AtomicStorage())
+	}
+	return autocert.DirCache(config.GetString(config.AutoSSLCacheDirKey))
+}
+
 func (components *Components) FreeCallLockerStorage() *storage.PrefixedAtomicStorage {
 	if components.freeCallLockerStorage != nil {
 		return components.freeCallLockerStorage
@@ -330,6 +425,7 @@ func (components *Components) TrainUnaryPaymentHandler() handler.UnaryPaymentHan
 		components.PaymentChannelService(),
 		components.Blockchain(),
 		escrow.NewTrainValidator(components.ModelStorage()),
+		components.ModelBilling(),
 	)
 
 	return components.trainUnaryPaymentHandler
@@ -344,6 +440,7 @@ func (components *Components) TrainStreamPaymentHandler() handler.StreamPaymentH
 		components.PaymentChannelService(),
 		components.Blockchain(),
 		escrow.NewIncomeStreamValidator(components.PricingStrategy(), components.ModelStorage()),
+		components.ModelBilling(),
 	)
 
 	return components.trainStreamPaymentHandler
@@ -371,6 +468,25 @@ func (components *Components) AllowedUserPaymentHandler() handler.StreamPaymentH
 	return components.allowedUserPaymentHandler
 }
 
+// CertIdentityPaymentHandler admits the calls of the clients with the trusted
+// mTLS certificates, nil is returned when there are no trusted identities
+func (components *Components) CertIdentityPaymentHandler() handler.StreamPaymentHandler {
+	if components.certIdentityPaymentHandler != nil {
+		return components.certIdentityPaymentHandler
+	}
+
+	settings, err := config.GetSSLClientAuth()
+	if err != nil {
+		zap.L().Panic("error during reading of the client authentication settings", zap.Error(err))
+	}
+	if !settings.Enabled || len(settings.TrustedIdentities) == 0 {
+		return nil
+	}
+	components.certIdentityPaymentHandler = escrow.CertIdentityPaymentHandler(settings.TrustedIdentities)
+
+	return components.certIdentityPaymentHandler
+}
+
 func (components *Components) PrePaidPaymentHandler() handler.StreamPaymentHandler {
 	if components.prepaidPaymentHandler != nil {
 		return components.prepaidPaymentHandler
@@ -412,14 +528,126 @@ func (components *Components) GrpcStreamInterceptor() grpc.StreamServerIntercept
 
 		components.grpcStreamInterceptor = grpcMiddleware.ChainStreamServer(
 			handler.GrpcMeteringInterceptor(components.Blockchain().CurrentBlock), handler.GrpcRateLimitInterceptor(components.ChannelBroadcast()),
-			components.GrpcStreamPaymentValidationInterceptor())
+			components.GrpcCircuitBreakerInterceptor(), components.GrpcRequestValidationInterceptor(), components.GrpcModelVersionInterceptor(), components.GrpcResponseCacheLookupInterceptor(),
+			components.GrpcStreamPaymentValidationInterceptor(), components.GrpcUsageInterceptor(), components.GrpcResponseCacheInterceptor())
 	} else {
 		components.grpcStreamInterceptor = grpcMiddleware.ChainStreamServer(handler.GrpcRateLimitInterceptor(components.ChannelBroadcast()),
-			components.GrpcStreamPaymentValidationInterceptor())
+			components.GrpcCircuitBreakerInterceptor(), components.GrpcRequestValidationInterceptor(), components.GrpcModelVersionInterceptor(), components.GrpcResponseCacheLookupInterceptor(),
+			components.GrpcStreamPaymentValidationInterceptor(), components.GrpcUsageInterceptor(), components.GrpcResponseCacheInterceptor())
 	}
+	// watch_model is free, the training daemon authorizes it by the signature,
+	// the reflection is free too
+	components.grpcStreamInterceptor = handler.SkipStreamMethods(components.grpcStreamInterceptor,
+		append([]string{training.Daemon_WatchModel_FullMethodName}, handler.ReflectionMethods...)...)
 	return components.grpcStreamInterceptor
 }
 
+// UpstreamPool returns the pool of the service_endpoint upstreams shared by the
+// passthrough handler and the circuit breaker interceptor
+func (components *Components) UpstreamPool() *handler.UpstreamPool {
+	if components.upstreamPool != nil {
+		return components.upstreamPool
+	}
+	pool, err := handler.NewServiceUpstreamPool()
+	if err != nil {
+		zap.L().Panic("unable to initialize service upstreams", zap.Error(err))
+	}
+	components.upstreamPool = pool
+	metrics.SetUpstreamStatesProvider(pool.States)
+	metrics.SetUpstreamRetryStatsProvider(pool.RetryStats)
+	return components.upstreamPool
+}
+
+// ProcessPool returns the worker processes of executable_path, nil when the
+// service isn't the process service or process_pool.workers is 0
+func (components *Components) ProcessPool() *handler.ProcessPool {
+	if components.processPool != nil {
+		return components.processPool
+	}
+	if !config.GetBool(config.PassthroughEnabledKey) || components.ServiceMetaData().GetServiceType() != "process" {
+		return nil
+	}
+	pool, err := handler.NewServiceProcessPool()
+	if err != nil {
+		zap.L().Panic("unable to start worker processes", zap.Error(err))
+	}
+	components.processPool = pool
+	return components.processPool
+}
+
+// GrpcCircuitBreakerInterceptor fails fast the calls to the unavailable upstreams
+// before the payment validation when passthrough is enabled.
+func (components *Components) GrpcCircuitBreakerInterceptor() grpc.StreamServerInterceptor {
+	if !config.GetBool(config.PassthroughEnabledKey) {
+		return handler.NoOpInterceptor
+	}
+	return handler.GrpcCircuitBreakerInterceptor(components.UpstreamPool())
+}
+
+// GrpcRequestValidationInterceptor rejects the invalid requests before the
+// payment validation when request_validation is enabled.
+func (components *Components) GrpcRequestValidationInterceptor() grpc.StreamServerInterceptor {
+	validator, err := handler.NewServiceRequestValidator(components.ServiceMetaData())
+	if err != nil {
+		zap.L().Panic("unable to initialize request validation", zap.Error(err))
+	}
+	if validator == nil {
+		return handler.NoOpInterceptor
+	}
+	return handler.GrpcRequestValidationInterceptor(validator)
+}
+
+// GrpcModelVersionInterceptor resolves the versions of the training models used
+// by the inference calls when model training is enabled.
+func (components *Components) GrpcModelVersionInterceptor() grpc.StreamServerInterceptor {
+	if !config.GetBool(config.ModelTrainingEnabled) {
+		return handler.NoOpInterceptor
+	}
+	return handler.GrpcModelVersionInterceptor(components.ModelVersions().Resolve)
+}
+
+// ResponseCache returns the cache of the responses, nil when response_cache is disabled
+func (components *Components) ResponseCache() *handler.ResponseCache {
+	if components.responseCache != nil {
+		return components.responseCache
+	}
+	cache, err := handler.NewServiceResponseCache(components.ServiceMetaData(), components.AtomicStorage)
+	if err != nil {
+		zap.L().Panic("unable to initialize response cache", zap.Error(err))
+	}
+	if cache != nil {
+		components.responseCache = cache
+		metrics.SetResponseCacheStatsProvider(cache.Stats)
+	}
+	return components.responseCache
+}
+
+// GrpcResponseCacheLookupInterceptor finds the cached responses before the payment
+// validation, so the calls served from the cache can be priced separately.
+func (components *Components) GrpcResponseCacheLookupInterceptor() grpc.StreamServerInterceptor {
+	if components.ResponseCache() == nil {
+		return handler.NoOpInterceptor
+	}
+	return handler.GrpcResponseCacheLookupInterceptor(components.ResponseCache())
+}
+
+// GrpcResponseCacheInterceptor serves the paid calls from the cache and caches the responses.
+func (components *Components) GrpcResponseCacheInterceptor() grpc.StreamServerInterceptor {
+	if components.ResponseCache() == nil {
+		return handler.NoOpInterceptor
+	}
+	return handler.GrpcResponseCacheInterceptor(components.ResponseCache())
+}
+
+// GrpcUsageInterceptor records the calls accepted by the payment validation
+// when usage reporting is enabled.
+func (components *Components) GrpcUsageInterceptor() grpc.StreamServerInterceptor {
+	if !config.GetBool(config.UsageReportingEnabled) {
+		return handler.NoOpInterceptor
+	}
+	return usage.GrpcUsageInterceptor(components.UsageRecorder())
+}
+
 func (components *Components) GrpcUnaryInterceptor() grpc.UnaryServerInterceptor {
 	if components.grpcUnaryInterceptor != nil {
 		return components.grpcUnaryInterceptor
@@ -433,7 +661,11 @@ func (components *Components) GrpcUnaryInterceptor() grpc.UnaryServerInterceptor
 // Metering end point authentication is now mandatory for daemon
 func (components *Components) verifyAuthenticationSetUpForFreeCall(serviceURL string, groupId string) (ok bool, err error) {
 
-	if _, err = crypto.HexToECDSA(config.GetString(config.PvtKeyForMetering)); err != nil {
+	privateKey, err := config.GetSecret(config.PvtKeyForMetering)
+	if err != nil {
+		return false, err
+	}
+	if _, err = crypto.HexToECDSA(privateKey); err != nil {
 		return false, errors.New("you need a specify a valid private key 'pvt_key_for_metering' as part of service publication process." + err.Error())
 	}
 
@@ -500,17 +732,26 @@ type VerifyMeteringResponse struct {
 }
 
 func (components *Components) GrpcStreamPaymentValidationInterceptor() grpc.StreamServerInterceptor {
+	var certHandlers []handler.StreamPaymentHandler
+	if certHandler := components.CertIdentityPaymentHandler(); certHandler != nil {
+		certHandlers = append(certHandlers, certHandler)
+	}
 	if !components.Blockchain().Enabled() {
 		if config.GetBool(config.AllowedUserFlag) {
 			zap.L().Info("Blockchain is disabled And AllowedUserFlag is enabled")
-			return handler.GrpcPaymentValidationInterceptor(components.ServiceMetaData(), components.AllowedUserPaymentHandler())
+			return handler.GrpcPaymentValidationInterceptor(components.ServiceMetaData(), components.AllowedUserPaymentHandler(), certHandlers...)
+		}
+		if len(certHandlers) > 0 {
+			zap.L().Info("Blockchain is disabled: only the trusted client certificates are admitted")
+			return handler.GrpcPaymentValidationInterceptor(components.ServiceMetaData(), certHandlers[0])
 		}
 		zap.L().Info("Blockchain is disabled: no payment validation")
 		return handler.NoOpInterceptor
 	} else {
 		zap.L().Info("Blockchain is enabled: instantiate payment validation interceptor")
 		return handler.GrpcPaymentValidationInterceptor(components.ServiceMetaData(), components.EscrowPaymentHandler(),
-			components.FreeCallPaymentHandler(), components.PrePaidPaymentHandler(), components.TrainStreamPaymentHandler())
+			append([]handler.StreamPaymentHandler{components.FreeCallPaymentHandler(), components.PrePaidPaymentHandler(),
+				components.TrainStreamPaymentHandler()}, certHandlers...)...)
 	}
 }
 
@@ -554,6 +795,35 @@ func (components *Components) ProviderControlService() (service escrow.ProviderC
 	return components.providerControlService
 }
 
+func (components *Components) UsageStorage() *usage.UsageStorage {
+	if components.usageStorage != nil {
+		return components.usageStorage
+	}
+	components.usageStorage = usage.NewUsageStorage(components.AtomicStorage())
+	return components.usageStorage
+}
+
+func (components *Components) UsageRecorder() *usage.Recorder {
+	if components.usageRecorder != nil {
+		return components.usageRecorder
+	}
+	components.usageRecorder = usage.NewRecorder(components.UsageStorage(), config.GetDuration(config.UsageReportingBucket))
+	return components.usageRecorder
+}
+
+func (components *Components) UsageReportService() (service usage.UsageReportServiceServer) {
+	if !config.GetBool(config.BlockchainEnabledKey) || !config.GetBool(config.UsageReportingEnabled) {
+		return &usage.DisabledUsageReportService{}
+	}
+	if components.usageReportService != nil {
+		return components.usageReportService
+	}
+	components.usageReportService = usage.NewUsageReportService(components.UsageStorage(),
+		config.GetDuration(config.UsageReportingBucket), components.Blockchain(),
+		components.ServiceMetaData(), components.OrganizationMetaData())
+	return components.usageReportService
+}
+
 func (components *Components) FreeCallStateService() (service escrow.FreeCallStateServiceServer) {
 
 	if !config.GetBool(config.BlockchainEnabledKey) {
@@ -568,12 +838,19 @@ func (components *Components) FreeCallStateService() (service escrow.FreeCallSta
 		return &escrow.BlockChainDisabledFreeCallStateService{}
 	}
 
-	if config.GetString(config.PvtKeyForFreeCalls) == "" {
+	// the key is read once, it must match free_call_signer_address of the
+	// metadata, so the daemon is restarted after its rotation
+	privateKeyString, err := config.GetSecret(config.PvtKeyForFreeCalls)
+	if err != nil {
+		zap.L().Error("Free calls disabled", zap.Error(err))
+		return &escrow.BlockChainDisabledFreeCallStateService{}
+	}
+	if privateKeyString == "" {
 		zap.L().Warn(fmt.Sprintf("Free calls disabled: no %s in the config", config.PvtKeyForFreeCalls))
 		return &escrow.BlockChainDisabledFreeCallStateService{}
 	}
 
-	privateKey := utils.ParsePrivateKey(config.GetString(config.PvtKeyForFreeCalls))
+	privateKey := utils.ParsePrivateKey(privateKeyString)
 	addrFromPrvKey := utils.GetAddressFromPrivateKeyECDSA(privateKey)
 	freeCallSignerAddr := components.ServiceMetaData().FreeCallSignerAddress()
 	if addrFromPrvKey != freeCallSignerAddr {
@@ -694,6 +971,78 @@ func (components *Components) PublicModelStorage() *training.PublicModelStorage
 	return components.publicModelStorage
 }
 
+func (components *Components) ModelJobStorage() *training.ModelJobStorage {
+	if components.modelJobStorage != nil {
+		return components.modelJobStorage
+	}
+
+	components.modelJobStorage = training.NewModelJobStorage(components.AtomicStorage(), components.OrganizationMetaData())
+
+	return components.modelJobStorage
+}
+
+func (components *Components) ModelChargeStorage() *training.ModelChargeStorage {
+	if components.modelChargeStorage != nil {
+		return components.modelChargeStorage
+	}
+
+	components.modelChargeStorage = training.NewModelChargeStorage(components.AtomicStorage(), components.OrganizationMetaData())
+
+	return components.modelChargeStorage
+}
+
+// ModelBilling holds the payments of the training jobs until they are finished,
+// the refunds are added to the credit of the payment channels
+func (components *Components) ModelBilling() *training.Billing {
+	if components.modelBilling != nil {
+		return components.modelBilling
+	}
+	settings, err := config.GetModelTrainingBilling()
+	if err != nil {
+		zap.L().Panic("unable to initialize model training billing", zap.Error(err))
+	}
+	refund := func(channelID *big.Int, amount *big.Int) error {
+		return components.PaymentChannelService().Refund(&escrow.PaymentChannelKey{ID: channelID}, amount)
+	}
+	components.modelBilling = training.NewBilling(settings, components.ModelChargeStorage(), components.ModelStorage(), refund)
+	return components.modelBilling
+}
+
+func (components *Components) ModelVersionStorage() *training.ModelVersionStorage {
+	if components.modelVersionStorage != nil {
+		return components.modelVersionStorage
+	}
+
+	components.modelVersionStorage = training.NewModelVersionStorage(components.AtomicStorage(), components.OrganizationMetaData())
+
+	return components.modelVersionStorage
+}
+
+// ModelVersions keeps the versions of the models created by the validation and training jobs
+func (components *Components) ModelVersions() *training.ModelVersions {
+	if components.modelVersions != nil {
+		return components.modelVersions
+	}
+	components.modelVersions = training.NewModelVersions(components.ModelVersionStorage(), components.ModelStorage())
+	return components.modelVersions
+}
+
+// DatasetStager keeps the uploaded datasets, nil when the datasets are streamed to the service provider
+func (components *Components) DatasetStager() *training.DatasetStager {
+	if components.datasetStager != nil {
+		return components.datasetStager
+	}
+	settings, err := config.GetModelTrainingDatasets()
+	if err != nil {
+		zap.L().Panic("unable to initialize dataset staging", zap.Error(err))
+	}
+	components.datasetStager, err = training.NewDatasetStager(settings, ipfsutils.AddFile)
+	if err != nil {
+		zap.L().Panic("unable to initialize dataset staging", zap.Error(err))
+	}
+	return components.datasetStager
+}
+
 func (components *Components) TrainingService() training.DaemonServer {
 	if components.trainingService != nil {
 		return components.trainingService
@@ -702,7 +1051,9 @@ func (components *Components) TrainingService() training.DaemonServer {
 		return &training.NoTrainingDaemonServer{}
 	}
 	components.trainingService = training.NewTrainingService(components.Blockchain(), components.ServiceMetaData(),
-		components.OrganizationMetaData(), components.ModelStorage(), components.ModelUserStorage(), components.PendingModelStorage(), components.PublicModelStorage(), training.DefaultAllowBlockDifference)
+		components.OrganizationMetaData(), components.ModelStorage(), components.ModelUserStorage(), components.PendingModelStorage(), components.PublicModelStorage(),
+		components.ModelJobStorage(), components.ModelBilling(), components.ModelVersions(),
+		components.DatasetStager(), training.DefaultAllowBlockDifference)
 	return components.trainingService
 }
 
@@ -1,11 +1,24 @@
 package cmd
 
 import (
+	"context"
+	"net"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestComponents_verifyMeteringConfigurations(t *testing.T) {
@@ -43,3 +56,65 @@ func TestComponents_verifyMeteringConfigurations(t *testing.T) {
 	}
 
 }
+
+func TestComponents_GrpcStreamInterceptorReflection(t *testing.T) {
+	// the allowed users are paid by the signature of the allowed address
+	config.Vip().Set(config.AllowedUserFlag, true)
+	defer config.Vip().Set(config.AllowedUserFlag, false)
+	patched, _, err := patchDevOrgMetadata([]byte(devTestOrgMetadata), common.HexToAddress("0x2dE5590580b29e74517448aee121bf760fE92d91"))
+	require.Nil(t, err)
+	orgMetadata, err := blockchain.InitOrganizationMetaDataFromJson(patched)
+	require.Nil(t, err)
+	components := &Components{
+		blockchain:           blockchain.NewMockProcessor(false),
+		organizationMetaData: orgMetadata,
+		serviceMetadata:      &blockchain.ServiceMetadata{},
+		atomicStorage:        storage.NewMemStorage(),
+	}
+	defer components.Close()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.StreamInterceptor(components.GrpcStreamInterceptor()))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	handler.RegisterReflection(server, components.ServiceMetaData)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///reflection-test",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// the reflection is called without the payment
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.Nil(t, err)
+	require.Nil(t, stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{}}))
+	resp, err := stream.Recv()
+	require.Nil(t, err)
+	assert.NotEmpty(t, resp.GetListServicesResponse().GetService())
+
+	alphaStream, err := grpc_reflection_v1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.Nil(t, err)
+	require.Nil(t, alphaStream.Send(&grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_ListServices{}}))
+	alphaResp, err := alphaStream.Recv()
+	require.Nil(t, err)
+	assert.NotEmpty(t, alphaResp.GetListServicesResponse().GetService())
+
+	// other streaming methods still need the payment
+	watch, err := grpc_health_v1.NewHealthClient(conn).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.Nil(t, err)
+	_, err = watch.Recv()
+	assert.NotNil(t, err)
+}
+
+func TestComponents_ReorgWatcherWithoutConfirmationDepth(t *testing.T) {
+	defer config.Vip().Set(config.BlockchainEnabledKey, config.Vip().Get(config.BlockchainEnabledKey))
+	config.Vip().Set(config.BlockchainEnabledKey, true)
+	require.Zero(t, config.GetConfirmationDepth())
+
+	components := &Components{}
+	assert.Nil(t, components.ReorgWatcher())
+}
@@ -0,0 +1,466 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/etcddb"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/ipfsutils"
+	"github.com/singnet/snet-daemon/v6/metrics"
+	"github.com/singnet/snet-daemon/v6/pricing"
+	"github.com/singnet/snet-daemon/v6/utils"
+	"github.com/spf13/cobra"
+)
+
+// ConfigCmd groups commands to inspect the daemon configuration
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the daemon configuration",
+}
+
+var ConfigCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the configuration without starting the daemon",
+	Long: "Check runs all validators of the configuration instead of stopping on the first failed one, resolves" +
+		" the organization and the service metadata, probes the blockchain, IPFS, etcd and service endpoints and" +
+		" cross-checks the group, the payment address and the pricing of the metadata with the configuration." +
+		" The command prints the report and exits with the error when any check fails; warnings don't fail it.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newConfigCheckCommand)
+	},
+}
+
+const (
+	ConfigCheckOfflineFlag = "offline"
+	ConfigCheckTimeoutFlag = "timeout"
+)
+
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarning
+	checkError
+	checkSkipped
+)
+
+var checkStatusLabels = map[checkStatus]string{
+	checkOK:      "[OK]",
+	checkWarning: "[WARN]",
+	checkError:   "[ERROR]",
+	checkSkipped: "[SKIP]",
+}
+
+type configCheckResult struct {
+	status  checkStatus
+	name    string
+	message string
+}
+
+// configReport collects the results of the checks in the order they are done
+type configReport struct {
+	results []configCheckResult
+}
+
+func (report *configReport) add(status checkStatus, name string, format string, args ...any) {
+	report.results = append(report.results, configCheckResult{status: status, name: name, message: fmt.Sprintf(format, args...)})
+}
+
+// check adds the error of the check or the OK result with the message
+func (report *configReport) check(name string, err error, format string, args ...any) {
+	if err != nil {
+		report.add(checkError, name, "%v", err)
+		return
+	}
+	report.add(checkOK, name, format, args...)
+}
+
+func (report *configReport) count(status checkStatus) (count int) {
+	for _, result := range report.results {
+		if result.status == status {
+			count++
+		}
+	}
+	return
+}
+
+func (report *configReport) print(output io.Writer) {
+	for _, result := range report.results {
+		line := fmt.Sprintf("%-8s%v", checkStatusLabels[result.status], result.name)
+		if result.message != "" {
+			line += ": " + result.message
+		}
+		fmt.Fprintln(output, line)
+	}
+	fmt.Fprintf(output, "\n%v passed, %v warnings, %v errors, %v skipped\n",
+		report.count(checkOK), report.count(checkWarning), report.count(checkError), report.count(checkSkipped))
+}
+
+type configCheckCommand struct {
+	output  io.Writer
+	offline bool
+	timeout time.Duration
+	report  configReport
+
+	orgMetadata     *blockchain.OrganizationMetaData
+	serviceMetadata *blockchain.ServiceMetadata
+}
+
+func newConfigCheckCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	offline, _ := cmd.Flags().GetBool(ConfigCheckOfflineFlag)
+	timeout, _ := cmd.Flags().GetDuration(ConfigCheckTimeoutFlag)
+	return &configCheckCommand{output: cmd.OutOrStdout(), offline: offline, timeout: timeout}, nil
+}
+
+func (command *configCheckCommand) Run() error {
+	command.checkDeprecatedParams()
+	command.checkValidators()
+	command.checkHeartbeat()
+	command.checkNotifications()
+	command.checkBlockchain()
+	command.checkStorage()
+	command.checkFreeCallSigner()
+	command.checkService()
+
+	command.report.print(command.output)
+	if errors := command.report.count(checkError); errors > 0 {
+		return fmt.Errorf("configuration check failed with %v errors", errors)
+	}
+	return nil
+}
+
+func (command *configCheckCommand) checkDeprecatedParams() {
+	used := config.DeprecatedParamsUsed(config.Vip())
+	oldKeys := make([]string, 0, len(used))
+	for oldKey := range used {
+		oldKeys = append(oldKeys, oldKey)
+	}
+	sort.Strings(oldKeys)
+	for _, oldKey := range oldKeys {
+		command.report.add(checkWarning, "deprecated key "+oldKey, "use %v instead", used[oldKey])
+	}
+}
+
+func (command *configCheckCommand) checkValidators() {
+	for _, result := range config.ValidateAll() {
+		command.report.check(result.Name, result.Err, "")
+	}
+}
+
+func (command *configCheckCommand) checkHeartbeat() {
+	heartbeatType, heartbeatEndpoint := config.GetString(config.ServiceHeartbeatType), config.GetString(config.HeartbeatServiceEndpoint)
+	err := metrics.ValidateHeartbeatConfig(heartbeatType, heartbeatEndpoint)
+	if err == nil && (heartbeatType == "" || heartbeatType == "none" || heartbeatEndpoint == "") {
+		command.report.add(checkOK, "heartbeat", "not configured, the service endpoint is pinged")
+		return
+	}
+	command.report.check("heartbeat", err, "%v %v", heartbeatType, heartbeatEndpoint)
+}
+
+func (command *configCheckCommand) checkNotifications() {
+	err := metrics.ValidateNotificationConfig()
+	if err == nil && (config.GetString(config.NotificationServiceEndpoint) == "" || config.GetString(config.AlertsEMail) == "") {
+		command.report.add(checkWarning, "notifications", "%v or %v isn't set, the alerts aren't sent",
+			config.NotificationServiceEndpoint, config.AlertsEMail)
+		return
+	}
+	command.report.check("notifications", err, "alerts are sent to %v", config.GetString(config.AlertsEMail))
+}
+
+// checkBlockchain probes the blockchain and IPFS, resolves the metadata and
+// cross-checks them with the configuration
+func (command *configCheckCommand) checkBlockchain() {
+	if !config.GetBool(config.BlockchainEnabledKey) {
+		command.report.add(checkWarning, "blockchain", "%v is false, the payments aren't validated", config.BlockchainEnabledKey)
+		orgMetadata, err := blockchain.LoadOrganizationMetaData()
+		if err != nil {
+			command.report.add(checkError, "organization metadata", "%v", err)
+		}
+		serviceMetadata, err := blockchain.LoadServiceMetaData()
+		if err != nil {
+			command.report.add(checkError, "service metadata", "%v", err)
+		}
+		command.orgMetadata, command.serviceMetadata = orgMetadata, serviceMetadata
+		return
+	}
+	if command.offline {
+		for _, name := range []string{"blockchain", "ipfs", "organization metadata", "service metadata"} {
+			command.report.add(checkSkipped, name, "--%v is set", ConfigCheckOfflineFlag)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), command.timeout)
+	err := blockchain.CheckChainID(ctx)
+	cancel()
+	command.report.check("blockchain", err, "network %v, chain id %v, %v",
+		config.GetString(config.BlockChainNetworkSelected), config.GetNetworkId(), config.GetBlockChainHTTPEndPoint())
+	if err != nil {
+		for _, name := range []string{"ipfs", "organization metadata", "service metadata"} {
+			command.report.add(checkSkipped, name, "the blockchain isn't available")
+		}
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), command.timeout)
+	version, err := ipfsutils.Version(ctx)
+	cancel()
+	command.report.check("ipfs", err, "%v, version %v", config.GetString(config.IpfsEndpoint), version)
+
+	orgMetadata, err := blockchain.LoadOrganizationMetaData()
+	command.report.check("organization metadata", err, "organization %v, group %v",
+		config.GetString(config.OrganizationId), config.GetString(config.DaemonGroupName))
+	serviceMetadata, err := blockchain.LoadServiceMetaData()
+	if err != nil {
+		command.report.add(checkError, "service metadata", "%v", err)
+	} else {
+		command.report.add(checkOK, "service metadata", "service %v, type %v, encoding %v",
+			config.GetString(config.ServiceId), serviceMetadata.GetServiceType(), serviceMetadata.GetWireEncoding())
+	}
+	command.orgMetadata, command.serviceMetadata = orgMetadata, serviceMetadata
+	if orgMetadata == nil || serviceMetadata == nil {
+		return
+	}
+
+	command.checkGroup()
+	command.checkContracts()
+	command.checkPricing()
+}
+
+func (command *configCheckCommand) serviceGroup() *blockchain.OrganizationGroup {
+	for _, group := range command.serviceMetadata.Groups {
+		if group.GroupName == config.GetString(config.DaemonGroupName) {
+			return &group
+		}
+	}
+	return nil
+}
+
+func (command *configCheckCommand) checkGroup() {
+	group := command.serviceGroup()
+	if group == nil {
+		command.report.add(checkError, "group", "group %v isn't found in the service metadata", config.GetString(config.DaemonGroupName))
+		return
+	}
+	if group.GroupID != command.orgMetadata.GetGroupIdString() {
+		command.report.add(checkError, "group", "group id %v of the service metadata doesn't match group id %v of the organization metadata",
+			group.GroupID, command.orgMetadata.GetGroupIdString())
+		return
+	}
+	command.report.add(checkOK, "group", "%v, id %v", group.GroupName, group.GroupID)
+}
+
+// checkContracts checks the payment address and the MultiPartyEscrow address
+// of the metadata against the chain
+func (command *configCheckCommand) checkContracts() {
+	ethHttpClient, err := blockchain.CreateHTTPEthereumClient()
+	if err != nil {
+		command.report.add(checkError, "payment address", "%v", err)
+		return
+	}
+	defer ethHttpClient.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), command.timeout)
+	defer cancel()
+
+	paymentAddress := command.orgMetadata.GetPaymentAddress()
+	if code, err := ethHttpClient.EthClient.CodeAt(ctx, paymentAddress, nil); err != nil {
+		command.report.add(checkError, "payment address", "can't get code of %v: %v", paymentAddress.Hex(), err)
+	} else if len(code) > 0 {
+		command.report.add(checkWarning, "payment address", "%v is a contract, the claims must be sent from the payment address",
+			paymentAddress.Hex())
+	} else {
+		command.report.add(checkOK, "payment address", "%v", paymentAddress.Hex())
+	}
+
+	mpeAddress := command.serviceMetadata.GetMpeAddress()
+	if address := config.GetMpeAddress(); address != "" && common.HexToAddress(address) != mpeAddress {
+		command.report.add(checkError, "mpe address", "mpe_address %v of the service metadata doesn't match mpe_address %v of the network",
+			mpeAddress.Hex(), address)
+		return
+	}
+	if code, err := ethHttpClient.EthClient.CodeAt(ctx, mpeAddress, nil); err != nil {
+		command.report.add(checkError, "mpe address", "can't get code of %v: %v", mpeAddress.Hex(), err)
+	} else if len(code) == 0 {
+		command.report.add(checkError, "mpe address", "no contract is deployed at %v", mpeAddress.Hex())
+	} else {
+		command.report.add(checkOK, "mpe address", "%v", mpeAddress.Hex())
+	}
+}
+
+// checkPricing checks the pricing of the group is supported and the methods
+// priced by the metadata are defined by the proto files of the service
+func (command *configCheckCommand) checkPricing() {
+	if _, err := pricing.InitPricingStrategy(command.serviceMetadata); err != nil {
+		command.report.add(checkError, "pricing", "%v", err)
+		return
+	}
+	defaultPricing := command.serviceMetadata.GetDefaultPricing()
+	var missing []string
+	if group := command.serviceGroup(); group != nil {
+		descriptors, err := command.serviceMetadata.GetProtoDescriptors()
+		if err != nil {
+			command.report.add(checkWarning, "pricing", "can't compile proto files of the service: %v", err)
+			return
+		}
+		for _, price := range group.Pricing {
+			for _, details := range price.PricingDetails {
+				for _, method := range details.MethodPricing {
+					if handler.FindServiceMethod(descriptors, details.ServiceName, method.MethodName) == nil {
+						missing = append(missing, details.ServiceName+"/"+method.MethodName)
+					}
+				}
+			}
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		command.report.add(checkError, "pricing", "priced methods %v aren't found in the proto files of the service",
+			strings.Join(missing, ", "))
+	case defaultPricing.PriceInCogs != nil && defaultPricing.PriceInCogs.Sign() == 0:
+		command.report.add(checkWarning, "pricing", "default %v is 0 cogs, the calls are free", defaultPricing.PriceModel)
+	default:
+		command.report.add(checkOK, "pricing", "default %v %v cogs", defaultPricing.PriceModel, defaultPricing.PriceInCogs)
+	}
+}
+
+// checkStorage checks the etcd configuration of the payment storage and probes
+// the etcd endpoints
+func (command *configCheckCommand) checkStorage() {
+	storageType := config.GetString(config.PaymentChannelStorageTypeKey)
+	if storageType != "etcd" {
+		command.report.add(checkWarning, "storage", "%v %q keeps the payments in memory, they are lost on restart",
+			config.PaymentChannelStorageTypeKey, storageType)
+		return
+	}
+
+	serverConf, err := etcddb.GetEtcdServerConf(config.Vip())
+	if err != nil {
+		command.report.add(checkError, "etcd server", "%v", err)
+	} else if serverConf.Enabled {
+		command.report.add(checkOK, "etcd server", "embedded member %v, %v://%v:%v", serverConf.ID,
+			serverConf.Scheme, serverConf.Host, serverConf.ClientPort)
+	}
+	if _, err = etcddb.GetEtcdMaintenanceConf(config.Vip()); err != nil {
+		command.report.add(checkError, "etcd maintenance", "%v", err)
+	}
+
+	if command.orgMetadata == nil {
+		command.report.add(checkSkipped, "etcd", "organization metadata isn't resolved")
+		return
+	}
+	conf, err := etcddb.GetEtcdClientConf(config.Vip(), command.orgMetadata)
+	if err == nil && len(conf.Endpoints) == 0 {
+		err = fmt.Errorf("no endpoints are set in %v or in the organization metadata", config.PaymentChannelStorageClientKey)
+	}
+	if err != nil {
+		command.report.add(checkError, "etcd", "%v", err)
+		return
+	}
+	if command.offline {
+		command.report.add(checkSkipped, "etcd", "--%v is set", ConfigCheckOfflineFlag)
+		return
+	}
+	client, err := etcddb.NewEtcdClientFromVip(config.Vip(), command.orgMetadata)
+	switch {
+	case err == nil:
+		client.Close()
+		command.report.add(checkOK, "etcd", "%v", strings.Join(conf.Endpoints, ", "))
+	case serverConf != nil && serverConf.Enabled:
+		command.report.add(checkWarning, "etcd", "%v, the embedded server is started by serve", err)
+	default:
+		command.report.add(checkError, "etcd", "%v: %v", strings.Join(conf.Endpoints, ", "), err)
+	}
+}
+
+// checkFreeCallSigner checks the key for the free calls matches the free call
+// signer of the service metadata
+func (command *configCheckCommand) checkFreeCallSigner() {
+	key, err := config.GetSecret(config.PvtKeyForFreeCalls)
+	if err != nil {
+		command.report.add(checkError, "free call signer", "%v", err)
+		return
+	}
+	if key == "" {
+		command.report.add(checkWarning, "free call signer", "%v isn't set, the free calls are disabled", config.PvtKeyForFreeCalls)
+		return
+	}
+	privateKey := utils.ParsePrivateKey(key)
+	if privateKey == nil {
+		command.report.add(checkError, "free call signer", "invalid %v", config.PvtKeyForFreeCalls)
+		return
+	}
+	address := utils.GetAddressFromPrivateKeyECDSA(privateKey)
+	if command.serviceMetadata == nil || !config.GetBool(config.BlockchainEnabledKey) {
+		command.report.add(checkOK, "free call signer", "%v", address.Hex())
+		return
+	}
+	signer := command.serviceMetadata.FreeCallSignerAddress()
+	if signer == (common.Address{}) {
+		command.report.add(checkError, "free call signer", "free_call_signer_address of the group isn't set in the service metadata")
+		return
+	}
+	if signer != address {
+		command.report.add(checkError, "free call signer", "address %v of %v doesn't match free_call_signer_address %v of the service metadata",
+			address.Hex(), config.PvtKeyForFreeCalls, signer.Hex())
+		return
+	}
+	command.report.add(checkOK, "free call signer", "%v", address.Hex())
+}
+
+// checkService checks the executable of the service exists or probes the
+// service endpoints
+func (command *configCheckCommand) checkService() {
+	if command.serviceMetadata != nil && command.serviceMetadata.GetServiceType() == "executable" {
+		path := config.GetString(config.ExecutablePathKey)
+		_, err := os.Stat(path)
+		command.report.check("service", err, "executable %v", path)
+		return
+	}
+	if !config.GetBool(config.PassthroughEnabledKey) {
+		command.report.add(checkSkipped, "service", "%v is false", config.PassthroughEnabledKey)
+		return
+	}
+	for _, endpoint := range config.GetServiceEndpoints() {
+		name := "service " + endpoint.Endpoint
+		if command.offline {
+			command.report.add(checkSkipped, name, "--%v is set", ConfigCheckOfflineFlag)
+			continue
+		}
+		command.report.check(name, dialEndpoint(endpoint.Endpoint, command.timeout), "reachable")
+	}
+}
+
+// dialEndpoint opens the TCP connection to the host of the endpoint
+func dialEndpoint(endpoint string, timeout time.Duration) error {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	port := endpointURL.Port()
+	if port == "" {
+		port = "80"
+		if endpointURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(endpointURL.Hostname(), port), timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
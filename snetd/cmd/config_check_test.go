@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigReport(t *testing.T) {
+	report := configReport{}
+	report.check("daemon type", nil, "")
+	report.check("ssl", errors.New("SSL requires both key and certificate when enabled"), "")
+	report.add(checkWarning, "deprecated key daemon_end_point", "use %v instead", "daemon_endpoint")
+	report.add(checkSkipped, "etcd", "--%v is set", ConfigCheckOfflineFlag)
+
+	assert.Equal(t, 1, report.count(checkOK))
+	assert.Equal(t, 1, report.count(checkError))
+	output := &bytes.Buffer{}
+	report.print(output)
+	assert.Equal(t, "[OK]    daemon type\n"+
+		"[ERROR] ssl: SSL requires both key and certificate when enabled\n"+
+		"[WARN]  deprecated key daemon_end_point: use daemon_endpoint instead\n"+
+		"[SKIP]  etcd: --offline is set\n"+
+		"\n1 passed, 1 warnings, 1 errors, 1 skipped\n", output.String())
+}
+
+func TestDialEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	address := listener.Addr().String()
+
+	assert.Nil(t, dialEndpoint("http://"+address, time.Second))
+	assert.Nil(t, dialEndpoint(address, time.Second))
+	listener.Close()
+
+	assert.NotNil(t, dialEndpoint("http://"+address, time.Second))
+	assert.ErrorContains(t, dialEndpoint("http://", time.Second), "invalid endpoint")
+}
+
+func TestConfigCheckBlockchainDisabled(t *testing.T) {
+	defer config.Vip().Set(config.BlockchainEnabledKey, config.Vip().Get(config.BlockchainEnabledKey))
+	config.Vip().Set(config.BlockchainEnabledKey, false)
+
+	command := &configCheckCommand{timeout: time.Second}
+	command.checkBlockchain()
+	assert.Equal(t, 1, command.report.count(checkWarning))
+	assert.Equal(t, 0, command.report.count(checkError))
+	require.NotNil(t, command.orgMetadata)
+	require.NotNil(t, command.serviceMetadata)
+	assert.Equal(t, "", command.orgMetadata.GetGroupIdString())
+	assert.Equal(t, "grpc", command.serviceMetadata.GetServiceType())
+}
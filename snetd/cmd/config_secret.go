@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/spf13/cobra"
+)
+
+var ConfigEncryptSecretCmd = &cobra.Command{
+	Use:   "encrypt-secret",
+	Short: "Encrypt the secret read from stdin to the keystore",
+	Long: "Encrypt-secret reads the secret from stdin and writes the keystore to use by the" +
+		" keystore:///path/to/keystore.json?passphrase=<reference> secret reference in the config." +
+		" The hex private key is encrypted to the keystore of the Ethereum tools, other secrets to the encrypted data." +
+		" The passphrase is the secret reference too, e.g. env://KEYSTORE_PASSPHRASE.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newConfigEncryptSecretCommand)
+	},
+}
+
+const (
+	ConfigPassphraseFlag = "passphrase"
+	ConfigOutputFlag     = "output"
+)
+
+type configEncryptSecretCommand struct {
+	input      io.Reader
+	output     io.Writer
+	outputFile string
+	passphrase string
+}
+
+func newConfigEncryptSecretCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	passphrase, _ := cmd.Flags().GetString(ConfigPassphraseFlag)
+	if !config.IsSecretReference(passphrase) {
+		return nil, fmt.Errorf("--%v must be the secret reference, e.g. env://KEYSTORE_PASSPHRASE", ConfigPassphraseFlag)
+	}
+	outputFile, _ := cmd.Flags().GetString(ConfigOutputFlag)
+	return &configEncryptSecretCommand{input: cmd.InOrStdin(), output: cmd.OutOrStdout(),
+		outputFile: outputFile, passphrase: passphrase}, nil
+}
+
+func (command *configEncryptSecretCommand) Run() error {
+	secret, err := io.ReadAll(command.input)
+	if err != nil {
+		return err
+	}
+	secret = bytes.TrimRight(secret, "\r\n")
+	if len(secret) == 0 {
+		return errors.New("secret is empty")
+	}
+	passphrase, err := config.ResolveSecret(command.passphrase)
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New("passphrase is empty")
+	}
+	keystore, err := config.EncryptSecret(secret, passphrase)
+	if err != nil {
+		return err
+	}
+	if command.outputFile == "" {
+		_, err = fmt.Fprintln(command.output, string(keystore))
+		return err
+	}
+	return os.WriteFile(command.outputFile, keystore, 0600)
+}
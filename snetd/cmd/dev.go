@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/ipfsutils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// DevCmd runs the daemon against a simulated chain and a local IPFS
+var DevCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Run the daemon with a local simulated chain and IPFS",
+	Long: "Dev starts the simulated Ethereum chain with the deployed Registry, MultiPartyEscrow and token contracts," +
+		" serves the files of the dev directory by the local IPFS, registers the organization and the service from " +
+		devOrgMetadataFile + " and " + devServiceMetadataFile + " of the dev directory and starts the daemon." +
+		" The funded keys are printed to make the paid calls. User can use 'snetd dev --dir ./dev --accounts 3'" +
+		" to start the development environment; the chain isn't persisted and is lost on exit.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadConfigFileFromCommandLine(cmd.Flags().Lookup("config"))
+
+		env, err := newDevEnvironment(cmd)
+		if err != nil {
+			return err
+		}
+		env.print(cmd.OutOrStdout())
+
+		err = runDaemon(cmd)
+		env.Close()
+		return err
+	},
+}
+
+const (
+	DevDirFlag       = "dir"
+	DevHostFlag      = "host"
+	DevRPCPortFlag   = "rpc-port"
+	DevWSPortFlag    = "ws-port"
+	DevIPFSPortFlag  = "ipfs-port"
+	DevBlockTimeFlag = "block-time"
+	DevAccountsFlag  = "accounts"
+	DevTokensFlag    = "tokens"
+	DevDepositFlag   = "deposit"
+
+	devNetworkName         = "dev"
+	devOrgMetadataFile     = "organization_metadata.json"
+	devServiceMetadataFile = "service_metadata.json"
+)
+
+// devEnvironment is the simulated chain and the local IPFS used by the daemon in the dev mode
+type devEnvironment struct {
+	chain              *blockchain.DevChain
+	ipfs               *ipfsutils.LocalIPFS
+	ipfsServer         *http.Server
+	ipfsEndpoint       string
+	orgID              string
+	serviceID          string
+	orgMetadataURI     string
+	serviceMetadataURI string
+}
+
+func newDevEnvironment(cmd *cobra.Command) (env *devEnvironment, err error) {
+	flags := cmd.Flags()
+	dir, _ := flags.GetString(DevDirFlag)
+	host, _ := flags.GetString(DevHostFlag)
+	ipfsPort, _ := flags.GetInt(DevIPFSPortFlag)
+	settings := blockchain.DevChainSettings{Host: host}
+	settings.HTTPPort, _ = flags.GetInt(DevRPCPortFlag)
+	settings.WSPort, _ = flags.GetInt(DevWSPortFlag)
+	settings.BlockTime, _ = flags.GetDuration(DevBlockTimeFlag)
+	settings.Accounts, _ = flags.GetInt(DevAccountsFlag)
+	if settings.BlockTime <= 0 {
+		return nil, fmt.Errorf("--%v must be positive", DevBlockTimeFlag)
+	}
+	if settings.Accounts < 1 {
+		return nil, fmt.Errorf("--%v must be at least 1, the first account is the service provider", DevAccountsFlag)
+	}
+	if settings.Tokens, err = getCogsFlag(cmd, DevTokensFlag); err != nil {
+		return nil, err
+	}
+	if settings.Deposit, err = getCogsFlag(cmd, DevDepositFlag); err != nil {
+		return nil, err
+	}
+	if settings.Deposit.Cmp(settings.Tokens) > 0 {
+		return nil, fmt.Errorf("--%v can't exceed --%v", DevDepositFlag, DevTokensFlag)
+	}
+
+	env = &devEnvironment{ipfs: ipfsutils.NewLocalIPFS(), serviceID: config.GetString(config.ServiceId)}
+	cIDs, err := env.ipfs.AddDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the dev directory: %v", err)
+	}
+	orgMetadata, err := os.ReadFile(filepath.Join(dir, devOrgMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	serviceMetadata, err := os.ReadFile(filepath.Join(dir, devServiceMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if env.chain, err = blockchain.NewDevChain(settings); err != nil {
+		return nil, fmt.Errorf("can't start the dev chain: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			env.Close()
+			env = nil
+		}
+	}()
+
+	provider := env.chain.Accounts[0]
+	if orgMetadata, env.orgID, err = patchDevOrgMetadata(orgMetadata, provider.Address()); err != nil {
+		return env, fmt.Errorf("invalid %v: %v", devOrgMetadataFile, err)
+	}
+	if serviceMetadata, err = patchDevServiceMetadata(serviceMetadata, env.chain.MultiPartyEscrowAddress, cIDs); err != nil {
+		return env, fmt.Errorf("invalid %v: %v", devServiceMetadataFile, err)
+	}
+	if env.orgMetadataURI, err = env.publish(orgMetadata); err != nil {
+		return env, err
+	}
+	if env.serviceMetadataURI, err = env.publish(serviceMetadata); err != nil {
+		return env, err
+	}
+	if err = env.chain.RegisterOrganization(env.orgID, env.orgMetadataURI); err != nil {
+		return env, err
+	}
+	if err = env.chain.RegisterService(env.orgID, env.serviceID, env.serviceMetadataURI); err != nil {
+		return env, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(ipfsPort)))
+	if err != nil {
+		return env, fmt.Errorf("can't start the local IPFS: %v", err)
+	}
+	env.ipfsEndpoint = "http://" + listener.Addr().String()
+	env.ipfsServer = &http.Server{Handler: env.ipfs, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := env.ipfsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("local IPFS is stopped", zap.Error(err))
+		}
+	}()
+
+	env.configure(settings.BlockTime)
+	env.chain.Start()
+	return env, nil
+}
+
+func getCogsFlag(cmd *cobra.Command, name string) (*big.Int, error) {
+	value, _ := cmd.Flags().GetString(name)
+	cogs, ok := new(big.Int).SetString(value, 10)
+	if !ok || cogs.Sign() < 0 {
+		return nil, fmt.Errorf("--%v %q is not a valid number of cogs", name, value)
+	}
+	return cogs, nil
+}
+
+// publish adds the metadata to the local IPFS and returns its URI
+func (env *devEnvironment) publish(metadata []byte) (uri string, err error) {
+	cID, err := env.ipfs.Add(metadata)
+	if err != nil {
+		return "", err
+	}
+	return ipfsutils.IpfsPrefix + cID, nil
+}
+
+// configure points the daemon to the dev chain and the local IPFS, the values
+// override the config file
+func (env *devEnvironment) configure(blockTime time.Duration) {
+	vip := config.Vip()
+	chain := env.chain
+	vip.Set(config.BlockchainEnabledKey, true)
+	vip.Set(config.BlockChainNetworkSelected, devNetworkName)
+	vip.Set(config.BlockchainNetworksKey, map[string]any{
+		devNetworkName: map[string]any{
+			config.NetworkId:                      strconv.Itoa(blockchain.DevChainID),
+			config.EthereumJsonRpcHTTPEndpointKey: chain.HTTPEndpoint(),
+			config.EthereumJsonRpcWSEndpointKey:   chain.WSEndpoint(),
+			config.RegistryAddressKey:             chain.RegistryAddress.Hex(),
+			"mpe_address":                         chain.MultiPartyEscrowAddress.Hex(),
+			"token_address":                       chain.TokenAddress.Hex(),
+			"block_time":                          blockTime.String(),
+		},
+	})
+	// the endpoints and the registry of the config file take precedence over the network definition
+	vip.Set(config.EthereumJsonRpcHTTPEndpointKey, chain.HTTPEndpoint())
+	vip.Set(config.EthereumJsonRpcWSEndpointKey, chain.WSEndpoint())
+	vip.Set(config.RegistryAddressKey, chain.RegistryAddress.Hex())
+	vip.Set(config.IpfsEndpoint, env.ipfsEndpoint)
+	vip.Set(config.OrganizationId, env.orgID)
+}
+
+func (env *devEnvironment) print(out io.Writer) {
+	chain := env.chain
+	fmt.Fprintln(out, "Dev environment is started")
+	fmt.Fprintf(out, "  Ethereum RPC:      %v (chain id %v)\n", chain.HTTPEndpoint(), blockchain.DevChainID)
+	fmt.Fprintf(out, "  Ethereum WS:       %v\n", chain.WSEndpoint())
+	fmt.Fprintf(out, "  IPFS:              %v\n", env.ipfsEndpoint)
+	fmt.Fprintf(out, "  Registry:          %v\n", chain.RegistryAddress.Hex())
+	fmt.Fprintf(out, "  MultiPartyEscrow:  %v\n", chain.MultiPartyEscrowAddress.Hex())
+	fmt.Fprintf(out, "  Token:             %v\n", chain.TokenAddress.Hex())
+	fmt.Fprintf(out, "  Organization:      %v (%v)\n", env.orgID, env.orgMetadataURI)
+	fmt.Fprintf(out, "  Service:           %v (%v)\n", env.serviceID, env.serviceMetadataURI)
+	fmt.Fprintln(out, "Funded accounts, each one has ether, tokens and the MultiPartyEscrow deposit:")
+	for i, account := range chain.Accounts {
+		role := fmt.Sprintf("client %v", i)
+		if i == 0 {
+			role = "provider"
+		}
+		fmt.Fprintf(out, "  %-9v %v private key: %v\n", role, account.Address().Hex(), account.PrivateKeyHex())
+	}
+	fmt.Fprintln(out, "⚠️ The keys are generated for the local chain only, don't send real funds to them!")
+}
+
+func (env *devEnvironment) Close() {
+	if env.ipfsServer != nil {
+		if err := env.ipfsServer.Close(); err != nil {
+			zap.L().Warn("can't stop local IPFS", zap.Error(err))
+		}
+	}
+	if env.chain != nil {
+		env.chain.Close()
+	}
+}
+
+// patchDevOrgMetadata sets the payment address of every group to the provider
+// account, it returns the patched metadata and the organization id
+func patchDevOrgMetadata(data []byte, paymentAddress common.Address) (patched []byte, orgID string, err error) {
+	metadata := map[string]any{}
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, "", err
+	}
+	orgID, _ = metadata["org_id"].(string)
+	if orgID == "" {
+		return nil, "", errors.New("org_id is required")
+	}
+	groups, _ := metadata["groups"].([]any)
+	if len(groups) == 0 {
+		return nil, "", errors.New("at least one group is required")
+	}
+	for _, group := range groups {
+		group, ok := group.(map[string]any)
+		if !ok {
+			return nil, "", errors.New("group must be an object")
+		}
+		payment, _ := group["payment"].(map[string]any)
+		if payment == nil {
+			payment = map[string]any{}
+			group["payment"] = payment
+		}
+		payment["payment_address"] = paymentAddress.Hex()
+	}
+	patched, err = json.MarshalIndent(metadata, "", "  ")
+	return patched, orgID, err
+}
+
+// patchDevServiceMetadata sets the MultiPartyEscrow address and replaces the
+// proto archives given by the file names of the dev directory with their CIDs
+func patchDevServiceMetadata(data []byte, mpeAddress common.Address, cIDs map[string]string) (patched []byte, err error) {
+	metadata := map[string]any{}
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	metadata["mpe_address"] = mpeAddress.Hex()
+	if source, ok := metadata["service_api_source"].(string); ok && cIDs[source] != "" {
+		metadata["service_api_source"] = ipfsutils.IpfsPrefix + cIDs[source]
+	}
+	if source, ok := metadata["model_ipfs_hash"].(string); ok && cIDs[source] != "" {
+		metadata["model_ipfs_hash"] = cIDs[source]
+	}
+	return json.MarshalIndent(metadata, "", "  ")
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/ipfsutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const devTestOrgMetadata = `{
+  "org_name": "Dev Organization",
+  "org_id": "dev_org",
+  "groups": [{
+    "group_name": "default_group",
+    "group_id": "99ybRIg2wAx55mqVsA6sB4S7WxPQHNKqa4BPu/bhj+U=",
+    "payment": {
+      "payment_expiration_threshold": 40320,
+      "payment_channel_storage_client": {"endpoints": ["http://127.0.0.1:2379"]}
+    }
+  }]
+}`
+
+const devTestServiceMetadata = `{
+  "version": 1,
+  "display_name": "Dev Calculator",
+  "service_api_source": "service.tar.gz",
+  "mpe_address": "0x7E0aF8988DF45B824b2E0e0A87c6196897744970",
+  "groups": [{"group_name": "default_group", "pricing": [{"price_model": "fixed_price", "price_in_cogs": 1, "default": true}]}]
+}`
+
+func TestPatchDevOrgMetadata(t *testing.T) {
+	address := common.HexToAddress("0x2dE5590580b29e74517448aee121bf760fE92d91")
+	patched, orgID, err := patchDevOrgMetadata([]byte(devTestOrgMetadata), address)
+	require.Nil(t, err)
+	assert.Equal(t, "dev_org", orgID)
+	metadata, err := blockchain.InitOrganizationMetaDataFromJson(patched)
+	require.NotNil(t, metadata, err)
+	assert.Equal(t, address, metadata.GetPaymentAddress())
+	assert.Equal(t, "Dev Organization", metadata.OrgName)
+
+	_, _, err = patchDevOrgMetadata([]byte(`{"groups": []}`), address)
+	assert.Equal(t, "org_id is required", err.Error())
+	_, _, err = patchDevOrgMetadata([]byte(`{"org_id": "dev_org"}`), address)
+	assert.Equal(t, "at least one group is required", err.Error())
+}
+
+func TestPatchDevServiceMetadata(t *testing.T) {
+	mpeAddress := common.HexToAddress("0x2950a3F5009b634C7c97fC16AC80A97ee2cec41D")
+	patched, err := patchDevServiceMetadata([]byte(devTestServiceMetadata), mpeAddress, map[string]string{"service.tar.gz": "bafkreid"})
+	require.Nil(t, err)
+
+	metadata := map[string]any{}
+	require.Nil(t, json.Unmarshal(patched, &metadata))
+	assert.Equal(t, mpeAddress.Hex(), metadata["mpe_address"])
+	assert.Equal(t, "ipfs://bafkreid", metadata["service_api_source"])
+	assert.Equal(t, "Dev Calculator", metadata["display_name"])
+
+	// the sources which aren't the files of the dev directory are kept
+	patched, err = patchDevServiceMetadata([]byte(devTestServiceMetadata), mpeAddress, map[string]string{})
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal(patched, &metadata))
+	assert.Equal(t, "service.tar.gz", metadata["service_api_source"])
+}
+
+func devFreePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestDevEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, devOrgMetadataFile), []byte(devTestOrgMetadata), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, devServiceMetadataFile), []byte(devTestServiceMetadata), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "service.tar.gz"), []byte("protos"), 0600))
+
+	keys := []string{config.BlockchainEnabledKey, config.BlockChainNetworkSelected, config.BlockchainNetworksKey,
+		config.EthereumJsonRpcHTTPEndpointKey, config.EthereumJsonRpcWSEndpointKey, config.RegistryAddressKey,
+		config.IpfsEndpoint, config.OrganizationId, config.ServiceId}
+	previous := map[string]any{}
+	for _, key := range keys {
+		previous[key] = config.Vip().Get(key)
+	}
+	defer func() {
+		for key, value := range previous {
+			config.Vip().Set(key, value)
+		}
+	}()
+	config.Vip().Set(config.ServiceId, "dev_service")
+
+	flags := DevCmd.Flags()
+	require.Nil(t, flags.Set(DevDirFlag, dir))
+	require.Nil(t, flags.Set(DevRPCPortFlag, devFreePort(t)))
+	require.Nil(t, flags.Set(DevWSPortFlag, devFreePort(t)))
+	require.Nil(t, flags.Set(DevIPFSPortFlag, "0"))
+	require.Nil(t, flags.Set(DevAccountsFlag, "2"))
+
+	env, err := newDevEnvironment(DevCmd)
+	require.Nil(t, err)
+	defer env.Close()
+
+	assert.Len(t, env.chain.Accounts, 2)
+	assert.Equal(t, "dev_org", config.GetString(config.OrganizationId))
+	assert.Equal(t, env.chain.HTTPEndpoint(), config.GetString(config.EthereumJsonRpcHTTPEndpointKey))
+	assert.Equal(t, env.chain.RegistryAddress.Hex(), config.GetString(config.RegistryAddressKey))
+	assert.Equal(t, env.ipfsEndpoint, config.GetString(config.IpfsEndpoint))
+
+	// the metadata registered in the Registry is served by the local IPFS
+	registry, err := blockchain.NewRegistryCaller(env.chain.RegistryAddress, env.chain.Backend.Client())
+	require.Nil(t, err)
+	org, err := registry.GetOrganizationById(nil, [32]byte{'d', 'e', 'v', '_', 'o', 'r', 'g'})
+	require.Nil(t, err)
+	assert.True(t, org.Found)
+	orgMetadata, err := blockchain.GetOrganizationMetaDataFromIPFS(string(org.OrgMetadataURI))
+	require.Nil(t, err)
+	assert.Equal(t, env.chain.Accounts[0].Address(), orgMetadata.GetPaymentAddress())
+
+	serviceMetadata, err := ipfsutils.ReadFile(env.serviceMetadataURI)
+	require.Nil(t, err)
+	metadata := map[string]any{}
+	require.Nil(t, json.Unmarshal(serviceMetadata, &metadata))
+	assert.Equal(t, env.chain.MultiPartyEscrowAddress.Hex(), metadata["mpe_address"])
+	protos, err := ipfsutils.ReadFile(metadata["service_api_source"].(string))
+	require.Nil(t, err)
+	assert.Equal(t, "protos", string(protos))
+}
@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/etcddb"
+	"github.com/spf13/cobra"
+)
+
+// EtcdCmd groups commands to manage the etcd cluster of the payment storage
+var EtcdCmd = &cobra.Command{
+	Use:   "etcd",
+	Short: "Manage the etcd cluster of the payment storage",
+	Long: "Etcd commands manage the members, the snapshots and the compaction of the etcd cluster" +
+		" configured by payment_channel_storage_client or by the organization metadata",
+}
+
+// EtcdMemberCmd groups commands to manage the members of the etcd cluster
+var EtcdMemberCmd = &cobra.Command{
+	Use:   "member",
+	Short: "List, add, remove and promote the etcd members",
+}
+
+var EtcdMemberListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the etcd members",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdMemberListCommand)
+	},
+}
+
+var EtcdMemberAddCmd = &cobra.Command{
+	Use:   "add <peer-url>...",
+	Short: "Add the etcd member",
+	Long: "Add the member with the peer urls to the cluster and print the cluster config of the new member." +
+		" User can use 'snetd etcd member add --name storage-4 --learner http://10.0.0.4:2380' to add the member" +
+		" which doesn't vote until it is promoted; the new member is started with cluster_state \"existing\".",
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdMemberAddCommand)
+	},
+}
+
+var EtcdMemberRemoveCmd = &cobra.Command{
+	Use:   "remove <member-id>",
+	Short: "Remove the etcd member, the member id is hex as printed by 'member list'",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdMemberRemoveCommand)
+	},
+}
+
+var EtcdMemberPromoteCmd = &cobra.Command{
+	Use:   "promote <member-id>",
+	Short: "Promote the learner etcd member to the voting member",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdMemberPromoteCommand)
+	},
+}
+
+// EtcdSnapshotCmd groups commands to save and restore the etcd snapshots
+var EtcdSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore the etcd snapshots",
+}
+
+var EtcdSnapshotSaveCmd = &cobra.Command{
+	Use:   "save [dir]",
+	Short: "Save the etcd snapshot, the snapshot_dir of payment_channel_storage_maintenance is used by default",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdSnapshotSaveCommand)
+	},
+}
+
+var EtcdSnapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <snapshot>",
+	Short: "Put the keys of the etcd snapshot into the cluster",
+	Long: "Put the latest values of the keys of the snapshot into the running cluster, the history of the keys" +
+		" and the keys attached to the leases aren't restored. To bootstrap the new cluster from the snapshot" +
+		" set restore_from of payment_channel_storage_server instead.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdSnapshotRestoreCommand)
+	},
+}
+
+var EtcdCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Compact the history of the etcd keys",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdCompactCommand)
+	},
+}
+
+var EtcdDefragCmd = &cobra.Command{
+	Use:   "defrag",
+	Short: "Defragment the etcd members to release the space of the compacted history",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newEtcdDefragCommand)
+	},
+}
+
+const (
+	EtcdLearnerFlag   = "learner"
+	EtcdNameFlag      = "name"
+	EtcdRetentionFlag = "retention"
+)
+
+// etcdCommand runs the action against the etcd cluster and prints its result
+type etcdCommand struct {
+	client *etcddb.EtcdClient
+	action func(client *etcddb.EtcdClient, output io.Writer) error
+	output io.Writer
+}
+
+func (command *etcdCommand) Run() error {
+	return command.action(command.client, command.output)
+}
+
+func newEtcdCommand(components *Components, action func(client *etcddb.EtcdClient, output io.Writer) error) *etcdCommand {
+	return &etcdCommand{client: components.EtcdClient(), action: action, output: os.Stdout}
+}
+
+func parseMemberID(arg string) (uint64, error) {
+	id, err := strconv.ParseUint(arg, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid member id %q, the id is hex as printed by 'member list'", arg)
+	}
+	return id, nil
+}
+
+func newEtcdMemberListCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		members, err := client.MemberList()
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			fmt.Fprintln(output, member)
+		}
+		return nil
+	}), nil
+}
+
+func newEtcdMemberAddCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	learner, err := cmd.Flags().GetBool(EtcdLearnerFlag)
+	if err != nil {
+		return
+	}
+	name, err := cmd.Flags().GetString(EtcdNameFlag)
+	if err != nil {
+		return
+	}
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		added, members, err := client.MemberAdd(args, learner)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Member %x is added\n", added.ID)
+		fmt.Fprintf(output, "Start the member with the payment_channel_storage_server config:\n")
+		fmt.Fprintf(output, "\"id\": %q,\n", name)
+		fmt.Fprintf(output, "\"cluster\": %q,\n", etcddb.InitialCluster(members, name, args))
+		fmt.Fprintf(output, "\"cluster_state\": \"existing\"\n")
+		return nil
+	}), nil
+}
+
+func newEtcdMemberRemoveCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	id, err := parseMemberID(args[0])
+	if err != nil {
+		return
+	}
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		if err := client.MemberRemove(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Member %x is removed\n", id)
+		return nil
+	}), nil
+}
+
+func newEtcdMemberPromoteCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	id, err := parseMemberID(args[0])
+	if err != nil {
+		return
+	}
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		if err := client.MemberPromote(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Member %x is promoted\n", id)
+		return nil
+	}), nil
+}
+
+func newEtcdSnapshotSaveCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	conf, err := etcddb.GetEtcdMaintenanceConf(config.Vip())
+	if err != nil {
+		return
+	}
+	dir := conf.SnapshotDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		path, err := client.SaveSnapshot(context.Background(), dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Snapshot is saved to %v\n", path)
+		return nil
+	}), nil
+}
+
+func newEtcdSnapshotRestoreCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		restored, err := client.RestoreSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "%v keys are restored from %v\n", restored, args[0])
+		return nil
+	}), nil
+}
+
+func newEtcdCompactCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	retention, err := cmd.Flags().GetInt64(EtcdRetentionFlag)
+	if err != nil {
+		return
+	}
+	if retention <= 0 {
+		conf, err := etcddb.GetEtcdMaintenanceConf(config.Vip())
+		if err != nil {
+			return nil, err
+		}
+		retention = conf.CompactionRetention
+	}
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		revision, err := client.Compact(retention)
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			fmt.Fprintf(output, "Nothing to compact, the storage has less than %v revisions\n", retention)
+			return nil
+		}
+		fmt.Fprintf(output, "Compacted till revision %v\n", revision)
+		return nil
+	}), nil
+}
+
+func newEtcdDefragCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	return newEtcdCommand(components, func(client *etcddb.EtcdClient, output io.Writer) error {
+		if err := client.Defragment(); err != nil {
+			return err
+		}
+		fmt.Fprintln(output, "Members are defragmented")
+		return nil
+	}), nil
+}
@@ -3,10 +3,12 @@ package cmd
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/escrow"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -129,6 +131,11 @@ func init() {
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(FreeCallUserCmd)
 	RootCmd.AddCommand(GenerateEvmKeys)
+	RootCmd.AddCommand(UsageCmd)
+	RootCmd.AddCommand(EtcdCmd)
+	RootCmd.AddCommand(DevCmd)
+	RootCmd.AddCommand(ClientCmd)
+	RootCmd.AddCommand(ConfigCmd)
 
 	FreeCallUserCmd.AddCommand(FreeCallUserUnLockCmd)
 	FreeCallUserCmd.AddCommand(FreeCallUserResetCmd)
@@ -137,6 +144,27 @@ func init() {
 	ListCmd.AddCommand(ListChannelsCmd)
 	ListCmd.AddCommand(ListClaimsCmd)
 
+	UsageCmd.AddCommand(UsageReportCmd)
+
+	EtcdCmd.AddCommand(EtcdMemberCmd)
+	EtcdCmd.AddCommand(EtcdSnapshotCmd)
+	EtcdCmd.AddCommand(EtcdCompactCmd)
+	EtcdCmd.AddCommand(EtcdDefragCmd)
+	EtcdMemberCmd.AddCommand(EtcdMemberListCmd)
+	EtcdMemberCmd.AddCommand(EtcdMemberAddCmd)
+	EtcdMemberCmd.AddCommand(EtcdMemberRemoveCmd)
+	EtcdMemberCmd.AddCommand(EtcdMemberPromoteCmd)
+	EtcdSnapshotCmd.AddCommand(EtcdSnapshotSaveCmd)
+	EtcdSnapshotCmd.AddCommand(EtcdSnapshotRestoreCmd)
+
+	ClientCmd.AddCommand(ClientOpenChannelCmd)
+	ClientCmd.AddCommand(ClientAddFundsCmd)
+	ClientCmd.AddCommand(ClientChannelStateCmd)
+	ClientCmd.AddCommand(ClientCallCmd)
+
+	ConfigCmd.AddCommand(ConfigCheckCmd)
+	ConfigCmd.AddCommand(ConfigEncryptSecretCmd)
+
 	ChannelCmd.Flags().StringVarP(&paymentChannelId, UnlockChannelFlag, "u", "", "unlocks the payment channel with the given ID, see \"list channels\"")
 
 	FreeCallUserUnLockCmd.Flags().StringP(AddressFlag, "a", "", "free call user address")
@@ -147,6 +175,47 @@ func init() {
 	FreeCallUserResetCmd.Flags().StringP(UserIdFlag, "u", "", "free call user-id (optional)")
 	_ = FreeCallUserResetCmd.MarkFlagRequired(AddressFlag)
 
+	UsageReportCmd.Flags().String(UsageFromFlag, "", "report buckets starting at or after this time (RFC3339 or unix timestamp)")
+	UsageReportCmd.Flags().String(UsageToFlag, "", "report buckets starting before this time (RFC3339 or unix timestamp)")
+	UsageReportCmd.Flags().String(UsageSenderFlag, "", "filter by sender address")
+	UsageReportCmd.Flags().String(UsageChannelFlag, "", "filter by payment channel id")
+	UsageReportCmd.Flags().String(UsageMethodFlag, "", "filter by full method name, e.g. /example_service.Calculator/add")
+	UsageReportCmd.Flags().StringP(UsageFormatFlag, "f", defaultUsageFormat, "output format: one of 'csv','json'")
+
+	EtcdMemberAddCmd.Flags().Bool(EtcdLearnerFlag, false, "add the member as the learner which doesn't vote until it is promoted")
+	EtcdMemberAddCmd.Flags().String(EtcdNameFlag, "", "id of the new member, used to print its cluster config")
+	EtcdCompactCmd.Flags().Int64(EtcdRetentionFlag, 0, "number of the latest revisions to keep, compaction_retention by default")
+
+	DevCmd.Flags().String(DevDirFlag, "dev", "directory with "+devOrgMetadataFile+", "+devServiceMetadataFile+" and the files served by the local IPFS")
+	DevCmd.Flags().String(DevHostFlag, "127.0.0.1", "host of the local chain and IPFS endpoints")
+	DevCmd.Flags().Int(DevRPCPortFlag, 8545, "port of the JSON RPC endpoint of the local chain")
+	DevCmd.Flags().Int(DevWSPortFlag, 8546, "port of the websocket JSON RPC endpoint of the local chain")
+	DevCmd.Flags().Int(DevIPFSPortFlag, 5002, "port of the local IPFS")
+	DevCmd.Flags().Duration(DevBlockTimeFlag, time.Second, "time between the blocks of the local chain")
+	DevCmd.Flags().Int(DevAccountsFlag, 3, "number of the funded accounts, the first one is the service provider")
+	DevCmd.Flags().String(DevTokensFlag, "100000000000000", "tokens minted to every account, in cogs")
+	DevCmd.Flags().String(DevDepositFlag, "10000000000000", "tokens of every account deposited to the MultiPartyEscrow, in cogs")
+
+	ClientCmd.PersistentFlags().String(ClientPrivateKeyFlag, "", "hex private key of the channel sender or of the free call user")
+	ClientCmd.PersistentFlags().String(ClientEndpointFlag, "", "daemon endpoint, the first endpoint of the daemon group in the service metadata by default")
+	ClientCmd.PersistentFlags().Duration(ClientTimeoutFlag, time.Minute, "timeout of the command")
+	ClientOpenChannelCmd.Flags().String(ClientAmountFlag, "", "value of the channel, in cogs")
+	ClientOpenChannelCmd.Flags().Int64(ClientExpirationFlag, 60480, "expiration of the channel in blocks of the Ethereum mainnet after the current block, scaled by the block time of the network")
+	_ = ClientOpenChannelCmd.MarkFlagRequired(ClientAmountFlag)
+	ClientAddFundsCmd.Flags().String(ClientAmountFlag, "0", "cogs added to the channel")
+	ClientAddFundsCmd.Flags().Int64(ClientExpirationFlag, 0, "new expiration of the channel in blocks of the Ethereum mainnet after the current block, the expiration isn't changed by default")
+	ClientCallCmd.Flags().String(ClientChannelFlag, "", "payment channel id of the escrow and prepaid payments")
+	ClientCallCmd.Flags().String(ClientPaymentTypeFlag, escrow.EscrowPaymentType, "payment type: one of '"+escrow.EscrowPaymentType+"','"+escrow.PrePaidPaymentType+"','"+escrow.FreeCallPaymentType+"'")
+	ClientCallCmd.Flags().String(ClientPriceFlag, "", "price of the call in cogs, the price of the service metadata by default")
+	ClientCallCmd.Flags().String(ClientAmountFlag, "", "cogs signed upfront by the prepaid payment, the price of the call by default")
+	ClientCallCmd.Flags().StringP(UserIdFlag, "u", "", "user id of the free call, allowed for the trusted free call signers only")
+	ClientCallCmd.Flags().StringP(ClientInputFlag, "i", "", "file with the JSON request, '-' reads stdin")
+
+	ConfigCheckCmd.Flags().Bool(ConfigCheckOfflineFlag, false, "skip the blockchain, IPFS, etcd and service probes")
+	ConfigCheckCmd.Flags().Duration(ConfigCheckTimeoutFlag, 10*time.Second, "timeout of each probe")
+	ConfigEncryptSecretCmd.Flags().String(ConfigPassphraseFlag, "", "secret reference of the passphrase, e.g. env://KEYSTORE_PASSPHRASE")
+	ConfigEncryptSecretCmd.Flags().StringP(ConfigOutputFlag, "o", "", "file of the keystore, stdout by default")
+
 	vip.BindPFlag(config.AutoSSLDomainKey, serveCmdFlags.Lookup("auto-ssl-domain"))
 	vip.BindPFlag(config.AutoSSLCacheDirKey, serveCmdFlags.Lookup("auto-ssl-cache"))
 	vip.BindPFlag(config.DaemonTypeKey, serveCmdFlags.Lookup("type"))
@@ -3,14 +3,15 @@ package cmd
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
-	"sync"
 	"syscall"
+	"time"
 
 	"github.com/singnet/snet-daemon/v6/errs"
 	"github.com/singnet/snet-daemon/v6/handler/httphandler"
@@ -25,6 +26,7 @@ import (
 	"github.com/singnet/snet-daemon/v6/logger"
 	"github.com/singnet/snet-daemon/v6/metrics"
 	"github.com/singnet/snet-daemon/v6/training"
+	"github.com/singnet/snet-daemon/v6/usage"
 
 	"github.com/gorilla/handlers"
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
@@ -34,7 +36,9 @@ import (
 	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/net/http2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 var corsOptionsHTTP = []handlers.CORSOption{
@@ -47,46 +51,59 @@ var ServeCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Is the default option which starts the Daemon.",
 	Run: func(cmd *cobra.Command, args []string) {
-		var err error
+		if err := runDaemon(cmd); err != nil {
+			zap.L().Fatal(fmt.Sprintf("Unable to initialize daemon: %v %v ", err, errs.ErrDescURL(errs.InvalidConfig)))
+		}
+	},
+}
 
-		components := InitComponents(cmd)
-		defer components.Close()
+// runDaemon starts the daemon and serves until SIGTERM or SIGINT, the error is
+// returned after the components are closed
+func runDaemon(cmd *cobra.Command) error {
+	components := InitComponents(cmd)
+	defer components.Close()
 
-		logger.Initialize()
-		config.LogConfig()
+	logger.Initialize()
+	config.LogConfig()
 
-		etcdServer := components.EtcdServer()
-		if etcdServer != nil {
-			zap.L().Info("Using internal etcd server because it is enabled in config")
-		}
+	etcdServer := components.EtcdServer()
+	if etcdServer != nil {
+		zap.L().Info("Using internal etcd server because it is enabled in config")
+	}
 
-		var d daemon
-		d, err = newDaemon(components)
-		if err != nil {
-			zap.L().Fatal(fmt.Sprintf("Unable to initialize daemon: %v %v ", err, errs.ErrDescURL(errs.InvalidConfig)))
-		}
+	d, err := newDaemon(components)
+	if err != nil {
+		return err
+	}
 
-		d.start()
-		defer d.stop()
-
-		// Check if the payment storage client is etcd by verifying if d.components.etcdClient exists.
-		// If etcdClient is not nil and hot reload is enabled, initialize a ContractEventListener
-		// to listen for changes in the organization metadata.
-		if d.components.etcdClient != nil && d.components.etcdClient.IsHotReloadEnabled() {
-			contractEventLister := contractListener.ContractEventListener{
-				BlockchainProcessor:         d.blockProc,
-				CurrentOrganizationMetaData: components.OrganizationMetaData(),
-				CurrentEtcdClient:           components.EtcdClient(),
-			}
-			go contractEventLister.ListenOrganizationMetadataChanging()
+	d.start()
+	defer d.stop()
+
+	components.EtcdMaintenance()
+	components.ReorgWatcher()
+
+	// Check if the payment storage client is etcd by verifying if d.components.etcdClient exists.
+	// If etcdClient is not nil and hot reload is enabled, initialize a ContractEventListener
+	// to listen for changes in the organization metadata.
+	if d.components.etcdClient != nil && d.components.etcdClient.IsHotReloadEnabled() {
+		contractEventLister := contractListener.ContractEventListener{
+			BlockchainProcessor:         d.blockProc,
+			CurrentOrganizationMetaData: components.OrganizationMetaData(),
+			CurrentEtcdClient:           components.EtcdClient(),
 		}
+		go contractEventLister.ListenOrganizationMetadataChanging()
+	}
 
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
-		<-sigChan
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	// SIGHUP makes the daemon resolve the secret references again, e.g. after the rotation of the keys
+	for sig := <-sigChan; sig == syscall.SIGHUP; sig = <-sigChan {
+		zap.L().Info("Reloading the secrets on SIGHUP")
+		config.ReloadSecrets()
+	}
 
-		zap.L().Debug("Exiting")
-	},
+	zap.L().Debug("Exiting")
+	return nil
 }
 
 type daemon struct {
@@ -95,8 +112,12 @@ type daemon struct {
 	grpcServer    *grpc.Server
 	blockProc     blockchain.Processor
 	lis           net.Listener
-	sslCert       *tls.Certificate
+	certReloader  *CertReloader
+	cancel        context.CancelFunc
 	components    *Components
+	restListener  *bufconn.Listener
+	restConn      *grpc.ClientConn
+	restHandler   http.Handler
 }
 
 func newDaemon(components *Components) (daemon, error) {
@@ -116,6 +137,15 @@ func newDaemon(components *Components) (daemon, error) {
 		return d, err
 	}
 
+	if config.GetBool(config.BlockchainEnabledKey) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := blockchain.CheckChainID(ctx)
+		cancel()
+		if err != nil {
+			return d, err
+		}
+	}
+
 	d.components = components
 
 	d.blockProc = components.Blockchain()
@@ -142,12 +172,19 @@ func newDaemon(components *Components) (daemon, error) {
 		}
 	}
 
-	if sslKey := config.GetString(config.SSLKeyPathKey); sslKey != "" {
-		cert, err := tls.LoadX509KeyPair(config.GetString(config.SSLCertPathKey), sslKey)
+	certificates, err := config.GetSSLCertificates()
+	if err != nil {
+		return d, err
+	}
+	if len(certificates) > 0 {
+		reload, err := config.GetSSLCertReload()
+		if err != nil {
+			return d, err
+		}
+		d.certReloader, err = NewCertReloader(certificates, reload, certExpiryAlert(d.blockProc))
 		if err != nil {
 			return d, errors.Wrap(err, "unable to load specific SSL X509 keypair")
 		}
-		d.sslCert = &cert
 	}
 
 	return d, nil
@@ -156,26 +193,19 @@ func newDaemon(components *Components) (daemon, error) {
 func (d *daemon) start() {
 
 	var tlsConfig *tls.Config
-	var certReloader *CertReloader
-
-	if config.GetString(config.SSLCertPathKey) != "" {
-		certReloader = &CertReloader{
-			CertFile: config.GetString(config.SSLCertPathKey),
-			KeyFile:  config.GetString(config.SSLKeyPathKey),
-			mutex:    new(sync.Mutex),
-		}
-	}
+	var ctx context.Context
+	ctx, d.cancel = context.WithCancel(context.Background())
 
-	if certReloader != nil {
-		certReloader.Listen()
+	if d.certReloader != nil {
+		d.certReloader.Listen(ctx)
 	}
 
 	if d.autoSSLDomain != "" {
 		zap.L().Debug("enabling automatic SSL support")
 		certMgr := autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist(d.autoSSLDomain),
-			Cache:      autocert.DirCache(config.GetString(config.AutoSSLCacheDirKey)),
+			HostPolicy: autocert.HostWhitelist(config.GetAutoSSLDomains()...),
+			Cache:      d.components.AutocertCache(),
 		}
 
 		// This is the HTTP server that handles ACME challenge/response
@@ -193,14 +223,25 @@ func (d *daemon) start() {
 				return crt, err
 			},
 		}
-	} else if d.sslCert != nil {
+	} else if d.certReloader != nil {
 		zap.L().Debug("enabling SSL support via X509 keypair")
 		tlsConfig = &tls.Config{
-			GetCertificate: func(c *tls.ClientHelloInfo) (*tls.Certificate, error) {
-				return certReloader.GetCertificate(), nil
-			},
-			Certificates: []tls.Certificate{*d.sslCert},
+			GetCertificate: d.certReloader.GetCertificate,
+		}
+	}
+
+	clientAuth, err := config.GetSSLClientAuth()
+	if err != nil {
+		zap.L().Fatal("invalid client authentication settings", zap.Error(err))
+	}
+	if tlsConfig != nil && clientAuth.Enabled {
+		zap.L().Debug("enabling mTLS client authentication", zap.String("mode", clientAuth.Mode))
+		verifier, err := NewClientCertVerifier(clientAuth)
+		if err != nil {
+			zap.L().Fatal("unable to load client CA certificates", zap.Error(err))
 		}
+		verifier.Apply(tlsConfig, clientAuth.Mode)
+		verifier.Listen(clientAuth.CRLReloadInterval)
 	}
 
 	if tlsConfig != nil {
@@ -218,12 +259,19 @@ func (d *daemon) start() {
 	}
 
 	maxsizeOpt := grpc.MaxRecvMsgSize(config.GetInt(config.MaxMessageSizeInMB) * 1024 * 1024)
-	d.grpcServer = grpc.NewServer(
-		grpc.UnknownServiceHandler(handler.NewGrpcHandler(d.components.ServiceMetaData())),
+	serverOpts := []grpc.ServerOption{
+		grpc.UnknownServiceHandler(handler.NewGrpcHandler(d.components.ServiceMetaData(), d.components.UpstreamPool(), d.components.ProcessPool())),
 		grpc.StreamInterceptor(d.components.GrpcStreamInterceptor()),
 		grpc.UnaryInterceptor(d.components.GrpcUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(training.StreamErrorInterceptor),
+		grpc.ChainUnaryInterceptor(training.UnaryErrorInterceptor),
 		maxsizeOpt,
-	)
+	}
+	if tlsConfig != nil && clientAuth.Enabled {
+		// the client certificate of the TLS listener is passed to the payment handlers
+		serverOpts = append(serverOpts, grpc.Creds(muxTLSCredentials{}))
+	}
+	d.grpcServer = grpc.NewServer(serverOpts...)
 	escrow.RegisterPaymentChannelStateServiceServer(d.grpcServer, d.components.PaymentChannelStateService())
 	escrow.RegisterProviderControlServiceServer(d.grpcServer, d.components.ProviderControlService())
 	escrow.RegisterFreeCallStateServiceServer(d.grpcServer, d.components.FreeCallStateService())
@@ -231,6 +279,15 @@ func (d *daemon) start() {
 	training.RegisterDaemonServer(d.grpcServer, d.components.TrainingService())
 	grpc_health_v1.RegisterHealthServer(d.grpcServer, d.components.DaemonHeartBeat())
 	configuration_service.RegisterConfigurationServiceServer(d.grpcServer, d.components.ConfigurationService())
+	usage.RegisterUsageReportServiceServer(d.grpcServer, d.components.UsageReportService())
+
+	if config.GetBool(config.GrpcReflectionEnabled) {
+		handler.RegisterReflection(d.grpcServer, d.components.ServiceMetaData)
+	}
+
+	if config.GetBool(config.RESTIngressEnabled) {
+		d.startRESTIngress()
+	}
 
 	var gmux GRPCMux
 
@@ -283,7 +340,7 @@ func (d *daemon) startWithTrafficSplit(exp *config.ExperimentalSettings) {
 
 	// Optional safety check: traffic_split is typically used when TLS is terminated
 	// before the daemon. If needed, you can enforce this.
-	if d.sslCert != nil || d.autoSSLDomain != "" {
+	if d.certReloader != nil || d.autoSSLDomain != "" {
 		zap.L().Warn("traffic_split mode is enabled, but TLS is also configured on daemon side; make sure this is really what you want")
 	}
 
@@ -319,6 +376,28 @@ func (d *daemon) startWithTrafficSplit(exp *config.ExperimentalSettings) {
 	)
 }
 
+// startRESTIngress serves the gRPC server on the in-memory listener used by the
+// REST ingress, so the REST calls pass the same interceptors as the gRPC calls.
+func (d *daemon) startRESTIngress() {
+	maxMessageSize := config.GetInt(config.MaxMessageSizeInMB) * 1024 * 1024
+	d.restListener = bufconn.Listen(1024 * 1024)
+	go d.grpcServer.Serve(d.restListener)
+
+	conn, err := grpc.NewClient("passthrough:///rest-ingress",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return d.restListener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)),
+	)
+	if err != nil {
+		zap.L().Fatal("unable to start REST ingress", zap.Error(err))
+	}
+	d.restConn = conn
+	d.restHandler = handler.NewRESTHandler(d.components.ServiceMetaData(), conn, maxMessageSize)
+	zap.L().Info("REST ingress enabled", zap.String("openapi", handler.OpenAPIPath))
+}
+
 // newGRPCWebServer wraps the gRPC server with grpc-web support.
 func (d *daemon) newGRPCWebServer() *grpcweb.WrappedGrpcServer {
 	return grpcweb.WrapServer(
@@ -333,6 +412,7 @@ func (d *daemon) newGRPCWebServer() *grpcweb.WrappedGrpcServer {
 //   - CORS preflight (OPTIONS),
 //   - gRPC-Web requests,
 //   - /encoding and /heartbeat endpoints,
+//   - REST ingress /v1/{service}/{method} and its /v1/openapi.json document,
 //   - 404 for everything else.
 func (d *daemon) newHTTPHandler(grpcWebServer *grpcweb.WrappedGrpcServer) http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
@@ -365,6 +445,23 @@ func (d *daemon) newHTTPHandler(grpcWebServer *grpcweb.WrappedGrpcServer) http.H
 		switch path {
 		case "encoding":
 			fmt.Fprintln(resp, d.components.ServiceMetaData().GetWireEncoding())
+		case "v1":
+			if d.restHandler == nil {
+				http.NotFound(resp, req)
+				return
+			}
+			if req.URL.Path == handler.OpenAPIPath {
+				document, err := handler.OpenAPIDocument(d.components.ServiceMetaData())
+				if err != nil {
+					http.Error(resp, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				resp.Header().Set("Content-Type", "application/json")
+				_, _ = resp.Write(document)
+				return
+			}
+			d.restHandler.ServeHTTP(resp, req)
+			return
 		case "heartbeat":
 			metrics.HeartbeatHandler(resp,
 				func() (*training.TrainingMetadata, error) {
@@ -384,7 +481,39 @@ func (d *daemon) newHTTPHandler(grpcWebServer *grpcweb.WrappedGrpcServer) http.H
 	})
 }
 
+// certExpiryAlert sends the notification about the certificate which expires
+// soon to the alerts email
+func certExpiryAlert(blockProc blockchain.Processor) CertExpiryAlert {
+	return func(cert *x509.Certificate) {
+		if config.GetString(config.AlertsEMail) == "" {
+			return
+		}
+		notification := &metrics.Notification{
+			Recipient: config.GetString(config.AlertsEMail),
+			Details:   fmt.Sprintf("certificate %v expires at %v", cert.Subject, cert.NotAfter),
+			Timestamp: time.Now().String(),
+			Message:   "SSL certificate of the daemon expires soon.",
+			Component: "Daemon",
+			DaemonID:  metrics.GetDaemonID(),
+			Level:     "WARNING",
+		}
+		currentBlock, err := blockProc.CurrentBlock()
+		if err != nil {
+			zap.L().Error("Error getting current block", zap.Error(err))
+		}
+		go notification.Send(currentBlock)
+	}
+}
+
 func (d *daemon) stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+
+	if d.restConn != nil {
+		d.restConn.Close()
+	}
+
 	if d.grpcServer != nil {
 		d.grpcServer.GracefulStop()
 	}
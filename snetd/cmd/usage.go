@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/singnet/snet-daemon/v6/usage"
+	"github.com/spf13/cobra"
+)
+
+// UsageCmd groups commands to inspect the usage recorded by the daemon
+var UsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Inspect usage recorded per caller",
+	Long:  "Usage commands read the per caller usage aggregated in the shared storage when usage_reporting_enabled is set",
+}
+
+// UsageReportCmd prints usage aggregated per sender, channel, method and time bucket
+var UsageReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Print usage per sender, channel, method and time bucket",
+	Long: "Print number of calls, free calls, errors and cogs charged per sender, payment channel, method and time bucket." +
+		" User can use 'snetd usage report --from 2024-05-01T00:00:00Z --sender {address} --format json' to filter the report.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunAndCleanup(cmd, args, newUsageReportCommand)
+	},
+}
+
+const (
+	UsageFromFlag      = "from"
+	UsageToFlag        = "to"
+	UsageSenderFlag    = "sender"
+	UsageChannelFlag   = "channel-id"
+	UsageMethodFlag    = "method"
+	UsageFormatFlag    = "format"
+	defaultUsageFormat = "csv"
+)
+
+type usageReportCommand struct {
+	storage *usage.UsageStorage
+	bucket  time.Duration
+	filter  *usage.Filter
+	format  string
+	output  io.Writer
+}
+
+func newUsageReportCommand(cmd *cobra.Command, args []string, components *Components) (command Command, err error) {
+	filter := &usage.Filter{}
+	if filter.From, err = getTimeFlag(cmd, UsageFromFlag); err != nil {
+		return
+	}
+	if filter.To, err = getTimeFlag(cmd, UsageToFlag); err != nil {
+		return
+	}
+	if filter.Sender, err = cmd.Flags().GetString(UsageSenderFlag); err != nil {
+		return
+	}
+	if filter.ChannelID, err = cmd.Flags().GetString(UsageChannelFlag); err != nil {
+		return
+	}
+	if filter.Method, err = cmd.Flags().GetString(UsageMethodFlag); err != nil {
+		return
+	}
+	format, err := cmd.Flags().GetString(UsageFormatFlag)
+	if err != nil {
+		return
+	}
+	if format != "csv" && format != "json" {
+		return nil, fmt.Errorf("unknown format %q, supported formats are csv and json", format)
+	}
+
+	command = &usageReportCommand{
+		storage: components.UsageStorage(),
+		bucket:  config.GetDuration(config.UsageReportingBucket),
+		filter:  filter,
+		format:  format,
+		output:  os.Stdout,
+	}
+	return
+}
+
+// getTimeFlag parses the flag as RFC3339 time or as unix timestamp in seconds
+func getTimeFlag(cmd *cobra.Command, name string) (t time.Time, err error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil || value == "" {
+		return
+	}
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return
+	}
+	var seconds int64
+	if _, scanErr := fmt.Sscan(value, &seconds); scanErr == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return t, fmt.Errorf("invalid --%v value %q, expected RFC3339 time or unix timestamp", name, value)
+}
+
+func (command *usageReportCommand) Run() (err error) {
+	reports, err := usage.Query(command.storage, command.bucket, command.filter)
+	if err != nil {
+		return
+	}
+	return usage.Write(command.output, command.format, reports)
+}
@@ -32,8 +32,12 @@ func (service customJWTokenServiceImpl) CreateToken(payLoad PayLoad, userAddress
 	//set the Expiry of the Token generated
 	atClaims["exp"] = time.Now().UTC().
 		Add(time.Minute * time.Duration(config.GetInt(config.TokenExpiryInMinutes))).Unix()
+	secretKey, err := config.GetSecret(config.TokenSecretKey)
+	if err != nil {
+		return nil, err
+	}
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
-	return jwtToken.SignedString([]byte(config.GetString(config.TokenSecretKey)))
+	return jwtToken.SignedString([]byte(secretKey))
 }
 
 func (service customJWTokenServiceImpl) VerifyToken(receivedToken CustomToken, payLoad PayLoad) (userAddress string, err error) {
@@ -42,7 +46,11 @@ func (service customJWTokenServiceImpl) VerifyToken(receivedToken CustomToken, p
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(config.GetString(config.TokenSecretKey)), nil
+		secretKey, err := config.GetSecret(config.TokenSecretKey)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secretKey), nil
 	})
 	if err != nil {
 		return "", err
@@ -0,0 +1,177 @@
+package training
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// refundAttempts is the number of the refund attempts, the payment channel
+	// is locked while the calls paid from it are in progress
+	refundAttempts   = 10
+	refundRetryDelay = 200 * time.Millisecond
+)
+
+// RefundFunc returns the amount to the sender of the payment channel
+type RefundFunc func(channelID *big.Int, amount *big.Int) error
+
+// Billing settles the payments of the validation and training when the job is
+// finished. It isn't an authorization hold: the payment is committed to the
+// channel like any other call and the billing only records it as "held" until
+// the job is finished. The payment is kept when the job succeeds, when the job
+// ends with the ERRORED status ErroredChargeRatio of the payment is kept and
+// the rest is refunded to the off-chain credit of the payment channel, which
+// pays the next calls of the channel. Every payment is kept in the storage with
+// its outcome.
+type Billing struct {
+	settings      *config.ModelTrainingBillingSettings
+	chargeStorage *ModelChargeStorage
+	modelStorage  *ModelStorage
+	refund        RefundFunc
+	now           func() time.Time
+}
+
+func NewBilling(settings *config.ModelTrainingBillingSettings, chargeStorage *ModelChargeStorage,
+	modelStorage *ModelStorage, refund RefundFunc) *Billing {
+	return &Billing{
+		settings:      settings,
+		chargeStorage: chargeStorage,
+		modelStorage:  modelStorage,
+		refund:        refund,
+		now:           time.Now,
+	}
+}
+
+// Hold keeps the payment of the model until the job is finished
+func (billing *Billing) Hold(modelID string, charge *ModelCharge) error {
+	charge.Status = ChargeHeld
+	charge.HeldAt = billing.now()
+	if err := billing.chargeStorage.AddCharge(billing.chargeStorage.buildChargeKey(modelID), charge); err != nil {
+		zap.L().Error("[Billing] can't hold the payment", zap.String("modelID", modelID), zap.Error(err))
+		return WrapError(ErrDaemonStorage, err.Error())
+	}
+	zap.L().Info("[Billing] payment is held", zap.String("modelID", modelID), zap.String("method", charge.Method),
+		zap.Any("amount", charge.Amount))
+
+	// the service provider may finish the job before the payment is held
+	model, err := billing.modelStorage.GetModel(modelID)
+	if err == nil && model != nil && !isPendingStatus(model.Status) {
+		billing.Settle(modelID, model.Status)
+	}
+	return nil
+}
+
+// Settle captures the held payments of the finished job, the errored jobs are
+// refunded partially. The refunds failed before are retried.
+func (billing *Billing) Settle(modelID string, status Status) {
+	ratio := 1.0
+	if status == Status_ERRORED {
+		ratio = billing.settings.ErroredChargeRatio
+	}
+	key := billing.chargeStorage.buildChargeKey(modelID)
+	settled, err := billing.chargeStorage.UpdateCharges(key, func(charge *ModelCharge) bool {
+		switch charge.Status {
+		case ChargeHeld:
+			charge.Captured = capturedAmount(charge.Amount, ratio)
+			charge.Refunded = new(big.Int).Sub(charge.Amount, charge.Captured)
+			charge.JobStatus = status
+			charge.SettledAt = billing.now()
+			charge.Status = ChargeCaptured
+			if charge.Refunded.Sign() > 0 {
+				charge.Status = ChargeRefunded
+			}
+			return true
+		case ChargeRefundFailed:
+			charge.Status = ChargeRefunded
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		zap.L().Error("[Billing] can't settle the payments", zap.String("modelID", modelID), zap.Error(err))
+		return
+	}
+
+	for _, charge := range settled {
+		zap.L().Info("[Billing] payment is settled", zap.String("modelID", modelID), zap.String("method", charge.Method),
+			zap.Any("captured", charge.Captured), zap.Any("refunded", charge.Refunded), zap.Stringer("jobStatus", charge.JobStatus))
+		if charge.Status != ChargeRefunded {
+			continue
+		}
+		if err = billing.refundCharge(charge); err == nil {
+			continue
+		}
+		zap.L().Error("[Billing] can't refund the payment", zap.String("modelID", modelID),
+			zap.Any("channelID", charge.ChannelId), zap.Any("amount", charge.Refunded), zap.Error(err))
+		chargeID := charge.ChargeId
+		_, err = billing.chargeStorage.UpdateCharges(key, func(charge *ModelCharge) bool {
+			if charge.ChargeId != chargeID || charge.Status != ChargeRefunded {
+				return false
+			}
+			charge.Status = ChargeRefundFailed
+			return true
+		})
+		if err != nil {
+			zap.L().Error("[Billing] can't save the failed refund", zap.String("modelID", modelID), zap.Error(err))
+		}
+	}
+}
+
+// refundCharge returns the refunded part of the payment to the payment channel
+func (billing *Billing) refundCharge(charge *ModelCharge) (err error) {
+	for attempt := 0; attempt < refundAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(refundRetryDelay)
+		}
+		if err = billing.refund(charge.ChannelId, charge.Refunded); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Charges returns the payments of the model
+func (billing *Billing) Charges(modelID string) ([]*ModelCharge, error) {
+	data, _, err := billing.chargeStorage.Get(billing.chargeStorage.buildChargeKey(modelID))
+	if err != nil {
+		return nil, WrapError(ErrDaemonStorage, err.Error())
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return data.Charges, nil
+}
+
+// capturedAmount returns the ratio of the amount rounded down
+func capturedAmount(amount *big.Int, ratio float64) *big.Int {
+	if ratio >= 1 {
+		return new(big.Int).Set(amount)
+	}
+	captured, _ := new(big.Float).Mul(new(big.Float).SetInt(amount), big.NewFloat(ratio)).Int(nil)
+	return captured
+}
+
+// toChargeDetails converts the charge to the get_model_charges message
+func (charge *ModelCharge) toChargeDetails() *ChargeDetails {
+	details := &ChargeDetails{
+		ChargeId:  charge.ChargeId,
+		Method:    charge.Method,
+		Status:    string(charge.Status),
+		Amount:    charge.Amount.Uint64(),
+		JobStatus: charge.JobStatus,
+		HeldAt:    timestamppb.New(charge.HeldAt),
+	}
+	if charge.ChannelId != nil {
+		details.ChannelId = charge.ChannelId.Uint64()
+	}
+	if charge.Status != ChargeHeld {
+		details.Captured = charge.Captured.Uint64()
+		details.Refunded = charge.Refunded.Uint64()
+		details.SettledAt = timestamppb.New(charge.SettledAt)
+	}
+	return details
+}
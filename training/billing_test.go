@@ -0,0 +1,140 @@
+package training
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	basestorage "github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRefunds records the refunds of the billing, the refunds fail while err is set
+type testRefunds struct {
+	mutex   sync.Mutex
+	err     error
+	refunds map[int64]int64
+}
+
+func (refunds *testRefunds) refund(channelID *big.Int, amount *big.Int) error {
+	refunds.mutex.Lock()
+	defer refunds.mutex.Unlock()
+	if refunds.err != nil {
+		return refunds.err
+	}
+	refunds.refunds[channelID.Int64()] += amount.Int64()
+	return nil
+}
+
+func newTestBilling(t *testing.T, ratio float64, modelIDs ...string) (*Billing, *ModelStorage, *testRefunds) {
+	orgMetadata, err := blockchain.InitOrganizationMetaDataFromJson([]byte(testJsonOrgMeta))
+	require.Nil(t, err)
+	memStorage := basestorage.NewMemStorage()
+	modelStorage := NewModelStorage(memStorage, orgMetadata)
+	for _, modelID := range modelIDs {
+		require.Nil(t, modelStorage.Put(modelStorage.buildModelKey(modelID), &ModelData{ModelId: modelID, Status: Status_VALIDATING}))
+	}
+	refunds := &testRefunds{refunds: map[int64]int64{}}
+	billing := NewBilling(&config.ModelTrainingBillingSettings{ErroredChargeRatio: ratio},
+		NewModelChargeStorage(memStorage, orgMetadata), modelStorage, refunds.refund)
+	return billing, modelStorage, refunds
+}
+
+func testCharge(chargeID string, channelID, amount int64) *ModelCharge {
+	return &ModelCharge{
+		ChargeId:  chargeID,
+		Method:    "validate_model",
+		ChannelId: big.NewInt(channelID),
+		Sender:    "0x3b2b3C2e2E7C93db335E69D827F3CC4bC2A2A2cB",
+		Amount:    big.NewInt(amount),
+	}
+}
+
+func TestBilling_Settle(t *testing.T) {
+	billing, _, refunds := newTestBilling(t, 0.25, "1", "2")
+
+	require.Nil(t, billing.Hold("1", testCharge("1/0/100", 1, 100)))
+	require.Nil(t, billing.Hold("1", testCharge("1/0/100", 1, 100)))
+	require.Nil(t, billing.Hold("2", testCharge("2/0/30", 2, 30)))
+
+	charges, err := billing.Charges("1")
+	require.Nil(t, err)
+	require.Len(t, charges, 1)
+	assert.Equal(t, ChargeHeld, charges[0].Status)
+
+	billing.Settle("1", Status_VALIDATED)
+	billing.Settle("2", Status_ERRORED)
+	billing.Settle("2", Status_ERRORED)
+
+	charges, err = billing.Charges("1")
+	require.Nil(t, err)
+	assert.Equal(t, ChargeCaptured, charges[0].Status)
+	assert.Equal(t, int64(100), charges[0].Captured.Int64())
+	assert.Equal(t, int64(0), charges[0].Refunded.Int64())
+	assert.Equal(t, Status_VALIDATED, charges[0].JobStatus)
+
+	charges, err = billing.Charges("2")
+	require.Nil(t, err)
+	assert.Equal(t, ChargeRefunded, charges[0].Status)
+	assert.Equal(t, int64(7), charges[0].Captured.Int64())
+	assert.Equal(t, int64(23), charges[0].Refunded.Int64())
+	assert.Equal(t, Status_ERRORED, charges[0].JobStatus)
+	assert.Equal(t, map[int64]int64{2: 23}, refunds.refunds)
+
+	details := charges[0].toChargeDetails()
+	assert.Equal(t, uint64(2), details.ChannelId)
+	assert.Equal(t, "refunded", details.Status)
+	assert.Equal(t, uint64(23), details.Refunded)
+	assert.NotNil(t, details.SettledAt)
+}
+
+func TestBilling_HoldFinishedJob(t *testing.T) {
+	billing, modelStorage, refunds := newTestBilling(t, 0, "1")
+	require.Nil(t, modelStorage.Put(modelStorage.buildModelKey("1"), &ModelData{ModelId: "1", Status: Status_ERRORED}))
+
+	require.Nil(t, billing.Hold("1", testCharge("1/0/50", 1, 50)))
+
+	charges, err := billing.Charges("1")
+	require.Nil(t, err)
+	assert.Equal(t, ChargeRefunded, charges[0].Status)
+	assert.Equal(t, int64(0), charges[0].Captured.Int64())
+	assert.Equal(t, map[int64]int64{1: 50}, refunds.refunds)
+}
+
+func TestBilling_RefundFailed(t *testing.T) {
+	billing, _, refunds := newTestBilling(t, 0.5, "1")
+	require.Nil(t, billing.Hold("1", testCharge("1/0/10", 1, 10)))
+
+	refunds.err = errors.New("channel is locked")
+	billing.Settle("1", Status_ERRORED)
+
+	charges, err := billing.Charges("1")
+	require.Nil(t, err)
+	assert.Equal(t, ChargeRefundFailed, charges[0].Status)
+	assert.Empty(t, refunds.refunds)
+
+	refunds.err = nil
+	billing.Settle("1", Status_ERRORED)
+
+	charges, err = billing.Charges("1")
+	require.Nil(t, err)
+	assert.Equal(t, ChargeRefunded, charges[0].Status)
+	assert.Equal(t, map[int64]int64{1: 5}, refunds.refunds)
+}
+
+func TestBilling_Charges(t *testing.T) {
+	billing, _, _ := newTestBilling(t, 1)
+	charges, err := billing.Charges("unknown")
+	assert.Nil(t, err)
+	assert.Empty(t, charges)
+}
+
+func Test_capturedAmount(t *testing.T) {
+	assert.Equal(t, int64(100), capturedAmount(big.NewInt(100), 1).Int64())
+	assert.Equal(t, int64(0), capturedAmount(big.NewInt(100), 0).Int64())
+	assert.Equal(t, int64(33), capturedAmount(big.NewInt(100), 0.333).Int64())
+}
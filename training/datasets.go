@@ -0,0 +1,435 @@
+package training
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/singnet/snet-daemon/v6/config"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DatasetUploadIdHeader returns the id of the new staged upload to the client,
+// the client resumes the interrupted upload with it
+const DatasetUploadIdHeader = "snet-dataset-upload-id"
+
+// IpfsAddFunc adds the content to the IPFS node and returns its CID
+type IpfsAddFunc func(ctx context.Context, content io.Reader) (string, error)
+
+// DatasetStager keeps the datasets uploaded by upload_and_validate in the
+// configured directory or IPFS node. The upload is checked against the dataset
+// requirements of the method, can be resumed by its id until UploadTTL passes
+// and is verified with SHA-256 or CID when it's complete. The service provider
+// receives the reference to the dataset instead of the byte stream.
+type DatasetStager struct {
+	settings  *config.ModelTrainingDatasetsSettings
+	addToIpfs IpfsAddFunc
+	now       func() time.Time
+
+	mutex  sync.Mutex
+	active map[string]struct{}
+}
+
+// StagedUpload is the state of the upload kept next to its data
+type StagedUpload struct {
+	UploadId  string    `json:"upload_id"`
+	ModelId   string    `json:"model_id"`
+	FileName  string    `json:"file_name"`
+	FileSize  uint64    `json:"file_size"`
+	Checksum  string    `json:"checksum"`
+	CreatedBy string    `json:"created_by"`
+	Reference string    `json:"reference"`
+	Sha256    string    `json:"sha256"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	received uint64
+	file     *os.File
+}
+
+// NewDatasetStager returns nil when the datasets are streamed to the service provider
+func NewDatasetStager(settings *config.ModelTrainingDatasetsSettings, addToIpfs IpfsAddFunc) (*DatasetStager, error) {
+	if settings.Storage == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(settings.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("can't create the datasets dir: %w", err)
+	}
+	return &DatasetStager{
+		settings:  settings,
+		addToIpfs: addToIpfs,
+		now:       time.Now,
+		active:    make(map[string]struct{}),
+	}, nil
+}
+
+// Begin starts the new upload when uploadID is empty or resumes the upload,
+// the upload is locked until Release
+func (stager *DatasetStager) Begin(uploadID, modelID, signer string, input *UploadInput, checksum string,
+	requirements *MethodMetadata) (upload *StagedUpload, err error) {
+	stager.removeExpired()
+	if uploadID == "" {
+		if err = checkDatasetRequirements(input.FileName, input.FileSize, requirements); err != nil {
+			return nil, err
+		}
+		if uploadID, err = newUploadID(); err != nil {
+			return nil, WrapError(ErrDatasetUpload, err.Error())
+		}
+		upload = &StagedUpload{
+			UploadId:  uploadID,
+			ModelId:   modelID,
+			FileName:  filepath.Base(input.FileName),
+			FileSize:  input.FileSize,
+			Checksum:  checksum,
+			CreatedBy: signer,
+		}
+	} else if upload, err = stager.load(uploadID); err != nil {
+		return nil, err
+	}
+	if upload.ModelId != modelID {
+		return nil, WrapError(ErrDatasetUploadNotFound, uploadID)
+	}
+
+	if err = stager.lock(uploadID); err != nil {
+		return nil, err
+	}
+	if upload.Reference == "" {
+		upload.UpdatedAt = stager.now()
+		upload.file, err = os.OpenFile(stager.partPath(uploadID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err == nil {
+			err = stager.save(upload)
+		}
+		if err != nil {
+			stager.Release(upload)
+			return nil, WrapError(ErrDatasetUpload, err.Error())
+		}
+	}
+	return upload, nil
+}
+
+// Write appends the chunk at offset to the upload, the bytes received already are skipped
+func (stager *DatasetStager) Write(upload *StagedUpload, offset uint64, data []byte) error {
+	if upload.Reference != "" {
+		return nil
+	}
+	if offset > upload.received {
+		return WrapError(ErrDatasetUpload, fmt.Sprintf("offset %v is after the received %v bytes", offset, upload.received))
+	}
+	skip := upload.received - offset
+	if skip >= uint64(len(data)) {
+		return nil
+	}
+	data = data[skip:]
+	if upload.received+uint64(len(data)) > upload.FileSize {
+		return WrapError(ErrDatasetRequirements, fmt.Sprintf("dataset is bigger than the declared file_size %v", upload.FileSize))
+	}
+	if _, err := upload.file.Write(data); err != nil {
+		return WrapError(ErrDatasetUpload, err.Error())
+	}
+	upload.received += uint64(len(data))
+	return nil
+}
+
+// Finish verifies the complete upload and stores the dataset, the reference to
+// the dataset is returned
+func (stager *DatasetStager) Finish(ctx context.Context, upload *StagedUpload) (reference string, err error) {
+	if upload.Reference != "" {
+		return upload.Reference, nil
+	}
+	if upload.file != nil {
+		upload.file.Close()
+		upload.file = nil
+	}
+	upload.UpdatedAt = stager.now()
+	if err = stager.save(upload); err != nil {
+		return "", WrapError(ErrDatasetUpload, err.Error())
+	}
+	if upload.received < upload.FileSize {
+		return "", WrapError(ErrDatasetIncomplete, fmt.Sprintf("received %v of %v bytes, resume the upload %v",
+			upload.received, upload.FileSize, upload.UploadId))
+	}
+
+	path := stager.partPath(upload.UploadId)
+	sum, err := fileSha256(path)
+	if err != nil {
+		return "", WrapError(ErrDatasetUpload, err.Error())
+	}
+	expectedCID, err := checkSha256(upload.Checksum, sum)
+	if err != nil {
+		stager.remove(upload.UploadId)
+		return "", err
+	}
+
+	switch stager.settings.Storage {
+	case config.DatasetStorageIpfs:
+		file, err := os.Open(path)
+		if err != nil {
+			return "", WrapError(ErrDatasetUpload, err.Error())
+		}
+		added, err := stager.addToIpfs(ctx, file)
+		file.Close()
+		if err != nil {
+			return "", WrapError(ErrDatasetUpload, fmt.Sprintf("can't add the dataset to IPFS: %v", err))
+		}
+		if expectedCID != "" && added != expectedCID {
+			stager.remove(upload.UploadId)
+			return "", WrapError(ErrDatasetChecksum, fmt.Sprintf("CID %v, expected %v", added, expectedCID))
+		}
+		if err = os.Remove(path); err != nil {
+			zap.L().Warn("[DatasetStager] can't remove the dataset added to IPFS", zap.String("path", path), zap.Error(err))
+		}
+		reference = "ipfs://" + added
+	default:
+		if expectedCID != "" {
+			stager.remove(upload.UploadId)
+			return "", WrapError(ErrDatasetChecksum, "CID is verified only with the ipfs storage, use SHA-256")
+		}
+		datasetPath, err := filepath.Abs(filepath.Join(stager.settings.Dir, upload.UploadId+"-"+upload.FileName))
+		if err == nil {
+			err = os.Rename(path, datasetPath)
+		}
+		if err != nil {
+			return "", WrapError(ErrDatasetUpload, err.Error())
+		}
+		reference = "file://" + filepath.ToSlash(datasetPath)
+	}
+
+	upload.Reference = reference
+	upload.Sha256 = sum
+	if err = stager.save(upload); err != nil {
+		return "", WrapError(ErrDatasetUpload, err.Error())
+	}
+	zap.L().Info("[DatasetStager] dataset is staged", zap.String("modelID", upload.ModelId),
+		zap.String("uploadID", upload.UploadId), zap.String("reference", reference), zap.String("sha256", sum))
+	return reference, nil
+}
+
+// Release unlocks the upload
+func (stager *DatasetStager) Release(upload *StagedUpload) {
+	if upload.file != nil {
+		upload.file.Close()
+		upload.file = nil
+	}
+	stager.mutex.Lock()
+	defer stager.mutex.Unlock()
+	delete(stager.active, upload.UploadId)
+}
+
+// Get returns the state of the upload of the model
+func (stager *DatasetStager) Get(modelID, uploadID string) (*DatasetUpload, error) {
+	upload, err := stager.load(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.ModelId != modelID {
+		return nil, WrapError(ErrDatasetUploadNotFound, uploadID)
+	}
+	response := &DatasetUpload{
+		UploadId:      upload.UploadId,
+		ModelId:       upload.ModelId,
+		FileName:      upload.FileName,
+		FileSize:      upload.FileSize,
+		ReceivedBytes: upload.received,
+		Reference:     upload.Reference,
+		Sha256:        upload.Sha256,
+	}
+	if upload.Reference == "" {
+		response.ExpiresAt = timestamppb.New(upload.UpdatedAt.Add(stager.settings.UploadTTL))
+	}
+	return response, nil
+}
+
+func (stager *DatasetStager) lock(uploadID string) error {
+	stager.mutex.Lock()
+	defer stager.mutex.Unlock()
+	if _, ok := stager.active[uploadID]; ok {
+		return WrapError(ErrDatasetUploadInProgress, uploadID)
+	}
+	stager.active[uploadID] = struct{}{}
+	return nil
+}
+
+func (stager *DatasetStager) statePath(uploadID string) string {
+	return filepath.Join(stager.settings.Dir, uploadID+".json")
+}
+
+func (stager *DatasetStager) partPath(uploadID string) string {
+	return filepath.Join(stager.settings.Dir, uploadID+".part")
+}
+
+// load reads the state of the upload, the received bytes are the size of its data
+func (stager *DatasetStager) load(uploadID string) (*StagedUpload, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return nil, WrapError(ErrDatasetUploadNotFound, uploadID)
+	}
+	content, err := os.ReadFile(stager.statePath(uploadID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, WrapError(ErrDatasetUploadNotFound, uploadID)
+	}
+	if err != nil {
+		return nil, WrapError(ErrDatasetUpload, err.Error())
+	}
+	upload := &StagedUpload{}
+	if err = json.Unmarshal(content, upload); err != nil {
+		return nil, WrapError(ErrDatasetUpload, err.Error())
+	}
+	if upload.Reference != "" {
+		upload.received = upload.FileSize
+		return upload, nil
+	}
+	if stager.expired(upload) {
+		return nil, WrapError(ErrDatasetUploadNotFound, uploadID)
+	}
+	if info, err := os.Stat(stager.partPath(uploadID)); err == nil {
+		upload.received = uint64(info.Size())
+	}
+	return upload, nil
+}
+
+func (stager *DatasetStager) save(upload *StagedUpload) error {
+	content, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	tmp := stager.statePath(upload.UploadId) + ".tmp"
+	if err = os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, stager.statePath(upload.UploadId))
+}
+
+func (stager *DatasetStager) expired(upload *StagedUpload) bool {
+	return upload.Reference == "" && stager.now().Sub(upload.UpdatedAt) > stager.settings.UploadTTL
+}
+
+func (stager *DatasetStager) remove(uploadID string) {
+	for _, path := range []string{stager.partPath(uploadID), stager.statePath(uploadID)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			zap.L().Warn("[DatasetStager] can't remove the upload", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// removeExpired removes the incomplete uploads which aren't resumed for UploadTTL
+func (stager *DatasetStager) removeExpired() {
+	states, err := filepath.Glob(filepath.Join(stager.settings.Dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, state := range states {
+		uploadID := strings.TrimSuffix(filepath.Base(state), ".json")
+		content, err := os.ReadFile(state)
+		if err != nil {
+			continue
+		}
+		upload := &StagedUpload{}
+		if json.Unmarshal(content, upload) != nil || !stager.expired(upload) {
+			continue
+		}
+		stager.mutex.Lock()
+		_, active := stager.active[uploadID]
+		stager.mutex.Unlock()
+		if !active {
+			zap.L().Info("[DatasetStager] removing the expired upload", zap.String("uploadID", uploadID))
+			stager.remove(uploadID)
+		}
+	}
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func fileSha256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checkSha256 verifies the SHA-256 checksum, the CID checksum is returned to be
+// verified by the IPFS node
+func checkSha256(checksum, sum string) (expectedCID string, err error) {
+	checksum = strings.TrimPrefix(strings.TrimSpace(checksum), "sha256:")
+	if checksum == "" {
+		return "", nil
+	}
+	if _, err = hex.DecodeString(checksum); err == nil && len(checksum) == sha256.Size*2 {
+		if !strings.EqualFold(checksum, sum) {
+			return "", WrapError(ErrDatasetChecksum, fmt.Sprintf("SHA-256 %v, expected %v", sum, checksum))
+		}
+		return "", nil
+	}
+	parsed, err := cid.Decode(checksum)
+	if err != nil {
+		return "", WrapError(ErrDatasetChecksum, fmt.Sprintf("checksum %q is neither SHA-256 nor CID", checksum))
+	}
+	return parsed.String(), nil
+}
+
+// checkDatasetRequirements checks the dataset against the dataset_max_size_mb,
+// dataset_max_size_single_file_mb, dataset_type and dataset_files_type options of the method
+func checkDatasetRequirements(fileName string, fileSize uint64, requirements *MethodMetadata) error {
+	if fileName == "" || filepath.Base(fileName) != fileName || fileName == ".." {
+		return WrapError(ErrDatasetRequirements, fmt.Sprintf("invalid file_name %q", fileName))
+	}
+	if fileSize == 0 {
+		return WrapError(ErrDatasetRequirements, "file_size is required")
+	}
+	if requirements == nil {
+		return nil
+	}
+
+	var maxSize uint64
+	for _, limit := range []uint64{requirements.DatasetMaxSizeMb, requirements.DatasetMaxSizeSingleFileMb} {
+		if limit > 0 && (maxSize == 0 || limit < maxSize) {
+			maxSize = limit
+		}
+	}
+	if maxSize > 0 && fileSize > maxSize<<20 {
+		return WrapError(ErrDatasetRequirements, fmt.Sprintf("file_size %v is more than %v MB", fileSize, maxSize))
+	}
+
+	extensions := append(parseDatasetTypes(requirements.DatasetType), parseDatasetTypes(requirements.DatasetFilesType)...)
+	if len(extensions) == 0 {
+		return nil
+	}
+	for _, extension := range extensions {
+		if strings.HasSuffix(strings.ToLower(fileName), "."+extension) {
+			return nil
+		}
+	}
+	return WrapError(ErrDatasetRequirements, fmt.Sprintf("file %q isn't one of %v", fileName, strings.Join(extensions, ", ")))
+}
+
+// parseDatasetTypes parses the single value or the array of the dataset types, e.g. "zip, tar.gz" or ["jpg", "png"]
+func parseDatasetTypes(types string) (extensions []string) {
+	for _, extension := range strings.FieldsFunc(types, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '[' || r == ']' || r == '"' || r == '\''
+	}) {
+		extensions = append(extensions, strings.ToLower(strings.TrimPrefix(extension, ".")))
+	}
+	return extensions
+}
@@ -0,0 +1,133 @@
+package training
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDatasetStager(t *testing.T, storage string, addToIpfs IpfsAddFunc) *DatasetStager {
+	stager, err := NewDatasetStager(&config.ModelTrainingDatasetsSettings{
+		Storage:   storage,
+		Dir:       t.TempDir(),
+		UploadTTL: time.Hour,
+	}, addToIpfs)
+	require.Nil(t, err)
+	return stager
+}
+
+func TestDatasetStager_Resume(t *testing.T) {
+	stager := newTestDatasetStager(t, config.DatasetStorageLocal, nil)
+	dataset := []byte("0123456789")
+	sum := sha256.Sum256(dataset)
+	input := &UploadInput{ModelId: "1", FileName: "dataset.zip", FileSize: uint64(len(dataset))}
+	requirements := &MethodMetadata{DatasetType: "zip, tar.gz", DatasetMaxSizeMb: 1}
+
+	upload, err := stager.Begin("", "1", "0x01", input, hex.EncodeToString(sum[:]), requirements)
+	require.Nil(t, err)
+	_, err = stager.Begin(upload.UploadId, "1", "0x01", input, "", requirements)
+	assert.True(t, errors.Is(err, ErrDatasetUploadInProgress))
+	require.Nil(t, stager.Write(upload, 0, dataset[:4]))
+	_, err = stager.Finish(context.Background(), upload)
+	assert.True(t, errors.Is(err, ErrDatasetIncomplete))
+	stager.Release(upload)
+
+	state, err := stager.Get("1", upload.UploadId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(4), state.ReceivedBytes)
+	assert.NotNil(t, state.ExpiresAt)
+	_, err = stager.Get("2", upload.UploadId)
+	assert.True(t, errors.Is(err, ErrDatasetUploadNotFound))
+
+	upload, err = stager.Begin(upload.UploadId, "1", "0x01", input, "", requirements)
+	require.Nil(t, err)
+	assert.NotNil(t, stager.Write(upload, 6, dataset[6:]))
+	require.Nil(t, stager.Write(upload, 2, dataset[2:7]))
+	require.Nil(t, stager.Write(upload, 7, dataset[7:]))
+	reference, err := stager.Finish(context.Background(), upload)
+	require.Nil(t, err)
+	stager.Release(upload)
+
+	assert.True(t, strings.HasPrefix(reference, "file://"))
+	content, err := os.ReadFile(strings.TrimPrefix(reference, "file://"))
+	require.Nil(t, err)
+	assert.Equal(t, dataset, content)
+
+	state, err = stager.Get("1", upload.UploadId)
+	require.Nil(t, err)
+	assert.Equal(t, reference, state.Reference)
+	assert.Equal(t, hex.EncodeToString(sum[:]), state.Sha256)
+	assert.Nil(t, state.ExpiresAt)
+}
+
+func TestDatasetStager_Checksum(t *testing.T) {
+	var added []byte
+	addToIpfs := func(ctx context.Context, content io.Reader) (string, error) {
+		added, _ = io.ReadAll(content)
+		return "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", nil
+	}
+	stager := newTestDatasetStager(t, config.DatasetStorageIpfs, addToIpfs)
+	input := &UploadInput{ModelId: "1", FileName: "dataset.csv", FileSize: 3}
+
+	upload, err := stager.Begin("", "1", "0x01", input, strings.Repeat("0", 64), nil)
+	require.Nil(t, err)
+	require.Nil(t, stager.Write(upload, 0, []byte("abc")))
+	_, err = stager.Finish(context.Background(), upload)
+	assert.True(t, errors.Is(err, ErrDatasetChecksum))
+	stager.Release(upload)
+	_, err = stager.Get("1", upload.UploadId)
+	assert.True(t, errors.Is(err, ErrDatasetUploadNotFound))
+
+	upload, err = stager.Begin("", "1", "0x01", input, "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", nil)
+	require.Nil(t, err)
+	require.Nil(t, stager.Write(upload, 0, []byte("abc")))
+	reference, err := stager.Finish(context.Background(), upload)
+	require.Nil(t, err)
+	stager.Release(upload)
+	assert.Equal(t, "ipfs://QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", reference)
+	assert.Equal(t, []byte("abc"), added)
+}
+
+func TestDatasetStager_Expired(t *testing.T) {
+	stager := newTestDatasetStager(t, config.DatasetStorageLocal, nil)
+	now := time.Now()
+	stager.now = func() time.Time { return now }
+
+	upload, err := stager.Begin("", "1", "0x01", &UploadInput{FileName: "dataset.zip", FileSize: 5}, "", nil)
+	require.Nil(t, err)
+	require.Nil(t, stager.Write(upload, 0, []byte("ab")))
+	stager.Release(upload)
+
+	now = now.Add(2 * time.Hour)
+	_, err = stager.Begin(upload.UploadId, "1", "0x01", &UploadInput{}, "", nil)
+	assert.True(t, errors.Is(err, ErrDatasetUploadNotFound))
+	_, err = os.Stat(stager.partPath(upload.UploadId))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_checkDatasetRequirements(t *testing.T) {
+	requirements := &MethodMetadata{
+		DatasetMaxSizeMb:           10,
+		DatasetMaxSizeSingleFileMb: 2,
+		DatasetType:                `["zip", "tar.gz"]`,
+		DatasetFilesType:           "jpg",
+	}
+	assert.Nil(t, checkDatasetRequirements("images.tar.gz", 1<<20, requirements))
+	assert.Nil(t, checkDatasetRequirements("image.JPG", 1<<20, requirements))
+	assert.Nil(t, checkDatasetRequirements("dataset.csv", 1<<20, nil))
+	for _, fileName := range []string{"dataset.csv", "", "../dataset.zip", "dir/dataset.zip"} {
+		assert.True(t, errors.Is(checkDatasetRequirements(fileName, 1<<20, requirements), ErrDatasetRequirements), fileName)
+	}
+	assert.True(t, errors.Is(checkDatasetRequirements("dataset.zip", 3<<20, requirements), ErrDatasetRequirements))
+	assert.True(t, errors.Is(checkDatasetRequirements("dataset.zip", 0, requirements), ErrDatasetRequirements))
+}
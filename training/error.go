@@ -1,8 +1,14 @@
 package training
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/singnet/snet-daemon/v6/errs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
 // Base Error
@@ -14,6 +20,8 @@ var (
 	ErrAccessToModel     = errors.New("unable to access model")
 	ErrDaemonStorage     = errors.New("daemon storage error")
 	ErrModelDoesntExist  = errors.New("model doesn't exist")
+	ErrModelVersion      = errors.New("invalid model version")
+	ErrDatasetUpload     = errors.New("dataset upload error")
 )
 
 // Specific Error
@@ -29,6 +37,13 @@ var (
 	ErrPutModelStorage           = fmt.Errorf("%w: error in putting data to model storage", ErrDaemonStorage)
 	ErrEmptyModelID              = fmt.Errorf("%w: model id can't be empty", ErrInvalidRequest)
 	ErrNotOwnerModel             = fmt.Errorf("%w: only owner can change the model state", ErrUpdatingModel)
+	ErrModelVersionDoesntExist   = fmt.Errorf("%w: version doesn't exist", ErrModelVersion)
+	ErrModelVersionNotReady      = fmt.Errorf("%w: version isn't ready to use", ErrModelVersion)
+	ErrDatasetRequirements       = fmt.Errorf("%w: dataset doesn't meet the requirements of the method", ErrDatasetUpload)
+	ErrDatasetUploadNotFound     = fmt.Errorf("%w: upload doesn't exist or is expired", ErrDatasetUpload)
+	ErrDatasetUploadInProgress   = fmt.Errorf("%w: upload is in progress", ErrDatasetUpload)
+	ErrDatasetIncomplete         = fmt.Errorf("%w: upload is incomplete", ErrDatasetUpload)
+	ErrDatasetChecksum           = fmt.Errorf("%w: checksum mismatch", ErrDatasetUpload)
 )
 
 // WrapError formats and wraps an error with additional context.
@@ -38,3 +53,64 @@ func WrapError(baseErr error, message string) error {
 	}
 	return fmt.Errorf("%w: %s", baseErr, message)
 }
+
+// statusErrors maps the training errors to the daemon errors, the specific
+// errors are checked before the base errors they wrap
+var statusErrors = []struct {
+	err        error
+	definition *errs.Definition
+}{
+	{ErrNoAuthorization, errs.ErrTrainingUnauthorized},
+	{ErrBadAuthorization, errs.ErrTrainingUnauthorized},
+	{ErrNotOwnerModel, errs.ErrTrainingAccessDenied},
+	{ErrModelVersionDoesntExist, errs.ErrModelVersionNotFound},
+	{ErrDatasetUploadNotFound, errs.ErrDatasetUploadNotFound},
+	{ErrInvalidRequest, errs.ErrTrainingInvalidRequest},
+	{ErrAccessToModel, errs.ErrTrainingAccessDenied},
+	{ErrModelDoesntExist, errs.ErrModelNotFound},
+	{ErrModelVersion, errs.ErrInvalidModelVersion},
+	{ErrDatasetUpload, errs.ErrDatasetUploadFailed},
+	{ErrUpdatingModel, errs.ErrModelUpdateFailed},
+	{ErrServiceInvocation, errs.ErrTrainingService},
+	{ErrServiceIssue, errs.ErrTrainingService},
+	{ErrDaemonStorage, errs.ErrTrainingStorage},
+}
+
+// StatusError converts the training error to the daemon error with the ErrorInfo
+// detail, the errors which already have the gRPC status are returned as is.
+func StatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, mapping := range statusErrors {
+		if errors.Is(err, mapping.err) {
+			return mapping.definition.New("%v", err)
+		}
+	}
+	return errs.ErrTraining.New("%v", err)
+}
+
+func isTrainingMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+Daemon_ServiceDesc.ServiceName+"/")
+}
+
+// UnaryErrorInterceptor converts the errors of the training methods by StatusError
+func UnaryErrorInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if isTrainingMethod(info.FullMethod) {
+		err = StatusError(err)
+	}
+	return resp, err
+}
+
+// StreamErrorInterceptor converts the errors of the training methods by StatusError
+func StreamErrorInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if isTrainingMethod(info.FullMethod) {
+		err = StatusError(err)
+	}
+	return err
+}
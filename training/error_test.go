@@ -0,0 +1,54 @@
+package training
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/errs"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		err        error
+		definition *errs.Definition
+	}{
+		{ErrNoAuthorization, errs.ErrTrainingUnauthorized},
+		{ErrEmptyModelID, errs.ErrTrainingInvalidRequest},
+		{WrapError(ErrModelDoesntExist, "model 42"), errs.ErrModelNotFound},
+		{ErrModelVersionDoesntExist, errs.ErrModelVersionNotFound},
+		{ErrModelVersionNotReady, errs.ErrInvalidModelVersion},
+		{ErrDatasetUploadNotFound, errs.ErrDatasetUploadNotFound},
+		{ErrDatasetChecksum, errs.ErrDatasetUploadFailed},
+		{ErrNotOwnerModel, errs.ErrTrainingAccessDenied},
+		{ErrEmptyResponse, errs.ErrTrainingService},
+		{ErrGetModelStorage, errs.ErrTrainingStorage},
+		{errors.New("unexpected"), errs.ErrTraining},
+	}
+	for _, test := range tests {
+		err := StatusError(test.err)
+		assert.True(t, test.definition.Is(err), test.err.Error())
+		assert.Equal(t, test.definition.Code, status.Code(err))
+		assert.Equal(t, test.err.Error(), status.Convert(err).Message())
+	}
+
+	assert.Nil(t, StatusError(nil))
+	statusErr := status.Error(codes.Aborted, "aborted")
+	assert.Equal(t, statusErr, StatusError(statusErr))
+}
+
+func TestUnaryErrorInterceptor(t *testing.T) {
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, ErrModelDoesntExist
+	}
+
+	_, err := UnaryErrorInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: Daemon_GetModel_FullMethodName}, handler)
+	assert.True(t, errs.ErrModelNotFound.Is(err))
+
+	_, err = UnaryErrorInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/example_service.Calculator/add"}, handler)
+	assert.Equal(t, ErrModelDoesntExist, err)
+}
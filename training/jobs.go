@@ -0,0 +1,359 @@
+package training
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/config"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// watcherBuffer is the number of the events a slow watch_model stream can lag behind,
+// the stream is closed when it lags more
+const watcherBuffer = 16
+
+// statusFunc requests the status of the model from the service provider
+type statusFunc func(ctx context.Context, modelID string) (*StatusResponse, error)
+
+// jobSchedule is the next status request of the tracked job
+type jobSchedule struct {
+	nextCheck  time.Time
+	interval   time.Duration
+	lastChange time.Time
+	running    bool
+}
+
+// JobTracker follows the validation and training jobs of the models: it requests
+// the status of the pending models from the service provider with a bounded
+// number of workers, backs off while the status doesn't change, keeps the status
+// history and notifies the watch_model streams. The status is recorded in the
+// running version of the model and the payments of the finished jobs are settled
+// by billing. The jobs which don't change their status for JobTimeout are dropped
+// and charged as errored.
+type JobTracker struct {
+	settings       *config.ModelTrainingJobsSettings
+	jobStorage     *ModelJobStorage
+	modelStorage   *ModelStorage
+	pendingStorage *PendingModelStorage
+	billing        *Billing
+	versions       *ModelVersions
+	status         statusFunc
+	now            func() time.Time
+
+	mutex     sync.Mutex
+	schedules map[string]*jobSchedule
+	watchers  map[string]map[chan *ModelJobEvent]struct{}
+	workers   chan struct{}
+}
+
+func NewJobTracker(settings *config.ModelTrainingJobsSettings, jobStorage *ModelJobStorage, modelStorage *ModelStorage,
+	pendingStorage *PendingModelStorage, billing *Billing, versions *ModelVersions, status statusFunc) *JobTracker {
+	return &JobTracker{
+		settings:       settings,
+		jobStorage:     jobStorage,
+		modelStorage:   modelStorage,
+		pendingStorage: pendingStorage,
+		billing:        billing,
+		versions:       versions,
+		status:         status,
+		now:            time.Now,
+		schedules:      make(map[string]*jobSchedule),
+		watchers:       make(map[string]map[chan *ModelJobEvent]struct{}),
+		workers:        make(chan struct{}, settings.Workers),
+	}
+}
+
+// isPendingStatus returns true while the service provider works on the model
+func isPendingStatus(status Status) bool {
+	return status == Status_VALIDATING || status == Status_TRAINING
+}
+
+// Update sets the status of the model reported by the service provider, adds it
+// to the history and notifies the watchers. The model is tracked while it's
+// validating or training.
+func (tracker *JobTracker) Update(modelID string, response *StatusResponse) (data *ModelData, err error) {
+	key := tracker.modelStorage.buildModelKey(modelID)
+	data, ok, err := tracker.modelStorage.Get(key)
+	if err != nil || !ok || data == nil {
+		zap.L().Error("[JobTracker] can't get model data from storage", zap.String("modelID", modelID), zap.Error(err))
+		return data, WrapError(ErrGetModelStorage, fmt.Sprintf("model %v: %v", modelID, err))
+	}
+	event := &ModelJobEvent{
+		Status:   response.Status,
+		Progress: response.Progress,
+		Metrics:  response.Metrics,
+		Message:  response.Message,
+		Time:     tracker.now(),
+	}
+	var ready *ModelVersionData
+	if tracker.versions != nil {
+		ready, _ = tracker.versions.Update(modelID, event)
+	}
+
+	if data.Status != response.Status || (ready != nil && data.CurrentVersion != ready.Version) {
+		data.Status = response.Status
+		if ready != nil {
+			data.CurrentVersion = ready.Version
+		}
+		if err = tracker.modelStorage.Put(key, data); err != nil {
+			zap.L().Error("[JobTracker] can't update model status", zap.String("modelID", modelID), zap.Error(err))
+			return data, WrapError(ErrPutModelStorage, err.Error())
+		}
+	}
+
+	added, err := tracker.jobStorage.AddEvent(tracker.jobStorage.buildJobKey(modelID), event, tracker.settings.HistorySize)
+	if err != nil {
+		zap.L().Error("[JobTracker] can't save status event", zap.String("modelID", modelID), zap.Error(err))
+	}
+
+	pending := isPendingStatus(response.Status)
+	tracker.mutex.Lock()
+	if added {
+		tracker.publish(modelID, event)
+	}
+	if pending {
+		tracker.schedule(modelID, added)
+	} else {
+		tracker.finish(modelID)
+	}
+	tracker.mutex.Unlock()
+
+	if err = tracker.setPending(modelID, pending); err != nil {
+		zap.L().Error("[JobTracker] can't update pending models", zap.String("modelID", modelID), zap.Error(err))
+	}
+	if !pending && tracker.billing != nil {
+		tracker.billing.Settle(modelID, response.Status)
+	}
+	return data, nil
+}
+
+// setPending adds the model to the pending models or removes it
+func (tracker *JobTracker) setPending(modelID string, pending bool) error {
+	isPending, err := tracker.isPending(modelID)
+	if err != nil || isPending == pending {
+		return err
+	}
+	key := tracker.pendingStorage.buildPendingModelKey()
+	if pending {
+		return tracker.pendingStorage.AddPendingModelId(key, modelID)
+	}
+	return tracker.pendingStorage.RemovePendingModelId(key, modelID)
+}
+
+// schedule plans the next status request of the job, the interval is reset
+// when the status changed and doubled otherwise. The caller holds the mutex.
+func (tracker *JobTracker) schedule(modelID string, changed bool) *jobSchedule {
+	now := tracker.now()
+	schedule, ok := tracker.schedules[modelID]
+	if !ok {
+		schedule = &jobSchedule{interval: tracker.settings.PollInterval, lastChange: now}
+		tracker.schedules[modelID] = schedule
+	} else if changed {
+		schedule.interval = tracker.settings.PollInterval
+		schedule.lastChange = now
+	} else {
+		schedule.interval = min(2*schedule.interval, tracker.settings.MaxPollInterval)
+	}
+	schedule.nextCheck = now.Add(schedule.interval)
+	return schedule
+}
+
+// finish stops tracking the job and closes its watchers. The caller holds the mutex.
+func (tracker *JobTracker) finish(modelID string) {
+	delete(tracker.schedules, modelID)
+	for watcher := range tracker.watchers[modelID] {
+		close(watcher)
+	}
+	delete(tracker.watchers, modelID)
+}
+
+// publish sends the event to the watchers of the model, the watchers which
+// can't keep up are closed. The caller holds the mutex.
+func (tracker *JobTracker) publish(modelID string, event *ModelJobEvent) {
+	for watcher := range tracker.watchers[modelID] {
+		select {
+		case watcher <- event:
+		default:
+			zap.L().Warn("[JobTracker] watcher is too slow, closing it", zap.String("modelID", modelID))
+			close(watcher)
+			delete(tracker.watchers[modelID], watcher)
+		}
+	}
+}
+
+// Watch returns the status history of the model and, if the model is still
+// validating or training, the channel of the next events which is closed when
+// the job is finished. stop must be called when the events aren't needed.
+func (tracker *JobTracker) Watch(modelID string) (history []*ModelJobEvent, events <-chan *ModelJobEvent, stop func(), err error) {
+	stop = func() {}
+	pending, err := tracker.isPending(modelID)
+	if err != nil {
+		return nil, nil, stop, err
+	}
+
+	var watcher chan *ModelJobEvent
+	if pending {
+		// subscribe before reading the history to not miss the events in between
+		watcher = make(chan *ModelJobEvent, watcherBuffer)
+		tracker.mutex.Lock()
+		if tracker.watchers[modelID] == nil {
+			tracker.watchers[modelID] = make(map[chan *ModelJobEvent]struct{})
+		}
+		tracker.watchers[modelID][watcher] = struct{}{}
+		tracker.mutex.Unlock()
+		stop = func() {
+			tracker.mutex.Lock()
+			defer tracker.mutex.Unlock()
+			if _, ok := tracker.watchers[modelID][watcher]; ok {
+				delete(tracker.watchers[modelID], watcher)
+				close(watcher)
+			}
+		}
+		events = watcher
+	}
+
+	jobData, _, err := tracker.jobStorage.Get(tracker.jobStorage.buildJobKey(modelID))
+	if err != nil {
+		stop()
+		return nil, nil, func() {}, WrapError(ErrDaemonStorage, err.Error())
+	}
+	if jobData != nil {
+		history = jobData.Events
+	}
+	return history, events, stop, nil
+}
+
+func (tracker *JobTracker) isPending(modelID string) (bool, error) {
+	pendingData, _, err := tracker.pendingStorage.Get(tracker.pendingStorage.buildPendingModelKey())
+	if err != nil {
+		return false, WrapError(ErrDaemonStorage, err.Error())
+	}
+	return pendingData != nil && slices.Contains(pendingData.ModelIDs, modelID), nil
+}
+
+// Run requests the status of the pending models until ctx is done
+func (tracker *JobTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(tracker.settings.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			tracker.checkPending(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkPending starts the status requests of the pending models which are due,
+// the models which wait for a free worker are checked on the next tick
+func (tracker *JobTracker) checkPending(ctx context.Context) {
+	pendingData, _, err := tracker.pendingStorage.Get(tracker.pendingStorage.buildPendingModelKey())
+	if err != nil {
+		zap.L().Error("[JobTracker] can't get pending models", zap.Error(err))
+		return
+	}
+	var modelIDs []string
+	if pendingData != nil {
+		modelIDs = pendingData.ModelIDs
+	}
+
+	now := tracker.now()
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for modelID, schedule := range tracker.schedules {
+		// the job finished by another replica of the daemon
+		if !schedule.running && !slices.Contains(modelIDs, modelID) {
+			tracker.finish(modelID)
+		}
+	}
+	for _, modelID := range modelIDs {
+		if modelID == "" {
+			continue
+		}
+		schedule, ok := tracker.schedules[modelID]
+		if !ok {
+			// the job is started before the restart or by another replica of the daemon
+			schedule = &jobSchedule{interval: tracker.settings.PollInterval, lastChange: now, nextCheck: now}
+			tracker.schedules[modelID] = schedule
+		}
+		if schedule.running || now.Before(schedule.nextCheck) {
+			continue
+		}
+		select {
+		case tracker.workers <- struct{}{}:
+		default:
+			return
+		}
+		schedule.running = true
+		go tracker.check(ctx, modelID)
+	}
+}
+
+// check requests the status of the model from the service provider
+func (tracker *JobTracker) check(ctx context.Context, modelID string) {
+	defer func() { <-tracker.workers }()
+
+	statusCtx, cancel := context.WithTimeout(ctx, tracker.settings.StatusTimeout)
+	response, err := tracker.status(statusCtx, modelID)
+	cancel()
+	if err == nil && response == nil {
+		err = ErrEmptyResponse
+	}
+	if err != nil {
+		zap.L().Warn("[JobTracker] can't get model status, service provider should implement get_model_status",
+			zap.String("modelID", modelID), zap.Error(err))
+	} else {
+		_, err = tracker.Update(modelID, response)
+	}
+
+	if tracker.finishAbandoned(modelID, err != nil) {
+		zap.L().Warn("[JobTracker] dropping the job without status changes", zap.String("modelID", modelID),
+			zap.Duration("jobTimeout", tracker.settings.JobTimeout))
+		if err = tracker.setPending(modelID, false); err != nil {
+			zap.L().Error("[JobTracker] can't remove the dropped job", zap.String("modelID", modelID), zap.Error(err))
+		}
+		if tracker.versions != nil {
+			tracker.versions.Update(modelID, &ModelJobEvent{Status: Status_ERRORED, Message: "job timeout", Time: tracker.now()})
+		}
+		if tracker.billing != nil {
+			tracker.billing.Settle(modelID, Status_ERRORED)
+		}
+	}
+}
+
+// finishAbandoned releases the job after the status request and stops tracking
+// it if its status doesn't change for JobTimeout, failed backs off the job
+func (tracker *JobTracker) finishAbandoned(modelID string, failed bool) (abandoned bool) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	schedule, ok := tracker.schedules[modelID]
+	if !ok {
+		return false
+	}
+	schedule.running = false
+	if failed {
+		tracker.schedule(modelID, false)
+	}
+	if tracker.now().Sub(schedule.lastChange) < tracker.settings.JobTimeout {
+		return false
+	}
+	tracker.finish(modelID)
+	return true
+}
+
+// toStatusEvent converts the event to the watch_model message
+func (event *ModelJobEvent) toStatusEvent(modelID string) *ModelStatusEvent {
+	return &ModelStatusEvent{
+		ModelId:  modelID,
+		Status:   event.Status,
+		Progress: event.Progress,
+		Metrics:  event.Metrics,
+		Message:  event.Message,
+		Time:     timestamppb.New(event.Time),
+	}
+}
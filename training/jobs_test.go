@@ -0,0 +1,295 @@
+package training
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	basestorage "github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// testProvider returns the statuses set by the test and counts the status requests
+type testProvider struct {
+	mutex    sync.Mutex
+	statuses map[string]*StatusResponse
+	calls    int
+	block    chan struct{}
+}
+
+func (provider *testProvider) status(ctx context.Context, modelID string) (*StatusResponse, error) {
+	provider.mutex.Lock()
+	provider.calls++
+	block := provider.block
+	response := provider.statuses[modelID]
+	provider.mutex.Unlock()
+	if block != nil {
+		<-block
+	}
+	return response, nil
+}
+
+func (provider *testProvider) set(modelID string, response *StatusResponse) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.statuses[modelID] = response
+}
+
+func (provider *testProvider) callCount() int {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	return provider.calls
+}
+
+func newTestJobTracker(t *testing.T, modelIDs ...string) (*JobTracker, *testProvider) {
+	orgMetadata, err := blockchain.InitOrganizationMetaDataFromJson([]byte(testJsonOrgMeta))
+	require.Nil(t, err)
+	memStorage := basestorage.NewMemStorage()
+	modelStorage := NewModelStorage(memStorage, orgMetadata)
+	for _, modelID := range modelIDs {
+		require.Nil(t, modelStorage.Put(modelStorage.buildModelKey(modelID), &ModelData{ModelId: modelID, Status: Status_CREATED}))
+	}
+	settings := &config.ModelTrainingJobsSettings{
+		Workers:         1,
+		PollInterval:    time.Second,
+		MaxPollInterval: 4 * time.Second,
+		StatusTimeout:   time.Second,
+		JobTimeout:      time.Hour,
+		HistorySize:     3,
+	}
+	provider := &testProvider{statuses: map[string]*StatusResponse{}}
+	tracker := NewJobTracker(settings, NewModelJobStorage(memStorage, orgMetadata), modelStorage,
+		NewPendingModelStorage(memStorage, orgMetadata), nil, nil, provider.status)
+	return tracker, provider
+}
+
+// testClock is the clock of the tracker moved by the test
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *testClock) add(elapsed time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(elapsed)
+}
+
+// tick moves the clock of the tracker and waits for the status requests of the due jobs
+func tick(t *testing.T, tracker *JobTracker, clock *testClock, elapsed time.Duration) {
+	clock.add(elapsed)
+	tracker.checkPending(context.Background())
+	assert.Eventually(t, func() bool { return len(tracker.workers) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestJobTracker_Update(t *testing.T) {
+	tracker, provider := newTestJobTracker(t, "1")
+	clock := &testClock{now: time.Now()}
+	tracker.now = clock.Now
+
+	data, err := tracker.Update("1", &StatusResponse{Status: Status_VALIDATING})
+	require.Nil(t, err)
+	assert.Equal(t, Status_VALIDATING, data.Status)
+	pending, err := tracker.isPending("1")
+	assert.Nil(t, err)
+	assert.True(t, pending)
+
+	history, events, stop, err := tracker.Watch("1")
+	require.Nil(t, err)
+	defer stop()
+	assert.Len(t, history, 1)
+	require.NotNil(t, events)
+
+	// the progress reported by the provider is sent to the watchers
+	provider.set("1", &StatusResponse{Status: Status_VALIDATING, Progress: 0.5, Metrics: map[string]float64{"loss": 0.1}})
+	tick(t, tracker, clock, time.Second)
+	event := <-events
+	assert.Equal(t, float32(0.5), event.Progress)
+	assert.Equal(t, map[string]float64{"loss": 0.1}, event.Metrics)
+	assert.Equal(t, 1, provider.callCount())
+
+	// the job isn't checked before the interval elapsed, the interval grows while nothing changes
+	tick(t, tracker, clock, 500*time.Millisecond)
+	assert.Equal(t, 1, provider.callCount())
+	tick(t, tracker, clock, 500*time.Millisecond)
+	assert.Equal(t, 2, provider.callCount())
+	tracker.mutex.Lock()
+	assert.Equal(t, 2*time.Second, tracker.schedules["1"].interval)
+	tracker.mutex.Unlock()
+	tick(t, tracker, clock, time.Second)
+	assert.Equal(t, 2, provider.callCount())
+	assert.Empty(t, events)
+
+	// the finished job closes the watchers and isn't pending anymore
+	provider.set("1", &StatusResponse{Status: Status_VALIDATED})
+	tick(t, tracker, clock, time.Second)
+	event = <-events
+	assert.Equal(t, Status_VALIDATED, event.Status)
+	_, ok := <-events
+	assert.False(t, ok)
+	pending, _ = tracker.isPending("1")
+	assert.False(t, pending)
+	model, err := tracker.modelStorage.GetModel("1")
+	assert.Nil(t, err)
+	assert.Equal(t, Status_VALIDATED, model.Status)
+
+	// the history keeps history_size last events
+	_, err = tracker.Update("1", &StatusResponse{Status: Status_TRAINING})
+	assert.Nil(t, err)
+	history, _, _, err = tracker.Watch("1")
+	assert.Nil(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, Status_TRAINING, history[2].Status)
+}
+
+func TestJobTracker_abandonedJob(t *testing.T) {
+	tracker, provider := newTestJobTracker(t, "1", "2")
+	clock := &testClock{now: time.Now()}
+	tracker.now = clock.Now
+
+	_, err := tracker.Update("1", &StatusResponse{Status: Status_TRAINING})
+	require.Nil(t, err)
+	_, err = tracker.Update("2", &StatusResponse{Status: Status_TRAINING})
+	require.Nil(t, err)
+	provider.set("1", &StatusResponse{Status: Status_TRAINING})
+	provider.set("2", &StatusResponse{Status: Status_TRAINING})
+
+	// one worker requests one status at a time
+	provider.block = make(chan struct{})
+	clock.add(time.Second)
+	tracker.checkPending(context.Background())
+	assert.Eventually(t, func() bool { return provider.callCount() == 1 }, time.Second, time.Millisecond)
+	tracker.checkPending(context.Background())
+	close(provider.block)
+	assert.Eventually(t, func() bool { return len(tracker.workers) == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, provider.callCount())
+
+	// the jobs without status changes for job_timeout are dropped
+	tick(t, tracker, clock, time.Hour)
+	tick(t, tracker, clock, time.Second)
+	pending1, _ := tracker.isPending("1")
+	pending2, _ := tracker.isPending("2")
+	assert.False(t, pending1)
+	assert.False(t, pending2)
+	tracker.mutex.Lock()
+	assert.Empty(t, tracker.schedules)
+	tracker.mutex.Unlock()
+}
+
+// testWatchStream collects the events sent by WatchModel
+type testWatchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *ModelStatusEvent
+}
+
+func (stream *testWatchStream) Send(event *ModelStatusEvent) error {
+	stream.events <- event
+	return nil
+}
+
+func (stream *testWatchStream) Context() context.Context {
+	return stream.ctx
+}
+
+// newTestWatchService returns the daemon service of the tracker, the models are owned by the test user
+func newTestWatchService(t *testing.T, tracker *JobTracker, modelIDs ...string) *DaemonService {
+	orgMetadata, err := blockchain.InitOrganizationMetaDataFromJson([]byte(testJsonOrgMeta))
+	require.Nil(t, err)
+	memStorage := basestorage.NewMemStorage()
+	userStorage := NewUserModelStorage(memStorage, orgMetadata)
+	require.Nil(t, userStorage.Put(userStorage.buildModelUserKey(testUserAddress), &ModelUserData{ModelIds: modelIDs}))
+	return &DaemonService{
+		blockchain:           blockchain.NewMockProcessor(true),
+		storage:              tracker.modelStorage,
+		userStorage:          userStorage,
+		pendingStorage:       tracker.pendingStorage,
+		publicStorage:        NewPublicModelStorage(memStorage, orgMetadata),
+		jobs:                 tracker,
+		allowBlockDifference: 5,
+	}
+}
+
+func watchModel(t *testing.T, service *DaemonService, modelID string) (*testWatchStream, chan error) {
+	stream := &testWatchStream{ctx: context.Background(), events: make(chan *ModelStatusEvent, 10)}
+	request := &CommonRequest{ModelId: modelID,
+		Authorization: createTestAuthDetails(big.NewInt(blockchain.MockedCurrentBlock), "watch_model")}
+	done := make(chan error, 1)
+	go func() { done <- service.WatchModel(request, stream) }()
+	return stream, done
+}
+
+func TestDaemonService_WatchModelFinished(t *testing.T) {
+	tracker, _ := newTestJobTracker(t, "1", "2")
+	service := newTestWatchService(t, tracker, "1", "2")
+	_, err := tracker.Update("1", &StatusResponse{Status: Status_VALIDATING})
+	require.Nil(t, err)
+	_, err = tracker.Update("1", &StatusResponse{Status: Status_VALIDATED})
+	require.Nil(t, err)
+
+	// the finished model gets its history
+	stream, done := watchModel(t, service, "1")
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WatchModel of the finished model isn't finished")
+	}
+	require.Len(t, stream.events, 2)
+	assert.Equal(t, Status_VALIDATING, (<-stream.events).Status)
+	assert.Equal(t, Status_VALIDATED, (<-stream.events).Status)
+
+	// the model trained before the history was kept gets its current status
+	stream, done = watchModel(t, service, "2")
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WatchModel of the model without the history isn't finished")
+	}
+	require.Len(t, stream.events, 1)
+	assert.Equal(t, Status_CREATED, (<-stream.events).Status)
+}
+
+func TestDaemonService_WatchModelPending(t *testing.T) {
+	tracker, provider := newTestJobTracker(t, "1")
+	clock := &testClock{now: time.Now()}
+	tracker.now = clock.Now
+	service := newTestWatchService(t, tracker, "1")
+	_, err := tracker.Update("1", &StatusResponse{Status: Status_TRAINING})
+	require.Nil(t, err)
+
+	stream, done := watchModel(t, service, "1")
+	assert.Equal(t, Status_TRAINING, (<-stream.events).Status)
+	assert.Eventually(t, func() bool {
+		tracker.mutex.Lock()
+		defer tracker.mutex.Unlock()
+		return len(tracker.watchers["1"]) == 1
+	}, time.Second, time.Millisecond)
+
+	// the new statuses are sent until the job is finished
+	provider.set("1", &StatusResponse{Status: Status_TRAINING, Progress: 0.5})
+	tick(t, tracker, clock, time.Second)
+	assert.Equal(t, float32(0.5), (<-stream.events).Progress)
+	provider.set("1", &StatusResponse{Status: Status_READY_TO_USE})
+	tick(t, tracker, clock, time.Second)
+	assert.Equal(t, Status_READY_TO_USE, (<-stream.events).Status)
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WatchModel isn't finished with the job")
+	}
+}
@@ -69,6 +69,30 @@ func (n NoTrainingDaemonServer) UpdateModel(ctx context.Context, request *Update
 		fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
 }
 
+func (n NoTrainingDaemonServer) WatchModel(request *CommonRequest, server Daemon_WatchModelServer) error {
+	return fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
+}
+
+func (n NoTrainingDaemonServer) GetModelCharges(ctx context.Context, request *CommonRequest) (*ModelChargesResponse, error) {
+	return nil, fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
+}
+
+func (n NoTrainingDaemonServer) ListModelVersions(ctx context.Context, request *CommonRequest) (*ModelVersionsResponse, error) {
+	return nil, fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
+}
+
+func (n NoTrainingDaemonServer) CompareModelVersions(ctx context.Context, request *CompareModelVersionsRequest) (*CompareModelVersionsResponse, error) {
+	return nil, fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
+}
+
+func (n NoTrainingDaemonServer) RollbackModelVersion(ctx context.Context, request *ModelVersionRequest) (*ModelResponse, error) {
+	return nil, fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
+}
+
+func (n NoTrainingDaemonServer) GetDatasetUpload(ctx context.Context, request *DatasetUploadRequest) (*DatasetUpload, error) {
+	return nil, fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
+}
+
 func (n NoTrainingDaemonServer) GetMethodMetadata(ctx context.Context, request *MethodMetadataRequest) (*MethodMetadata, error) {
 	return nil, fmt.Errorf("service end point is not defined or is invalid , please contact the AI developer")
 }
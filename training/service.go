@@ -12,7 +12,6 @@ import (
 	"net/url"
 	"slices"
 	"strings"
-	"sync"
 	"time"
 
 	_ "embed"
@@ -30,6 +29,7 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -44,6 +44,10 @@ type DaemonService struct {
 	userStorage          *ModelUserStorage
 	pendingStorage       *PendingModelStorage
 	publicStorage        *PublicModelStorage
+	jobs                 *JobTracker
+	billing              *Billing
+	versions             *ModelVersions
+	datasets             *DatasetStager
 	serviceUrl           string
 	trainingMetadata     *TrainingMetadata
 	methodsMetadata      map[string]*MethodMetadata
@@ -114,105 +118,14 @@ func (ds *DaemonService) CreateModel(ctx context.Context, request *NewModelReque
 	return modelResponse, err
 }
 
-func (ds *DaemonService) getPendingModelIds() (*PendingModelData, error) {
-	key := ds.pendingStorage.buildPendingModelKey()
-
-	data, _, err := ds.pendingStorage.Get(key)
+// getModelStatus requests the status of the model from the service provider
+func (ds *DaemonService) getModelStatus(ctx context.Context, modelID string) (*StatusResponse, error) {
+	conn, client, err := ds.getServiceClient()
 	if err != nil {
 		return nil, err
 	}
-
-	return data, nil
-}
-
-func (ds *DaemonService) startUpdateModelStatusWorker(ctx context.Context, modelId string) {
-	modelKey := ds.storage.buildModelKey(modelId)
-	//zap.L().Debug("[startUpdateModelStatusWorker]", zap.String("modelID", modelId))
-	currentModelData, ok, err := ds.storage.Get(modelKey)
-	if err != nil {
-		zap.L().Error("[startUpdateModelStatusWorker] err in getting modelData from storage", zap.Error(err))
-		return
-	}
-	if !ok {
-		zap.L().Error("[startUpdateModelStatusWorker] there is no model with such modelKey", zap.Any("modelKey", modelKey))
-		return
-	}
-
-	_, client, err := ds.getServiceClient()
-	if err != nil {
-		zap.L().Error("[startUpdateModelStatusWorker] error in getting service client", zap.Error(err))
-		return
-	}
-
-	response, err := client.GetModelStatus(ctx, &ModelID{ModelId: modelId})
-	if response == nil || err != nil {
-		zap.L().Error("[startUpdateModelStatusWorker] error in invoking GetModelStatus, service-provider should implement it", zap.Error(err))
-		return
-	}
-
-	if response.Status != Status_TRAINING && response.Status != Status_VALIDATING {
-		err := ds.pendingStorage.RemovePendingModelId(ds.pendingStorage.buildPendingModelKey(), modelId)
-		if err != nil {
-			zap.L().Error("[RemovePendingModelId] error in updating model status", zap.Error(err))
-		}
-	}
-
-	if currentModelData.Status == response.Status {
-		// if status don't changed yet we skip it
-		return
-	}
-	zap.L().Debug("[startUpdateModelStatusWorker]", zap.String("current status", currentModelData.Status.String()), zap.String("new status", response.Status.String()))
-	currentModelData.Status = response.Status
-	err = ds.storage.Put(modelKey, currentModelData)
-	if err != nil {
-		zap.L().Debug("[startUpdateModelStatusWorker] error in updating model status", zap.Bool("isOK", ok), zap.Error(err))
-	}
-}
-
-func (ds *DaemonService) updateModelStatusWorker(ctx context.Context, tasks <-chan string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for {
-		select {
-		case modelID := <-tasks:
-			go ds.startUpdateModelStatusWorker(ctx, modelID)
-		case <-ctx.Done():
-			return
-		}
-	}
-}
-
-func (ds *DaemonService) ManageUpdateModelStatusWorkers(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-
-	tasks := make(chan string)
-	var wg sync.WaitGroup
-
-	go ds.updateModelStatusWorker(ctx, tasks, &wg)
-
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			data, err := ds.getPendingModelIds()
-			if data == nil || len(data.ModelIDs) == 0 {
-				continue
-			}
-			zap.L().Debug("Pending models", zap.Strings("id's", data.ModelIDs))
-			if err != nil {
-				zap.L().Error("Error in getting pending model IDs", zap.Error(err))
-				return
-			}
-			for _, modelID := range data.ModelIDs {
-				if modelID != "" {
-					tasks <- modelID
-				}
-			}
-		case <-ctx.Done():
-			wg.Wait()
-			return
-		}
-	}
+	defer closeConn(conn)
+	return client.GetModelStatus(ctx, &ModelID{ModelId: modelID})
 }
 
 func (ds *DaemonService) ValidateModelPrice(ctx context.Context, req *AuthValidateRequest) (*PriceInBaseUnit, error) {
@@ -268,8 +181,11 @@ func (ds *DaemonService) ValidateModelPrice(ctx context.Context, req *AuthValida
 }
 
 func (ds *DaemonService) UploadAndValidate(clientStream Daemon_UploadAndValidateServer) error {
+	if ds.datasets != nil {
+		return ds.stageAndValidate(clientStream)
+	}
 	var fullData bytes.Buffer
-	var modelID string
+	var modelID, signer string
 
 	providerConn, client, err := ds.getServiceClient()
 	if err != nil {
@@ -325,6 +241,7 @@ func (ds *DaemonService) UploadAndValidate(clientStream Daemon_UploadAndValidate
 		zap.L().Debug(fmt.Sprintf("[UploadAndValidate] filesize: %v", req.UploadInput.FileSize))
 
 		modelID = req.UploadInput.ModelId
+		signer = req.Authorization.SignerAddress
 
 		if modelID == "" {
 			return WrapError(ErrEmptyModelID, ErrEmptyModelID.Error())
@@ -342,16 +259,11 @@ func (ds *DaemonService) UploadAndValidate(clientStream Daemon_UploadAndValidate
 	zap.L().Debug("[UploadAndValidate] Received file for model %s with size %d bytes", zap.String("modelID", modelID), zap.Int("len", fullData.Len()))
 	closeConn(providerConn)
 
-	go func() {
-		err := ds.pendingStorage.AddPendingModelId(ds.pendingStorage.buildPendingModelKey(), modelID)
-		if err != nil {
-			zap.L().Error("[AddPendingModelId]", zap.Error(err))
-		}
-	}()
 	if stResp == nil {
 		stResp = &StatusResponse{Status: Status_VALIDATING}
 	}
-	_, err = ds.updateModelStatus(modelID, stResp.Status)
+	ds.startVersion(modelID, "upload_and_validate", nil, signer, stResp.Status)
+	_, err = ds.jobs.Update(modelID, stResp)
 	if err != nil {
 		zap.L().Error("[UploadAndValidate] updateModelStatus", zap.Error(err))
 	}
@@ -362,6 +274,102 @@ func (ds *DaemonService) UploadAndValidate(clientStream Daemon_UploadAndValidate
 	return err
 }
 
+// stageAndValidate stages the uploaded dataset and requests its validation by
+// the reference, the interrupted upload is resumed by its id
+func (ds *DaemonService) stageAndValidate(clientStream Daemon_UploadAndValidateServer) error {
+	ctx := clientStream.Context()
+	var upload *StagedUpload
+	defer func() {
+		if upload != nil {
+			ds.datasets.Release(upload)
+		}
+	}()
+
+	for {
+		req, err := clientStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			zap.L().Error("[stageAndValidate] error in receiving upload request", zap.Error(err))
+			return err
+		}
+		if req == nil || req.UploadInput == nil {
+			continue
+		}
+
+		if upload == nil {
+			if req.Authorization == nil {
+				return ErrNoAuthorization
+			}
+			if req.UploadInput.ModelId == "" {
+				return ErrEmptyModelID
+			}
+			if err = ds.verifySignature(req.Authorization, "upload_and_validate"); err != nil {
+				return WrapError(ErrNoAuthorization, err.Error())
+			}
+			if err = ds.verifyCreatedByAddress(req.UploadInput.ModelId, req.Authorization.SignerAddress); err != nil {
+				return WrapError(ErrAccessToModel, err.Error())
+			}
+			requirements, _ := ds.GetMethodMetadata(ctx, &MethodMetadataRequest{ModelId: req.UploadInput.ModelId})
+			upload, err = ds.datasets.Begin(req.UploadId, req.UploadInput.ModelId, req.Authorization.SignerAddress,
+				req.UploadInput, req.Checksum, requirements)
+			if err != nil {
+				return err
+			}
+			if err = clientStream.SendHeader(metadata.Pairs(DatasetUploadIdHeader, upload.UploadId)); err != nil {
+				zap.L().Warn("[stageAndValidate] can't send the upload id", zap.Error(err))
+			}
+		} else if req.UploadInput.ModelId != upload.ModelId {
+			return WrapError(ErrInvalidRequest, "model_id of the upload can't be changed")
+		}
+
+		if err = ds.datasets.Write(upload, req.Offset, req.UploadInput.Data); err != nil {
+			return err
+		}
+	}
+	if upload == nil {
+		return ErrNoAuthorization
+	}
+
+	reference, err := ds.datasets.Finish(ctx, upload)
+	if err != nil {
+		return err
+	}
+	statusResp, err := ds.validateDataset(ctx, upload.ModelId, reference, nil, upload.CreatedBy, "upload_and_validate")
+	if err != nil {
+		return err
+	}
+	return clientStream.SendAndClose(statusResp)
+}
+
+// GetDatasetUpload returns the state of the staged dataset upload
+func (ds *DaemonService) GetDatasetUpload(ctx context.Context, request *DatasetUploadRequest) (*DatasetUpload, error) {
+	if request == nil || request.Authorization == nil {
+		return nil, ErrNoAuthorization
+	}
+
+	method, ok := ctx.Value(ctxkeys.MethodKey).(string)
+	if !ok {
+		zap.L().Error("method not found in context")
+		return nil, WrapError(ErrBadAuthorization, "method not found in context")
+	}
+
+	if err := ds.verifySignature(request.Authorization, method); err != nil {
+		return nil, WrapError(ErrBadAuthorization, err.Error())
+	}
+	if request.ModelId == "" {
+		return nil, ErrEmptyModelID
+	}
+	if err := ds.verifyCreatedByAddress(request.ModelId, request.Authorization.SignerAddress); err != nil {
+		return nil, WrapError(ErrAccessToModel, err.Error())
+	}
+	if ds.datasets == nil {
+		return nil, WrapError(ErrDatasetUploadNotFound, "datasets aren't staged by the daemon")
+	}
+	return ds.datasets.Get(request.ModelId, request.UploadId)
+}
+
 func (ds *DaemonService) ValidateModel(ctx context.Context, req *AuthValidateRequest) (*StatusResponse, error) {
 
 	if req == nil || req.Authorization == nil {
@@ -387,6 +395,12 @@ func (ds *DaemonService) ValidateModel(ctx context.Context, req *AuthValidateReq
 			WrapError(ErrAccessToModel, err.Error())
 	}
 
+	return ds.validateDataset(ctx, req.ModelId, req.TrainingDataLink, req.Parameters, req.Authorization.SignerAddress, "validate_model")
+}
+
+// validateDataset requests the validation of the dataset by the link from the service provider
+func (ds *DaemonService) validateDataset(ctx context.Context, modelID, link string, parameters map[string]string,
+	signer, method string) (*StatusResponse, error) {
 	conn, client, err := ds.getServiceClient()
 	if client == nil || err != nil {
 		return &StatusResponse{
@@ -394,32 +408,30 @@ func (ds *DaemonService) ValidateModel(ctx context.Context, req *AuthValidateReq
 		}, WrapError(ErrServiceIssue, err.Error())
 	}
 
-	model, err := ds.storage.GetModel(req.ModelId)
+	model, err := ds.storage.GetModel(modelID)
 	if err != nil {
 		return nil, WrapError(ErrModelDoesntExist, err.Error())
 	}
 	statusResp, err := client.ValidateModel(ctx, &ValidateRequest{
-		ModelId:          req.ModelId,
-		TrainingDataLink: req.TrainingDataLink,
+		ModelId:          modelID,
+		TrainingDataLink: link,
+		Parameters:       parameters,
 	})
 	closeConn(conn)
 	if err != nil {
 		return nil, WrapError(ErrServiceIssue, err.Error())
 	}
-	key := ds.storage.buildModelKey(req.ModelId)
-	model.TrainingLink = req.TrainingDataLink
-	model.Status = statusResp.Status
+	key := ds.storage.buildModelKey(modelID)
+	model.TrainingLink = link
 	err = ds.storage.Put(key, model)
 	if err != nil {
 		zap.L().Error("Error in putting data in storage", zap.Error(err))
 	}
+	ds.startVersion(modelID, method, parameters, signer, statusResp.Status)
 
-	go func() {
-		err := ds.pendingStorage.AddPendingModelId(ds.pendingStorage.buildPendingModelKey(), req.ModelId)
-		if err != nil {
-			zap.L().Error("[AddPendingModelId]", zap.Error(err))
-		}
-	}()
+	if _, err = ds.jobs.Update(modelID, statusResp); err != nil {
+		zap.L().Error("Error in updating model status", zap.Error(err))
+	}
 
 	return statusResp, nil
 }
@@ -507,16 +519,12 @@ func (ds *DaemonService) TrainModel(ctx context.Context, req *CommonRequest) (*S
 			Status: Status_ERRORED,
 		}, WrapError(ErrServiceIssue, err.Error())
 	}
+	ds.startVersion(req.ModelId, "train_model", nil, req.Authorization.SignerAddress, statusResp.Status)
 	go func() {
-		_, err = ds.updateModelStatus(req.ModelId, statusResp.Status)
+		_, err := ds.jobs.Update(req.ModelId, statusResp)
 		if err != nil {
 			zap.L().Error("Error in updating model data in storage", zap.Error(err))
 		}
-
-		err = ds.pendingStorage.AddPendingModelId(ds.pendingStorage.buildPendingModelKey(), req.ModelId)
-		if err != nil {
-			zap.L().Error("[AddPendingModelId]", zap.Error(err))
-		}
 	}()
 	return statusResp, nil
 }
@@ -748,6 +756,7 @@ func convertModelDataToBO(data *ModelData) (responseData *ModelResponse) {
 		CreatedDate:      data.CreatedDate,
 		UpdatedByAddress: data.UpdatedByAddress,
 		CreatedByAddress: data.CreatedByAddress,
+		CurrentVersion:   data.CurrentVersion,
 	}
 	return
 }
@@ -853,28 +862,6 @@ func (ds *DaemonService) verifyCreatedByAddress(modelId, address string) (err er
 	return
 }
 
-func (ds *DaemonService) updateModelStatus(modelID string, newStatus Status) (data *ModelData, err error) {
-	key := &ModelKey{
-		OrganizationId: config.GetString(config.OrganizationId),
-		ServiceId:      config.GetString(config.ServiceId),
-		GroupId:        ds.organizationMetaData.GetGroupIdString(),
-		ModelId:        modelID,
-	}
-	ok := false
-	zap.L().Debug("[updateModelStatus]", zap.String("modelID", key.ModelId))
-	data, ok, err = ds.storage.Get(key)
-	if err != nil || !ok || data == nil {
-		zap.L().Error("[updateModelStatus] can't get model data from etcd", zap.Error(err))
-		return data, WrapError(ErrGetModelStorage, err.Error())
-	}
-	data.Status = newStatus
-	if err = ds.storage.Put(key, data); err != nil {
-		zap.L().Error("[updateModelStatus] issue with retrieving model data from storage", zap.Error(err))
-		return data, WrapError(ErrGetModelStorage, err.Error())
-	}
-	return
-}
-
 func (ds *DaemonService) updateModelPrices(modelID string, validatePrice, trainPrice *PriceInBaseUnit) error {
 	key := &ModelKey{
 		OrganizationId: config.GetString(config.OrganizationId),
@@ -1029,6 +1016,7 @@ func BuildModelResponse(data *ModelData, status Status) *ModelResponse {
 		CreatedDate:      data.CreatedDate,
 		CreatedByAddress: data.CreatedByAddress,
 		UpdatedByAddress: data.UpdatedByAddress,
+		CurrentVersion:   data.CurrentVersion,
 	}
 }
 
@@ -1112,7 +1100,7 @@ func (ds *DaemonService) GetModel(ctx context.Context, request *CommonRequest) (
 		}
 		zap.L().Info("[GetModelStatus] response from service-provider", zap.Any("status", responseStatus.Status))
 		zap.L().Debug("[GetModelStatus] updating model status based on response from GetModelStatus")
-		data, err := ds.updateModelStatus(request.ModelId, responseStatus.Status)
+		data, err := ds.jobs.Update(request.ModelId, responseStatus)
 		closeConn(conn)
 		zap.L().Debug("[GetModelStatus] data that be returned to client", zap.Any("data", data))
 		if err == nil && data != nil {
@@ -1127,6 +1115,217 @@ func (ds *DaemonService) GetModel(ctx context.Context, request *CommonRequest) (
 	return
 }
 
+// WatchModel sends the status history of the model, then the new statuses
+// until the validation or training of the model is finished
+func (ds *DaemonService) WatchModel(request *CommonRequest, stream Daemon_WatchModelServer) error {
+	if request == nil || request.Authorization == nil {
+		return ErrNoAuthorization
+	}
+	if request.ModelId == "" {
+		return ErrEmptyModelID
+	}
+	if err := ds.verifySignature(request.Authorization, Daemon_WatchModel_FullMethodName); err != nil {
+		return WrapError(ErrBadAuthorization, err.Error())
+	}
+	if err := ds.verifySignerHasAccessToTheModel(request.ModelId, request.Authorization.SignerAddress); err != nil {
+		return WrapError(ErrAccessToModel, err.Error())
+	}
+
+	history, events, stop, err := ds.jobs.Watch(request.ModelId)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	if len(history) == 0 {
+		// the models trained before the history was kept have only the current status
+		model, err := ds.storage.GetModel(request.ModelId)
+		if err != nil || model == nil {
+			return WrapError(ErrModelDoesntExist, request.ModelId)
+		}
+		history = []*ModelJobEvent{{Status: model.Status, Time: time.Now()}}
+	}
+	var last time.Time
+	for _, event := range history {
+		if err = stream.Send(event.toStatusEvent(request.ModelId)); err != nil {
+			return err
+		}
+		last = event.Time
+	}
+	// the model isn't validated or trained now, its status doesn't change
+	if events == nil {
+		return nil
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			// the events saved while the history was read are sent already
+			if !event.Time.After(last) {
+				continue
+			}
+			if err = stream.Send(event.toStatusEvent(request.ModelId)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// GetModelCharges returns the payments of the validation and training of the model
+func (ds *DaemonService) GetModelCharges(ctx context.Context, request *CommonRequest) (*ModelChargesResponse, error) {
+	if request == nil || request.Authorization == nil {
+		return nil, ErrNoAuthorization
+	}
+
+	method, ok := ctx.Value(ctxkeys.MethodKey).(string)
+	if !ok {
+		zap.L().Error("method not found in context")
+		return nil, WrapError(ErrBadAuthorization, "method not found in context")
+	}
+
+	if err := ds.verifySignature(request.Authorization, method); err != nil {
+		return nil, WrapError(ErrBadAuthorization, err.Error())
+	}
+	if request.ModelId == "" {
+		return nil, ErrEmptyModelID
+	}
+	if err := ds.verifySignerHasAccessToTheModel(request.ModelId, request.Authorization.SignerAddress); err != nil {
+		return nil, WrapError(ErrAccessToModel, err.Error())
+	}
+
+	response := &ModelChargesResponse{}
+	if ds.billing == nil {
+		return response, nil
+	}
+	charges, err := ds.billing.Charges(request.ModelId)
+	if err != nil {
+		return nil, err
+	}
+	for _, charge := range charges {
+		response.Charges = append(response.Charges, charge.toChargeDetails())
+	}
+	return response, nil
+}
+
+// startVersion adds the version of the validation or training of the model
+func (ds *DaemonService) startVersion(modelID, method string, parameters map[string]string, signer string, status Status) {
+	if ds.versions == nil {
+		return
+	}
+	model, err := ds.storage.GetModel(modelID)
+	if err != nil || model == nil {
+		zap.L().Error("[startVersion] can't get model data", zap.String("modelID", modelID), zap.Error(err))
+		return
+	}
+	if _, err = ds.versions.Start(model, method, parameters, signer, status); err != nil {
+		zap.L().Error("[startVersion] can't add the model version", zap.String("modelID", modelID), zap.Error(err))
+	}
+}
+
+// ListModelVersions returns the versions of the model
+func (ds *DaemonService) ListModelVersions(ctx context.Context, request *CommonRequest) (*ModelVersionsResponse, error) {
+	if request == nil || request.Authorization == nil {
+		return nil, ErrNoAuthorization
+	}
+
+	method, ok := ctx.Value(ctxkeys.MethodKey).(string)
+	if !ok {
+		zap.L().Error("method not found in context")
+		return nil, WrapError(ErrBadAuthorization, "method not found in context")
+	}
+
+	if err := ds.verifySignature(request.Authorization, method); err != nil {
+		return nil, WrapError(ErrBadAuthorization, err.Error())
+	}
+	if request.ModelId == "" {
+		return nil, ErrEmptyModelID
+	}
+	if err := ds.verifySignerHasAccessToTheModel(request.ModelId, request.Authorization.SignerAddress); err != nil {
+		return nil, WrapError(ErrAccessToModel, err.Error())
+	}
+
+	model, err := ds.storage.GetModel(request.ModelId)
+	if err != nil || model == nil {
+		return nil, WrapError(ErrModelDoesntExist, request.ModelId)
+	}
+	response := &ModelVersionsResponse{CurrentVersion: model.CurrentVersion}
+	if ds.versions == nil {
+		return response, nil
+	}
+	versions, err := ds.versions.List(request.ModelId)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		response.Versions = append(response.Versions, version.toModelVersion())
+	}
+	return response, nil
+}
+
+// CompareModelVersions returns two versions of the model with their differences
+func (ds *DaemonService) CompareModelVersions(ctx context.Context, request *CompareModelVersionsRequest) (*CompareModelVersionsResponse, error) {
+	if request == nil || request.Authorization == nil {
+		return nil, ErrNoAuthorization
+	}
+
+	method, ok := ctx.Value(ctxkeys.MethodKey).(string)
+	if !ok {
+		zap.L().Error("method not found in context")
+		return nil, WrapError(ErrBadAuthorization, "method not found in context")
+	}
+
+	if err := ds.verifySignature(request.Authorization, method); err != nil {
+		return nil, WrapError(ErrBadAuthorization, err.Error())
+	}
+	if request.ModelId == "" {
+		return nil, ErrEmptyModelID
+	}
+	if err := ds.verifySignerHasAccessToTheModel(request.ModelId, request.Authorization.SignerAddress); err != nil {
+		return nil, WrapError(ErrAccessToModel, err.Error())
+	}
+	if ds.versions == nil {
+		return nil, WrapError(ErrModelVersionDoesntExist, request.ModelId)
+	}
+	return ds.versions.Compare(request.ModelId, request.BaseVersion, request.Version)
+}
+
+// RollbackModelVersion makes the earlier version the current version of the model,
+// only the creator of the model can roll it back
+func (ds *DaemonService) RollbackModelVersion(ctx context.Context, request *ModelVersionRequest) (*ModelResponse, error) {
+	if request == nil || request.Authorization == nil {
+		return nil, ErrNoAuthorization
+	}
+
+	method, ok := ctx.Value(ctxkeys.MethodKey).(string)
+	if !ok {
+		zap.L().Error("method not found in context")
+		return nil, WrapError(ErrBadAuthorization, "method not found in context")
+	}
+
+	if err := ds.verifySignature(request.Authorization, method); err != nil {
+		return nil, WrapError(ErrBadAuthorization, err.Error())
+	}
+	if request.ModelId == "" {
+		return nil, ErrEmptyModelID
+	}
+	if err := ds.verifyCreatedByAddress(request.ModelId, request.Authorization.SignerAddress); err != nil {
+		return nil, WrapError(ErrAccessToModel, err.Error())
+	}
+	if ds.versions == nil {
+		return nil, WrapError(ErrModelVersionDoesntExist, request.ModelId)
+	}
+	model, err := ds.versions.Rollback(request.ModelId, request.Version)
+	if err != nil {
+		return nil, err
+	}
+	return BuildModelResponse(model, model.Status), nil
+}
+
 // getFileDescriptorsWithTraining converts text of proto files to bufbuild linker
 func getFileDescriptorsWithTraining(protoFiles map[string]string) (linker.Files, error) {
 	protoFiles["training.proto"] = TrainingProtoEmbeded
@@ -1147,7 +1346,8 @@ func getFileDescriptorsWithTraining(protoFiles map[string]string) (linker.Files,
 // NewTrainingService daemon self server
 func NewTrainingService(b blockchain.Processor, serMetaData *blockchain.ServiceMetadata,
 	orgMetadata *blockchain.OrganizationMetaData, storage *ModelStorage, userStorage *ModelUserStorage,
-	pendingStorage *PendingModelStorage, publicStorage *PublicModelStorage, allowBlockDifference uint64) DaemonServer {
+	pendingStorage *PendingModelStorage, publicStorage *PublicModelStorage, jobStorage *ModelJobStorage,
+	billing *Billing, versions *ModelVersions, datasets *DatasetStager, allowBlockDifference uint64) DaemonServer {
 
 	var err error
 	serMetaData.ProtoDescriptors, err = getFileDescriptorsWithTraining(serMetaData.ProtoFiles)
@@ -1185,8 +1385,14 @@ func NewTrainingService(b blockchain.Processor, serMetaData *blockchain.ServiceM
 	serviceURL := config.GetString(config.ModelMaintenanceEndPoint)
 	if serviceURL == "" {
 		zap.L().Info("model_maintenance_endpoint is empty, using service_endpoint for models maintains")
-		serviceURL = config.GetString(config.ServiceEndpointKey)
+		serviceURL = config.GetServiceEndpoint()
+	}
+	jobsSettings, err := config.GetModelTrainingJobs()
+	if err != nil {
+		zap.L().Error("[NewTrainingService] can't init training", zap.Error(err))
+		return &NoTrainingDaemonServer{}
 	}
+
 	if config.IsValidUrl(serviceURL) && config.GetBool(config.BlockchainEnabledKey) {
 		daemonService := &DaemonService{
 			blockchain:           b,
@@ -1196,12 +1402,16 @@ func NewTrainingService(b blockchain.Processor, serMetaData *blockchain.ServiceM
 			userStorage:          userStorage,
 			pendingStorage:       pendingStorage,
 			publicStorage:        publicStorage,
+			billing:              billing,
+			versions:             versions,
+			datasets:             datasets,
 			serviceUrl:           serviceURL,
 			trainingMetadata:     trainMD,
 			methodsMetadata:      methodsMD,
 			allowBlockDifference: allowBlockDifference,
 		}
-		go daemonService.ManageUpdateModelStatusWorkers(context.Background(), 3*time.Second)
+		daemonService.jobs = NewJobTracker(jobsSettings, jobStorage, storage, pendingStorage, billing, versions, daemonService.getModelStatus)
+		go daemonService.jobs.Run(context.Background())
 		return daemonService
 	}
 
@@ -87,7 +87,7 @@ func (suite *DaemonServiceSuite) SetupSuite() {
 
 func (suite *DaemonServiceSuite) SetupTest() {
 	// setup storages before each test for isolation environment
-	modelStorage, userModelStorage, pendingModelStorage, publicModelStorage := suite.createTestModels()
+	modelStorage, userModelStorage, pendingModelStorage, publicModelStorage, jobStorage := suite.createTestModels()
 	suite.modelStorage = modelStorage
 	suite.userModelStorage = userModelStorage
 	suite.pendingModelStorage = pendingModelStorage
@@ -102,6 +102,10 @@ func (suite *DaemonServiceSuite) SetupTest() {
 		userModelStorage,
 		pendingModelStorage,
 		publicModelStorage,
+		jobStorage,
+		nil,
+		nil,
+		nil,
 		100,
 	)
 }
@@ -216,12 +220,13 @@ func (suite *DaemonServiceSuite) setupTestConfig() {
 	config.SetVip(testConfig)
 }
 
-func (suite *DaemonServiceSuite) createTestModels() (*ModelStorage, *ModelUserStorage, *PendingModelStorage, *PublicModelStorage) {
+func (suite *DaemonServiceSuite) createTestModels() (*ModelStorage, *ModelUserStorage, *PendingModelStorage, *PublicModelStorage, *ModelJobStorage) {
 	memStorage := storage.NewMemStorage()
 	modelStorage := NewModelStorage(memStorage, suite.organizationMetadata)
 	userModelStorage := NewUserModelStorage(memStorage, suite.organizationMetadata)
 	pendingModelStorage := NewPendingModelStorage(memStorage, suite.organizationMetadata)
 	publicModelStorage := NewPublicModelStorage(memStorage, suite.organizationMetadata)
+	jobStorage := NewModelJobStorage(memStorage, suite.organizationMetadata)
 
 	modelA := &ModelData{
 		IsPublic:            true,
@@ -363,7 +368,7 @@ func (suite *DaemonServiceSuite) createTestModels() (*ModelStorage, *ModelUserSt
 	suite.pendingModelKeys = []*ModelKey{modelAKey}
 
 	// return all model keys, storages
-	return modelStorage, userModelStorage, pendingModelStorage, publicModelStorage
+	return modelStorage, userModelStorage, pendingModelStorage, publicModelStorage, jobStorage
 }
 
 func (suite *DaemonServiceSuite) createAdditionalTestModel(modelName string, authDetails *AuthorizationDetails) string {
@@ -2,9 +2,12 @@ package training
 
 import (
 	"fmt"
+	"maps"
+	"math/big"
 	"reflect"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/singnet/snet-daemon/v6/blockchain"
 	"github.com/singnet/snet-daemon/v6/config"
@@ -34,6 +37,24 @@ type PublicModelStorage struct {
 	organizationMetaData *blockchain.OrganizationMetaData
 }
 
+// ModelJobStorage keeps the status history of the validation and training jobs by model
+type ModelJobStorage struct {
+	delegate             storage.TypedAtomicStorage
+	organizationMetaData *blockchain.OrganizationMetaData
+}
+
+// ModelChargeStorage keeps the payments of the validation and training jobs by model
+type ModelChargeStorage struct {
+	delegate             storage.TypedAtomicStorage
+	organizationMetaData *blockchain.OrganizationMetaData
+}
+
+// ModelVersionStorage keeps the versions of the models created by the validation and training jobs
+type ModelVersionStorage struct {
+	delegate             storage.TypedAtomicStorage
+	organizationMetaData *blockchain.OrganizationMetaData
+}
+
 func NewUserModelStorage(atomicStorage storage.AtomicStorage, orgMetadata *blockchain.OrganizationMetaData) *ModelUserStorage {
 	prefixedStorage := storage.NewPrefixedAtomicStorage(atomicStorage, "/model-user/userModelStorage")
 	userModelStorage := storage.NewTypedAtomicStorageImpl(
@@ -70,6 +91,33 @@ func NewPublicModelStorage(atomicStorage storage.AtomicStorage, orgMetadata *blo
 	return &PublicModelStorage{delegate: publicModelStorage, organizationMetaData: orgMetadata}
 }
 
+func NewModelJobStorage(atomicStorage storage.AtomicStorage, orgMetadata *blockchain.OrganizationMetaData) *ModelJobStorage {
+	prefixedStorage := storage.NewPrefixedAtomicStorage(atomicStorage, "/model-user/jobStorage")
+	jobStorage := storage.NewTypedAtomicStorageImpl(
+		prefixedStorage, serializeModelKey, reflect.TypeFor[ModelKey](), utils.Serialize, utils.Deserialize,
+		reflect.TypeFor[ModelJobData](),
+	)
+	return &ModelJobStorage{delegate: jobStorage, organizationMetaData: orgMetadata}
+}
+
+func NewModelChargeStorage(atomicStorage storage.AtomicStorage, orgMetadata *blockchain.OrganizationMetaData) *ModelChargeStorage {
+	prefixedStorage := storage.NewPrefixedAtomicStorage(atomicStorage, "/model-user/chargeStorage")
+	chargeStorage := storage.NewTypedAtomicStorageImpl(
+		prefixedStorage, serializeModelKey, reflect.TypeFor[ModelKey](), utils.Serialize, utils.Deserialize,
+		reflect.TypeFor[ModelChargesData](),
+	)
+	return &ModelChargeStorage{delegate: chargeStorage, organizationMetaData: orgMetadata}
+}
+
+func NewModelVersionStorage(atomicStorage storage.AtomicStorage, orgMetadata *blockchain.OrganizationMetaData) *ModelVersionStorage {
+	prefixedStorage := storage.NewPrefixedAtomicStorage(atomicStorage, "/model-user/versionStorage")
+	versionStorage := storage.NewTypedAtomicStorageImpl(
+		prefixedStorage, serializeModelKey, reflect.TypeFor[ModelKey](), utils.Serialize, utils.Deserialize,
+		reflect.TypeFor[ModelVersionsData](),
+	)
+	return &ModelVersionStorage{delegate: versionStorage, organizationMetaData: orgMetadata}
+}
+
 type ModelKey struct {
 	OrganizationId string
 	ServiceId      string
@@ -101,12 +149,144 @@ type ModelData struct {
 	TrainPrice          uint64
 	UpdatedDate         string
 	CreatedDate         string
+	// CurrentVersion is the version used by the inference calls which don't pin
+	// the version, 0 until the first version is ready to use
+	CurrentVersion uint64
 }
 
 func (data *ModelData) String() string {
-	return fmt.Sprintf("{DATA:%v|%v|%v|%v|%v|%v|Name:%v|IsPublic:%v|AuthorizedAddresses:%v|CreatedBy:%v|UpdatedBy:%v|Status:%v|TrainingLink:%v|Updated:%v|Created:%v|ValPrice:%v|TrPrice:%v|Desc:%v}",
+	return fmt.Sprintf("{DATA:%v|%v|%v|%v|%v|%v|Name:%v|IsPublic:%v|AuthorizedAddresses:%v|CreatedBy:%v|UpdatedBy:%v|Status:%v|TrainingLink:%v|Updated:%v|Created:%v|ValPrice:%v|TrPrice:%v|Desc:%v|Version:%v}",
 		data.OrganizationId, data.ServiceId, data.GroupId, data.GRPCServiceName, data.GRPCMethodName, data.ModelId, data.ModelName, data.IsPublic, data.AuthorizedAddresses,
-		data.CreatedByAddress, data.UpdatedByAddress, data.Status, data.TrainingLink, data.UpdatedDate, data.CreatedDate, data.ValidatePrice, data.TrainPrice, data.Description)
+		data.CreatedByAddress, data.UpdatedByAddress, data.Status, data.TrainingLink, data.UpdatedDate, data.CreatedDate, data.ValidatePrice, data.TrainPrice, data.Description,
+		data.CurrentVersion)
+}
+
+// ModelJobEvent is the status of the model reported by the service provider
+type ModelJobEvent struct {
+	Status   Status
+	Progress float32
+	Metrics  map[string]float64
+	Message  string
+	Time     time.Time
+}
+
+// sameAs returns true if the event reports nothing new after the previous one,
+// the events without progress, metrics and message only report the status
+func (event *ModelJobEvent) sameAs(previous *ModelJobEvent) bool {
+	if event.Status != previous.Status {
+		return false
+	}
+	if event.Progress == 0 && len(event.Metrics) == 0 && event.Message == "" {
+		return true
+	}
+	return event.Progress == previous.Progress && maps.Equal(event.Metrics, previous.Metrics) && event.Message == previous.Message
+}
+
+// ModelJobData is the status history of the model, the last event is the current status
+type ModelJobData struct {
+	ModelId string
+	Events  []*ModelJobEvent
+}
+
+func (data *ModelJobData) String() string {
+	return fmt.Sprintf("{DATA:%v|Events:%v}", data.ModelId, len(data.Events))
+}
+
+// lastEvent returns the current status of the job, nil if there are no events yet
+func (data *ModelJobData) lastEvent() *ModelJobEvent {
+	if data == nil || len(data.Events) == 0 {
+		return nil
+	}
+	return data.Events[len(data.Events)-1]
+}
+
+// ChargeStatus is the state of the payment of the validation or training
+type ChargeStatus string
+
+const (
+	// ChargeHeld - the job isn't finished yet
+	ChargeHeld ChargeStatus = "held"
+	// ChargeCaptured - the whole payment is charged
+	ChargeCaptured ChargeStatus = "captured"
+	// ChargeRefunded - the job failed, Refunded is returned to the payment channel
+	ChargeRefunded ChargeStatus = "refunded"
+	// ChargeRefundFailed - the refund failed, it's retried on the next status update
+	ChargeRefundFailed ChargeStatus = "refund_failed"
+)
+
+// ModelCharge is the payment of one validation or training of the model
+type ModelCharge struct {
+	ChargeId  string
+	Method    string
+	ChannelId *big.Int
+	Sender    string
+	Amount    *big.Int
+	Captured  *big.Int
+	Refunded  *big.Int
+	Status    ChargeStatus
+	// JobStatus is the status of the model which settled the charge
+	JobStatus Status
+	HeldAt    time.Time
+	SettledAt time.Time
+}
+
+// ModelChargesData is the payments of the model in the order they are held
+type ModelChargesData struct {
+	ModelId string
+	Charges []*ModelCharge
+}
+
+func (data *ModelChargesData) String() string {
+	return fmt.Sprintf("{DATA:%v|Charges:%v}", data.ModelId, len(data.Charges))
+}
+
+// ModelVersionData is the validation or training run of the model, the version
+// isn't changed after the run is finished
+type ModelVersionData struct {
+	Version uint64
+	// ParentVersion is the version the run started from, 0 for the first version
+	ParentVersion    uint64
+	Method           string
+	TrainingLink     string
+	Parameters       map[string]string
+	Status           Status
+	ValidatePrice    uint64
+	TrainPrice       uint64
+	Metrics          map[string]float64
+	Message          string
+	CreatedByAddress string
+	CreatedAt        time.Time
+	FinishedAt       time.Time
+}
+
+// finished returns true if the run of the version is over
+func (version *ModelVersionData) finished() bool {
+	return !version.FinishedAt.IsZero()
+}
+
+type ModelVersionsData struct {
+	ModelId  string
+	Versions []*ModelVersionData
+}
+
+func (data *ModelVersionsData) String() string {
+	return fmt.Sprintf("{VERSIONS:%v|%v}", data.ModelId, len(data.Versions))
+}
+
+// version returns the version by its number or nil
+func (data *ModelVersionsData) version(number uint64) *ModelVersionData {
+	if number == 0 || number > uint64(len(data.Versions)) {
+		return nil
+	}
+	return data.Versions[number-1]
+}
+
+// latest returns the last version or nil
+func (data *ModelVersionsData) latest() *ModelVersionData {
+	if len(data.Versions) == 0 {
+		return nil
+	}
+	return data.Versions[len(data.Versions)-1]
 }
 
 type ModelUserKey struct {
@@ -536,3 +716,217 @@ func (publicStorage *PublicModelStorage) buildPublicModelKey() *PublicModelKey {
 		GroupId:        publicStorage.organizationMetaData.GetGroupIdString(),
 	}
 }
+
+func (jobStorage *ModelJobStorage) buildJobKey(modelID string) *ModelKey {
+	return &ModelKey{
+		OrganizationId: config.GetString(config.OrganizationId),
+		ServiceId:      config.GetString(config.ServiceId),
+		GroupId:        jobStorage.organizationMetaData.GetGroupIdString(),
+		ModelId:        modelID,
+	}
+}
+
+func (jobStorage *ModelJobStorage) Get(key *ModelKey) (state *ModelJobData, ok bool, err error) {
+	value, ok, err := jobStorage.delegate.Get(key)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return value.(*ModelJobData), ok, err
+}
+
+func (jobStorage *ModelJobStorage) Put(key *ModelKey, state *ModelJobData) (err error) {
+	return jobStorage.delegate.Put(key, state)
+}
+
+// AddEvent appends the event to the history of the model unless it reports
+// nothing new, the oldest events are removed to keep historySize events
+func (jobStorage *ModelJobStorage) AddEvent(key *ModelKey, event *ModelJobEvent, historySize int) (added bool, err error) {
+	typedUpdateFunc := func(conditionValues []storage.TypedKeyValueData) (update []storage.TypedKeyValueData, ok bool, err error) {
+		if len(conditionValues) != 1 || conditionValues[0].Key != key {
+			return nil, false, fmt.Errorf("unexpected condition values or missing key")
+		}
+
+		jobData := &ModelJobData{ModelId: key.ModelId}
+		if conditionValues[0].Present {
+			current := conditionValues[0].Value.(*ModelJobData)
+			jobData.Events = slices.Clone(current.Events)
+		}
+
+		if last := jobData.lastEvent(); last != nil && event.sameAs(last) {
+			added = false
+			return nil, true, nil
+		}
+		jobData.Events = append(jobData.Events, event)
+		if len(jobData.Events) > historySize {
+			jobData.Events = jobData.Events[len(jobData.Events)-historySize:]
+		}
+		added = true
+
+		return []storage.TypedKeyValueData{{Key: key, Value: jobData, Present: true}}, true, nil
+	}
+
+	request := storage.TypedCASRequest{
+		ConditionKeys:           []any{key},
+		RetryTillSuccessOrError: true,
+		Update:                  typedUpdateFunc,
+	}
+
+	ok, err := jobStorage.delegate.ExecuteTransaction(request)
+	if err != nil {
+		return false, fmt.Errorf("transaction execution failed: %w", err)
+	}
+	if !ok {
+		return false, fmt.Errorf("transaction was not successful")
+	}
+	return added, nil
+}
+
+func (chargeStorage *ModelChargeStorage) buildChargeKey(modelID string) *ModelKey {
+	return &ModelKey{
+		OrganizationId: config.GetString(config.OrganizationId),
+		ServiceId:      config.GetString(config.ServiceId),
+		GroupId:        chargeStorage.organizationMetaData.GetGroupIdString(),
+		ModelId:        modelID,
+	}
+}
+
+func (chargeStorage *ModelChargeStorage) Get(key *ModelKey) (state *ModelChargesData, ok bool, err error) {
+	value, ok, err := chargeStorage.delegate.Get(key)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return value.(*ModelChargesData), ok, err
+}
+
+// AddCharge appends the charge to the payments of the model unless the charge
+// with the same id is added already
+func (chargeStorage *ModelChargeStorage) AddCharge(key *ModelKey, charge *ModelCharge) (err error) {
+	return chargeStorage.updateCharges(key, func(data *ModelChargesData) bool {
+		if slices.ContainsFunc(data.Charges, func(c *ModelCharge) bool { return c.ChargeId == charge.ChargeId }) {
+			return false
+		}
+		data.Charges = append(data.Charges, charge)
+		return true
+	})
+}
+
+// UpdateCharges applies update to every charge of the model and returns the
+// charges for which update returned true
+func (chargeStorage *ModelChargeStorage) UpdateCharges(key *ModelKey, update func(charge *ModelCharge) bool) (updated []*ModelCharge, err error) {
+	err = chargeStorage.updateCharges(key, func(data *ModelChargesData) bool {
+		updated = nil
+		for _, charge := range data.Charges {
+			if update(charge) {
+				updated = append(updated, charge)
+			}
+		}
+		return len(updated) > 0
+	})
+	return updated, err
+}
+
+func (chargeStorage *ModelChargeStorage) updateCharges(key *ModelKey, update func(data *ModelChargesData) bool) (err error) {
+	typedUpdateFunc := func(conditionValues []storage.TypedKeyValueData) (newValues []storage.TypedKeyValueData, ok bool, err error) {
+		if len(conditionValues) != 1 || conditionValues[0].Key != key {
+			return nil, false, fmt.Errorf("unexpected condition values or missing key")
+		}
+
+		chargesData := &ModelChargesData{ModelId: key.ModelId}
+		if conditionValues[0].Present {
+			chargesData = conditionValues[0].Value.(*ModelChargesData)
+		}
+		if !update(chargesData) {
+			return nil, true, nil
+		}
+		return []storage.TypedKeyValueData{{Key: key, Value: chargesData, Present: true}}, true, nil
+	}
+
+	request := storage.TypedCASRequest{
+		ConditionKeys:           []any{key},
+		RetryTillSuccessOrError: true,
+		Update:                  typedUpdateFunc,
+	}
+
+	ok, err := chargeStorage.delegate.ExecuteTransaction(request)
+	if err != nil {
+		return fmt.Errorf("transaction execution failed: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("transaction was not successful")
+	}
+	return nil
+}
+
+func (versionStorage *ModelVersionStorage) buildVersionKey(modelID string) *ModelKey {
+	return &ModelKey{
+		OrganizationId: config.GetString(config.OrganizationId),
+		ServiceId:      config.GetString(config.ServiceId),
+		GroupId:        versionStorage.organizationMetaData.GetGroupIdString(),
+		ModelId:        modelID,
+	}
+}
+
+func (versionStorage *ModelVersionStorage) Get(key *ModelKey) (state *ModelVersionsData, ok bool, err error) {
+	value, ok, err := versionStorage.delegate.Get(key)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return value.(*ModelVersionsData), ok, err
+}
+
+// AddVersion appends the version to the versions of the model, the version
+// gets the next number
+func (versionStorage *ModelVersionStorage) AddVersion(key *ModelKey, version *ModelVersionData) (err error) {
+	return versionStorage.updateVersions(key, func(data *ModelVersionsData) bool {
+		version.Version = uint64(len(data.Versions)) + 1
+		data.Versions = append(data.Versions, version)
+		return true
+	})
+}
+
+// UpdateLatest applies update to the last version of the model if its run
+// isn't finished yet, the updated version is returned
+func (versionStorage *ModelVersionStorage) UpdateLatest(key *ModelKey, update func(version *ModelVersionData) bool) (updated *ModelVersionData, err error) {
+	err = versionStorage.updateVersions(key, func(data *ModelVersionsData) bool {
+		updated = nil
+		latest := data.latest()
+		if latest == nil || latest.finished() || !update(latest) {
+			return false
+		}
+		updated = latest
+		return true
+	})
+	return updated, err
+}
+
+func (versionStorage *ModelVersionStorage) updateVersions(key *ModelKey, update func(data *ModelVersionsData) bool) (err error) {
+	typedUpdateFunc := func(conditionValues []storage.TypedKeyValueData) (newValues []storage.TypedKeyValueData, ok bool, err error) {
+		if len(conditionValues) != 1 || conditionValues[0].Key != key {
+			return nil, false, fmt.Errorf("unexpected condition values or missing key")
+		}
+
+		versionsData := &ModelVersionsData{ModelId: key.ModelId}
+		if conditionValues[0].Present {
+			versionsData = conditionValues[0].Value.(*ModelVersionsData)
+		}
+		if !update(versionsData) {
+			return nil, true, nil
+		}
+		return []storage.TypedKeyValueData{{Key: key, Value: versionsData, Present: true}}, true, nil
+	}
+
+	request := storage.TypedCASRequest{
+		ConditionKeys:           []any{key},
+		RetryTillSuccessOrError: true,
+		Update:                  typedUpdateFunc,
+	}
+
+	ok, err := versionStorage.delegate.ExecuteTransaction(request)
+	if err != nil {
+		return fmt.Errorf("transaction execution failed: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("transaction was not successful")
+	}
+	return nil
+}
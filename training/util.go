@@ -20,10 +20,15 @@ import (
 )
 
 var unifiedAuthMethods = map[string]struct{}{
-	"validate_model_price": {},
-	"train_model_price":    {},
-	"get_all_models":       {},
-	"get_model":            {},
+	"validate_model_price":   {},
+	"train_model_price":      {},
+	"get_all_models":         {},
+	"get_model":              {},
+	"watch_model":            {},
+	"get_model_charges":      {},
+	"list_model_versions":    {},
+	"compare_model_versions": {},
+	"get_dataset_upload":     {},
 }
 
 const unifiedAllowBlockDifference = 600 // in blocks
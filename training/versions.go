@@ -0,0 +1,273 @@
+package training
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ModelVersions keeps a version of the model for every validation and training
+// run. The version records the dataset link, parameters, prices and the outcome
+// of the run and isn't changed after the run is finished. The last version which
+// is ready to use becomes the current version of the model; the inference calls
+// use it unless they pin another version.
+type ModelVersions struct {
+	versionStorage *ModelVersionStorage
+	modelStorage   *ModelStorage
+	now            func() time.Time
+}
+
+func NewModelVersions(versionStorage *ModelVersionStorage, modelStorage *ModelStorage) *ModelVersions {
+	return &ModelVersions{
+		versionStorage: versionStorage,
+		modelStorage:   modelStorage,
+		now:            time.Now,
+	}
+}
+
+// Start adds the version of the validation or training run of the model. The
+// training starts from the last validated version and inherits its dataset,
+// the validation starts from the current version.
+func (versions *ModelVersions) Start(model *ModelData, method string, parameters map[string]string,
+	createdBy string, status Status) (*ModelVersionData, error) {
+	key := versions.versionStorage.buildVersionKey(model.ModelId)
+	version := &ModelVersionData{
+		ParentVersion:    model.CurrentVersion,
+		Method:           method,
+		TrainingLink:     model.TrainingLink,
+		Parameters:       parameters,
+		Status:           status,
+		ValidatePrice:    model.ValidatePrice,
+		TrainPrice:       model.TrainPrice,
+		CreatedByAddress: createdBy,
+		CreatedAt:        versions.now(),
+	}
+	if method == "train_model" {
+		data, _, err := versions.versionStorage.Get(key)
+		if err != nil {
+			return nil, WrapError(ErrDaemonStorage, err.Error())
+		}
+		if validated := lastValidated(data); validated != nil {
+			version.ParentVersion = validated.Version
+			version.TrainingLink = validated.TrainingLink
+			version.Parameters = validated.Parameters
+		}
+	}
+	if !isPendingStatus(status) {
+		version.FinishedAt = version.CreatedAt
+	}
+	if err := versions.versionStorage.AddVersion(key, version); err != nil {
+		zap.L().Error("[ModelVersions] can't add the version", zap.String("modelID", model.ModelId), zap.Error(err))
+		return nil, WrapError(ErrDaemonStorage, err.Error())
+	}
+	zap.L().Info("[ModelVersions] version is started", zap.String("modelID", model.ModelId),
+		zap.Uint64("version", version.Version), zap.Uint64("parent", version.ParentVersion), zap.String("method", method))
+	return version, nil
+}
+
+// lastValidated returns the last validated version or nil
+func lastValidated(data *ModelVersionsData) *ModelVersionData {
+	if data == nil {
+		return nil
+	}
+	for _, version := range slices.Backward(data.Versions) {
+		if version.Status == Status_VALIDATED {
+			return version
+		}
+	}
+	return nil
+}
+
+// Update sets the status reported by the service provider to the running
+// version of the model, the version is finished when the job is finished.
+// The finished version which is ready to use is returned.
+func (versions *ModelVersions) Update(modelID string, event *ModelJobEvent) (ready *ModelVersionData, err error) {
+	key := versions.versionStorage.buildVersionKey(modelID)
+	updated, err := versions.versionStorage.UpdateLatest(key, func(version *ModelVersionData) bool {
+		changed := version.Status != event.Status || (event.Message != "" && version.Message != event.Message) ||
+			(len(event.Metrics) > 0 && !maps.Equal(version.Metrics, event.Metrics))
+		version.Status = event.Status
+		if event.Message != "" {
+			version.Message = event.Message
+		}
+		if len(event.Metrics) > 0 {
+			version.Metrics = event.Metrics
+		}
+		if !isPendingStatus(event.Status) {
+			version.FinishedAt = event.Time
+			changed = true
+		}
+		return changed
+	})
+	if err != nil {
+		zap.L().Error("[ModelVersions] can't update the version", zap.String("modelID", modelID), zap.Error(err))
+		return nil, WrapError(ErrDaemonStorage, err.Error())
+	}
+	if updated != nil && updated.finished() && updated.Status == Status_READY_TO_USE {
+		return updated, nil
+	}
+	return nil, nil
+}
+
+// List returns the versions of the model
+func (versions *ModelVersions) List(modelID string) ([]*ModelVersionData, error) {
+	data, _, err := versions.versionStorage.Get(versions.versionStorage.buildVersionKey(modelID))
+	if err != nil {
+		return nil, WrapError(ErrDaemonStorage, err.Error())
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return data.Versions, nil
+}
+
+// Compare returns the versions of the model with their differences
+func (versions *ModelVersions) Compare(modelID string, baseNumber, number uint64) (*CompareModelVersionsResponse, error) {
+	data, _, err := versions.versionStorage.Get(versions.versionStorage.buildVersionKey(modelID))
+	if err != nil {
+		return nil, WrapError(ErrDaemonStorage, err.Error())
+	}
+	if data == nil {
+		data = &ModelVersionsData{}
+	}
+	base, version := data.version(baseNumber), data.version(number)
+	if base == nil || version == nil {
+		return nil, WrapError(ErrModelVersionDoesntExist, fmt.Sprintf("model %v has %v versions", modelID, len(data.Versions)))
+	}
+
+	response := &CompareModelVersionsResponse{
+		Base:           base.toModelVersion(),
+		Version:        version.toModelVersion(),
+		MetricsDiff:    make(map[string]float64),
+		CommonAncestor: commonAncestor(data, base, version),
+	}
+	for field, changed := range map[string]bool{
+		"method":             base.Method != version.Method,
+		"status":             base.Status != version.Status,
+		"training_data_link": base.TrainingLink != version.TrainingLink,
+		"parameters":         !maps.Equal(base.Parameters, version.Parameters),
+		"validate_price":     base.ValidatePrice != version.ValidatePrice,
+		"train_price":        base.TrainPrice != version.TrainPrice,
+		"metrics":            !maps.Equal(base.Metrics, version.Metrics),
+		"created_by_address": base.CreatedByAddress != version.CreatedByAddress,
+	} {
+		if changed {
+			response.ChangedFields = append(response.ChangedFields, field)
+		}
+	}
+	slices.Sort(response.ChangedFields)
+	for name, value := range version.Metrics {
+		if baseValue, ok := base.Metrics[name]; ok {
+			response.MetricsDiff[name] = value - baseValue
+		}
+	}
+	return response, nil
+}
+
+// commonAncestor returns the closest version both versions are derived from
+func commonAncestor(data *ModelVersionsData, base, version *ModelVersionData) uint64 {
+	lineage := make(map[uint64]struct{})
+	for v := base; v != nil; v = data.version(v.ParentVersion) {
+		lineage[v.Version] = struct{}{}
+	}
+	for v := version; v != nil; v = data.version(v.ParentVersion) {
+		if _, ok := lineage[v.Version]; ok {
+			return v.Version
+		}
+	}
+	return 0
+}
+
+// Rollback makes the finished version which is ready to use the current version of the model
+func (versions *ModelVersions) Rollback(modelID string, number uint64) (*ModelData, error) {
+	data, _, err := versions.versionStorage.Get(versions.versionStorage.buildVersionKey(modelID))
+	if err != nil {
+		return nil, WrapError(ErrDaemonStorage, err.Error())
+	}
+	var version *ModelVersionData
+	if data != nil {
+		version = data.version(number)
+	}
+	if version == nil {
+		return nil, WrapError(ErrModelVersionDoesntExist, fmt.Sprintf("model %v, version %v", modelID, number))
+	}
+	if !version.finished() || version.Status != Status_READY_TO_USE {
+		return nil, WrapError(ErrModelVersionNotReady, fmt.Sprintf("version %v is %v", number, version.Status))
+	}
+
+	key := versions.modelStorage.buildModelKey(modelID)
+	model, ok, err := versions.modelStorage.Get(key)
+	if err != nil || !ok || model == nil {
+		return nil, WrapError(ErrModelDoesntExist, modelID)
+	}
+	if isPendingStatus(model.Status) {
+		return nil, WrapError(ErrModelVersionNotReady, fmt.Sprintf("model %v is %v", modelID, model.Status))
+	}
+	model.CurrentVersion = number
+	model.UpdatedDate = versions.now().Format(DateFormat)
+	if err = versions.modelStorage.Put(key, model); err != nil {
+		return nil, WrapError(ErrPutModelStorage, err.Error())
+	}
+	zap.L().Info("[ModelVersions] model is rolled back", zap.String("modelID", modelID), zap.Uint64("version", number))
+	return model, nil
+}
+
+// Resolve returns the version of the model used by the inference call, pinned
+// is the version requested by the call. The empty version is returned for the
+// models without versions.
+func (versions *ModelVersions) Resolve(modelID string, pinned string) (string, error) {
+	if pinned == "" {
+		model, err := versions.modelStorage.GetModel(modelID)
+		if err != nil || model == nil || model.CurrentVersion == 0 {
+			return "", nil
+		}
+		return strconv.FormatUint(model.CurrentVersion, 10), nil
+	}
+
+	number, err := strconv.ParseUint(pinned, 10, 64)
+	if err != nil {
+		return "", WrapError(ErrModelVersionDoesntExist, fmt.Sprintf("invalid version %q", pinned))
+	}
+	data, _, err := versions.versionStorage.Get(versions.versionStorage.buildVersionKey(modelID))
+	if err != nil {
+		return "", WrapError(ErrDaemonStorage, err.Error())
+	}
+	var version *ModelVersionData
+	if data != nil {
+		version = data.version(number)
+	}
+	if version == nil {
+		return "", WrapError(ErrModelVersionDoesntExist, fmt.Sprintf("model %v, version %v", modelID, number))
+	}
+	if !version.finished() || version.Status != Status_READY_TO_USE {
+		return "", WrapError(ErrModelVersionNotReady, fmt.Sprintf("version %v is %v", number, version.Status))
+	}
+	return pinned, nil
+}
+
+// toModelVersion converts the version to the list_model_versions message
+func (version *ModelVersionData) toModelVersion() *ModelVersion {
+	message := &ModelVersion{
+		Version:          version.Version,
+		ParentVersion:    version.ParentVersion,
+		Method:           version.Method,
+		Status:           version.Status,
+		TrainingDataLink: version.TrainingLink,
+		Parameters:       version.Parameters,
+		ValidatePrice:    version.ValidatePrice,
+		TrainPrice:       version.TrainPrice,
+		Metrics:          version.Metrics,
+		Message:          version.Message,
+		CreatedByAddress: version.CreatedByAddress,
+		CreatedAt:        timestamppb.New(version.CreatedAt),
+	}
+	if version.finished() {
+		message.FinishedAt = timestamppb.New(version.FinishedAt)
+	}
+	return message
+}
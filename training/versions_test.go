@@ -0,0 +1,126 @@
+package training
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/config"
+	basestorage "github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestModelVersions(t *testing.T, modelID string) (*ModelVersions, *JobTracker) {
+	orgMetadata, err := blockchain.InitOrganizationMetaDataFromJson([]byte(testJsonOrgMeta))
+	require.Nil(t, err)
+	memStorage := basestorage.NewMemStorage()
+	modelStorage := NewModelStorage(memStorage, orgMetadata)
+	require.Nil(t, modelStorage.Put(modelStorage.buildModelKey(modelID), &ModelData{ModelId: modelID, Status: Status_CREATED}))
+	versions := NewModelVersions(NewModelVersionStorage(memStorage, orgMetadata), modelStorage)
+	settings := &config.ModelTrainingJobsSettings{Workers: 1, HistorySize: 10}
+	tracker := NewJobTracker(settings, NewModelJobStorage(memStorage, orgMetadata), modelStorage,
+		NewPendingModelStorage(memStorage, orgMetadata), nil, versions, nil)
+	return versions, tracker
+}
+
+// runJob starts the version of the job and finishes it with the status
+func runJob(t *testing.T, versions *ModelVersions, tracker *JobTracker, method, link string, final *StatusResponse) {
+	model, err := versions.modelStorage.GetModel("1")
+	require.Nil(t, err)
+	model.TrainingLink = link
+	require.Nil(t, versions.modelStorage.Put(versions.modelStorage.buildModelKey("1"), model))
+	pending := Status_VALIDATING
+	if method == "train_model" {
+		pending = Status_TRAINING
+	}
+	_, err = versions.Start(model, method, map[string]string{"link": link}, "0x01", pending)
+	require.Nil(t, err)
+	_, err = tracker.Update("1", &StatusResponse{Status: pending})
+	require.Nil(t, err)
+	_, err = tracker.Update("1", final)
+	require.Nil(t, err)
+}
+
+func TestModelVersions(t *testing.T) {
+	versions, tracker := newTestModelVersions(t, "1")
+
+	runJob(t, versions, tracker, "validate_model", "link1", &StatusResponse{Status: Status_VALIDATED})
+	runJob(t, versions, tracker, "train_model", "", &StatusResponse{Status: Status_READY_TO_USE, Metrics: map[string]float64{"accuracy": 0.5, "loss": 1}})
+	runJob(t, versions, tracker, "validate_model", "link2", &StatusResponse{Status: Status_VALIDATED})
+	runJob(t, versions, tracker, "train_model", "", &StatusResponse{Status: Status_READY_TO_USE, Metrics: map[string]float64{"accuracy": 0.75}})
+	runJob(t, versions, tracker, "train_model", "", &StatusResponse{Status: Status_ERRORED, Message: "out of memory"})
+
+	list, err := versions.List("1")
+	require.Nil(t, err)
+	require.Len(t, list, 5)
+	for i, parent := range []uint64{0, 1, 2, 3, 3} {
+		assert.Equal(t, uint64(i+1), list[i].Version)
+		assert.Equal(t, parent, list[i].ParentVersion)
+		assert.False(t, list[i].FinishedAt.IsZero())
+	}
+	assert.Equal(t, "link1", list[1].TrainingLink)
+	assert.Equal(t, map[string]string{"link": "link2"}, list[3].Parameters)
+	assert.Equal(t, Status_ERRORED, list[4].Status)
+	assert.Equal(t, "out of memory", list[4].Message)
+
+	model, err := versions.modelStorage.GetModel("1")
+	require.Nil(t, err)
+	assert.Equal(t, uint64(4), model.CurrentVersion)
+	assert.Equal(t, Status_ERRORED, model.Status)
+
+	// the finished version isn't changed
+	_, err = tracker.Update("1", &StatusResponse{Status: Status_READY_TO_USE})
+	require.Nil(t, err)
+	list, _ = versions.List("1")
+	assert.Equal(t, Status_ERRORED, list[4].Status)
+
+	comparison, err := versions.Compare("1", 2, 4)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"metrics", "parameters", "training_data_link"}, comparison.ChangedFields)
+	assert.Equal(t, map[string]float64{"accuracy": 0.25}, comparison.MetricsDiff)
+	assert.Equal(t, uint64(2), comparison.CommonAncestor)
+	assert.Equal(t, uint64(3), comparison.Version.ParentVersion)
+
+	_, err = versions.Compare("1", 2, 6)
+	assert.True(t, errors.Is(err, ErrModelVersionDoesntExist))
+}
+
+func TestModelVersions_RollbackAndResolve(t *testing.T) {
+	versions, tracker := newTestModelVersions(t, "1")
+
+	version, err := versions.Resolve("1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", version)
+
+	runJob(t, versions, tracker, "validate_model", "link1", &StatusResponse{Status: Status_VALIDATED})
+	runJob(t, versions, tracker, "train_model", "", &StatusResponse{Status: Status_READY_TO_USE})
+	runJob(t, versions, tracker, "train_model", "", &StatusResponse{Status: Status_READY_TO_USE})
+
+	version, err = versions.Resolve("1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", version)
+
+	_, err = versions.Rollback("1", 1)
+	assert.True(t, errors.Is(err, ErrModelVersionNotReady))
+	_, err = versions.Rollback("1", 7)
+	assert.True(t, errors.Is(err, ErrModelVersionDoesntExist))
+
+	model, err := versions.Rollback("1", 2)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), model.CurrentVersion)
+
+	version, err = versions.Resolve("1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", version)
+	version, err = versions.Resolve("1", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", version)
+
+	for _, pinned := range []string{"1", "4", "latest"} {
+		_, err = versions.Resolve("1", pinned)
+		assert.True(t, errors.Is(err, ErrModelVersion), pinned)
+	}
+	_, err = versions.Resolve("unknown", "1")
+	assert.True(t, errors.Is(err, ErrModelVersionDoesntExist))
+}
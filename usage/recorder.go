@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/ctxkeys"
+	"github.com/singnet/snet-daemon/v6/escrow"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Call describes a single call accepted by the payment validation.
+type Call struct {
+	Time      time.Time
+	Sender    string
+	ChannelID string
+	Method    string
+	Free      bool
+	Failed    bool
+	Cogs      *big.Int
+}
+
+// Recorder aggregates calls into time buckets of the configured duration.
+type Recorder struct {
+	storage *UsageStorage
+	bucket  time.Duration
+}
+
+func NewRecorder(storage *UsageStorage, bucket time.Duration) *Recorder {
+	return &Recorder{storage: storage, bucket: bucket}
+}
+
+// BucketStart returns the start of the bucket the time belongs to.
+func (recorder *Recorder) BucketStart(t time.Time) time.Time {
+	return t.Truncate(recorder.bucket)
+}
+
+// Bucket returns the configured bucket duration.
+func (recorder *Recorder) Bucket() time.Duration {
+	return recorder.bucket
+}
+
+func (recorder *Recorder) Record(call *Call) error {
+	key := &UsageKey{
+		Bucket:    recorder.BucketStart(call.Time).Unix(),
+		Sender:    call.Sender,
+		ChannelID: call.ChannelID,
+		Method:    call.Method,
+	}
+	delta := &UsageData{Calls: 1, Cogs: call.Cogs}
+	if call.Free {
+		delta.FreeCalls = 1
+	}
+	if call.Failed {
+		delta.Errors = 1
+	}
+	return recorder.storage.Add(key, delta)
+}
+
+// GrpcUsageInterceptor records every call which passed the payment validation.
+// It should be chained after the payment validation interceptor to be able to
+// read the payment details from the stream context.
+func GrpcUsageInterceptor(recorder *Recorder) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, grpcHandler grpc.StreamHandler) error {
+		start := time.Now()
+		err := grpcHandler(srv, ss)
+		call := callFromContext(ss.Context(), info.FullMethod, err != nil)
+		call.Time = start
+		go func() {
+			if recordErr := recorder.Record(call); recordErr != nil {
+				zap.L().Warn("unable to record usage", zap.Error(recordErr), zap.String("method", call.Method))
+			}
+		}()
+		return err
+	}
+}
+
+func callFromContext(ctx context.Context, method string, failed bool) *Call {
+	call := &Call{Method: method, Failed: failed, Cogs: big.NewInt(0)}
+	if sender, ok := ctx.Value(ctxkeys.SenderKey).(common.Address); ok {
+		call.Sender = sender.Hex()
+	}
+	if paymentType, ok := ctx.Value(ctxkeys.PaymentTypeKey).(string); ok {
+		call.Free = paymentType == escrow.FreeCallPaymentType
+	}
+	// the payment is rolled back or refunded when the service returns an error
+	if amount, ok := ctx.Value(ctxkeys.ChargedAmountKey).(*big.Int); ok && amount != nil && !failed {
+		call.Cogs = amount
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && !call.Free {
+		if values := md.Get(handler.PaymentChannelIDHeader); len(values) > 0 {
+			call.ChannelID = values[0]
+		}
+	}
+	return call
+}
@@ -0,0 +1,146 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects the usage records to report, empty fields match any value.
+type Filter struct {
+	From      time.Time
+	To        time.Time
+	Sender    string
+	ChannelID string
+	Method    string
+}
+
+func (filter *Filter) matches(data *UsageData) bool {
+	if !filter.From.IsZero() && data.Bucket < filter.From.Unix() {
+		return false
+	}
+	if !filter.To.IsZero() && data.Bucket >= filter.To.Unix() {
+		return false
+	}
+	if filter.Sender != "" && !strings.EqualFold(filter.Sender, data.Sender) {
+		return false
+	}
+	if filter.ChannelID != "" && filter.ChannelID != data.ChannelID {
+		return false
+	}
+	if filter.Method != "" && filter.Method != data.Method {
+		return false
+	}
+	return true
+}
+
+// Report is a usage record with explicit bucket bounds.
+type Report struct {
+	BucketStart time.Time `json:"bucket_start"`
+	BucketEnd   time.Time `json:"bucket_end"`
+	Sender      string    `json:"sender"`
+	ChannelID   string    `json:"channel_id"`
+	Method      string    `json:"method"`
+	Calls       uint64    `json:"calls"`
+	FreeCalls   uint64    `json:"free_calls"`
+	Errors      uint64    `json:"errors"`
+	Cogs        *big.Int  `json:"cogs"`
+}
+
+// Query returns the records matching the filter ordered by bucket, sender,
+// channel and method.
+func Query(storage *UsageStorage, bucket time.Duration, filter *Filter) ([]*Report, error) {
+	values, err := storage.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*Report, 0)
+	for _, data := range values {
+		if !filter.matches(data) {
+			continue
+		}
+		cogs := data.Cogs
+		if cogs == nil {
+			cogs = big.NewInt(0)
+		}
+		start := time.Unix(data.Bucket, 0).UTC()
+		reports = append(reports, &Report{
+			BucketStart: start,
+			BucketEnd:   start.Add(bucket),
+			Sender:      data.Sender,
+			ChannelID:   data.ChannelID,
+			Method:      data.Method,
+			Calls:       data.Calls,
+			FreeCalls:   data.FreeCalls,
+			Errors:      data.Errors,
+			Cogs:        cogs,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if !a.BucketStart.Equal(b.BucketStart) {
+			return a.BucketStart.Before(b.BucketStart)
+		}
+		if a.Sender != b.Sender {
+			return a.Sender < b.Sender
+		}
+		if a.ChannelID != b.ChannelID {
+			return a.ChannelID < b.ChannelID
+		}
+		return a.Method < b.Method
+	})
+	return reports, nil
+}
+
+var csvHeader = []string{"bucket_start", "bucket_end", "sender", "channel_id", "method", "calls", "free_calls", "errors", "cogs"}
+
+// WriteCSV writes the reports as CSV with a header line.
+func WriteCSV(writer io.Writer, reports []*Report) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, report := range reports {
+		err := csvWriter.Write([]string{
+			report.BucketStart.Format(time.RFC3339),
+			report.BucketEnd.Format(time.RFC3339),
+			report.Sender,
+			report.ChannelID,
+			report.Method,
+			strconv.FormatUint(report.Calls, 10),
+			strconv.FormatUint(report.FreeCalls, 10),
+			strconv.FormatUint(report.Errors, 10),
+			report.Cogs.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// WriteJSON writes the reports as an indented JSON array.
+func WriteJSON(writer io.Writer, reports []*Report) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(reports)
+}
+
+// Write writes the reports in the given format, "csv" or "json".
+func Write(writer io.Writer, format string, reports []*Report) error {
+	switch strings.ToLower(format) {
+	case "csv":
+		return WriteCSV(writer, reports)
+	case "json":
+		return WriteJSON(writer, reports)
+	default:
+		return fmt.Errorf("unknown format %q, supported formats are csv and json", format)
+	}
+}
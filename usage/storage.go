@@ -0,0 +1,111 @@
+//go:generate protoc -I . ./usage_service.proto --go-grpc_out=paths=source_relative:. --go_out=paths=source_relative:.
+package usage
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/singnet/snet-daemon/v6/storage"
+	"github.com/singnet/snet-daemon/v6/utils"
+)
+
+// UsageKey identifies the usage of a method by a sender via a payment channel
+// within a single time bucket.
+type UsageKey struct {
+	// Bucket is a unix timestamp (seconds) of the bucket start
+	Bucket    int64
+	Sender    string
+	ChannelID string
+	Method    string
+}
+
+func (key *UsageKey) String() string {
+	return fmt.Sprintf("{ID:%v|%v|%v|%v}", key.Bucket, key.Sender, key.ChannelID, key.Method)
+}
+
+// UsageData is the usage aggregated for a single UsageKey.
+type UsageData struct {
+	Bucket    int64
+	Sender    string
+	ChannelID string
+	Method    string
+	Calls     uint64
+	FreeCalls uint64
+	Errors    uint64
+	Cogs      *big.Int
+}
+
+func (data *UsageData) String() string {
+	return fmt.Sprintf("{DATA:%v|%v|%v|%v|Calls:%v|FreeCalls:%v|Errors:%v|Cogs:%v}",
+		data.Bucket, data.Sender, data.ChannelID, data.Method, data.Calls, data.FreeCalls, data.Errors, data.Cogs)
+}
+
+// UsageStorage keeps the usage records shared by all the replicas of the group.
+type UsageStorage struct {
+	delegate storage.TypedAtomicStorage
+}
+
+func NewUsageStorage(atomicStorage storage.AtomicStorage) *UsageStorage {
+	prefixedStorage := storage.NewPrefixedAtomicStorage(atomicStorage, "/usage/storage")
+	usageStorage := storage.NewTypedAtomicStorageImpl(
+		prefixedStorage, serializeUsageKey, reflect.TypeFor[UsageKey](), utils.Serialize, utils.Deserialize,
+		reflect.TypeFor[UsageData](),
+	)
+	return &UsageStorage{delegate: usageStorage}
+}
+
+func serializeUsageKey(key any) (serialized string, err error) {
+	usageKey := key.(*UsageKey)
+	return usageKey.String(), nil
+}
+
+func (usageStorage *UsageStorage) Get(key *UsageKey) (data *UsageData, ok bool, err error) {
+	value, ok, err := usageStorage.delegate.Get(key)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return value.(*UsageData), ok, err
+}
+
+func (usageStorage *UsageStorage) GetAll() (data []*UsageData, err error) {
+	values, err := usageStorage.delegate.GetAll()
+	if err != nil || values == nil {
+		return nil, err
+	}
+	return values.([]*UsageData), nil
+}
+
+// Add atomically adds the counters of delta to the record stored by key.
+func (usageStorage *UsageStorage) Add(key *UsageKey, delta *UsageData) error {
+	updateFunc := func(conditionValues []storage.TypedKeyValueData) (update []storage.TypedKeyValueData, ok bool, err error) {
+		data := &UsageData{Bucket: key.Bucket, Sender: key.Sender, ChannelID: key.ChannelID, Method: key.Method, Cogs: big.NewInt(0)}
+		if len(conditionValues) == 1 && conditionValues[0].Present {
+			data = conditionValues[0].Value.(*UsageData)
+			if data.Cogs == nil {
+				data.Cogs = big.NewInt(0)
+			}
+		}
+		data.Calls += delta.Calls
+		data.FreeCalls += delta.FreeCalls
+		data.Errors += delta.Errors
+		if delta.Cogs != nil {
+			data.Cogs = new(big.Int).Add(data.Cogs, delta.Cogs)
+		}
+		return []storage.TypedKeyValueData{{Key: key, Value: data, Present: true}}, true, nil
+	}
+
+	request := storage.TypedCASRequest{
+		ConditionKeys:           []any{key},
+		Update:                  updateFunc,
+		RetryTillSuccessOrError: true,
+	}
+	ok, err := usageStorage.delegate.ExecuteTransaction(request)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unable to update usage for %v", key)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/singnet/snet-daemon/v6/blockchain"
+	"github.com/singnet/snet-daemon/v6/escrow"
+	"github.com/singnet/snet-daemon/v6/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UsageReportServiceImpl returns the usage records to the service provider,
+// the request should be signed by the payment address of the group.
+type UsageReportServiceImpl struct {
+	UnimplementedUsageReportServiceServer
+	storage              *UsageStorage
+	bucket               time.Duration
+	organizationMetaData *blockchain.OrganizationMetaData
+	blockchain           blockchain.Processor
+	mpeAddress           common.Address
+}
+
+// DisabledUsageReportService is used when usage reporting or blockchain is disabled.
+type DisabledUsageReportService struct {
+	UnimplementedUsageReportServiceServer
+}
+
+func (service *DisabledUsageReportService) GetUsageReport(ctx context.Context, request *UsageReportRequest) (*UsageReportReply, error) {
+	return nil, status.Error(codes.Unavailable, "usage reporting is disabled")
+}
+
+func NewUsageReportService(storage *UsageStorage, bucket time.Duration, blockchainProcessor blockchain.Processor,
+	serMetaData *blockchain.ServiceMetadata, orgMetadata *blockchain.OrganizationMetaData) *UsageReportServiceImpl {
+	return &UsageReportServiceImpl{
+		storage:              storage,
+		bucket:               bucket,
+		organizationMetaData: orgMetadata,
+		blockchain:           blockchainProcessor,
+		mpeAddress:           common.HexToAddress(serMetaData.MpeAddress),
+	}
+}
+
+/*
+	GetUsageReport
+
+Verify that mpe_address is correct
+Verify that actual block_number is not very different (+-5 blocks) from the current_block_number from the signature
+Verify that message was signed by the service provider (“payment_address” in metadata should match to the signer).
+Send the usage records matching the filter
+*/
+func (service *UsageReportServiceImpl) GetUsageReport(ctx context.Context, request *UsageReportRequest) (*UsageReportReply, error) {
+	if common.HexToAddress(request.GetMpeAddress()) != service.mpeAddress {
+		return nil, status.Errorf(codes.InvalidArgument, "the mpeAddress: %s passed does not match to what has been registered", request.GetMpeAddress())
+	}
+	if err := service.blockchain.CompareWithLatestBlockNumber(big.NewInt(int64(request.CurrentBlock)), escrow.AllowedBlockDifference); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := service.verifySigner(request); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	filter := &Filter{Sender: request.GetSender(), ChannelID: request.GetChannelId(), Method: request.GetMethod()}
+	if request.GetFrom() > 0 {
+		filter.From = time.Unix(request.GetFrom(), 0)
+	}
+	if request.GetTo() > 0 {
+		filter.To = time.Unix(request.GetTo(), 0)
+	}
+	reports, err := Query(service.storage, service.bucket, filter)
+	if err != nil {
+		zap.L().Error("unable to read usage records", zap.Error(err))
+		return nil, status.Error(codes.Internal, fmt.Sprintf("unable to read usage records: %v", err))
+	}
+
+	reply := &UsageReportReply{Records: make([]*UsageRecord, 0, len(reports))}
+	for _, report := range reports {
+		reply.Records = append(reply.Records, &UsageRecord{
+			BucketStart: report.BucketStart.Unix(),
+			BucketEnd:   report.BucketEnd.Unix(),
+			Sender:      report.Sender,
+			ChannelId:   report.ChannelID,
+			Method:      report.Method,
+			Calls:       report.Calls,
+			FreeCalls:   report.FreeCalls,
+			Errors:      report.Errors,
+			Cogs:        common.BigToHash(report.Cogs).Bytes(),
+		})
+	}
+	return reply, nil
+}
+
+// ("__get_usage_report", mpe_address, current_block_number)
+func (service *UsageReportServiceImpl) verifySigner(request *UsageReportRequest) error {
+	message := bytes.Join([][]byte{
+		[]byte("__get_usage_report"),
+		service.mpeAddress.Bytes(),
+		math.U256Bytes(big.NewInt(int64(request.CurrentBlock))),
+	}, nil)
+	signer, err := utils.GetSignerAddressFromMessage(message, request.GetSignature())
+	if err != nil {
+		zap.L().Error(err.Error())
+		return err
+	}
+	return utils.VerifyAddress(*signer, service.organizationMetaData.GetPaymentAddress())
+}
@@ -0,0 +1,130 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/v6/ctxkeys"
+	"github.com/singnet/snet-daemon/v6/escrow"
+	"github.com/singnet/snet-daemon/v6/handler"
+	"github.com/singnet/snet-daemon/v6/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/metadata"
+)
+
+type UsageTestSuite struct {
+	suite.Suite
+	storage  *UsageStorage
+	recorder *Recorder
+	start    time.Time
+}
+
+func (suite *UsageTestSuite) SetupTest() {
+	suite.storage = NewUsageStorage(storage.NewMemStorage())
+	suite.recorder = NewRecorder(suite.storage, time.Hour)
+	suite.start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+}
+
+func TestUsageTestSuite(t *testing.T) {
+	suite.Run(t, new(UsageTestSuite))
+}
+
+func (suite *UsageTestSuite) record(call Call) {
+	assert.Nil(suite.T(), suite.recorder.Record(&call))
+}
+
+func (suite *UsageTestSuite) TestRecordAggregatesByBucket() {
+	suite.record(Call{Time: suite.start.Add(5 * time.Minute), Sender: "0x1", ChannelID: "1", Method: "/svc/add", Cogs: big.NewInt(10)})
+	suite.record(Call{Time: suite.start.Add(50 * time.Minute), Sender: "0x1", ChannelID: "1", Method: "/svc/add", Cogs: big.NewInt(0), Failed: true})
+	suite.record(Call{Time: suite.start.Add(70 * time.Minute), Sender: "0x1", ChannelID: "1", Method: "/svc/add", Cogs: big.NewInt(10)})
+	suite.record(Call{Time: suite.start.Add(10 * time.Minute), Sender: "0x2", Method: "/svc/add", Free: true})
+
+	data, ok, err := suite.storage.Get(&UsageKey{Bucket: suite.start.Unix(), Sender: "0x1", ChannelID: "1", Method: "/svc/add"})
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), uint64(2), data.Calls)
+	assert.Equal(suite.T(), uint64(1), data.Errors)
+	assert.Equal(suite.T(), big.NewInt(10), data.Cogs)
+
+	reports, err := Query(suite.storage, time.Hour, &Filter{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 3, len(reports))
+	assert.Equal(suite.T(), "0x1", reports[0].Sender)
+	assert.Equal(suite.T(), "0x2", reports[1].Sender)
+	assert.Equal(suite.T(), uint64(1), reports[1].FreeCalls)
+	assert.Equal(suite.T(), suite.start.Add(time.Hour), reports[2].BucketStart)
+	assert.Equal(suite.T(), suite.start.Add(2*time.Hour), reports[2].BucketEnd)
+}
+
+func (suite *UsageTestSuite) TestQueryFilter() {
+	suite.record(Call{Time: suite.start, Sender: "0xAb", ChannelID: "1", Method: "/svc/add", Cogs: big.NewInt(1)})
+	suite.record(Call{Time: suite.start, Sender: "0xCd", ChannelID: "2", Method: "/svc/sub", Cogs: big.NewInt(1)})
+	suite.record(Call{Time: suite.start.Add(time.Hour), Sender: "0xAb", ChannelID: "1", Method: "/svc/add", Cogs: big.NewInt(1)})
+
+	reports, err := Query(suite.storage, time.Hour, &Filter{Sender: "0xab"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(reports))
+
+	reports, err = Query(suite.storage, time.Hour, &Filter{Method: "/svc/sub"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(reports))
+	assert.Equal(suite.T(), "2", reports[0].ChannelID)
+
+	reports, err = Query(suite.storage, time.Hour, &Filter{From: suite.start.Add(time.Minute)})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(reports))
+
+	reports, err = Query(suite.storage, time.Hour, &Filter{To: suite.start.Add(time.Hour)})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(reports))
+}
+
+func (suite *UsageTestSuite) TestWrite() {
+	suite.record(Call{Time: suite.start, Sender: "0x1", ChannelID: "1", Method: "/svc/add", Cogs: big.NewInt(42)})
+	reports, err := Query(suite.storage, time.Hour, &Filter{})
+	assert.Nil(suite.T(), err)
+
+	var csvOut bytes.Buffer
+	assert.Nil(suite.T(), Write(&csvOut, "csv", reports))
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	assert.Equal(suite.T(), 2, len(lines))
+	assert.Equal(suite.T(), "bucket_start,bucket_end,sender,channel_id,method,calls,free_calls,errors,cogs", lines[0])
+	assert.Equal(suite.T(), "2024-05-01T10:00:00Z,2024-05-01T11:00:00Z,0x1,1,/svc/add,1,0,0,42", lines[1])
+
+	var jsonOut bytes.Buffer
+	assert.Nil(suite.T(), Write(&jsonOut, "json", reports))
+	var decoded []map[string]any
+	assert.Nil(suite.T(), json.Unmarshal(jsonOut.Bytes(), &decoded))
+	assert.Equal(suite.T(), float64(42), decoded[0]["cogs"])
+
+	assert.NotNil(suite.T(), Write(&jsonOut, "xml", reports))
+}
+
+func TestCallFromContext(t *testing.T) {
+	sender := common.HexToAddress("0x94d04332C4f5273feF69c4a52D24f42a3aF1F207")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(handler.PaymentChannelIDHeader, "7"))
+	ctx = context.WithValue(ctx, ctxkeys.PaymentTypeKey, escrow.EscrowPaymentType)
+	ctx = context.WithValue(ctx, ctxkeys.SenderKey, sender)
+	ctx = context.WithValue(ctx, ctxkeys.ChargedAmountKey, big.NewInt(5))
+
+	call := callFromContext(ctx, "/svc/add", false)
+	assert.Equal(t, sender.Hex(), call.Sender)
+	assert.Equal(t, "7", call.ChannelID)
+	assert.Equal(t, big.NewInt(5), call.Cogs)
+	assert.False(t, call.Free)
+
+	call = callFromContext(ctx, "/svc/add", true)
+	assert.True(t, call.Failed)
+	assert.Equal(t, big.NewInt(0), call.Cogs)
+
+	call = callFromContext(context.WithValue(ctx, ctxkeys.PaymentTypeKey, escrow.FreeCallPaymentType), "/svc/add", false)
+	assert.True(t, call.Free)
+	assert.Equal(t, "", call.ChannelID)
+}